@@ -0,0 +1,97 @@
+package anomaly
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+)
+
+// kinds of things on-call wants to see in one place
+const (
+	DeadLetter     = "dead_letter"     // a job that failed for good
+	WebhookFailure = "webhook_failure" // a delivery that used up its attempts
+	SlowRequest    = "slow_request"
+)
+
+type Anomaly struct {
+	Kind    string            `json:"kind"`
+	Message string            `json:"message"`
+	At      time.Time         `json:"at"`
+	Attrs   map[string]string `json:"attrs,omitempty"`
+}
+
+// ring keeps only the last n anomalies of one kind, old ones are overwritten so memory never grows
+type ring struct {
+	items []Anomaly
+	next  int
+	full  bool
+	total int // how many were ever recorded, also the ones already overwritten
+}
+
+func (r *ring) add(a Anomaly) {
+	r.items[r.next] = a
+	r.next = (r.next + 1) % len(r.items)
+	if r.next == 0 {
+		r.full = true
+	}
+	r.total++
+}
+
+// list returns oldest first
+func (r *ring) list() []Anomaly {
+	if !r.full {
+		return append([]Anomaly(nil), r.items[:r.next]...)
+	}
+	return append(append([]Anomaly(nil), r.items[r.next:]...), r.items[:r.next]...)
+}
+
+// Recorder is shared by all subsystems, each kind gets its own ring so a flood of one kind can not hide the others
+type Recorder struct {
+	mu    sync.Mutex
+	size  int
+	clock clock.Clock
+	rings map[string]*ring
+}
+
+func NewRecorder(size int, clk clock.Clock) *Recorder {
+	if size < 1 {
+		size = 1
+	}
+	return &Recorder{size: size, clock: clk, rings: map[string]*ring{}}
+}
+
+func (rec *Recorder) Record(kind string, message string, attrs map[string]string) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	r, ok := rec.rings[kind]
+	if !ok {
+		r = &ring{items: make([]Anomaly, rec.size)}
+		rec.rings[kind] = r
+	}
+	r.add(Anomaly{Kind: kind, Message: message, At: rec.clock.Now().UTC(), Attrs: attrs})
+}
+
+// Summary is what the runbook endpoint returns for one kind
+type Summary struct {
+	Kind   string    `json:"kind"`
+	Total  int       `json:"total"`
+	Recent []Anomaly `json:"recent"` // newest first
+}
+
+// Snapshot copies all rings, kinds sorted by name so the output is stable
+func (rec *Recorder) Snapshot() []Summary {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	out := make([]Summary, 0, len(rec.rings))
+	for kind, r := range rec.rings {
+		recent := r.list()
+		for i, j := 0, len(recent)-1; i < j; i, j = i+1, j-1 {
+			recent[i], recent[j] = recent[j], recent[i]
+		}
+		out = append(out, Summary{Kind: kind, Total: r.total, Recent: recent})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Kind < out[j].Kind })
+	return out
+}
@@ -0,0 +1,38 @@
+package anomaly_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/anomaly"
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+)
+
+func TestRecorderKeepsLastNPerKind(t *testing.T) {
+	t.Parallel()
+
+	clk := clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	rec := anomaly.NewRecorder(2, clk)
+
+	for _, msg := range []string{"q1", "q2", "q3"} {
+		rec.Record(anomaly.SlowRequest, msg, nil)
+		clk.Advance(time.Second)
+	}
+	rec.Record(anomaly.DeadLetter, "job 9", map[string]string{"job": "9"})
+
+	got := rec.Snapshot()
+	if len(got) != 2 {
+		t.Fatalf("want 2 kinds, got %d", len(got))
+	}
+	if got[0].Kind != anomaly.DeadLetter || got[1].Kind != anomaly.SlowRequest {
+		t.Fatalf("kinds not sorted: %q, %q", got[0].Kind, got[1].Kind)
+	}
+
+	slow := got[1]
+	if slow.Total != 3 {
+		t.Fatalf("want total 3, got %d", slow.Total)
+	}
+	if len(slow.Recent) != 2 || slow.Recent[0].Message != "q3" || slow.Recent[1].Message != "q2" {
+		t.Fatalf("want [q3 q2] newest first, got %+v", slow.Recent)
+	}
+}
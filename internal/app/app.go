@@ -0,0 +1,605 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/felixge/fgprof"
+	"github.com/manishtomar-cpi/go-server/internal/anomaly"
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+	"github.com/manishtomar-cpi/go-server/internal/auth/password"
+	"github.com/manishtomar-cpi/go-server/internal/buildinfo"
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/errorreport"
+	"github.com/manishtomar-cpi/go-server/internal/events"
+	"github.com/manishtomar-cpi/go-server/internal/export"
+	"github.com/manishtomar-cpi/go-server/internal/health"
+	"github.com/manishtomar-cpi/go-server/internal/http/handllers/admin"
+	authhandler "github.com/manishtomar-cpi/go-server/internal/http/handllers/auth"
+	healthhandler "github.com/manishtomar-cpi/go-server/internal/http/handllers/health"
+	livehandler "github.com/manishtomar-cpi/go-server/internal/http/handllers/live"
+	student "github.com/manishtomar-cpi/go-server/internal/http/handllers/students"
+	"github.com/manishtomar-cpi/go-server/internal/http/middleware"
+	"github.com/manishtomar-cpi/go-server/internal/http/router"
+	httpserver "github.com/manishtomar-cpi/go-server/internal/http/server"
+	"github.com/manishtomar-cpi/go-server/internal/i18n"
+	"github.com/manishtomar-cpi/go-server/internal/idempotency"
+	"github.com/manishtomar-cpi/go-server/internal/ids"
+	"github.com/manishtomar-cpi/go-server/internal/importer"
+	"github.com/manishtomar-cpi/go-server/internal/jobs"
+	"github.com/manishtomar-cpi/go-server/internal/live"
+	"github.com/manishtomar-cpi/go-server/internal/messaging"
+	"github.com/manishtomar-cpi/go-server/internal/metrics"
+	"github.com/manishtomar-cpi/go-server/internal/notify"
+	"github.com/manishtomar-cpi/go-server/internal/observability"
+	"github.com/manishtomar-cpi/go-server/internal/openapi"
+	"github.com/manishtomar-cpi/go-server/internal/outbox"
+	"github.com/manishtomar-cpi/go-server/internal/ratelimit"
+	"github.com/manishtomar-cpi/go-server/internal/rpc"
+	"github.com/manishtomar-cpi/go-server/internal/rpc/studentpb"
+	"github.com/manishtomar-cpi/go-server/internal/scheduler"
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/storage/cache"
+	"github.com/manishtomar-cpi/go-server/internal/storage/sqlite"
+	"github.com/manishtomar-cpi/go-server/internal/utills/request"
+	"github.com/manishtomar-cpi/go-server/internal/validation"
+	"github.com/manishtomar-cpi/go-server/internal/webhook"
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
+)
+
+// App is the whole server -> storage, router, middlewares and both listeners.
+// main only loads config and calls Run, tests and other programs can embed it the same way
+type App struct {
+	cfg   *config.Config
+	hooks Hooks
+
+	logger      *slog.Logger // base of the request scoped loggers
+	logLevel    *slog.LevelVar
+	version     string // release reported with errors
+	reporter    errorreport.Reporter
+	clock       clock.Clock
+	ids         ids.IDSource
+	storage     *sqlite.Sqlite
+	students    storage.Storage // storage, behind the student cache when it is on
+	bus         *events.Bus
+	anomalies   *anomaly.Recorder
+	readiness   *health.Readiness
+	checker     *health.Checker // dependency checks behind /readyz
+	maintenance *health.Maintenance
+	inFlight    *middleware.InFlight
+	jobs        *jobs.Queue
+	webhooks    *webhook.Dispatcher
+	importer    *importer.Importer   // large csv/ndjson uploads, stored by a job
+	notifier    *notify.Notifier     // nil when email.host is empty
+	outbox      *outbox.Relay        // nil when outbox.sink is empty
+	scheduler   *scheduler.Scheduler // periodic cleanups
+	hub         *live.Hub            // websocket and server-sent events clients
+	registry    *prometheus.Registry // all prometheus metrics, served on the admin listener
+
+	// tried in order for every request, jwt/api key/... add themselves here
+	authenticators auth.Chain
+
+	handler      http.Handler // public api with all middlewares
+	patterns     []string     // of the public router, see Routes
+	adminHandler http.Handler
+
+	server      *http.Server
+	adminServer *http.Server
+	http3Server *http3.Server // nil when http3 is off
+	grpcServer  *grpc.Server  // nil when grpc_server.address is empty
+
+	mu        sync.Mutex
+	addr      net.Addr
+	adminAddr net.Addr
+	grpcAddr  net.Addr
+	started   chan struct{} // closed once the listeners are open
+
+	shutdownOnce sync.Once
+	shutdownErr  error
+}
+
+// Option changes how the app is wired, mostly for tests
+type Option func(a *App)
+
+// WithClock replaces the real clock, tests use clock.Fake to freeze time
+func WithClock(clk clock.Clock) Option {
+	return func(a *App) { a.clock = clk }
+}
+
+// WithLogger replaces the default logger as the base of every request logger,
+// level is the one the logger was built with so the admin endpoint can change it
+func WithLogger(logger *slog.Logger, level *slog.LevelVar) Option {
+	return func(a *App) {
+		a.logger = logger
+		a.logLevel = level
+	}
+}
+
+// WithVersion sets the release errors are reported with, main passes the version it was built with
+func WithVersion(version string) Option {
+	return func(a *App) { a.version = version }
+}
+
+// WithIDSource replaces the uuid generator, tests use ids.Sequence for predictable ids
+func WithIDSource(src ids.IDSource) Option {
+	return func(a *App) { a.ids = src }
+}
+
+// New opens storage and wires everything, nothing listens until Run is called
+func New(cfg *config.Config, opts ...Option) (*App, error) {
+	a := &App{
+		cfg:         cfg,
+		logger:      slog.Default(),
+		logLevel:    new(slog.LevelVar), // only changes something when WithLogger passes the level of the logger
+		version:     buildinfo.Version,
+		clock:       clock.System{},
+		ids:         ids.UUID{},
+		bus:         events.NewBus(),     // in-process pub/sub for domain events
+		readiness:   &health.Readiness{}, // not ready until warm-up is done
+		maintenance: &health.Maintenance{},
+		inFlight:    &middleware.InFlight{},
+		registry:    metrics.NewRegistry(),
+		started:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	a.anomalies = anomaly.NewRecorder(cfg.Anomalies.BufferSize, a.clock)
+	metrics.RegisterRuntime(a.registry)
+	a.maintenance.SetEnabled(cfg.Maintenance.Enabled)
+	a.checker = health.NewChecker(a.readiness, cfg.Health.CheckTimeout, cfg.Health.RefreshInterval)
+	if cfg.I18n.Dir != "" {
+		if err := i18n.LoadDir(cfg.I18n.Dir); err != nil {
+			return nil, fmt.Errorf("i18n.dir: %w", err)
+		}
+	}
+	if err := validation.Configure(cfg.Validation); err != nil {
+		return nil, err
+	}
+	request.SetLenient(cfg.Decoding.Lenient)
+	request.SetMaxBody(cfg.Decoding.MaxBody)
+
+	// observability first, so everything created after it already uses the real tracer and meter providers
+	shutdownObservability, err := observability.Setup(context.Background(), cfg.Observability)
+	if err != nil {
+		return nil, err
+	}
+	a.OnShutdown(shutdownObservability) // registered first so it runs last and still exports the spans of the shutdown
+
+	reporter, flushErrors, err := errorreport.Setup(cfg.Errors, cfg.Env, a.version)
+	if err != nil {
+		return nil, err
+	}
+	a.reporter = reporter
+	a.OnShutdown(flushErrors)
+
+	//db setup
+	storage, err := sqlite.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	a.storage = storage
+	//things to close on shutdown, they run in reverse order after the http servers are drained
+	a.OnShutdown(func(ctx context.Context) error {
+		return storage.Close()
+	})
+	a.checker.Add(health.Check{Name: "database", Run: storage.Ping})
+	a.checker.Add(health.Check{Name: "schema", Run: storage.SchemaReady})
+	a.students = storage
+	if cfg.StudentCache.Enabled {
+		a.students = cache.New(storage, cfg.StudentCache, a.clock, metrics.NewCache(a.registry))
+	}
+
+	// background work runs on the job queue, Run starts it and the jobs still running are waited for before the db closes
+	a.jobs = jobs.New(storage, cfg.Jobs, a.clock, a.anomalies)
+	a.OnShutdown(a.jobs.Drain)
+	// every public event is queued for the registered webhooks, the job queue sends them
+	a.webhooks = webhook.NewDispatcher(storage, a.bus, a.jobs, cfg.Webhooks, a.clock, a.anomalies)
+	// large imports are stored by a job too, the client follows it by the job id
+	imports := cfg.Imports
+	if imports.Dir == "" {
+		imports.Dir = filepath.Join(filepath.Dir(cfg.Storage_path), "imports")
+	}
+	a.importer = importer.New(storage, a.bus, a.jobs, imports, a.clock)
+	// emails to students go out on the job queue as well
+	if cfg.Email.Host != "" {
+		sender, err := notify.NewSMTP(cfg.Email, a.clock)
+		if err != nil {
+			return nil, err
+		}
+		a.notifier = notify.NewNotifier(storage, a.students, a.bus, a.jobs, sender, cfg.Email, a.clock)
+	}
+	// jobs.retry is checked once every kind is registered
+	if err := a.jobs.Validate(); err != nil {
+		return nil, err
+	}
+	// one nats connection for everything, it is drained after the outbox relay stopped publishing on it
+	var nc *nats.Conn
+	if cfg.Messaging.NATS.URL != "" {
+		nc, err = messaging.Connect(cfg.Messaging.NATS)
+		if err != nil {
+			return nil, err
+		}
+		a.OnShutdown(messaging.Close(nc))
+		a.checker.Add(health.Check{Name: "nats", Run: messaging.Check(nc)})
+		if cfg.Messaging.NATS.Serve {
+			responder := messaging.NewResponder(a.students, cfg.Messaging.NATS.RequestTimeout)
+			if err := responder.Serve(nc, cfg.Messaging.NATS.Prefix, cfg.Messaging.NATS.QueueGroup); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// public events also go to the external sink through the outbox, the sink closes after the relay stopped
+	if cfg.Outbox.Sink != "" {
+		sink, err := outbox.New(cfg.Outbox, nc)
+		if err != nil {
+			return nil, err
+		}
+		a.OnShutdown(func(ctx context.Context) error {
+			return sink.Close()
+		})
+		a.outbox = outbox.NewRelay(storage, sink, a.bus, cfg.Outbox, a.clock, metrics.NewOutbox(a.registry))
+		a.OnShutdown(a.outbox.Wait)
+	}
+	// periodic tasks start with Run too, a shutdown waits for the one running so the db is not closed under it
+	a.scheduler = scheduler.New(a.clock, metrics.NewScheduler(a.registry))
+	a.OnShutdown(a.scheduler.Wait)
+	if err := a.scheduleTasks(cfg.Scheduler); err != nil {
+		return nil, err
+	}
+
+	if err := a.routes(); err != nil {
+		return nil, err
+	}
+
+	a.http3Server = httpserver.NewHTTP3(cfg.HTTPServer, a.handler)
+	a.server = httpserver.New(cfg.HTTPServer, httpserver.AltSvc(a.http3Server)(a.handler))
+	a.server.RegisterOnShutdown(a.hub.Close) // ends websocket and event streams, they would keep Shutdown waiting otherwise
+	a.adminServer = httpserver.New(cfg.AdminServer, a.adminHandler)
+
+	// grpc for internal services, same storage and the same authenticators as the http api
+	if cfg.GRPCServer.Address != "" {
+		a.grpcServer, err = rpc.NewServer(cfg.GRPCServer, a.authenticators)
+		if err != nil {
+			return nil, err
+		}
+		studentpb.RegisterStudentServiceServer(a.grpcServer, rpc.NewStudents(a.students, a.bus, a.clock))
+	}
+	return a, nil
+}
+
+// routes builds the public and admin handlers
+func (a *App) routes() error {
+	cfg := a.cfg
+
+	trusted, err := middleware.ParseTrustedProxies(cfg.Proxy.TrustedProxies)
+	if err != nil {
+		return err
+	}
+
+	//setup router
+	//router.New() is like express.Router(), Group("/api") is like app.use('/api', apiRouter)
+	//HandleFunc("GET /", handler) is like app.get('/', handler)
+	rt := router.New()
+	// everything is under /api/v1, a breaking change to a response goes to a new rt.Version("/api", "v2") group.
+	// the unversioned paths from before still answer, with a Deprecation header pointing at the v1 path
+	v1 := rt.Version("/api", "v1")
+	if !cfg.APIVersions.DisableLegacy {
+		var sunset time.Time
+		if cfg.APIVersions.LegacySunset != "" {
+			if sunset, err = time.Parse(time.RFC3339, cfg.APIVersions.LegacySunset); err != nil {
+				return fmt.Errorf("api_versions.legacy_sunset: %w", err)
+			}
+		}
+		v1.Alias("/api", middleware.Deprecated(func(path string) string {
+			return "/api/v1" + strings.TrimPrefix(path, "/api")
+		}, sunset))
+	}
+	api := v1.Group("", middleware.Timeout(cfg.Timeouts.Default))
+
+	// config users first, then the accounts people registered themselves
+	hasher := password.New(cfg.Password)
+	staticUsers := auth.NewStaticUsers(cfg.Users, hasher)
+	accounts := auth.NewAccounts(a.storage, a.clock, hasher, staticUsers)
+	api.HandleFunc("POST /auth/register", authhandler.Register(accounts))
+
+	// jwt login, tokens are checked for every request by the Authenticate middleware below
+	tokens, err := auth.NewJWT(cfg.JWT, a.clock)
+	switch {
+	case errors.Is(err, auth.ErrJWTDisabled):
+		slog.Warn("jwt is not configured, login is off and only api keys can use the student routes")
+	case err != nil:
+		return err
+	default:
+		a.authenticators = append(a.authenticators, tokens)
+		refresh := auth.NewRefreshTokens(a.storage, a.clock, cfg.JWT.RefreshTTL)
+		throttle := auth.NewLoginThrottle(cfg.LoginThrottle, a.clock, metrics.NewAuth(a.registry), a.bus)
+		api.HandleFunc("POST /auth/login", authhandler.Login(auth.Credentials{staticUsers, accounts}, tokens, refresh, throttle))
+		api.HandleFunc("POST /auth/refresh", authhandler.Refresh(tokens, refresh))
+		api.HandleFunc("POST /auth/logout", authhandler.Logout(refresh))
+
+		// sign in with google, microsoft... one login url per configured provider
+		if len(cfg.OIDC) > 0 {
+			providers := make(map[string]*auth.OIDC, len(cfg.OIDC))
+			for name, provider := range cfg.OIDC {
+				providers[name] = auth.NewOIDC(name, provider)
+			}
+			api.HandleFunc("GET /auth/oidc/{provider}/login", authhandler.OIDCLogin(providers))
+			api.HandleFunc("GET /auth/oidc/{provider}/callback", authhandler.OIDCCallback(providers, tokens, refresh))
+		}
+	}
+
+	// integrations send X-API-Key, keys are managed on the admin listener
+	a.authenticators = append(a.authenticators, auth.NewAPIKeys(a.storage, a.clock))
+
+	idempotent := middleware.Idempotency(idempotency.NewMemoryStore(cfg.Idempotency.TTL), a.clock)
+	// what each route needs is declared here, which role has which permission is in auth.rolePermissions
+	api.HandleFunc("POST /students", student.New(a.students, a.bus, a.clock), middleware.Require(auth.WriteStudents), idempotent)
+	api.HandleFunc("POST /students/bulk", student.CreateBulk(a.students, a.bus, a.clock), middleware.Require(auth.WriteStudents), idempotent)
+	api.HandleFunc("GET /students/check-email", student.CheckEmail(a.students), middleware.Require(auth.WriteStudents))
+	api.HandleFunc("GET /students", student.List(a.students), middleware.Require(auth.ReadStudents), middleware.Cache(cfg.Caching.Students))
+	api.HandleFunc("GET /students/{id}", student.GetById(a.students),
+		middleware.RequireOwn(auth.ReadStudents, auth.ReadOwnStudent, "id"), middleware.Cache(cfg.Caching.Student))
+	api.HandleFunc("PUT /students/{id}", student.Update(a.students, a.bus, a.clock), middleware.Require(auth.WriteStudents))
+	api.HandleFunc("PATCH /students/{id}", student.Patch(a.students, a.bus, a.clock), middleware.Require(auth.WriteStudents))
+	api.HandleFunc("DELETE /students/{id}", student.Delete(a.students, a.bus, a.clock), middleware.Require(auth.DeleteStudents))
+	api.HandleFunc("GET /jobs/{id}", student.Job(a.storage, a.clock), middleware.Require(auth.WriteStudents))
+	api.HandleFunc("GET /jobs/{id}/errors", student.JobErrors(a.storage), middleware.Require(auth.WriteStudents))
+	api.HandleFunc("GET /version", healthhandler.Version())
+
+	// the spec is written by hand in openapi.go, a test keeps it in line with the routes above
+	spec := apiSpec(a.version, tokens != nil, len(cfg.OIDC) > 0)
+	api.HandleFunc("GET /openapi.json", spec.Handler())
+	api.HandleFunc("GET /docs", openapi.Docs("go-server api", "/api/v1/openapi.json"))
+
+	// rest/json gateway generated from student.proto, the same service the grpc listener serves
+	gateway, err := rpc.Gateway(context.Background(), rpc.NewStudents(a.students, a.bus, a.clock))
+	if err != nil {
+		return err
+	}
+	gw := rt.Group("/api/rpc", middleware.Timeout(cfg.Timeouts.Default))
+	gw.Handle("/v1/", gateway)
+	gw.HandleFunc("GET /openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(studentpb.OpenAPI)
+	})
+
+	// probes sit outside /api, load balancers and kubernetes call them without any version or prefix
+	rt.HandleFunc("GET /healthz", healthhandler.Live())
+	rt.HandleFunc("GET /readyz", healthhandler.Ready(a.checker))
+
+	// the export streams for much longer than a normal request, so it is outside the default timeout with its own
+	stream := v1.Group("")
+	stream.Handle("GET /students/export", student.Export(a.students, export.Budget(cfg.Export), a.clock),
+		middleware.Timeout(cfg.Timeouts.Export), middleware.Require(auth.ReadStudents))
+	// bulk import reads and answers for as long as the upload takes
+	stream.Handle("POST /students/stream", student.Ingest(a.students, a.bus, a.clock, cfg.Ingest),
+		middleware.Timeout(cfg.Timeouts.Ingest), middleware.Require(auth.WriteStudents))
+	// a background import only reads the upload, but that takes as long as with the stream
+	stream.Handle("POST /students/imports", student.Import(a.importer, cfg.Imports),
+		middleware.Timeout(cfg.Timeouts.Ingest), middleware.Require(auth.WriteStudents))
+	// live updates stay open until the client or the shutdown ends them
+	a.hub = live.NewHub(a.bus, cfg.Live)
+	stream.HandleLongLived("GET /ws", livehandler.WebSocket(a.hub, cfg.Live.PingInterval), middleware.Require(auth.ReadStudents))
+	stream.HandleLongLived("GET /students/events", livehandler.Events(a.hub, cfg.Live.PingInterval), middleware.Require(auth.ReadStudents))
+
+	// embedded admin ui, only when a password is configured
+	if cfg.AdminAuth.Password != "" {
+		ui := rt.Group("/admin", middleware.BasicAuth("admin", cfg.AdminAuth.Username, cfg.AdminAuth.Password))
+		ui.Handle("GET /", admin.UI())
+		// the ui calls the json api with a short token it gets here, the basic auth password itself is never an api credential
+		if tokens != nil {
+			ui.HandleFunc("POST /token", admin.UIToken(tokens, cfg.AdminAuth.Username))
+		} else {
+			slog.Warn("jwt is not configured, the admin ui can not call the api")
+		}
+	}
+
+	//global middlewares -> like app.use() in express, the first one here runs first
+	requestLimit, failedAuth := a.rateLimitStores()
+	limiter := middleware.NewLimiter(cfg.Concurrency.MaxInFlight, cfg.Concurrency.QueueSize, cfg.Concurrency.QueueTimeout)
+	rt.UseGlobal(
+		middleware.RealIP(trusted), // first, so every log line and the rate limiter see the real client
+		middleware.RequestID(a.ids),
+		middleware.ProblemDetails(cfg.Problems.Always), // before anything that can answer with an error
+		middleware.Localize(),
+		middleware.Logger(a.logger),
+		middleware.AccessLog, // outside of the limiters so rejected requests are logged too
+		middleware.SlowRequests(cfg.SlowRequests.Threshold, a.anomalies, a.clock),
+		middleware.Recover(a.reporter),
+		middleware.Tracing,
+		middleware.Metrics(metrics.NewHTTP(a.registry)), // early, so rejected requests (429, 503) are counted too
+		a.inFlight.Middleware,
+		middleware.Authenticate(a.authenticators, middleware.NewAuthFailures(failedAuth, a.clock)), // counts wrong credentials per ip, RateLimit comes after it
+		middleware.Prioritize(middleware.DefaultClassifier),
+		middleware.ReadOnly(a.maintenance, "/api/v1/auth/login", "/api/v1/auth/refresh", "/api/v1/auth/logout",
+			"/api/auth/login", "/api/auth/refresh", "/api/auth/logout"),
+	)
+	if cfg.RateLimit.Enabled { // before the limiter, a client over its rate should not take a slot at all
+		rt.UseGlobal(middleware.RateLimit(requestLimit, middleware.PrincipalOrIP, a.clock))
+	}
+	if cfg.Shedding.Enabled { // in front of the limiter, so the latency it sees includes the time spent in its queue
+		shedder := middleware.NewShedder(middleware.ShedOptions{
+			Target:   cfg.Shedding.TargetLatency,
+			MaxQueue: cfg.Shedding.MaxQueue,
+			Interval: cfg.Shedding.Interval,
+			Step:     cfg.Shedding.Step,
+		}, limiter.Queued, a.clock, metrics.NewShedding(a.registry))
+		rt.UseGlobal(shedder.Middleware)
+	}
+	rt.UseGlobal(limiter.Middleware)
+	if cfg.Compression.Enabled {
+		rt.UseGlobal(middleware.Compress(middleware.CompressOptions{
+			MinSize:      cfg.Compression.MinSize,
+			ContentTypes: cfg.Compression.ContentTypes,
+		}))
+	}
+	a.handler = rt
+	a.patterns = rt.Routes()
+
+	//admin router -> operational endpoints live on their own listener so they are never exposed with the public api
+	adminRouter := router.New()
+	// probes stay open, kubelet and load balancers do not log in
+	adminRouter.HandleFunc("GET /healthz", healthhandler.Live())
+	adminRouter.HandleFunc("GET /readyz", healthhandler.Ready(a.checker))
+
+	// everything else shows internals (config, metrics, profiles) or changes the running server
+	var guard []middleware.Middleware
+	if cfg.AdminAuth.Password != "" {
+		guard = append(guard, middleware.BasicAuth("admin", cfg.AdminAuth.Username, cfg.AdminAuth.Password))
+	} else {
+		slog.Warn("admin_auth.password is not set, the admin listener is open to anyone who can reach it")
+	}
+	ops := adminRouter.Group("", guard...)
+	ops.HandleFunc("GET /api/admin/config", admin.Config(cfg))
+	ops.HandleFunc("GET /api/admin/anomalies", admin.Anomalies(a.anomalies))
+	ops.HandleFunc("GET /api/admin/inflight", admin.InFlight(a.inFlight))
+	ops.HandleFunc("GET /api/admin/maintenance", admin.Maintenance(a.maintenance))
+	ops.HandleFunc("PUT /api/admin/maintenance", admin.SetMaintenance(a.maintenance))
+	ops.HandleFunc("GET /api/admin/apikeys", admin.APIKeys(a.storage))
+	ops.HandleFunc("POST /api/admin/apikeys", admin.CreateAPIKey(a.storage, a.clock))
+	ops.HandleFunc("DELETE /api/admin/apikeys/{id}", admin.RevokeAPIKey(a.storage, a.clock))
+	ops.HandleFunc("GET /api/admin/webhooks", admin.Webhooks(a.storage))
+	ops.HandleFunc("POST /api/admin/webhooks", admin.CreateWebhook(a.storage, a.clock))
+	ops.HandleFunc("DELETE /api/admin/webhooks/{id}", admin.DeleteWebhook(a.storage, a.clock))
+	ops.HandleFunc("GET /api/admin/webhooks/{id}/deliveries", admin.WebhookDeliveries(a.storage))
+	ops.HandleFunc("GET /api/admin/emails", admin.Emails(a.storage))
+	ops.HandleFunc("GET /api/admin/jobs/dead", admin.DeadJobs(a.storage))
+	ops.HandleFunc("POST /api/admin/jobs/dead/{id}/requeue", admin.RequeueDeadJob(a.jobs))
+	ops.HandleFunc("GET /api/admin/tasks", admin.Tasks(a.scheduler))
+	ops.HandleFunc("GET /api/admin/loglevel", admin.LogLevel(a.logLevel))
+	ops.HandleFunc("PUT /api/admin/loglevel", admin.SetLogLevel(a.logLevel))
+	ops.Handle("GET /metrics", metrics.Handler(a.registry))
+	if !cfg.Profiling.Disabled {
+		a.profiling(ops)
+	}
+	a.adminHandler = adminRouter
+	return nil
+}
+
+// profiling mounts pprof (and fgprof if enabled) so cpu, heap and goroutine profiles can be taken from production ->
+// go tool pprof http://<admin address>/debug/pprof/profile?seconds=30
+func (a *App) profiling(rt *router.Router) {
+	debug := rt.Group("/debug")
+	debug.HandleFunc("GET /pprof/", pprof.Index) // also serves the named profiles like /debug/pprof/heap
+	debug.HandleFunc("GET /pprof/cmdline", pprof.Cmdline)
+	debug.HandleFunc("GET /pprof/profile", pprof.Profile)
+	debug.HandleFunc("GET /pprof/symbol", pprof.Symbol)
+	debug.HandleFunc("POST /pprof/symbol", pprof.Symbol)
+	debug.HandleFunc("GET /pprof/trace", pprof.Trace)
+	if a.cfg.Profiling.FGProf {
+		debug.Handle("GET /fgprof", fgprof.Handler())
+	}
+}
+
+// rateLimitStores are redis when an address is configured, in memory otherwise. one for the requests of a client,
+// one for the wrong credentials of an ip
+func (a *App) rateLimitStores() (requests, failedAuth ratelimit.Store) {
+	limits := ratelimit.Limits{Rate: a.cfg.RateLimit.Rate, Burst: a.cfg.RateLimit.Burst}
+	failures := ratelimit.Limits{Rate: a.cfg.RateLimit.FailedAuthRate, Burst: a.cfg.RateLimit.FailedAuthBurst}
+	if a.cfg.RateLimit.RedisAddr == "" {
+		return ratelimit.NewMemoryStore(limits), ratelimit.NewMemoryStore(failures)
+	}
+	client := redis.NewClient(&redis.Options{Addr: a.cfg.RateLimit.RedisAddr})
+	a.OnShutdown(func(ctx context.Context) error {
+		return client.Close()
+	})
+	a.checker.Add(health.Check{Name: "redis", Run: func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	}})
+	return ratelimit.NewRedisStore(client, limits), ratelimit.NewRedisStore(client, failures)
+}
+
+// scheduleTasks adds the cleanups, each deletes the rows older than its keep_for
+func (a *App) scheduleTasks(cfg config.Scheduler) error {
+	purges := []struct {
+		name  string
+		cfg   config.Purge
+		purge func(ctx context.Context, before time.Time) (int64, error)
+	}{
+		{"purge_jobs", cfg.PurgeJobs, a.storage.PurgeJobs},
+		{"purge_dead_jobs", cfg.PurgeDeadJobs, a.storage.PurgeDeadJobs},
+		{"purge_deliveries", cfg.PurgeDeliveries, a.storage.PurgeDeliveries},
+		{"purge_refresh_tokens", cfg.PurgeRefreshTokens, a.storage.PurgeRefreshTokens},
+		{"purge_emails", cfg.PurgeEmails, a.storage.PurgeEmails},
+		{"purge_outbox", cfg.PurgeOutbox, a.storage.PurgeOutbox},
+		{"purge_imports", cfg.PurgeImports, a.importer.Purge}, // the uploads are files, the importer removes them
+	}
+	for _, p := range purges {
+		if p.cfg.Schedule == "" || p.cfg.Schedule == "off" {
+			continue
+		}
+		err := a.scheduler.Add(scheduler.Task{
+			Name:     p.name,
+			Schedule: p.cfg.Schedule,
+			Timeout:  10 * time.Minute,
+			Run: func(ctx context.Context) error {
+				n, err := p.purge(ctx, a.clock.Now().Add(-p.cfg.KeepFor))
+				if err != nil {
+					return err
+				}
+				slog.Info("purged old rows", slog.String("task", p.name), slog.Int64("rows", n))
+				return nil
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("scheduler.%s: %w", p.name, err)
+		}
+	}
+	return nil
+}
+
+// OnShutdown registers a teardown func, they run in reverse order after the http servers are drained
+func (a *App) OnShutdown(fn ShutdownFunc) {
+	a.hooks.OnShutdown(fn)
+}
+
+// Handler is the public api handler with all middlewares, tests can call it directly with httptest
+func (a *App) Handler() http.Handler {
+	return a.handler
+}
+
+// Routes lists the patterns of the public router, used to check the openapi document
+func (a *App) Routes() []string {
+	return append([]string(nil), a.patterns...)
+}
+
+// Started is closed once Run has opened the listeners, after that Addr is set
+func (a *App) Started() <-chan struct{} {
+	return a.started
+}
+
+// Addr is the real address of the api listener, useful when the config said port 0
+func (a *App) Addr() net.Addr {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.addr
+}
+
+// AdminAddr is the real address of the admin listener, nil when it is turned off
+func (a *App) AdminAddr() net.Addr {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.adminAddr
+}
+
+// GRPCAddr is the real address of the grpc listener, nil when it is turned off
+func (a *App) GRPCAddr() net.Addr {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.grpcAddr
+}
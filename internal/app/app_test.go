@@ -0,0 +1,1831 @@
+package app_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/manishtomar-cpi/go-server/internal/app"
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/errcode"
+	"github.com/manishtomar-cpi/go-server/internal/http/dto"
+	student "github.com/manishtomar-cpi/go-server/internal/http/handllers/students"
+	"github.com/manishtomar-cpi/go-server/internal/importer"
+	"github.com/manishtomar-cpi/go-server/internal/outbox"
+	"github.com/manishtomar-cpi/go-server/internal/rpc/studentpb"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// testConfig is a config that listens on a random port and keeps the db in a temp dir
+func testConfig(t testing.TB) *config.Config {
+	t.Helper()
+	return &config.Config{
+		Env:          "test",
+		Storage_path: filepath.Join(t.TempDir(), "test.db"),
+		HTTPServer:   config.HTTPServer{Address: "127.0.0.1:0"},
+		Shutdown:     config.Shutdown{DrainTimeout: 30 * time.Second},                 // well over the 5s Shutdown gives a connection that never sent a request
+		Password:     config.Password{MemoryKiB: 1024, Iterations: 1, Parallelism: 1}, // cheap hashes, tests do not need the real cost
+		JWT:          config.JWT{Secret: strings.Repeat("s", 32), Issuer: "go-server", TTL: time.Minute, RefreshTTL: time.Hour},
+		Users: []config.User{
+			{Username: "teacher", PasswordHash: testPasswordHash, Roles: []string{"teacher"}},
+			{Username: "student", PasswordHash: testPasswordHash, Roles: []string{"student"}, StudentID: 1},
+		},
+	}
+}
+
+// bcrypt of "secret" with the lowest cost, so tests do not spend time hashing
+var testPasswordHash = func() string {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	return string(hash)
+}()
+
+// login gets an access token for the test user
+func login(t testing.TB, baseURL string) string {
+	t.Helper()
+	return loginAs(t, baseURL, "teacher", "secret")
+}
+
+func loginAs(t testing.TB, baseURL, username, password string) string {
+	t.Helper()
+
+	res, err := http.Post(baseURL+"/api/v1/auth/login", "application/json",
+		strings.NewReader(fmt.Sprintf(`{"username":%q,"password":%q}`, username, password)))
+	if err != nil {
+		t.Fatalf("login request failed: %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("login: want 200, got %d", res.StatusCode)
+	}
+	var body map[string]any
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		t.Fatalf("decode login response: %v", err)
+	}
+	token, _ := body["access_token"].(string)
+	return token
+}
+
+// postJSON sends body with the bearer token, empty token sends the request anonymously
+func postJSON(t testing.TB, url, token, body string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	return res
+}
+
+// getJSON sends a GET with the bearer token, empty token sends the request anonymously
+func getJSON(t testing.TB, url, token string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("build request: %v", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	return res
+}
+
+// startApp runs the app in the background and stops it when the test ends
+func startApp(t testing.TB, cfg *config.Config, opts ...app.Option) string {
+	t.Helper()
+	return "http://" + runApp(t, cfg, opts...).Addr().String()
+}
+
+// runApp is startApp for tests that need more than the api address
+func runApp(t testing.TB, cfg *config.Config, opts ...app.Option) *app.App {
+	t.Helper()
+
+	a, err := app.New(cfg, opts...)
+	if err != nil {
+		t.Fatalf("app.New: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- a.Run(ctx) }()
+
+	select {
+	case <-a.Started():
+	case err := <-runErr:
+		t.Fatalf("app did not start: %v", err)
+	}
+	t.Cleanup(func() {
+		// the transport may have dialed connections it never sent a request on, Shutdown would wait 5s for each
+		http.DefaultClient.CloseIdleConnections()
+		cancel()
+		if err := <-runErr; err != nil {
+			t.Errorf("Run returned error on shutdown: %v", err)
+		}
+	})
+	return a
+}
+
+func TestAppEndToEnd(t *testing.T) {
+	t.Parallel()
+
+	baseURL := startApp(t, testConfig(t))
+	const student = `{"name":"Asha","email":"asha@example.com","age":21}`
+
+	res := postJSON(t, baseURL+"/api/v1/students", "", student)
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("anonymous create: want 401, got %d", res.StatusCode)
+	}
+
+	token := login(t, baseURL)
+	res = postJSON(t, baseURL+"/api/v1/students", token, student)
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("create: want 201, got %d", res.StatusCode)
+	}
+	var created struct {
+		Data struct {
+			Id int64 `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&created); err != nil {
+		t.Fatalf("decode create response: %v", err)
+	}
+	if location := res.Header.Get("Location"); location != fmt.Sprintf("/api/v1/students/%d", created.Data.Id) {
+		t.Fatalf("create: want Location of the new student, got %q", location)
+	}
+
+	res = getJSON(t, fmt.Sprintf("%s/api/v1/students/%d", baseURL, created.Data.Id), token)
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("get: want 200, got %d", res.StatusCode)
+	}
+	var got struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&got); err != nil {
+		t.Fatalf("decode get response: %v", err)
+	}
+	if got.Data["email"] != "asha@example.com" {
+		t.Fatalf("want email asha@example.com, got %v", got.Data["email"])
+	}
+
+	res = getJSON(t, baseURL+"/api/v1/students/999", token)
+	defer res.Body.Close()
+	var missing map[string]any
+	if err := json.NewDecoder(res.Body).Decode(&missing); err != nil {
+		t.Fatalf("decode missing response: %v", err)
+	}
+	if res.StatusCode != http.StatusNotFound || missing["code"] != "STUDENT_NOT_FOUND" {
+		t.Fatalf("missing student: want 404 STUDENT_NOT_FOUND, got %d %v", res.StatusCode, missing["code"])
+	}
+}
+
+func TestAppLocalizedErrors(t *testing.T) {
+	t.Parallel()
+
+	baseURL := startApp(t, testConfig(t))
+	token := login(t, baseURL)
+
+	type testCase struct {
+		name      string
+		language  string
+		wantLang  string
+		wantError string
+	}
+
+	tests := []testCase{
+		{name: "english_by_default", wantLang: "en", wantError: "student with id 999: not found"},
+		{name: "spanish", language: "es-ES,es;q=0.9", wantLang: "es", wantError: "estudiante no encontrado"},
+		{name: "hindi", language: "hi", wantLang: "hi", wantError: "छात्र नहीं मिला"},
+		{name: "unknown_is_english", language: "de", wantLang: "en", wantError: "student with id 999: not found"},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			req, _ := http.NewRequest(http.MethodGet, baseURL+"/api/v1/students/999", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			if tc.language != "" {
+				req.Header.Set("Accept-Language", tc.language)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer res.Body.Close()
+			var body map[string]any
+			json.NewDecoder(res.Body).Decode(&body)
+			if res.Header.Get("Content-Language") != tc.wantLang || body["error"] != tc.wantError || body["code"] != "STUDENT_NOT_FOUND" {
+				t.Fatalf("want %s %q, got %s %v", tc.wantLang, tc.wantError, res.Header.Get("Content-Language"), body)
+			}
+		})
+	}
+}
+
+func TestAppLegacyPaths(t *testing.T) {
+	t.Parallel()
+
+	cfg := testConfig(t)
+	cfg.APIVersions.LegacySunset = "2027-06-30T00:00:00Z"
+	baseURL := startApp(t, cfg)
+	token := login(t, baseURL)
+	res := postJSON(t, baseURL+"/api/v1/students", token, `{"name":"Asha","email":"asha@example.com","age":21}`)
+	res.Body.Close()
+
+	// the old path still answers, with a notice where to go
+	res = getJSON(t, baseURL+"/api/students/1", token)
+	var legacy struct {
+		Meta struct {
+			Warnings []string `json:"warnings"`
+		} `json:"meta"`
+	}
+	json.NewDecoder(res.Body).Decode(&legacy)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("legacy get: want 200, got %d", res.StatusCode)
+	}
+	if len(legacy.Meta.Warnings) != 1 || legacy.Meta.Warnings[0] != "deprecated, use /api/v1/students/1" {
+		t.Fatalf("legacy get: want the notice in meta.warnings, got %v", legacy.Meta.Warnings)
+	}
+	if res.Header.Get("Deprecation") != "true" || res.Header.Get("Link") != `</api/v1/students/1>; rel="successor-version"` ||
+		res.Header.Get("Sunset") != "Wed, 30 Jun 2027 00:00:00 GMT" {
+		t.Fatalf("legacy get: missing deprecation headers, got %v", res.Header)
+	}
+
+	res = getJSON(t, baseURL+"/api/v1/students/1", token)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || res.Header.Get("Deprecation") != "" {
+		t.Fatalf("v1 get: want 200 without Deprecation, got %d %q", res.StatusCode, res.Header.Get("Deprecation"))
+	}
+
+	cfg = testConfig(t)
+	cfg.APIVersions.DisableLegacy = true
+	baseURL = startApp(t, cfg)
+	res = getJSON(t, baseURL+"/api/version", "")
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("legacy path with legacy turned off: want 404, got %d", res.StatusCode)
+	}
+}
+
+func TestAppIngest(t *testing.T) {
+	t.Parallel()
+
+	cfg := testConfig(t)
+	cfg.Ingest = config.Ingest{BatchSize: 2, MaxLineBytes: 1024}
+	baseURL := startApp(t, cfg)
+
+	upload := strings.Join([]string{
+		`{"name":"Asha","email":"asha@example.com","age":21}`,
+		`{"name":"Ravi","email":"not an email","age":22}`,
+		``,
+		`{"name":"Mia","email":"mia@example.com","age":20}`,
+		`{"name":`,
+	}, "\n")
+	req, _ := http.NewRequest(http.MethodPost, baseURL+"/api/v1/students/stream", strings.NewReader(upload))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Authorization", "Bearer "+login(t, baseURL))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil || res.StatusCode != http.StatusOK {
+		t.Fatalf("ingest: %v %v", res, err)
+	}
+	defer res.Body.Close()
+
+	var results []map[string]any
+	lines := bufio.NewScanner(res.Body)
+	for lines.Scan() {
+		var line map[string]any
+		if err := json.Unmarshal(lines.Bytes(), &line); err != nil {
+			t.Fatalf("answer line %q: %v", lines.Text(), err)
+		}
+		results = append(results, line)
+	}
+	if len(results) != 5 {
+		t.Fatalf("want 4 results and a summary, got %v", results)
+	}
+	want := []string{"created", "error", "created", "error"}
+	for i, status := range want {
+		if results[i]["status"] != status {
+			t.Fatalf("result %d: want %s, got %v", i, status, results[i])
+		}
+	}
+	if results[2]["line"] != float64(4) || results[2]["id"] != float64(2) {
+		t.Fatalf("third record: want line 4 with id 2, got %v", results[2])
+	}
+	summary, _ := results[4]["summary"].(map[string]any)
+	if summary["created"] != float64(2) || summary["failed"] != float64(2) || summary["complete"] != true {
+		t.Fatalf("unexpected summary: %v", results[4])
+	}
+
+	res = postJSON(t, baseURL+"/api/v1/students/stream", login(t, baseURL), `{"name":"Asha"}`)
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnsupportedMediaType {
+		t.Fatalf("json upload: want 415, got %d", res.StatusCode)
+	}
+}
+
+func TestAppImport(t *testing.T) {
+	t.Parallel()
+
+	cfg := testConfig(t)
+	cfg.Imports = config.Imports{MaxBytes: 1 << 20, BatchSize: 2}
+	cfg.Users = append(cfg.Users,
+		config.User{Username: "other", PasswordHash: testPasswordHash, Roles: []string{"teacher"}},
+		config.User{Username: "admin", PasswordHash: testPasswordHash, Roles: []string{"admin"}})
+	baseURL := startApp(t, cfg)
+	token := login(t, baseURL)
+
+	send := func(contentType, upload string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, baseURL+"/api/v1/students/imports", strings.NewReader(upload))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Authorization", "Bearer "+token)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("import: %v", err)
+		}
+		return res
+	}
+
+	res := send("text/csv; charset=utf-8", "name,email,age\nAsha,asha@example.com,21\nRavi,not an email,22\nMia,mia@example.com,20\n")
+	var accepted struct {
+		Data dto.ImportJob `json:"data"`
+	}
+	json.NewDecoder(res.Body).Decode(&accepted)
+	res.Body.Close()
+	location := res.Header.Get("Location")
+	if res.StatusCode != http.StatusAccepted || accepted.Data.ID == 0 || location != fmt.Sprintf("/api/v1/jobs/%d", accepted.Data.ID) {
+		t.Fatalf("import: want 202 with the job at Location, got %d %q %+v", res.StatusCode, location, accepted.Data)
+	}
+
+	var job struct {
+		Data dto.ImportJob `json:"data"`
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Data.Status != types.JobDone {
+		if time.Now().After(deadline) {
+			t.Fatalf("import did not finish: %+v", job.Data)
+		}
+		time.Sleep(10 * time.Millisecond)
+		res = getJSON(t, baseURL+location, token)
+		json.NewDecoder(res.Body).Decode(&job)
+		res.Body.Close()
+	}
+	want := dto.ImportProgress{Total: 3, Processed: 3, Created: 2, Failed: 1, Percent: 100}
+	if job.Data.Progress != want || job.Data.ErrorReport != location+"/errors" || job.Data.FinishedAt == nil {
+		t.Fatalf("finished job: want progress %+v and an error report, got %+v", want, job.Data)
+	}
+
+	res = getJSON(t, baseURL+job.Data.ErrorReport, token)
+	report, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	var failure importer.Failure
+	if err := json.Unmarshal(report, &failure); err != nil || failure.Line != 3 || len(failure.Fields) != 1 || failure.Fields[0].Field != "email" {
+		t.Fatalf("error report: want line 3 with the email, got %q", report)
+	}
+	// another teacher can not tell the job from one that does not exist, an admin sees it
+	other, admin := loginAs(t, baseURL, "other", "secret"), loginAs(t, baseURL, "admin", "secret")
+	for _, path := range []string{location, job.Data.ErrorReport} {
+		res = getJSON(t, baseURL+path, other)
+		res.Body.Close()
+		if res.StatusCode != http.StatusNotFound {
+			t.Fatalf("%s of another teacher: want 404, got %d", path, res.StatusCode)
+		}
+		res = getJSON(t, baseURL+path, admin)
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("%s as admin: want 200, got %d", path, res.StatusCode)
+		}
+	}
+	res = getJSON(t, baseURL+"/api/v1/students?sort=id", token)
+	var students struct {
+		Data []dto.Student `json:"data"`
+	}
+	json.NewDecoder(res.Body).Decode(&students)
+	res.Body.Close()
+	if len(students.Data) != 2 || students.Data[1].Email != "mia@example.com" {
+		t.Fatalf("students: want the 2 valid rows, got %+v", students.Data)
+	}
+
+	res = send("text/csv", "name,email,grade\nAsha,asha@example.com,A\n")
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("unknown column: want 400, got %d", res.StatusCode)
+	}
+	res = send("application/json", `{"name":"Asha"}`)
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnsupportedMediaType {
+		t.Fatalf("json upload: want 415, got %d", res.StatusCode)
+	}
+	res = send("text/csv", "name,email,age\n"+strings.Repeat("Asha,asha@example.com,21\n", 50000)) // over max_bytes
+	res.Body.Close()
+	if res.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("upload over max_bytes: want 413, got %d", res.StatusCode)
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(5 * time.Millisecond) {
+		left, _ := os.ReadDir(filepath.Join(filepath.Dir(cfg.Storage_path), "imports")) // the done import's goes just after its status
+		if len(left) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("uploads left after the import and the refused ones: %v", left)
+		}
+	}
+	res = getJSON(t, baseURL+"/api/v1/jobs/999", token)
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("unknown job: want 404, got %d", res.StatusCode)
+	}
+	res = getJSON(t, baseURL+"/api/v1/jobs/999/errors", token)
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("error report of an unknown job: want 404, got %d", res.StatusCode)
+	}
+}
+
+func TestAppUnknownField(t *testing.T) {
+	t.Parallel()
+
+	baseURL := startApp(t, testConfig(t))
+	req, _ := http.NewRequest(http.MethodPost, baseURL+"/api/v1/students", strings.NewReader(`{"name":"Asha","emial":"asha@example.com","age":21}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+login(t, baseURL))
+	req.Header.Set("Accept", "application/problem+json")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	var problem response.Problem
+	json.NewDecoder(res.Body).Decode(&problem)
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest || problem.Code != errcode.UnknownField ||
+		len(problem.Errors) != 1 || problem.Errors[0].Field != "emial" || problem.Errors[0].Rule != "unknown" {
+		t.Fatalf("want 400 UNKNOWN_FIELD naming emial, got %d %+v", res.StatusCode, problem)
+	}
+}
+
+func TestAppUniqueEmail(t *testing.T) {
+	t.Parallel()
+
+	baseURL := startApp(t, testConfig(t))
+	token := login(t, baseURL)
+	for _, body := range []string{`{"name":"Asha","email":"asha@example.com","age":21}`, `{"name":"Ravi","email":"ravi@example.com","age":22}`} {
+		res := postJSON(t, baseURL+"/api/v1/students", token, body)
+		res.Body.Close()
+	}
+
+	type testCase struct {
+		name          string
+		email         string
+		wantStatus    int
+		wantAvailable bool
+	}
+
+	tests := []testCase{
+		{name: "taken", email: "asha@example.com", wantStatus: http.StatusOK},
+		{name: "taken_other_case", email: "ASHA@Example.com", wantStatus: http.StatusOK},
+		{name: "free", email: "mia@example.com", wantStatus: http.StatusOK, wantAvailable: true},
+		{name: "not_an_email", email: "asha", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			res := getJSON(t, baseURL+"/api/v1/students/check-email?email="+url.QueryEscape(tc.email), token)
+			var body struct {
+				Data student.EmailCheck `json:"data"`
+			}
+			json.NewDecoder(res.Body).Decode(&body)
+			res.Body.Close()
+			if res.StatusCode != tc.wantStatus || body.Data.Available != tc.wantAvailable {
+				t.Fatalf("want %d available=%v, got %d %+v", tc.wantStatus, tc.wantAvailable, res.StatusCode, body.Data)
+			}
+		})
+	}
+
+	res := postJSON(t, baseURL+"/api/v1/students", token, `{"name":"Asha B","email":"Asha@example.com","age":30}`)
+	var dup map[string]any
+	json.NewDecoder(res.Body).Decode(&dup)
+	res.Body.Close()
+	if res.StatusCode != http.StatusConflict || dup["code"] != string(errcode.DuplicateEmail) || dup["error"] != "email is already taken" {
+		t.Fatalf("duplicate create: want 409 DUPLICATE_EMAIL, got %d %v", res.StatusCode, dup)
+	}
+
+	req, _ := http.NewRequest(http.MethodPut, baseURL+"/api/v1/students/2", strings.NewReader(`{"name":"Ravi","email":"asha@example.com","age":22}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/problem+json")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	var problem response.Problem
+	json.NewDecoder(res.Body).Decode(&problem)
+	res.Body.Close()
+	if res.StatusCode != http.StatusConflict || len(problem.Errors) != 1 || problem.Errors[0].Field != "email" || problem.Errors[0].Rule != "unique" {
+		t.Fatalf("duplicate update: want 409 with the email field, got %d %+v", res.StatusCode, problem)
+	}
+}
+
+func TestAppBulkCreate(t *testing.T) {
+	t.Parallel()
+
+	baseURL := startApp(t, testConfig(t))
+	token := login(t, baseURL)
+
+	type bulkBody struct {
+		Data []response.BulkItem `json:"data"`
+		Meta response.Meta       `json:"meta"`
+	}
+
+	res := postJSON(t, baseURL+"/api/v1/students/bulk", token,
+		`[{"name":"Asha","email":"asha@example.com","age":21},{"name":"Ravi","email":"not an email","age":22}]`)
+	var mixed bulkBody
+	json.NewDecoder(res.Body).Decode(&mixed)
+	res.Body.Close()
+	if res.StatusCode != http.StatusMultiStatus {
+		t.Fatalf("mixed: want 207, got %d", res.StatusCode)
+	}
+	if len(mixed.Data) != 2 || mixed.Data[0].Status != http.StatusCreated || mixed.Data[0].ID != 1 ||
+		mixed.Data[1].Status != http.StatusBadRequest || mixed.Data[1].Code != errcode.ValidationFailed || len(mixed.Data[1].Fields) != 1 {
+		t.Fatalf("mixed: unexpected items %+v", mixed.Data)
+	}
+	if s := mixed.Meta.Bulk; s == nil || s.Total != 2 || s.Succeeded != 1 || s.Failed != 1 {
+		t.Fatalf("mixed: unexpected summary %+v", s)
+	}
+
+	res = postJSON(t, baseURL+"/api/v1/students/bulk", token, `[{"name":"Mia","email":"mia@example.com","age":20}]`)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("all created: want 200, got %d", res.StatusCode)
+	}
+
+	res = postJSON(t, baseURL+"/api/v1/students/bulk", token, `[]`)
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("empty: want 400, got %d", res.StatusCode)
+	}
+}
+
+func TestAppBulkCreateBatched(t *testing.T) {
+	t.Parallel()
+
+	cfg := testConfig(t)
+	cfg.SQLite.InsertBatch = 2 // 5 students -> inserts of 2, 2 and 1
+	baseURL := startApp(t, cfg)
+	token := login(t, baseURL)
+
+	names := []string{"Asha", "Ravi", "Mia", "Noor", "Kiran"}
+	var body strings.Builder
+	body.WriteString("[")
+	for i, name := range names {
+		if i > 0 {
+			body.WriteString(",")
+		}
+		fmt.Fprintf(&body, `{"name":%q,"email":"%s@example.com","age":%d}`, name, strings.ToLower(name), 20+i)
+	}
+	body.WriteString("]")
+	res := postJSON(t, baseURL+"/api/v1/students/bulk", token, body.String())
+	var created struct {
+		Data []response.BulkItem `json:"data"`
+	}
+	json.NewDecoder(res.Body).Decode(&created)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || len(created.Data) != len(names) {
+		t.Fatalf("want 200 with %d items, got %d %+v", len(names), res.StatusCode, created.Data)
+	}
+	for i, item := range created.Data {
+		res := getJSON(t, fmt.Sprintf("%s/api/v1/students/%d", baseURL, item.ID), token)
+		var got struct {
+			Data dto.Student `json:"data"`
+		}
+		json.NewDecoder(res.Body).Decode(&got)
+		res.Body.Close()
+		if got.Data.Name != names[i] {
+			t.Fatalf("item %d: id %d is %q, want %q", i, item.ID, got.Data.Name, names[i])
+		}
+	}
+
+	// a taken email in the batch -> the others are still created
+	res = postJSON(t, baseURL+"/api/v1/students/bulk", token,
+		`[{"name":"Zoya","email":"zoya@example.com","age":21},{"name":"Asha","email":"asha@example.com","age":21}]`)
+	var mixed struct {
+		Data []response.BulkItem `json:"data"`
+	}
+	json.NewDecoder(res.Body).Decode(&mixed)
+	res.Body.Close()
+	if res.StatusCode != http.StatusMultiStatus || len(mixed.Data) != 2 ||
+		mixed.Data[0].Status != http.StatusCreated || mixed.Data[1].Code != errcode.DuplicateEmail {
+		t.Fatalf("want zoya created and asha a duplicate, got %d %+v", res.StatusCode, mixed.Data)
+	}
+}
+
+func TestAppProblemDetails(t *testing.T) {
+	t.Parallel()
+
+	baseURL := startApp(t, testConfig(t))
+	token := login(t, baseURL)
+
+	send := func(accept string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, baseURL+"/api/v1/students", strings.NewReader(`{"name":"Asha","age":21}`))
+		if err != nil {
+			t.Fatalf("build request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		return res
+	}
+
+	res := send("application/problem+json, application/json;q=0.9")
+	defer res.Body.Close()
+	var problem response.Problem
+	if err := json.NewDecoder(res.Body).Decode(&problem); err != nil {
+		t.Fatalf("decode problem: %v", err)
+	}
+	if res.StatusCode != http.StatusBadRequest || res.Header.Get("Content-Type") != response.ProblemContentType {
+		t.Fatalf("want 400 problem+json, got %d %q", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+	if problem.Status != http.StatusBadRequest || problem.Type != "/problems/validation-failed" || problem.Instance != "/api/v1/students" ||
+		len(problem.Errors) != 1 || problem.Errors[0].Field != "email" || problem.RequestID == "" {
+		t.Fatalf("unexpected problem: %+v", problem)
+	}
+	if res.Header.Get(response.ProblemHeader) != "" {
+		t.Fatalf("internal %s header leaked to the client", response.ProblemHeader)
+	}
+
+	// clients that did not ask keep the old body
+	legacy := send("")
+	defer legacy.Body.Close()
+	var body map[string]any
+	json.NewDecoder(legacy.Body).Decode(&body)
+	if legacy.Header.Get("Content-Type") != "application/json" || body["status"] != response.StatusError {
+		t.Fatalf("want the old error body, got %q %v", legacy.Header.Get("Content-Type"), body)
+	}
+}
+
+func TestAppMaintenanceMode(t *testing.T) {
+	t.Parallel()
+
+	cfg := testConfig(t)
+	cfg.Maintenance.Enabled = true
+	baseURL := startApp(t, cfg)
+
+	res := postJSON(t, baseURL+"/api/v1/students", login(t, baseURL), `{"name":"Asha","email":"asha@example.com","age":21}`)
+	defer res.Body.Close()
+	var body map[string]string
+	json.NewDecoder(res.Body).Decode(&body)
+	if res.StatusCode != http.StatusServiceUnavailable || body["code"] != "MAINTENANCE" {
+		t.Fatalf("create in maintenance: want 503 with code maintenance, got %d %v", res.StatusCode, body)
+	}
+
+	res = getJSON(t, baseURL+"/api/v1/students", login(t, baseURL))
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("list in maintenance: want 200, got %d", res.StatusCode)
+	}
+}
+
+func TestAppRegister(t *testing.T) {
+	t.Parallel()
+
+	baseURL := startApp(t, testConfig(t))
+
+	type testCase struct {
+		name       string
+		body       string
+		wantStatus int
+	}
+
+	// in order, later cases depend on the account made by the first one
+	tests := []testCase{
+		{name: "new_user", body: `{"username":"asha","password":"correct-horse-battery"}`, wantStatus: http.StatusCreated},
+		{name: "taken_username", body: `{"username":"Asha","password":"another-long-one"}`, wantStatus: http.StatusConflict},
+		{name: "config_username", body: `{"username":"teacher","password":"yet-another-one"}`, wantStatus: http.StatusConflict},
+		{name: "short_password", body: `{"username":"ravi","password":"short"}`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		res := postJSON(t, baseURL+"/api/v1/auth/register", "", tc.body)
+		res.Body.Close()
+		if res.StatusCode != tc.wantStatus {
+			t.Fatalf("%s: want %d, got %d", tc.name, tc.wantStatus, res.StatusCode)
+		}
+	}
+
+	if token := loginAs(t, baseURL, "asha", "correct-horse-battery"); token == "" {
+		t.Fatal("registered user got no token")
+	}
+}
+
+func TestAppRoles(t *testing.T) {
+	t.Parallel()
+
+	baseURL := startApp(t, testConfig(t))
+	teacher := login(t, baseURL)
+	student := loginAs(t, baseURL, "student", "secret")
+	for _, body := range []string{`{"name":"Asha","email":"asha@example.com","age":21}`, `{"name":"Ravi","email":"ravi@example.com","age":22}`} {
+		res := postJSON(t, baseURL+"/api/v1/students", teacher, body)
+		res.Body.Close()
+		if res.StatusCode != http.StatusCreated {
+			t.Fatalf("teacher create: want 201, got %d", res.StatusCode)
+		}
+	}
+
+	type testCase struct {
+		name       string
+		token      string
+		path       string
+		wantStatus int
+	}
+
+	tests := []testCase{
+		{name: "anonymous_list", path: "/api/v1/students", wantStatus: http.StatusUnauthorized},
+		{name: "teacher_list", token: teacher, path: "/api/v1/students", wantStatus: http.StatusOK},
+		{name: "student_list", token: student, path: "/api/v1/students", wantStatus: http.StatusForbidden},
+		{name: "student_own_record", token: student, path: "/api/v1/students/1", wantStatus: http.StatusOK},
+		{name: "student_other_record", token: student, path: "/api/v1/students/2", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			res := getJSON(t, baseURL+tc.path, tc.token)
+			res.Body.Close()
+			if res.StatusCode != tc.wantStatus {
+				t.Fatalf("want %d, got %d", tc.wantStatus, res.StatusCode)
+			}
+		})
+	}
+
+	res := postJSON(t, baseURL+"/api/v1/students", student, `{"name":"Mia","email":"mia@example.com","age":20}`)
+	res.Body.Close()
+	if res.StatusCode != http.StatusForbidden {
+		t.Fatalf("student create: want 403, got %d", res.StatusCode)
+	}
+}
+
+func TestAppDeleteStudent(t *testing.T) {
+	t.Parallel()
+
+	cfg := testConfig(t)
+	cfg.Users = append(cfg.Users, config.User{Username: "admin", PasswordHash: testPasswordHash, Roles: []string{"admin"}})
+	baseURL := startApp(t, cfg)
+	admin := loginAs(t, baseURL, "admin", "secret")
+	res := postJSON(t, baseURL+"/api/v1/students", admin, `{"name":"Asha","email":"asha@example.com","age":21}`)
+	res.Body.Close()
+
+	del := func(token string) *http.Response {
+		req, _ := http.NewRequest(http.MethodDelete, baseURL+"/api/v1/students/1", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		return res
+	}
+
+	res = del(login(t, baseURL))
+	res.Body.Close()
+	if res.StatusCode != http.StatusForbidden {
+		t.Fatalf("teacher delete: want 403, got %d", res.StatusCode)
+	}
+
+	res = del(admin)
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusNoContent || len(body) != 0 || res.Header.Get("Content-Type") != "" {
+		t.Fatalf("admin delete: want an empty 204, got %d %q %q", res.StatusCode, res.Header.Get("Content-Type"), body)
+	}
+
+	res = del(admin)
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("second delete: want 404, got %d", res.StatusCode)
+	}
+}
+
+func TestAppListQuery(t *testing.T) {
+	t.Parallel()
+
+	cfg := testConfig(t)
+	cfg.Users = append(cfg.Users, config.User{Username: "admin", PasswordHash: testPasswordHash, Roles: []string{"admin"}})
+	baseURL := startApp(t, cfg)
+	token := loginAs(t, baseURL, "admin", "secret") // sees the names unmasked
+	for _, body := range []string{
+		`{"name":"Ravi","email":"ravi@example.com","age":30}`,
+		`{"name":"asha","email":"asha@example.com","age":21}`,
+		`{"name":"Mia Lee","email":"mia@example.com","age":25}`,
+	} {
+		res := postJSON(t, baseURL+"/api/v1/students", token, body)
+		res.Body.Close()
+	}
+
+	type testCase struct {
+		name       string
+		query      string
+		wantStatus int
+		wantNames  []string
+	}
+
+	tests := []testCase{
+		{name: "default_by_id", wantStatus: http.StatusOK, wantNames: []string{"Ravi", "asha", "Mia Lee"}},
+		{name: "by_name_any_case", query: "sort=name", wantStatus: http.StatusOK, wantNames: []string{"asha", "Mia Lee", "Ravi"}},
+		{name: "by_age_desc", query: "sort=-age&limit=2", wantStatus: http.StatusOK, wantNames: []string{"Ravi", "Mia Lee"}},
+		{name: "age_range", query: "min_age=22&max_age=29", wantStatus: http.StatusOK, wantNames: []string{"Mia Lee"}},
+		{name: "name_part", query: "name=SH", wantStatus: http.StatusOK, wantNames: []string{"asha"}},
+		{name: "underscore_is_literal", query: "name=a_", wantStatus: http.StatusOK}, // a LIKE wildcard would match asha
+		{name: "unknown_sort", query: "sort=email", wantStatus: http.StatusBadRequest},
+		{name: "empty_age_range", query: "min_age=30&max_age=20", wantStatus: http.StatusBadRequest},
+		{name: "limit_not_a_number", query: "limit=ten", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			res := getJSON(t, baseURL+"/api/v1/students?"+tc.query, token)
+			var body struct {
+				Data []dto.Student `json:"data"`
+			}
+			json.NewDecoder(res.Body).Decode(&body)
+			res.Body.Close()
+			if res.StatusCode != tc.wantStatus {
+				t.Fatalf("want %d, got %d", tc.wantStatus, res.StatusCode)
+			}
+			var names []string
+			for _, s := range body.Data {
+				names = append(names, s.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tc.wantNames, ",") {
+				t.Fatalf("want %v, got %v", tc.wantNames, names)
+			}
+		})
+	}
+}
+
+func TestAppPatchStudent(t *testing.T) {
+	t.Parallel()
+
+	baseURL := startApp(t, testConfig(t))
+	token := login(t, baseURL)
+	res := postJSON(t, baseURL+"/api/v1/students", token, `{"name":"Asha","email":"asha@example.com","age":21}`)
+	res.Body.Close()
+
+	patch := func(id, body string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPatch, baseURL+"/api/v1/students/"+id, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("patch: %v", err)
+		}
+		return res
+	}
+
+	type testCase struct {
+		name       string
+		id         string
+		body       string
+		wantStatus int
+	}
+
+	// one after the other, every case builds on the student the one before left
+	tests := []testCase{
+		{name: "empty", id: "1", body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "id_in_body", id: "1", body: `{"id":7,"age":22}`, wantStatus: http.StatusBadRequest},
+		{name: "age_zero", id: "1", body: `{"age":0}`, wantStatus: http.StatusBadRequest},
+		{name: "bad_email", id: "1", body: `{"email":"asha"}`, wantStatus: http.StatusBadRequest},
+		{name: "missing", id: "999", body: `{"age":22}`, wantStatus: http.StatusNotFound},
+		{name: "age_only", id: "1", body: `{"age":22}`, wantStatus: http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		res := patch(tc.id, tc.body)
+		res.Body.Close()
+		if res.StatusCode != tc.wantStatus {
+			t.Fatalf("%s: want %d, got %d", tc.name, tc.wantStatus, res.StatusCode)
+		}
+	}
+
+	res = getJSON(t, baseURL+"/api/v1/students/1", token)
+	var got struct {
+		Data dto.Student `json:"data"`
+	}
+	json.NewDecoder(res.Body).Decode(&got)
+	res.Body.Close()
+	if want := (dto.Student{ID: 1, Name: "Asha", Email: "asha@example.com", Age: 22}); got.Data != want {
+		t.Fatalf("want %+v, got %+v", want, got.Data)
+	}
+}
+
+func TestAppLastModified(t *testing.T) {
+	t.Parallel()
+
+	clk := clock.NewFake(time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC))
+	cfg := testConfig(t)
+	cfg.Users = append(cfg.Users, config.User{Username: "admin", PasswordHash: testPasswordHash, Roles: []string{"admin"}})
+	baseURL := startApp(t, cfg, app.WithClock(clk))
+	token := login(t, baseURL)
+	res := postJSON(t, baseURL+"/api/v1/students", token, `{"name":"Asha","email":"asha@example.com","age":21}`)
+	res.Body.Close()
+
+	get := func(path, ifModifiedSince string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, baseURL+path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		if ifModifiedSince != "" {
+			req.Header.Set("If-Modified-Since", ifModifiedSince)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("get %s: %v", path, err)
+		}
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+		return res
+	}
+
+	created := "Sat, 01 Mar 2025 10:00:00 GMT"
+	for _, path := range []string{"/api/v1/students/1", "/api/v1/students"} {
+		if got := get(path, "").Header.Get("Last-Modified"); got != created {
+			t.Fatalf("%s: want Last-Modified %q, got %q", path, created, got)
+		}
+		if res := get(path, created); res.StatusCode != http.StatusNotModified {
+			t.Fatalf("%s: want 304 for an unchanged student, got %d", path, res.StatusCode)
+		}
+	}
+
+	// a later change is seen by the student and the list, a delete only by the list
+	clk.Advance(30 * time.Second) // tokens last a minute
+	req, _ := http.NewRequest(http.MethodPatch, baseURL+"/api/v1/students/1", strings.NewReader(`{"age":22}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("patch: %v", err)
+	}
+	res.Body.Close()
+	for _, path := range []string{"/api/v1/students/1", "/api/v1/students"} {
+		if res := get(path, created); res.StatusCode != http.StatusOK || res.Header.Get("Last-Modified") != "Sat, 01 Mar 2025 10:00:30 GMT" {
+			t.Fatalf("%s after the patch: want 200 with the new Last-Modified, got %d %q", path, res.StatusCode, res.Header.Get("Last-Modified"))
+		}
+	}
+
+	clk.Advance(30 * time.Second) // tokens last a minute
+	req, _ = http.NewRequest(http.MethodDelete, baseURL+"/api/v1/students/1", nil)
+	req.Header.Set("Authorization", "Bearer "+loginAs(t, baseURL, "admin", "secret"))
+	if res, err = http.DefaultClient.Do(req); err != nil || res.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete: %v %v", res, err)
+	}
+	res.Body.Close()
+	token = login(t, baseURL)
+	if res := get("/api/v1/students", "Sat, 01 Mar 2025 10:00:30 GMT"); res.StatusCode != http.StatusOK || res.Header.Get("Last-Modified") != "Sat, 01 Mar 2025 10:01:00 GMT" {
+		t.Fatalf("list after the delete: want 200 with the new Last-Modified, got %d %q", res.StatusCode, res.Header.Get("Last-Modified"))
+	}
+}
+
+func TestAppStudentCache(t *testing.T) {
+	t.Parallel()
+
+	cfg := testConfig(t)
+	cfg.StudentCache = config.StudentCache{Enabled: true, Size: 10, TTL: time.Hour}
+	cfg.AdminServer = config.HTTPServer{Address: "127.0.0.1:0"}
+	a := runApp(t, cfg)
+	baseURL, adminURL := "http://"+a.Addr().String(), "http://"+a.AdminAddr().String()
+	token := login(t, baseURL)
+	res := postJSON(t, baseURL+"/api/v1/students", token, `{"name":"Asha","email":"asha@example.com","age":21}`)
+	res.Body.Close()
+
+	student := func() dto.Student {
+		res := getJSON(t, baseURL+"/api/v1/students/1", token)
+		defer res.Body.Close()
+		var got struct {
+			Data dto.Student `json:"data"`
+		}
+		json.NewDecoder(res.Body).Decode(&got)
+		return got.Data
+	}
+	student()
+	student()
+
+	// the write drops the cached student, the next read sees it and not the one from an hour of ttl ago
+	req, _ := http.NewRequest(http.MethodPatch, baseURL+"/api/v1/students/1", strings.NewReader(`{"age":22}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("patch: %v", err)
+	}
+	res.Body.Close()
+	if got := student(); got.Age != 22 {
+		t.Fatalf("want age 22 after the patch, got %+v", got)
+	}
+
+	res, err = http.Get(adminURL + "/metrics")
+	if err != nil {
+		t.Fatalf("metrics: %v", err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	for _, want := range []string{`student_cache_requests_total{result="hit"}`, `student_cache_requests_total{result="miss"}`} {
+		if !strings.Contains(string(body), want) {
+			t.Fatalf("want %s in the metrics", want)
+		}
+	}
+}
+
+func TestAppAdminAuth(t *testing.T) {
+	t.Parallel()
+
+	cfg := testConfig(t)
+	cfg.AdminServer = config.HTTPServer{Address: "127.0.0.1:0"}
+	cfg.AdminAuth = config.AdminAuth{Username: "ops", Password: "hunter2-but-longer"}
+	a, err := app.New(cfg)
+	if err != nil {
+		t.Fatalf("app.New: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() { runErr <- a.Run(ctx) }()
+	<-a.Started()
+	t.Cleanup(func() { cancel(); <-runErr })
+	adminURL := "http://" + a.AdminAddr().String()
+
+	type testCase struct {
+		name       string
+		path       string
+		user, pass string
+		wantStatus int
+	}
+
+	tests := []testCase{
+		{name: "probe_open", path: "/healthz", wantStatus: http.StatusOK},
+		{name: "metrics_anonymous", path: "/metrics", wantStatus: http.StatusUnauthorized},
+		{name: "config_wrong_password", path: "/api/admin/config", user: "ops", pass: "nope", wantStatus: http.StatusUnauthorized},
+		{name: "config_with_password", path: "/api/admin/config", user: "ops", pass: "hunter2-but-longer", wantStatus: http.StatusOK},
+		{name: "pprof_anonymous", path: "/debug/pprof/", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			req, _ := http.NewRequest(http.MethodGet, adminURL+tc.path, nil)
+			if tc.user != "" {
+				req.SetBasicAuth(tc.user, tc.pass)
+			}
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			res.Body.Close()
+			if res.StatusCode != tc.wantStatus {
+				t.Fatalf("want %d, got %d", tc.wantStatus, res.StatusCode)
+			}
+		})
+	}
+}
+
+// the admin ui gets a short token with its basic auth login and calls the json api with it, the login itself is no
+// api credential
+func TestAppAdminUICallsAPI(t *testing.T) {
+	t.Parallel()
+
+	cfg := testConfig(t)
+	cfg.AdminAuth = config.AdminAuth{Username: "ops", Password: "hunter2-but-longer"}
+	baseURL := startApp(t, cfg)
+
+	call := func(method, path, body string, auth func(*http.Request)) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, baseURL+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		auth(req)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		return res
+	}
+	basic := func(pass string) func(*http.Request) {
+		return func(req *http.Request) { req.SetBasicAuth("ops", pass) }
+	}
+
+	res := call(http.MethodGet, "/admin/", "", basic("hunter2-but-longer"))
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("ui page: want 200, got %d", res.StatusCode)
+	}
+	res = call(http.MethodPost, "/admin/token", "", basic("nope"))
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("token with a wrong password: want 401, got %d", res.StatusCode)
+	}
+	res = call(http.MethodGet, "/api/v1/students", "", basic("hunter2-but-longer"))
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("api with the admin password: want 401, got %d", res.StatusCode)
+	}
+
+	res = call(http.MethodPost, "/admin/token", "", basic("hunter2-but-longer"))
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	err := json.NewDecoder(res.Body).Decode(&token)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || err != nil || token.AccessToken == "" || token.ExpiresIn != 300 || res.Header.Get("Cache-Control") != "no-store" {
+		t.Fatalf("ui token: want 200 with a 5 minute token, got %d %v %+v", res.StatusCode, err, token)
+	}
+	bearer := func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+token.AccessToken) }
+
+	res = call(http.MethodPost, "/api/v1/students", `{"name":"Asha","email":"asha@example.com","age":21}`, bearer)
+	var created struct {
+		Data struct {
+			ID int64 `json:"id"`
+		} `json:"data"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&created)
+	res.Body.Close()
+	if res.StatusCode != http.StatusCreated || err != nil {
+		t.Fatalf("create from the ui: want 201, got %d %v", res.StatusCode, err)
+	}
+
+	res = call(http.MethodPut, fmt.Sprintf("/api/v1/students/%d", created.Data.ID), `{"name":"Asha B","email":"asha@example.com","age":22}`, bearer)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("edit from the ui: want 200, got %d", res.StatusCode)
+	}
+
+	res = call(http.MethodGet, "/api/v1/students?limit=20&offset=0", "", bearer)
+	var list struct {
+		Data []map[string]any `json:"data"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&list)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || err != nil || len(list.Data) != 1 {
+		t.Fatalf("list from the ui: want 200 with 1 student, got %d %v %v", res.StatusCode, err, list.Data)
+	}
+}
+
+func TestAppScopedToken(t *testing.T) {
+	t.Parallel()
+
+	baseURL := startApp(t, testConfig(t))
+	res := postJSON(t, baseURL+"/api/v1/auth/login", "", `{"username":"teacher","password":"secret","scope":"students:read"}`)
+	defer res.Body.Close()
+	var body map[string]any
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil || res.StatusCode != http.StatusOK {
+		t.Fatalf("scoped login: want 200, got %d %v", res.StatusCode, err)
+	}
+	token, _ := body["access_token"].(string)
+
+	res = postJSON(t, baseURL+"/api/v1/students", login(t, baseURL), `{"name":"Asha","email":"asha@example.com","age":21}`)
+	res.Body.Close()
+
+	// read scope only, so no students:pii either -> emails come back masked
+	res = getJSON(t, baseURL+"/api/v1/students/1", token)
+	defer res.Body.Close()
+	var student struct {
+		Data map[string]any `json:"data"`
+	}
+	json.NewDecoder(res.Body).Decode(&student)
+	if res.StatusCode != http.StatusOK || student.Data["email"] != "a***@example.com" {
+		t.Fatalf("read with read scope: want 200 with masked email, got %d %v", res.StatusCode, student)
+	}
+	res = postJSON(t, baseURL+"/api/v1/students", token, `{"name":"Ravi","email":"ravi@example.com","age":22}`)
+	res.Body.Close()
+	if res.StatusCode != http.StatusForbidden {
+		t.Fatalf("write with read scope: want 403, got %d", res.StatusCode)
+	}
+}
+
+// TestOpenAPIMatchesRoutes fails when a route is added or removed without updating apiSpec in openapi.go
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	t.Parallel()
+
+	withOIDC := func(cfg *config.Config) {
+		cfg.OIDC = map[string]config.OIDCProvider{
+			"google": {Issuer: "https://accounts.example.com", ClientID: "id", RedirectURL: "http://localhost/api/v1/auth/oidc/google/callback"},
+		}
+	}
+	withoutJWT := func(cfg *config.Config) { cfg.JWT = config.JWT{} }
+
+	tests := []struct {
+		name   string
+		modify func(*config.Config)
+	}{
+		{name: "default"},
+		{name: "oidc", modify: withOIDC},
+		{name: "no jwt", modify: withoutJWT},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := testConfig(t)
+			if tc.modify != nil {
+				tc.modify(cfg)
+			}
+			a, err := app.New(cfg)
+			if err != nil {
+				t.Fatalf("app.New: %v", err)
+			}
+
+			rec := httptest.NewRecorder()
+			a.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("GET /api/v1/openapi.json = %d, want 200", rec.Code)
+			}
+			var doc struct {
+				Paths map[string]map[string]json.RawMessage `json:"paths"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+				t.Fatalf("decode spec: %v", err)
+			}
+
+			documented := map[string]bool{}
+			for path, ops := range doc.Paths {
+				for method := range ops {
+					documented[strings.ToUpper(method)+" "+path] = true
+				}
+			}
+			for _, route := range a.Routes() {
+				if !strings.Contains(route, " /api/") {
+					continue // probes and the admin ui are not part of the api
+				}
+				if !documented[route] {
+					t.Errorf("route %q is missing from the openapi document", route)
+				}
+				delete(documented, route)
+			}
+			for route := range documented {
+				t.Errorf("openapi document has %q but no such route is registered", route)
+			}
+		})
+	}
+}
+
+func TestAppGRPC(t *testing.T) {
+	t.Parallel()
+
+	cfg := testConfig(t)
+	cfg.GRPCServer = config.GRPCServer{Address: "127.0.0.1:0"}
+	cfg.Users = append(cfg.Users, config.User{Username: "admin", PasswordHash: testPasswordHash, Roles: []string{"admin"}})
+	a := runApp(t, cfg)
+	baseURL := "http://" + a.Addr().String()
+
+	conn, err := grpc.NewClient(a.GRPCAddr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc client: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	client := studentpb.NewStudentServiceClient(conn)
+	as := func(token string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+	}
+	teacher, student, admin := as(login(t, baseURL)), as(loginAs(t, baseURL, "student", "secret")), as(loginAs(t, baseURL, "admin", "secret"))
+
+	if _, err := client.ListStudents(context.Background(), &studentpb.ListStudentsRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("anonymous list: want Unauthenticated, got %v", err)
+	}
+	if _, err := client.CreateStudent(teacher, &studentpb.CreateStudentRequest{Name: "Asha", Email: "not an email", Age: 21}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("invalid create: want InvalidArgument, got %v", err)
+	}
+	created, err := client.CreateStudent(teacher, &studentpb.CreateStudentRequest{Name: "Asha", Email: "asha@example.com", Age: 21})
+	if err != nil || created.GetId() != 1 {
+		t.Fatalf("create: want id 1, got %v %v", created, err)
+	}
+
+	// grpc and http share the storage
+	res := getJSON(t, baseURL+"/api/v1/students/1", login(t, baseURL))
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("http get of a grpc created student: want 200, got %d", res.StatusCode)
+	}
+
+	if got, err := client.GetStudent(student, &studentpb.GetStudentRequest{Id: 1}); err != nil || got.GetEmail() != "asha@example.com" {
+		t.Fatalf("student reads own record: got %v %v", got, err)
+	}
+	if _, err := client.UpdateStudent(student, &studentpb.UpdateStudentRequest{Id: 1, Name: "Asha", Email: "asha@example.com", Age: 22}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("student update: want PermissionDenied, got %v", err)
+	}
+	if got, err := client.UpdateStudent(teacher, &studentpb.UpdateStudentRequest{Id: 1, Name: "Asha", Email: "asha@example.com", Age: 22}); err != nil || got.GetAge() != 22 {
+		t.Fatalf("update: got %v %v", got, err)
+	}
+	if page, err := client.ListStudents(teacher, &studentpb.ListStudentsRequest{}); err != nil || len(page.GetStudents()) != 1 {
+		t.Fatalf("list: got %v %v", page, err)
+	}
+
+	// only admins may delete
+	if _, err := client.DeleteStudent(teacher, &studentpb.DeleteStudentRequest{Id: 1}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("teacher delete: want PermissionDenied, got %v", err)
+	}
+	if _, err := client.DeleteStudent(admin, &studentpb.DeleteStudentRequest{Id: 1}); err != nil {
+		t.Fatalf("admin delete: %v", err)
+	}
+	if _, err := client.GetStudent(teacher, &studentpb.GetStudentRequest{Id: 1}); status.Code(err) != codes.NotFound {
+		t.Fatalf("get deleted: want NotFound, got %v", err)
+	}
+}
+
+func TestAppGateway(t *testing.T) {
+	t.Parallel()
+
+	baseURL := startApp(t, testConfig(t))
+	token := login(t, baseURL)
+
+	res := postJSON(t, baseURL+"/api/rpc/v1/students", "", `{"name":"Asha","email":"asha@example.com","age":21}`)
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("anonymous create: want 401, got %d", res.StatusCode)
+	}
+	res = postJSON(t, baseURL+"/api/rpc/v1/students", token, `{"name":"Asha","email":"not an email","age":21}`)
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("invalid create: want 400, got %d", res.StatusCode)
+	}
+	res = postJSON(t, baseURL+"/api/rpc/v1/students", token, `{"name":"Asha","email":"asha@example.com","age":21}`)
+	var created map[string]any
+	json.NewDecoder(res.Body).Decode(&created)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || created["id"] != "1" { // protojson writes int64 as a string
+		t.Fatalf("create: want 200 with id 1, got %d %v", res.StatusCode, created)
+	}
+
+	// the gateway and the hand written routes share the storage
+	res = getJSON(t, baseURL+"/api/v1/students/1", token)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("http get of a gateway created student: want 200, got %d", res.StatusCode)
+	}
+	res = getJSON(t, baseURL+"/api/rpc/v1/students?limit=10", token)
+	var page struct {
+		Students []map[string]any `json:"students"`
+	}
+	json.NewDecoder(res.Body).Decode(&page)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || len(page.Students) != 1 || page.Students[0]["email"] != "asha@example.com" {
+		t.Fatalf("list: got %d %v", res.StatusCode, page)
+	}
+	res = getJSON(t, baseURL+"/api/rpc/v1/students/99", token)
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("missing student: want 404, got %d", res.StatusCode)
+	}
+}
+
+func TestAppWebSocket(t *testing.T) {
+	t.Parallel()
+
+	a, err := app.New(testConfig(t))
+	if err != nil {
+		t.Fatalf("app.New: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runErr := make(chan error, 1)
+	go func() { runErr <- a.Run(ctx) }()
+	<-a.Started()
+	baseURL := "http://" + a.Addr().String()
+	token := login(t, baseURL)
+
+	dialCtx, cancelDial := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelDial()
+	if _, res, err := websocket.Dial(dialCtx, baseURL+"/api/v1/ws", nil); err == nil || res == nil || res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("anonymous dial: want 401, got %v", err)
+	}
+	conn, _, err := websocket.Dial(dialCtx, baseURL+"/api/v1/ws", &websocket.DialOptions{
+		HTTPHeader: http.Header{"Authorization": {"Bearer " + token}},
+	})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.CloseNow()
+
+	read := func() map[string]any {
+		t.Helper()
+		_, data, err := conn.Read(dialCtx)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		var msg map[string]any
+		json.Unmarshal(data, &msg)
+		return msg
+	}
+	conn.Write(dialCtx, websocket.MessageText, []byte(`{"type":"subscribe","events":["account.locked"]}`))
+	if msg := read(); msg["type"] != "error" {
+		t.Fatalf("subscribe to an internal event: want error, got %v", msg)
+	}
+	conn.Write(dialCtx, websocket.MessageText, []byte(`{"type":"subscribe","events":["student.created","student.updated"]}`))
+	if msg := read(); msg["type"] != "subscribed" {
+		t.Fatalf("subscribe: got %v", msg)
+	}
+
+	res := postJSON(t, baseURL+"/api/v1/students", token, `{"name":"Asha","email":"asha@example.com","age":21}`)
+	res.Body.Close()
+	msg := read()
+	payload, _ := msg["payload"].(map[string]any)
+	if msg["type"] != "student.created" || payload["email"] != "asha@example.com" {
+		t.Fatalf("want student.created with the email for a teacher, got %v", msg)
+	}
+
+	// shutdown ends the connection with going away instead of leaving it hanging
+	cancel()
+	if _, _, err := conn.Read(dialCtx); websocket.CloseStatus(err) != websocket.StatusGoingAway {
+		t.Fatalf("after shutdown: want going away, got %v", err)
+	}
+	if err := <-runErr; err != nil {
+		t.Fatalf("Run returned error on shutdown: %v", err)
+	}
+}
+
+func TestAppEventStream(t *testing.T) {
+	t.Parallel()
+
+	baseURL := startApp(t, testConfig(t))
+	token := login(t, baseURL)
+
+	// open subscribes to the stream, next returns the id and type of the next event
+	open := func(lastID string) (next func() (string, string), stop func()) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/api/v1/students/events", nil)
+		req.Header.Set("Accept", "text/event-stream")
+		req.Header.Set("Authorization", "Bearer "+token)
+		if lastID != "" {
+			req.Header.Set("Last-Event-ID", lastID)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil || res.StatusCode != http.StatusOK {
+			t.Fatalf("open stream: %v %v", res, err)
+		}
+		lines := bufio.NewScanner(res.Body)
+		next = func() (id, event string) {
+			for lines.Scan() {
+				line := lines.Text()
+				switch {
+				case strings.HasPrefix(line, "id: "):
+					id = strings.TrimPrefix(line, "id: ")
+				case strings.HasPrefix(line, "event: "):
+					event = strings.TrimPrefix(line, "event: ")
+				case line == "" && event != "":
+					return id, event
+				}
+			}
+			t.Fatalf("stream ended: %v", lines.Err())
+			return "", ""
+		}
+		return next, func() { cancel(); res.Body.Close() }
+	}
+
+	next, stop := open("")
+	res := postJSON(t, baseURL+"/api/v1/students", token, `{"name":"Asha","email":"asha@example.com","age":21}`)
+	res.Body.Close()
+	id, event := next()
+	if event != "student.created" || id == "" {
+		t.Fatalf("want student.created with an id, got %q %q", event, id)
+	}
+	stop()
+
+	// the update happens while nobody listens, resuming from the last id still delivers it
+	req, _ := http.NewRequest(http.MethodPut, baseURL+"/api/v1/students/1", strings.NewReader(`{"name":"Asha","email":"asha@example.com","age":22}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil || res.StatusCode != http.StatusOK {
+		t.Fatalf("update: %v %v", res, err)
+	}
+	res.Body.Close()
+
+	next, stop = open(id)
+	defer stop()
+	if _, event := next(); event != "student.updated" {
+		t.Fatalf("resume: want student.updated, got %q", event)
+	}
+
+	next, stop = open("unknown-1")
+	defer stop()
+	if _, event := next(); event != "reset" {
+		t.Fatalf("resume from an unknown id: want reset, got %q", event)
+	}
+}
+
+func TestAppWebhooks(t *testing.T) {
+	t.Parallel()
+
+	received := make(chan string, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("X-Webhook-Event")
+	}))
+	t.Cleanup(receiver.Close)
+
+	cfg := testConfig(t)
+	cfg.AdminServer = config.HTTPServer{Address: "127.0.0.1:0"}
+	a := runApp(t, cfg)
+	baseURL, adminURL := "http://"+a.Addr().String(), "http://"+a.AdminAddr().String()
+
+	res := postJSON(t, adminURL+"/api/admin/webhooks", "", `{"url":"`+receiver.URL+`","events":["account.locked"]}`)
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("webhook for an internal event: want 400, got %d", res.StatusCode)
+	}
+	res = postJSON(t, adminURL+"/api/admin/webhooks", "", `{"url":"`+receiver.URL+`","events":["student.created"]}`)
+	var created struct {
+		Data map[string]any `json:"data"`
+	}
+	json.NewDecoder(res.Body).Decode(&created)
+	res.Body.Close()
+	hook := created.Data
+	if res.StatusCode != http.StatusCreated || hook["secret"] == "" {
+		t.Fatalf("create webhook: want 201 with a secret, got %d %v", res.StatusCode, hook)
+	}
+	if location := res.Header.Get("Location"); location != fmt.Sprintf("/api/admin/webhooks/%v", hook["id"]) {
+		t.Fatalf("create webhook: want Location of the new webhook, got %q", location)
+	}
+
+	res = postJSON(t, baseURL+"/api/v1/students", login(t, baseURL), `{"name":"Asha","email":"asha@example.com","age":21}`)
+	res.Body.Close()
+	select {
+	case event := <-received:
+		if event != "student.created" {
+			t.Fatalf("want student.created, got %q", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not called")
+	}
+
+	// the log shows it once the result is saved
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		res = getJSON(t, fmt.Sprintf("%s/api/admin/webhooks/%v/deliveries", adminURL, hook["id"]), "")
+		var log struct {
+			Deliveries []struct {
+				Status string `json:"status"`
+			} `json:"data"`
+		}
+		json.NewDecoder(res.Body).Decode(&log)
+		res.Body.Close()
+		if len(log.Deliveries) == 1 && log.Deliveries[0].Status == "delivered" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery log: want one delivered, got %+v", log.Deliveries)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAppDeadJobs(t *testing.T) {
+	t.Parallel()
+
+	// the receiver is down until up is set
+	var up atomic.Bool
+	received := make(chan struct{}, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		received <- struct{}{}
+	}))
+	t.Cleanup(receiver.Close)
+
+	cfg := testConfig(t)
+	cfg.AdminServer = config.HTTPServer{Address: "127.0.0.1:0"}
+	cfg.Jobs.Retry = map[string]config.Retry{types.DeliveryJob: {MaxAttempts: 1}} // fails for good on the first 502
+	a := runApp(t, cfg)
+	baseURL, adminURL := "http://"+a.Addr().String(), "http://"+a.AdminAddr().String()
+
+	res := postJSON(t, adminURL+"/api/admin/webhooks", "", `{"url":"`+receiver.URL+`","events":["student.created"]}`)
+	res.Body.Close()
+	res = postJSON(t, baseURL+"/api/v1/students", login(t, baseURL), `{"name":"Asha","email":"asha@example.com","age":21}`)
+	res.Body.Close()
+
+	type deadJobs struct {
+		Data []dto.DeadJob `json:"data"`
+	}
+	var dead deadJobs
+	deadline := time.Now().Add(5 * time.Second)
+	for len(dead.Data) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the delivery did not become a dead job")
+		}
+		time.Sleep(10 * time.Millisecond)
+		res = getJSON(t, adminURL+"/api/admin/jobs/dead?kind="+types.DeliveryJob, "")
+		json.NewDecoder(res.Body).Decode(&dead)
+		res.Body.Close()
+	}
+	if dead.Data[0].Attempts != 1 || !strings.Contains(dead.Data[0].LastError, "502") || dead.Data[0].RequeuedAt != nil {
+		t.Fatalf("dead job: want the delivery after one attempt with the 502, got %+v", dead.Data[0])
+	}
+
+	up.Store(true)
+	requeue := fmt.Sprintf("%s/api/admin/jobs/dead/%d/requeue", adminURL, dead.Data[0].ID)
+	res = postJSON(t, requeue, "", "")
+	var requeued struct {
+		Data dto.DeadJob `json:"data"`
+	}
+	json.NewDecoder(res.Body).Decode(&requeued)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || requeued.Data.RequeuedJobID == 0 {
+		t.Fatalf("requeue: want 200 with the new job, got %d %+v", res.StatusCode, requeued.Data)
+	}
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("requeued delivery was not sent")
+	}
+
+	res = postJSON(t, requeue, "", "")
+	res.Body.Close()
+	if res.StatusCode != http.StatusConflict {
+		t.Fatalf("second requeue: want 409, got %d", res.StatusCode)
+	}
+	res = postJSON(t, adminURL+"/api/admin/jobs/dead/999/requeue", "", "")
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("requeue of an unknown dead job: want 404, got %d", res.StatusCode)
+	}
+
+	cfg = testConfig(t)
+	cfg.Jobs.Jitter = 2
+	if _, err := app.New(cfg); err == nil || !strings.Contains(err.Error(), "jobs.jitter") {
+		t.Fatalf("app.New with a jitter over 1: want a jobs.jitter error, got %v", err)
+	}
+}
+
+func TestAppScheduledTasks(t *testing.T) {
+	t.Parallel()
+
+	cfg := testConfig(t)
+	cfg.Scheduler.PurgeJobs = config.Purge{Schedule: "@every 1s", KeepFor: time.Hour}
+	cfg.Scheduler.PurgeDeliveries = config.Purge{Schedule: "@every 1s", KeepFor: time.Hour}
+	cfg.Scheduler.PurgeRefreshTokens = config.Purge{Schedule: "off"}
+	cfg.AdminServer = config.HTTPServer{Address: "127.0.0.1:0"}
+	a := runApp(t, cfg)
+	adminURL := "http://" + a.AdminAddr().String()
+
+	type task struct {
+		Name      string `json:"name"`
+		Runs      int    `json:"runs"`
+		LastError string `json:"last_error"`
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		res := getJSON(t, adminURL+"/api/admin/tasks", "")
+		var body struct {
+			Data []task `json:"data"`
+		}
+		json.NewDecoder(res.Body).Decode(&body)
+		res.Body.Close()
+		if len(body.Data) != 2 || body.Data[0].Name != "purge_jobs" || body.Data[1].Name != "purge_deliveries" {
+			t.Fatalf("tasks: want the two purges that are on, got %+v", body.Data)
+		}
+		if body.Data[0].Runs > 0 && body.Data[1].Runs > 0 {
+			for _, task := range body.Data {
+				if task.LastError != "" {
+					t.Fatalf("task %s failed: %s", task.Name, task.LastError)
+				}
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("tasks did not run: %+v", body.Data)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	cfg = testConfig(t)
+	cfg.Scheduler.PurgeJobs = config.Purge{Schedule: "every day"}
+	if _, err := app.New(cfg); err == nil || !strings.Contains(err.Error(), "scheduler.purge_jobs") {
+		t.Fatalf("app.New with a bad schedule: want a scheduler.purge_jobs error, got %v", err)
+	}
+}
+
+func TestAppEmails(t *testing.T) {
+	t.Parallel()
+
+	// nothing listens there, the email stays pending with the error of the first attempt
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	cfg := testConfig(t)
+	cfg.Email = config.Email{Host: "127.0.0.1", Port: port, TLS: "none", From: "no-reply@example.com"}
+	cfg.AdminServer = config.HTTPServer{Address: "127.0.0.1:0"}
+	a := runApp(t, cfg)
+	baseURL, adminURL := "http://"+a.Addr().String(), "http://"+a.AdminAddr().String()
+
+	res := postJSON(t, baseURL+"/api/v1/students", login(t, baseURL), `{"name":"Asha","email":"asha@example.com","age":21}`)
+	res.Body.Close()
+
+	type email struct {
+		Template  string `json:"template"`
+		To        string `json:"to"`
+		Status    string `json:"status"`
+		Attempts  int    `json:"attempts"`
+		LastError string `json:"last_error"`
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		res = getJSON(t, adminURL+"/api/admin/emails?status=pending", "")
+		var body struct {
+			Data []email `json:"data"`
+		}
+		json.NewDecoder(res.Body).Decode(&body)
+		res.Body.Close()
+		if len(body.Data) == 1 && body.Data[0].Attempts == 1 {
+			if e := body.Data[0]; e.Template != "welcome" || e.To != "asha@example.com" || e.LastError == "" {
+				t.Fatalf("outbox: want the failed welcome email, got %+v", e)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("outbox: want one welcome email after its first attempt, got %+v", body.Data)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	res = getJSON(t, adminURL+"/api/admin/emails?status=bounced", "")
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("unknown status filter: want 400, got %d", res.StatusCode)
+	}
+}
+
+func TestAppOutbox(t *testing.T) {
+	t.Parallel()
+
+	received := make(chan []outbox.Message, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []outbox.Message
+		json.NewDecoder(r.Body).Decode(&batch)
+		received <- batch
+	}))
+	t.Cleanup(receiver.Close)
+
+	cfg := testConfig(t)
+	cfg.Outbox = config.Outbox{Sink: "http", URL: receiver.URL}
+	baseURL := startApp(t, cfg)
+
+	res := postJSON(t, baseURL+"/api/v1/students", login(t, baseURL), `{"name":"Asha","email":"asha@example.com","age":21}`)
+	res.Body.Close()
+	select {
+	case batch := <-received:
+		if len(batch) != 1 || batch[0].Type != "student.created" || batch[0].Id != 1 {
+			t.Fatalf("sink got %+v, want the student.created event", batch)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not published to the sink")
+	}
+}
@@ -0,0 +1,119 @@
+package app_test
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/app"
+)
+
+// the whole hot path over a real socket -> router, middlewares, decode, validate, sqlite and the json answer.
+// go test -run '^$' -bench . -benchmem ./internal/app, compare runs with benchstat. for load from outside the
+// process see loadtest/
+
+// benchApp starts the app without request logs, they would be most of what gets measured
+func benchApp(b *testing.B) (baseURL, token string) {
+	b.Helper()
+	level := new(slog.LevelVar)
+	level.Set(slog.LevelError)
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: level}))
+	baseURL = startApp(b, testConfig(b), app.WithLogger(logger, level))
+	return baseURL, login(b, baseURL)
+}
+
+// send does the request and reads the answer to the end, so the connection goes back to the pool. Errorf and not
+// Fatalf, it runs in the goroutines of RunParallel too
+func send(b *testing.B, req *http.Request, token string, wantStatus int) {
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		b.Errorf("request: %v", err)
+		return
+	}
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	if res.StatusCode != wantStatus {
+		b.Errorf("%s %s: want %d, got %d", req.Method, req.URL.Path, wantStatus, res.StatusCode)
+	}
+}
+
+// seed adds n students, ids 1 to n
+func seed(b *testing.B, baseURL, token string, n int) {
+	b.Helper()
+	for i := 0; i < n; i++ {
+		req, _ := http.NewRequest(http.MethodPost, baseURL+"/api/v1/students",
+			strings.NewReader(fmt.Sprintf(`{"name":"Seed","email":"seed%d@example.com","age":21}`, i)))
+		req.Header.Set("Content-Type", "application/json")
+		send(b, req, token, http.StatusCreated)
+	}
+}
+
+func BenchmarkCreateStudent(b *testing.B) {
+	baseURL, token := benchApp(b)
+	var n atomic.Int64 // emails are unique
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req, _ := http.NewRequest(http.MethodPost, baseURL+"/api/v1/students",
+			strings.NewReader(fmt.Sprintf(`{"name":"Asha","email":"asha%d@example.com","age":21}`, n.Add(1))))
+		req.Header.Set("Content-Type", "application/json")
+		send(b, req, token, http.StatusCreated)
+	}
+}
+
+func BenchmarkGetStudent(b *testing.B) {
+	baseURL, token := benchApp(b)
+	seed(b, baseURL, token, 1)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			req, _ := http.NewRequest(http.MethodGet, baseURL+"/api/v1/students/1", nil)
+			send(b, req, token, http.StatusOK)
+		}
+	})
+}
+
+func BenchmarkListStudents(b *testing.B) {
+	baseURL, token := benchApp(b)
+	seed(b, baseURL, token, 50)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			req, _ := http.NewRequest(http.MethodGet, baseURL+"/api/v1/students?limit=50", nil)
+			send(b, req, token, http.StatusOK)
+		}
+	})
+}
+
+// 100 students per request, the most a bulk create takes
+func BenchmarkBulkCreate(b *testing.B) {
+	baseURL, token := benchApp(b)
+	var n atomic.Int64
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var body strings.Builder
+		body.WriteString("[")
+		for j := 0; j < 100; j++ {
+			if j > 0 {
+				body.WriteString(",")
+			}
+			fmt.Fprintf(&body, `{"name":"Asha","email":"asha%d@example.com","age":21}`, n.Add(1))
+		}
+		body.WriteString("]")
+		req, _ := http.NewRequest(http.MethodPost, baseURL+"/api/v1/students/bulk", strings.NewReader(body.String()))
+		req.Header.Set("Content-Type", "application/json")
+		send(b, req, token, http.StatusOK)
+	}
+}
@@ -0,0 +1,40 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ShutdownFunc closes one resource (db handle, cache client, worker pool...)
+type ShutdownFunc func(ctx context.Context) error
+
+// Hooks keeps the teardown functions registered during startup.
+// they run in reverse order, like defer, so something opened last (a worker using the db) is closed before what it depends on (the db)
+type Hooks struct {
+	mu    sync.Mutex
+	hooks []ShutdownFunc
+}
+
+func (h *Hooks) OnShutdown(fn ShutdownFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hooks = append(h.hooks, fn)
+}
+
+// Run calls every hook once, even when one fails, and returns all the errors joined.
+// hooks are removed after running so calling Run twice does not close things twice
+func (h *Hooks) Run(ctx context.Context) error {
+	h.mu.Lock()
+	hooks := h.hooks
+	h.hooks = nil
+	h.mu.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i](ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,38 @@
+package app_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/app"
+)
+
+func TestHooksRunInReverseOrder(t *testing.T) {
+	t.Parallel()
+
+	var hooks app.Hooks
+	var order []string
+	errCache := errors.New("cache close failed")
+
+	hooks.OnShutdown(func(ctx context.Context) error { order = append(order, "db"); return nil })
+	hooks.OnShutdown(func(ctx context.Context) error { order = append(order, "cache"); return errCache })
+	hooks.OnShutdown(func(ctx context.Context) error { order = append(order, "workers"); return nil })
+
+	err := hooks.Run(context.Background())
+
+	// a failing hook must not stop the ones after it
+	if want := []string{"workers", "cache", "db"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("want order %v, got %v", want, order)
+	}
+	if !errors.Is(err, errCache) {
+		t.Fatalf("want joined error to contain %v, got %v", errCache, err)
+	}
+
+	// second run is a no-op
+	order = nil
+	if err := hooks.Run(context.Background()); err != nil || len(order) != 0 {
+		t.Fatalf("second run should do nothing, got order=%v err=%v", order, err)
+	}
+}
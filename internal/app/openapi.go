@@ -0,0 +1,122 @@
+package app
+
+import (
+	"net/http"
+	"reflect"
+
+	"github.com/manishtomar-cpi/go-server/internal/buildinfo"
+	"github.com/manishtomar-cpi/go-server/internal/export"
+	"github.com/manishtomar-cpi/go-server/internal/http/dto"
+	authhandler "github.com/manishtomar-cpi/go-server/internal/http/handllers/auth"
+	student "github.com/manishtomar-cpi/go-server/internal/http/handllers/students"
+	"github.com/manishtomar-cpi/go-server/internal/importer"
+	"github.com/manishtomar-cpi/go-server/internal/openapi"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+// apiSpec describes every /api/v1 route (the deprecated unversioned aliases are left out).
+// login and oidc say which optional auth routes are mounted, TestOpenAPIMatchesRoutes fails when this list and the router drift apart
+func apiSpec(version string, login, oidc bool) *openapi.Spec {
+	spec := openapi.New("go-server", version)
+	failed := response.Response{}
+
+	spec.Add(openapi.Operation{Method: "GET", Path: "/api/v1/openapi.json", Summary: "This document", Tag: "docs",
+		Responses: map[int]any{http.StatusOK: map[string]any{}}})
+	spec.Add(openapi.Operation{Method: "GET", Path: "/api/v1/docs", Summary: "Swagger UI", Tag: "docs",
+		Responses: map[int]any{http.StatusOK: nil}})
+	spec.Add(openapi.Operation{Method: "GET", Path: "/api/rpc/openapi.json", Summary: "Swagger 2.0 document of the /api/rpc/v1 gateway, generated from student.proto", Tag: "docs",
+		Responses: map[int]any{http.StatusOK: map[string]any{}}})
+	spec.Add(openapi.Operation{Method: "GET", Path: "/api/v1/version", Summary: "Build information", Tag: "meta",
+		Responses: map[int]any{http.StatusOK: enveloped(buildinfo.Info{})}})
+
+	spec.Add(openapi.Operation{Method: "POST", Path: "/api/v1/auth/register", Summary: "Create an account", Tag: "auth",
+		Body:      authhandler.RegisterRequest{},
+		Responses: map[int]any{http.StatusCreated: enveloped(dto.User{}), http.StatusBadRequest: failed, http.StatusConflict: failed}})
+	if login {
+		spec.Add(openapi.Operation{Method: "POST", Path: "/api/v1/auth/login", Summary: "Log in with username and password", Tag: "auth",
+			Body: authhandler.LoginRequest{},
+			Responses: map[int]any{http.StatusOK: authhandler.TokenResponse{}, http.StatusBadRequest: failed,
+				http.StatusUnauthorized: failed, http.StatusTooManyRequests: failed}})
+		spec.Add(openapi.Operation{Method: "POST", Path: "/api/v1/auth/refresh", Summary: "Trade a refresh token for new tokens", Tag: "auth",
+			Body:      authhandler.RefreshRequest{},
+			Responses: map[int]any{http.StatusOK: authhandler.TokenResponse{}, http.StatusUnauthorized: failed}})
+		spec.Add(openapi.Operation{Method: "POST", Path: "/api/v1/auth/logout", Summary: "Revoke a refresh token and its session", Tag: "auth",
+			Body:      authhandler.RefreshRequest{},
+			Responses: map[int]any{http.StatusNoContent: nil}})
+	}
+	if login && oidc {
+		spec.Add(openapi.Operation{Method: "GET", Path: "/api/v1/auth/oidc/{provider}/login", Summary: "Start sign-in with an identity provider", Tag: "auth",
+			Responses: map[int]any{http.StatusFound: nil, http.StatusNotFound: failed}})
+		spec.Add(openapi.Operation{Method: "GET", Path: "/api/v1/auth/oidc/{provider}/callback", Summary: "Finish sign-in with an identity provider", Tag: "auth",
+			Query:     []openapi.Param{{Name: "code", Type: "string"}, {Name: "state", Type: "string"}},
+			Responses: map[int]any{http.StatusOK: authhandler.TokenResponse{}, http.StatusBadRequest: failed, http.StatusUnauthorized: failed}})
+	}
+
+	page := []openapi.Param{
+		{Name: "limit", Type: "integer", Description: "1 to 500, default 50"},
+		{Name: "offset", Type: "integer", Description: "rows to skip"},
+	}
+	spec.Add(openapi.Operation{Method: "POST", Path: "/api/v1/students", Summary: "Create a student", Tag: "students", Auth: true,
+		Body:      dto.CreateStudentRequest{},
+		Responses: map[int]any{http.StatusCreated: enveloped(dto.Student{}), http.StatusBadRequest: failed, http.StatusForbidden: failed}})
+	spec.Add(openapi.Operation{Method: "POST", Path: "/api/v1/students/bulk", Summary: "Create up to 100 students, one result per student", Tag: "students", Auth: true,
+		Body: []dto.CreateStudentRequest{},
+		Responses: map[int]any{http.StatusOK: enveloped([]response.BulkItem{}), http.StatusMultiStatus: enveloped([]response.BulkItem{}),
+			http.StatusBadRequest: failed, http.StatusForbidden: failed}})
+	spec.Add(openapi.Operation{Method: "GET", Path: "/api/v1/students", Summary: "List students", Tag: "students", Auth: true,
+		Query: append(page,
+			openapi.Param{Name: "sort", Type: "string", Description: "id, name or age, a leading - sorts descending, default id"},
+			openapi.Param{Name: "name", Type: "string", Description: "part of the name, any case"},
+			openapi.Param{Name: "min_age", Type: "integer", Description: "1 to 100"},
+			openapi.Param{Name: "max_age", Type: "integer", Description: "1 to 100"}),
+		Responses: map[int]any{http.StatusOK: enveloped([]dto.Student{}), http.StatusNotModified: nil, http.StatusForbidden: failed}})
+	spec.Add(openapi.Operation{Method: "GET", Path: "/api/v1/students/check-email", Summary: "Check if an email is still free", Tag: "students", Auth: true,
+		Query:     []openapi.Param{{Name: "email", Type: "string", Description: "the email a form is about to send"}},
+		Responses: map[int]any{http.StatusOK: enveloped(student.EmailCheck{}), http.StatusBadRequest: failed, http.StatusForbidden: failed}})
+	spec.Add(openapi.Operation{Method: "GET", Path: "/api/v1/students/{id}", Summary: "Get one student", Tag: "students", Auth: true,
+		Responses: map[int]any{http.StatusOK: enveloped(dto.Student{}), http.StatusNotModified: nil, http.StatusForbidden: failed,
+			http.StatusNotFound: failed}})
+	spec.Add(openapi.Operation{Method: "PUT", Path: "/api/v1/students/{id}", Summary: "Replace a student", Tag: "students", Auth: true,
+		Body:      dto.CreateStudentRequest{},
+		Responses: map[int]any{http.StatusOK: enveloped(dto.Student{}), http.StatusBadRequest: failed, http.StatusNotFound: failed}})
+	spec.Add(openapi.Operation{Method: "PATCH", Path: "/api/v1/students/{id}", Summary: "Change some fields of a student", Tag: "students", Auth: true,
+		Body:      dto.UpdateStudentRequest{},
+		Responses: map[int]any{http.StatusOK: enveloped(dto.Student{}), http.StatusBadRequest: failed, http.StatusNotFound: failed}})
+	spec.Add(openapi.Operation{Method: "DELETE", Path: "/api/v1/students/{id}", Summary: "Delete a student", Tag: "students", Auth: true,
+		Responses: map[int]any{http.StatusNoContent: nil, http.StatusForbidden: failed, http.StatusNotFound: failed}})
+	spec.Add(openapi.Operation{Method: "GET", Path: "/api/v1/students/export", Summary: "Stream all students", Tag: "students", Auth: true,
+		Query: []openapi.Param{{Name: "cursor", Type: "string", Description: "continuation of a partial export"}},
+		Responses: map[int]any{http.StatusOK: struct {
+			Data []dto.Student `json:"data"`
+			Meta export.Meta   `json:"meta"`
+		}{}}})
+	spec.Add(openapi.Operation{Method: "POST", Path: "/api/v1/students/stream", Summary: "Import students sent as ndjson, one result line per record", Tag: "students", Auth: true,
+		Body:      dto.CreateStudentRequest{},
+		Responses: map[int]any{http.StatusOK: student.IngestResult{}, http.StatusForbidden: failed, http.StatusUnsupportedMediaType: failed}})
+	spec.Add(openapi.Operation{Method: "POST", Path: "/api/v1/students/imports", Summary: "Import a large csv or ndjson upload in the background", Tag: "students", Auth: true,
+		Body: dto.CreateStudentRequest{},
+		Responses: map[int]any{http.StatusAccepted: enveloped(dto.ImportJob{}), http.StatusBadRequest: failed, http.StatusForbidden: failed,
+			http.StatusRequestEntityTooLarge: failed, http.StatusUnsupportedMediaType: failed}})
+	spec.Add(openapi.Operation{Method: "GET", Path: "/api/v1/jobs/{id}", Summary: "Progress of a background import", Tag: "students", Auth: true,
+		Responses: map[int]any{http.StatusOK: enveloped(dto.ImportJob{}), http.StatusForbidden: failed, http.StatusNotFound: failed}})
+	spec.Add(openapi.Operation{Method: "GET", Path: "/api/v1/jobs/{id}/errors", Summary: "Records of a background import that failed, as ndjson", Tag: "students", Auth: true,
+		Responses: map[int]any{http.StatusOK: importer.Failure{}, http.StatusForbidden: failed, http.StatusNotFound: failed}})
+	spec.Add(openapi.Operation{Method: "GET", Path: "/api/v1/students/events", Summary: "Student changes as server-sent events", Tag: "live", Auth: true,
+		Query: []openapi.Param{
+			{Name: "types", Type: "string", Description: "comma separated event types, default all"},
+			{Name: "last_event_id", Type: "string", Description: "resume point when the Last-Event-ID header can not be sent"},
+		},
+		Responses: map[int]any{http.StatusOK: nil, http.StatusBadRequest: failed, http.StatusServiceUnavailable: failed}})
+	spec.Add(openapi.Operation{Method: "GET", Path: "/api/v1/ws", Summary: "Live student changes over websocket", Tag: "live", Auth: true,
+		Responses: map[int]any{http.StatusSwitchingProtocols: nil, http.StatusServiceUnavailable: failed}})
+	return spec
+}
+
+// enveloped is the type of response.Envelope with data typed as v, so the schema shows what is inside data
+func enveloped(v any) any {
+	t := reflect.StructOf([]reflect.StructField{
+		{Name: "Data", Type: reflect.TypeOf(v), Tag: `json:"data"`},
+		{Name: "Meta", Type: reflect.TypeOf(response.Meta{}), Tag: `json:"meta"`},
+	})
+	return reflect.Zero(t).Interface()
+}
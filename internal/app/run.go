@@ -0,0 +1,245 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+	httpserver "github.com/manishtomar-cpi/go-server/internal/http/server"
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/warmup"
+)
+
+// Run opens the listeners, warms up, flips readiness to ready and then blocks until ctx is cancelled
+// (main cancels it on SIGINT/SIGTERM), after that it shuts everything down gracefully
+func (a *App) Run(ctx context.Context) error {
+	ln, err := httpserver.Listen(a.cfg.HTTPServer)
+	if err != nil {
+		return err
+	}
+	var adminLn net.Listener
+	if a.cfg.AdminServer.Address != "" {
+		adminLn, err = httpserver.Listen(a.cfg.AdminServer)
+		if err != nil {
+			ln.Close()
+			return err
+		}
+	}
+
+	var grpcLn net.Listener
+	if a.grpcServer != nil {
+		grpcLn, err = net.Listen("tcp", a.cfg.GRPCServer.Address)
+		if err != nil {
+			ln.Close()
+			if adminLn != nil {
+				adminLn.Close()
+			}
+			return err
+		}
+	}
+
+	a.mu.Lock()
+	a.addr = ln.Addr()
+	if adminLn != nil {
+		a.adminAddr = adminLn.Addr()
+	}
+	if grpcLn != nil {
+		a.grpcAddr = grpcLn.Addr()
+	}
+	a.mu.Unlock()
+	close(a.started)
+
+	serveErr := make(chan error, 4)
+	go func() { // so over server is running in seprate go routine
+		serveErr <- httpserver.Serve(a.server, ln, a.cfg.HTTPServer)
+	}()
+	if adminLn != nil {
+		go func() {
+			serveErr <- httpserver.Serve(a.adminServer, adminLn, a.cfg.AdminServer)
+		}()
+	}
+	if a.http3Server != nil {
+		go func() {
+			serveErr <- httpserver.ServeHTTP3(a.http3Server, a.cfg.HTTPServer)
+		}()
+	}
+	if grpcLn != nil {
+		go func() {
+			serveErr <- a.grpcServer.Serve(grpcLn) // nil once GracefulStop was called
+		}()
+		slog.Info("grpc server started", slog.String("address", grpcLn.Addr().String()))
+	}
+	slog.Info("server started", slog.String("address", ln.Addr().String()))
+
+	a.warmUp(ctx)
+	// the background workers stop when shutdown starts, also when a listener failing started it
+	workCtx, stopWorkers := context.WithCancel(ctx)
+	defer stopWorkers()
+	go a.checker.Run(workCtx) // dependency checks refresh in the background until shutdown starts
+	go a.jobs.Run(workCtx)
+	go a.scheduler.Run(workCtx)
+	if a.outbox != nil {
+		go a.outbox.Run(workCtx)
+	}
+	a.readiness.SetReady(true)
+
+	var runErr error
+	select {
+	case <-ctx.Done(): // we will block here untill we dont get any intruptions
+	case err := <-serveErr:
+		// ErrServerClosed is what Serve returns after Shutdown was called, that is the normal way to stop
+		if !errors.Is(err, http.ErrServerClosed) {
+			runErr = err
+		}
+	}
+
+	stopWorkers()
+	//Try to gracefully shut down the server, but if it takes longer than the drain timeout, force quit.
+	drainCtx, cancel := context.WithTimeout(context.Background(), a.cfg.Shutdown.DrainTimeout)
+	defer cancel()
+	return errors.Join(runErr, a.Shutdown(drainCtx))
+}
+
+// warm up before telling the load balancer we are ready
+func (a *App) warmUp(ctx context.Context) {
+	if !a.cfg.Warmup.Enabled {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, a.cfg.Warmup.Timeout)
+	defer cancel()
+
+	var steps []warmup.Step
+	if warmer, ok := any(a.storage).(storage.Warmer); ok {
+		steps = append(steps, warmup.Step{Name: "storage", Run: warmer.Warm})
+	}
+	// the list goes through authorization, the store and the encoder like a real read, as a principal that may only
+	// read and sees no personal data
+	reader := &auth.Principal{Subject: "warm-up", Kind: "internal", Scopes: []string{string(auth.ReadStudents)}}
+	probes := []warmup.Probe{
+		{Method: http.MethodPost, Path: "/api/v1/auth/login", Body: "{}"}, // fails validation so nothing happens, but warms decoder and validator
+		{Method: http.MethodGet, Path: "/api/v1/students?limit=1", Principal: reader},
+	}
+	if err := warmup.Run(ctx, a.handler, steps, probes); err != nil {
+		slog.Warn("warm-up failed, going ready anyway", slog.String("error", err.Error()))
+	}
+}
+
+// Shutdown fails readiness, drains both listeners until ctx runs out and then runs the shutdown hooks.
+// safe to call more than once, only the first call does the work
+func (a *App) Shutdown(ctx context.Context) error {
+	a.shutdownOnce.Do(func() {
+		a.shutdownErr = a.shutdown(ctx)
+	})
+	return a.shutdownErr
+}
+
+// shut down server gracefully -> mean if server shut down in production so the ongoing requests will not intruppted first those requests will complete then the server will shut down
+func (a *App) shutdown(ctx context.Context) error {
+	started := time.Now()
+	slog.Info("shutting down the server...", slog.Int64("in_flight", a.inFlight.Count()))
+
+	// background jobs take no new work from here and finish what they run while the listeners drain, they share the
+	// deadline and are told to checkpoint a little before it. the Drain hook waits for what is still running after that
+	a.jobs.Stop(ctx)
+
+	// fail readiness first so the load balancer stops sending new work, then give it some time to notice
+	a.readiness.SetReady(false)
+	if delay := a.cfg.Shutdown.ReadinessDelay; delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+	}
+
+	// report what is still running while we wait
+	drained := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-drained:
+				return
+			case <-ticker.C:
+				slog.Info("draining", slog.Int64("in_flight", a.inFlight.Count()), slog.Int("jobs_running", a.jobs.Running()))
+			}
+		}
+	}()
+
+	// both listeners drain at the same time and share the same deadline
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for name, srv := range map[string]*http.Server{"api": a.server, "admin": a.adminServer} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := srv.Shutdown(ctx) // shutdown the server graceffully but somethime its take time somethime it may hang here so that we used the timer if server not shutdown in this time report us
+			if err != nil {
+				slog.Error("failed to shut down server", slog.String("server", name), slog.String("error:", err.Error()))
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	if a.http3Server != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := a.http3Server.Shutdown(ctx); err != nil {
+				slog.Error("failed to shut down server", slog.String("server", "http3"), slog.String("error:", err.Error()))
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	if a.grpcServer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stopped := make(chan struct{})
+			go func() {
+				a.grpcServer.GracefulStop() // waits for running calls, like http Shutdown
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				a.grpcServer.Stop() // cuts off what is still running, GracefulStop returns right after
+				slog.Error("failed to shut down server", slog.String("server", "grpc"), slog.String("error:", ctx.Err().Error()))
+				mu.Lock()
+				errs = append(errs, ctx.Err())
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	// Shutdown does not wait for hijacked connections, the websocket handlers were told to stop by the hub
+	// and get what is left of the drain time to send their close frame
+	for a.inFlight.Count() > 0 && ctx.Err() == nil {
+		select {
+		case <-time.After(10 * time.Millisecond):
+		case <-ctx.Done():
+		}
+	}
+	close(drained)
+	if n := a.inFlight.Count(); n > 0 {
+		slog.Warn("drain timeout reached, requests were cut off", slog.Int64("in_flight", n))
+	}
+
+	if err := a.hooks.Run(ctx); err != nil {
+		slog.Error("shutdown hooks failed", slog.String("error", err.Error()))
+		errs = append(errs, err)
+	}
+	slog.Info("shutdown finished", slog.Duration("took", time.Since(started)), slog.Int("errors", len(errs)))
+	return errors.Join(errs...)
+}
@@ -0,0 +1,142 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+// fakeKeys is an in-memory storage.APIKeyStore that counts last-used writes
+type fakeKeys struct {
+	keys    map[string]types.APIKey
+	touches int
+}
+
+func (f *fakeKeys) CreateAPIKey(ctx context.Context, key types.APIKey) (int64, error) {
+	key.Id = int64(len(f.keys) + 1)
+	f.keys[key.Hash] = key
+	return key.Id, nil
+}
+
+func (f *fakeKeys) ListAPIKeys(ctx context.Context) ([]types.APIKey, error) { return nil, nil }
+
+func (f *fakeKeys) APIKeyByHash(ctx context.Context, hash string) (types.APIKey, error) {
+	key, ok := f.keys[hash]
+	if !ok {
+		return types.APIKey{}, storage.ErrNotFound
+	}
+	return key, nil
+}
+
+func (f *fakeKeys) RevokeAPIKey(ctx context.Context, id int64, at time.Time) error {
+	for hash, key := range f.keys {
+		if key.Id == id {
+			key.RevokedAt = &at
+			f.keys[hash] = key
+			return nil
+		}
+	}
+	return storage.ErrNotFound
+}
+
+func (f *fakeKeys) TouchAPIKey(ctx context.Context, id int64, at time.Time) error {
+	f.touches++
+	for hash, key := range f.keys {
+		if key.Id == id {
+			key.LastUsedAt = &at
+			f.keys[hash] = key
+		}
+	}
+	return nil
+}
+
+func TestAPIKeys(t *testing.T) {
+	t.Parallel()
+
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := &fakeKeys{keys: map[string]types.APIKey{}}
+	newKey := func(name string) string {
+		key, prefix, hash, err := auth.GenerateAPIKey()
+		if err != nil {
+			t.Fatalf("GenerateAPIKey: %v", err)
+		}
+		if !strings.HasPrefix(key, prefix) || hash == key {
+			t.Fatalf("prefix %q or hash %q do not fit key %q", prefix, hash, key)
+		}
+		store.CreateAPIKey(context.Background(), types.APIKey{Name: name, Prefix: prefix, Hash: hash, Scopes: []string{"students:read"}})
+		return key
+	}
+	active := newKey("active")
+	revoked := newKey("revoked")
+	store.RevokeAPIKey(context.Background(), 2, clk.Now())
+	keys := auth.NewAPIKeys(store, clk)
+
+	type testCase struct {
+		name    string
+		key     string
+		wantErr error
+	}
+
+	tests := []testCase{
+		{name: "valid_key", key: active},
+		{name: "no_header", wantErr: auth.ErrNoCredentials},
+		{name: "unknown_key", key: "gsk_nope", wantErr: auth.ErrInvalidCredentials},
+		{name: "revoked_key", key: revoked, wantErr: auth.ErrInvalidCredentials},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			// not parallel, the fake store is not safe for concurrent use
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.key != "" {
+				req.Header.Set(auth.APIKeyHeader, tc.key)
+			}
+			p, err := keys.Authenticate(req)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want error %v, got %v", tc.wantErr, err)
+			}
+			if tc.wantErr == nil && (p.Kind != "api_key" || len(p.Scopes) != 1 || p.Scopes[0] != "students:read") {
+				t.Fatalf("unexpected principal %+v", p)
+			}
+		})
+	}
+}
+
+func TestAPIKeysTouchLastUsedOncePerMinute(t *testing.T) {
+	t.Parallel()
+
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := &fakeKeys{keys: map[string]types.APIKey{}}
+	key, prefix, hash, _ := auth.GenerateAPIKey()
+	store.CreateAPIKey(context.Background(), types.APIKey{Name: "sync", Prefix: prefix, Hash: hash})
+	keys := auth.NewAPIKeys(store, clk)
+
+	use := func() {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(auth.APIKeyHeader, key)
+		if _, err := keys.Authenticate(req); err != nil {
+			t.Fatalf("Authenticate: %v", err)
+		}
+	}
+	use()
+	clk.Advance(30 * time.Second)
+	use()
+	if store.touches != 1 {
+		t.Fatalf("want 1 last-used write within a minute, got %d", store.touches)
+	}
+	clk.Advance(time.Minute)
+	use()
+	if store.touches != 2 {
+		t.Fatalf("want 2 last-used writes after a minute, got %d", store.touches)
+	}
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+var (
+	// ErrNoCredentials means the request did not carry the kind of credentials this authenticator looks for,
+	// the next authenticator in a Chain gets a turn
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials means credentials were there but wrong or expired, the request is rejected
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal is who is making the request, handlers read it from the context and never care how it was authenticated
+type Principal struct {
+	Subject string   `json:"subject"` // user id, api key id...
+	Kind    string   `json:"kind"`    // "user", "api_key", "admin"
+	Roles   []string `json:"roles,omitempty"`
+	Scopes  []string `json:"scopes,omitempty"`
+	// StudentID links a principal with the student role to its own student record, 0 for everyone else
+	StudentID int64 `json:"student_id,omitempty"`
+}
+
+// Authenticator looks at a request and says who sent it.
+// jwt, api key and basic auth all implement this, so they can be swapped or stacked with Chain
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// AuthenticatorFunc lets a plain function be an Authenticator
+type AuthenticatorFunc func(r *http.Request) (*Principal, error)
+
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (*Principal, error) {
+	return f(r)
+}
+
+// Chain tries authenticators in order. the first one that finds its credentials decides,
+// when none of them finds anything the result is ErrNoCredentials (anonymous request)
+type Chain []Authenticator
+
+func (c Chain) Authenticate(r *http.Request) (*Principal, error) {
+	for _, a := range c {
+		p, err := a.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return p, err
+	}
+	return nil, ErrNoCredentials
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the authenticated caller, ok is false for anonymous requests
+func PrincipalFrom(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
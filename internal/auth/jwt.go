@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+)
+
+// ErrJWTDisabled is returned by NewJWT when neither a secret nor keys are configured
+var ErrJWTDisabled = errors.New("jwt: no secret or keys configured")
+
+type claims struct {
+	jwt.RegisteredClaims
+	Kind      string   `json:"kind,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	Scopes    []string `json:"scopes,omitempty"`
+	StudentID int64    `json:"student_id,omitempty"`
+}
+
+// JWT issues and checks signed access tokens. HS256 signs with a shared secret,
+// RS256 signs with a private key and only needs the public key to verify (other services can check our tokens)
+type JWT struct {
+	method    jwt.SigningMethod
+	signKey   any
+	verifyKey any
+	issuer    string
+	ttl       time.Duration
+	clock     clock.Clock
+}
+
+func NewJWT(cfg config.JWT, clk clock.Clock) (*JWT, error) {
+	if cfg.Secret == "" && cfg.PrivateKeyFile == "" {
+		return nil, ErrJWTDisabled
+	}
+	j := &JWT{issuer: cfg.Issuer, ttl: cfg.TTL, clock: clk}
+	switch strings.ToUpper(cfg.Algorithm) {
+	case "HS256", "":
+		if len(cfg.Secret) < 32 { // shorter secrets can be brute forced from a single token
+			return nil, errors.New("jwt: secret must be at least 32 bytes")
+		}
+		j.method = jwt.SigningMethodHS256
+		j.signKey, j.verifyKey = []byte(cfg.Secret), []byte(cfg.Secret)
+	case "RS256":
+		pemBytes, err := os.ReadFile(cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("jwt: %w", err)
+		}
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("jwt: %w", err)
+		}
+		j.method = jwt.SigningMethodRS256
+		j.signKey, j.verifyKey = key, &key.PublicKey
+	default:
+		return nil, fmt.Errorf("jwt: unsupported algorithm %q, use HS256 or RS256", cfg.Algorithm)
+	}
+	return j, nil
+}
+
+// Issue signs an access token for p, valid for the configured ttl
+func (j *JWT) Issue(p Principal) (token string, expires time.Time, err error) {
+	return j.IssueFor(p, j.ttl)
+}
+
+// IssueFor signs an access token for p that is valid for ttl, for tokens that should live shorter than a login
+func (j *JWT) IssueFor(p Principal, ttl time.Duration) (token string, expires time.Time, err error) {
+	now := j.clock.Now()
+	expires = now.Add(ttl)
+	c := claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    j.issuer,
+			Subject:   p.Subject,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expires),
+		},
+		Kind:      p.Kind,
+		Roles:     p.Roles,
+		Scopes:    p.Scopes,
+		StudentID: p.StudentID,
+	}
+	token, err = jwt.NewWithClaims(j.method, c).SignedString(j.signKey)
+	return token, expires, err
+}
+
+// TTL is how long issued tokens are valid
+func (j *JWT) TTL() time.Duration {
+	return j.ttl
+}
+
+// Authenticate reads "Authorization: Bearer <token>", so JWT can sit in a Chain with the other authenticators
+func (j *JWT) Authenticate(r *http.Request) (*Principal, error) {
+	header := r.Header.Get("Authorization")
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || token == "" {
+		return nil, ErrNoCredentials
+	}
+
+	var c claims
+	_, err := jwt.ParseWithClaims(token, &c, func(*jwt.Token) (any, error) { return j.verifyKey, nil },
+		jwt.WithValidMethods([]string{j.method.Alg()}), // never let the token pick its own algorithm ("none", hs256 with the public key...)
+		jwt.WithIssuer(j.issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(j.clock.Now),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+	kind := c.Kind
+	if kind == "" {
+		kind = "user"
+	}
+	return &Principal{Subject: c.Subject, Kind: kind, Roles: c.Roles, Scopes: c.Scopes, StudentID: c.StudentID}, nil
+}
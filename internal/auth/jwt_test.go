@@ -0,0 +1,89 @@
+package auth_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+)
+
+func TestJWT(t *testing.T) {
+	t.Parallel()
+
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	cfg := config.JWT{Algorithm: "HS256", Secret: strings.Repeat("k", 32), Issuer: "go-server", TTL: 15 * time.Minute}
+	tokens, err := auth.NewJWT(cfg, clk)
+	if err != nil {
+		t.Fatalf("NewJWT: %v", err)
+	}
+	token, _, err := tokens.Issue(auth.Principal{Subject: "teacher", Kind: "user", Roles: []string{"teacher"}})
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	otherCfg := cfg
+	otherCfg.Secret = strings.Repeat("x", 32)
+	other, _ := auth.NewJWT(otherCfg, clk)
+	forged, _, _ := other.Issue(auth.Principal{Subject: "teacher"})
+	short, _, err := tokens.IssueFor(auth.Principal{Subject: auth.RoleAdmin, Kind: "admin", Roles: []string{auth.RoleAdmin}}, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("IssueFor: %v", err)
+	}
+
+	type testCase struct {
+		name    string
+		header  string
+		advance time.Duration
+		wantErr error
+		wantSub string // subject and only role of the principal, teacher when empty
+	}
+
+	tests := []testCase{
+		{name: "valid_token", header: "Bearer " + token},
+		{name: "no_header", wantErr: auth.ErrNoCredentials},
+		{name: "other_scheme", header: "Basic dXNlcjpwYXNz", wantErr: auth.ErrNoCredentials},
+		{name: "wrong_secret", header: "Bearer " + forged, wantErr: auth.ErrInvalidCredentials},
+		{name: "garbage", header: "Bearer not.a.token", wantErr: auth.ErrInvalidCredentials},
+		{name: "expired", header: "Bearer " + token, advance: 16 * time.Minute, wantErr: auth.ErrInvalidCredentials},
+		{name: "short_token_valid", header: "Bearer " + short, advance: 4 * time.Minute, wantSub: auth.RoleAdmin},
+		{name: "short_token_expired", header: "Bearer " + short, advance: 6 * time.Minute, wantErr: auth.ErrInvalidCredentials},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			// not parallel, expired moves the shared clock
+			clk.Set(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Add(tc.advance))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			p, err := tokens.Authenticate(req)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want error %v, got %v", tc.wantErr, err)
+			}
+			wantSub := tc.wantSub
+			if wantSub == "" {
+				wantSub = "teacher"
+			}
+			if tc.wantErr == nil && (p.Subject != wantSub || len(p.Roles) != 1 || p.Roles[0] != wantSub) {
+				t.Fatalf("unexpected principal %+v", p)
+			}
+		})
+	}
+}
+
+func TestNewJWTDisabledWithoutSecret(t *testing.T) {
+	t.Parallel()
+
+	if _, err := auth.NewJWT(config.JWT{Algorithm: "HS256"}, clock.System{}); !errors.Is(err, auth.ErrJWTDisabled) {
+		t.Fatalf("want ErrJWTDisabled, got %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"golang.org/x/oauth2"
+)
+
+// OIDC signs people in with an outside identity provider (authorization code flow with pkce).
+// the id token of the provider is only checked once, after that the user gets our own jwt like any other login
+type OIDC struct {
+	name string
+	cfg  config.OIDCProvider
+
+	// discovery needs the provider to be reachable, it happens on the first login instead of at startup
+	mu       sync.Mutex
+	oauth    *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+func NewOIDC(name string, cfg config.OIDCProvider) *OIDC {
+	return &OIDC{name: name, cfg: cfg}
+}
+
+func (o *OIDC) discover(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.oauth != nil {
+		return o.oauth, o.verifier, nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, o.cfg.Issuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("oidc %s: discovery: %w", o.name, err)
+	}
+	o.oauth = &oauth2.Config{
+		ClientID:     o.cfg.ClientID,
+		ClientSecret: o.cfg.ClientSecret,
+		RedirectURL:  o.cfg.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       append([]string{oidc.ScopeOpenID, "email", "profile"}, o.cfg.Scopes...),
+	}
+	o.verifier = provider.Verifier(&oidc.Config{ClientID: o.cfg.ClientID})
+	return o.oauth, o.verifier, nil
+}
+
+// AuthURL is where the browser is sent to sign in. state, nonce and the pkce verifier must be kept by the caller for Exchange
+func (o *OIDC) AuthURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	oauth, _, err := o.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return oauth.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// Exchange trades the code from the callback for the provider's tokens, checks the id token and returns the principal
+func (o *OIDC) Exchange(ctx context.Context, code, nonce, verifier string) (*Principal, error) {
+	oauth, idVerifier, err := o.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	token, err := oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("%w: oidc %s: code exchange: %w", ErrInvalidCredentials, o.name, err)
+	}
+	raw, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: oidc %s: no id_token in token response", ErrInvalidCredentials, o.name)
+	}
+	idToken, err := idVerifier.Verify(ctx, raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: oidc %s: %w", ErrInvalidCredentials, o.name, err)
+	}
+	if idToken.Nonce != nonce { // a replayed id token from another login
+		return nil, fmt.Errorf("%w: oidc %s: nonce mismatch", ErrInvalidCredentials, o.name)
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("oidc %s: claims: %w", o.name, err)
+	}
+	return &Principal{
+		Subject: o.name + ":" + idToken.Subject, // sub is only unique per provider
+		Kind:    "user",
+		Roles:   MapGroups(o.cfg.RoleMapping, stringList(claims[o.groupsClaim()])),
+	}, nil
+}
+
+func (o *OIDC) groupsClaim() string {
+	if o.cfg.GroupsClaim == "" {
+		return "groups"
+	}
+	return o.cfg.GroupsClaim
+}
+
+// MapGroups turns provider groups into roles with the configured mapping, unmapped groups are ignored
+func MapGroups(mapping map[string]string, groups []string) []string {
+	var roles []string
+	for _, group := range groups {
+		if role, ok := mapping[group]; ok && !slices.Contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// stringList reads a claim that is a json array of strings, anything else is empty
+func stringList(v any) []string {
+	list, _ := v.([]any)
+	out := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
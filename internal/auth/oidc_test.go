@@ -0,0 +1,38 @@
+package auth_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+)
+
+func TestMapGroups(t *testing.T) {
+	t.Parallel()
+
+	mapping := map[string]string{"staff": auth.RoleTeacher, "it-admins": auth.RoleAdmin, "faculty": auth.RoleTeacher}
+
+	type testCase struct {
+		name   string
+		groups []string
+		want   []string
+	}
+
+	tests := []testCase{
+		{name: "no_groups"},
+		{name: "unmapped_groups_ignored", groups: []string{"alumni", "staff"}, want: []string{auth.RoleTeacher}},
+		{name: "several_roles", groups: []string{"it-admins", "staff"}, want: []string{auth.RoleAdmin, auth.RoleTeacher}},
+		{name: "duplicate_role_once", groups: []string{"staff", "faculty"}, want: []string{auth.RoleTeacher}},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := auth.MapGroups(mapping, tc.groups); !slices.Equal(got, tc.want) {
+				t.Fatalf("want %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
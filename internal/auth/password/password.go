@@ -0,0 +1,130 @@
+// Package password hashes and checks user passwords. new hashes are argon2id, bcrypt hashes (config users,
+// accounts from before argon2id) are still accepted and Verify asks for a rehash so they move over on the next login
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrWeakPassword wraps every reason Policy gives for refusing a password, the message is safe to show to the user
+var ErrWeakPassword = errors.New("password is too weak")
+
+const (
+	saltLength = 16
+	keyLength  = 32
+)
+
+// Params is the argon2id cost. raising any of them makes existing hashes "outdated" and they are rehashed on login
+type Params struct {
+	MemoryKiB   uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// defaults are the owasp recommendation for argon2id at the time of writing
+var defaultParams = Params{MemoryKiB: 64 * 1024, Iterations: 3, Parallelism: 2}
+
+// Hasher makes and checks password hashes with one set of params
+type Hasher struct {
+	params Params
+	policy Policy
+
+	dummyOnce sync.Once
+	dummy     string
+}
+
+// New uses the params from the config, zero values take the defaults
+func New(cfg config.Password) *Hasher {
+	p := Params{MemoryKiB: cfg.MemoryKiB, Iterations: cfg.Iterations, Parallelism: cfg.Parallelism}
+	if p.MemoryKiB == 0 {
+		p.MemoryKiB = defaultParams.MemoryKiB
+	}
+	if p.Iterations == 0 {
+		p.Iterations = defaultParams.Iterations
+	}
+	if p.Parallelism == 0 {
+		p.Parallelism = defaultParams.Parallelism
+	}
+	return &Hasher{params: p, policy: Policy{MinLength: cfg.MinLength}}
+}
+
+// Hash returns password in the PHC string format -> $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+func (h *Hasher) Hash(password string) (string, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.MemoryKiB, h.params.Parallelism, keyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version,
+		h.params.MemoryKiB, h.params.Iterations, h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify checks password against an argon2id or bcrypt hash. rehash is true when the password is right
+// but the hash was made with other params or with bcrypt, the caller should store a fresh Hash then
+func (h *Hasher) Verify(password, encoded string) (ok, rehash bool, err error) {
+	if strings.HasPrefix(encoded, "$2") { // $2a$, $2b$, $2y$ are bcrypt
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		return err == nil, err == nil, err
+	}
+
+	params, salt, key, err := decode(encoded)
+	if err != nil {
+		return false, false, err
+	}
+	got := argon2.IDKey([]byte(password), salt, params.Iterations, params.MemoryKiB, params.Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(got, key) != 1 {
+		return false, false, nil
+	}
+	return true, params != h.params, nil
+}
+
+// VerifyDummy takes as long as Verify but checks against nothing, call it for unknown users
+// so a login can not tell from the response time if the username exists
+func (h *Hasher) VerifyDummy(password string) {
+	h.dummyOnce.Do(func() { h.dummy, _ = h.Hash("not a real password") })
+	h.Verify(password, h.dummy)
+}
+
+// Check runs the password policy, see Policy
+func (h *Hasher) Check(password, username string) error {
+	return h.policy.Check(password, username)
+}
+
+func decode(encoded string) (Params, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, hash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return Params{}, nil, nil, errors.New("password: unknown hash format")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Params{}, nil, nil, fmt.Errorf("password: unsupported argon2 version %q", parts[2])
+	}
+	var p Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.MemoryKiB, &p.Iterations, &p.Parallelism); err != nil {
+		return Params{}, nil, nil, fmt.Errorf("password: bad argon2 params: %w", err)
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Params{}, nil, nil, fmt.Errorf("password: bad salt: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return Params{}, nil, nil, fmt.Errorf("password: bad hash: %w", err)
+	}
+	return p, salt, key, nil
+}
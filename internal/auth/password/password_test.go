@@ -0,0 +1,96 @@
+package password_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/auth/password"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// cheap params so the tests run fast, the format is the same as with the real cost
+var cheap = config.Password{MemoryKiB: 1024, Iterations: 1, Parallelism: 1}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	hasher := password.New(cheap)
+	current, err := hasher.Hash("correct-horse")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	older, _ := password.New(config.Password{MemoryKiB: 512, Iterations: 1, Parallelism: 1}).Hash("correct-horse")
+	legacy, _ := bcrypt.GenerateFromPassword([]byte("correct-horse"), bcrypt.MinCost)
+
+	type testCase struct {
+		name       string
+		password   string
+		hash       string
+		wantOk     bool
+		wantRehash bool
+	}
+
+	tests := []testCase{
+		{name: "current_params", password: "correct-horse", hash: current, wantOk: true},
+		{name: "wrong_password", password: "wrong-horse", hash: current},
+		{name: "older_params_rehash", password: "correct-horse", hash: older, wantOk: true, wantRehash: true},
+		{name: "bcrypt_rehash", password: "correct-horse", hash: string(legacy), wantOk: true, wantRehash: true},
+		{name: "bcrypt_wrong_password", password: "wrong-horse", hash: string(legacy)},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ok, rehash, err := hasher.Verify(tc.password, tc.hash)
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if ok != tc.wantOk || rehash != tc.wantRehash {
+				t.Fatalf("want ok=%v rehash=%v, got ok=%v rehash=%v", tc.wantOk, tc.wantRehash, ok, rehash)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsGarbage(t *testing.T) {
+	t.Parallel()
+
+	if _, _, err := password.New(cheap).Verify("x", "$argon2id$nope"); err == nil {
+		t.Fatal("want error for a malformed hash")
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name     string
+		password string
+		wantWeak bool
+	}
+
+	tests := []testCase{
+		{name: "long_enough", password: "correct-horse-battery"},
+		{name: "too_short", password: "short", wantWeak: true},
+		{name: "too_long", password: strings.Repeat("ab", 65), wantWeak: true},
+		{name: "contains_username", password: "my-name-is-Asha!", wantWeak: true},
+		{name: "common", password: "Password123", wantWeak: true},
+		{name: "repeated_character", password: "aaaaaaaaaaaa", wantWeak: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := password.Policy{}.Check(tc.password, "asha")
+			if errors.Is(err, password.ErrWeakPassword) != tc.wantWeak {
+				t.Fatalf("want weak=%v, got %v", tc.wantWeak, err)
+			}
+		})
+	}
+}
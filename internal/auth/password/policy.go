@@ -0,0 +1,44 @@
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Policy is what a new password must look like. it follows nist 800-63b -> length and a deny list,
+// no rules about digits and symbols, those only make people write "Password1!"
+type Policy struct {
+	MinLength int // 10 when zero
+}
+
+const maxLength = 128 // argon2 takes any length, this only stops megabyte "passwords" eating cpu
+
+// most common leaked passwords that pass the length check, lowercased
+var common = map[string]bool{
+	"1234567890": true, "0123456789": true, "qwertyuiop": true, "password12": true, "password123": true,
+	"iloveyou12": true, "1q2w3e4r5t": true, "qwerty1234": true, "letmein123": true, "welcome123": true,
+	"passw0rd123": true, "abc1234567": true, "admin12345": true, "password1234": true,
+}
+
+func (p Policy) Check(password, username string) error {
+	minLength := p.MinLength
+	if minLength == 0 {
+		minLength = 10
+	}
+	n := utf8.RuneCountInString(password)
+	lower := strings.ToLower(password)
+	switch {
+	case n < minLength:
+		return fmt.Errorf("%w: use at least %d characters", ErrWeakPassword, minLength)
+	case n > maxLength:
+		return fmt.Errorf("%w: use at most %d characters", ErrWeakPassword, maxLength)
+	case username != "" && strings.Contains(lower, strings.ToLower(username)):
+		return fmt.Errorf("%w: must not contain the username", ErrWeakPassword)
+	case common[lower]:
+		return fmt.Errorf("%w: this password is too common", ErrWeakPassword)
+	case strings.Count(password, password[:1]) == len(password):
+		return fmt.Errorf("%w: must not be one repeated character", ErrWeakPassword)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/manishtomar-cpi/go-server/internal/redact"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+// ErrUnknownScope is returned for scopes that do not exist or that the principal can not have
+var ErrUnknownScope = errors.New("invalid scope")
+
+// roles a principal can have, from the config users or the jwt
+const (
+	RoleAdmin   = "admin"
+	RoleTeacher = "teacher"
+	RoleStudent = "student"
+)
+
+// Permission is one thing a principal may do. api key scopes use the same names, so a key with the
+// "students:read" scope can do what the permission allows
+type Permission string
+
+const (
+	ReadStudents   Permission = "students:read"
+	ReadOwnStudent Permission = "students:read:own" // only the record in Principal.StudentID
+	WriteStudents  Permission = "students:write"
+	DeleteStudents Permission = "students:delete"
+	ReadStudentPII Permission = "students:pii" // see emails unmasked, students always see their own record in full
+)
+
+// Permissions is every permission there is, scopes of api keys and tokens must be one of these
+var Permissions = []Permission{ReadStudents, ReadOwnStudent, WriteStudents, DeleteStudents, ReadStudentPII}
+
+// rolePermissions is the whole policy, who may do what is changed here and nowhere else
+var rolePermissions = map[string][]Permission{
+	RoleAdmin:   {ReadStudents, WriteStudents, DeleteStudents, ReadStudentPII},
+	RoleTeacher: {ReadStudents, WriteStudents, ReadStudentPII},
+	RoleStudent: {ReadOwnStudent},
+}
+
+// Can says if the principal may do perm. scopes only ever take away -> a principal with roles and scopes needs both
+// to allow perm, one without roles (an api key) may do exactly what its scopes say
+func (p *Principal) Can(perm Permission) bool {
+	if p == nil {
+		return false
+	}
+	if len(p.Scopes) > 0 && !slices.Contains(p.Scopes, string(perm)) {
+		return false
+	}
+	if len(p.Roles) == 0 {
+		return len(p.Scopes) > 0
+	}
+	for _, role := range p.Roles {
+		if slices.Contains(rolePermissions[role], perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactStudent returns student as p may see it -> personal fields are masked unless p has students:pii or it is p's
+// own record. every response and live event with a student goes through it, http, grpc and websocket/sse alike
+func RedactStudent(p *Principal, student types.Student) types.Student {
+	if p.Can(ReadStudentPII) || (p != nil && p.StudentID != 0 && p.StudentID == student.Id) {
+		return student
+	}
+	return redact.Student(student)
+}
+
+// CheckScopes returns an error naming the first scope that is not a known permission
+func CheckScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if !slices.Contains(Permissions, Permission(scope)) {
+			return fmt.Errorf("%w: %q", ErrUnknownScope, scope)
+		}
+	}
+	return nil
+}
+
+// Narrow returns a copy of p limited to scopes, for a token that should do less than its owner can.
+// asking for a scope p does not have is an error, a token can never do more than its owner
+func (p *Principal) Narrow(scopes []string) (*Principal, error) {
+	if err := CheckScopes(scopes); err != nil {
+		return nil, err
+	}
+	for _, scope := range scopes {
+		if !p.Can(Permission(scope)) {
+			return nil, fmt.Errorf("%w: %q is not allowed for %s", ErrUnknownScope, scope, p.Subject)
+		}
+	}
+	narrowed := *p
+	narrowed.Scopes = slices.Clone(scopes)
+	return &narrowed, nil
+}
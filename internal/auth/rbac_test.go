@@ -0,0 +1,111 @@
+package auth_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+func TestPrincipalCan(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name      string
+		principal *auth.Principal
+		perm      auth.Permission
+		want      bool
+	}
+
+	tests := []testCase{
+		{name: "admin_deletes", principal: &auth.Principal{Roles: []string{auth.RoleAdmin}}, perm: auth.DeleteStudents, want: true},
+		{name: "teacher_writes", principal: &auth.Principal{Roles: []string{auth.RoleTeacher}}, perm: auth.WriteStudents, want: true},
+		{name: "teacher_can_not_delete", principal: &auth.Principal{Roles: []string{auth.RoleTeacher}}, perm: auth.DeleteStudents},
+		{name: "student_reads_own", principal: &auth.Principal{Roles: []string{auth.RoleStudent}}, perm: auth.ReadOwnStudent, want: true},
+		{name: "student_can_not_read_all", principal: &auth.Principal{Roles: []string{auth.RoleStudent}}, perm: auth.ReadStudents},
+		{name: "unknown_role", principal: &auth.Principal{Roles: []string{"janitor"}}, perm: auth.ReadStudents},
+		{name: "api_key_scope", principal: &auth.Principal{Kind: "api_key", Scopes: []string{"students:read"}}, perm: auth.ReadStudents, want: true},
+		{name: "nil_principal", perm: auth.ReadStudents},
+		{name: "api_key_without_scopes", principal: &auth.Principal{Kind: "api_key"}, perm: auth.ReadStudents},
+		{name: "scoped_teacher_reads", principal: &auth.Principal{Roles: []string{auth.RoleTeacher}, Scopes: []string{"students:read"}}, perm: auth.ReadStudents, want: true},
+		{name: "scoped_teacher_can_not_write", principal: &auth.Principal{Roles: []string{auth.RoleTeacher}, Scopes: []string{"students:read"}}, perm: auth.WriteStudents},
+		{name: "scope_does_not_add_to_role", principal: &auth.Principal{Roles: []string{auth.RoleStudent}, Scopes: []string{"students:write"}}, perm: auth.WriteStudents},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tc.principal.Can(tc.perm); got != tc.want {
+				t.Fatalf("Can(%s): want %v, got %v", tc.perm, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestPrincipalNarrow(t *testing.T) {
+	t.Parallel()
+
+	teacher := &auth.Principal{Subject: "teacher", Roles: []string{auth.RoleTeacher}}
+
+	type testCase struct {
+		name    string
+		scopes  []string
+		wantErr error
+	}
+
+	tests := []testCase{
+		{name: "subset", scopes: []string{"students:read"}},
+		{name: "unknown_scope", scopes: []string{"courses:write"}, wantErr: auth.ErrUnknownScope},
+		{name: "more_than_role", scopes: []string{"students:delete"}, wantErr: auth.ErrUnknownScope},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			narrowed, err := teacher.Narrow(tc.scopes)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want error %v, got %v", tc.wantErr, err)
+			}
+			if err == nil && (narrowed.Can(auth.WriteStudents) || len(teacher.Scopes) != 0) {
+				t.Fatalf("narrowed token can still write or the original changed: %+v %+v", narrowed, teacher)
+			}
+		})
+	}
+}
+
+func TestRedactStudent(t *testing.T) {
+	t.Parallel()
+
+	student := types.Student{Id: 7, Name: "Asha", Email: "asha@example.com", Age: 21}
+
+	type testCase struct {
+		name      string
+		principal *auth.Principal
+		wantEmail string
+	}
+
+	tests := []testCase{
+		{name: "teacher_sees_pii", principal: &auth.Principal{Roles: []string{auth.RoleTeacher}}, wantEmail: "asha@example.com"},
+		{name: "own_record", principal: &auth.Principal{Roles: []string{auth.RoleStudent}, StudentID: 7}, wantEmail: "asha@example.com"},
+		{name: "other_student", principal: &auth.Principal{Roles: []string{auth.RoleStudent}, StudentID: 8}, wantEmail: "a***@example.com"},
+		{name: "read_only_key", principal: &auth.Principal{Kind: "api_key", Scopes: []string{"students:read"}}, wantEmail: "a***@example.com"},
+		{name: "nil_principal", wantEmail: "a***@example.com"},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := auth.RedactStudent(tc.principal, student)
+			if got.Email != tc.wantEmail || got.Id != student.Id || got.Name != student.Name {
+				t.Fatalf("want %s, got %+v", tc.wantEmail, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/logging"
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+// ErrRefreshReuse means an already rotated refresh token came back. either the client has a bug or the token was stolen,
+// we can not tell which one is the thief so the whole family is revoked and the user has to log in again
+var ErrRefreshReuse = fmt.Errorf("%w: refresh token reused", ErrInvalidCredentials)
+
+// RefreshTokens issues long-lived opaque tokens that are traded for a new access token. every refresh token works once,
+// using it gives a new one in the same family
+type RefreshTokens struct {
+	store storage.RefreshTokenStore
+	clock clock.Clock
+	ttl   time.Duration
+}
+
+func NewRefreshTokens(store storage.RefreshTokenStore, clk clock.Clock, ttl time.Duration) *RefreshTokens {
+	return &RefreshTokens{store: store, clock: clk, ttl: ttl}
+}
+
+// Issue starts a new family for p, called on login
+func (rt *RefreshTokens) Issue(ctx context.Context, p Principal) (string, error) {
+	family, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	return rt.issue(ctx, family, p)
+}
+
+func (rt *RefreshTokens) issue(ctx context.Context, family string, p Principal) (string, error) {
+	token, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	now := rt.clock.Now()
+	err = rt.store.CreateRefreshToken(ctx, types.RefreshToken{
+		Hash:      HashAPIKey(token), // same reasoning as api keys, 256 random bits need no slow hash
+		Family:    family,
+		Subject:   p.Subject,
+		Kind:      p.Kind,
+		Roles:     p.Roles,
+		Scopes:    p.Scopes,
+		StudentID: p.StudentID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(rt.ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Rotate uses up token and returns who it belonged to plus the refresh token that replaces it
+func (rt *RefreshTokens) Rotate(ctx context.Context, token string) (*Principal, string, error) {
+	stored, err := rt.lookup(ctx, token)
+	if err != nil {
+		return nil, "", err
+	}
+	now := rt.clock.Now()
+	if stored.UsedAt != nil {
+		return nil, "", rt.reused(ctx, stored)
+	}
+	won, err := rt.store.MarkRefreshTokenUsed(ctx, stored.Id, now)
+	if err != nil {
+		return nil, "", err
+	}
+	if !won { // two requests raced with the same token, only one may get a new one
+		return nil, "", rt.reused(ctx, stored)
+	}
+
+	p := &Principal{Subject: stored.Subject, Kind: stored.Kind, Roles: stored.Roles, Scopes: stored.Scopes, StudentID: stored.StudentID}
+	next, err := rt.issue(ctx, stored.Family, *p)
+	if err != nil {
+		return nil, "", err
+	}
+	return p, next, nil
+}
+
+// Revoke ends the session token belongs to, used by logout. unknown tokens are fine, the session is gone either way
+func (rt *RefreshTokens) Revoke(ctx context.Context, token string) error {
+	stored, err := rt.lookup(ctx, token)
+	if errors.Is(err, ErrInvalidCredentials) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return rt.store.RevokeRefreshFamily(ctx, stored.Family, rt.clock.Now())
+}
+
+// lookup finds a token that can still be used, anything else is ErrInvalidCredentials
+func (rt *RefreshTokens) lookup(ctx context.Context, token string) (types.RefreshToken, error) {
+	stored, err := rt.store.RefreshTokenByHash(ctx, HashAPIKey(token))
+	if errors.Is(err, storage.ErrNotFound) {
+		return types.RefreshToken{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return types.RefreshToken{}, fmt.Errorf("refresh token lookup: %w", err)
+	}
+	if stored.RevokedAt != nil || !rt.clock.Now().Before(stored.ExpiresAt) {
+		return types.RefreshToken{}, ErrInvalidCredentials
+	}
+	return stored, nil
+}
+
+func (rt *RefreshTokens) reused(ctx context.Context, stored types.RefreshToken) error {
+	logging.FromContext(ctx).WarnContext(ctx, "refresh token reused, revoking the session",
+		slog.String("subject", stored.Subject), slog.String("family", stored.Family))
+	if err := rt.store.RevokeRefreshFamily(ctx, stored.Family, rt.clock.Now()); err != nil {
+		return fmt.Errorf("revoke refresh family: %w", err)
+	}
+	return ErrRefreshReuse
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,116 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+// fakeRefresh is an in-memory storage.RefreshTokenStore
+type fakeRefresh struct {
+	tokens map[string]*types.RefreshToken
+}
+
+func (f *fakeRefresh) CreateRefreshToken(ctx context.Context, token types.RefreshToken) error {
+	token.Id = int64(len(f.tokens) + 1)
+	f.tokens[token.Hash] = &token
+	return nil
+}
+
+func (f *fakeRefresh) RefreshTokenByHash(ctx context.Context, hash string) (types.RefreshToken, error) {
+	token, ok := f.tokens[hash]
+	if !ok {
+		return types.RefreshToken{}, storage.ErrNotFound
+	}
+	return *token, nil
+}
+
+func (f *fakeRefresh) MarkRefreshTokenUsed(ctx context.Context, id int64, at time.Time) (bool, error) {
+	for _, token := range f.tokens {
+		if token.Id == id && token.UsedAt == nil {
+			token.UsedAt = &at
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeRefresh) RevokeRefreshFamily(ctx context.Context, family string, at time.Time) error {
+	for _, token := range f.tokens {
+		if token.Family == family {
+			token.RevokedAt = &at
+		}
+	}
+	return nil
+}
+
+func TestRefreshTokens(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	user := auth.Principal{Subject: "teacher", Kind: "user", Roles: []string{"teacher"}}
+
+	type testCase struct {
+		name string
+		run  func(t *testing.T, rt *auth.RefreshTokens, clk *clock.Fake)
+	}
+
+	tests := []testCase{
+		{name: "rotate", run: func(t *testing.T, rt *auth.RefreshTokens, clk *clock.Fake) {
+			first, _ := rt.Issue(ctx, user)
+			p, second, err := rt.Rotate(ctx, first)
+			if err != nil || p.Subject != "teacher" || second == first {
+				t.Fatalf("want new token for teacher, got %+v %q %v", p, second, err)
+			}
+			if _, _, err := rt.Rotate(ctx, second); err != nil {
+				t.Fatalf("rotated token should work once: %v", err)
+			}
+		}},
+		{name: "reuse_revokes_family", run: func(t *testing.T, rt *auth.RefreshTokens, clk *clock.Fake) {
+			first, _ := rt.Issue(ctx, user)
+			_, second, _ := rt.Rotate(ctx, first)
+			if _, _, err := rt.Rotate(ctx, first); !errors.Is(err, auth.ErrRefreshReuse) {
+				t.Fatalf("want ErrRefreshReuse, got %v", err)
+			}
+			if _, _, err := rt.Rotate(ctx, second); !errors.Is(err, auth.ErrInvalidCredentials) {
+				t.Fatalf("newest token of a reused family should be revoked, got %v", err)
+			}
+		}},
+		{name: "expired", run: func(t *testing.T, rt *auth.RefreshTokens, clk *clock.Fake) {
+			token, _ := rt.Issue(ctx, user)
+			clk.Advance(time.Hour)
+			if _, _, err := rt.Rotate(ctx, token); !errors.Is(err, auth.ErrInvalidCredentials) {
+				t.Fatalf("want ErrInvalidCredentials, got %v", err)
+			}
+		}},
+		{name: "logout", run: func(t *testing.T, rt *auth.RefreshTokens, clk *clock.Fake) {
+			token, _ := rt.Issue(ctx, user)
+			if err := rt.Revoke(ctx, token); err != nil {
+				t.Fatalf("Revoke: %v", err)
+			}
+			if _, _, err := rt.Rotate(ctx, token); !errors.Is(err, auth.ErrInvalidCredentials) {
+				t.Fatalf("want ErrInvalidCredentials after logout, got %v", err)
+			}
+			if err := rt.Revoke(ctx, "unknown"); err != nil {
+				t.Fatalf("revoking an unknown token should be fine, got %v", err)
+			}
+		}},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			rt := auth.NewRefreshTokens(&fakeRefresh{tokens: map[string]*types.RefreshToken{}}, clk, time.Hour)
+			tc.run(t, rt, clk)
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/events"
+	"github.com/manishtomar-cpi/go-server/internal/logging"
+	"github.com/manishtomar-cpi/go-server/internal/metrics"
+)
+
+// LoginThrottle counts failed logins per account and per client address and says how long a new attempt has to wait.
+// it is in memory, so every instance counts on its own and a restart forgives everyone.
+// note that anyone can lock a known username out for LockoutDuration, that is the price of stopping guessing
+type LoginThrottle struct {
+	cfg     config.LoginThrottle
+	clock   clock.Clock
+	metrics *metrics.Auth
+	bus     *events.Bus
+
+	mu        sync.Mutex
+	accounts  map[string]*failures
+	ips       map[string]*failures
+	lastSweep time.Time
+}
+
+type failures struct {
+	count        int
+	last         time.Time
+	blockedUntil time.Time
+}
+
+func NewLoginThrottle(cfg config.LoginThrottle, clk clock.Clock, m *metrics.Auth, bus *events.Bus) *LoginThrottle {
+	return &LoginThrottle{
+		cfg:      cfg,
+		clock:    clk,
+		metrics:  m,
+		bus:      bus,
+		accounts: map[string]*failures{},
+		ips:      map[string]*failures{},
+	}
+}
+
+// Wait is how long the caller has to wait before trying to log in again, 0 means go ahead
+func (t *LoginThrottle) Wait(username, ip string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.clock.Now()
+	wait := max(t.blocked(t.accounts, accountKey(username), now), t.blocked(t.ips, ip, now))
+	if wait > 0 {
+		t.metrics.Login("throttled")
+	}
+	return wait
+}
+
+// Failure counts a wrong password and blocks the account and address when they are over their limits
+func (t *LoginThrottle) Failure(ctx context.Context, username, ip string) {
+	t.metrics.Login("failure")
+
+	t.mu.Lock()
+	now := t.clock.Now()
+	t.sweep(now)
+	var locked []events.AccountLocked
+	if e, ok := t.fail(t.accounts, accountKey(username), now, t.cfg.FreeAttempts, t.cfg.LockoutAfter); ok {
+		e.Scope = "account"
+		locked = append(locked, e)
+	}
+	if e, ok := t.fail(t.ips, ip, now, t.cfg.IPFreeAttempts, t.cfg.IPLockoutAfter); ok {
+		e.Scope = "ip"
+		locked = append(locked, e)
+	}
+	t.mu.Unlock()
+
+	for _, e := range locked {
+		t.metrics.Lockout(e.Scope)
+		logging.FromContext(ctx).WarnContext(ctx, "login locked out", slog.String("scope", e.Scope),
+			slog.String("key", e.Key), slog.Int("failures", e.Failures), slog.Time("until", e.Until))
+		t.bus.Publish(ctx, e)
+	}
+}
+
+// Success forgets the failures of the account. the address keeps its count,
+// otherwise logging into your own account would reset the guessing budget for all the others
+func (t *LoginThrottle) Success(username string) {
+	t.metrics.Login("success")
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.accounts, accountKey(username))
+}
+
+func (t *LoginThrottle) blocked(m map[string]*failures, key string, now time.Time) time.Duration {
+	f, ok := m[key]
+	if !ok || key == "" {
+		return 0
+	}
+	return max(f.blockedUntil.Sub(now), 0)
+}
+
+// fail counts one failure for key, ok is true when this failure started a lockout
+func (t *LoginThrottle) fail(m map[string]*failures, key string, now time.Time, free, lockoutAfter int) (events.AccountLocked, bool) {
+	if key == "" {
+		return events.AccountLocked{}, false
+	}
+	f, exists := m[key]
+	if !exists || (t.cfg.ForgetAfter > 0 && now.Sub(f.last) >= t.cfg.ForgetAfter && !now.Before(f.blockedUntil)) {
+		f = &failures{}
+		m[key] = f
+	}
+	f.count++
+	f.last = now
+
+	switch {
+	case lockoutAfter > 0 && f.count >= lockoutAfter:
+		f.blockedUntil = now.Add(t.cfg.LockoutDuration)
+		if f.count == lockoutAfter { // only the failure that crosses the line is news
+			return events.AccountLocked{Key: key, Failures: f.count, Until: f.blockedUntil, OccurredAt: now.UTC()}, true
+		}
+	case t.cfg.BaseDelay > 0 && f.count > free:
+		delay := t.cfg.BaseDelay << min(f.count-free-1, 20) // 1s, 2s, 4s... shift capped so it can not overflow
+		if t.cfg.LockoutDuration > 0 {
+			delay = min(delay, t.cfg.LockoutDuration)
+		}
+		f.blockedUntil = now.Add(delay)
+	}
+	return events.AccountLocked{}, false
+}
+
+// sweep drops entries nobody failed with for ForgetAfter, at most once per ForgetAfter so it costs nothing per request
+func (t *LoginThrottle) sweep(now time.Time) {
+	if t.cfg.ForgetAfter <= 0 || now.Sub(t.lastSweep) < t.cfg.ForgetAfter {
+		return
+	}
+	t.lastSweep = now
+	for _, m := range []map[string]*failures{t.accounts, t.ips} {
+		for key, f := range m {
+			if now.Sub(f.last) >= t.cfg.ForgetAfter && !now.Before(f.blockedUntil) {
+				delete(m, key)
+			}
+		}
+	}
+}
+
+// usernames are case insensitive at registration, so "Asha" and "asha" share one budget
+func accountKey(username string) string {
+	return strings.ToLower(username)
+}
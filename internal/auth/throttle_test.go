@@ -0,0 +1,107 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/events"
+	"github.com/manishtomar-cpi/go-server/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var throttleCfg = config.LoginThrottle{
+	FreeAttempts:    2,
+	IPFreeAttempts:  100,
+	BaseDelay:       time.Second,
+	LockoutAfter:    5,
+	LockoutDuration: 15 * time.Minute,
+	ForgetAfter:     time.Hour,
+}
+
+func newThrottle(t *testing.T) (*auth.LoginThrottle, *clock.Fake, *[]events.AccountLocked) {
+	t.Helper()
+
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	bus := events.NewBus()
+	var locked []events.AccountLocked
+	bus.Subscribe(func(ctx context.Context, e events.Event) {
+		if l, ok := e.(events.AccountLocked); ok {
+			locked = append(locked, l)
+		}
+	})
+	return auth.NewLoginThrottle(throttleCfg, clk, metrics.NewAuth(prometheus.NewRegistry()), bus), clk, &locked
+}
+
+func TestLoginThrottleDelaysDouble(t *testing.T) {
+	t.Parallel()
+
+	throttle, _, _ := newThrottle(t)
+	ctx := context.Background()
+
+	// wait after the 1st, 2nd... failure -> two free ones, then doubling from 1s
+	want := []time.Duration{0, 0, time.Second, 2 * time.Second}
+	for i, wantWait := range want {
+		throttle.Failure(ctx, "asha", "10.0.0.1")
+		if got := throttle.Wait("asha", "10.0.0.1"); got != wantWait {
+			t.Fatalf("after %d failures: want wait %s, got %s", i+1, wantWait, got)
+		}
+	}
+	if got := throttle.Wait("ravi", "10.0.0.2"); got != 0 {
+		t.Fatalf("other account and address must not wait, got %s", got)
+	}
+	if got := throttle.Wait("ASHA", "10.0.0.2"); got == 0 {
+		t.Fatal("username case must not get around the throttle")
+	}
+}
+
+func TestLoginThrottleLockout(t *testing.T) {
+	t.Parallel()
+
+	throttle, clk, locked := newThrottle(t)
+	ctx := context.Background()
+
+	for range throttleCfg.LockoutAfter {
+		throttle.Failure(ctx, "asha", "10.0.0.1")
+	}
+	if got := throttle.Wait("asha", "10.0.0.9"); got != 15*time.Minute {
+		t.Fatalf("want 15m lockout, got %s", got)
+	}
+	if len(*locked) != 1 || (*locked)[0].Scope != "account" || (*locked)[0].Key != "asha" {
+		t.Fatalf("want one account.locked event for asha, got %+v", *locked)
+	}
+
+	clk.Advance(16 * time.Minute)
+	if got := throttle.Wait("asha", "10.0.0.1"); got != 0 {
+		t.Fatalf("lockout should be over, got %s", got)
+	}
+}
+
+func TestLoginThrottleSuccessAndForget(t *testing.T) {
+	t.Parallel()
+
+	throttle, clk, _ := newThrottle(t)
+	ctx := context.Background()
+
+	for range 3 {
+		throttle.Failure(ctx, "asha", "10.0.0.1")
+	}
+	clk.Advance(time.Minute)
+	throttle.Success("asha")
+	throttle.Failure(ctx, "asha", "10.0.0.1")
+	if got := throttle.Wait("asha", ""); got != 0 {
+		t.Fatalf("success should reset the account, got wait %s", got)
+	}
+
+	for range 3 {
+		throttle.Failure(ctx, "ravi", "")
+	}
+	clk.Advance(2 * time.Hour)
+	throttle.Failure(ctx, "ravi", "")
+	if got := throttle.Wait("ravi", ""); got != 0 {
+		t.Fatalf("old failures should be forgotten, got wait %s", got)
+	}
+}
@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/manishtomar-cpi/go-server/internal/auth/password"
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/logging"
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+// CredentialChecker checks a username and password, the login endpoint turns the principal into a token.
+// returns ErrInvalidCredentials for an unknown user or a wrong password, never which of the two it was
+type CredentialChecker interface {
+	CheckPassword(ctx context.Context, username, password string) (*Principal, error)
+}
+
+// StaticUsers are the accounts from the config file, with bcrypt or argon2id password hashes
+type StaticUsers struct {
+	users  map[string]config.User
+	hasher *password.Hasher
+}
+
+func NewStaticUsers(users []config.User, hasher *password.Hasher) *StaticUsers {
+	m := make(map[string]config.User, len(users))
+	for _, u := range users {
+		m[u.Username] = u
+	}
+	return &StaticUsers{users: m, hasher: hasher}
+}
+
+// Has says if username is taken by a config user, case does not matter
+func (s *StaticUsers) Has(username string) bool {
+	for name := range s.users {
+		if strings.EqualFold(name, username) {
+			return true
+		}
+	}
+	return false
+}
+
+// config hashes are never rehashed, the file is not ours to write
+func (s *StaticUsers) CheckPassword(ctx context.Context, username, password string) (*Principal, error) {
+	u, found := s.users[username]
+	if !found {
+		s.hasher.VerifyDummy(password)
+		return nil, ErrInvalidCredentials
+	}
+	ok, _, err := s.hasher.Verify(password, u.PasswordHash)
+	if err != nil {
+		return nil, fmt.Errorf("config user %q: %w", username, err)
+	}
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	return &Principal{Subject: u.Username, Kind: "user", Roles: u.Roles, StudentID: u.StudentID}, nil
+}
+
+// Credentials tries each checker in order and takes the first that accepts, config users first and then registered ones
+type Credentials []CredentialChecker
+
+func (c Credentials) CheckPassword(ctx context.Context, username, password string) (*Principal, error) {
+	for _, checker := range c {
+		p, err := checker.CheckPassword(ctx, username, password)
+		if errors.Is(err, ErrInvalidCredentials) {
+			continue
+		}
+		return p, err
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// Accounts are the users that registered through the api, kept in the database
+type Accounts struct {
+	store    storage.UserStore
+	clock    clock.Clock
+	hasher   *password.Hasher
+	reserved *StaticUsers // config users, nobody may register their names
+}
+
+func NewAccounts(store storage.UserStore, clk clock.Clock, hasher *password.Hasher, reserved *StaticUsers) *Accounts {
+	return &Accounts{store: store, clock: clk, hasher: hasher, reserved: reserved}
+}
+
+// Register creates an account after the password passed the policy (password.ErrWeakPassword otherwise).
+// a taken name gives a storage.DuplicateError
+func (a *Accounts) Register(ctx context.Context, username, pass string) (types.User, error) {
+	if a.reserved.Has(username) { // would let the new account log in as the config user
+		return types.User{}, &storage.DuplicateError{Entity: "user", Field: "username"}
+	}
+	if err := a.hasher.Check(pass, username); err != nil {
+		return types.User{}, err
+	}
+	hash, err := a.hasher.Hash(pass)
+	if err != nil {
+		return types.User{}, err
+	}
+	user := types.User{Username: username, PasswordHash: hash, Roles: []string{}, CreatedAt: a.clock.Now()}
+	user.Id, err = a.store.CreateUser(ctx, user)
+	if err != nil {
+		return types.User{}, err
+	}
+	return user, nil
+}
+
+// CheckPassword also moves the stored hash to the current argon2id params when it was made with older ones
+func (a *Accounts) CheckPassword(ctx context.Context, username, pass string) (*Principal, error) {
+	u, err := a.store.UserByUsername(ctx, username)
+	if errors.Is(err, storage.ErrNotFound) {
+		a.hasher.VerifyDummy(pass)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("user lookup: %w", err)
+	}
+	ok, rehash, err := a.hasher.Verify(pass, u.PasswordHash)
+	if err != nil {
+		return nil, fmt.Errorf("user %q: %w", username, err)
+	}
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	if rehash {
+		a.rehash(ctx, u, pass)
+	}
+	return &Principal{Subject: u.Username, Kind: "user", Roles: u.Roles}, nil
+}
+
+// rehash failing is not worth failing the login over, it is tried again next time
+func (a *Accounts) rehash(ctx context.Context, u types.User, pass string) {
+	hash, err := a.hasher.Hash(pass)
+	if err == nil {
+		err = a.store.UpdatePasswordHash(ctx, u.Id, hash)
+	}
+	if err != nil {
+		logging.FromContext(ctx).WarnContext(ctx, "password rehash failed", slog.Int64("user_id", u.Id), slog.String("error", err.Error()))
+	}
+}
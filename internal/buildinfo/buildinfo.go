@@ -0,0 +1,50 @@
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// set at build time, like
+//
+//	go build -ldflags "-X github.com/manishtomar-cpi/go-server/internal/buildinfo.Version=1.4.0
+//	  -X github.com/manishtomar-cpi/go-server/internal/buildinfo.Commit=$(git rev-parse HEAD)
+//	  -X github.com/manishtomar-cpi/go-server/internal/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/go-server
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// Info is what GET /api/v1/version answers and what the startup log line shows
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+	Modified  bool   `json:"modified,omitempty"` // built from a checkout with uncommitted changes
+}
+
+// Get returns the ldflags values, commit and time fall back to what the go toolchain stamped into the binary
+func Get() Info {
+	info := Info{Version: Version, Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = s.Value
+			}
+		case "vcs.time":
+			if info.BuildTime == "" {
+				info.BuildTime = s.Value
+			}
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	return info
+}
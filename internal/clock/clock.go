@@ -0,0 +1,47 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is where code gets the current time from, instead of calling time.Now directly,
+// so tests can freeze or move time (token expiry, ttls, budgets)
+type Clock interface {
+	Now() time.Time
+}
+
+// System is the real clock used in production
+type System struct{}
+
+func (System) Now() time.Time {
+	return time.Now()
+}
+
+// Fake is a clock that only moves when the test tells it to
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = now
+}
+
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
@@ -4,20 +4,535 @@ import (
 	"flag"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
 // struct tags -> They tell Go libraries how to read data into struct fields, So, when we load the YAML file, Go knows how to fill these values into your struct.
 type HTTPServer struct {
-	Address string `yaml:"address" env-requried:"true"`
+	Address      string `yaml:"address" env-requried:"true"`    // host:port or unix:///path/to/file.sock
+	SocketMode   string `yaml:"socket_mode" env-default:"0660"` // permissions of the unix socket file
+	TLS          TLS    `yaml:"tls"`
+	DisableHTTP2 bool   `yaml:"disable_http2"` // HTTP/2 is on by default
+	H2C          bool   `yaml:"h2c"`           // HTTP/2 over plain tcp, only for running behind a proxy that does TLS
+	HTTP3        bool   `yaml:"http3"`         // extra QUIC listener on the same port over udp, needs tls
+	// how long a keep-alive connection may sit idle between requests before it is closed, 0 keeps it open until the
+	// client goes away. with many clients every idle connection holds a descriptor and memory
+	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"2m"`
+	Listener    Listener      `yaml:"listener"`
+}
+
+// socket options of a tcp listener, for hosts with many connections.
+// KeepAlive is how long an accepted connection may be quiet before tcp starts probing it (0 keeps the go default of
+// 15s, -1 turns probing off), KeepAliveInterval and KeepAliveCount are the time between probes and how many may go
+// unanswered before the connection is dropped (0 is the default of go, -1 the one of the os).
+// ReusePort sets SO_REUSEPORT so several processes on the host can listen on the same port, the kernel spreads the
+// connections over them (linux and the bsds, macOS included). Backlog is the accept queue the deployment expects,
+// go always asks for the most the kernel allows (net.core.somaxconn on linux), so it is only checked against that
+// and a warning is logged when the kernel would cut it down
+type Listener struct {
+	KeepAlive         time.Duration `yaml:"keep_alive"`
+	KeepAliveInterval time.Duration `yaml:"keep_alive_interval"`
+	KeepAliveCount    int           `yaml:"keep_alive_count"`
+	ReusePort         bool          `yaml:"reuse_port"`
+	Backlog           int           `yaml:"backlog"`
+}
+
+// grpc listener for internal services, same storage and auth as the http api. empty address turns it off
+type GRPCServer struct {
+	Address string `yaml:"address"` // host:port
+	TLS     TLS    `yaml:"tls"`
+}
+
+// cert and key for serving https, leave empty to serve plain http
+type TLS struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+func (t TLS) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
+}
+
+// limits for export/report endpoints, so one big export can not eat all the memory of the process
+type Export struct {
+	MaxRows     int           `yaml:"max_rows" env-default:"10000"`
+	MaxBytes    int64         `yaml:"max_bytes" env-default:"10485760"` // 10MB
+	MaxDuration time.Duration `yaml:"max_duration" env-default:"30s"`
+}
+
+// how many requests can run at the same time, 0 means no limit.
+// requests over the limit wait up to QueueTimeout in a queue of QueueSize, after that they get 503
+type Concurrency struct {
+	MaxInFlight  int           `yaml:"max_in_flight" env-default:"100"`
+	QueueSize    int           `yaml:"queue_size" env-default:"50"`
+	QueueTimeout time.Duration `yaml:"queue_timeout" env-default:"1s"`
+}
+
+// adaptive load shedding in front of the concurrency limiter. every Interval the p99 latency of the requests that got
+// through and the depth of the limiter queue are checked, while either is over its threshold the shed level goes up by
+// Step, and back down by Step once both are fine. the level rejects a growing share of low priority requests and, past
+// half, of normal ones too -> they get a 503 right away instead of timing out in front of the sqlite writer
+type Shedding struct {
+	Enabled       bool          `yaml:"enabled" env:"SHEDDING"`
+	TargetLatency time.Duration `yaml:"target_latency" env-default:"500ms"`
+	MaxQueue      int           `yaml:"max_queue" env-default:"25"` // of concurrency.queue_size
+	Interval      time.Duration `yaml:"interval" env-default:"1s"`
+	Step          float64       `yaml:"step" env-default:"0.1"`
+}
+
+// warm-up runs before readiness flips to ready, so the first requests after a deploy are not slow
+type Warmup struct {
+	Enabled bool          `yaml:"enabled"`
+	Timeout time.Duration `yaml:"timeout" env-default:"10s"`
+}
+
+// how many recent anomalies of each kind are kept for the admin runbook endpoint
+type Anomalies struct {
+	BufferSize int `yaml:"buffer_size" env-default:"50"`
+}
+
+// request deadlines, when one passes the request context is cancelled and the client gets a 504 json body, 0 means no timeout.
+// Default is for every api route, the others override it for their route
+type RouteTimeouts struct {
+	Default time.Duration `yaml:"default" env-default:"30s"`
+	Export  time.Duration `yaml:"export" env-default:"60s"`
+	Ingest  time.Duration `yaml:"ingest" env-default:"10m"` // ndjson bulk import, reads the upload while it answers
+}
+
+// tuning of the sqlite storage -> InsertBatch is how many students one INSERT statement carries when many are added at
+// once (bulk create, ndjson import), at most 8191 because sqlite takes 32766 parameters per statement
+type SQLite struct {
+	InsertBatch int `yaml:"insert_batch" env:"SQLITE_INSERT_BATCH" env-default:"500"`
+}
+
+// ndjson bulk import -> valid records are stored BatchSize at a time, a single line may not be longer than MaxLineBytes
+type Ingest struct {
+	BatchSize    int `yaml:"batch_size" env-default:"100"`
+	MaxLineBytes int `yaml:"max_line_bytes" env-default:"65536"`
+}
+
+// background imports of csv or ndjson uploads -> the upload is stored (up to MaxBytes) and answered with 202 right away,
+// a job stores it BatchSize records at a time. a failed or timed out attempt goes on after the last stored batch, so
+// Timeout only bounds one attempt and has to stay under jobs.lease, or another worker takes the running job over.
+// uploads wait for their job as files in Dir, empty is an imports directory next to storage_path
+type Imports struct {
+	Dir            string        `yaml:"dir"`
+	MaxBytes       int64         `yaml:"max_bytes" env-default:"67108864"` // 64MB
+	MaxRecordBytes int           `yaml:"max_record_bytes" env-default:"65536"`
+	BatchSize      int           `yaml:"batch_size" env-default:"500"`
+	MaxAttempts    int           `yaml:"max_attempts" env-default:"5"`
+	BaseDelay      time.Duration `yaml:"base_delay" env-default:"10s"`
+	MaxDelay       time.Duration `yaml:"max_delay" env-default:"5m"`
+	Timeout        time.Duration `yaml:"timeout" env-default:"4m"`
+}
+
+// graceful shutdown -> background jobs stop taking new ones and readiness fails first, we wait ReadinessDelay so the
+// load balancer stops sending traffic, then in-flight requests and running jobs get DrainTimeout to finish. a job still
+// running after it is cancelled and runs again after the restart
+type Shutdown struct {
+	ReadinessDelay time.Duration `yaml:"readiness_delay" env-default:"0s"`
+	DrainTimeout   time.Duration `yaml:"drain_timeout" env-default:"5s"`
+}
+
+// login for the /admin ui and every route of the admin listener except the probes, the ui is turned off while password is empty.
+// PasswordFile is for secrets mounted as files (docker/kubernetes secrets), it wins over Password
+type AdminAuth struct {
+	Username     string `yaml:"username" env:"ADMIN_USERNAME" env-default:"admin"`
+	Password     string `yaml:"password" env:"ADMIN_PASSWORD" json:"-"` // json:"-" so the config dump never shows it
+	PasswordFile string `yaml:"password_file" env:"ADMIN_PASSWORD_FILE"`
+}
+
+// token bucket per client (the user or api key, the ip for anonymous requests) -> Rate requests per second on average, Burst at once.
+// with RedisAddr set the buckets are shared by all instances, otherwise every instance counts on its own.
+// wrong credentials are counted per ip in a bucket of their own (FailedAuthRate, FailedAuthBurst), that one is on
+// even without Enabled, so nobody can guess passwords or api keys at full speed
+type RateLimit struct {
+	Enabled         bool    `yaml:"enabled"`
+	Rate            float64 `yaml:"rate" env-default:"10"`
+	Burst           int     `yaml:"burst" env-default:"20"`
+	FailedAuthRate  float64 `yaml:"failed_auth_rate" env-default:"0.05"`
+	FailedAuthBurst int     `yaml:"failed_auth_burst" env-default:"10"`
+	RedisAddr       string  `yaml:"redis_addr"`
+}
+
+// gzip/deflate for clients that send Accept-Encoding
+type Compression struct {
+	Enabled      bool     `yaml:"enabled"`
+	MinSize      int      `yaml:"min_size" env-default:"1024"`
+	ContentTypes []string `yaml:"content_types" env-default:"application/json,application/problem+json,text/csv,application/xml,text/plain"`
+}
+
+// where traces and metrics go over OTLP/http, empty otlp_endpoint (like "localhost:4318") means nothing is exported
+type Observability struct {
+	ServiceName    string            `yaml:"service_name" env-default:"go-server"`
+	OTLPEndpoint   string            `yaml:"otlp_endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	Headers        map[string]string `yaml:"headers" json:"-"`             // usually an api key for a hosted collector, so never dumped
+	Insecure       bool              `yaml:"insecure"`                     // plain http to the collector, for a collector running next to the app
+	SampleRatio    float64           `yaml:"sample_ratio" env-default:"1"` // share of new traces kept, 0.1 -> 10%
+	MetricInterval time.Duration     `yaml:"metric_interval" env-default:"60s"`
+}
+
+// how long the first response to an Idempotency-Key is replayed to retries
+type Idempotency struct {
+	TTL time.Duration `yaml:"ttl" env-default:"24h"`
+}
+
+// Cache-Control sent with student reads, "no-cache" still lets clients revalidate with the ETag and get a 304
+type Caching struct {
+	Students string `yaml:"students" env-default:"no-cache"` // GET /api/v1/students
+	Student  string `yaml:"student" env-default:"no-cache"`  // GET /api/v1/students/{id}
+}
+
+// in-process LRU of the students read by id, for a single instance that does not want to run redis. Size is how many
+// students it keeps, a cached one is read from the db again after TTL. other instances do not tell it about their
+// writes, so with several instances keep it off or the ttl short
+type StudentCache struct {
+	Enabled bool          `yaml:"enabled" env:"STUDENT_CACHE"`
+	Size    int           `yaml:"size" env-default:"10000"`
+	TTL     time.Duration `yaml:"ttl" env-default:"1m"`
+}
+
+// start in read-only mode, it can also be switched at runtime on the admin listener
+type Maintenance struct {
+	Enabled bool `yaml:"enabled" env:"MAINTENANCE"`
+}
+
+// load balancers in front of the server, their X-Forwarded-For / X-Real-IP headers are trusted for the client ip.
+// cidrs or single addresses, empty means the connection address is always the client
+type Proxy struct {
+	TrustedProxies []string `yaml:"trusted_proxies" env:"TRUSTED_PROXIES" env-separator:","`
+}
+
+// pprof on the admin listener under /debug/pprof/, behind the admin login when a password is set.
+// fgprof (/debug/fgprof) also samples goroutines waiting on io, which plain pprof cpu profiles do not show
+type Profiling struct {
+	Disabled bool `yaml:"disabled"`
+	FGProf   bool `yaml:"fgprof"`
+}
+
+// every /readyz dependency check (db ping, schema, redis...) has to answer within CheckTimeout.
+// they run every RefreshInterval in the background and /readyz answers with the last results, 0 runs them on every probe
+type Health struct {
+	CheckTimeout    time.Duration `yaml:"check_timeout" env-default:"2s"`
+	RefreshInterval time.Duration `yaml:"refresh_interval" env-default:"10s"`
+}
+
+// log output -> format json (for log shippers) or text (for reading in a terminal), level debug/info/warn/error
+type Logging struct {
+	Format string  `yaml:"format" env:"LOG_FORMAT" env-default:"json"`
+	Level  string  `yaml:"level" env:"LOG_LEVEL" env-default:"info"`
+	File   LogFile `yaml:"file"`
+}
+
+// logs also go to a rotated file when Path is set, for bare metal boxes without a log shipper.
+// a file is rotated once it reaches MaxSizeMB, old ones are deleted after MaxAgeDays or when there are more than MaxBackups
+type LogFile struct {
+	Path       string `yaml:"path" env:"LOG_FILE"`
+	MaxSizeMB  int    `yaml:"max_size_mb" env-default:"100"`
+	MaxAgeDays int    `yaml:"max_age_days" env-default:"28"`
+	MaxBackups int    `yaml:"max_backups" env-default:"5"`
+	Compress   bool   `yaml:"compress"` // gzip rotated files
+	Only       bool   `yaml:"only"`     // file only, nothing on stderr
+}
+
+// panics and 5xx go to a sentry compatible tracker when DSN is set, SampleRate 0.25 -> a quarter of the events is sent
+type ErrorReporting struct {
+	DSN        string  `yaml:"dsn" env:"SENTRY_DSN" json:"-"` // the dsn has the key in it, so never dumped
+	SampleRate float64 `yaml:"sample_rate" env-default:"1"`
+}
+
+// requests slower than Threshold are logged with all their details and listed as anomalies, 0 turns it off
+type SlowRequests struct {
+	Threshold time.Duration `yaml:"threshold" env-default:"500ms"`
+}
+
+// signed access tokens -> HS256 with Secret (at least 32 bytes) or RS256 with a PEM private key.
+// without a secret/key there is no login and the student write endpoints can not be used
+type JWT struct {
+	Algorithm      string        `yaml:"algorithm" env-default:"HS256"`
+	Secret         string        `yaml:"secret" env:"JWT_SECRET" json:"-"`
+	PrivateKeyFile string        `yaml:"private_key_file" env:"JWT_PRIVATE_KEY_FILE"`
+	Issuer         string        `yaml:"issuer" env-default:"go-server"`
+	TTL            time.Duration `yaml:"ttl" env-default:"15m"`
+	RefreshTTL     time.Duration `yaml:"refresh_ttl" env-default:"720h"` // refresh tokens, rotated on every use
+}
+
+// OIDCProvider is an identity provider people can sign in with, like google or microsoft entra id
+type OIDCProvider struct {
+	Issuer       string   `yaml:"issuer"` // https://accounts.google.com, https://login.microsoftonline.com/<tenant>/v2.0
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret" json:"-"`
+	RedirectURL  string   `yaml:"redirect_url"` // https://<host>/api/v1/auth/oidc/<name>/callback, registered at the provider
+	Scopes       []string `yaml:"scopes"`       // asked for on top of openid, email and profile
+	GroupsClaim  string   `yaml:"groups_claim" env-default:"groups"`
+	// RoleMapping turns provider groups into our roles -> {"<group id>": "teacher"}. users in no mapped group get no role
+	RoleMapping map[string]string `yaml:"role_mapping"`
+}
+
+// Password is the argon2id cost for new password hashes and the minimum length of new passwords.
+// raising the cost rehashes every user on their next login
+type Password struct {
+	MemoryKiB   uint32 `yaml:"memory_kib" env-default:"65536"`
+	Iterations  uint32 `yaml:"iterations" env-default:"3"`
+	Parallelism uint8  `yaml:"parallelism" env-default:"2"`
+	MinLength   int    `yaml:"min_length" env-default:"10"`
+}
+
+// LoginThrottle slows down password guessing. after FreeAttempts failures every further failure blocks the account
+// for BaseDelay, doubling each time, and LockoutAfter failures lock it for LockoutDuration. addresses get the same
+// treatment with their own, higher, limit (one office behind a NAT shares an address). zero values turn a part off
+type LoginThrottle struct {
+	FreeAttempts    int           `yaml:"free_attempts" env-default:"3"`
+	IPFreeAttempts  int           `yaml:"ip_free_attempts" env-default:"20"`
+	BaseDelay       time.Duration `yaml:"base_delay" env-default:"1s"`
+	LockoutAfter    int           `yaml:"lockout_after" env-default:"10"`
+	IPLockoutAfter  int           `yaml:"ip_lockout_after" env-default:"100"`
+	LockoutDuration time.Duration `yaml:"lockout_duration" env-default:"15m"`
+	ForgetAfter     time.Duration `yaml:"forget_after" env-default:"1h"` // failures are forgotten after this long without another one
+}
+
+// live updates over websocket and server-sent events -> PingInterval 0 turns keepalive pings off, MaxConnections 0 means no limit.
+// a client that falls SendBuffer events behind is disconnected instead of slowing everyone down,
+// the last History events are kept so a reconnecting client can resume with Last-Event-ID
+type Live struct {
+	PingInterval   time.Duration `yaml:"ping_interval" env-default:"30s"`
+	MaxConnections int           `yaml:"max_connections" env-default:"1000"`
+	SendBuffer     int           `yaml:"send_buffer" env-default:"64"`
+	History        int           `yaml:"history" env-default:"1000"`
+}
+
+// error bodies -> with Always every client gets RFC 7807 application/problem+json,
+// without it only clients that ask for it in Accept, the rest keeps the old {status, error} body
+type Problems struct {
+	Always bool `yaml:"always" env:"PROBLEM_DETAILS"`
+}
+
+// the api is served under /api/v1, the old unversioned /api paths stay as deprecated aliases until DisableLegacy is set.
+// LegacySunset (RFC 3339, like 2027-06-30T00:00:00Z) is announced in a Sunset header on every response of an old path
+type APIVersions struct {
+	DisableLegacy bool   `yaml:"disable_legacy"`
+	LegacySunset  string `yaml:"legacy_sunset"`
+}
+
+// error messages are translated to the Accept-Language of the client, hi and es are built in.
+// Dir holds more <lang>.json catalogs (like pt-BR.json), they add languages or override built-in messages
+type I18n struct {
+	Dir string `yaml:"dir" env:"I18N_DIR"`
+}
+
+// custom rules of the request validation. NamePattern is the regexp names must match (the "name" rule), EmailDomains
+// limits emails to these domains ("email_domain", empty allows all) and PhonePattern is the "phone" rule.
+// empty patterns keep the defaults of the validation package -> letters of any script for names, E.164 for phones
+type Validation struct {
+	NamePattern  string   `yaml:"name_pattern" env:"VALIDATION_NAME_PATTERN"`
+	EmailDomains []string `yaml:"email_domains" env:"VALIDATION_EMAIL_DOMAINS" env-separator:","`
+	PhonePattern string   `yaml:"phone_pattern" env:"VALIDATION_PHONE_PATTERN"`
+}
+
+// request bodies -> Lenient drops fields the target type does not have instead of answering 400 UNKNOWN_FIELD,
+// for clients that can not stop sending extras yet. MaxBody is the most bytes a body may have, bigger ones get 413
+type Decoding struct {
+	Lenient bool  `yaml:"lenient" env:"DECODING_LENIENT"`
+	MaxBody int64 `yaml:"max_body" env:"DECODING_MAX_BODY" env-default:"1048576"`
+}
+
+// outbound webhooks -> a failed delivery is retried after BaseDelay, doubling up to MaxDelay, until MaxAttempts is used up
+type Webhooks struct {
+	MaxAttempts int           `yaml:"max_attempts" env-default:"8"`
+	BaseDelay   time.Duration `yaml:"base_delay" env-default:"10s"`
+	MaxDelay    time.Duration `yaml:"max_delay" env-default:"1h"`
+	Timeout     time.Duration `yaml:"timeout" env-default:"10s"` // for one attempt
+}
+
+// notification emails (welcome, enrollment confirmation) -> off while Host is empty. TLS is "starttls" (upgrade after
+// connecting, usually port 587), "tls" (tls from the start, usually 465) or "none" for a relay on localhost. a failed
+// send is retried like a webhook delivery, a 5xx answer of the server is not
+type Email struct {
+	Host        string        `yaml:"host" env:"SMTP_HOST"`
+	Port        int           `yaml:"port" env:"SMTP_PORT" env-default:"587"`
+	Username    string        `yaml:"username" env:"SMTP_USERNAME"`
+	Password    string        `yaml:"password" env:"SMTP_PASSWORD" json:"-"`
+	TLS         string        `yaml:"tls" env-default:"starttls"`
+	From        string        `yaml:"from" env:"SMTP_FROM"` // "School <no-reply@example.com>"
+	MaxAttempts int           `yaml:"max_attempts" env-default:"5"`
+	BaseDelay   time.Duration `yaml:"base_delay" env-default:"30s"`
+	MaxDelay    time.Duration `yaml:"max_delay" env-default:"1h"`
+	Timeout     time.Duration `yaml:"timeout" env-default:"30s"` // for one attempt
+}
+
+// the outbox relay publishes every public event to one sink -> off while Sink is empty. "http" posts batches as a json
+// array to URL, "kafka" writes to Topic on Brokers, "nats" publishes to the subject <Topic>.<event type> on the
+// connection of messaging.nats, "amqp" publishes to the exchange Topic of the rabbitmq at URL (amqp:// or amqps://).
+// delivery is at least once and in order, consumers de-duplicate on the event id
+type Outbox struct {
+	Sink         string            `yaml:"sink" env:"OUTBOX_SINK"` // http, kafka, nats or amqp
+	URL          string            `yaml:"url" env:"OUTBOX_URL"`
+	Brokers      []string          `yaml:"brokers" env:"OUTBOX_BROKERS" env-separator:","`
+	Topic        string            `yaml:"topic" env-default:"go-server.events"`
+	Headers      map[string]string `yaml:"headers" json:"-"` // for the http sink, usually a token so never dumped
+	BatchSize    int               `yaml:"batch_size" env-default:"100"`
+	PollInterval time.Duration     `yaml:"poll_interval" env-default:"1s"` // new events also wake the relay right away
+	Timeout      time.Duration     `yaml:"timeout" env-default:"10s"`      // for publishing one batch
+	BaseDelay    time.Duration     `yaml:"base_delay" env-default:"1s"`    // after a failed batch, doubling up to MaxDelay
+	MaxDelay     time.Duration     `yaml:"max_delay" env-default:"1m"`
+	Kafka        OutboxKafka       `yaml:"kafka"`
+	AMQP         OutboxAMQP        `yaml:"amqp"`
+}
+
+// NATS is the connection to a nats server, off while URL is empty. it reconnects forever by default (MaxReconnects -1),
+// what is published while it is away is buffered up to ReconnectBuffer bytes. with Serve on, internal services can
+// ask <Prefix>.students.get and <Prefix>.students.list over request/reply, the replicas share the queue group so
+// every request is answered once. nats permissions on those subjects are what keeps others out
+type NATS struct {
+	URL             string        `yaml:"url" env:"NATS_URL"` // nats://host:4222, a comma separated list for a cluster
+	Name            string        `yaml:"name" env-default:"go-server"`
+	CredsFile       string        `yaml:"creds_file"` // jwt + nkey credentials, for servers with decentralized auth
+	Token           string        `yaml:"token" env:"NATS_TOKEN" json:"-"`
+	ConnectTimeout  time.Duration `yaml:"connect_timeout" env-default:"5s"`
+	ReconnectWait   time.Duration `yaml:"reconnect_wait" env-default:"2s"`
+	MaxReconnects   int           `yaml:"max_reconnects" env-default:"-1"`
+	ReconnectBuffer int           `yaml:"reconnect_buffer" env-default:"8388608"`
+	Serve           bool          `yaml:"serve" env:"NATS_SERVE"`
+	Prefix          string        `yaml:"prefix" env-default:"go-server"`
+	QueueGroup      string        `yaml:"queue_group" env-default:"go-server"`
+	RequestTimeout  time.Duration `yaml:"request_timeout" env-default:"5s"` // for answering one request
+}
+
+// OutboxAMQP is how the amqp sink publishes to rabbitmq. the exchange is declared durable as ExchangeType, with Declare
+// off it has to exist already (owned by whoever runs the broker). the routing key is the event type, RoutingKeys maps
+// an event type to another key. the credentials stay out of the url so it can be dumped
+type OutboxAMQP struct {
+	ExchangeType string            `yaml:"exchange_type" env-default:"topic"`
+	Declare      bool              `yaml:"declare" env-default:"true"`
+	RoutingKeys  map[string]string `yaml:"routing_keys"` // student.created: students.new
+	Username     string            `yaml:"username" env:"AMQP_USERNAME"`
+	Password     string            `yaml:"password" env:"AMQP_PASSWORD" json:"-"`
+	CAFile       string            `yaml:"ca_file"` // extra root certificate for amqps, for brokers with a private ca
+}
+
+// Messaging is the message brokers the server talks to besides its own listeners
+type Messaging struct {
+	NATS NATS `yaml:"nats"`
+}
+
+// OutboxKafka is how the kafka sink talks to the brokers and encodes the events. Encoding is "json" (the outbox message)
+// or "avro" (the student change as outbox.AvroSchema, for analytics). with SchemaID > 0 the avro is framed the way
+// confluent schema registry clients expect it. SASL is "plain", "scram-sha-256" or "scram-sha-512", empty is none
+type OutboxKafka struct {
+	Encoding string `yaml:"encoding" env:"OUTBOX_KAFKA_ENCODING" env-default:"json"`
+	SchemaID int    `yaml:"schema_id"`
+	SASL     string `yaml:"sasl"`
+	Username string `yaml:"username" env:"KAFKA_USERNAME"`
+	Password string `yaml:"password" env:"KAFKA_PASSWORD" json:"-"`
+	TLS      bool   `yaml:"tls"`
+	CAFile   string `yaml:"ca_file"` // extra root certificate, for brokers with a private ca
+}
+
+// background jobs (webhook deliveries, emails and imports) -> Workers of them run at the same time, due ones are looked
+// for every PollInterval and right away when one is queued. Timeout is for one attempt of a kind that sets none. a
+// running job is locked for Lease, a job whose worker died (a crash) is picked up again once it ran out, it has to be
+// longer than every timeout of a kind (jobs, imports, webhooks, email) or loading fails. on shutdown running jobs get what is left of the drain timeout, then go back to the queue.
+// every retry delay is cut by a random part of up to Jitter (0 to 1, 0 is off), so jobs that failed together do not all
+// retry at the same moment. Retry changes how the jobs of one kind (webhook.deliver, email.send, student.import) are
+// retried. a job that failed for good is kept as a dead job, GET /api/admin/jobs/dead shows them
+type Jobs struct {
+	Workers      int              `yaml:"workers" env:"JOBS_WORKERS" env-default:"4"`
+	PollInterval time.Duration    `yaml:"poll_interval" env-default:"1s"`
+	Timeout      time.Duration    `yaml:"timeout" env-default:"1m"`
+	Lease        time.Duration    `yaml:"lease" env-default:"5m"`
+	Jitter       float64          `yaml:"jitter" env-default:"0.2"`
+	Retry        map[string]Retry `yaml:"retry"`
+	// how long before the end of shutdown.drain_timeout the running jobs are told to save their progress and stop,
+	// until then they go on working
+	Checkpoint time.Duration `yaml:"checkpoint" env-default:"1s"`
+}
+
+// Retry is the retry policy of one job kind, the fields left out keep what the kind has on its own (like
+// webhooks.max_attempts for webhook.deliver). Jitter is a pointer so 0 can turn it off for one kind
+type Retry struct {
+	MaxAttempts int           `yaml:"max_attempts"`
+	BaseDelay   time.Duration `yaml:"base_delay"` // after the first failure, doubling up to MaxDelay
+	MaxDelay    time.Duration `yaml:"max_delay"`
+	Jitter      *float64      `yaml:"jitter"`
+}
+
+// Purge is a scheduled cleanup -> rows older than KeepFor go every time Schedule matches. Schedule is a 5 field cron
+// line or "@daily", "@every 6h"..., "off" turns the cleanup off
+type Purge struct {
+	Schedule string        `yaml:"schedule" env-default:"@daily"`
+	KeepFor  time.Duration `yaml:"keep_for" env-default:"720h"`
+}
+
+// periodic tasks, their state is on GET /api/admin/tasks of the admin listener
+type Scheduler struct {
+	PurgeJobs          Purge `yaml:"purge_jobs"`           // done and failed jobs
+	PurgeDeadJobs      Purge `yaml:"purge_dead_jobs"`      // dead jobs, requeued or not
+	PurgeDeliveries    Purge `yaml:"purge_deliveries"`     // delivered and failed webhook deliveries
+	PurgeRefreshTokens Purge `yaml:"purge_refresh_tokens"` // expired refresh tokens
+	PurgeEmails        Purge `yaml:"purge_emails"`         // sent and failed emails
+	PurgeOutbox        Purge `yaml:"purge_outbox"`         // events the relay published
+	PurgeImports       Purge `yaml:"purge_imports"`        // finished imports with their error reports
+}
+
+// an account that can log in, PasswordHash is argon2id or bcrypt -> htpasswd -nbBC 10 "" 'the password' | cut -d: -f2
+type User struct {
+	Username     string   `yaml:"username"`
+	PasswordHash string   `yaml:"password_hash" json:"-"`
+	Roles        []string `yaml:"roles"`      // admin, teacher or student
+	StudentID    int64    `yaml:"student_id"` // the student record a "student" account belongs to
 }
 
 type Config struct {
 	//means `what will be the value of this -> from where we are getting called struct tags`
-	Env          string               `yaml:"env" env:"ENV" env-requried:"true"`
-	Storage_path string               `yaml:"storage_path" env-requried:"true"`
-	HTTPServer   `yaml:"http_server"` //struct embed
+	Env           string                  `yaml:"env" env:"ENV" env-requried:"true"`
+	Storage_path  string                  `yaml:"storage_path" env-requried:"true"`
+	SQLite        SQLite                  `yaml:"sqlite"`
+	HTTPServer    `yaml:"http_server"`    //struct embed
+	AdminServer   HTTPServer              `yaml:"admin_server"` // metrics, pprof, health, config dump... keep it on localhost or an internal port, empty address turns it off
+	GRPCServer    GRPCServer              `yaml:"grpc_server"`
+	Export        Export                  `yaml:"export"`
+	Ingest        Ingest                  `yaml:"ingest"`
+	Imports       Imports                 `yaml:"imports"`
+	Concurrency   Concurrency             `yaml:"concurrency"`
+	Shedding      Shedding                `yaml:"shedding"`
+	Warmup        Warmup                  `yaml:"warmup"`
+	Anomalies     Anomalies               `yaml:"anomalies"`
+	Timeouts      RouteTimeouts           `yaml:"route_timeouts"`
+	Shutdown      Shutdown                `yaml:"shutdown"`
+	AdminAuth     AdminAuth               `yaml:"admin_auth"`
+	RateLimit     RateLimit               `yaml:"rate_limit"`
+	Compression   Compression             `yaml:"compression"`
+	Observability Observability           `yaml:"observability"`
+	Idempotency   Idempotency             `yaml:"idempotency"`
+	Caching       Caching                 `yaml:"caching"`
+	StudentCache  StudentCache            `yaml:"student_cache"`
+	Maintenance   Maintenance             `yaml:"maintenance"`
+	Proxy         Proxy                   `yaml:"proxy"`
+	Profiling     Profiling               `yaml:"profiling"`
+	Health        Health                  `yaml:"health"`
+	Logging       Logging                 `yaml:"logging"`
+	Errors        ErrorReporting          `yaml:"error_reporting"`
+	SlowRequests  SlowRequests            `yaml:"slow_requests"`
+	JWT           JWT                     `yaml:"jwt"`
+	Users         []User                  `yaml:"users"`
+	OIDC          map[string]OIDCProvider `yaml:"oidc"` // keyed by the name in the login url
+	Password      Password                `yaml:"password"`
+	LoginThrottle LoginThrottle           `yaml:"login_throttle"`
+	Live          Live                    `yaml:"live"`
+	Webhooks      Webhooks                `yaml:"webhooks"`
+	Jobs          Jobs                    `yaml:"jobs"`
+	Scheduler     Scheduler               `yaml:"scheduler"`
+	Email         Email                   `yaml:"email"`
+	Outbox        Outbox                  `yaml:"outbox"`
+	Messaging     Messaging               `yaml:"messaging"`
+	Problems      Problems                `yaml:"problem_details"`
+	APIVersions   APIVersions             `yaml:"api_versions"`
+	I18n          I18n                    `yaml:"i18n"`
+	Validation    Validation              `yaml:"validation"`
+	Decoding      Decoding                `yaml:"decoding"`
 }
 
 func MustLoad() *Config {
@@ -47,6 +562,20 @@ func MustLoad() *Config {
 	if err != nil {
 		log.Fatalf("can not read config file: %s", err.Error())
 	}
+	if cfg.AdminAuth.PasswordFile != "" {
+		secret, err := os.ReadFile(cfg.AdminAuth.PasswordFile)
+		if err != nil {
+			log.Fatalf("can not read admin password file: %s", err.Error())
+		}
+		cfg.AdminAuth.Password = strings.TrimSpace(string(secret)) // editors and echo leave a newline at the end
+	}
+	// an attempt that outlives its lease is taken over by another worker while it still runs
+	for kind, timeout := range map[string]time.Duration{"jobs": cfg.Jobs.Timeout, "imports": cfg.Imports.Timeout,
+		"webhooks": cfg.Webhooks.Timeout, "email": cfg.Email.Timeout} {
+		if timeout >= cfg.Jobs.Lease {
+			log.Fatalf("%s.timeout (%s) has to be under jobs.lease (%s)", kind, timeout, cfg.Jobs.Lease)
+		}
+	}
 
 	return &cfg
 }
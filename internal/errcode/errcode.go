@@ -0,0 +1,113 @@
+// Package errcode is the one list of machine readable error codes the api sends in the code field of every error body.
+// clients branch on these instead of the english message, so a code never changes its meaning once shipped -
+// add a new one instead. each code has the http status it is sent with
+package errcode
+
+import (
+	"net/http"
+	"strings"
+)
+
+type Code string
+
+const (
+	InvalidRequest        Code = "INVALID_REQUEST" // body or parameters can not be read
+	ValidationFailed      Code = "VALIDATION_FAILED"
+	UnknownField          Code = "UNKNOWN_FIELD"   // the body has a field the endpoint does not know, often a typo
+	Unauthenticated       Code = "UNAUTHENTICATED" // no or unusable credentials
+	InvalidCredentials    Code = "INVALID_CREDENTIALS"
+	Forbidden             Code = "FORBIDDEN"
+	NotFound              Code = "NOT_FOUND"
+	StudentNotFound       Code = "STUDENT_NOT_FOUND"
+	MethodNotAllowed      Code = "METHOD_NOT_ALLOWED"
+	Conflict              Code = "CONFLICT"
+	DuplicateEmail        Code = "DUPLICATE_EMAIL"
+	UsernameTaken         Code = "USERNAME_TAKEN"
+	IdempotencyInProgress Code = "IDEMPOTENCY_IN_PROGRESS" // the first request with this Idempotency-Key is still running
+	PayloadTooLarge       Code = "PAYLOAD_TOO_LARGE"
+	UnsupportedMediaType  Code = "UNSUPPORTED_MEDIA_TYPE"
+	RateLimited           Code = "RATE_LIMITED"
+	LoginThrottled        Code = "LOGIN_THROTTLED" // too many failed logins for the account or address
+	Internal              Code = "INTERNAL"
+	UpstreamFailed        Code = "UPSTREAM_FAILED" // a service we depend on (like an identity provider) did not answer
+	Unavailable           Code = "UNAVAILABLE"
+	Overloaded            Code = "OVERLOADED" // concurrency limit and queue are full, retry later
+	Maintenance           Code = "MAINTENANCE"
+	Timeout               Code = "TIMEOUT"
+)
+
+var statuses = map[Code]int{
+	InvalidRequest:        http.StatusBadRequest,
+	ValidationFailed:      http.StatusBadRequest,
+	UnknownField:          http.StatusBadRequest,
+	Unauthenticated:       http.StatusUnauthorized,
+	InvalidCredentials:    http.StatusUnauthorized,
+	Forbidden:             http.StatusForbidden,
+	NotFound:              http.StatusNotFound,
+	StudentNotFound:       http.StatusNotFound,
+	MethodNotAllowed:      http.StatusMethodNotAllowed,
+	Conflict:              http.StatusConflict,
+	DuplicateEmail:        http.StatusConflict,
+	UsernameTaken:         http.StatusConflict,
+	IdempotencyInProgress: http.StatusConflict,
+	PayloadTooLarge:       http.StatusRequestEntityTooLarge,
+	UnsupportedMediaType:  http.StatusUnsupportedMediaType,
+	RateLimited:           http.StatusTooManyRequests,
+	LoginThrottled:        http.StatusTooManyRequests,
+	Internal:              http.StatusInternalServerError,
+	UpstreamFailed:        http.StatusBadGateway,
+	Unavailable:           http.StatusServiceUnavailable,
+	Overloaded:            http.StatusServiceUnavailable,
+	Maintenance:           http.StatusServiceUnavailable,
+	Timeout:               http.StatusGatewayTimeout,
+}
+
+// the general code of each status, for errors written without one
+var generic = map[int]Code{
+	http.StatusBadRequest:            InvalidRequest,
+	http.StatusUnauthorized:          Unauthenticated,
+	http.StatusForbidden:             Forbidden,
+	http.StatusNotFound:              NotFound,
+	http.StatusMethodNotAllowed:      MethodNotAllowed,
+	http.StatusConflict:              Conflict,
+	http.StatusRequestEntityTooLarge: PayloadTooLarge,
+	http.StatusUnsupportedMediaType:  UnsupportedMediaType,
+	http.StatusTooManyRequests:       RateLimited,
+	http.StatusBadGateway:            UpstreamFailed,
+	http.StatusServiceUnavailable:    Unavailable,
+	http.StatusGatewayTimeout:        Timeout,
+}
+
+// Status is the http status the code is sent with, 500 for a code that is not in the list
+func (c Code) Status() int {
+	if status, ok := statuses[c]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Slug is the code in the form used in urls -> STUDENT_NOT_FOUND is student-not-found
+func (c Code) Slug() string {
+	return strings.ReplaceAll(strings.ToLower(string(c)), "_", "-")
+}
+
+// ForStatus is the general code of an error status, so an error written without a code still gets one.
+// 4xx without a code of its own are InvalidRequest, 5xx are Internal
+func ForStatus(status int) Code {
+	if code, ok := generic[status]; ok {
+		return code
+	}
+	if status >= 400 && status < 500 {
+		return InvalidRequest
+	}
+	return Internal
+}
+
+// All lists every code with its status, for the docs
+func All() map[Code]int {
+	all := make(map[Code]int, len(statuses))
+	for code, status := range statuses {
+		all[code] = status
+	}
+	return all
+}
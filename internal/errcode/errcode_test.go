@@ -0,0 +1,46 @@
+package errcode_test
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/errcode"
+)
+
+func TestCodes(t *testing.T) {
+	t.Parallel()
+
+	valid := regexp.MustCompile(`^[A-Z]+(_[A-Z]+)*$`)
+	for code, status := range errcode.All() {
+		if !valid.MatchString(string(code)) {
+			t.Errorf("code %q is not UPPER_SNAKE_CASE", code)
+		}
+		if status < 400 || status > 599 {
+			t.Errorf("code %s maps to %d, not an error status", code, status)
+		}
+		// the general code of a status has to be sent with that status
+		if generic := errcode.ForStatus(status); generic.Status() != status {
+			t.Errorf("ForStatus(%d) = %s which is sent with %d", status, generic, generic.Status())
+		}
+	}
+
+	type testCase struct {
+		status int
+		want   errcode.Code
+	}
+
+	tests := []testCase{
+		{status: http.StatusNotFound, want: errcode.NotFound},
+		{status: http.StatusTeapot, want: errcode.InvalidRequest},
+		{status: http.StatusNotImplemented, want: errcode.Internal},
+	}
+	for _, tc := range tests {
+		if got := errcode.ForStatus(tc.status); got != tc.want {
+			t.Errorf("ForStatus(%d): want %s, got %s", tc.status, tc.want, got)
+		}
+	}
+	if got := errcode.StudentNotFound.Slug(); got != "student-not-found" {
+		t.Errorf("slug: got %q", got)
+	}
+}
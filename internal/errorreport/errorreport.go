@@ -0,0 +1,101 @@
+package errorreport
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/logging"
+)
+
+// Reporter sends panics and 5xx responses to an error tracker, with the request they happened in
+type Reporter interface {
+	Panic(r *http.Request, value any)
+	ServerError(r *http.Request, status int)
+}
+
+// Nop is used while no dsn is configured
+type Nop struct{}
+
+func (Nop) Panic(*http.Request, any)       {}
+func (Nop) ServerError(*http.Request, int) {}
+
+// Sentry reports to anything that speaks the sentry protocol (sentry itself, glitchtip, self-hosted...)
+type Sentry struct{}
+
+// Setup initialises the sentry client, the returned func waits until queued events are sent, call it on shutdown.
+// without a dsn it returns Nop and nothing leaves the process
+func Setup(cfg config.ErrorReporting, environment, release string) (Reporter, func(context.Context) error, error) {
+	if cfg.DSN == "" {
+		return Nop{}, func(context.Context) error { return nil }, nil
+	}
+	err := sentry.Init(sentry.ClientOptions{
+		Dsn:              cfg.DSN,
+		Environment:      environment,
+		Release:          release,
+		SampleRate:       cfg.SampleRate,
+		AttachStacktrace: true,  // 5xx without a panic still get the stack of where they were reported
+		SendDefaultPII:   false, // sentry drops auth headers, cookies and the user ip itself, scrub does the rest
+		BeforeSend: func(event *sentry.Event, hint *sentry.EventHint) *sentry.Event {
+			return scrub(event)
+		},
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reporting: %w", err)
+	}
+	flush := func(ctx context.Context) error {
+		timeout := 5 * time.Second
+		if deadline, ok := ctx.Deadline(); ok {
+			timeout = time.Until(deadline)
+		}
+		if !sentry.Flush(timeout) {
+			return fmt.Errorf("error reporting: events still queued after %s", timeout)
+		}
+		return nil
+	}
+	return Sentry{}, flush, nil
+}
+
+func (Sentry) Panic(r *http.Request, value any) {
+	hub := hubFor(r)
+	hub.RecoverWithContext(r.Context(), value)
+}
+
+func (Sentry) ServerError(r *http.Request, status int) {
+	hub := hubFor(r)
+	hub.Scope().SetTag("status", fmt.Sprint(status))
+	hub.CaptureMessage(fmt.Sprintf("%s %s answered %d", r.Method, r.URL.Path, status))
+}
+
+// every event gets its own hub, so the request and tags of one request never end up on another one
+func hubFor(r *http.Request) *sentry.Hub {
+	hub := sentry.CurrentHub().Clone()
+	scope := hub.Scope()
+	scope.SetRequest(r)
+	if id := logging.RequestID(r.Context()); id != "" {
+		scope.SetTag("request_id", id) // to find the log lines of the request
+	}
+	return hub
+}
+
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+// scrub removes personal data before an event leaves the process -> bodies (student names, emails),
+// query strings, cookies, the user, and email addresses in messages and exception texts
+func scrub(event *sentry.Event) *sentry.Event {
+	if event.Request != nil {
+		event.Request.Data = ""
+		event.Request.QueryString = ""
+		event.Request.Cookies = ""
+	}
+	event.User = sentry.User{}
+	event.Message = emailPattern.ReplaceAllString(event.Message, "[email]")
+	for i := range event.Exception {
+		event.Exception[i].Value = emailPattern.ReplaceAllString(event.Exception[i].Value, "[email]")
+	}
+	return event
+}
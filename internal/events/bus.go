@@ -0,0 +1,47 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// Handler gets every published event, it runs in the publisher goroutine so keep it fast (hand work off to a channel or job)
+type Handler func(ctx context.Context, e Event)
+
+// Bus is a small in-process pub/sub, handlers use a type switch on the event to pick what they care about
+type Bus struct {
+	mu     sync.RWMutex
+	nextId int
+	subs   map[int]Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: map[int]Handler{}}
+}
+
+// Subscribe registers a handler, call the returned func to remove it again
+func (b *Bus) Subscribe(h Handler) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.nextId
+	b.nextId++
+	b.subs[id] = h
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs, id)
+	}
+}
+
+func (b *Bus) Publish(ctx context.Context, e Event) {
+	b.mu.RLock()
+	handlers := make([]Handler, 0, len(b.subs))
+	for _, h := range b.subs {
+		handlers = append(handlers, h)
+	}
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		h(ctx, e)
+	}
+}
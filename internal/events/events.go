@@ -0,0 +1,167 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+// names used on the wire, subscribers outside this process (webhooks, message brokers) depend on them so never rename
+const (
+	StudentCreatedType  = "student.created"
+	StudentUpdatedType  = "student.updated"
+	StudentDeletedType  = "student.deleted"
+	EnrollmentAddedType = "enrollment.added"
+	AccountLockedType   = "account.locked"
+)
+
+// Public are the events that may leave the server -> live connections and webhooks. internal ones like account.locked never do
+var Public = map[string]bool{
+	StudentCreatedType: true,
+	StudentUpdatedType: true,
+	StudentDeletedType: true,
+}
+
+// Event is anything that can go on the bus, every event is its own struct so subscribers get compile time safety
+type Event interface {
+	EventType() string
+}
+
+type StudentCreated struct {
+	StudentId  int64     `json:"student_id"`
+	Name       string    `json:"name"`
+	Email      string    `json:"email"`
+	Age        int       `json:"age"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+func (StudentCreated) EventType() string { return StudentCreatedType }
+
+// NewStudentCreated checks the required fields so a half filled event never gets published
+func NewStudentCreated(student types.Student, at time.Time) (StudentCreated, error) {
+	if student.Id <= 0 {
+		return StudentCreated{}, errors.New("student.created: student id is required")
+	}
+	if student.Email == "" {
+		return StudentCreated{}, errors.New("student.created: email is required")
+	}
+	return StudentCreated{
+		StudentId:  student.Id,
+		Name:       student.Name,
+		Email:      student.Email,
+		Age:        student.Age,
+		OccurredAt: at.UTC(),
+	}, nil
+}
+
+// StudentUpdated carries the student as it is after the update
+type StudentUpdated struct {
+	StudentId  int64     `json:"student_id"`
+	Name       string    `json:"name"`
+	Email      string    `json:"email"`
+	Age        int       `json:"age"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+func (StudentUpdated) EventType() string { return StudentUpdatedType }
+
+func NewStudentUpdated(student types.Student, at time.Time) (StudentUpdated, error) {
+	if student.Id <= 0 {
+		return StudentUpdated{}, errors.New("student.updated: student id is required")
+	}
+	return StudentUpdated{
+		StudentId:  student.Id,
+		Name:       student.Name,
+		Email:      student.Email,
+		Age:        student.Age,
+		OccurredAt: at.UTC(),
+	}, nil
+}
+
+type StudentDeleted struct {
+	StudentId  int64     `json:"student_id"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+func (StudentDeleted) EventType() string { return StudentDeletedType }
+
+func NewStudentDeleted(studentId int64, at time.Time) (StudentDeleted, error) {
+	if studentId <= 0 {
+		return StudentDeleted{}, errors.New("student.deleted: student id is required")
+	}
+	return StudentDeleted{StudentId: studentId, OccurredAt: at.UTC()}, nil
+}
+
+type EnrollmentAdded struct {
+	StudentId  int64     `json:"student_id"`
+	CourseId   int64     `json:"course_id"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+func (EnrollmentAdded) EventType() string { return EnrollmentAddedType }
+
+func NewEnrollmentAdded(studentId int64, courseId int64, at time.Time) (EnrollmentAdded, error) {
+	if studentId <= 0 || courseId <= 0 {
+		return EnrollmentAdded{}, errors.New("enrollment.added: student id and course id are required")
+	}
+	return EnrollmentAdded{StudentId: studentId, CourseId: courseId, OccurredAt: at.UTC()}, nil
+}
+
+// AccountLocked is the audit record of a login lockout, Scope is "account" (Key is the username) or "ip"
+type AccountLocked struct {
+	Scope      string    `json:"scope"`
+	Key        string    `json:"key"`
+	Failures   int       `json:"failures"`
+	Until      time.Time `json:"until"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+func (AccountLocked) EventType() string { return AccountLockedType }
+
+// envelope is the json shape of an event -> {"type":"student.created","payload":{...}}
+type envelope struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// decoders know how to turn a payload back into the right struct for each type
+var decoders = map[string]func(payload []byte) (Event, error){
+	StudentCreatedType:  decodeInto[StudentCreated],
+	StudentUpdatedType:  decodeInto[StudentUpdated],
+	StudentDeletedType:  decodeInto[StudentDeleted],
+	EnrollmentAddedType: decodeInto[EnrollmentAdded],
+	AccountLockedType:   decodeInto[AccountLocked],
+}
+
+func decodeInto[T Event](payload []byte) (Event, error) {
+	var e T
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Marshal wraps the event in its envelope
+func Marshal(e Event) ([]byte, error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(envelope{Type: e.EventType(), Payload: payload})
+}
+
+// Unmarshal reads an envelope back into the typed event
+func Unmarshal(data []byte) (Event, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, err
+	}
+	decode, ok := decoders[env.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown event type %q", env.Type)
+	}
+	return decode(env.Payload)
+}
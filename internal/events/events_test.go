@@ -0,0 +1,80 @@
+package events_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/events"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+func TestEventJSONRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	created, err := events.NewStudentCreated(types.Student{Id: 7, Name: "Asha", Email: "asha@example.com", Age: 20}, at)
+	if err != nil {
+		t.Fatalf("NewStudentCreated: %v", err)
+	}
+	updated, err := events.NewStudentUpdated(types.Student{Id: 7, Name: "Asha", Email: "asha@example.com", Age: 21}, at)
+	if err != nil {
+		t.Fatalf("NewStudentUpdated: %v", err)
+	}
+	deleted, err := events.NewStudentDeleted(7, at)
+	if err != nil {
+		t.Fatalf("NewStudentDeleted: %v", err)
+	}
+	enrolled, err := events.NewEnrollmentAdded(7, 3, at)
+	if err != nil {
+		t.Fatalf("NewEnrollmentAdded: %v", err)
+	}
+
+	type testCase struct {
+		name  string
+		event events.Event
+	}
+
+	tests := []testCase{
+		{name: "student_created", event: created},
+		{name: "student_updated", event: updated},
+		{name: "student_deleted", event: deleted},
+		{name: "enrollment_added", event: enrolled},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			data, err := events.Marshal(tc.event)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			got, err := events.Unmarshal(data)
+			if err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.event) {
+				t.Fatalf("round trip mismatch:\nwant %#v\ngot  %#v", tc.event, got)
+			}
+		})
+	}
+}
+
+func TestConstructorsRejectMissingFields(t *testing.T) {
+	t.Parallel()
+
+	if _, err := events.NewStudentCreated(types.Student{Email: "a@b.com"}, time.Now()); err == nil {
+		t.Fatal("want error for missing student id")
+	}
+	if _, err := events.NewStudentUpdated(types.Student{Name: "Asha"}, time.Now()); err == nil {
+		t.Fatal("want error for missing student id")
+	}
+	if _, err := events.NewEnrollmentAdded(1, 0, time.Now()); err == nil {
+		t.Fatal("want error for missing course id")
+	}
+	if _, err := events.Unmarshal([]byte(`{"type":"student.exploded","payload":{}}`)); err == nil {
+		t.Fatal("want error for unknown event type")
+	}
+}
@@ -0,0 +1,85 @@
+package export
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+)
+
+// ErrBudgetExceeded is returned when an export used all the rows/bytes/time it was allowed
+var ErrBudgetExceeded = errors.New("export budget exceeded")
+
+// Budget is how much one export request is allowed to use, zero means no limit for that field
+type Budget struct {
+	MaxRows     int
+	MaxBytes    int64
+	MaxDuration time.Duration
+}
+
+// Tracker counts what an export has used so far against its budget
+type Tracker struct {
+	budget   Budget
+	clock    clock.Clock
+	rows     int
+	bytes    int64
+	deadline time.Time
+	reason   string // which limit was hit -> "max_rows", "max_bytes" or "max_duration"
+}
+
+func NewTracker(budget Budget, clk clock.Clock) *Tracker {
+	t := &Tracker{budget: budget, clock: clk}
+	if budget.MaxDuration > 0 {
+		t.deadline = clk.Now().Add(budget.MaxDuration)
+	}
+	return t
+}
+
+// Allow checks if one more row of size n bytes still fits in the budget and counts it if yes.
+// the first row is always allowed so a client can never get stuck on the same continuation token
+func (t *Tracker) Allow(n int) error {
+	if t.rows > 0 {
+		switch {
+		case t.budget.MaxRows > 0 && t.rows >= t.budget.MaxRows:
+			t.reason = "max_rows"
+		case t.budget.MaxBytes > 0 && t.bytes+int64(n) > t.budget.MaxBytes:
+			t.reason = "max_bytes"
+		case !t.deadline.IsZero() && t.clock.Now().After(t.deadline):
+			t.reason = "max_duration"
+		}
+		if t.reason != "" {
+			return ErrBudgetExceeded
+		}
+	}
+	t.rows++
+	t.bytes += int64(n)
+	return nil
+}
+
+// Reason returns which limit stopped the export, empty if none
+func (t *Tracker) Reason() string {
+	return t.reason
+}
+
+// EncodeCursor makes the continuation token from the last id that was sent to the client
+func EncodeCursor(lastId int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(lastId, 10)))
+}
+
+// DecodeCursor reads the continuation token back, empty token means start from the beginning
+func DecodeCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errors.New("invalid continuation token")
+	}
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || id < 0 {
+		return 0, errors.New("invalid continuation token")
+	}
+	return id, nil
+}
@@ -0,0 +1,115 @@
+package export_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/export"
+)
+
+func TestWriterBudget(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name        string
+		budget      export.Budget
+		rows        int
+		wantRows    int
+		wantPartial bool
+		wantReason  string
+	}
+
+	tests := []testCase{
+		{
+			name:     "no_limits_writes_everything",
+			budget:   export.Budget{},
+			rows:     5,
+			wantRows: 5,
+		},
+		{
+			name:        "stops_at_max_rows",
+			budget:      export.Budget{MaxRows: 2},
+			rows:        5,
+			wantRows:    2,
+			wantPartial: true,
+			wantReason:  "max_rows",
+		},
+		{
+			name:        "stops_at_max_bytes_but_always_sends_first_row",
+			budget:      export.Budget{MaxBytes: 1},
+			rows:        3,
+			wantRows:    1,
+			wantPartial: true,
+			wantReason:  "max_bytes",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			ew := export.NewWriter(&buf, export.NewTracker(tc.budget, clock.System{}), 0)
+			if err := ew.Begin(); err != nil {
+				t.Fatalf("begin: %v", err)
+			}
+
+			var stopErr error
+			for i := 1; i <= tc.rows; i++ {
+				if err := ew.Row(int64(i), map[string]int{"id": i}); err != nil {
+					stopErr = err
+					break
+				}
+			}
+			if tc.wantPartial && !errors.Is(stopErr, export.ErrBudgetExceeded) {
+				t.Fatalf("want ErrBudgetExceeded, got %v", stopErr)
+			}
+			if err := ew.End(stopErr); err != nil {
+				t.Fatalf("end: %v", err)
+			}
+
+			var got struct {
+				Data []map[string]int `json:"data"`
+				Meta export.Meta      `json:"meta"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("output is not valid json: %v (%s)", err, buf.String())
+			}
+			if len(got.Data) != tc.wantRows || got.Meta.Rows != tc.wantRows {
+				t.Fatalf("want %d rows, got data=%d meta=%d", tc.wantRows, len(got.Data), got.Meta.Rows)
+			}
+			if got.Meta.Partial != tc.wantPartial || got.Meta.Reason != tc.wantReason {
+				t.Fatalf("want partial=%v reason=%q, got %+v", tc.wantPartial, tc.wantReason, got.Meta)
+			}
+			if tc.wantPartial {
+				lastId, err := export.DecodeCursor(got.Meta.Continuation)
+				if err != nil || lastId != int64(tc.wantRows) {
+					t.Fatalf("want continuation to last id %d, got %d (%v)", tc.wantRows, lastId, err)
+				}
+			}
+		})
+	}
+}
+
+func TestTrackerStopsWhenTimeIsUp(t *testing.T) {
+	t.Parallel()
+
+	clk := clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	tracker := export.NewTracker(export.Budget{MaxDuration: time.Second}, clk)
+
+	if err := tracker.Allow(10); err != nil {
+		t.Fatalf("first row should pass, got %v", err)
+	}
+	clk.Advance(2 * time.Second)
+	if err := tracker.Allow(10); err != export.ErrBudgetExceeded {
+		t.Fatalf("want ErrBudgetExceeded after deadline, got %v", err)
+	}
+	if tracker.Reason() != "max_duration" {
+		t.Fatalf("want reason max_duration, got %q", tracker.Reason())
+	}
+}
@@ -0,0 +1,88 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// Meta is written after the rows so the client knows if it got everything or has to continue
+type Meta struct {
+	Rows         int    `json:"rows"`
+	Partial      bool   `json:"partial"`
+	Reason       string `json:"reason,omitempty"`
+	Continuation string `json:"continuation,omitempty"`
+}
+
+// Writer streams rows as {"data":[...],"meta":{...}} one by one, so the full result is never held in memory
+type Writer struct {
+	w       io.Writer
+	tracker *Tracker
+	rows    int
+	lastId  int64
+}
+
+// afterId is where this export starts, so a continuation token is still correct when no row was written
+func NewWriter(w io.Writer, tracker *Tracker, afterId int64) *Writer {
+	return &Writer{w: w, tracker: tracker, lastId: afterId}
+}
+
+// Begin opens the json document
+func (ew *Writer) Begin() error {
+	_, err := io.WriteString(ew.w, `{"data":[`)
+	return err
+}
+
+// Row writes one row, returns ErrBudgetExceeded when the row does not fit anymore
+func (ew *Writer) Row(id int64, row any) error {
+	data, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+	if err := ew.tracker.Allow(len(data)); err != nil {
+		return err
+	}
+	if ew.rows > 0 {
+		if _, err := io.WriteString(ew.w, ","); err != nil {
+			return err
+		}
+	}
+	if _, err := ew.w.Write(data); err != nil {
+		return err
+	}
+	ew.rows++
+	ew.lastId = id
+	return nil
+}
+
+// End closes the json document. err is whatever stopped the iteration (nil when all rows were sent),
+// anything other than nil marks the result as partial and adds a continuation token
+func (ew *Writer) End(err error) error {
+	meta := Meta{Rows: ew.rows}
+	if err != nil {
+		meta.Partial = true
+		switch {
+		case errors.Is(err, ErrBudgetExceeded):
+			meta.Reason = ew.tracker.Reason()
+		case errors.Is(err, context.DeadlineExceeded):
+			meta.Reason = "max_duration"
+		default:
+			meta.Reason = "error"
+		}
+		meta.Continuation = EncodeCursor(ew.lastId)
+	}
+
+	data, mErr := json.Marshal(meta)
+	if mErr != nil {
+		return mErr
+	}
+	if _, wErr := io.WriteString(ew.w, `],"meta":`); wErr != nil {
+		return wErr
+	}
+	if _, wErr := ew.w.Write(data); wErr != nil {
+		return wErr
+	}
+	_, wErr := io.WriteString(ew.w, "}\n")
+	return wErr
+}
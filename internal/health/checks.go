@@ -0,0 +1,138 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Check is one dependency readiness depends on, like the database. Run returns nil when it is usable
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+const (
+	StatusOK      = "ok"
+	StatusFailing = "failing"
+	// not ready on purpose -> still warming up or draining for shutdown, the checks are not even run
+	StatusNotReady = "not_ready"
+)
+
+type CheckResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report is the /readyz body, one entry per check so on-call sees right away which dependency is down
+type Report struct {
+	Status    string                 `json:"status"`
+	Checks    map[string]CheckResult `json:"checks,omitempty"`
+	CheckedAt time.Time              `json:"checked_at,omitzero"` // when the checks ran, results can be up to one interval old
+}
+
+func (r Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+// Checker is where components (storage, cache, queue, webhook sink...) register their checks.
+// with an interval the checks run in the background (see Run) and probes get the last result,
+// so a load balancer probing every second does not turn into a ping storm on the dependencies
+type Checker struct {
+	readiness *Readiness
+	timeout   time.Duration
+	interval  time.Duration
+
+	mu     sync.Mutex
+	checks []Check
+	last   *Report // nil until the first refresh
+}
+
+// interval <= 0 means no caching, every Check runs all checks
+func NewChecker(readiness *Readiness, timeout, interval time.Duration) *Checker {
+	return &Checker{readiness: readiness, timeout: timeout, interval: interval}
+}
+
+// Add registers a check, storage, caches and other clients add themselves while the app is wired
+func (c *Checker) Add(check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, check)
+}
+
+// Run refreshes the results every interval until ctx is cancelled
+func (c *Checker) Run(ctx context.Context) {
+	if c.interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		c.Refresh(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check is what /readyz answers -> not ready while warming up or draining, otherwise the cached results
+func (c *Checker) Check(ctx context.Context) Report {
+	if !c.readiness.IsReady() {
+		return Report{Status: StatusNotReady}
+	}
+	if c.interval > 0 {
+		c.mu.Lock()
+		last := c.last
+		c.mu.Unlock()
+		if last != nil {
+			return *last
+		}
+	}
+	return c.Refresh(ctx) // no caching, or Run did not get to its first round yet
+}
+
+// Refresh runs all checks at once, each one gets at most timeout, and keeps the result for Check
+func (c *Checker) Refresh(ctx context.Context) Report {
+	c.mu.Lock()
+	checks := append([]Check(nil), c.checks...)
+	c.mu.Unlock()
+
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	report := Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(checks)), CheckedAt: time.Now()}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := check.Run(ctx)
+			result := CheckResult{Status: StatusOK, Duration: time.Since(start).String()}
+			if err != nil {
+				result.Status = StatusFailing
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[check.Name] = result
+			if err != nil {
+				report.Status = StatusFailing
+			}
+		}()
+	}
+	wg.Wait()
+
+	c.mu.Lock()
+	c.last = &report
+	c.mu.Unlock()
+	return report
+}
@@ -0,0 +1,87 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/health"
+)
+
+func TestChecker(t *testing.T) {
+	t.Parallel()
+
+	ok := health.Check{Name: "database", Run: func(ctx context.Context) error { return nil }}
+	broken := health.Check{Name: "redis", Run: func(ctx context.Context) error { return errors.New("connection refused") }}
+	slow := health.Check{Name: "slow", Run: func(ctx context.Context) error {
+		<-ctx.Done() // a check that hangs is cut off by the timeout
+		return ctx.Err()
+	}}
+
+	type testCase struct {
+		name       string
+		ready      bool
+		checks     []health.Check
+		wantStatus string
+		wantFailed []string
+	}
+
+	tests := []testCase{
+		{name: "all_ok", ready: true, checks: []health.Check{ok}, wantStatus: health.StatusOK},
+		{name: "one_failing", ready: true, checks: []health.Check{ok, broken}, wantStatus: health.StatusFailing, wantFailed: []string{"redis"}},
+		{name: "timeout", ready: true, checks: []health.Check{ok, slow}, wantStatus: health.StatusFailing, wantFailed: []string{"slow"}},
+		{name: "not_ready_skips_checks", ready: false, checks: []health.Check{broken}, wantStatus: health.StatusNotReady},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			readiness := &health.Readiness{}
+			readiness.SetReady(tc.ready)
+			checker := health.NewChecker(readiness, 50*time.Millisecond, 0)
+			for _, c := range tc.checks {
+				checker.Add(c)
+			}
+
+			report := checker.Check(context.Background())
+			if report.Status != tc.wantStatus {
+				t.Fatalf("want status %q, got %q", tc.wantStatus, report.Status)
+			}
+			for _, name := range tc.wantFailed {
+				if report.Checks[name].Status != health.StatusFailing || report.Checks[name].Error == "" {
+					t.Fatalf("want check %q failing with an error, got %+v", name, report.Checks[name])
+				}
+			}
+		})
+	}
+}
+
+func TestCheckerCachesResults(t *testing.T) {
+	t.Parallel()
+
+	readiness := &health.Readiness{}
+	readiness.SetReady(true)
+	checker := health.NewChecker(readiness, time.Second, time.Hour)
+	runs := 0
+	checker.Add(health.Check{Name: "database", Run: func(ctx context.Context) error {
+		runs++
+		return nil
+	}})
+
+	for range 3 {
+		if report := checker.Check(context.Background()); !report.OK() {
+			t.Fatalf("want ok, got %+v", report)
+		}
+	}
+	if runs != 1 {
+		t.Fatalf("want the check to run once and be cached after, ran %d times", runs)
+	}
+
+	checker.Refresh(context.Background())
+	if runs != 2 {
+		t.Fatalf("want Refresh to run the check again, ran %d times", runs)
+	}
+}
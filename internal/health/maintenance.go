@@ -0,0 +1,16 @@
+package health
+
+import "sync/atomic"
+
+// Maintenance is the read-only switch for migrations and backups, while it is on writes are rejected and reads keep working
+type Maintenance struct {
+	on atomic.Bool
+}
+
+func (m *Maintenance) SetEnabled(on bool) {
+	m.on.Store(on)
+}
+
+func (m *Maintenance) Enabled() bool {
+	return m.on.Load()
+}
@@ -0,0 +1,17 @@
+package health
+
+import "sync/atomic"
+
+// Readiness tells load balancers if this instance should get traffic yet.
+// it starts as not ready and is flipped once startup work (like warm-up) is done
+type Readiness struct {
+	ready atomic.Bool
+}
+
+func (r *Readiness) SetReady(ready bool) {
+	r.ready.Store(ready)
+}
+
+func (r *Readiness) IsReady() bool {
+	return r.ready.Load()
+}
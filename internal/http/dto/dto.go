@@ -0,0 +1,240 @@
+// Package dto is the wire format of the api. handlers never send the domain and storage types of internal/types
+// directly, they go through the constructors here -> every field a client sees is listed on purpose, with its lowercase
+// json name, and a new column in a table does not show up in the api by accident. request bodies are decoded into the
+// request types of this package too, so a client can only set the fields they list
+package dto
+
+//go:generate easyjson -build_tags easyjson -no_std_marshalers dto.go
+
+import (
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/types"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Time is sent as RFC 3339 in UTC, to the second -> "2026-10-16T08:30:00Z"
+type Time time.Time
+
+func (t Time) MarshalText() ([]byte, error) {
+	return []byte(time.Time(t).UTC().Format(time.RFC3339)), nil
+}
+
+// EncodeMsgpack writes the same text as a msgpack string, msgpack would send a TextMarshaler as bytes
+func (t Time) EncodeMsgpack(enc *msgpack.Encoder) error {
+	text, _ := t.MarshalText()
+	return enc.EncodeString(string(text))
+}
+
+func (t *Time) UnmarshalText(b []byte) error {
+	parsed, err := time.Parse(time.RFC3339, string(b))
+	*t = Time(parsed)
+	return err
+}
+
+func timePtr(t *time.Time) *Time {
+	if t == nil {
+		return nil
+	}
+	v := Time(*t)
+	return &v
+}
+
+//easyjson:json
+type Student struct {
+	ID    int64  `json:"id" xml:"id"`
+	Name  string `json:"name" xml:"name"`
+	Email string `json:"email" xml:"email"`
+	Age   int    `json:"age" xml:"age"`
+}
+
+func NewStudent(s types.Student) Student {
+	return Student{ID: s.Id, Name: s.Name, Email: s.Email, Age: s.Age}
+}
+
+// Students is a list of students, its own type so the list endpoints get a generated marshaler too (see
+// response/generated.go)
+//
+//easyjson:json
+type Students []Student
+
+// NewStudents never returns nil, an empty list is [] in json and not null
+func NewStudents(students []types.Student) Students {
+	out := make(Students, len(students))
+	for i, s := range students {
+		out[i] = NewStudent(s)
+	}
+	return out
+}
+
+type User struct {
+	ID        int64    `json:"id"`
+	Username  string   `json:"username"`
+	Roles     []string `json:"roles"`
+	CreatedAt Time     `json:"created_at"`
+}
+
+func NewUser(u types.User) User {
+	roles := u.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	return User{ID: u.Id, Username: u.Username, Roles: roles, CreatedAt: Time(u.CreatedAt)}
+}
+
+// APIKey never has the key or its hash, only the prefix people recognise their keys by
+type APIKey struct {
+	ID         int64    `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	CreatedAt  Time     `json:"created_at"`
+	LastUsedAt *Time    `json:"last_used_at,omitempty"`
+	RevokedAt  *Time    `json:"revoked_at,omitempty"`
+}
+
+func NewAPIKey(k types.APIKey) APIKey {
+	return APIKey{ID: k.Id, Name: k.Name, Prefix: k.Prefix, Scopes: k.Scopes, CreatedAt: Time(k.CreatedAt),
+		LastUsedAt: timePtr(k.LastUsedAt), RevokedAt: timePtr(k.RevokedAt)}
+}
+
+func NewAPIKeys(keys []types.APIKey) []APIKey {
+	out := make([]APIKey, len(keys))
+	for i, k := range keys {
+		out[i] = NewAPIKey(k)
+	}
+	return out
+}
+
+// Webhook has no secret, it is shown once when the webhook is created
+type Webhook struct {
+	ID        int64    `json:"id"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	CreatedAt Time     `json:"created_at"`
+	DeletedAt *Time    `json:"deleted_at,omitempty"`
+}
+
+func NewWebhook(h types.Webhook) Webhook {
+	return Webhook{ID: h.Id, URL: h.URL, Events: h.Events, CreatedAt: Time(h.CreatedAt), DeletedAt: timePtr(h.DeletedAt)}
+}
+
+func NewWebhooks(hooks []types.Webhook) []Webhook {
+	out := make([]Webhook, len(hooks))
+	for i, h := range hooks {
+		out[i] = NewWebhook(h)
+	}
+	return out
+}
+
+type WebhookDelivery struct {
+	ID            int64  `json:"id"`
+	WebhookID     int64  `json:"webhook_id"`
+	EventType     string `json:"event_type"`
+	Payload       string `json:"payload"`
+	Status        string `json:"status"` // pending, delivered or failed
+	Attempts      int    `json:"attempts"`
+	LastStatus    int    `json:"last_status,omitempty"`
+	LastError     string `json:"last_error,omitempty"`
+	CreatedAt     Time   `json:"created_at"`
+	NextAttemptAt *Time  `json:"next_attempt_at,omitempty"`
+	DeliveredAt   *Time  `json:"delivered_at,omitempty"`
+}
+
+func NewWebhookDeliveries(deliveries []types.WebhookDelivery) []WebhookDelivery {
+	out := make([]WebhookDelivery, len(deliveries))
+	for i, d := range deliveries {
+		out[i] = WebhookDelivery{ID: d.Id, WebhookID: d.WebhookId, EventType: d.EventType, Payload: d.Payload,
+			Status: d.Status, Attempts: d.Attempts, LastStatus: d.LastStatus, LastError: d.LastError,
+			CreatedAt: Time(d.CreatedAt), NextAttemptAt: timePtr(d.NextAttemptAt), DeliveredAt: timePtr(d.DeliveredAt)}
+	}
+	return out
+}
+
+// Email is one message of the outbox, without its body
+type Email struct {
+	ID        int64  `json:"id"`
+	Template  string `json:"template"`
+	To        string `json:"to"`
+	Subject   string `json:"subject"`
+	Status    string `json:"status"` // pending, sent or failed
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"last_error,omitempty"`
+	CreatedAt Time   `json:"created_at"`
+	SentAt    *Time  `json:"sent_at,omitempty"`
+}
+
+func NewEmails(emails []types.Email) []Email {
+	out := make([]Email, len(emails))
+	for i, e := range emails {
+		out[i] = Email{ID: e.Id, Template: e.Template, To: e.To, Subject: e.Subject, Status: e.Status, Attempts: e.Attempts,
+			LastError: e.LastError, CreatedAt: Time(e.CreatedAt), SentAt: timePtr(e.SentAt)}
+	}
+	return out
+}
+
+// DeadJob is a job that failed for good, RequeuedJobID is the job that runs it again once it was requeued
+type DeadJob struct {
+	ID            int64  `json:"id"`
+	JobID         int64  `json:"job_id"`
+	Kind          string `json:"kind"`
+	Payload       string `json:"payload"`
+	Attempts      int    `json:"attempts"`
+	LastError     string `json:"last_error"`
+	FailedAt      Time   `json:"failed_at"`
+	RequeuedAt    *Time  `json:"requeued_at,omitempty"`
+	RequeuedJobID int64  `json:"requeued_job_id,omitempty"`
+}
+
+func NewDeadJob(j types.DeadJob) DeadJob {
+	return DeadJob{ID: j.Id, JobID: j.JobId, Kind: j.Kind, Payload: j.Payload, Attempts: j.Attempts, LastError: j.LastError,
+		FailedAt: Time(j.FailedAt), RequeuedAt: timePtr(j.RequeuedAt), RequeuedJobID: j.RequeuedJobId}
+}
+
+func NewDeadJobs(dead []types.DeadJob) []DeadJob {
+	out := make([]DeadJob, len(dead))
+	for i, j := range dead {
+		out[i] = NewDeadJob(j)
+	}
+	return out
+}
+
+// ImportJob is the background job of an import as clients follow it on GET /jobs/{id}
+type ImportJob struct {
+	ID          int64          `json:"id"`
+	Kind        string         `json:"kind"`
+	Status      string         `json:"status"` // pending, running, done or failed
+	Format      string         `json:"format"` // csv or ndjson
+	Progress    ImportProgress `json:"progress"`
+	ETASeconds  *int64         `json:"eta_seconds,omitempty"`  // while running, at the pace so far
+	LastError   string         `json:"last_error,omitempty"`   // why the last attempt failed
+	ErrorReport string         `json:"error_report,omitempty"` // url of the failed records, once there are any
+	CreatedAt   Time           `json:"created_at"`
+	StartedAt   *Time          `json:"started_at,omitempty"`
+	FinishedAt  *Time          `json:"finished_at,omitempty"`
+}
+
+// ImportProgress counts records, Processed is Created plus Failed
+type ImportProgress struct {
+	Total     int `json:"total"`
+	Processed int `json:"processed"`
+	Created   int `json:"created"`
+	Failed    int `json:"failed"`
+	Percent   int `json:"percent"`
+}
+
+// NewImportJob is imp with its eta (nil when there is none yet) and the url of its error report
+func NewImportJob(imp types.Import, eta *time.Duration, report string) ImportJob {
+	job := ImportJob{ID: imp.JobId, Kind: types.ImportJob, Status: imp.Status, Format: imp.Format,
+		Progress:  ImportProgress{Total: imp.Total, Processed: imp.Processed, Created: imp.Created, Failed: imp.Failed},
+		LastError: imp.LastError, ErrorReport: report, CreatedAt: Time(imp.CreatedAt), StartedAt: timePtr(imp.StartedAt),
+		FinishedAt: timePtr(imp.FinishedAt)}
+	if imp.Total > 0 {
+		job.Progress.Percent = imp.Processed * 100 / imp.Total
+	}
+	if eta != nil {
+		seconds := int64(eta.Round(time.Second) / time.Second)
+		job.ETASeconds = &seconds
+	}
+	return job
+}
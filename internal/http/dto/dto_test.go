@@ -0,0 +1,93 @@
+package dto_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/http/dto"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestWireFormat(t *testing.T) {
+	t.Parallel()
+
+	created := time.Date(2026, 10, 16, 10, 30, 0, 123456789, time.FixedZone("IST", 5*3600+1800))
+
+	type testCase struct {
+		name string
+		v    any
+		want string
+	}
+
+	tests := []testCase{
+		{
+			name: "student",
+			v:    dto.NewStudent(types.Student{Id: 1, Name: "Asha", Email: "asha@example.com", Age: 21}),
+			want: `{"id":1,"name":"Asha","email":"asha@example.com","age":21}`,
+		},
+		{
+			name: "empty_list_is_not_null",
+			v:    dto.NewStudents(nil),
+			want: `[]`,
+		},
+		{
+			name: "time_in_utc_to_the_second",
+			v:    dto.NewUser(types.User{Id: 2, Username: "asha", CreatedAt: created}),
+			want: `{"id":2,"username":"asha","roles":[],"created_at":"2026-10-16T05:00:00Z"}`,
+		},
+		{
+			name: "no_secrets",
+			v:    dto.NewAPIKey(types.APIKey{Id: 3, Name: "sync", Prefix: "gs_ab", Hash: "secret-hash", Scopes: []string{"students:read"}, CreatedAt: created}),
+			want: `{"id":3,"name":"sync","prefix":"gs_ab","scopes":["students:read"],"created_at":"2026-10-16T05:00:00Z"}`,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := json.Marshal(tc.v)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Fatalf("want %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestTimeMsgPack(t *testing.T) {
+	t.Parallel()
+
+	b, err := msgpack.Marshal(dto.Time(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var got any
+	if err := msgpack.Unmarshal(b, &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got != "2026-01-02T03:04:05Z" {
+		t.Fatalf("want the rfc 3339 string, got %#v", got)
+	}
+}
+
+func TestUpdateStudentRequest(t *testing.T) {
+	t.Parallel()
+
+	var patch dto.UpdateStudentRequest
+	if err := json.Unmarshal([]byte(`{"age":22}`), &patch); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	got := patch.Apply(types.Student{Id: 1, Name: "Asha", Email: "asha@example.com", Age: 21})
+	if want := (types.Student{Id: 1, Name: "Asha", Email: "asha@example.com", Age: 22}); got != want {
+		t.Fatalf("want %+v, got %+v", want, got)
+	}
+	if patch.Empty() || !(dto.UpdateStudentRequest{}).Empty() {
+		t.Fatal("only a patch without fields is empty")
+	}
+}
@@ -0,0 +1,42 @@
+package dto
+
+import "github.com/manishtomar-cpi/go-server/internal/types"
+
+// CreateStudentRequest is the body of POST /students and PUT /students/{id} (a replace sends every field too).
+// there is no id -> ids are ours to give, and an "id" in the body is an unknown field and not silently dropped
+type CreateStudentRequest struct {
+	Name  string `json:"name" validate:"required,name"`
+	Email string `json:"email" validate:"required,email,email_domain"`
+	Age   int    `json:"age" validate:"required,gte=1,lte=100"`
+}
+
+// Student is the student the request asks for, without an id
+func (c CreateStudentRequest) Student() types.Student {
+	return types.Student{Name: c.Name, Email: c.Email, Age: c.Age}
+}
+
+// UpdateStudentRequest is the body of PATCH /students/{id}, a field that is left out (nil) keeps its value
+type UpdateStudentRequest struct {
+	Name  *string `json:"name,omitempty" validate:"omitempty,name"`
+	Email *string `json:"email,omitempty" validate:"omitempty,email,email_domain"`
+	Age   *int    `json:"age,omitempty" validate:"omitempty,gte=1,lte=100"`
+}
+
+// Empty is a patch that would change nothing
+func (u UpdateStudentRequest) Empty() bool {
+	return u.Name == nil && u.Email == nil && u.Age == nil
+}
+
+// Apply returns s with the fields of the patch set
+func (u UpdateStudentRequest) Apply(s types.Student) types.Student {
+	if u.Name != nil {
+		s.Name = *u.Name
+	}
+	if u.Email != nil {
+		s.Email = *u.Email
+	}
+	if u.Age != nil {
+		s.Age = *u.Age
+	}
+	return s
+}
@@ -0,0 +1,95 @@
+package admin
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/manishtomar-cpi/go-server/internal/anomaly"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/health"
+	"github.com/manishtomar-cpi/go-server/internal/http/middleware"
+	"github.com/manishtomar-cpi/go-server/internal/logging"
+	"github.com/manishtomar-cpi/go-server/internal/scheduler"
+	"github.com/manishtomar-cpi/go-server/internal/utills/request"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+// Config dumps the config the server is running with, so on-call can check what was really loaded.
+// this is only mounted on the admin listener, never on the public one
+func Config(cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response.OK(w, r, cfg)
+	}
+}
+
+// Anomalies is the one-call triage view for on-call -> recent dead letters, failed webhooks and slow requests
+func Anomalies(rec *anomaly.Recorder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response.OK(w, r, rec.Snapshot())
+	}
+}
+
+// InFlight shows how many api requests are running, handy to watch a drain during shutdown
+func InFlight(inFlight *middleware.InFlight) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response.OK(w, r, map[string]int64{"in_flight": inFlight.Count()})
+	}
+}
+
+// Tasks shows the periodic tasks -> schedule, next run and how the last one went
+func Tasks(s *scheduler.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response.OK(w, r, s.Statuses())
+	}
+}
+
+type maintenanceState struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// Maintenance shows if the server is in read-only mode
+func Maintenance(m *health.Maintenance) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response.OK(w, r, map[string]bool{"enabled": m.Enabled()})
+	}
+}
+
+// SetMaintenance turns read-only mode on or off at runtime -> PUT {"enabled": true}
+func SetMaintenance(m *health.Maintenance) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var state maintenanceState
+		if err := request.DecodeJSON(r, &state); err != nil || state.Enabled == nil {
+			request.WriteShapeError(w, err, `body must be {"enabled": true|false}`)
+			return
+		}
+		m.SetEnabled(*state.Enabled)
+		logging.FromContext(r.Context()).WarnContext(r.Context(), "maintenance mode changed", slog.Bool("enabled", *state.Enabled))
+		response.OK(w, r, map[string]bool{"enabled": *state.Enabled})
+	}
+}
+
+type logLevel struct {
+	Level string `json:"level"`
+}
+
+// LogLevel shows the current log level
+func LogLevel(level *slog.LevelVar) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response.OK(w, r, logLevel{Level: level.Level().String()})
+	}
+}
+
+// SetLogLevel changes the log level of the running server -> PUT {"level": "debug"}, remember to put it back
+func SetLogLevel(level *slog.LevelVar) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body logLevel
+		var l slog.Level
+		if err := request.DecodeJSON(r, &body); err != nil || l.UnmarshalText([]byte(body.Level)) != nil {
+			request.WriteShapeError(w, err, `body must be {"level": "debug|info|warn|error"}`)
+			return
+		}
+		level.Set(l)
+		logging.FromContext(r.Context()).WarnContext(r.Context(), "log level changed", slog.String("level", l.String()))
+		response.OK(w, r, logLevel{Level: l.String()})
+	}
+}
@@ -0,0 +1,94 @@
+package admin
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/http/dto"
+	"github.com/manishtomar-cpi/go-server/internal/http/handllers/storeerr"
+	"github.com/manishtomar-cpi/go-server/internal/http/router"
+	"github.com/manishtomar-cpi/go-server/internal/logging"
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+	"github.com/manishtomar-cpi/go-server/internal/utills/request"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+	"github.com/manishtomar-cpi/go-server/internal/validation"
+)
+
+type APIKeyRequest struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Scopes []string `json:"scopes" validate:"required,min=1"` // a key without scopes could do nothing
+}
+
+// CreatedAPIKey is the only response that ever carries the plain key
+type CreatedAPIKey struct {
+	dto.APIKey
+	Key string `json:"key"`
+}
+
+// CreateAPIKey makes a key -> POST {"name": "billing-sync", "scopes": ["students:read"]}.
+// the key is in the response once and can not be shown again, a lost key gets revoked and replaced
+func CreateAPIKey(store storage.APIKeyStore, clk clock.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body APIKeyRequest
+		if err := request.DecodeJSON(r, &body); err != nil {
+			request.WriteShapeError(w, err, `body must be {"name": "...", "scopes": [...]}`)
+			return
+		}
+		if err := validation.Struct(body); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.ValidationError(err.(validator.ValidationErrors)))
+			return
+		}
+
+		if err := auth.CheckScopes(body.Scopes); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			return
+		}
+
+		key, prefix, hash, err := auth.GenerateAPIKey()
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(errors.New("could not generate key")))
+			return
+		}
+		created := types.APIKey{Name: body.Name, Prefix: prefix, Hash: hash, Scopes: body.Scopes, CreatedAt: clk.Now()}
+		created.Id, err = store.CreateAPIKey(r.Context(), created)
+		if err != nil {
+			storeerr.Write(w, r, err, "create key")
+			return
+		}
+		logging.FromContext(r.Context()).WarnContext(r.Context(), "api key created", slog.Int64("id", created.Id), slog.String("name", created.Name))
+		response.Created(w, r, response.Location(r, created.Id), CreatedAPIKey{APIKey: dto.NewAPIKey(created), Key: key})
+	}
+}
+
+// APIKeys lists all keys, revoked ones too, without the keys themselves
+func APIKeys(store storage.APIKeyStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keys, err := store.ListAPIKeys(r.Context())
+		if err != nil {
+			storeerr.Write(w, r, err, "load keys")
+			return
+		}
+		response.OK(w, r, dto.NewAPIKeys(keys))
+	}
+}
+
+// RevokeAPIKey stops a key from working, the row is kept so the audit trail still shows who had it
+func RevokeAPIKey(store storage.APIKeyStore, clk clock.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := router.Int64Param(w, r, "id")
+		if !ok {
+			return
+		}
+		if err := store.RevokeAPIKey(r.Context(), id, clk.Now()); err != nil {
+			storeerr.Write(w, r, err, "revoke key")
+			return
+		}
+		logging.FromContext(r.Context()).WarnContext(r.Context(), "api key revoked", slog.Int64("id", id))
+		response.NoContent(w)
+	}
+}
@@ -0,0 +1,34 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/manishtomar-cpi/go-server/internal/http/dto"
+	"github.com/manishtomar-cpi/go-server/internal/http/handllers/storeerr"
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/utills/request"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+// EmailsQuery is the query string of Emails
+type EmailsQuery struct {
+	Status string `query:"status" validate:"omitempty,oneof=pending sent failed"`
+	Limit  int    `query:"limit" default:"50" validate:"gte=1,lte=500"`
+}
+
+// Emails is the outbox of notification emails, newest first, ?status=pending|sent|failed and ?limit= (default 50, max 500)
+func Emails(store storage.EmailStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var q EmailsQuery
+		if err := request.Query(r, &q); err != nil {
+			request.WriteError(w, err)
+			return
+		}
+		emails, err := store.ListEmails(r.Context(), q.Status, q.Limit)
+		if err != nil {
+			storeerr.Write(w, r, err, "load emails")
+			return
+		}
+		response.OK(w, r, dto.NewEmails(emails))
+	}
+}
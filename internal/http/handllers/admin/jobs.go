@@ -0,0 +1,54 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/manishtomar-cpi/go-server/internal/http/dto"
+	"github.com/manishtomar-cpi/go-server/internal/http/handllers/storeerr"
+	"github.com/manishtomar-cpi/go-server/internal/http/router"
+	"github.com/manishtomar-cpi/go-server/internal/jobs"
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/utills/request"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+// DeadJobsQuery is the query string of DeadJobs
+type DeadJobsQuery struct {
+	Kind  string `query:"kind"`
+	Limit int    `query:"limit" default:"50" validate:"gte=1,lte=500"`
+}
+
+// DeadJobs is the jobs that failed for good, newest first, ?kind=webhook.deliver|email.send|student.import and ?limit=
+// (default 50, max 500). the requeued ones stay with the id of the job that runs them again
+func DeadJobs(store storage.JobStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var q DeadJobsQuery
+		if err := request.Query(r, &q); err != nil {
+			request.WriteError(w, err)
+			return
+		}
+		dead, err := store.ListDeadJobs(r.Context(), q.Kind, q.Limit)
+		if err != nil {
+			storeerr.Write(w, r, err, "load dead jobs")
+			return
+		}
+		response.OK(w, r, dto.NewDeadJobs(dead))
+	}
+}
+
+// RequeueDeadJob runs a dead job again as a new job with fresh attempts, once the cause is fixed (the receiver is
+// back, the smtp login works again). 409 when it was requeued before
+func RequeueDeadJob(queue *jobs.Queue) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := router.Int64Param(w, r, "id")
+		if !ok {
+			return
+		}
+		dead, err := queue.Requeue(r.Context(), id)
+		if err != nil {
+			storeerr.Write(w, r, err, "requeue job")
+			return
+		}
+		response.OK(w, r, dto.NewDeadJob(dead))
+	}
+}
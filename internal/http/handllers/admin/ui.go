@@ -0,0 +1,48 @@
+package admin
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"net/http"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+// the ui files are compiled into the binary, so there is nothing extra to deploy
+//
+//go:embed ui
+var uiFiles embed.FS
+
+// UI serves the single page admin ui, mount it under /admin/ behind admin auth
+func UI() http.Handler {
+	files, err := fs.Sub(uiFiles, "ui")
+	if err != nil {
+		panic(err) // can only happen if the embed path above is wrong
+	}
+	return http.StripPrefix("/admin/", http.FileServerFS(files))
+}
+
+// uiTokenTTL is short, the ui asks for a new token whenever it needs one
+const uiTokenTTL = 5 * time.Minute
+
+// UIToken gives the admin ui a short access token with the admin role, mount it next to UI so only the basic auth login
+// of the ui gets one. the ui sends it as a bearer token, so the json api never takes the admin password itself and a
+// page on another site can not make the browser call the api with it
+func UIToken(tokens *auth.JWT, username string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, _, err := tokens.IssueFor(auth.Principal{Subject: username, Kind: "admin", Roles: []string{auth.RoleAdmin}}, uiTokenTTL)
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(errors.New("could not issue token")))
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store") // tokens must never end up in a cache
+		response.WriteJson(w, http.StatusOK, map[string]any{
+			"access_token": token,
+			"token_type":   "Bearer",
+			"expires_in":   int64(uiTokenTTL.Seconds()),
+		})
+	}
+}
@@ -0,0 +1,113 @@
+// small admin page, talks to the same json api every other client uses
+const pageSize = 20;
+let offset = 0;
+
+const el = (id) => document.getElementById(id);
+
+function showStatus(text, isError) {
+  el("status").textContent = text;
+  el("status").className = isError ? "error" : "";
+}
+
+// the api does not take the basic auth login of this page, /admin/token turns it into a short bearer token
+let token = null;
+
+async function accessToken() {
+  if (token && Date.now() < token.expires) {
+    return token.value;
+  }
+  const res = await fetch("/admin/token", { method: "POST" });
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) {
+    throw new Error(data.error || data.detail || res.statusText);
+  }
+  // renewed a little before it runs out
+  token = { value: data.access_token, expires: Date.now() + (data.expires_in - 30) * 1000 };
+  return token.value;
+}
+
+async function api(method, path, body) {
+  const res = await fetch(path, {
+    method,
+    headers: { "Content-Type": "application/json", Authorization: `Bearer ${await accessToken()}` },
+    body: body ? JSON.stringify(body) : undefined,
+  });
+  if (res.status === 401) {
+    token = null; // the server restarted with another key, or the clock jumped
+  }
+  const data = await res.json().catch(() => ({}));
+  if (!res.ok) {
+    throw new Error(data.error || data.detail || res.statusText);
+  }
+  return data;
+}
+
+async function load() {
+  try {
+    const { data: students } = await api("GET", `/api/v1/students?limit=${pageSize}&offset=${offset}`);
+    const rows = el("rows");
+    rows.replaceChildren();
+    for (const s of students) {
+      const tr = document.createElement("tr");
+      for (const value of [s.id, s.name, s.email, s.age]) {
+        const td = document.createElement("td");
+        td.textContent = value;
+        tr.appendChild(td);
+      }
+      const edit = document.createElement("button");
+      edit.textContent = "Edit";
+      edit.onclick = () => startEdit(s);
+      const td = document.createElement("td");
+      td.appendChild(edit);
+      tr.appendChild(td);
+      rows.appendChild(tr);
+    }
+    el("prev").disabled = offset === 0;
+    el("next").disabled = students.length < pageSize;
+  } catch (err) {
+    showStatus(err.message, true);
+  }
+}
+
+function startEdit(s) {
+  el("student-id").value = s.id;
+  el("name").value = s.name;
+  el("email").value = s.email;
+  el("age").value = s.age;
+  el("form-title").textContent = `Edit student ${s.id}`;
+  el("submit").textContent = "Save";
+  el("cancel").hidden = false;
+}
+
+function resetForm() {
+  el("student-form").reset();
+  el("student-id").value = "";
+  el("form-title").textContent = "Add student";
+  el("submit").textContent = "Create";
+  el("cancel").hidden = true;
+}
+
+el("student-form").onsubmit = async (e) => {
+  e.preventDefault();
+  const id = el("student-id").value;
+  const body = { name: el("name").value, email: el("email").value, age: Number(el("age").value) };
+  try {
+    if (id) {
+      await api("PUT", `/api/v1/students/${id}`, body);
+      showStatus(`student ${id} saved`);
+    } else {
+      const { data: created } = await api("POST", "/api/v1/students", body);
+      showStatus(`student ${created.id} created`);
+    }
+    resetForm();
+    load();
+  } catch (err) {
+    showStatus(err.message, true);
+  }
+};
+
+el("cancel").onclick = resetForm;
+el("prev").onclick = () => { offset = Math.max(0, offset - pageSize); load(); };
+el("next").onclick = () => { offset += pageSize; load(); };
+
+load();
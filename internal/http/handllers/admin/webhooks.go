@@ -0,0 +1,132 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/events"
+	"github.com/manishtomar-cpi/go-server/internal/http/dto"
+	"github.com/manishtomar-cpi/go-server/internal/http/handllers/storeerr"
+	"github.com/manishtomar-cpi/go-server/internal/http/router"
+	"github.com/manishtomar-cpi/go-server/internal/logging"
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+	"github.com/manishtomar-cpi/go-server/internal/utills/request"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+	"github.com/manishtomar-cpi/go-server/internal/validation"
+	"github.com/manishtomar-cpi/go-server/internal/webhook"
+)
+
+type WebhookRequest struct {
+	URL    string   `json:"url" validate:"required,url"`
+	Events []string `json:"events" validate:"required,min=1"`
+	Secret string   `json:"secret" validate:"omitempty,min=16"` // generated when empty
+}
+
+// CreatedWebhook is the only response that carries the signing secret
+type CreatedWebhook struct {
+	dto.Webhook
+	Secret string `json:"secret"`
+}
+
+// CreateWebhook registers a receiver -> POST {"url": "https://...", "events": ["student.created"]}
+func CreateWebhook(store storage.WebhookStore, clk clock.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body WebhookRequest
+		if err := request.DecodeJSON(r, &body); err != nil {
+			request.WriteShapeError(w, err, `body must be {"url": "...", "events": [...]}`)
+			return
+		}
+		if err := validation.Struct(body); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.ValidationError(err.(validator.ValidationErrors)))
+			return
+		}
+		if u, err := url.Parse(body.URL); err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(errors.New("url must be http or https")))
+			return
+		}
+		for _, name := range body.Events {
+			if !events.Public[name] {
+				response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("unknown event %q", name)))
+				return
+			}
+		}
+
+		secret := body.Secret
+		if secret == "" {
+			var err error
+			if secret, err = webhook.NewSecret(); err != nil {
+				response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(errors.New("could not generate secret")))
+				return
+			}
+		}
+		hook := types.Webhook{URL: body.URL, Secret: secret, Events: body.Events, CreatedAt: clk.Now()}
+		var err error
+		hook.Id, err = store.CreateWebhook(r.Context(), hook)
+		if err != nil {
+			storeerr.Write(w, r, err, "create webhook")
+			return
+		}
+		logging.FromContext(r.Context()).WarnContext(r.Context(), "webhook created", slog.Int64("id", hook.Id), slog.String("url", hook.URL))
+		response.Created(w, r, response.Location(r, hook.Id), CreatedWebhook{Webhook: dto.NewWebhook(hook), Secret: secret})
+	}
+}
+
+// Webhooks lists the active webhooks without their secrets
+func Webhooks(store storage.WebhookStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hooks, err := store.ListWebhooks(r.Context())
+		if err != nil {
+			storeerr.Write(w, r, err, "load webhooks")
+			return
+		}
+		response.OK(w, r, dto.NewWebhooks(hooks))
+	}
+}
+
+// DeleteWebhook stops deliveries to a webhook, its pending ones end up failed and the delivery log is kept
+func DeleteWebhook(store storage.WebhookStore, clk clock.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := router.Int64Param(w, r, "id")
+		if !ok {
+			return
+		}
+		if err := store.DeleteWebhook(r.Context(), id, clk.Now()); err != nil {
+			storeerr.Write(w, r, err, "delete webhook")
+			return
+		}
+		logging.FromContext(r.Context()).WarnContext(r.Context(), "webhook deleted", slog.Int64("id", id))
+		response.NoContent(w)
+	}
+}
+
+// DeliveriesQuery is the query string of WebhookDeliveries
+type DeliveriesQuery struct {
+	Limit int `query:"limit" default:"50" validate:"gte=1,lte=500"`
+}
+
+// WebhookDeliveries is the delivery log of one webhook, newest first, ?limit= (default 50, max 500)
+func WebhookDeliveries(store storage.WebhookStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := router.Int64Param(w, r, "id")
+		if !ok {
+			return
+		}
+		var q DeliveriesQuery
+		if err := request.Query(r, &q); err != nil {
+			request.WriteError(w, err)
+			return
+		}
+		deliveries, err := store.ListDeliveries(r.Context(), id, q.Limit)
+		if err != nil {
+			storeerr.Write(w, r, err, "load deliveries")
+			return
+		}
+		response.OK(w, r, dto.NewWebhookDeliveries(deliveries))
+	}
+}
@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+	"github.com/manishtomar-cpi/go-server/internal/auth/password"
+	"github.com/manishtomar-cpi/go-server/internal/errcode"
+	"github.com/manishtomar-cpi/go-server/internal/http/dto"
+	"github.com/manishtomar-cpi/go-server/internal/http/handllers/storeerr"
+	"github.com/manishtomar-cpi/go-server/internal/logging"
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/utills/request"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+	"github.com/manishtomar-cpi/go-server/internal/validation"
+)
+
+type LoginRequest struct {
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required"`
+	Scope    string `json:"scope"` // optional, space separated like oauth2 -> "students:read" for a read-only token
+}
+
+// length and strength of the password are checked by the password policy, not here
+type RegisterRequest struct {
+	Username string `json:"username" validate:"required,min=3,max=50,alphanum"`
+	Password string `json:"password" validate:"required"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"` // seconds
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Login checks username and password and answers with a signed access token and a refresh token -> POST {"username": "...", "password": "..."}.
+// repeated failures for an account or from an address get 429 with Retry-After until the throttle lets them try again
+func Login(users auth.CredentialChecker, tokens *auth.JWT, refresh *auth.RefreshTokens, throttle *auth.LoginThrottle) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, bindErr := request.BindJSON[LoginRequest](r)
+		if bindErr != nil {
+			bindErr.Write(w)
+			return
+		}
+
+		ip := logging.ClientIP(r.Context())
+		if wait := throttle.Wait(req.Username, ip); wait > 0 { // checked before the password, a locked account does not even get to guess
+			w.Header().Set("Retry-After", strconv.Itoa(max(int(math.Ceil(wait.Seconds())), 1)))
+			response.WriteError(w, errcode.LoginThrottled, errors.New("too many failed logins, try again later"))
+			return
+		}
+
+		p, err := users.CheckPassword(r.Context(), req.Username, req.Password)
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			throttle.Failure(r.Context(), req.Username, ip)
+			response.WriteError(w, errcode.InvalidCredentials, auth.ErrInvalidCredentials)
+			return
+		}
+		if err != nil {
+			logging.FromContext(r.Context()).ErrorContext(r.Context(), "login failed", slog.String("error", err.Error()))
+			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(errors.New("could not check credentials")))
+			return
+		}
+		throttle.Success(req.Username)
+		if req.Scope != "" {
+			if p, err = p.Narrow(strings.Fields(req.Scope)); err != nil {
+				response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+				return
+			}
+		}
+		refreshToken, err := refresh.Issue(r.Context(), *p)
+		if err != nil {
+			logging.FromContext(r.Context()).ErrorContext(r.Context(), "issue refresh token failed", slog.String("error", err.Error()))
+			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(errors.New("could not issue token")))
+			return
+		}
+		writeTokens(w, tokens, *p, refreshToken)
+	}
+}
+
+// Refresh trades a refresh token for a new access token and a new refresh token -> POST {"refresh_token": "..."}.
+// the old refresh token stops working, sending it again logs the session out everywhere
+func Refresh(tokens *auth.JWT, refresh *auth.RefreshTokens) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, ok := decodeRefresh(w, r)
+		if !ok {
+			return
+		}
+		p, refreshToken, err := refresh.Rotate(r.Context(), req.RefreshToken)
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			response.WriteJson(w, http.StatusUnauthorized, response.GeneralError(errors.New("invalid refresh token")))
+			return
+		}
+		if err != nil {
+			logging.FromContext(r.Context()).ErrorContext(r.Context(), "refresh failed", slog.String("error", err.Error()))
+			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(errors.New("could not refresh token")))
+			return
+		}
+		writeTokens(w, tokens, *p, refreshToken)
+	}
+}
+
+// Logout revokes the refresh token and every token rotated from the same login -> POST {"refresh_token": "..."}.
+// access tokens already handed out stay valid until they expire, that is why they are short
+func Logout(refresh *auth.RefreshTokens) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, ok := decodeRefresh(w, r)
+		if !ok {
+			return
+		}
+		if err := refresh.Revoke(r.Context(), req.RefreshToken); err != nil {
+			logging.FromContext(r.Context()).ErrorContext(r.Context(), "logout failed", slog.String("error", err.Error()))
+			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(errors.New("could not log out")))
+			return
+		}
+		response.NoContent(w)
+	}
+}
+
+func decodeRefresh(w http.ResponseWriter, r *http.Request) (RefreshRequest, bool) {
+	var req RefreshRequest
+	if err := request.DecodeJSON(r, &req); err != nil {
+		request.WriteShapeError(w, err, `body must be {"refresh_token": "..."}`)
+		return req, false
+	}
+	if err := validation.Struct(req); err != nil {
+		response.WriteJson(w, http.StatusBadRequest, response.ValidationError(err.(validator.ValidationErrors)))
+		return req, false
+	}
+	return req, true
+}
+
+// writeTokens sends the oauth2 token response (rfc 6749 5.1), it stays flat and outside the data envelope so oauth client libraries can read it
+func writeTokens(w http.ResponseWriter, tokens *auth.JWT, p auth.Principal, refreshToken string) {
+	token, _, err := tokens.Issue(p)
+	if err != nil {
+		response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(errors.New("could not issue token")))
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store") // tokens must never end up in a cache
+	response.WriteJson(w, http.StatusOK, TokenResponse{
+		AccessToken:  token,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(tokens.TTL().Seconds()),
+		RefreshToken: refreshToken,
+	})
+}
+
+// Register creates an account -> POST {"username": "...", "password": "..."}, then log in with it to get a token
+func Register(accounts *auth.Accounts) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, bindErr := request.BindJSON[RegisterRequest](r)
+		if bindErr != nil {
+			bindErr.Write(w)
+			return
+		}
+
+		user, err := accounts.Register(r.Context(), req.Username, req.Password)
+		if errors.Is(err, password.ErrWeakPassword) {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			return
+		}
+		if errors.Is(err, storage.ErrDuplicate) {
+			response.WriteError(w, errcode.UsernameTaken, errors.New("username is taken"))
+			return
+		}
+		if err != nil {
+			storeerr.Write(w, r, err, "create account")
+			return
+		}
+		response.Created(w, r, "", dto.NewUser(user))
+	}
+}
@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+	"github.com/manishtomar-cpi/go-server/internal/errcode"
+	"github.com/manishtomar-cpi/go-server/internal/logging"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+// the login flow keeps state, nonce and pkce verifier in a short-lived cookie between the redirect and the callback,
+// so no server side session is needed and any instance can take the callback
+const (
+	oidcCookie    = "oidc_flow"
+	oidcCookieTTL = 10 * time.Minute
+)
+
+// OIDCLogin sends the browser to the identity provider -> GET /api/v1/auth/oidc/{provider}/login
+func OIDCLogin(providers map[string]*auth.OIDC) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		provider, ok := providers[r.PathValue("provider")]
+		if !ok {
+			response.WriteJson(w, http.StatusNotFound, response.GeneralError(errors.New("unknown sign-in provider")))
+			return
+		}
+		state, nonce, verifier := randomToken(), randomToken(), randomToken()
+		url, err := provider.AuthURL(r.Context(), state, nonce, verifier)
+		if err != nil {
+			logging.FromContext(r.Context()).ErrorContext(r.Context(), "oidc login failed", slog.String("error", err.Error()))
+			response.WriteJson(w, http.StatusBadGateway, response.GeneralError(errors.New("sign-in provider is not reachable")))
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     oidcCookie,
+			Value:    state + "." + nonce + "." + verifier,
+			Path:     "/api/", // the callback can be the /api/v1 one or the deprecated unversioned one
+			MaxAge:   int(oidcCookieTTL.Seconds()),
+			HttpOnly: true,
+			Secure:   true,
+			SameSite: http.SameSiteLaxMode, // lax, the callback is a top level redirect from the provider
+		})
+		http.Redirect(w, r, url, http.StatusFound)
+	}
+}
+
+// OIDCCallback finishes the sign-in and answers with our own access and refresh token, same as Login
+func OIDCCallback(providers map[string]*auth.OIDC, tokens *auth.JWT, refresh *auth.RefreshTokens) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		provider, ok := providers[r.PathValue("provider")]
+		if !ok {
+			response.WriteJson(w, http.StatusNotFound, response.GeneralError(errors.New("unknown sign-in provider")))
+			return
+		}
+		if msg := r.URL.Query().Get("error"); msg != "" { // the user said no, or the provider refused
+			response.WriteJson(w, http.StatusUnauthorized, response.GeneralError(errors.New("sign-in failed: "+msg)))
+			return
+		}
+		cookie, err := r.Cookie(oidcCookie)
+		parts := []string{}
+		if err == nil {
+			parts = strings.Split(cookie.Value, ".")
+		}
+		// clear the flow cookie whatever happens, it is single use
+		http.SetCookie(w, &http.Cookie{Name: oidcCookie, Path: "/api/", MaxAge: -1, HttpOnly: true, Secure: true})
+		state := r.URL.Query().Get("state")
+		if len(parts) != 3 || state == "" || subtle.ConstantTimeCompare([]byte(parts[0]), []byte(state)) != 1 {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(errors.New("sign-in expired or was started somewhere else, try again")))
+			return
+		}
+
+		p, err := provider.Exchange(r.Context(), r.URL.Query().Get("code"), parts[1], parts[2])
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			logging.FromContext(r.Context()).InfoContext(r.Context(), "oidc sign-in rejected", slog.String("error", err.Error()))
+			response.WriteError(w, errcode.InvalidCredentials, auth.ErrInvalidCredentials)
+			return
+		}
+		if err != nil {
+			logging.FromContext(r.Context()).ErrorContext(r.Context(), "oidc callback failed", slog.String("error", err.Error()))
+			response.WriteJson(w, http.StatusBadGateway, response.GeneralError(errors.New("could not finish sign-in")))
+			return
+		}
+		refreshToken, err := refresh.Issue(r.Context(), *p)
+		if err != nil {
+			logging.FromContext(r.Context()).ErrorContext(r.Context(), "issue refresh token failed", slog.String("error", err.Error()))
+			response.WriteJson(w, http.StatusInternalServerError, response.GeneralError(errors.New("could not issue token")))
+			return
+		}
+		writeTokens(w, tokens, *p, refreshToken)
+	}
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	rand.Read(b) // never fails, see crypto/rand
+	return base64.RawURLEncoding.EncodeToString(b)
+}
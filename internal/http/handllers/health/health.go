@@ -0,0 +1,37 @@
+package health
+
+import (
+	"net/http"
+
+	"github.com/manishtomar-cpi/go-server/internal/buildinfo"
+	"github.com/manishtomar-cpi/go-server/internal/health"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+// Live is the liveness probe -> the process is up and serving http, nothing else is checked.
+// a failing dependency must not get the process restarted, that is what Ready is for
+func Live() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response.WriteJson(w, http.StatusOK, health.Report{Status: health.StatusOK})
+	}
+}
+
+// Ready is the readiness probe, 503 while warming up, draining or when a dependency check fails
+func Ready(checker *health.Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := checker.Check(r.Context())
+		status := http.StatusOK
+		if !report.OK() {
+			status = http.StatusServiceUnavailable
+		}
+		response.WriteJson(w, status, report)
+	}
+}
+
+// Version shows what is deployed, so bug reports can name the exact build
+func Version() http.HandlerFunc {
+	info := buildinfo.Get() // fixed for the life of the process
+	return func(w http.ResponseWriter, r *http.Request) {
+		response.OK(w, r, info)
+	}
+}
@@ -0,0 +1,91 @@
+package live
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+	"github.com/manishtomar-cpi/go-server/internal/events"
+	"github.com/manishtomar-cpi/go-server/internal/live"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+// Events streams student changes as server-sent events, for clients that can not use websockets ->
+//
+//	id: <id>
+//	event: student.created
+//	data: {"student_id":1,...}
+//
+// a client that reconnects with Last-Event-ID gets what it missed, when that is no longer known it gets one "reset"
+// event and should reload its data. ?types=student.created,student.deleted picks events, default is all of them
+func Events(hub *live.Hub, ping time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		wanted := map[string]bool{}
+		for _, name := range strings.Split(r.URL.Query().Get("types"), ",") {
+			if name = strings.TrimSpace(name); name == "" {
+				continue
+			}
+			if !events.Public[name] {
+				response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("unknown event %q", name)))
+				return
+			}
+			wanted[name] = true
+		}
+
+		lastID := r.Header.Get("Last-Event-ID")
+		if lastID == "" {
+			lastID = r.URL.Query().Get("last_event_id") // EventSource can not set headers on the first connect
+		}
+		sub, err := hub.Resume(lastID)
+		if err != nil {
+			response.WriteJson(w, http.StatusServiceUnavailable, response.GeneralError(err))
+			return
+		}
+		defer hub.Unsubscribe(sub)
+
+		rc := http.NewResponseController(w)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no") // nginx would hold events back otherwise
+		w.WriteHeader(http.StatusOK)
+		if sub.Gap() {
+			fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		p, _ := auth.PrincipalFrom(r.Context())
+		var tick <-chan time.Time
+		if ping > 0 {
+			ticker := time.NewTicker(ping)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			select {
+			case m := <-sub.Events():
+				if len(wanted) > 0 && !wanted[m.Event.EventType()] {
+					continue
+				}
+				data, err := json.Marshal(live.For(p, m.Event))
+				if err != nil {
+					return
+				}
+				fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", m.ID, m.Event.EventType(), data)
+			case <-tick:
+				fmt.Fprint(w, ": ping\n\n") // a comment, clients ignore it but proxies see traffic
+			case <-sub.Done():
+				return // shutdown or too slow, the client reconnects with its last id
+			case <-r.Context().Done():
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
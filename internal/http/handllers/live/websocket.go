@@ -0,0 +1,189 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+	"github.com/manishtomar-cpi/go-server/internal/events"
+	"github.com/manishtomar-cpi/go-server/internal/live"
+	"github.com/manishtomar-cpi/go-server/internal/logging"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+// writeTimeout bounds one write, a client that does not read is dropped instead of blocking the connection forever
+const writeTimeout = 10 * time.Second
+
+// ClientMessage is what clients send -> {"type":"subscribe","events":["student.created","student.updated"]}
+type ClientMessage struct {
+	Type   string   `json:"type"` // subscribe or unsubscribe
+	Events []string `json:"events"`
+}
+
+// ServerMessage acknowledges a ClientMessage or reports an error, events themselves are sent as their envelope
+// -> {"type":"student.created","payload":{...}}
+type ServerMessage struct {
+	Type   string   `json:"type"` // subscribed or error
+	Events []string `json:"events,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// WebSocket pushes the events a client subscribed to. the caller is authenticated once on the upgrade request,
+// ping keeps idle connections (and the proxies in between) alive, 0 turns it off
+func WebSocket(hub *live.Hub, ping time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sub, err := hub.Subscribe()
+		if err != nil { // checked before the upgrade so the client still gets a normal http error
+			response.WriteJson(w, http.StatusServiceUnavailable, response.GeneralError(err))
+			return
+		}
+		defer hub.Unsubscribe(sub)
+
+		conn, err := websocket.Accept(w, r, nil) // writes the error response itself, cross origin browsers are refused
+		if err != nil {
+			return
+		}
+		defer conn.CloseNow()
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		c := &client{conn: conn, principal: principal(r), wanted: map[string]bool{}}
+		go c.read(ctx, cancel)
+
+		var tick <-chan time.Time
+		if ping > 0 {
+			ticker := time.NewTicker(ping)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			select {
+			case m := <-sub.Events():
+				if err := c.send(ctx, m.Event); err != nil {
+					return
+				}
+			case <-tick:
+				pingCtx, cancelPing := context.WithTimeout(ctx, writeTimeout)
+				err := conn.Ping(pingCtx) // waits for the pong, the read loop is what receives it
+				cancelPing()
+				if err != nil {
+					logging.FromContext(r.Context()).InfoContext(r.Context(), "websocket ping failed", slog.String("error", err.Error()))
+					return
+				}
+			case <-sub.Done():
+				if errors.Is(sub.Err(), live.ErrClosed) {
+					conn.Close(websocket.StatusGoingAway, "server shutting down")
+				} else {
+					conn.Close(websocket.StatusPolicyViolation, sub.Err().Error())
+				}
+				return
+			case <-ctx.Done(): // client went away or sent something we could not read
+				return
+			}
+		}
+	}
+}
+
+func principal(r *http.Request) *auth.Principal {
+	p, _ := auth.PrincipalFrom(r.Context())
+	return p
+}
+
+// client is the state of one connection, the read loop changes the subscriptions while the write loop reads them
+type client struct {
+	conn      *websocket.Conn
+	principal *auth.Principal
+
+	mu     sync.Mutex
+	wanted map[string]bool
+}
+
+// read handles subscribe/unsubscribe until the client closes the connection, it also has to run for pongs to arrive
+func (c *client) read(ctx context.Context, cancel context.CancelFunc) {
+	defer cancel()
+	for {
+		_, data, err := c.conn.Read(ctx)
+		if err != nil {
+			return
+		}
+		var msg ClientMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.reply(ctx, ServerMessage{Type: "error", Error: "message must be json"})
+			continue
+		}
+		if err := c.apply(msg); err != nil {
+			c.reply(ctx, ServerMessage{Type: "error", Error: err.Error()})
+			continue
+		}
+		c.reply(ctx, ServerMessage{Type: "subscribed", Events: c.subscriptions()})
+	}
+}
+
+func (c *client) apply(msg ClientMessage) error {
+	for _, name := range msg.Events {
+		if !events.Public[name] {
+			return fmt.Errorf("unknown event %q", name)
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch msg.Type {
+	case "subscribe":
+		for _, name := range msg.Events {
+			c.wanted[name] = true
+		}
+	case "unsubscribe":
+		for _, name := range msg.Events {
+			delete(c.wanted, name)
+		}
+	default:
+		return fmt.Errorf("unknown message type %q, use subscribe or unsubscribe", msg.Type)
+	}
+	return nil
+}
+
+func (c *client) subscriptions() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := make([]string, 0, len(c.wanted))
+	for name := range c.wanted {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// send writes one event when the client subscribed to it, masked like the http responses
+func (c *client) send(ctx context.Context, e events.Event) error {
+	c.mu.Lock()
+	wanted := c.wanted[e.EventType()]
+	c.mu.Unlock()
+	if !wanted {
+		return nil
+	}
+	data, err := events.Marshal(live.For(c.principal, e))
+	if err != nil {
+		return err
+	}
+	return c.write(ctx, data)
+}
+
+func (c *client) reply(ctx context.Context, msg ServerMessage) {
+	data, _ := json.Marshal(msg)
+	c.write(ctx, data)
+}
+
+// write is safe from both loops, the library allows concurrent writers
+func (c *client) write(ctx context.Context, data []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+	return c.conn.Write(ctx, websocket.MessageText, data)
+}
@@ -0,0 +1,82 @@
+// Package storeerr is the one place that turns errors of storage calls into http answers, so every handler
+// sends the same status and code for the same failure and raw db errors never reach the client
+package storeerr
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/manishtomar-cpi/go-server/internal/errcode"
+	"github.com/manishtomar-cpi/go-server/internal/logging"
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+// codes more specific than NOT_FOUND and CONFLICT, by entity and by unique field
+var (
+	notFound = map[string]errcode.Code{
+		"student": errcode.StudentNotFound,
+	}
+	duplicate = map[string]errcode.Code{
+		"email":    errcode.DuplicateEmail,
+		"username": errcode.UsernameTaken,
+	}
+)
+
+// Map gives the code to answer err with and the error the client may see.
+// the returned error is nil for failures whose text must stay in the logs (anything the storage package does not define)
+func Map(err error) (errcode.Code, error) {
+	var missing *storage.NotFoundError
+	var dup *storage.DuplicateError
+	switch {
+	case errors.As(err, &missing):
+		if code, ok := notFound[missing.Entity]; ok {
+			return code, missing
+		}
+		return errcode.NotFound, missing
+	case errors.Is(err, storage.ErrNotFound):
+		return errcode.NotFound, storage.ErrNotFound
+	case errors.As(err, &dup):
+		if code, ok := duplicate[dup.Field]; ok {
+			return code, dup
+		}
+		return errcode.Conflict, dup
+	case errors.Is(err, storage.ErrDuplicate):
+		return errcode.Conflict, storage.ErrDuplicate
+	case errors.Is(err, storage.ErrConflict):
+		return errcode.Conflict, storage.ErrConflict
+	case errors.Is(err, context.DeadlineExceeded):
+		return errcode.Timeout, errors.New("request took too long")
+	}
+	return errcode.Internal, nil
+}
+
+// Write answers the failed storage call. action says what was tried, like "load student" -> the log gets
+// "load student failed" with the real error and the client "could not load student" when the error is not one of ours.
+// nothing is written when the client went away
+func Write(w http.ResponseWriter, r *http.Request, err error, action string) {
+	ctx := r.Context()
+	if errors.Is(err, context.Canceled) && ctx.Err() != nil {
+		logging.FromContext(ctx).InfoContext(ctx, action+" cancelled, client is gone")
+		return
+	}
+	resp := Error(ctx, err, action)
+	response.WriteJson(w, resp.Code.Status(), resp)
+}
+
+// Error is the error body Write would send, for answers that carry it next to others like one item of a bulk request
+func Error(ctx context.Context, err error, action string) response.Response {
+	code, public := Map(err)
+	if public == nil {
+		logging.FromContext(ctx).ErrorContext(ctx, action+" failed", slog.String("error", err.Error()))
+		public = errors.New("could not " + action)
+	}
+	resp := response.CodedError(code, public)
+	var dup *storage.DuplicateError
+	if errors.As(err, &dup) && dup.Field != "" { // forms put this next to the input, like a failed validation
+		resp.Fields = []response.FieldError{{Field: dup.Field, Rule: "unique", Message: dup.Field + " is already taken"}}
+	}
+	return resp
+}
@@ -0,0 +1,116 @@
+package storeerr_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/errcode"
+	"github.com/manishtomar-cpi/go-server/internal/http/handllers/storeerr"
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+)
+
+func TestMap(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name       string
+		err        error
+		wantCode   errcode.Code
+		wantPublic string // empty means the error must stay hidden
+	}
+
+	tests := []testCase{
+		{
+			name:       "missing student",
+			err:        fmt.Errorf("load: %w", &storage.NotFoundError{Entity: "student", Key: "id 5"}),
+			wantCode:   errcode.StudentNotFound,
+			wantPublic: "student with id 5: not found",
+		},
+		{
+			name:       "missing webhook",
+			err:        &storage.NotFoundError{Entity: "active webhook", Key: "id 2"},
+			wantCode:   errcode.NotFound,
+			wantPublic: "active webhook with id 2: not found",
+		},
+		{
+			name:       "taken email",
+			err:        &storage.DuplicateError{Entity: "student", Field: "email"},
+			wantCode:   errcode.DuplicateEmail,
+			wantPublic: "student with this email: already exists",
+		},
+		{
+			name:       "bare conflict",
+			err:        fmt.Errorf("student: %w", storage.ErrConflict),
+			wantCode:   errcode.Conflict,
+			wantPublic: "conflicts with stored data",
+		},
+		{
+			name:     "db error",
+			err:      errors.New("database is locked"),
+			wantCode: errcode.Internal,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			code, public := storeerr.Map(tc.err)
+			if code != tc.wantCode {
+				t.Fatalf("code: want %s, got %s", tc.wantCode, code)
+			}
+			if tc.wantPublic == "" {
+				if public != nil {
+					t.Fatalf("want the error hidden, got %q", public)
+				}
+				return
+			}
+			if public == nil || public.Error() != tc.wantPublic {
+				t.Fatalf("public error: want %q, got %v", tc.wantPublic, public)
+			}
+		})
+	}
+}
+
+func TestWriteHidesUnknownErrors(t *testing.T) {
+	t.Parallel()
+
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/students", nil)
+	storeerr.Write(rr, r, errors.New("disk I/O error at /var/lib/students.db"), "create student")
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("want 500, got %d", rr.Code)
+	}
+	if strings.Contains(rr.Body.String(), "students.db") {
+		t.Fatalf("db error leaked: %s", rr.Body.String())
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("want a single json body, got %q: %v", rr.Body.String(), err)
+	}
+	if body["error"] != "could not create student" || body["code"] != string(errcode.Internal) {
+		t.Fatalf("unexpected body: %v", body)
+	}
+}
+
+func TestWriteSkipsGoneClients(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rr := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/students", nil).WithContext(ctx)
+	storeerr.Write(rr, r, fmt.Errorf("query: %w", context.Canceled), "load students")
+
+	if rr.Body.Len() != 0 {
+		t.Fatalf("want nothing written, got %q", rr.Body.String())
+	}
+}
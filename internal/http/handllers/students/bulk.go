@@ -0,0 +1,92 @@
+package student
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/events"
+	"github.com/manishtomar-cpi/go-server/internal/http/dto"
+	"github.com/manishtomar-cpi/go-server/internal/http/handllers/storeerr"
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+	"github.com/manishtomar-cpi/go-server/internal/utills/request"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+	"github.com/manishtomar-cpi/go-server/internal/validation"
+)
+
+// MaxBulk is how many students one bulk request may carry, bigger imports go to the ndjson stream
+const MaxBulk = 100
+
+// CreateBulk adds every student of a json array on its own -> a bad or duplicate one does not stop the others.
+// the answer has one item per student in the order they were sent, 207 when some of them failed
+func CreateBulk(store storage.Storage, bus *events.Bus, clk clock.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reqs, bindErr := request.Bind[[]dto.CreateStudentRequest](r) // every student is validated on its own below
+		if bindErr != nil {
+			bindErr.Write(w)
+			return
+		}
+		if len(reqs) == 0 || len(reqs) > MaxBulk {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("send between 1 and %d students", MaxBulk)))
+			return
+		}
+
+		// validated first, the valid students then go to storage together in multi-row inserts
+		now := clk.Now()
+		invalid := map[int]response.Response{}
+		students := make([]types.Student, 0, len(reqs))
+		for i, req := range reqs {
+			if err := validation.Struct(req); err != nil {
+				var validateErrs validator.ValidationErrors
+				if errors.As(err, &validateErrs) {
+					invalid[i] = response.ValidationError(validateErrs)
+				} else {
+					invalid[i] = response.GeneralError(err)
+				}
+				continue
+			}
+			student := req.Student()
+			student.UpdatedAt = now
+			students = append(students, student)
+		}
+		var ids []int64
+		if len(students) > 0 {
+			var err error
+			// all or nothing -> when storage refuses one (a taken email) every student is tried on its own below,
+			// so only that one fails
+			if ids, err = store.CreateStudents(r.Context(), students); err != nil {
+				ids = nil
+			}
+		}
+
+		var bulk response.Bulk
+		next := 0
+		for i := range reqs {
+			if resp, bad := invalid[i]; bad {
+				bulk.Fail(i, http.StatusBadRequest, resp)
+				continue
+			}
+			student := students[next]
+			if ids != nil {
+				student.Id = ids[next]
+			}
+			next++
+			if student.Id == 0 {
+				id, err := store.CreateStudent(r.Context(), student.Name, student.Email, student.Age, now)
+				if err != nil {
+					bulk.Fail(i, http.StatusInternalServerError, storeerr.Error(r.Context(), err, "create student"))
+					continue
+				}
+				student.Id = id
+			}
+			if event, err := events.NewStudentCreated(student, now); err == nil {
+				bus.Publish(r.Context(), event)
+			}
+			bulk.OK(i, http.StatusCreated, student.Id)
+		}
+		response.WriteBulk(w, r, &bulk)
+	}
+}
@@ -0,0 +1,158 @@
+package student
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/errcode"
+	"github.com/manishtomar-cpi/go-server/internal/http/dto"
+	"github.com/manishtomar-cpi/go-server/internal/http/handllers/storeerr"
+	"github.com/manishtomar-cpi/go-server/internal/http/router"
+	"github.com/manishtomar-cpi/go-server/internal/importer"
+	"github.com/manishtomar-cpi/go-server/internal/logging"
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+	"github.com/manishtomar-cpi/go-server/internal/utills/request"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+const CSV = "text/csv"
+
+// Import takes a large upload of students as csv (a header row with name, email and age) or ndjson and stores it in
+// the background -> 202 with the job right away, Location is where GET follows its progress. nothing is stored for an
+// upload over cfg.MaxBytes (413) or one that can not be read to the end or has an unknown csv column (400)
+func Import(im *importer.Importer, cfg config.Imports) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var format string
+		switch mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType {
+		case CSV:
+			format = types.ImportCSV
+		case NDJSON:
+			format = types.ImportNDJSON
+		default:
+			response.WriteJson(w, http.StatusUnsupportedMediaType, response.GeneralError(fmt.Errorf("send the students as %s or %s", CSV, NDJSON)))
+			return
+		}
+		body := &uploadReader{r: r.Body}
+		if cfg.MaxBytes > 0 {
+			body.r = http.MaxBytesReader(w, r.Body, cfg.MaxBytes)
+		}
+
+		p, _ := auth.PrincipalFrom(r.Context()) // Require(auth.WriteStudents) in front, there always is one
+		imp, err := im.Create(r.Context(), format, body, importOwner(p))
+		if body.err != nil { // too large or the client went away, not something wrong with the records
+			request.WriteError(w, body.err)
+			return
+		}
+		var refused *importer.UploadError
+		if errors.As(err, &refused) {
+			response.WriteError(w, errcode.InvalidRequest, refused)
+			return
+		}
+		if err != nil {
+			storeerr.Write(w, r, err, "store import")
+			return
+		}
+		// /api/v1/students/imports -> /api/v1/jobs/7, the deprecated /api paths keep their prefix
+		location := strings.TrimSuffix(r.URL.Path, "/students/imports") + "/jobs/" + strconv.FormatInt(imp.JobId, 10)
+		response.Accepted(w, r, location, dto.NewImportJob(imp, nil, ""))
+	}
+}
+
+// uploadReader keeps the error reading the request body ended with, the importer only sees that the upload broke off
+type uploadReader struct {
+	r   io.Reader
+	err error
+}
+
+func (u *uploadReader) Read(p []byte) (int, error) {
+	n, err := u.r.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		u.err = err
+	}
+	return n, err
+}
+
+// importOwner is who an import belongs to, one user or api key whatever it calls from
+func importOwner(p *auth.Principal) string {
+	return p.Kind + ":" + p.Subject
+}
+
+// ownImport loads the import of a job for its uploader or an admin. for anyone else it is not found, like a job that
+// does not exist, so guessing job ids tells nothing about the imports of others
+func ownImport(r *http.Request, store storage.ImportStore, jobId int64) (types.Import, error) {
+	imp, err := store.ImportByJob(r.Context(), jobId)
+	if err != nil {
+		return types.Import{}, err
+	}
+	p, _ := auth.PrincipalFrom(r.Context())
+	if p == nil || (imp.CreatedBy != importOwner(p) && !slices.Contains(p.Roles, auth.RoleAdmin)) {
+		return types.Import{}, &storage.NotFoundError{Entity: "import", Key: fmt.Sprintf("job %d", jobId)}
+	}
+	return imp, nil
+}
+
+// Job is how the import of a job id that Import answered with stands, with an eta while it runs and a link to the
+// error report once records failed. jobs of other kinds are internal and not found here, and so are the imports of
+// other callers unless an admin asks
+func Job(store storage.ImportStore, clk clock.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := router.Int64Param(w, r, "id")
+		if !ok {
+			return
+		}
+		imp, err := ownImport(r, store, id)
+		if err != nil {
+			storeerr.Write(w, r, err, "load job")
+			return
+		}
+		var eta *time.Duration
+		if d, ok := importer.ETA(imp, clk.Now()); ok {
+			eta = &d
+		}
+		report := ""
+		if imp.Failed > 0 {
+			report = path.Join(r.URL.Path, "errors")
+		}
+		response.OK(w, r, dto.NewImportJob(imp, eta, report))
+	}
+}
+
+// JobErrors is the error report of an import as an ndjson download, one importer.Failure per record that was not
+// stored, in upload order. it grows while the import runs. like Job only for the uploader and admins
+func JobErrors(store storage.ImportStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := router.Int64Param(w, r, "id")
+		if !ok {
+			return
+		}
+		// the status first, a job without an import is still a 404 and not an empty download
+		imp, err := ownImport(r, store, id)
+		if err != nil {
+			storeerr.Write(w, r, err, "load job")
+			return
+		}
+		w.Header().Set("Content-Type", NDJSON)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="import-%d-errors.ndjson"`, id))
+		w.WriteHeader(http.StatusOK)
+		err = store.ImportErrors(r.Context(), imp.Id, func(line []byte) error {
+			_, err := w.Write(line)
+			return err
+		})
+		if err != nil { // the status line is out already, all that is left is to log it
+			logging.FromContext(r.Context()).ErrorContext(r.Context(), "import error report stopped", slog.Int64("job", id), slog.String("error", err.Error()))
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package student
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/events"
+	"github.com/manishtomar-cpi/go-server/internal/http/handllers/storeerr"
+	"github.com/manishtomar-cpi/go-server/internal/importer"
+	"github.com/manishtomar-cpi/go-server/internal/logging"
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+const NDJSON = "application/x-ndjson"
+
+// IngestResult is one line of the ingest answer, for one line of the upload
+type IngestResult struct {
+	Line   int                   `json:"line"`
+	Status string                `json:"status"` // created or error
+	Id     int64                 `json:"id,omitempty"`
+	Error  string                `json:"error,omitempty"`
+	Fields []response.FieldError `json:"fields,omitempty"` // which fields failed validation
+}
+
+// IngestSummary is the last line of the ingest answer
+type IngestSummary struct {
+	Received int  `json:"received"`
+	Created  int  `json:"created"`
+	Failed   int  `json:"failed"`
+	Complete bool `json:"complete"` // false when the upload could not be read to the end
+}
+
+const (
+	IngestCreated = "created"
+	IngestError   = "error"
+)
+
+// Ingest imports students sent as newline delimited json, one student per line. records are handled while the upload
+// is still coming in -> every line is validated, valid ones are stored cfg.BatchSize at a time, and the answer streams
+// one IngestResult per line (in line order, after its batch is stored) followed by {"summary": IngestSummary}.
+// a bad line never stops the import, so the client can fix and resend only the lines that failed
+func Ingest(store storage.Storage, bus *events.Bus, clk clock.Clock, cfg config.Ingest) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != NDJSON {
+			response.WriteJson(w, http.StatusUnsupportedMediaType, response.GeneralError(fmt.Errorf("send the students as %s, one per line", NDJSON)))
+			return
+		}
+
+		rc := http.NewResponseController(w)
+		rc.EnableFullDuplex() // http/1 would not let us answer before the whole upload is read, ignored where it is not needed
+		w.Header().Set("Content-Type", NDJSON)
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		in := &ingest{store: store, bus: bus, clk: clk, r: r, enc: json.NewEncoder(w), batchSize: max(cfg.BatchSize, 1)}
+		lines := bufio.NewScanner(r.Body)
+		lines.Buffer(make([]byte, 0, 4096), max(cfg.MaxLineBytes, 1024))
+		line := 0
+		for lines.Scan() {
+			line++
+			raw := bytes.TrimSpace(lines.Bytes())
+			if len(raw) == 0 {
+				continue // blank lines (a trailing newline) are not records
+			}
+			in.add(line, raw)
+			if in.full() {
+				if err := in.flush(); err != nil {
+					return // client is gone
+				}
+				rc.Flush()
+			}
+		}
+
+		complete := lines.Err() == nil
+		if !complete {
+			// line too long or the upload broke off, what came before is kept
+			in.pending = append(in.pending, IngestResult{Line: line + 1, Status: IngestError, Error: readError(lines.Err())})
+			in.summary.Failed++
+		}
+		if err := in.flush(); err != nil {
+			return
+		}
+		in.summary.Complete = complete
+		in.enc.Encode(map[string]IngestSummary{"summary": in.summary})
+		logging.FromContext(r.Context()).InfoContext(r.Context(), "students ingested",
+			slog.Int("received", in.summary.Received), slog.Int("created", in.summary.Created), slog.Int("failed", in.summary.Failed))
+	}
+}
+
+// ingest holds the lines of the batch being collected
+type ingest struct {
+	store     storage.Storage
+	bus       *events.Bus
+	clk       clock.Clock
+	r         *http.Request
+	enc       *json.Encoder
+	batchSize int
+
+	pending  []IngestResult // results of the batch in line order, ids are filled in by flush
+	students []types.Student
+	rows     []int // index in pending of every student
+	summary  IngestSummary
+}
+
+func (in *ingest) add(line int, raw []byte) {
+	in.summary.Received++
+	student, failure := importer.Decode(raw)
+	if failure != nil {
+		in.fail(IngestResult{Line: line, Error: failure.Error, Fields: failure.Fields})
+		return
+	}
+	in.rows = append(in.rows, len(in.pending))
+	in.pending = append(in.pending, IngestResult{Line: line, Status: IngestCreated})
+	student.UpdatedAt = in.clk.Now()
+	in.students = append(in.students, student)
+}
+
+func (in *ingest) fail(result IngestResult) {
+	result.Status = IngestError
+	in.pending = append(in.pending, result)
+	in.summary.Failed++
+}
+
+// full counts failed lines too, a long run of bad lines is answered without waiting for good ones
+func (in *ingest) full() bool {
+	return len(in.pending) >= in.batchSize
+}
+
+// flush stores the collected students and writes the results of the batch
+func (in *ingest) flush() error {
+	if len(in.students) > 0 {
+		ctx := in.r.Context()
+		ids, err := in.store.CreateStudents(ctx, in.students)
+		msg := "could not store student"
+		if err != nil {
+			logging.FromContext(ctx).ErrorContext(ctx, "ingest batch failed", slog.String("error", err.Error()), slog.Int("rows", len(in.students)))
+			if _, public := storeerr.Map(err); public != nil {
+				msg = "could not store student: " + public.Error() // the whole batch is rolled back, say why
+			}
+		}
+		for i, row := range in.rows {
+			if err != nil {
+				in.pending[row].Status, in.pending[row].Error = IngestError, msg
+				in.summary.Failed++
+				continue
+			}
+			in.pending[row].Id = ids[i]
+			in.summary.Created++
+			student := in.students[i]
+			student.Id = ids[i]
+			if event, err := events.NewStudentCreated(student, student.UpdatedAt); err == nil {
+				in.bus.Publish(ctx, event)
+			}
+		}
+	}
+	for _, result := range in.pending {
+		if err := in.enc.Encode(result); err != nil {
+			return err
+		}
+	}
+	in.pending, in.students, in.rows = in.pending[:0], in.students[:0], in.rows[:0]
+	return nil
+}
+
+func readError(err error) string {
+	if errors.Is(err, bufio.ErrTooLong) {
+		return "line is too long, nothing after it was read"
+	}
+	return "upload broke off: " + err.Error()
+}
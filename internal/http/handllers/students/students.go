@@ -1,57 +1,285 @@
 package student
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/events"
+	"github.com/manishtomar-cpi/go-server/internal/export"
+	"github.com/manishtomar-cpi/go-server/internal/http/dto"
+	"github.com/manishtomar-cpi/go-server/internal/http/handllers/storeerr"
+	"github.com/manishtomar-cpi/go-server/internal/http/router"
+	"github.com/manishtomar-cpi/go-server/internal/logging"
 	"github.com/manishtomar-cpi/go-server/internal/storage"
 	"github.com/manishtomar-cpi/go-server/internal/types"
+	"github.com/manishtomar-cpi/go-server/internal/utills/request"
 	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+	"github.com/manishtomar-cpi/go-server/internal/validation"
 )
 
-func Ready() http.HandlerFunc {
+func New(storage storage.Storage, bus *events.Bus, clk clock.Clock) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) { // w is response , r is request
-		w.Write([]byte("welcome to go server"))
+		// what data is comimng decode it in req, json or msgpack, and validate it. blank and too big bodies, unknown
+		// fields, content types we can not read and invalid fields all get their own answer
+		req, bindErr := request.Bind[dto.CreateStudentRequest](r)
+		if bindErr != nil {
+			bindErr.Write(w)
+			return
+		}
+		student := req.Student()
+		now := clk.Now()
+		//calling function
+		lastId, err := storage.CreateStudent(
+			r.Context(),
+			student.Name,
+			student.Email,
+			student.Age,
+			now,
+		)
+		if err != nil {
+			storeerr.Write(w, r, err, "create student")
+			return
+		}
+		logging.FromContext(r.Context()).InfoContext(r.Context(), "user created", slog.String("userId", fmt.Sprint(lastId)))
+		student.Id = lastId
+		// let subscribers (webhooks, live feeds...) know
+		if event, err := events.NewStudentCreated(student, now); err == nil {
+			bus.Publish(r.Context(), event)
+		}
+		response.Created(w, r, response.Location(r, lastId), dto.NewStudent(student))
+
 	}
 }
 
-func New(storage storage.Storage) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) { // w is response , r is request
-		var student types.Student
-		err := json.NewDecoder(r.Body).Decode(&student) // what data is comimng decode it in the student var
-		if errors.Is(err, io.EOF) {                     // if getting blank body
-			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+func GetById(store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := router.Int64Param(w, r, "id") // writes the 400 itself when id is not a number
+		if !ok {
+			return
+		}
+		student, err := store.GetStudentById(r.Context(), id)
+		if err != nil {
+			storeerr.Write(w, r, err, "load student")
+			return
+		}
+		if response.NotModified(w, r, student.UpdatedAt) {
 			return
 		}
+		response.OK(w, r, dto.NewStudent(shape(r, student)))
+	}
+}
+
+// ListQuery is the query string of List, the sort values are storage.StudentSorts
+type ListQuery struct {
+	Limit  int    `query:"limit" default:"50" validate:"gte=1,lte=500"`
+	Offset int    `query:"offset" validate:"gte=0"`
+	Sort   string `query:"sort" default:"id" validate:"oneof=id -id name -name age -age"`
+	Name   string `query:"name" validate:"max=100"` // part of the name, any case
+	MinAge int    `query:"min_age" validate:"omitempty,gte=1,lte=100"`
+	MaxAge int    `query:"max_age" validate:"omitempty,gte=1,lte=100"`
+}
 
-		//any general errro
+func init() {
+	if err := validation.RegisterStruct(ageRange, ListQuery{}); err != nil {
+		panic(err)
+	}
+}
+
+// ageRange keeps ?max_age= from being below ?min_age=, that range could only ever be empty
+func ageRange(sl validator.StructLevel) {
+	q := sl.Current().Interface().(ListQuery)
+	if q.MinAge > 0 && q.MaxAge > 0 && q.MaxAge < q.MinAge {
+		sl.ReportError(q.MaxAge, "max_age", "MaxAge", "gtefield", "min_age")
+	}
+}
+
+// List returns one page of students, ?limit= (default 50, max 500), ?offset=, ?sort=name (-name for descending)
+// and the filters ?name=, ?min_age= and ?max_age=. json, xml or csv depending on Accept. Last-Modified is the last
+// write to any student, not only the ones of the page
+func List(store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var q ListQuery
+		if err := request.Query(r, &q); err != nil {
+			request.WriteError(w, err)
+			return
+		}
+
+		// a polling client that has seen the last write gets a 304 without the page being read at all
+		changed, err := store.StudentsChangedAt(r.Context())
 		if err != nil {
-			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			storeerr.Write(w, r, err, "load students")
 			return
 		}
-		//validation of request
-		validationError := validator.New().Struct(student)
-		if validationError != nil {
-			validateErrs := validationError.(validator.ValidationErrors)
-			response.WriteJson(w, http.StatusBadRequest, response.ValidationError(validateErrs))
+		if response.NotModified(w, r, changed) {
 			return
 		}
-		//calling function
-		lastId, err := storage.CreateStudent(
-			student.Name,
-			student.Email,
-			student.Age,
-		)
-		slog.Info("user created", slog.String("userId", fmt.Sprint(lastId)))
+
+		students, err := store.ListStudents(r.Context(), storage.StudentQuery{
+			Limit: q.Limit, Offset: q.Offset, Sort: q.Sort, Name: q.Name, MinAge: q.MinAge, MaxAge: q.MaxAge,
+		})
+		if err != nil {
+			storeerr.Write(w, r, err, "load students")
+			return
+		}
+		for i := range students {
+			students[i] = shape(r, students[i])
+		}
+		response.OKPage(w, r, dto.NewStudents(students), response.Page{Limit: q.Limit, Offset: q.Offset})
+	}
+}
+
+// Update replaces name, email and age of one student
+func Update(store storage.Storage, bus *events.Bus, clk clock.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := router.Int64Param(w, r, "id")
+		if !ok {
+			return
+		}
+		req, bindErr := request.Bind[dto.CreateStudentRequest](r)
+		if bindErr != nil {
+			bindErr.Write(w)
+			return
+		}
+		student := req.Student()
+		student.Id = id // id comes from the path, the body has none
+		save(w, r, store, bus, clk, student)
+	}
+}
+
+// Patch changes only the fields the body has, -> {"age": 22} keeps name and email
+func Patch(store storage.Storage, bus *events.Bus, clk clock.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := router.Int64Param(w, r, "id")
+		if !ok {
+			return
+		}
+		req, bindErr := request.Bind[dto.UpdateStudentRequest](r)
+		if bindErr != nil {
+			bindErr.Write(w)
+			return
+		}
+		if req.Empty() {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(errors.New("send at least one of name, email and age")))
+			return
+		}
+		student, err := store.GetStudentById(r.Context(), id)
 		if err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, err)
+			storeerr.Write(w, r, err, "load student")
+			return
 		}
-		response.WriteJson(w, http.StatusCreated, map[string]int64{"id": lastId})
+		save(w, r, store, bus, clk, req.Apply(student))
+	}
+}
 
+// save writes the changed student and tells the subscribers, shared by Update and Patch
+func save(w http.ResponseWriter, r *http.Request, store storage.Storage, bus *events.Bus, clk clock.Clock, student types.Student) {
+	student.UpdatedAt = clk.Now()
+	if err := store.UpdateStudent(r.Context(), student); err != nil {
+		storeerr.Write(w, r, err, "update student")
+		return
 	}
+	if event, err := events.NewStudentUpdated(student, student.UpdatedAt); err == nil {
+		bus.Publish(r.Context(), event)
+	}
+	response.OK(w, r, dto.NewStudent(student))
+}
+
+// Delete removes one student, 204 with no body
+func Delete(store storage.Storage, bus *events.Bus, clk clock.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := router.Int64Param(w, r, "id")
+		if !ok {
+			return
+		}
+		now := clk.Now()
+		if err := store.DeleteStudent(r.Context(), id, now); err != nil {
+			storeerr.Write(w, r, err, "delete student")
+			return
+		}
+		if event, err := events.NewStudentDeleted(id, now); err == nil {
+			bus.Publish(r.Context(), event)
+		}
+		response.NoContent(w)
+	}
+}
+
+// ExportQuery is the query string of Export
+type ExportQuery struct {
+	Cursor string `query:"cursor" validate:"max=64"`
+}
+
+// streams all students as json, stops cleanly with a partial flag and a continuation token when the budget is used up.
+// client calls again with ?cursor=<continuation> to get the next part
+func Export(storage storage.Storage, budget export.Budget, clk clock.Clock) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var q ExportQuery
+		if err := request.Query(r, &q); err != nil {
+			request.WriteError(w, err)
+			return
+		}
+		afterId, err := export.DecodeCursor(q.Cursor)
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+			return
+		}
+
+		ctx := r.Context()
+		if budget.MaxDuration > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, budget.MaxDuration) // db query is cancelled when time is over
+			defer cancel()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		ew := export.NewWriter(w, export.NewTracker(budget, clk), afterId)
+		if err := ew.Begin(); err != nil {
+			return // client is gone
+		}
+		exportErr := storage.ExportStudents(ctx, afterId, func(student types.Student) error {
+			return ew.Row(student.Id, dto.NewStudent(shape(r, student)))
+		})
+		if exportErr != nil && !errors.Is(exportErr, export.ErrBudgetExceeded) {
+			logging.FromContext(r.Context()).ErrorContext(r.Context(), "student export stopped", slog.String("error", exportErr.Error()))
+		}
+		ew.End(exportErr)
+	}
+}
+
+// EmailCheck is the answer of CheckEmail
+type EmailCheck struct {
+	Email     string `json:"email"`
+	Available bool   `json:"available"`
+}
+
+// CheckEmail tells a form whether ?email= is still free before the student is sent. it is only a hint, two creates
+// can still race for the same email -> the unique index decides and the loser gets DUPLICATE_EMAIL
+func CheckEmail(store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		email := r.URL.Query().Get("email")
+		if err := validation.Var(email, "required,email"); err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GeneralError(errors.New("email must be a valid email address")))
+			return
+		}
+		taken, err := store.StudentEmailTaken(r.Context(), email)
+		if err != nil {
+			storeerr.Write(w, r, err, "check email")
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store") // the answer changes with the next create
+		response.OK(w, r, EmailCheck{Email: email, Available: !taken})
+	}
+}
+
+// shape is student as the caller may see it
+func shape(r *http.Request, student types.Student) types.Student {
+	p, _ := auth.PrincipalFrom(r.Context())
+	return auth.RedactStudent(p, student)
 }
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/errcode"
+	"github.com/manishtomar-cpi/go-server/internal/logging"
+	"github.com/manishtomar-cpi/go-server/internal/ratelimit"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+// AuthFailures counts wrong credentials per client ip. once an address used up its bucket every request from it
+// that carries credentials gets 429, right or wrong, so a blocked client learns nothing from its guesses
+type AuthFailures struct {
+	store ratelimit.Store
+	clk   clock.Clock
+
+	mu      sync.Mutex
+	blocked map[string]time.Time // ip -> blocked until, per instance, the count itself is in the store
+}
+
+func NewAuthFailures(store ratelimit.Store, clk clock.Clock) *AuthFailures {
+	return &AuthFailures{store: store, clk: clk, blocked: map[string]time.Time{}}
+}
+
+// blockedFor is how long ip is still blocked, 0 when it is not
+func (f *AuthFailures) blockedFor(ip string) time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	until, ok := f.blocked[ip]
+	if !ok {
+		return 0
+	}
+	left := until.Sub(f.clk.Now())
+	if left <= 0 {
+		delete(f.blocked, ip)
+		return 0
+	}
+	return left
+}
+
+// fail counts one wrong credential of ip and says how long it is blocked now, 0 while it still has tries left
+func (f *AuthFailures) fail(ctx context.Context, ip string) time.Duration {
+	now := f.clk.Now()
+	allowed, retryAfter, err := f.store.Allow(ctx, "auth-failure:"+ip, now)
+	if err != nil {
+		logging.FromContext(ctx).WarnContext(ctx, "failed auth store failed, not counting", slog.String("error", err.Error()))
+		return 0
+	}
+	if allowed {
+		return 0
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.blocked) >= sweepAt {
+		for key, until := range f.blocked {
+			if !until.After(now) {
+				delete(f.blocked, key)
+			}
+		}
+	}
+	f.blocked[ip] = now.Add(retryAfter)
+	return retryAfter
+}
+
+// blocked entries are only cleaned up once there are this many
+const sweepAt = 10000
+
+// Authenticate asks the authenticator who sent the request and puts the principal in the context.
+// requests without credentials go on as anonymous, wrong credentials get a 401 right here.
+// with failures set wrong credentials count against the client ip, nil turns that off.
+// use RequireAuth on routes that must not be anonymous
+func Authenticate(authenticator auth.Authenticator, failures *AuthFailures) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, err := authenticator.Authenticate(r)
+			if failures != nil && !errors.Is(err, auth.ErrNoCredentials) {
+				ip := ClientIP(r)
+				wait := failures.blockedFor(ip)
+				if wait == 0 && err != nil {
+					wait = failures.fail(r.Context(), ip)
+				}
+				if wait > 0 {
+					tooManyRequests(w, wait)
+					return
+				}
+			}
+			switch {
+			case errors.Is(err, auth.ErrNoCredentials):
+				next.ServeHTTP(w, r)
+			case err != nil:
+				logging.FromContext(r.Context()).InfoContext(r.Context(), "authentication failed", slog.String("error", err.Error()))
+				response.WriteError(w, errcode.InvalidCredentials, auth.ErrInvalidCredentials)
+			default:
+				next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
+			}
+		})
+	}
+}
+
+// RequireAuth rejects anonymous requests with 401
+func RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := auth.PrincipalFrom(r.Context()); !ok {
+			response.WriteJson(w, http.StatusUnauthorized, response.GeneralError(errUnauthorized))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
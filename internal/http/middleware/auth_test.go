@@ -0,0 +1,107 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/http/middleware"
+	"github.com/manishtomar-cpi/go-server/internal/ratelimit"
+)
+
+// attempt is one request, the token decides what the fake authenticator says
+type attempt struct {
+	ip    string
+	token string // "" no credentials, "right" a valid one, anything else is wrong
+	after time.Duration
+	want  int
+}
+
+func TestAuthenticateFailures(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name     string
+		attempts []attempt
+	}
+
+	tests := []testCase{
+		{name: "wrong_until_blocked", attempts: []attempt{
+			{ip: "203.0.113.7", token: "guess1", want: http.StatusUnauthorized},
+			{ip: "203.0.113.7", token: "guess2", want: http.StatusUnauthorized},
+			{ip: "203.0.113.7", token: "guess3", want: http.StatusTooManyRequests},
+		}},
+		{name: "blocked_right_guess_looks_the_same", attempts: []attempt{
+			{ip: "203.0.113.7", token: "guess1", want: http.StatusUnauthorized},
+			{ip: "203.0.113.7", token: "guess2", want: http.StatusUnauthorized},
+			{ip: "203.0.113.7", token: "guess3", want: http.StatusTooManyRequests},
+			{ip: "203.0.113.7", token: "right", want: http.StatusTooManyRequests},
+		}},
+		{name: "blocked_anonymous_still_passes", attempts: []attempt{
+			{ip: "203.0.113.7", token: "guess1", want: http.StatusUnauthorized},
+			{ip: "203.0.113.7", token: "guess2", want: http.StatusUnauthorized},
+			{ip: "203.0.113.7", token: "guess3", want: http.StatusTooManyRequests},
+			{ip: "203.0.113.7", want: http.StatusOK},
+		}},
+		{name: "other_ip_not_blocked", attempts: []attempt{
+			{ip: "203.0.113.7", token: "guess1", want: http.StatusUnauthorized},
+			{ip: "203.0.113.7", token: "guess2", want: http.StatusUnauthorized},
+			{ip: "203.0.113.7", token: "guess3", want: http.StatusTooManyRequests},
+			{ip: "198.51.100.9", token: "right", want: http.StatusOK},
+		}},
+		{name: "right_credentials_not_counted", attempts: []attempt{
+			{ip: "203.0.113.7", token: "right", want: http.StatusOK},
+			{ip: "203.0.113.7", token: "right", want: http.StatusOK},
+			{ip: "203.0.113.7", token: "right", want: http.StatusOK},
+			{ip: "203.0.113.7", token: "guess1", want: http.StatusUnauthorized},
+		}},
+		{name: "unblocked_after_retry_after", attempts: []attempt{
+			{ip: "203.0.113.7", token: "guess1", want: http.StatusUnauthorized},
+			{ip: "203.0.113.7", token: "guess2", want: http.StatusUnauthorized},
+			{ip: "203.0.113.7", token: "guess3", want: http.StatusTooManyRequests},
+			{ip: "203.0.113.7", token: "right", after: 10 * time.Second, want: http.StatusOK},
+		}},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			clk := clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+			authenticator := auth.AuthenticatorFunc(func(r *http.Request) (*auth.Principal, error) {
+				switch r.Header.Get("Authorization") {
+				case "":
+					return nil, auth.ErrNoCredentials
+				case "Bearer right":
+					return &auth.Principal{Subject: "asha", Kind: "user"}, nil
+				default:
+					return nil, auth.ErrInvalidCredentials
+				}
+			})
+			// two wrong tries, then one more every 10s
+			failures := middleware.NewAuthFailures(ratelimit.NewMemoryStore(ratelimit.Limits{Rate: 0.1, Burst: 2}), clk)
+			handler := middleware.Authenticate(authenticator, failures)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			for i, a := range tc.attempts {
+				clk.Advance(a.after)
+				req := httptest.NewRequest(http.MethodGet, "/api/v1/students", nil)
+				req.RemoteAddr = a.ip + ":5555"
+				if a.token != "" {
+					req.Header.Set("Authorization", "Bearer "+a.token)
+				}
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				if rec.Code != a.want {
+					t.Fatalf("attempt %d: want %d, got %d", i+1, a.want, rec.Code)
+				}
+				if rec.Code == http.StatusTooManyRequests && rec.Header().Get("Retry-After") == "" {
+					t.Fatalf("attempt %d: 429 without Retry-After", i+1)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+var errForbidden = errors.New("permission denied")
+
+// Require lets a request through only when its principal has perm -> anonymous gets 401, not allowed gets 403.
+// put it on the route so what a route needs is readable next to the route itself
+func Require(perm auth.Permission) Middleware {
+	return authorize(func(p *auth.Principal, r *http.Request) bool {
+		return p.Can(perm)
+	})
+}
+
+// RequireOwn is Require that also lets in a principal that only has own, when the student id in the path param is its own record
+func RequireOwn(perm, own auth.Permission, param string) Middleware {
+	return authorize(func(p *auth.Principal, r *http.Request) bool {
+		if p.Can(perm) {
+			return true
+		}
+		id, err := strconv.ParseInt(r.PathValue(param), 10, 64)
+		return err == nil && p.StudentID != 0 && id == p.StudentID && p.Can(own)
+	})
+}
+
+func authorize(allowed func(p *auth.Principal, r *http.Request) bool) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, ok := auth.PrincipalFrom(r.Context())
+			if !ok {
+				response.WriteJson(w, http.StatusUnauthorized, response.GeneralError(errUnauthorized))
+				return
+			}
+			if !allowed(p, r) {
+				response.WriteJson(w, http.StatusForbidden, response.GeneralError(errForbidden))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+	"github.com/manishtomar-cpi/go-server/internal/http/middleware"
+)
+
+func TestRequireOwn(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /students/{id}", middleware.RequireOwn(auth.ReadStudents, auth.ReadOwnStudent, "id")(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	teacher := &auth.Principal{Roles: []string{auth.RoleTeacher}}
+	student := &auth.Principal{Roles: []string{auth.RoleStudent}, StudentID: 7}
+	unlinked := &auth.Principal{Roles: []string{auth.RoleStudent}}
+
+	type testCase struct {
+		name       string
+		principal  *auth.Principal
+		path       string
+		wantStatus int
+	}
+
+	tests := []testCase{
+		{name: "anonymous", path: "/students/7", wantStatus: http.StatusUnauthorized},
+		{name: "teacher_any_record", principal: teacher, path: "/students/3", wantStatus: http.StatusOK},
+		{name: "student_own_record", principal: student, path: "/students/7", wantStatus: http.StatusOK},
+		{name: "student_other_record", principal: student, path: "/students/3", wantStatus: http.StatusForbidden},
+		{name: "student_without_record", principal: unlinked, path: "/students/0", wantStatus: http.StatusForbidden},
+		{name: "bad_id", principal: student, path: "/students/abc", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.principal != nil {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), tc.principal))
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("want %d, got %d", tc.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestRequire(t *testing.T) {
+	t.Parallel()
+
+	h := middleware.Require(auth.WriteStudents)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest(http.MethodPost, "/students", nil)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Roles: []string{auth.RoleStudent}}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("student write: want 403, got %d", rec.Code)
+	}
+}
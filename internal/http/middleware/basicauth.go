@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+var errUnauthorized = errors.New("authentication required")
+
+// BasicAuth protects routes with one username and password.
+// both are hashed first and compared in constant time, so the time a wrong guess takes tells nothing about the real value
+func BasicAuth(realm string, username string, password string) Middleware {
+	wantUser := sha256.Sum256([]byte(username))
+	wantPass := sha256.Sum256([]byte(password))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, pass, ok := r.BasicAuth()
+			gotUser := sha256.Sum256([]byte(user))
+			gotPass := sha256.Sum256([]byte(pass))
+			userOk := subtle.ConstantTimeCompare(gotUser[:], wantUser[:]) == 1
+			passOk := subtle.ConstantTimeCompare(gotPass[:], wantPass[:]) == 1
+
+			if !ok || !userOk || !passOk {
+				w.Header().Set("WWW-Authenticate", `Basic realm="`+realm+`", charset="UTF-8"`)
+				response.WriteJson(w, http.StatusUnauthorized, response.GeneralError(errUnauthorized))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
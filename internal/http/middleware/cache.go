@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/manishtomar-cpi/go-server/internal/utills/bufpool"
+)
+
+// Cache gives successful GET responses a strong ETag and the Cache-Control policy of the route.
+// a client sending a matching If-None-Match gets 304 without a body, so does one the handler answered 304 itself
+// (If-Modified-Since, see response.NotModified). the response is buffered to hash it, so this is for normal json
+// endpoints only, never for streams like the export
+func Cache(cacheControl string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			bw := &bufferedWriter{ResponseWriter: w, status: http.StatusOK, buf: bufpool.Get()}
+			defer bufpool.Put(bw.buf) // after the bytes went out below
+			next.ServeHTTP(bw, r)
+
+			if bw.status == http.StatusNotModified {
+				if cacheControl != "" {
+					w.Header().Set("Cache-Control", cacheControl)
+				}
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			if bw.status != http.StatusOK { // errors are neither tagged nor cached
+				w.WriteHeader(bw.status)
+				w.Write(bw.buf.Bytes())
+				return
+			}
+
+			sum := sha256.Sum256(bw.buf.Bytes())
+			etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+			h := w.Header()
+			h.Set("ETag", etag)
+			if cacheControl != "" {
+				h.Set("Cache-Control", cacheControl)
+			}
+			if etagMatches(r.Header.Get("If-None-Match"), etag) {
+				h.Del("Content-Type")
+				h.Del("Content-Length")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			w.Write(bw.buf.Bytes())
+		})
+	}
+}
+
+// etagMatches is the weak comparison If-None-Match asks for -> "*" or any listed tag, W/ prefix ignored
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedWriter holds the whole response back, headers stay on the real writer
+type bufferedWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	buf         *bytes.Buffer
+}
+
+func (bw *bufferedWriter) WriteHeader(status int) {
+	if bw.wroteHeader {
+		return
+	}
+	bw.wroteHeader = true
+	bw.status = status
+}
+
+func (bw *bufferedWriter) Write(b []byte) (int, error) {
+	bw.wroteHeader = true
+	return bw.buf.Write(b)
+}
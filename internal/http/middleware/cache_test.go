@@ -0,0 +1,88 @@
+package middleware_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/http/middleware"
+)
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+
+	h := middleware.Cache("max-age=60")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `[{"id":1}]`)
+	}))
+
+	get := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := get("/", "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" || first.Body.String() != `[{"id":1}]` {
+		t.Fatalf("first response: %d etag %q body %q", first.Code, etag, first.Body)
+	}
+	if got := first.Header().Get("Cache-Control"); got != "max-age=60" {
+		t.Fatalf("want Cache-Control max-age=60, got %q", got)
+	}
+
+	type testCase struct {
+		name        string
+		path        string
+		ifNoneMatch string
+		wantStatus  int
+	}
+
+	tests := []testCase{
+		{name: "matching_etag", path: "/", ifNoneMatch: etag, wantStatus: http.StatusNotModified},
+		{name: "weak_and_listed", path: "/", ifNoneMatch: `"other", W/` + etag, wantStatus: http.StatusNotModified},
+		{name: "star", path: "/", ifNoneMatch: "*", wantStatus: http.StatusNotModified},
+		{name: "stale_etag", path: "/", ifNoneMatch: `"other"`, wantStatus: http.StatusOK},
+		{name: "errors_are_not_tagged", path: "/missing", ifNoneMatch: "*", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rr := get(tc.path, tc.ifNoneMatch)
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("want %d, got %d", tc.wantStatus, rr.Code)
+			}
+			if rr.Code == http.StatusNotModified && rr.Body.Len() != 0 {
+				t.Fatalf("304 must not have a body")
+			}
+		})
+	}
+}
+
+func BenchmarkCache(b *testing.B) {
+	h := middleware.Cache("no-cache")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(benchPage)
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/students", nil)
+	w := &discard{header: http.Header{}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		clear(w.header)
+		h.ServeHTTP(w, r)
+	}
+}
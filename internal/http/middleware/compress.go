@@ -0,0 +1,211 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/manishtomar-cpi/go-server/internal/utills/bufpool"
+)
+
+// CompressOptions -> bodies smaller than MinSize are sent as they are (compressing them costs more than it saves),
+// and only content types in ContentTypes get compressed
+type CompressOptions struct {
+	MinSize      int
+	ContentTypes []string
+}
+
+// writers are reused between requests, allocating a new gzip writer every time is expensive
+var (
+	gzipPool = sync.Pool{New: func() any {
+		return gzip.NewWriter(io.Discard)
+	}}
+	flatePool = sync.Pool{New: func() any {
+		w, _ := flate.NewWriter(io.Discard, flate.DefaultCompression) // only fails for a bad level
+		return w
+	}}
+)
+
+// compressor is what gzip.Writer and flate.Writer have in common
+type compressor interface {
+	io.Writer
+	Reset(w io.Writer)
+	Flush() error
+	Close() error
+}
+
+// Compress gzips (or deflates) responses when the client sent Accept-Encoding for it
+func Compress(opts CompressOptions) Middleware {
+	allowed := map[string]bool{}
+	for _, ct := range opts.ContentTypes {
+		allowed[strings.ToLower(strings.TrimSpace(ct))] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding") // caches must not give a gzip body to a client that can not read it
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if encoding == "" || r.Method == http.MethodHead || LongLived(r) { // a hijacked connection has no body to compress
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{w: w, encoding: encoding, minSize: opts.MinSize, allowed: allowed, status: http.StatusOK}
+			defer cw.close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding picks gzip over deflate, and respects q=0 which means "never send me this"
+func negotiateEncoding(header string) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if v, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		accepted[name] = q > 0
+	}
+	switch {
+	case accepted["gzip"]:
+		return "gzip"
+	case accepted["deflate"]:
+		return "deflate"
+	default:
+		return ""
+	}
+}
+
+// compressWriter holds the first MinSize bytes back, once it knows the size and content type it decides
+// to either compress or pass everything through untouched
+type compressWriter struct {
+	w        http.ResponseWriter
+	encoding string
+	minSize  int
+	allowed  map[string]bool
+
+	status      int
+	wroteHeader bool // handler called WriteHeader
+	decided     bool
+	buf         *bytes.Buffer // the held back bytes, from bufpool until decide hands it back
+	comp        compressor    // nil when we decided not to compress
+}
+
+func (cw *compressWriter) Header() http.Header {
+	return cw.w.Header()
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	cw.status = status
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.decided {
+		return cw.out().Write(b)
+	}
+	if cw.buf == nil {
+		cw.buf = bufpool.Get()
+	}
+	cw.buf.Write(b)
+	if cw.buf.Len() >= cw.minSize {
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush sends what we have right away, used by streaming endpoints
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.decide(true)
+	}
+	if cw.comp != nil {
+		cw.comp.Flush()
+	}
+	if f, ok := cw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressWriter) out() io.Writer {
+	if cw.comp != nil {
+		return cw.comp
+	}
+	return cw.w
+}
+
+// decide writes the real header and the held back bytes, bigEnough is false when the body ended below MinSize
+func (cw *compressWriter) decide(bigEnough bool) error {
+	cw.decided = true
+	if bigEnough && cw.shouldCompress() {
+		h := cw.w.Header()
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length") // the length changes after compression
+		if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+			h.Set("ETag", "W/"+etag) // the compressed bytes differ, so the tag can only be a weak one now
+		}
+		if cw.encoding == "gzip" {
+			cw.comp = gzipPool.Get().(*gzip.Writer)
+		} else {
+			cw.comp = flatePool.Get().(*flate.Writer)
+		}
+		cw.comp.Reset(cw.w)
+	}
+	cw.w.WriteHeader(cw.status)
+	if cw.buf == nil { // nothing was written
+		return nil
+	}
+	_, err := cw.out().Write(cw.buf.Bytes())
+	bufpool.Put(cw.buf)
+	cw.buf = nil
+	return err
+}
+
+func (cw *compressWriter) shouldCompress() bool {
+	h := cw.w.Header()
+	if h.Get("Content-Encoding") != "" { // handler already encoded the body itself
+		return false
+	}
+	if cw.status < 200 || cw.status == http.StatusNoContent || cw.status == http.StatusNotModified {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return cw.allowed[mediaType]
+}
+
+// close finishes the response and puts the compressor back in its pool
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		cw.decide(false)
+	}
+	if cw.comp == nil {
+		return
+	}
+	cw.comp.Close()
+	cw.comp.Reset(io.Discard) // do not keep the response writer alive from the pool
+	switch c := cw.comp.(type) {
+	case *gzip.Writer:
+		gzipPool.Put(c)
+	case *flate.Writer:
+		flatePool.Put(c)
+	}
+}
@@ -0,0 +1,101 @@
+package middleware_test
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/http/middleware"
+)
+
+func TestCompress(t *testing.T) {
+	t.Parallel()
+
+	big := strings.Repeat(`{"name":"student"},`, 200)
+
+	type testCase struct {
+		name           string
+		acceptEncoding string
+		contentType    string
+		body           string
+		wantEncoding   string
+	}
+
+	tests := []testCase{
+		{name: "gzips_big_json", acceptEncoding: "gzip, deflate", contentType: "application/json", body: big, wantEncoding: "gzip"},
+		{name: "deflate_when_gzip_refused", acceptEncoding: "gzip;q=0, deflate", contentType: "application/json", body: big, wantEncoding: "deflate"},
+		{name: "small_body_not_compressed", acceptEncoding: "gzip", contentType: "application/json", body: `{"id":1}`},
+		{name: "content_type_not_allowed", acceptEncoding: "gzip", contentType: "image/png", body: big},
+		{name: "client_without_accept_encoding", contentType: "application/json", body: big},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := middleware.Compress(middleware.CompressOptions{MinSize: 256, ContentTypes: []string{"application/json"}})(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", tc.contentType)
+					w.WriteHeader(http.StatusOK)
+					io.WriteString(w, tc.body)
+				}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			if got := rr.Header().Get("Content-Encoding"); got != tc.wantEncoding {
+				t.Fatalf("want Content-Encoding %q, got %q", tc.wantEncoding, got)
+			}
+
+			body := rr.Body.String()
+			if tc.wantEncoding == "gzip" {
+				zr, err := gzip.NewReader(rr.Body)
+				if err != nil {
+					t.Fatalf("body is not gzip: %v", err)
+				}
+				raw, _ := io.ReadAll(zr)
+				body = string(raw)
+			}
+			if tc.wantEncoding != "deflate" && body != tc.body {
+				t.Fatalf("body mismatch after decoding")
+			}
+		})
+	}
+}
+
+// discard is a ResponseWriter that throws the answer away, with a recorder its own buffer would be most of what a
+// benchmark measures
+type discard struct{ header http.Header }
+
+func (d *discard) Header() http.Header         { return d.header }
+func (d *discard) Write(b []byte) (int, error) { return len(b), nil }
+func (d *discard) WriteHeader(int)             {}
+
+// a 50 student page, the size of a default list
+var benchPage = []byte(strings.Repeat(`{"id":1,"name":"Asha","email":"asha@example.com","age":21},`, 50))
+
+func BenchmarkCompress(b *testing.B) {
+	h := middleware.Compress(middleware.CompressOptions{MinSize: 1024, ContentTypes: []string{"application/json"}})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(benchPage)
+		}))
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/students", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := &discard{header: http.Header{}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		clear(w.header)
+		h.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+// Deprecated marks the responses of routes that are going away -> "Deprecation: true", a Link to the route that replaces it
+// (successor maps the request path to it) and, when sunset is not zero, a Sunset header with the date it stops working (RFC 8594).
+// the route itself keeps working, clients and their monitoring get the notice in every response, json bodies also in meta.warnings
+func Deprecated(successor func(path string) string, sunset time.Time) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var to string
+			if successor != nil {
+				to = successor(r.URL.Path)
+			}
+			response.Deprecate(w, to, sunset)
+			if to != "" {
+				response.Warn(w, "deprecated, use "+to)
+			} else {
+				response.Warn(w, "deprecated")
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/errcode"
+	"github.com/manishtomar-cpi/go-server/internal/idempotency"
+	"github.com/manishtomar-cpi/go-server/internal/logging"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// set on replayed responses so clients (and we, in logs) can tell a replay from a fresh one
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKey = 255
+	maxIdempotentBody = 1 << 20 // bodies are hashed in memory, the student api never gets near this
+)
+
+var (
+	errIdempotencyKey        = errors.New("Idempotency-Key must be 1 to 255 characters")
+	errIdempotencyInProgress = errors.New("a request with this Idempotency-Key is still in progress, retry later")
+	errIdempotentBodyTooBig  = errors.New("request body too large")
+)
+
+// Idempotency replays the first response to clients retrying an unsafe request with the same Idempotency-Key header.
+// entries are keyed by (caller, key, route, body hash), the caller is the principal (kind:subject) that Authenticate
+// found or else the client ip. so the same key with a different body, or from someone else, is a different request.
+// 5xx responses are not stored, the retry runs the handler again. requests without the header are not touched
+func Idempotency(store idempotency.Store, clk clock.Clock) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" || isSafeMethod(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKey {
+				response.WriteJson(w, http.StatusBadRequest, response.GeneralError(errIdempotencyKey))
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBody+1))
+			if err != nil {
+				response.WriteJson(w, http.StatusBadRequest, response.GeneralError(err))
+				return
+			}
+			if len(body) > maxIdempotentBody {
+				response.WriteJson(w, http.StatusRequestEntityTooLarge, response.GeneralError(errIdempotentBodyTooBig))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body)) // the handler still has to read it
+			sum := sha256.Sum256(body)
+			// keys are picked by clients, two callers sending the same one never see each other's response
+			storeKey := PrincipalOrIP(r) + "\x00" + key + "\x00" + RoutePattern(r.Context()) + "\x00" + hex.EncodeToString(sum[:])
+
+			ctx := r.Context()
+			stored, err := store.Begin(ctx, storeKey, clk.Now())
+			switch {
+			case errors.Is(err, idempotency.ErrInProgress):
+				response.WriteError(w, errcode.IdempotencyInProgress, errIdempotencyInProgress)
+				return
+			case err != nil: // same as the rate limiter, a broken store should not take the api down
+				logging.FromContext(ctx).WarnContext(ctx, "idempotency store failed, running request without it", slog.String("error", err.Error()))
+				next.ServeHTTP(w, r)
+				return
+			case stored != nil:
+				replay(w, stored)
+				return
+			}
+
+			rec := &recordingWriter{ResponseWriter: w}
+			defer func() {
+				// also on panic, otherwise the key stays "in progress" until it expires
+				if rec.status == 0 || rec.status >= 500 {
+					if err := store.Release(ctx, storeKey); err != nil {
+						logging.FromContext(ctx).WarnContext(ctx, "idempotency release failed", slog.String("error", err.Error()))
+					}
+					return
+				}
+				resp := idempotency.Response{Status: rec.status, Header: w.Header().Clone(), Body: rec.body.Bytes()}
+				if err := store.Complete(ctx, storeKey, resp, clk.Now()); err != nil {
+					logging.FromContext(ctx).WarnContext(ctx, "idempotency store failed to save response", slog.String("error", err.Error()))
+				}
+			}()
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+func replay(w http.ResponseWriter, stored *idempotency.Response) {
+	for name, values := range stored.Header {
+		if name == RequestIDHeader { // the retry keeps its own request id
+			continue
+		}
+		w.Header()[name] = values
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(stored.Status)
+	w.Write(stored.Body)
+}
+
+// recordingWriter passes the response through to the client and keeps a copy of it
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
+
+func (rw *recordingWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,106 @@
+package middleware_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/http/middleware"
+	"github.com/manishtomar-cpi/go-server/internal/idempotency"
+)
+
+func TestIdempotency(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name       string
+		firstUser  string // subject of the principal, empty is anonymous
+		retryUser  string
+		firstKey   string
+		retryKey   string
+		retryBody  string
+		advance    time.Duration
+		wantCalls  int
+		wantReplay bool
+	}
+
+	tests := []testCase{
+		{name: "same_key_replays", firstKey: "k1", retryKey: "k1", retryBody: `{"name":"a"}`, wantCalls: 1, wantReplay: true},
+		{name: "different_body_runs_again", firstKey: "k1", retryKey: "k1", retryBody: `{"name":"b"}`, wantCalls: 2},
+		{name: "different_key_runs_again", firstKey: "k1", retryKey: "k2", retryBody: `{"name":"a"}`, wantCalls: 2},
+		{name: "no_key_runs_again", retryBody: `{"name":"a"}`, wantCalls: 2},
+		{name: "same_user_replays", firstUser: "asha", retryUser: "asha", firstKey: "k1", retryKey: "k1", retryBody: `{"name":"a"}`, wantCalls: 1, wantReplay: true},
+		{name: "other_user_runs_again", firstUser: "asha", retryUser: "ravi", firstKey: "k1", retryKey: "k1", retryBody: `{"name":"a"}`, wantCalls: 2},
+		{name: "anonymous_after_user_runs_again", firstUser: "asha", firstKey: "k1", retryKey: "k1", retryBody: `{"name":"a"}`, wantCalls: 2},
+		{name: "expired_runs_again", firstKey: "k1", retryKey: "k1", retryBody: `{"name":"a"}`, advance: 2 * time.Hour, wantCalls: 2},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			calls := 0
+			h := middleware.Idempotency(idempotency.NewMemoryStore(time.Hour), clk)(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					calls++
+					w.WriteHeader(http.StatusCreated)
+					fmt.Fprintf(w, `{"id":%d}`, calls)
+				}))
+
+			send := func(user, key, body string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodPost, "/api/students", strings.NewReader(body))
+				if user != "" {
+					req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Subject: user, Kind: "user"}))
+				}
+				if key != "" {
+					req.Header.Set(middleware.IdempotencyKeyHeader, key)
+				}
+				rr := httptest.NewRecorder()
+				h.ServeHTTP(rr, req)
+				return rr
+			}
+
+			first := send(tc.firstUser, tc.firstKey, `{"name":"a"}`)
+			clk.Advance(tc.advance)
+			retry := send(tc.retryUser, tc.retryKey, tc.retryBody)
+
+			if calls != tc.wantCalls {
+				t.Fatalf("want %d handler calls, got %d", tc.wantCalls, calls)
+			}
+			replayed := retry.Header().Get(middleware.IdempotentReplayedHeader) == "true"
+			if replayed != tc.wantReplay {
+				t.Fatalf("want replayed %v, got %v", tc.wantReplay, replayed)
+			}
+			if tc.wantReplay && (retry.Code != first.Code || retry.Body.String() != first.Body.String()) {
+				t.Fatalf("replay differs: %d %q vs %d %q", retry.Code, retry.Body, first.Code, first.Body)
+			}
+		})
+	}
+}
+
+func TestIdempotencyServerErrorNotStored(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	h := middleware.Idempotency(idempotency.NewMemoryStore(time.Hour), clock.System{})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+
+	for range 2 {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{}"))
+		req.Header.Set(middleware.IdempotencyKeyHeader, "k")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if calls != 2 {
+		t.Fatalf("want the handler to run again after a 5xx, ran %d times", calls)
+	}
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// InFlight counts requests that are running right now, used to see how much work is left while draining on shutdown
+type InFlight struct {
+	n atomic.Int64
+}
+
+func (f *InFlight) Count() int64 {
+	return f.n.Load()
+}
+
+func (f *InFlight) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.n.Add(1)
+		defer f.n.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
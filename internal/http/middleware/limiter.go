@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/errcode"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+var errOverloaded = errors.New("server is overloaded, try again later")
+
+// Limiter caps how many requests run at the same time.
+// lower priorities can only use part of the slots, so when it gets full the low priority traffic is rejected first
+// and the last slots are always kept for admin and health checks.
+// a request that finds no free slot waits in a short queue, once the queue is full (or the wait is over) it gets 503 + Retry-After
+type Limiter struct {
+	max       int64
+	queueSize int
+	queueWait time.Duration
+
+	mu       sync.Mutex
+	inFlight int64
+	waiters  list.List // *waiter, oldest first
+}
+
+type waiter struct {
+	priority Priority
+	ready    chan struct{} // closed when release handed this waiter a slot
+	granted  bool          // guarded by mu
+}
+
+// max <= 0 means no limit, queueSize <= 0 means requests are rejected right away when full
+func NewLimiter(max, queueSize int, queueWait time.Duration) *Limiter {
+	return &Limiter{max: int64(max), queueSize: queueSize, queueWait: queueWait}
+}
+
+// Queued is how many requests wait for a slot right now, the shedder treats a long queue as overload
+func (l *Limiter) Queued() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.waiters.Len()
+}
+
+// how many slots this priority is allowed to fill
+func (l *Limiter) limitFor(p Priority) int64 {
+	var limit int64
+	switch p {
+	case PriorityHigh:
+		limit = l.max
+	case PriorityNormal:
+		limit = l.max * 8 / 10
+	default:
+		limit = l.max / 2
+	}
+	if limit < 1 {
+		limit = 1
+	}
+	return limit
+}
+
+func (l *Limiter) acquire(r *http.Request) bool {
+	p := PriorityFrom(r.Context())
+
+	l.mu.Lock()
+	if l.inFlight < l.limitFor(p) {
+		l.inFlight++
+		l.mu.Unlock()
+		return true
+	}
+	if l.waiters.Len() >= l.queueSize || l.queueWait <= 0 {
+		l.mu.Unlock()
+		return false
+	}
+	w := &waiter{priority: p, ready: make(chan struct{})}
+	elem := l.waiters.PushBack(w)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.queueWait)
+	defer timer.Stop()
+	select {
+	case <-w.ready:
+		return true
+	case <-timer.C:
+	case <-r.Context().Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if w.granted { // release picked us right when we gave up, the slot is ours now
+		return true
+	}
+	l.waiters.Remove(elem)
+	return false
+}
+
+// release frees a slot, or hands it straight to the oldest waiter whose priority may use it
+func (l *Limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	for e := l.waiters.Front(); e != nil; e = e.Next() {
+		w := e.Value.(*waiter)
+		if l.inFlight < l.limitFor(w.priority) {
+			l.inFlight++
+			w.granted = true
+			l.waiters.Remove(e)
+			close(w.ready)
+			return
+		}
+	}
+}
+
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	if l.max <= 0 {
+		return next
+	}
+	// a full queue means slots free up slower than queueWait, so that is roughly when a retry has a chance
+	retryAfter := strconv.Itoa(int(math.Max(1, math.Ceil(l.queueWait.Seconds()))))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if LongLived(r) { // would hold a slot for hours, live.Hub caps these connections on its own
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !l.acquire(r) {
+			w.Header().Set("Retry-After", retryAfter)
+			response.WriteError(w, errcode.Overloaded, errOverloaded)
+			return
+		}
+		defer l.release()
+		next.ServeHTTP(w, r)
+	})
+}
+
+type longLivedKey struct{}
+
+// WithLongLived marks a request for a route that stays open for as long as the client wants (websocket, event stream).
+// the router sets it from how the route was registered, never from what the client sent
+func WithLongLived(ctx context.Context) context.Context {
+	return context.WithValue(ctx, longLivedKey{}, true)
+}
+
+// LongLived is true for requests WithLongLived marked
+func LongLived(r *http.Request) bool {
+	longLived, _ := r.Context().Value(longLivedKey{}).(bool)
+	return longLived
+}
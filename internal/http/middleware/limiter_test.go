@@ -0,0 +1,65 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/http/middleware"
+)
+
+func TestLimiterQueue(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name       string
+		queueSize  int
+		queueWait  time.Duration
+		holdFor    time.Duration // how long the first request keeps the only slot
+		wantStatus int
+	}
+
+	tests := []testCase{
+		{name: "queued_request_gets_the_freed_slot", queueSize: 1, queueWait: time.Second, holdFor: 20 * time.Millisecond, wantStatus: http.StatusOK},
+		{name: "queue_wait_over", queueSize: 1, queueWait: 20 * time.Millisecond, holdFor: 200 * time.Millisecond, wantStatus: http.StatusServiceUnavailable},
+		{name: "no_queue_rejects_right_away", queueSize: 0, queueWait: time.Second, holdFor: 200 * time.Millisecond, wantStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			started := make(chan struct{})
+			release := make(chan struct{})
+			limiter := middleware.NewLimiter(1, tc.queueSize, tc.queueWait)
+			h := middleware.Prioritize(func(*http.Request) middleware.Priority { return middleware.PriorityHigh })(
+				limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.URL.Path == "/hold" {
+						close(started)
+						<-release
+					}
+				})))
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/hold", nil))
+			}()
+			<-started
+			time.AfterFunc(tc.holdFor, func() { close(release) })
+
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+			<-done
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("want %d, got %d", tc.wantStatus, rr.Code)
+			}
+			if rr.Code == http.StatusServiceUnavailable && rr.Header().Get("Retry-After") == "" {
+				t.Fatalf("503 without Retry-After")
+			}
+		})
+	}
+}
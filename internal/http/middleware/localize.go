@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/manishtomar-cpi/go-server/internal/i18n"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+// Localize picks the language of the error messages from Accept-Language and says it in Content-Language,
+// response.WriteJson reads it back from there. languages nobody registered get english
+func Localize() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(response.LanguageHeader, i18n.Match(r.Header.Get("Accept-Language")))
+			w.Header().Add("Vary", "Accept-Language")
+			next.ServeHTTP(w, r)
+		})
+	}
+}
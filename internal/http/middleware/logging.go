@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/logging"
+)
+
+// Logger puts a logger with the method and path of the request into its context, handlers get it with logging.FromContext
+func Logger(base *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logger := base.With(slog.String("method", r.Method), slog.String("path", r.URL.Path))
+			next.ServeHTTP(w, r.WithContext(logging.WithLogger(r.Context(), logger)))
+		})
+	}
+}
+
+// AccessLog writes one line per finished request, 5xx as error and 4xx as warn so they stand out
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx := WithRouteHolder(r.Context())
+		sw := NewStatusWriter(w)
+
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		status := sw.StatusCode()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		logging.FromContext(ctx).LogAttrs(ctx, level, "request",
+			slog.String("route", RoutePattern(ctx)),
+			slog.Int("status", status),
+			slog.Int64("bytes", sw.Bytes),
+			slog.Duration("duration", time.Since(start)),
+		)
+	})
+}
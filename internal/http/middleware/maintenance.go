@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/manishtomar-cpi/go-server/internal/errcode"
+	"github.com/manishtomar-cpi/go-server/internal/health"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+// ReadOnly rejects writes with 503 while maintenance mode is on, clients can tell it from overload by code MAINTENANCE.
+// allow are paths that use POST without writing anything, like the login
+func ReadOnly(m *health.Maintenance, allow ...string) Middleware {
+	allowed := make(map[string]bool, len(allow))
+	for _, path := range allow {
+		allowed[path] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if m.Enabled() && !isSafeMethod(r.Method) && !allowed[r.URL.Path] {
+				response.WriteJson(w, http.StatusServiceUnavailable, response.Response{
+					Status: response.StatusError,
+					Error:  "server is in maintenance mode, only reads are allowed right now",
+					Code:   errcode.Maintenance,
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/metrics"
+)
+
+// known methods keep their name, anything else is "OTHER" so junk methods can not create new time series
+var knownMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+// Metrics records count, errors and latency of every request labelled by the matched route
+func Metrics(m *metrics.HTTP) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ctx := WithRouteHolder(r.Context())
+			sw := NewStatusWriter(w)
+
+			next.ServeHTTP(sw, r.WithContext(ctx))
+
+			m.Observe(RoutePattern(ctx), metricMethod(r.Method), sw.StatusCode(), time.Since(start))
+		})
+	}
+}
+
+func metricMethod(method string) string {
+	if !knownMethods[method] {
+		return "OTHER"
+	}
+	return method
+}
@@ -0,0 +1,17 @@
+package middleware
+
+import "net/http"
+
+// Middleware wraps a handler to run code before and/or after it -> like (req, res, next) in express
+type Middleware func(http.Handler) http.Handler
+
+// Chain joins middlewares into one, the first one is the outermost so it runs first:
+// Chain(a, b, c)(h) is the same as a(b(c(h)))
+func Chain(middlewares ...Middleware) Middleware {
+	return func(final http.Handler) http.Handler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			final = middlewares[i](final)
+		}
+		return final
+	}
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+)
+
+// Priority decides who gets shed first when the server is overloaded, higher value is more important
+type Priority int
+
+const (
+	PriorityLow    Priority = iota // anonymous traffic, exports and bulk imports
+	PriorityNormal                 // authenticated api calls
+	PriorityHigh                   // admin and health checks, these must always get through
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityHigh:
+		return "high"
+	case PriorityNormal:
+		return "normal"
+	default:
+		return "low"
+	}
+}
+
+type priorityKey struct{} // own type so no other package can clash with our context key
+
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// PriorityFrom reads the priority set by Prioritize, low if nothing was set
+func PriorityFrom(ctx context.Context) Priority {
+	p, _ := ctx.Value(priorityKey{}).(Priority)
+	return p
+}
+
+// Classifier picks the priority of a request from its route and auth
+type Classifier func(r *http.Request) Priority
+
+// DefaultClassifier -> admin/health > authenticated api > anonymous/export/bulk import
+func DefaultClassifier(r *http.Request) Priority {
+	path := r.URL.Path
+	switch {
+	case strings.HasPrefix(path, "/api/admin"), path == "/healthz", path == "/readyz":
+		return PriorityHigh
+	case strings.HasSuffix(path, "/export"), strings.HasSuffix(path, "/students/stream"), strings.HasSuffix(path, "/students/imports"):
+		return PriorityLow
+	default:
+		if _, ok := auth.PrincipalFrom(r.Context()); ok { // set by Authenticate, which has to run before Prioritize
+			return PriorityNormal
+		}
+		return PriorityLow
+	}
+}
+
+// Prioritize stores the priority of every request in its context so the limiter can read it later
+func Prioritize(classify Classifier) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(WithPriority(r.Context(), classify(r))))
+		})
+	}
+}
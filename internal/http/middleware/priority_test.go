@@ -0,0 +1,45 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+	"github.com/manishtomar-cpi/go-server/internal/http/middleware"
+)
+
+func TestDefaultClassifier(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name          string
+		method, path  string
+		authenticated bool
+		want          middleware.Priority
+	}
+
+	tests := []testCase{
+		{name: "admin", method: http.MethodGet, path: "/api/admin/config", want: middleware.PriorityHigh},
+		{name: "probe", method: http.MethodGet, path: "/readyz", want: middleware.PriorityHigh},
+		{name: "authenticated_api", method: http.MethodGet, path: "/api/v1/students", authenticated: true, want: middleware.PriorityNormal},
+		{name: "anonymous_api", method: http.MethodGet, path: "/api/v1/students", want: middleware.PriorityLow},
+		{name: "export", method: http.MethodGet, path: "/api/v1/students/export", authenticated: true, want: middleware.PriorityLow},
+		{name: "bulk_import", method: http.MethodPost, path: "/api/v1/students/imports", authenticated: true, want: middleware.PriorityLow},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			if tc.authenticated {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Subject: "asha", Kind: "user"}))
+			}
+			if got := middleware.DefaultClassifier(req); got != tc.want {
+				t.Fatalf("want priority %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
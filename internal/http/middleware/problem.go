@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+// ProblemDetails switches error bodies to RFC 7807 application/problem+json. with always false only clients
+// that list application/problem+json in Accept get them, everyone else keeps the old {status, error} body.
+// response.WriteJson has no request, so the choice travels in the response headers and is removed again before they go out
+func ProblemDetails(always bool) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !always && !AcceptsProblem(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set(response.ProblemHeader, r.URL.Path)
+			next.ServeHTTP(&problemWriter{ResponseWriter: w}, r)
+		})
+	}
+}
+
+// AcceptsProblem reports if application/problem+json is listed in the Accept header (and not with q=0)
+func AcceptsProblem(r *http.Request) bool {
+	for _, header := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(header, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil || mediaType != response.ProblemContentType {
+				continue
+			}
+			if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+				continue
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// problemWriter drops the marker header right before the headers are sent
+type problemWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (pw *problemWriter) WriteHeader(status int) {
+	pw.wroteHeader = true
+	pw.ResponseWriter.Header().Del(response.ProblemHeader)
+	pw.ResponseWriter.WriteHeader(status)
+}
+
+func (pw *problemWriter) Write(b []byte) (int, error) {
+	if !pw.wroteHeader {
+		pw.WriteHeader(http.StatusOK)
+	}
+	return pw.ResponseWriter.Write(b)
+}
+
+func (pw *problemWriter) Flush() {
+	if f, ok := pw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the real writer
+func (pw *problemWriter) Unwrap() http.ResponseWriter {
+	return pw.ResponseWriter
+}
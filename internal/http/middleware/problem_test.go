@@ -0,0 +1,54 @@
+package middleware_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/http/middleware"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+func TestProblemDetails(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name            string
+		always          bool
+		accept          string
+		wantContentType string
+	}
+
+	tests := []testCase{
+		{name: "no_accept_keeps_old_body", wantContentType: "application/json"},
+		{name: "json_only_keeps_old_body", accept: "application/json", wantContentType: "application/json"},
+		{name: "asked_for_problem", accept: "application/json;q=0.5, application/problem+json", wantContentType: response.ProblemContentType},
+		{name: "refused_problem", accept: "application/problem+json;q=0", wantContentType: "application/json"},
+		{name: "always_on", always: true, wantContentType: response.ProblemContentType},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := middleware.ProblemDetails(tc.always)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				response.WriteJson(w, http.StatusNotFound, response.GeneralError(errors.New("student not found")))
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/api/students/7", nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+
+			if ct := rr.Header().Get("Content-Type"); ct != tc.wantContentType {
+				t.Fatalf("content-type: want %q, got %q", tc.wantContentType, ct)
+			}
+			if rr.Header().Get(response.ProblemHeader) != "" {
+				t.Fatalf("%s must not reach the client", response.ProblemHeader)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/logging"
+	"github.com/manishtomar-cpi/go-server/internal/ratelimit"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+var errTooManyRequests = errors.New("too many requests, slow down")
+
+// KeyFunc picks who a request is counted against
+type KeyFunc func(r *http.Request) string
+
+// ClientIP counts per client ip address, the one RealIP resolved behind a load balancer
+func ClientIP(r *http.Request) string {
+	if ip := logging.ClientIP(r.Context()); ip != "" {
+		return ip
+	}
+	return remoteIP(r)
+}
+
+// PrincipalOrIP counts an authenticated caller per user or api key, wherever it calls from, and everyone else per
+// client ip. it needs Authenticate to run first. many users behind one nat no longer share a bucket, and one api key
+// can not get more by spreading its calls over many addresses
+func PrincipalOrIP(r *http.Request) string {
+	if p, ok := auth.PrincipalFrom(r.Context()); ok && p.Subject != "" {
+		return p.Kind + ":" + p.Subject
+	}
+	return ClientIP(r)
+}
+
+// RateLimit answers 429 with Retry-After once a client used up its bucket.
+// when the store itself fails (redis down) the request is let through, a broken limiter should not take the api down
+func RateLimit(store ratelimit.Store, key KeyFunc, clk clock.Clock) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed, retryAfter, err := store.Allow(r.Context(), key(r), clk.Now())
+			if err != nil {
+				logging.FromContext(r.Context()).WarnContext(r.Context(), "rate limit store failed, letting request through", slog.String("error", err.Error()))
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !allowed {
+				tooManyRequests(w, retryAfter)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// tooManyRequests answers 429, Retry-After is rounded up to whole seconds and at least one
+func tooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	response.WriteJson(w, http.StatusTooManyRequests, response.GeneralError(errTooManyRequests))
+}
@@ -0,0 +1,67 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/http/middleware"
+	"github.com/manishtomar-cpi/go-server/internal/ratelimit"
+)
+
+// caller is who sent one request, an empty subject is anonymous
+type caller struct {
+	ip      string
+	kind    string
+	subject string
+}
+
+func TestRateLimitPrincipalOrIP(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name       string
+		first      caller
+		second     caller
+		wantStatus int // of the second request, the bucket holds one
+	}
+
+	tests := []testCase{
+		{name: "same_ip_anonymous", first: caller{ip: "203.0.113.7"}, second: caller{ip: "203.0.113.7"}, wantStatus: http.StatusTooManyRequests},
+		{name: "different_ips_anonymous", first: caller{ip: "203.0.113.7"}, second: caller{ip: "203.0.113.8"}, wantStatus: http.StatusOK},
+		{name: "users_behind_one_nat", first: caller{ip: "203.0.113.7", kind: "user", subject: "asha"}, second: caller{ip: "203.0.113.7", kind: "user", subject: "ravi"}, wantStatus: http.StatusOK},
+		{name: "user_from_two_ips", first: caller{ip: "203.0.113.7", kind: "user", subject: "asha"}, second: caller{ip: "198.51.100.9", kind: "user", subject: "asha"}, wantStatus: http.StatusTooManyRequests},
+		{name: "api_key_from_two_ips", first: caller{ip: "203.0.113.7", kind: "api_key", subject: "3"}, second: caller{ip: "198.51.100.9", kind: "api_key", subject: "3"}, wantStatus: http.StatusTooManyRequests},
+		{name: "user_and_api_key_same_subject", first: caller{ip: "203.0.113.7", kind: "user", subject: "3"}, second: caller{ip: "203.0.113.7", kind: "api_key", subject: "3"}, wantStatus: http.StatusOK},
+		{name: "anonymous_after_user_same_ip", first: caller{ip: "203.0.113.7", kind: "user", subject: "asha"}, second: caller{ip: "203.0.113.7"}, wantStatus: http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			clk := clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+			limited := middleware.RateLimit(ratelimit.NewMemoryStore(ratelimit.Limits{Rate: 0.001, Burst: 1}), middleware.PrincipalOrIP, clk)(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			var status int
+			for _, c := range []caller{tc.first, tc.second} {
+				req := httptest.NewRequest(http.MethodGet, "/api/v1/students", nil)
+				req.RemoteAddr = c.ip + ":5555"
+				if c.subject != "" { // what Authenticate leaves in the context
+					req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Subject: c.subject, Kind: c.kind}))
+				}
+				rec := httptest.NewRecorder()
+				limited.ServeHTTP(rec, req)
+				status = rec.Code
+			}
+			if status != tc.wantStatus {
+				t.Fatalf("second request: want %d, got %d", tc.wantStatus, status)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/manishtomar-cpi/go-server/internal/logging"
+)
+
+// ParseTrustedProxies turns config entries into prefixes, both "10.0.0.0/8" and a single "10.1.2.3" work
+func ParseTrustedProxies(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("trusted proxy %q: %w", entry, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", entry, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// RealIP finds the real client address and puts it in the context for ClientIP and the logs.
+// forwarding headers are only believed when the connection comes from a trusted proxy, anyone else could just send them.
+// X-Forwarded-For is read right to left and the first address that is not a trusted proxy wins,
+// so a client can not sneak in a fake address at the left end. X-Real-IP is the fallback
+func RealIP(trusted []netip.Prefix) Middleware {
+	isTrusted := func(addr netip.Addr) bool {
+		addr = addr.Unmap() // ::ffff:10.0.0.1 is the same host as 10.0.0.1
+		for _, p := range trusted {
+			if p.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := remoteIP(r)
+			if addr, err := netip.ParseAddr(ip); err == nil && isTrusted(addr) {
+				ip = forwardedIP(r.Header, isTrusted, ip)
+			}
+			next.ServeHTTP(w, r.WithContext(logging.WithClientIP(r.Context(), ip)))
+		})
+	}
+}
+
+func forwardedIP(h http.Header, isTrusted func(netip.Addr) bool, proxy string) string {
+	if h.Get("X-Forwarded-For") == "" {
+		if addr, err := netip.ParseAddr(strings.TrimSpace(h.Get("X-Real-IP"))); err == nil {
+			return addr.Unmap().String()
+		}
+		return proxy
+	}
+
+	// a request can carry several X-Forwarded-For lines, together they are one list
+	client := proxy
+	hops := strings.Split(strings.Join(h.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break // garbage in the chain, nothing left of it can be trusted
+		}
+		client = addr.Unmap().String()
+		if !isTrusted(addr) {
+			break
+		}
+	}
+	return client // when every hop is a trusted proxy the leftmost one is the best we know
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr // unix socket or a RemoteAddr without port
+	}
+	return host
+}
@@ -0,0 +1,62 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/http/middleware"
+)
+
+func TestRealIP(t *testing.T) {
+	t.Parallel()
+
+	trusted, err := middleware.ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies: %v", err)
+	}
+
+	type testCase struct {
+		name       string
+		remoteAddr string
+		xff        string
+		xRealIP    string
+		want       string
+	}
+
+	tests := []testCase{
+		{name: "no_proxy", remoteAddr: "203.0.113.7:5555", want: "203.0.113.7"},
+		{name: "untrusted_peer_headers_ignored", remoteAddr: "203.0.113.7:5555", xff: "1.2.3.4", want: "203.0.113.7"},
+		{name: "trusted_peer_xff", remoteAddr: "10.0.0.2:80", xff: "198.51.100.9", want: "198.51.100.9"},
+		{name: "spoofed_left_end_ignored", remoteAddr: "10.0.0.2:80", xff: "6.6.6.6, 198.51.100.9, 10.0.0.5", want: "198.51.100.9"},
+		{name: "single_trusted_address", remoteAddr: "192.168.1.1:80", xff: "198.51.100.9", want: "198.51.100.9"},
+		{name: "x_real_ip_fallback", remoteAddr: "10.0.0.2:80", xRealIP: "198.51.100.9", want: "198.51.100.9"},
+		{name: "all_hops_trusted", remoteAddr: "10.0.0.2:80", xff: "10.0.0.9", want: "10.0.0.9"},
+		{name: "garbage_hop", remoteAddr: "10.0.0.2:80", xff: "198.51.100.9, nonsense", want: "10.0.0.2"},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got string
+			h := middleware.RealIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = middleware.ClientIP(r)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			if tc.xff != "" {
+				req.Header.Set("X-Forwarded-For", tc.xff)
+			}
+			if tc.xRealIP != "" {
+				req.Header.Set("X-Real-IP", tc.xRealIP)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tc.want {
+				t.Fatalf("want client ip %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
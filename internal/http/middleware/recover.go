@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/manishtomar-cpi/go-server/internal/errorreport"
+	"github.com/manishtomar-cpi/go-server/internal/logging"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+var errInternal = errors.New("internal server error")
+
+// Recover turns a panicking handler into a 500 json response instead of a dropped connection,
+// logs the stack and reports the panic. 5xx answers of handlers that did not panic are reported too
+func Recover(reporter errorreport.Reporter) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sw := NewStatusWriter(w)
+			defer func() {
+				p := recover()
+				if p == nil {
+					if sw.StatusCode() >= 500 {
+						reporter.ServerError(r, sw.StatusCode())
+					}
+					return
+				}
+				if p == http.ErrAbortHandler { // the documented way to abort a response, not a bug
+					panic(p)
+				}
+				logging.FromContext(r.Context()).ErrorContext(r.Context(), "handler panicked",
+					slog.Any("panic", p), slog.String("stack", string(debug.Stack())))
+				reporter.Panic(r, p)
+				if sw.Status == 0 { // nothing sent yet, otherwise the client just gets a cut off body
+					response.WriteJson(sw, http.StatusInternalServerError, response.GeneralError(errInternal))
+				}
+			}()
+			next.ServeHTTP(sw, r)
+		})
+	}
+}
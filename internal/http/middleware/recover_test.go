@@ -0,0 +1,67 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/http/middleware"
+)
+
+type fakeReporter struct {
+	panics   []any
+	statuses []int
+}
+
+func (f *fakeReporter) Panic(r *http.Request, value any) { f.panics = append(f.panics, value) }
+func (f *fakeReporter) ServerError(r *http.Request, status int) {
+	f.statuses = append(f.statuses, status)
+}
+
+func TestRecover(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name         string
+		handler      http.HandlerFunc
+		wantStatus   int
+		wantPanics   int
+		wantStatuses int
+	}
+
+	tests := []testCase{
+		{
+			name:       "panic_becomes_500",
+			handler:    func(w http.ResponseWriter, r *http.Request) { panic("boom") },
+			wantStatus: http.StatusInternalServerError, wantPanics: 1,
+		},
+		{
+			name:       "5xx_is_reported",
+			handler:    func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusBadGateway) },
+			wantStatus: http.StatusBadGateway, wantStatuses: 1,
+		},
+		{
+			name:       "4xx_is_not_reported",
+			handler:    func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotFound) },
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			reporter := &fakeReporter{}
+			rr := httptest.NewRecorder()
+			middleware.Recover(reporter)(tc.handler).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("want %d, got %d", tc.wantStatus, rr.Code)
+			}
+			if len(reporter.panics) != tc.wantPanics || len(reporter.statuses) != tc.wantStatuses {
+				t.Fatalf("want %d panics and %d 5xx reported, got %v and %v", tc.wantPanics, tc.wantStatuses, reporter.panics, reporter.statuses)
+			}
+		})
+	}
+}
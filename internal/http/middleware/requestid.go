@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/manishtomar-cpi/go-server/internal/ids"
+	"github.com/manishtomar-cpi/go-server/internal/logging"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+const RequestIDHeader = response.RequestIDHeader
+
+// RequestID gives every request an id -> the one the client (or a proxy) sent in X-Request-ID, or a new one.
+// it goes into the context (so log lines carry it) and back in the response header
+func RequestID(src ids.IDSource) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = src.NewID()
+			}
+			w.Header().Set(RequestIDHeader, id)
+			next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+		})
+	}
+}
+
+// incoming ids end up in our logs, so only short printable ones are trusted (no newlines to fake log lines)
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/http/middleware"
+	"github.com/manishtomar-cpi/go-server/internal/ids"
+	"github.com/manishtomar-cpi/go-server/internal/logging"
+)
+
+func TestRequestID(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name     string
+		incoming string // X-Request-ID the client sent, empty for none
+		want     string
+	}
+
+	tests := []testCase{
+		{name: "none_sent", want: "req-1"},
+		{name: "valid_kept", incoming: "edge-7f3a:42", want: "edge-7f3a:42"},
+		{name: "longest_valid_kept", incoming: strings.Repeat("a", 128), want: strings.Repeat("a", 128)},
+		{name: "too_long_replaced", incoming: strings.Repeat("a", 129), want: "req-1"},
+		{name: "space_replaced", incoming: "two words", want: "req-1"},
+		{name: "newline_replaced", incoming: "id\nlevel=ERROR msg=fake", want: "req-1"},
+		{name: "non_ascii_replaced", incoming: "idé", want: "req-1"},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var logs bytes.Buffer
+			logger := slog.New(logging.NewContextHandler(slog.NewJSONHandler(&logs, nil)))
+			var inContext string
+			h := middleware.RequestID(&ids.Sequence{Prefix: "req"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				inContext = logging.RequestID(r.Context())
+				logger.InfoContext(r.Context(), "handled")
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/students", nil)
+			if tc.incoming != "" {
+				req.Header.Set(middleware.RequestIDHeader, tc.incoming)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get(middleware.RequestIDHeader); got != tc.want {
+				t.Fatalf("response header: want %q, got %q", tc.want, got)
+			}
+			if inContext != tc.want {
+				t.Fatalf("context: want %q, got %q", tc.want, inContext)
+			}
+			var line map[string]any
+			if err := json.Unmarshal(logs.Bytes(), &line); err != nil {
+				t.Fatalf("log line %q: %v", logs.String(), err)
+			}
+			if line["request_id"] != tc.want {
+				t.Fatalf("log line: want request_id %q, got %v", tc.want, line["request_id"])
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/errcode"
+	"github.com/manishtomar-cpi/go-server/internal/metrics"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+// maxShedSamples bounds the latencies kept per interval, past it the oldest are overwritten -> the p99 of a busy
+// second is the p99 of its last 1024 requests
+const maxShedSamples = 1024
+
+// ShedOptions -> Target is the p99 latency the server should stay under, MaxQueue the limiter queue depth that counts
+// as overload too. both are checked every Interval and the shed level moves by Step (0-1) each time
+type ShedOptions struct {
+	Target   time.Duration
+	MaxQueue int
+	Interval time.Duration
+	Step     float64
+}
+
+// Shedder rejects part of the traffic before it reaches the limiter while the server is slower than it should be.
+// the Limiter alone lets requests wait until their queue time runs out, under a long overload that means everyone
+// waits and most still fail. the shedder notices the latency going up and turns the cheapest requests away at once,
+// low priority first and normal ones only once every low one is rejected. admin and health checks always get through.
+// like CoDel it reacts to what requests actually experienced (queue wait included), not to a fixed rate.
+// only requests above low priority make up the latency, an export or an import upload is slow on purpose and would
+// otherwise shed everyone else. overload that only low priority traffic causes still shows in the queue depth
+type Shedder struct {
+	opts    ShedOptions
+	queued  func() int // limiter queue depth, nil when there is no limiter
+	clk     clock.Clock
+	metrics *metrics.Shedding
+
+	mu          sync.Mutex
+	level       float64 // 0 nothing is shed, 0.5 every low priority request, 1 every normal one too
+	windowStart time.Time
+	samples     []time.Duration // latencies seen since windowStart
+	seen        int             // samples written since windowStart, samples wraps around past maxShedSamples
+	credit      [PriorityHigh]float64
+}
+
+func NewShedder(opts ShedOptions, queued func() int, clk clock.Clock, m *metrics.Shedding) *Shedder {
+	return &Shedder{opts: opts, queued: queued, clk: clk, metrics: m, windowStart: clk.Now()}
+}
+
+// ratio is the share of requests of priority p that are shed at level
+func ratio(level float64, p Priority) float64 {
+	switch p {
+	case PriorityLow:
+		return math.Min(1, 2*level)
+	case PriorityNormal:
+		return math.Max(0, 2*level-1)
+	default:
+		return 0
+	}
+}
+
+// shed decides for one request. instead of rolling dice it adds the ratio to a running credit per priority and sheds
+// whenever a whole request is owed -> at ratio 0.3 exactly 3 of every 10 requests are rejected
+func (s *Shedder) shed(p Priority) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.adjust(s.clk.Now())
+	if p >= PriorityHigh {
+		return false
+	}
+	s.credit[p] += ratio(s.level, p)
+	if s.credit[p] < 1 {
+		return false
+	}
+	s.credit[p]--
+	return true
+}
+
+// observe records how long a request that got through took
+func (s *Shedder) observe(took time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.adjust(s.clk.Now())
+	if len(s.samples) < maxShedSamples {
+		s.samples = append(s.samples, took)
+	} else {
+		s.samples[s.seen%maxShedSamples] = took
+	}
+	s.seen++
+}
+
+// adjust closes the interval once it is over and moves the level, mu must be held
+func (s *Shedder) adjust(now time.Time) {
+	elapsed := now.Sub(s.windowStart)
+	if elapsed < s.opts.Interval {
+		return
+	}
+	overloaded := s.p99() > s.opts.Target || (s.queued != nil && s.opts.MaxQueue > 0 && s.queued() > s.opts.MaxQueue)
+	if overloaded {
+		s.level = math.Min(1, s.level+s.opts.Step)
+	} else {
+		// quiet intervals with no request at all count too, so a burst that stopped does not leave the level up
+		steps := float64(elapsed / s.opts.Interval)
+		s.level = math.Max(0, s.level-steps*s.opts.Step)
+	}
+	if s.level == 0 {
+		s.credit = [PriorityHigh]float64{}
+	}
+	s.windowStart = now
+	s.samples = s.samples[:0]
+	s.seen = 0
+	for _, p := range []Priority{PriorityLow, PriorityNormal} {
+		s.metrics.Ratio(p.String(), ratio(s.level, p))
+	}
+}
+
+// p99 of the current interval, 0 without samples. mu must be held
+func (s *Shedder) p99() time.Duration {
+	if len(s.samples) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(s.samples)
+	slices.Sort(sorted)
+	return sorted[(len(sorted)*99-1)/100]
+}
+
+// Level is the current shed level, 0 when nothing is rejected (see Shedder)
+func (s *Shedder) Level() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.adjust(s.clk.Now())
+	return s.level
+}
+
+func (s *Shedder) Middleware(next http.Handler) http.Handler {
+	if s.opts.Interval <= 0 || s.opts.Step <= 0 {
+		return next
+	}
+	// the level is looked at again after one interval, a retry before that meets the same odds
+	retryAfter := strconv.Itoa(int(math.Max(1, math.Ceil(s.opts.Interval.Seconds()))))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if LongLived(r) { // a live connection is slow on purpose, its duration says nothing about load
+			next.ServeHTTP(w, r)
+			return
+		}
+		p := PriorityFrom(r.Context())
+		if s.shed(p) {
+			s.metrics.Shed(p.String())
+			w.Header().Set("Retry-After", retryAfter)
+			response.WriteError(w, errcode.Overloaded, errOverloaded)
+			return
+		}
+		if p == PriorityLow {
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := s.clk.Now()
+		next.ServeHTTP(w, r)
+		s.observe(s.clk.Now().Sub(start))
+	})
+}
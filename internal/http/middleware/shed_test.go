@@ -0,0 +1,147 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/http/middleware"
+	"github.com/manishtomar-cpi/go-server/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// the handler moves the fake clock by latency, that is how long the shedder sees every request take
+func shedHandler(opts middleware.ShedOptions, queued int, clk *clock.Fake, latency *time.Duration) (*middleware.Shedder, http.Handler) {
+	s := middleware.NewShedder(opts, func() int { return queued }, clk, metrics.NewShedding(prometheus.NewRegistry()))
+	return s, s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clk.Advance(*latency)
+	}))
+}
+
+// rejected sends n requests of priority p and counts the 503s
+func rejected(t *testing.T, h http.Handler, p middleware.Priority, n int) int {
+	t.Helper()
+	count := 0
+	for i := 0; i < n; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/students", nil)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req.WithContext(middleware.WithPriority(req.Context(), p)))
+		if rr.Code == http.StatusServiceUnavailable {
+			if rr.Header().Get("Retry-After") == "" {
+				t.Fatal("503 without Retry-After")
+			}
+			count++
+		}
+	}
+	return count
+}
+
+func TestShedder(t *testing.T) {
+	t.Parallel()
+
+	opts := middleware.ShedOptions{Target: 100 * time.Millisecond, MaxQueue: 5, Interval: time.Minute, Step: 0.25}
+
+	type testCase struct {
+		name       string
+		latency    time.Duration // of the requests in the overloaded intervals
+		queued     int
+		intervals  int // how many intervals in a row look like that
+		priority   middleware.Priority
+		wantShedOf int // out of 100 afterwards
+	}
+
+	tests := []testCase{
+		{name: "fast_nothing_shed", latency: 10 * time.Millisecond, intervals: 3, priority: middleware.PriorityLow, wantShedOf: 0},
+		{name: "slow_sheds_part_of_low", latency: 300 * time.Millisecond, intervals: 1, priority: middleware.PriorityLow, wantShedOf: 50},
+		{name: "slow_keeps_normal_while_low_is_shed", latency: 300 * time.Millisecond, intervals: 1, priority: middleware.PriorityNormal, wantShedOf: 0},
+		{name: "long_overload_sheds_all_low", latency: 300 * time.Millisecond, intervals: 3, priority: middleware.PriorityLow, wantShedOf: 100},
+		{name: "long_overload_reaches_normal", latency: 300 * time.Millisecond, intervals: 3, priority: middleware.PriorityNormal, wantShedOf: 50},
+		{name: "high_never_shed", latency: 300 * time.Millisecond, intervals: 10, priority: middleware.PriorityHigh, wantShedOf: 0},
+		{name: "long_queue_is_overload", latency: 10 * time.Millisecond, queued: 6, intervals: 1, priority: middleware.PriorityLow, wantShedOf: 50},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			clk := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+			latency := tc.latency
+			_, h := shedHandler(opts, tc.queued, clk, &latency)
+			for i := 0; i < tc.intervals; i++ {
+				// high priority, so none of them is shed and every interval has its samples
+				rejected(t, h, middleware.PriorityHigh, 10)
+				clk.Advance(opts.Interval)
+			}
+
+			latency = 0
+			if got := rejected(t, h, tc.priority, 100); got != tc.wantShedOf {
+				t.Fatalf("shed %d of 100, want %d", got, tc.wantShedOf)
+			}
+		})
+	}
+}
+
+func TestShedderRecovers(t *testing.T) {
+	t.Parallel()
+
+	opts := middleware.ShedOptions{Target: 100 * time.Millisecond, Interval: time.Minute, Step: 0.25}
+	clk := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	latency := 300 * time.Millisecond
+	s, h := shedHandler(opts, 0, clk, &latency)
+
+	for i := 0; i < 4; i++ {
+		rejected(t, h, middleware.PriorityHigh, 10)
+		clk.Advance(opts.Interval)
+	}
+	if got := s.Level(); got != 1 {
+		t.Fatalf("level %v after a long overload, want 1", got)
+	}
+
+	// fast again, one interval brings the level down one step
+	latency = 10 * time.Millisecond
+	rejected(t, h, middleware.PriorityHigh, 10)
+	clk.Advance(opts.Interval)
+	if got := s.Level(); got != 0.75 {
+		t.Fatalf("level %v after a good interval, want 0.75", got)
+	}
+
+	// and intervals without any traffic count as good ones
+	clk.Advance(3 * opts.Interval)
+	if got := s.Level(); got != 0 {
+		t.Fatalf("level %v after a quiet period, want 0", got)
+	}
+	if got := rejected(t, h, middleware.PriorityLow, 100); got != 0 {
+		t.Fatalf("shed %d low priority requests after recovering, want 0", got)
+	}
+}
+
+func TestShedderIgnoresSlowLowPriority(t *testing.T) {
+	t.Parallel()
+
+	opts := middleware.ShedOptions{Target: 100 * time.Millisecond, Interval: time.Minute, Step: 0.25}
+	clk := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	latency := 10 * time.Millisecond
+	s, h := shedHandler(opts, 0, clk, &latency)
+
+	// every interval one export runs for half a minute next to fast api calls
+	for i := 0; i < 4; i++ {
+		latency = 30 * time.Second
+		if got := rejected(t, h, middleware.PriorityLow, 1); got != 0 {
+			t.Fatalf("interval %d: the export was shed", i+1)
+		}
+		latency = 10 * time.Millisecond
+		if got := rejected(t, h, middleware.PriorityNormal, 10); got != 0 {
+			t.Fatalf("interval %d: shed %d api calls while only an export was slow", i+1, got)
+		}
+		clk.Advance(opts.Interval)
+		if got := s.Level(); got != 0 {
+			t.Fatalf("interval %d: level %v while only an export was slow, want 0", i+1, got)
+		}
+	}
+	if got := rejected(t, h, middleware.PriorityLow, 100); got != 0 {
+		t.Fatalf("shed %d low priority requests, want 0", got)
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/anomaly"
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/logging"
+)
+
+// SlowRequests warns about every request that took longer than threshold, with everything needed to reproduce it
+// and the route that handled it. separate from the access log so tail latency offenders are easy to grep,
+// and they show up on the admin anomalies page. threshold <= 0 turns it off
+func SlowRequests(threshold time.Duration, rec *anomaly.Recorder, clk clock.Clock) Middleware {
+	return func(next http.Handler) http.Handler {
+		if threshold <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := clk.Now()
+			ctx := WithRouteHolder(r.Context())
+			sw := NewStatusWriter(w)
+
+			next.ServeHTTP(sw, r.WithContext(ctx))
+
+			took := clk.Now().Sub(start)
+			if took < threshold || LongLived(r) { // a live connection is supposed to stay open
+				return
+			}
+			route := RoutePattern(ctx)
+			logging.FromContext(ctx).LogAttrs(ctx, slog.LevelWarn, "slow request",
+				slog.String("route", route),
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("query", r.URL.RawQuery),
+				slog.Int("status", sw.StatusCode()),
+				slog.Int64("request_bytes", r.ContentLength),
+				slog.Int64("response_bytes", sw.Bytes),
+				slog.String("user_agent", r.UserAgent()),
+				slog.String("priority", PriorityFrom(ctx).String()),
+				slog.Duration("duration", took),
+				slog.Duration("threshold", threshold),
+			)
+			rec.Record(anomaly.SlowRequest, "request exceeded "+threshold.String(), map[string]string{
+				"route":      route,
+				"path":       r.URL.Path,
+				"status":     strconv.Itoa(sw.StatusCode()),
+				"duration":   took.String(),
+				"request_id": logging.RequestID(ctx),
+			})
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/anomaly"
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/http/middleware"
+)
+
+func TestSlowRequests(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name     string
+		took     time.Duration
+		wantSlow bool
+	}
+
+	tests := []testCase{
+		{name: "fast_request_ignored", took: 100 * time.Millisecond},
+		{name: "slow_request_recorded", took: 700 * time.Millisecond, wantSlow: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			rec := anomaly.NewRecorder(10, clk)
+			h := middleware.SlowRequests(500*time.Millisecond, rec, clk)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				clk.Advance(tc.took)
+			}))
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/students", nil))
+
+			snapshot := rec.Snapshot()
+			if gotSlow := len(snapshot) == 1 && snapshot[0].Kind == anomaly.SlowRequest; gotSlow != tc.wantSlow {
+				t.Fatalf("want slow request recorded %v, got %+v", tc.wantSlow, snapshot)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+)
+
+// StatusWriter remembers the status code and body size a handler wrote, for metrics and logs
+type StatusWriter struct {
+	http.ResponseWriter
+	Status int
+	Bytes  int64
+}
+
+func NewStatusWriter(w http.ResponseWriter) *StatusWriter {
+	return &StatusWriter{ResponseWriter: w}
+}
+
+func (sw *StatusWriter) WriteHeader(status int) {
+	if sw.Status == 0 {
+		sw.Status = status
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *StatusWriter) Write(b []byte) (int, error) {
+	if sw.Status == 0 {
+		sw.Status = http.StatusOK // like net/http, first Write without WriteHeader means 200
+	}
+	n, err := sw.ResponseWriter.Write(b)
+	sw.Bytes += int64(n)
+	return n, err
+}
+
+func (sw *StatusWriter) Flush() {
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the real writer
+func (sw *StatusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// StatusCode is 200 when the handler never wrote anything, that is what net/http sends then
+func (sw *StatusWriter) StatusCode() int {
+	if sw.Status == 0 {
+		return http.StatusOK
+	}
+	return sw.Status
+}
+
+// route pattern -> the mux only knows which route matched after global middlewares already ran,
+// so they put an empty holder in the context and the router fills it in once the route is found
+
+type routeKey struct{}
+
+type routeHolder struct {
+	pattern string
+}
+
+// WithRouteHolder prepares the context so RoutePattern works after the request was served
+func WithRouteHolder(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(routeKey{}).(*routeHolder); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, routeKey{}, &routeHolder{})
+}
+
+// SetRoutePattern is called by the router with the pattern that matched, like "GET /api/students/{id}"
+func SetRoutePattern(ctx context.Context, pattern string) {
+	if h, ok := ctx.Value(routeKey{}).(*routeHolder); ok {
+		h.pattern = pattern
+	}
+}
+
+// RoutePattern returns the matched route, empty when nothing matched (404) or the holder is missing
+func RoutePattern(ctx context.Context) string {
+	if h, ok := ctx.Value(routeKey{}).(*routeHolder); ok {
+		return h.pattern
+	}
+	return ""
+}
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+var errTimeout = errors.New("request took too long and was cancelled")
+
+// Timeout cancels the request context after d and answers 504 with a json body, so the client never hangs.
+// unlike http.TimeoutHandler the response is not buffered, streaming endpoints (export) keep streaming,
+// and if the deadline hits after the body started the stream is just cut at that point
+func Timeout(d time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			tw := &timeoutWriter{w: w, ctx: ctx, header: w.Header().Clone()} // a copy, so headers set earlier (request id) are still visible
+			done := make(chan struct{})
+			panicChan := make(chan any, 1)
+
+			go func() { // handler runs in its own goroutine so we can stop waiting for it
+				defer func() {
+					if p := recover(); p != nil {
+						panicChan <- p
+						return
+					}
+					close(done)
+				}()
+				next.ServeHTTP(tw, r.WithContext(ctx))
+			}()
+
+			select {
+			case p := <-panicChan:
+				panic(p) // let the server (or a recover middleware) see the panic like without this middleware
+			case <-done:
+				// a handler that stopped on ctx.Done and returned finishes about when the deadline fires, without
+				// this it would get the implicit 200 of the server instead of the 504
+				if ctx.Err() != nil {
+					tw.timeout()
+				}
+			case <-ctx.Done():
+				tw.timeout()
+			}
+		})
+	}
+}
+
+// timeoutWriter guards the real writer, once the timeout fired the handler goroutine can not write anymore
+type timeoutWriter struct {
+	w      http.ResponseWriter
+	ctx    context.Context // once it is over nothing of the handler gets through, even before timeout ran
+	header http.Header     // handler has its own header map so it never races with the timeout response
+
+	mu          sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.writeHeaderLocked(status)
+}
+
+func (tw *timeoutWriter) writeHeaderLocked(status int) {
+	tw.checkLocked()
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	for k, v := range tw.header {
+		tw.w.Header()[k] = v
+	}
+	tw.w.WriteHeader(status)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.writeHeaderLocked(http.StatusOK)
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	return tw.w.Write(b)
+}
+
+// Flush keeps streaming responses working through this wrapper
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.checkLocked()
+	if f, ok := tw.w.(http.Flusher); ok && !tw.timedOut {
+		f.Flush()
+	}
+}
+
+// timeout answers with 504 if nothing was sent yet, only once and not when the client itself went away
+func (tw *timeoutWriter) timeout() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.timeoutLocked()
+}
+
+// checkLocked times the writer out as soon as ctx is over, a handler write can not beat the 504 to it
+func (tw *timeoutWriter) checkLocked() {
+	if !tw.timedOut && tw.ctx.Err() != nil {
+		tw.timeoutLocked()
+	}
+}
+
+func (tw *timeoutWriter) timeoutLocked() {
+	if tw.timedOut {
+		return
+	}
+	if !tw.wroteHeader && errors.Is(tw.ctx.Err(), context.DeadlineExceeded) {
+		response.WriteJson(tw.w, http.StatusGatewayTimeout, response.GeneralError(errTimeout))
+	}
+	tw.timedOut = true
+}
@@ -0,0 +1,62 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/http/middleware"
+)
+
+func TestTimeout(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name       string
+		handler    http.HandlerFunc
+		wantStatus int
+		wantBody   string
+	}
+
+	tests := []testCase{
+		{
+			name: "fast_handler_passes_through",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Test", "yes")
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte("done"))
+			},
+			wantStatus: http.StatusCreated,
+			wantBody:   "done",
+		},
+		{
+			name: "slow_handler_gets_json_504_and_cancelled_context",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done() // a well behaved handler stops when the context is cancelled
+				w.Write([]byte("too late"))
+			},
+			wantStatus: http.StatusGatewayTimeout,
+			wantBody:   "took too long",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rr := httptest.NewRecorder()
+			h := middleware.Timeout(20 * time.Millisecond)(tc.handler)
+			h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("status mismatch: want %d, got %d", tc.wantStatus, rr.Code)
+			}
+			if !strings.Contains(rr.Body.String(), tc.wantBody) {
+				t.Fatalf("want body containing %q, got %q", tc.wantBody, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/logging"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentation = "github.com/manishtomar-cpi/go-server/internal/http"
+
+var (
+	tracer = otel.Tracer(instrumentation)
+	// the global meter forwards to the real provider once observability.Setup installed it, so creating it here is fine
+	requestDuration, _ = otel.Meter(instrumentation).Float64Histogram("http.server.request.duration",
+		metric.WithUnit("s"), metric.WithDescription("Duration of HTTP server requests."))
+)
+
+// Tracing starts a server span per request. an incoming traceparent header makes it a child of the caller's span,
+// and the span goes into the request context so storage calls nest under it.
+// it also records the otel request duration, the prometheus one is done by Metrics
+func Tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx = WithRouteHolder(ctx)
+		ctx, span := tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+				attribute.String("user_agent.original", r.UserAgent()),
+			))
+		defer span.End()
+		if sc := span.SpanContext(); sc.HasTraceID() { // only when tracing is on or the caller sent a traceparent
+			w.Header().Set(response.TraceIDHeader, sc.TraceID().String())
+		}
+		if id := logging.RequestID(ctx); id != "" {
+			span.SetAttributes(attribute.String("request.id", id))
+		}
+
+		start := time.Now()
+		sw := NewStatusWriter(w)
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		// the route is only known now, "GET /api/students/{id}" is a much better span name than the raw path
+		route := RoutePattern(ctx)
+		if route != "" {
+			span.SetName(route)
+			span.SetAttributes(attribute.String("http.route", route))
+		}
+		status := sw.StatusCode()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, strconv.Itoa(status))
+		}
+		requestDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(
+			attribute.String("http.request.method", metricMethod(r.Method)),
+			attribute.String("http.route", route),
+			attribute.Int("http.response.status_code", status),
+		))
+	})
+}
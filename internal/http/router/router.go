@@ -0,0 +1,176 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/manishtomar-cpi/go-server/internal/http/middleware"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+// Router is a thin layer over http.ServeMux that adds route groups (like express.Router() mounted on a path)
+// and middleware at three levels:
+//   - global (UseGlobal) -> runs for every request, also the ones that end in 404/405
+//   - group (Group/Use) -> runs for the routes of that group
+//   - route (extra args of Handle) -> runs only for that one route
+type Router struct {
+	root        *root
+	prefix      string
+	middlewares []middleware.Middleware
+	aliases     []alias
+}
+
+// alias mounts the routes of a group a second time under another prefix, like the unversioned /api for /api/v1
+type alias struct {
+	prefix      string
+	middlewares []middleware.Middleware // run before the group ones, only for requests that came in through the alias
+}
+
+// root is shared by all groups of one router
+type root struct {
+	mux     *http.ServeMux
+	handler http.Handler // mux wrapped in the global middlewares
+	global  []middleware.Middleware
+	routes  []string // full patterns in registration order, aliases are not listed
+	// longLived are the full patterns, aliases too, of the routes registered with HandleLongLived
+	longLived map[string]bool
+}
+
+func New() *Router {
+	mux := http.NewServeMux()
+	return &Router{root: &root{mux: mux, handler: mux, longLived: map[string]bool{}}}
+}
+
+// UseGlobal adds middleware that wraps the whole router, call it while setting up, before serving
+func (rt *Router) UseGlobal(middlewares ...middleware.Middleware) {
+	rt.root.global = append(rt.root.global, middlewares...)
+	rt.root.handler = middleware.Chain(rt.root.global...)(rt.root.mux)
+}
+
+// Group makes a sub router, its routes get the prefix and run the parent middlewares first, then its own
+func (rt *Router) Group(prefix string, middlewares ...middleware.Middleware) *Router {
+	prefix = strings.TrimSuffix(prefix, "/")
+	aliases := make([]alias, 0, len(rt.aliases))
+	for _, a := range rt.aliases {
+		aliases = append(aliases, alias{prefix: a.prefix + prefix, middlewares: a.middlewares})
+	}
+	return &Router{
+		root:        rt.root,
+		prefix:      rt.prefix + prefix,
+		middlewares: append(append([]middleware.Middleware{}, rt.middlewares...), middlewares...),
+		aliases:     aliases,
+	}
+}
+
+// Version is a group for one version of an api -> rt.Version("/api", "v1") serves under /api/v1.
+// handlers (and everything else behind it) can ask which version the request is for with APIVersion
+func (rt *Router) Version(prefix, version string, middlewares ...middleware.Middleware) *Router {
+	tag := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), versionKey{}, version)))
+		})
+	}
+	return rt.Group(strings.TrimSuffix(prefix, "/")+"/"+version, append([]middleware.Middleware{tag}, middlewares...)...)
+}
+
+// Alias serves every route registered on this group (and its sub groups) after this call under prefix as well.
+// middlewares only run for requests that came in through the alias, like a Deprecation header for old paths
+func (rt *Router) Alias(prefix string, middlewares ...middleware.Middleware) {
+	rt.aliases = append(rt.aliases, alias{prefix: strings.TrimSuffix(prefix, "/"), middlewares: middlewares})
+}
+
+type versionKey struct{}
+
+// APIVersion is the version of the group the route was registered on ("v1"), empty for routes outside of a Version group
+func APIVersion(r *http.Request) string {
+	version, _ := r.Context().Value(versionKey{}).(string)
+	return version
+}
+
+// Use adds middleware for routes registered after this call on this group
+func (rt *Router) Use(middlewares ...middleware.Middleware) {
+	rt.middlewares = append(rt.middlewares, middlewares...)
+}
+
+// Handle registers a route, pattern is like the ServeMux one: "GET /students/{id}".
+// middlewares passed here only run for this route, after the group ones
+func (rt *Router) Handle(pattern string, handler http.Handler, middlewares ...middleware.Middleware) {
+	method, path, found := strings.Cut(pattern, " ")
+	if !found { // no method in the pattern
+		method, path = "", pattern
+	}
+	full := joinPattern(method, rt.prefix+path)
+
+	rt.root.routes = append(rt.root.routes, full)
+	all := append(append([]middleware.Middleware{}, rt.middlewares...), middlewares...)
+	routed := middleware.Chain(all...)(handler)
+	rt.root.mux.Handle(full, withPattern(full, routed))
+
+	for _, a := range rt.aliases {
+		// own pattern, so metrics and logs show how much traffic still uses the alias
+		aliased := joinPattern(method, a.prefix+path)
+		rt.root.mux.Handle(aliased, withPattern(aliased, middleware.Chain(a.middlewares...)(routed)))
+	}
+}
+
+func joinPattern(method, path string) string {
+	if method == "" {
+		return path
+	}
+	return method + " " + path
+}
+
+func withPattern(pattern string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		middleware.SetRoutePattern(r.Context(), pattern) // so global middlewares (metrics, logs) know which route this was
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (rt *Router) HandleFunc(pattern string, handler http.HandlerFunc, middlewares ...middleware.Middleware) {
+	rt.Handle(pattern, handler, middlewares...)
+}
+
+// HandleLongLived registers a route that stays open for as long as the client wants, like a websocket or an event
+// stream. its requests are marked with middleware.WithLongLived before the global middlewares run, so the limiter,
+// the shedder and the slow request log leave them alone
+func (rt *Router) HandleLongLived(pattern string, handler http.Handler, middlewares ...middleware.Middleware) {
+	rt.Handle(pattern, handler, middlewares...)
+	method, path, found := strings.Cut(pattern, " ")
+	if !found {
+		method, path = "", pattern
+	}
+	rt.root.longLived[joinPattern(method, rt.prefix+path)] = true
+	for _, a := range rt.aliases {
+		rt.root.longLived[joinPattern(method, a.prefix+path)] = true
+	}
+}
+
+// Routes lists the full pattern of every route of the router and all its groups -> "GET /api/students/{id}"
+func (rt *Router) Routes() []string {
+	return append([]string(nil), rt.root.routes...)
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if len(rt.root.longLived) > 0 {
+		// the mux only looks the route up here, the global middlewares run before it serves
+		if _, pattern := rt.root.mux.Handler(r); rt.root.longLived[pattern] {
+			r = r.WithContext(middleware.WithLongLived(r.Context()))
+		}
+	}
+	rt.root.handler.ServeHTTP(w, r)
+}
+
+// Int64Param reads a {name} path value as int64. when it is missing or not a number it writes a 400 and returns false,
+// so handlers just do -> id, ok := router.Int64Param(w, r, "id"); if !ok { return }
+func Int64Param(w http.ResponseWriter, r *http.Request, name string) (int64, bool) {
+	value, err := strconv.ParseInt(r.PathValue(name), 10, 64)
+	if err != nil {
+		response.WriteJson(w, http.StatusBadRequest, response.GeneralError(fmt.Errorf("path parameter %s must be a number", name)))
+		return 0, false
+	}
+	return value, true
+}
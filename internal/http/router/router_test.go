@@ -0,0 +1,175 @@
+package router_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/http/middleware"
+	"github.com/manishtomar-cpi/go-server/internal/http/router"
+)
+
+func TestGroupsAndInt64Param(t *testing.T) {
+	t.Parallel()
+
+	// middleware that appends its name to a header, so we can see the order they ran in
+	tag := func(name string) middleware.Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Trace", name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	rt := router.New()
+	rt.UseGlobal(tag("global"))
+	api := rt.Group("/api", tag("api"))
+	v1 := api.Group("/v1", tag("v1"))
+	v1.HandleFunc("GET /students/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, ok := router.Int64Param(w, r, "id")
+		if !ok {
+			return
+		}
+		w.Write([]byte(strconv.FormatInt(id, 10)))
+	}, tag("route"))
+
+	type testCase struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+		wantTrace  []string
+	}
+
+	tests := []testCase{
+		{name: "parses_id", path: "/api/v1/students/42", wantStatus: http.StatusOK, wantBody: "42", wantTrace: []string{"global", "api", "v1", "route"}},
+		{name: "bad_id_is_400", path: "/api/v1/students/abc", wantStatus: http.StatusBadRequest, wantTrace: []string{"global", "api", "v1", "route"}},
+		{name: "outside_group_is_404_but_global_runs", path: "/students/42", wantStatus: http.StatusNotFound, wantTrace: []string{"global"}},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rr := httptest.NewRecorder()
+			rt.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("status mismatch: want %d, got %d", tc.wantStatus, rr.Code)
+			}
+			if tc.wantBody != "" && rr.Body.String() != tc.wantBody {
+				t.Fatalf("body mismatch: want %q, got %q", tc.wantBody, rr.Body.String())
+			}
+			if got := rr.Header().Values("X-Trace"); !reflect.DeepEqual(got, tc.wantTrace) {
+				t.Fatalf("want middlewares %v, got %v", tc.wantTrace, got)
+			}
+		})
+	}
+}
+
+func TestVersionAndAlias(t *testing.T) {
+	t.Parallel()
+
+	rt := router.New()
+	v1 := rt.Version("/api", "v1")
+	v1.Alias("/api", func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", "true")
+			next.ServeHTTP(w, r)
+		})
+	})
+	students := v1.Group("/students")
+	students.HandleFunc("GET /{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(router.APIVersion(r) + " " + r.PathValue("id")))
+	})
+
+	type testCase struct {
+		name           string
+		path           string
+		wantStatus     int
+		wantBody       string
+		wantDeprecated bool
+	}
+
+	tests := []testCase{
+		{name: "versioned", path: "/api/v1/students/7", wantStatus: http.StatusOK, wantBody: "v1 7"},
+		{name: "alias_runs_the_same_route", path: "/api/students/7", wantStatus: http.StatusOK, wantBody: "v1 7", wantDeprecated: true},
+		{name: "unknown_version", path: "/api/v2/students/7", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rr := httptest.NewRecorder()
+			rt.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("status mismatch: want %d, got %d", tc.wantStatus, rr.Code)
+			}
+			if tc.wantBody != "" && rr.Body.String() != tc.wantBody {
+				t.Fatalf("body mismatch: want %q, got %q", tc.wantBody, rr.Body.String())
+			}
+			if got := rr.Header().Get("Deprecation") == "true"; got != tc.wantDeprecated {
+				t.Fatalf("want deprecated %v, got %v", tc.wantDeprecated, got)
+			}
+		})
+	}
+
+	if got := rt.Routes(); !reflect.DeepEqual(got, []string{"GET /api/v1/students/{id}"}) {
+		t.Fatalf("aliases should not be listed, got %v", got)
+	}
+}
+
+func TestHandleLongLived(t *testing.T) {
+	t.Parallel()
+
+	var longLived bool
+	rt := router.New()
+	rt.UseGlobal(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			longLived = middleware.LongLived(r)
+			next.ServeHTTP(w, r)
+		})
+	})
+	v1 := rt.Version("/api", "v1")
+	v1.Alias("/api")
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	v1.HandleLongLived("GET /ws", ok)
+	v1.HandleFunc("GET /students", ok)
+
+	type testCase struct {
+		name    string
+		path    string
+		headers map[string]string
+		want    bool
+	}
+
+	tests := []testCase{
+		{name: "long_lived_route", path: "/api/v1/ws", want: true},
+		{name: "long_lived_route_alias", path: "/api/ws", want: true},
+		{name: "normal_route", path: "/api/v1/students"},
+		{name: "upgrade_header_on_normal_route", path: "/api/v1/students", headers: map[string]string{"Upgrade": "websocket", "Connection": "Upgrade"}},
+		{name: "event_stream_accept_on_normal_route", path: "/api/v1/students", headers: map[string]string{"Accept": "text/event-stream"}},
+		{name: "not_found", path: "/api/v1/nothing", headers: map[string]string{"Upgrade": "websocket"}},
+	}
+
+	for _, tc := range tests {
+		// not parallel, they share the router and what the global middleware saw
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			rt.ServeHTTP(httptest.NewRecorder(), req)
+			if longLived != tc.want {
+				t.Fatalf("want long lived %v, got %v", tc.want, longLived)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// NewHTTP3 builds the QUIC listener that runs next to the tcp one on the same port (udp),
+// nil when http3 is off. QUIC always needs TLS and can not run on a unix socket
+func NewHTTP3(cfg config.HTTPServer, handler http.Handler) *http3.Server {
+	if !cfg.HTTP3 {
+		return nil
+	}
+	if !cfg.TLS.Enabled() || strings.HasPrefix(cfg.Address, unixPrefix) {
+		slog.Warn("http3 needs tls and a tcp/udp address, not starting it", slog.String("address", cfg.Address))
+		return nil
+	}
+	return &http3.Server{
+		Addr:    cfg.Address,
+		Handler: handler,
+	}
+}
+
+// ServeHTTP3 starts the QUIC listener with the cert and key from config
+func ServeHTTP3(srv *http3.Server, cfg config.HTTPServer) error {
+	return srv.ListenAndServeTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+}
+
+// AltSvc adds the Alt-Svc header to tcp responses, that is how browsers and mobile clients learn they can switch to HTTP/3
+func AltSvc(srv *http3.Server) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if srv == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ProtoMajor < 3 {
+				srv.SetQUICHeaders(w.Header())
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package server_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/http/server"
+)
+
+// selfSignedTLS writes a cert and key for 127.0.0.1 that lives as long as the test
+func selfSignedTLS(t *testing.T) config.TLS {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("cert: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	dir := t.TempDir()
+	cfg := config.TLS{CertFile: filepath.Join(dir, "cert.pem"), KeyFile: filepath.Join(dir, "key.pem")}
+	if err := os.WriteFile(cfg.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write cert: %v", err)
+	}
+	if err := os.WriteFile(cfg.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	return cfg
+}
+
+func TestNewHTTP3(t *testing.T) {
+	t.Parallel()
+
+	certs := selfSignedTLS(t)
+
+	type testCase struct {
+		name string
+		cfg  config.HTTPServer
+		want bool // a server is built
+	}
+
+	tests := []testCase{
+		{name: "off", cfg: config.HTTPServer{Address: "127.0.0.1:8443", TLS: certs}},
+		{name: "no_tls", cfg: config.HTTPServer{Address: "127.0.0.1:8443", HTTP3: true}},
+		{name: "unix_socket", cfg: config.HTTPServer{Address: "unix:///tmp/go-server.sock", TLS: certs, HTTP3: true}},
+		{name: "on", cfg: config.HTTPServer{Address: "127.0.0.1:8443", TLS: certs, HTTP3: true}, want: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := server.NewHTTP3(tc.cfg, http.NotFoundHandler())
+			if got := srv != nil; got != tc.want {
+				t.Fatalf("want a server: %v, got %v", tc.want, srv)
+			}
+			if srv != nil && srv.Addr != tc.cfg.Address {
+				t.Fatalf("want the server on %s, got %s", tc.cfg.Address, srv.Addr)
+			}
+
+			// no server, no header, whatever the request
+			if srv == nil {
+				rec := httptest.NewRecorder()
+				server.AltSvc(srv)(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+				if got := rec.Header().Get("Alt-Svc"); got != "" {
+					t.Fatalf("want no Alt-Svc, got %q", got)
+				}
+			}
+		})
+	}
+}
+
+func TestAltSvc(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.HTTPServer{Address: "127.0.0.1:0", TLS: selfSignedTLS(t), HTTP3: true}
+	srv := server.NewHTTP3(cfg, http.NotFoundHandler())
+	served := make(chan error, 1)
+	go func() { served <- server.ServeHTTP3(srv, cfg) }()
+	t.Cleanup(func() { srv.Close(); <-served })
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	ts := httptest.NewUnstartedServer(server.AltSvc(srv)(ok))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+
+	// the port is only announced once the udp listener is up
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rec := httptest.NewRecorder()
+		server.AltSvc(srv)(ok).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Header().Get("Alt-Svc") != "" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("http3 listener did not start")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	http1 := ts.Client().Transport.(*http.Transport).Clone()
+	http1.ForceAttemptHTTP2 = false
+	http1.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	http1.TLSClientConfig.NextProtos = []string{"http/1.1"}
+
+	type testCase struct {
+		name      string
+		client    *http.Client
+		wantProto int
+	}
+
+	tests := []testCase{
+		{name: "http1", client: &http.Client{Transport: http1}, wantProto: 1},
+		{name: "http2", client: ts.Client(), wantProto: 2},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := tc.client.Get(ts.URL)
+			if err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			res.Body.Close()
+			if res.ProtoMajor != tc.wantProto {
+				t.Fatalf("want HTTP/%d, got %s", tc.wantProto, res.Proto)
+			}
+			if got := res.Header.Get("Alt-Svc"); !strings.HasPrefix(got, `h3=":`) {
+				t.Fatalf("want an h3 Alt-Svc, got %q", got)
+			}
+		})
+	}
+
+	// a client already on http3 is not told about it again
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.ProtoMajor = 3
+	server.AltSvc(srv)(ok).ServeHTTP(rec, req)
+	if got := rec.Header().Get("Alt-Svc"); got != "" {
+		t.Fatalf("http3 request: want no Alt-Svc, got %q", got)
+	}
+}
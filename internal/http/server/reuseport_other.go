@@ -0,0 +1,17 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package server
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+func reusePort(network, address string, c syscall.RawConn) error {
+	return fmt.Errorf("listener.reuse_port is not supported on %s", runtime.GOOS)
+}
+
+func maxBacklog() int {
+	return 0
+}
@@ -0,0 +1,32 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package server
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// reusePort sets SO_REUSEPORT before the socket is bound, it is the Control of the listen config
+func reusePort(network, address string, c syscall.RawConn) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	})
+	return errors.Join(err, sockErr)
+}
+
+// maxBacklog is the longest accept queue the kernel hands out, 0 when it can not be read (only linux has the file)
+func maxBacklog() int {
+	raw, err := os.ReadFile("/proc/sys/net/core/somaxconn")
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(string(raw)))
+	return n
+}
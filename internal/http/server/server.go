@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/manishtomar-cpi/go-server/internal/config"
+)
+
+const unixPrefix = "unix://"
+
+// New builds the http.Server for one listener and turns on the protocols asked for in its config
+func New(cfg config.HTTPServer, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:        cfg.Address,
+		Handler:     handler,
+		Protocols:   protocols(cfg),
+		IdleTimeout: cfg.IdleTimeout,
+	}
+}
+
+// which protocols this listener speaks.
+// HTTP/2 over TLS is negotiated with ALPN, h2c is HTTP/2 without TLS for when a proxy in front already did TLS
+func protocols(cfg config.HTTPServer) *http.Protocols {
+	p := new(http.Protocols)
+	p.SetHTTP1(true)
+	if !cfg.DisableHTTP2 {
+		p.SetHTTP2(true)
+		p.SetUnencryptedHTTP2(cfg.H2C)
+	}
+	return p
+}
+
+// Listen opens a tcp listener with the socket options of cfg.Listener, or a unix socket when address looks like
+// unix:///var/run/go-server.sock
+func Listen(cfg config.HTTPServer) (net.Listener, error) {
+	if !strings.HasPrefix(cfg.Address, unixPrefix) {
+		checkBacklog(cfg.Listener.Backlog, cfg.Address)
+		lc := listenConfig(cfg.Listener)
+		return lc.Listen(context.Background(), "tcp", cfg.Address)
+	}
+
+	path := strings.TrimPrefix(cfg.Address, unixPrefix)
+	mode, err := strconv.ParseUint(cfg.SocketMode, 8, 32) // permissions are written in octal like 0660
+	if err != nil {
+		return nil, fmt.Errorf("invalid socket_mode %q: %w", cfg.SocketMode, err)
+	}
+
+	// a socket file left behind by a crashed process would make listen fail with "address already in use"
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// the socket file is removed again when the listener is closed on shutdown
+	ln.(*net.UnixListener).SetUnlinkOnClose(true)
+
+	if err := os.Chmod(path, os.FileMode(mode)); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// listenConfig turns the listener options into the net.ListenConfig the socket is opened with
+func listenConfig(opts config.Listener) net.ListenConfig {
+	lc := net.ListenConfig{KeepAlive: opts.KeepAlive}
+	if opts.KeepAlive > 0 {
+		lc.KeepAliveConfig = net.KeepAliveConfig{
+			Enable:   true,
+			Idle:     opts.KeepAlive,
+			Interval: opts.KeepAliveInterval,
+			Count:    opts.KeepAliveCount,
+		}
+	}
+	if opts.ReusePort {
+		lc.Control = reusePort
+	}
+	return lc
+}
+
+// checkBacklog warns when the kernel would give the listener a shorter accept queue than the deployment expects,
+// connections over it are refused or retried by the clients during a burst
+func checkBacklog(want int, address string) {
+	if want <= 0 {
+		return
+	}
+	limit := maxBacklog()
+	if limit > 0 && want > limit {
+		slog.Warn("the kernel caps the accept queue below listener.backlog, raise net.core.somaxconn",
+			slog.String("address", address), slog.Int("backlog", want), slog.Int("somaxconn", limit))
+	}
+}
+
+// ListenAndServe starts the listener with TLS when cert and key are configured, plain otherwise
+func ListenAndServe(srv *http.Server, cfg config.HTTPServer) error {
+	ln, err := Listen(cfg)
+	if err != nil {
+		return err
+	}
+	return Serve(srv, ln, cfg)
+}
+
+// Serve is ListenAndServe for a listener that is already open (tests listen on port 0 and read the real port first)
+func Serve(srv *http.Server, ln net.Listener, cfg config.HTTPServer) error {
+	if cfg.TLS.Enabled() {
+		return srv.ServeTLS(ln, cfg.TLS.CertFile, cfg.TLS.KeyFile)
+	}
+	return srv.Serve(ln)
+}
@@ -0,0 +1,50 @@
+package server_test
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/http/server"
+)
+
+func TestListenReusePort(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("no SO_REUSEPORT on windows")
+	}
+
+	type testCase struct {
+		name       string
+		reusePort  bool
+		wantSecond bool // a second listener on the same port opens
+	}
+
+	tests := []testCase{
+		{name: "reuse_port", reusePort: true, wantSecond: true},
+		{name: "default", reusePort: false, wantSecond: false},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := config.HTTPServer{Address: "127.0.0.1:0", Listener: config.Listener{ReusePort: tc.reusePort}}
+			first, err := server.Listen(cfg)
+			if err != nil {
+				t.Fatalf("first listener: %v", err)
+			}
+			defer first.Close()
+
+			cfg.Address = first.Addr().String()
+			second, err := server.Listen(cfg)
+			if second != nil {
+				second.Close()
+			}
+			if got := err == nil; got != tc.wantSecond {
+				t.Fatalf("second listener on %s: want opened=%v, got err %v", cfg.Address, tc.wantSecond, err)
+			}
+		})
+	}
+}
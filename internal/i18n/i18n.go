@@ -0,0 +1,176 @@
+// Package i18n translates the messages clients read (error texts and validation messages) into the language
+// they ask for in Accept-Language. english is the source language, the code writes its messages in english and the
+// catalogs map them to other languages. hi and es ship with the binary, deployments add or override languages with
+// <lang>.json files in the i18n.dir folder
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/language"
+)
+
+// Default is the language of the messages in the code, used when nothing in Accept-Language is known
+const Default = "en"
+
+// Catalog is the translations of one language
+type Catalog struct {
+	// english text of a message -> translation, for the fixed messages like "limit must be between 1 and 500"
+	Messages map[string]string `json:"messages"`
+	// error code -> general message, used for texts with no translation of their own (they often carry an id or a name)
+	Codes map[string]string `json:"codes"`
+	// validation rule -> message for one field, {field} is replaced by the field name. the validation package has
+	// sentences of its own for en, es and hi, these are for the rules it has none for (like unique) and for other languages
+	Rules map[string]string `json:"rules"`
+}
+
+//go:embed locales/*.json
+var builtin embed.FS
+
+type registry struct {
+	mu       sync.RWMutex
+	catalogs map[string]Catalog
+	tags     []language.Tag // Default first, the matcher falls back to the first one
+	matcher  language.Matcher
+}
+
+var catalogs = &registry{catalogs: map[string]Catalog{}}
+
+func init() {
+	if err := Register(Default, Catalog{}); err != nil {
+		panic(err)
+	}
+	files, err := builtin.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	for _, f := range files {
+		data, err := builtin.ReadFile("locales/" + f.Name())
+		if err != nil {
+			panic(err)
+		}
+		if err := registerJSON(f.Name(), data); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Register adds the catalog of a language, entries of a language that is already there are replaced one by one
+func Register(lang string, c Catalog) error {
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return fmt.Errorf("i18n: language %q: %w", lang, err)
+	}
+	lang = tag.String()
+
+	catalogs.mu.Lock()
+	defer catalogs.mu.Unlock()
+	merged, ok := catalogs.catalogs[lang]
+	if !ok {
+		catalogs.tags = append(catalogs.tags, tag)
+		catalogs.matcher = language.NewMatcher(catalogs.tags)
+	}
+	merged.Messages = merge(merged.Messages, c.Messages)
+	merged.Codes = merge(merged.Codes, c.Codes)
+	merged.Rules = merge(merged.Rules, c.Rules)
+	catalogs.catalogs[lang] = merged
+	return nil
+}
+
+// LoadDir registers every <lang>.json in dir, like hi.json or pt-BR.json
+func LoadDir(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if err := registerJSON(filepath.Base(file), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func registerJSON(name string, data []byte) error {
+	var c Catalog
+	if err := json.Unmarshal(data, &c); err != nil {
+		return fmt.Errorf("i18n: %s: %w", name, err)
+	}
+	return Register(strings.TrimSuffix(name, ".json"), c)
+}
+
+// Match picks the registered language the Accept-Language header likes best, Default when there is none
+func Match(acceptLanguage string) string {
+	if acceptLanguage == "" {
+		return Default
+	}
+	prefs, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(prefs) == 0 {
+		return Default
+	}
+	catalogs.mu.RLock()
+	defer catalogs.mu.RUnlock()
+	_, i, confidence := catalogs.matcher.Match(prefs...)
+	if confidence == language.No {
+		return Default
+	}
+	return catalogs.tags[i].String()
+}
+
+// Message is text in lang -> its own translation, else the general message of code, else text unchanged
+func Message(lang, code, text string) string {
+	c, ok := catalog(lang)
+	if !ok {
+		return text
+	}
+	if translated, ok := c.Messages[text]; ok {
+		return translated
+	}
+	if general, ok := c.Codes[code]; ok {
+		return general
+	}
+	return text
+}
+
+// Rule is the message for a field that failed rule, false when lang has none for the rule
+func Rule(lang, rule, field string) (string, bool) {
+	c, ok := catalog(lang)
+	if !ok {
+		return "", false
+	}
+	msg, ok := c.Rules[rule]
+	if !ok {
+		return "", false
+	}
+	return strings.ReplaceAll(msg, "{field}", field), true
+}
+
+func catalog(lang string) (Catalog, bool) {
+	catalogs.mu.RLock()
+	defer catalogs.mu.RUnlock()
+	c, ok := catalogs.catalogs[lang]
+	return c, ok
+}
+
+func merge(dst, src map[string]string) map[string]string {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]string, len(src))
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
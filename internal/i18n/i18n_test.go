@@ -0,0 +1,85 @@
+package i18n_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/i18n"
+)
+
+func TestMatch(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		accept string
+		want   string
+	}
+
+	tests := []testCase{
+		{accept: "", want: "en"},
+		{accept: "es", want: "es"},
+		{accept: "es-MX,es;q=0.9", want: "es"},
+		{accept: "fr-FR, hi;q=0.8, en;q=0.5", want: "hi"},
+		{accept: "de", want: "en"},
+		{accept: "not a language!!", want: "en"},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.accept, func(t *testing.T) {
+			t.Parallel()
+
+			if got := i18n.Match(tc.accept); got != tc.want {
+				t.Fatalf("Match(%q): want %s, got %s", tc.accept, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestMessage(t *testing.T) {
+	t.Parallel()
+
+	if got := i18n.Message("es", "INVALID_REQUEST", "limit must be between 1 and 500"); got != "limit debe estar entre 1 y 500" {
+		t.Fatalf("own translation: got %q", got)
+	}
+	// a text with an id in it has no translation, the general message of the code is used
+	if got := i18n.Message("hi", "STUDENT_NOT_FOUND", "student with id 7: not found"); got != "छात्र नहीं मिला" {
+		t.Fatalf("code fallback: got %q", got)
+	}
+	if got := i18n.Message("en", "STUDENT_NOT_FOUND", "student with id 7: not found"); got != "student with id 7: not found" {
+		t.Fatalf("english stays as it is: got %q", got)
+	}
+	if got, ok := i18n.Rule("es", "required", "Name"); !ok || got != "el campo Name es obligatorio" {
+		t.Fatalf("rule: got %q %v", got, ok)
+	}
+	if _, ok := i18n.Rule("es", "no_such_rule", "Name"); ok {
+		t.Fatal("unknown rule: want false")
+	}
+}
+
+// not parallel, it adds a language every other test would see
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	catalog := `{"messages": {"empty body": "corpo vazio"}, "codes": {"NOT_FOUND": "não encontrado"}}`
+	if err := os.WriteFile(filepath.Join(dir, "pt-BR.json"), []byte(catalog), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := i18n.LoadDir(dir); err != nil {
+		t.Fatalf("LoadDir: %v", err)
+	}
+	lang := i18n.Match("pt-BR")
+	if lang != "pt-BR" {
+		t.Fatalf("want pt-BR to be matched after loading, got %s", lang)
+	}
+	if got := i18n.Message(lang, "INVALID_REQUEST", "empty body"); got != "corpo vazio" {
+		t.Fatalf("loaded message: got %q", got)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := i18n.LoadDir(dir); err == nil {
+		t.Fatal("broken catalog: want an error")
+	}
+}
@@ -0,0 +1,87 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrInProgress -> the first request with this key is still running, the retry has to wait for it
+var ErrInProgress = errors.New("a request with this idempotency key is still in progress")
+
+// Response is what gets replayed to a client retrying with the same key
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Store remembers the first response for every key.
+// Begin claims a key: it returns the stored response when there is one, ErrInProgress while the first request runs,
+// or (nil, nil) when the caller now owns the key and has to call Complete or Release.
+type Store interface {
+	Begin(ctx context.Context, key string, now time.Time) (*Response, error)
+	Complete(ctx context.Context, key string, resp Response, now time.Time) error
+	Release(ctx context.Context, key string) error // the request failed, the next retry runs the handler again
+}
+
+type entry struct {
+	resp    *Response // nil while in progress
+	expires time.Time
+}
+
+// MemoryStore keeps the responses in this process, they are lost on restart and not shared between instances
+type MemoryStore struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+// entries are only cleaned up once there are this many, same as the rate limit buckets
+const sweepAt = 10000
+
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{ttl: ttl, entries: map[string]*entry{}}
+}
+
+func (m *MemoryStore) Begin(ctx context.Context, key string, now time.Time) (*Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.entries[key]; ok && now.Before(e.expires) {
+		if e.resp == nil {
+			return nil, ErrInProgress
+		}
+		return e.resp, nil
+	}
+	if len(m.entries) >= sweepAt {
+		m.sweep(now)
+	}
+	// in progress entries expire too, so a crashed request can not block its key forever
+	m.entries[key] = &entry{expires: now.Add(m.ttl)}
+	return nil, nil
+}
+
+func (m *MemoryStore) Complete(ctx context.Context, key string, resp Response, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = &entry{resp: &resp, expires: now.Add(m.ttl)}
+	return nil
+}
+
+func (m *MemoryStore) Release(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+func (m *MemoryStore) sweep(now time.Time) {
+	for key, e := range m.entries {
+		if !now.Before(e.expires) {
+			delete(m.entries, key)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package ids
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sync/atomic"
+)
+
+// IDSource makes new unique ids (request ids, token ids, ...), tests swap it for Sequence to get predictable ids
+type IDSource interface {
+	NewID() string
+}
+
+// UUID makes random version 4 uuids
+type UUID struct{}
+
+func (UUID) NewID() string {
+	var b [16]byte
+	rand.Read(b[:])             // crypto/rand never returns an error on supported platforms
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // variant RFC 4122
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// Sequence gives prefix-1, prefix-2, ... for tests
+type Sequence struct {
+	Prefix string
+	n      atomic.Int64
+}
+
+func (s *Sequence) NewID() string {
+	return fmt.Sprintf("%s-%d", s.Prefix, s.n.Add(1))
+}
@@ -0,0 +1,36 @@
+package ids_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/ids"
+)
+
+func TestUUIDFormat(t *testing.T) {
+	t.Parallel()
+
+	uuidV4 := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		id := ids.UUID{}.NewID()
+		if !uuidV4.MatchString(id) {
+			t.Fatalf("not a v4 uuid: %q", id)
+		}
+		if seen[id] {
+			t.Fatalf("duplicate id %q", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestSequenceIsDeterministic(t *testing.T) {
+	t.Parallel()
+
+	seq := &ids.Sequence{Prefix: "req"}
+	for _, want := range []string{"req-1", "req-2", "req-3"} {
+		if got := seq.NewID(); got != want {
+			t.Fatalf("want %q, got %q", want, got)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+// Package importer stores large uploads of students in the background. the upload is streamed to a file in
+// imports.dir and checked on the way, then the import is stored with its job in one transaction. the client gets the
+// job id right away and follows the progress by it. the job reads the file and stores the records a batch at a time
+// together with the progress, so an attempt that failed or ran out of time goes on after the last stored batch and
+// never stores a record twice
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/events"
+	"github.com/manishtomar-cpi/go-server/internal/jobs"
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+// UploadError is an upload that was refused before anything was stored, the client can fix it and send it again
+type UploadError struct {
+	Err error
+}
+
+func (e *UploadError) Error() string { return "upload can not be imported: " + e.Err.Error() }
+func (e *UploadError) Unwrap() error { return e.Err }
+
+// Importer accepts uploads and runs their jobs
+type Importer struct {
+	store storage.ImportStore
+	bus   *events.Bus
+	queue *jobs.Queue
+	clock clock.Clock
+	cfg   config.Imports
+}
+
+func New(store storage.ImportStore, bus *events.Bus, queue *jobs.Queue, cfg config.Imports, clk clock.Clock) *Importer {
+	// zero values come from configs built in code (tests), they get the same defaults as the yaml
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = 10 * time.Second
+	}
+	if cfg.MaxRecordBytes <= 0 {
+		cfg.MaxRecordBytes = 64 << 10
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "go-server-imports")
+	}
+	im := &Importer{store: store, bus: bus, queue: queue, clock: clk, cfg: cfg}
+	queue.Register(types.ImportJob, jobs.Kind{
+		Handler:     im.run,
+		MaxAttempts: cfg.MaxAttempts,
+		BaseDelay:   cfg.BaseDelay,
+		MaxDelay:    cfg.MaxDelay,
+		Timeout:     cfg.Timeout,
+		Revive:      im.revive,
+	})
+	return im
+}
+
+// Create streams the upload to a file and stores the import with its job, then wakes the queue up. createdBy is the
+// kind:subject of the uploader. an upload that can not be read to the end, or a csv with a column the students do not
+// have, is an UploadError and leaves nothing behind
+func (im *Importer) Create(ctx context.Context, format string, body io.Reader, createdBy string) (_ types.Import, err error) {
+	if err := os.MkdirAll(im.cfg.Dir, 0o700); err != nil {
+		return types.Import{}, err
+	}
+	f, err := os.CreateTemp(im.cfg.Dir, "upload-*."+format)
+	if err != nil {
+		return types.Import{}, err
+	}
+	defer func() {
+		f.Close()
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+	// counted while it is written, so the upload is read once and never held in memory
+	total, err := Count(format, io.TeeReader(body, f), im.cfg.MaxRecordBytes)
+	if err != nil {
+		return types.Import{}, &UploadError{Err: err}
+	}
+	if total == 0 {
+		return types.Import{}, &UploadError{Err: errors.New("it has no records")}
+	}
+	if err := f.Sync(); err != nil { // the job has to find all of it, also after a crash right after the 202
+		return types.Import{}, err
+	}
+	imp, err := im.store.CreateImport(ctx, types.Import{Format: format, CreatedBy: createdBy, Upload: f.Name(), Total: total, CreatedAt: im.clock.Now()})
+	if err != nil {
+		return types.Import{}, err
+	}
+	im.queue.Wake()
+	return imp, nil
+}
+
+// Purge deletes the finished imports created before before, with their error reports and the uploads failed ones kept
+func (im *Importer) Purge(ctx context.Context, before time.Time) (int64, error) {
+	n, uploads, err := im.store.PurgeImports(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+	for _, upload := range uploads {
+		if err := os.Remove(upload); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("remove import upload failed", slog.String("upload", upload), slog.String("error", err.Error()))
+		}
+	}
+	return n, nil
+}
+
+// ETA is how long the rest of a running import takes at the pace it had so far, false while there is no pace yet
+func ETA(imp types.Import, now time.Time) (time.Duration, bool) {
+	if imp.Status != types.JobRunning || imp.StartedAt == nil || imp.Processed == 0 {
+		return 0, false
+	}
+	elapsed := now.Sub(*imp.StartedAt)
+	if elapsed <= 0 {
+		return 0, false
+	}
+	perRecord := elapsed / time.Duration(imp.Processed)
+	return perRecord * time.Duration(imp.Total-imp.Processed), true
+}
+
+// run is the job of one import. the import keeps the status of its job, so it still answers after done jobs are purged
+func (im *Importer) run(ctx context.Context, job types.Job) error {
+	id, err := strconv.ParseInt(job.Payload, 10, 64)
+	if err != nil {
+		return jobs.Permanent(fmt.Errorf("import id %q: %w", job.Payload, err))
+	}
+	imp, err := im.store.ImportById(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return jobs.Permanent(err)
+	}
+	if err != nil {
+		return err
+	}
+	if imp.FinishedAt != nil {
+		return nil // the last attempt finished it but died before the job was saved
+	}
+
+	err = im.work(ctx, &imp)
+	var upload *UploadError
+	now := im.clock.Now()
+	switch {
+	case err == nil:
+		imp.Status, imp.LastError, imp.FinishedAt = types.JobDone, "", &now
+		slog.Info("students imported", slog.Int64("import", imp.Id), slog.Int("created", imp.Created), slog.Int("failed", imp.Failed))
+	case errors.Is(err, jobs.ErrStopping):
+		imp.Status = types.JobPending
+		slog.Info("import paused for shutdown", slog.Int64("import", imp.Id), slog.Int("processed", imp.Processed), slog.Int("total", imp.Total))
+	case errors.Is(ctx.Err(), context.Canceled):
+		// shutdown ran out of time and cut it off (a timeout is DeadlineExceeded), the queue does not count the
+		// attempt either and runs the job again after the restart, even when it was the last one
+		imp.Status = types.JobPending
+		slog.Warn("import cut off by shutdown", slog.Int64("import", imp.Id), slog.Int("processed", imp.Processed), slog.Int("total", imp.Total))
+	case errors.As(err, &upload) || job.Attempts >= im.queue.Kind(job.Kind).MaxAttempts:
+		imp.Status, imp.LastError, imp.FinishedAt = types.JobFailed, err.Error(), &now
+	default:
+		imp.Status, imp.LastError = types.JobPending, err.Error()
+	}
+	// ctx may be over already, the status still has to be saved
+	saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if saveErr := im.store.UpdateImportStatus(saveCtx, imp); saveErr != nil {
+		slog.Error("save import status failed", slog.Int64("import", imp.Id), slog.String("error", saveErr.Error()))
+	} else if imp.Status == types.JobDone {
+		// the store dropped the path with it, a failed import keeps its file in case the job is requeued
+		if err := os.Remove(imp.Upload); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("remove import upload failed", slog.Int64("import", imp.Id), slog.String("error", err.Error()))
+		}
+	}
+	if upload != nil {
+		return jobs.Permanent(err) // the upload stays the same, so does the error
+	}
+	return err
+}
+
+// revive sets a failed import back to pending, for a dead job that is requeued. it goes on after the records it
+// stored before
+func (im *Importer) revive(ctx context.Context, payload string) error {
+	id, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		return fmt.Errorf("import id %q: %w", payload, err)
+	}
+	imp, err := im.store.ImportById(ctx, id)
+	if err != nil || imp.Status != types.JobFailed {
+		return err
+	}
+	imp.Status, imp.FinishedAt = types.JobPending, nil
+	return im.store.UpdateImportStatus(ctx, imp)
+}
+
+// item is one record of a batch, either a student to store or the reason it is not stored
+type item struct {
+	line    int
+	student *types.Student
+	failure *Failure
+}
+
+// work stores the records after imp.Processed a batch at a time, until the upload is through or ctx is over
+func (im *Importer) work(ctx context.Context, imp *types.Import) error {
+	f, err := os.Open(imp.Upload)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &UploadError{Err: errors.New("the uploaded file is gone")}
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	records, err := open(imp.Format, f, im.cfg.MaxRecordBytes)
+	if err != nil {
+		return &UploadError{Err: err}
+	}
+	for skipped := 0; skipped < imp.Processed; skipped++ {
+		if _, err := records.next(); err != nil {
+			return &UploadError{Err: fmt.Errorf("upload ended before the %d records already processed: %w", imp.Processed, err)}
+		}
+	}
+	now := im.clock.Now()
+	if imp.StartedAt == nil {
+		imp.StartedAt = &now
+	}
+	imp.Status = types.JobRunning
+
+	batch := make([]item, 0, im.cfg.BatchSize)
+	for done := false; !done; {
+		if err := ctx.Err(); err != nil {
+			return err // out of time, the next attempt goes on from here
+		}
+		select {
+		case <-jobs.Stopping(ctx):
+			return jobs.ErrStopping // every batch so far is saved with the progress, that is the checkpoint
+		default:
+		}
+		batch = batch[:0]
+		for len(batch) < im.cfg.BatchSize {
+			rec, err := records.next()
+			if errors.Is(err, io.EOF) {
+				done = true
+				break
+			}
+			if err != nil {
+				return &UploadError{Err: err}
+			}
+			if rec.Err != "" {
+				batch = append(batch, item{line: rec.Line, failure: &Failure{Error: rec.Err}})
+				continue
+			}
+			student, failure := Decode(rec.Raw)
+			if failure != nil {
+				batch = append(batch, item{line: rec.Line, failure: failure})
+				continue
+			}
+			student.UpdatedAt = im.clock.Now()
+			batch = append(batch, item{line: rec.Line, student: &student})
+		}
+		if err := im.save(ctx, imp, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// save stores the students of items and the progress after them. one taken email rolls the whole batch back, so
+// then every record is stored on its own and only the taken ones fail
+func (im *Importer) save(ctx context.Context, imp *types.Import, items []item) error {
+	next := *imp
+	next.Processed += len(items)
+	var (
+		students []types.Student
+		failures []types.ImportFailure
+	)
+	for _, it := range items {
+		if it.failure != nil {
+			next.Failed++
+			it.failure.Line = it.line
+			report, _ := json.Marshal(it.failure)
+			failures = append(failures, types.ImportFailure{Line: it.line, Report: string(report)})
+			continue
+		}
+		students = append(students, *it.student)
+	}
+	next.Created += len(students) // all of them or none, the batch is one transaction
+	ids, err := im.store.ImportBatch(ctx, next, students, failures)
+	var dup *storage.DuplicateError
+	if errors.As(err, &dup) {
+		if len(items) > 1 {
+			for _, it := range items {
+				if err := im.save(ctx, imp, []item{it}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		failure := &Failure{Error: dup.Error(), Fields: []response.FieldError{{Field: dup.Field, Rule: "unique", Message: dup.Field + " is already taken"}}}
+		return im.save(ctx, imp, []item{{line: items[0].line, failure: failure}})
+	}
+	if err != nil {
+		return err
+	}
+	*imp = next
+	for i, student := range students {
+		student.Id = ids[i]
+		if event, err := events.NewStudentCreated(student, student.UpdatedAt); err == nil {
+			im.bus.Publish(ctx, event)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,493 @@
+package importer_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/events"
+	"github.com/manishtomar-cpi/go-server/internal/importer"
+	"github.com/manishtomar-cpi/go-server/internal/jobs"
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/storage/sqlite"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+func TestCount(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name      string
+		format    string
+		body      string
+		want      int
+		wantError string // part of it, empty for none
+	}
+
+	tests := []testCase{
+		{name: "csv", format: types.ImportCSV, body: "name,email,age\nAsha,asha@example.com,21\nRavi,ravi@example.com,22\n", want: 2},
+		{name: "csv_header_any_case_with_bom", format: types.ImportCSV, body: "\ufeffName, Email ,AGE\nAsha,asha@example.com,21", want: 1},
+		{name: "csv_short_row_is_a_record", format: types.ImportCSV, body: "name,email,age\nAsha,asha@example.com\n", want: 1},
+		{name: "csv_unknown_column", format: types.ImportCSV, body: "name,email,age,grade\nAsha,asha@example.com,21,A", wantError: `column "grade"`},
+		{name: "csv_column_twice", format: types.ImportCSV, body: "name,email,name\n", wantError: "twice"},
+		{name: "csv_empty", format: types.ImportCSV, body: "", wantError: "no header row"},
+		{name: "csv_broken_quote", format: types.ImportCSV, body: "name,email,age\n\"Asha,asha@example.com,21\n", wantError: "quote"},
+		{name: "ndjson_blank_lines", format: types.ImportNDJSON, body: "{}\n\n{}\n", want: 2},
+		{name: "ndjson_line_too_long", format: types.ImportNDJSON, body: "{}\n" + strings.Repeat("x", 2000), wantError: "line 2 is longer"},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			n, err := importer.Count(tc.format, strings.NewReader(tc.body), 1024)
+			if tc.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+					t.Fatalf("error = %v, want one with %q", err, tc.wantError)
+				}
+				return
+			}
+			if err != nil || n != tc.want {
+				t.Fatalf("Count = %d, %v, want %d", n, err, tc.want)
+			}
+		})
+	}
+}
+
+// flakyStore fails the ImportBatch call number failOn like a db that is busy for a moment
+type flakyStore struct {
+	*sqlite.Sqlite
+	failOn int32
+	calls  atomic.Int32
+}
+
+func (s *flakyStore) ImportBatch(ctx context.Context, progress types.Import, students []types.Student, failures []types.ImportFailure) ([]int64, error) {
+	if s.calls.Add(1) == s.failOn {
+		return nil, fmt.Errorf("database is locked")
+	}
+	return s.Sqlite.ImportBatch(ctx, progress, students, failures)
+}
+
+func TestImport(t *testing.T) {
+	t.Parallel()
+
+	// rows 2 and 4 fail validation, row 6 has the email of row 1 in the same batch
+	var rows []string
+	for i := 1; i <= 7; i++ {
+		switch i {
+		case 2:
+			rows = append(rows, "Ravi,not an email,22")
+		case 4:
+			rows = append(rows, "Mia,mia@example.com,200")
+		case 6:
+			rows = append(rows, "Zoe,student1@example.com,19")
+		default:
+			rows = append(rows, fmt.Sprintf("Student,student%d@example.com,20", i))
+		}
+	}
+	upload := "name,email,age\n" + strings.Join(rows, "\n")
+
+	type testCase struct {
+		name      string
+		batchSize int
+		failOn    int32 // ImportBatch call that fails, 0 for none
+	}
+
+	tests := []testCase{
+		{name: "one_batch", batchSize: 10},
+		{name: "small_batches", batchSize: 2},
+		{name: "retried_after_a_failed_batch", batchSize: 2, failOn: 2},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			db, err := sqlite.New(&config.Config{Storage_path: filepath.Join(t.TempDir(), "test.db")})
+			if err != nil {
+				t.Fatalf("sqlite: %v", err)
+			}
+			t.Cleanup(func() { db.Close() })
+			store := &flakyStore{Sqlite: db, failOn: tc.failOn}
+			uploads := t.TempDir()
+			clk := clock.NewFake(time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC))
+			bus := events.NewBus()
+			var published atomic.Int32
+			bus.Subscribe(func(context.Context, events.Event) { published.Add(1) })
+			// the import is pending for a moment after its job started, the lock must outlive the clock moving on
+			queue := jobs.New(db, config.Jobs{PollInterval: 5 * time.Millisecond, Lease: time.Hour}, clk, nil)
+			im := importer.New(store, bus, queue, config.Imports{Dir: uploads, BatchSize: tc.batchSize, BaseDelay: time.Minute}, clk)
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() { queue.Run(ctx); close(done) }()
+			t.Cleanup(func() { cancel(); <-done; queue.Drain(context.Background()) })
+
+			imp, err := im.Create(context.Background(), types.ImportCSV, strings.NewReader(upload), "user:teacher")
+			if err != nil {
+				t.Fatalf("create: %v", err)
+			}
+			if imp.Total != 7 || imp.JobId == 0 || imp.Status != types.JobPending {
+				t.Fatalf("created import %+v, want 7 pending records with a job", imp)
+			}
+
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				imp, err = db.ImportByJob(context.Background(), imp.JobId)
+				if err != nil {
+					t.Fatalf("import: %v", err)
+				}
+				left, _ := os.ReadDir(uploads) // the upload goes once the done status is saved
+				if imp.FinishedAt != nil && len(left) == 0 {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("import did not finish or left its upload: %+v %v", imp, left)
+				}
+				if imp.Status == types.JobPending {
+					clk.Advance(time.Minute) // past the backoff of a failed attempt
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+
+			if imp.Status != types.JobDone || imp.Processed != 7 || imp.Created != 4 || imp.Failed != 3 {
+				t.Fatalf("import = %+v, want done with 4 created and 3 failed", imp)
+			}
+			students, err := db.ListStudents(context.Background(), storage.StudentQuery{Limit: 100, Sort: "id"})
+			if err != nil || len(students) != 4 {
+				t.Fatalf("students = %d %v, want 4, none twice", len(students), err)
+			}
+			if published.Load() != 4 {
+				t.Fatalf("published %d events, want one per created student", published.Load())
+			}
+			var errs strings.Builder
+			if err := db.ImportErrors(context.Background(), imp.Id, func(line []byte) error { errs.Write(line); return nil }); err != nil {
+				t.Fatalf("error report: %v", err)
+			}
+			report := strings.Split(strings.TrimSpace(errs.String()), "\n")
+			if len(report) != 3 || !strings.Contains(report[0], `"line":3`) || !strings.Contains(report[1], `"line":5`) ||
+				!strings.Contains(report[2], `"line":7`) || !strings.Contains(report[2], `"rule":"unique"`) {
+				t.Fatalf("error report %q, want lines 3, 5 and 7 with the taken email last", errs.String())
+			}
+			if _, ok := importer.ETA(imp, clk.Now()); ok {
+				t.Fatal("finished import has an eta")
+			}
+		})
+	}
+}
+
+// the error report is read a page at a time, in line order and without a line missing or twice
+func TestImportErrorsInPages(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite.New(&config.Config{Storage_path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("sqlite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	clk := clock.NewFake(time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC))
+	queue := jobs.New(db, config.Jobs{PollInterval: 5 * time.Millisecond}, clk, nil)
+	im := importer.New(db, events.NewBus(), queue, config.Imports{Dir: t.TempDir()}, clk)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { queue.Run(ctx); close(done) }()
+	t.Cleanup(func() { cancel(); <-done; queue.Drain(context.Background()) })
+
+	const bad = 2500 // over two pages of report
+	rows := make([]string, bad)
+	for i := range rows {
+		rows[i] = fmt.Sprintf("Student %d,not an email,20", i)
+	}
+	imp, err := im.Create(context.Background(), types.ImportCSV, strings.NewReader("name,email,age\n"+strings.Join(rows, "\n")), "user:teacher")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for imp.FinishedAt == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("import did not finish: %+v", imp)
+		}
+		time.Sleep(5 * time.Millisecond)
+		if imp, err = db.ImportByJob(context.Background(), imp.JobId); err != nil {
+			t.Fatalf("import: %v", err)
+		}
+	}
+
+	var lines []importer.Failure
+	err = db.ImportErrors(context.Background(), imp.Id, func(line []byte) error {
+		var failure importer.Failure
+		if err := json.Unmarshal(line, &failure); err != nil || line[len(line)-1] != '\n' {
+			t.Fatalf("report line %q: %v", line, err)
+		}
+		lines = append(lines, failure)
+		return nil
+	})
+	if err != nil || len(lines) != bad {
+		t.Fatalf("report = %d lines %v, want all %d failures", len(lines), err, bad)
+	}
+	for i, failure := range lines {
+		if failure.Line != i+2 { // the header is line 1
+			t.Fatalf("report line %d is for line %d, want %d", i, failure.Line, i+2)
+		}
+	}
+	if err := db.ImportErrors(context.Background(), 999, func([]byte) error { t.Fatal("line for an unknown import"); return nil }); err != nil {
+		t.Fatalf("report of an unknown import = %v", err)
+	}
+}
+
+// stoppingStore begins the shutdown of the queue once the first batch is saved, ctx is the one of the batch
+type stoppingStore struct {
+	*sqlite.Sqlite
+	stop func(ctx context.Context)
+	once sync.Once
+}
+
+func (s *stoppingStore) ImportBatch(ctx context.Context, progress types.Import, students []types.Student, failures []types.ImportFailure) ([]int64, error) {
+	ids, err := s.Sqlite.ImportBatch(ctx, progress, students, failures)
+	s.once.Do(func() { s.stop(ctx) })
+	return ids, err
+}
+
+func TestImportPausesForShutdown(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite.New(&config.Config{Storage_path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("sqlite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	clk := clock.NewFake(time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC))
+	cfg := config.Imports{Dir: t.TempDir(), BatchSize: 2}
+	var rows []string
+	for i := 1; i <= 5; i++ {
+		rows = append(rows, fmt.Sprintf("Student,student%d@example.com,20", i))
+	}
+
+	queue := jobs.New(db, config.Jobs{PollInterval: 5 * time.Millisecond}, clk, nil)
+	// a deadline that is already within the checkpoint, the import stops after the batch it is on
+	nearDeadline, stopNear := context.WithTimeout(context.Background(), 0)
+	defer stopNear()
+	im := importer.New(&stoppingStore{Sqlite: db, stop: func(context.Context) { queue.Stop(nearDeadline) }}, events.NewBus(), queue, cfg, clk)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { queue.Run(ctx); close(done) }()
+	imp, err := im.Create(context.Background(), types.ImportCSV, strings.NewReader("name,email,age\n"+strings.Join(rows, "\n")), "user:teacher")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	<-done // Run returns once the first batch stopped the queue
+	cancel()
+	drainCtx, stop := context.WithTimeout(context.Background(), 5*time.Second)
+	defer stop()
+	if err := queue.Drain(drainCtx); err != nil {
+		t.Fatalf("drain: %v", err)
+	}
+	paused, err := db.ImportByJob(context.Background(), imp.JobId)
+	if err != nil || paused.Status != types.JobPending || paused.Processed != 2 || paused.FinishedAt != nil {
+		t.Fatalf("import after shutdown = %+v %v, want pending after the first batch", paused, err)
+	}
+
+	// after the restart it goes on from the checkpoint
+	queue = jobs.New(db, config.Jobs{PollInterval: 5 * time.Millisecond}, clk, nil)
+	importer.New(db, events.NewBus(), queue, cfg, clk)
+	ctx, cancel = context.WithCancel(context.Background())
+	done = make(chan struct{})
+	go func() { queue.Run(ctx); close(done) }()
+	t.Cleanup(func() { cancel(); <-done; queue.Drain(context.Background()) })
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		imp, err = db.ImportByJob(context.Background(), imp.JobId)
+		if err != nil {
+			t.Fatalf("import: %v", err)
+		}
+		if imp.FinishedAt != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("import did not finish after the restart: %+v", imp)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if imp.Status != types.JobDone || imp.Processed != 5 || imp.Created != 5 {
+		t.Fatalf("import = %+v, want all 5 created once", imp)
+	}
+	var attempts int
+	if err := db.Db.QueryRow("SELECT attempts FROM jobs WHERE id = ?", imp.JobId).Scan(&attempts); err != nil || attempts != 1 {
+		t.Fatalf("job attempts = %d %v, want 1, the paused one does not count", attempts, err)
+	}
+}
+
+// shutdown with time to spare lets a running import go on, it finishes before the drain is over
+func TestImportFinishesDuringShutdown(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite.New(&config.Config{Storage_path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("sqlite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	clk := clock.NewFake(time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC))
+
+	queue := jobs.New(db, config.Jobs{PollInterval: 5 * time.Millisecond}, clk, nil)
+	drainCtx, stop := context.WithTimeout(context.Background(), time.Minute)
+	defer stop()
+	im := importer.New(&stoppingStore{Sqlite: db, stop: func(context.Context) { queue.Stop(drainCtx) }}, events.NewBus(), queue, config.Imports{Dir: t.TempDir(), BatchSize: 2}, clk)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { queue.Run(ctx); close(done) }()
+
+	imp, err := im.Create(context.Background(), types.ImportCSV, strings.NewReader("name,email,age\nAsha,asha@example.com,21\nRavi,ravi@example.com,22\nMeera,meera@example.com,23"), "user:teacher")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	<-done // Run returns once the first batch stopped the queue
+	cancel()
+	if err := queue.Drain(drainCtx); err != nil {
+		t.Fatalf("drain: %v", err)
+	}
+	if drainCtx.Err() != nil {
+		t.Fatal("drain waited until the deadline")
+	}
+
+	finished, err := db.ImportByJob(context.Background(), imp.JobId)
+	if err != nil || finished.Status != types.JobDone || finished.Processed != 3 || finished.Created != 3 || finished.FinishedAt == nil {
+		t.Fatalf("import after shutdown = %+v %v, want done with all 3 created", finished, err)
+	}
+}
+
+// a shutdown that runs out of time cancels the last attempt, the import waits for the restart instead of failing
+func TestImportCutOffOnLastAttempt(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite.New(&config.Config{Storage_path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("sqlite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	clk := clock.NewFake(time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC))
+
+	queue := jobs.New(db, config.Jobs{PollInterval: 5 * time.Millisecond}, clk, nil)
+	drained := make(chan error, 1)
+	cutOff := func(ctx context.Context) {
+		expired, cancel := context.WithTimeout(context.Background(), 0)
+		defer cancel()
+		go func() { drained <- queue.Drain(expired) }()
+		<-ctx.Done() // the batch is saved, the next one sees the cancelled ctx before anything else
+	}
+	im := importer.New(&stoppingStore{Sqlite: db, stop: cutOff}, events.NewBus(), queue, config.Imports{Dir: t.TempDir(), BatchSize: 2, MaxAttempts: 1}, clk)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { queue.Run(ctx); close(done) }()
+	t.Cleanup(func() { cancel(); <-done })
+
+	imp, err := im.Create(context.Background(), types.ImportCSV, strings.NewReader("name,email,age\nAsha,asha@example.com,21\nRavi,ravi@example.com,22\nMeera,meera@example.com,23"), "user:teacher")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := <-drained; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("drain = %v, want the deadline it ran out of", err)
+	}
+
+	cut, err := db.ImportByJob(context.Background(), imp.JobId)
+	if err != nil || cut.Status != types.JobPending || cut.FinishedAt != nil || cut.Processed != 2 {
+		t.Fatalf("import after shutdown = %+v %v, want pending after the first batch", cut, err)
+	}
+	var (
+		status   string
+		attempts int
+	)
+	if err := db.Db.QueryRow("SELECT status, attempts FROM jobs WHERE id = ?", imp.JobId).Scan(&status, &attempts); err != nil ||
+		status != types.JobPending || attempts != 0 {
+		t.Fatalf("job = %s after %d attempts %v, want pending with its attempt back", status, attempts, err)
+	}
+}
+
+func TestCreateRefusesBadUpload(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite.New(&config.Config{Storage_path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("sqlite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	clk := clock.NewFake(time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC))
+	uploads := t.TempDir()
+	im := importer.New(db, events.NewBus(), jobs.New(db, config.Jobs{}, clk, nil), config.Imports{Dir: uploads}, clk)
+
+	for _, upload := range []string{"name,email,age\n", "name,email,grade\nAsha,asha@example.com,A"} {
+		_, err := im.Create(context.Background(), types.ImportCSV, strings.NewReader(upload), "user:teacher")
+		var refused *importer.UploadError
+		if !errors.As(err, &refused) {
+			t.Errorf("Create(%q) = %v, want an UploadError", upload, err)
+		}
+	}
+	if left, _ := os.ReadDir(uploads); len(left) != 0 {
+		t.Fatalf("refused uploads left files behind: %v", left)
+	}
+}
+
+// a failed import keeps its upload for a requeue until it is purged
+func TestPurgeRemovesUploads(t *testing.T) {
+	t.Parallel()
+
+	db, err := sqlite.New(&config.Config{Storage_path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("sqlite: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	clk := clock.NewFake(time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC))
+	uploads := t.TempDir()
+	im := importer.New(db, events.NewBus(), jobs.New(db, config.Jobs{}, clk, nil), config.Imports{Dir: uploads}, clk)
+
+	imp, err := im.Create(context.Background(), types.ImportCSV, strings.NewReader("name,email,age\nAsha,asha@example.com,21"), "user:teacher")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	finished := clk.Now()
+	imp.Status, imp.LastError, imp.FinishedAt = types.JobFailed, "gave up", &finished
+	if err := db.UpdateImportStatus(context.Background(), imp); err != nil {
+		t.Fatalf("fail import: %v", err)
+	}
+	if left, _ := os.ReadDir(uploads); len(left) != 1 {
+		t.Fatalf("failed import has %d uploads, want its own kept", len(left))
+	}
+
+	if n, err := im.Purge(context.Background(), clk.Now()); err != nil || n != 0 {
+		t.Fatalf("purge before it is old enough = %d %v, want nothing", n, err)
+	}
+	if n, err := im.Purge(context.Background(), clk.Now().Add(time.Second)); err != nil || n != 1 {
+		t.Fatalf("purge = %d %v, want the failed import", n, err)
+	}
+	if left, _ := os.ReadDir(uploads); len(left) != 0 {
+		t.Fatalf("uploads left after the purge: %v", left)
+	}
+	if _, err := db.ImportById(context.Background(), imp.Id); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("purged import = %v, want ErrNotFound", err)
+	}
+}
+
+func TestETA(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	imp := types.Import{Status: types.JobRunning, Total: 1000, Processed: 250, StartedAt: &start}
+	eta, ok := importer.ETA(imp, start.Add(10*time.Second))
+	if !ok || eta != 30*time.Second {
+		t.Fatalf("eta = %v %v, want 30s for the 750 records left", eta, ok)
+	}
+	imp.Processed = 0
+	if _, ok := importer.ETA(imp, start.Add(10*time.Second)); ok {
+		t.Fatal("eta without any record processed")
+	}
+}
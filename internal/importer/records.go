@@ -0,0 +1,190 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/manishtomar-cpi/go-server/internal/http/dto"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+	"github.com/manishtomar-cpi/go-server/internal/utills/request"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+	"github.com/manishtomar-cpi/go-server/internal/validation"
+)
+
+// Failure is a record that was not stored, the error report has one json line of it per failed record
+type Failure struct {
+	Line   int                   `json:"line"` // where the record starts in the upload, the csv header is line 1
+	Error  string                `json:"error"`
+	Fields []response.FieldError `json:"fields,omitempty"` // which fields failed validation
+}
+
+// Decode reads one record the way POST /students reads its body, unknown fields and failed validations are a Failure
+// naming the fields. the caller sets the Line of it
+func Decode(raw []byte) (types.Student, *Failure) {
+	var req dto.CreateStudentRequest
+	if err := request.Unmarshal(raw, &req); err != nil {
+		var unknown *request.UnknownFieldError
+		if errors.As(err, &unknown) {
+			resp := unknown.Response()
+			return types.Student{}, &Failure{Error: resp.Error, Fields: resp.Fields}
+		}
+		return types.Student{}, &Failure{Error: err.Error()}
+	}
+	if err := validation.Struct(req); err != nil {
+		var validateErrs validator.ValidationErrors
+		if !errors.As(err, &validateErrs) {
+			return types.Student{}, &Failure{Error: err.Error()}
+		}
+		invalid := response.ValidationError(validateErrs)
+		return types.Student{}, &Failure{Error: invalid.Error, Fields: invalid.Fields}
+	}
+	return req.Student(), nil
+}
+
+// record is one student of an upload as a json object, Err is set instead when the record could not be read but the
+// ones after it can
+type record struct {
+	Line int
+	Raw  []byte
+	Err  string
+}
+
+// records reads an upload one record at a time, next returns io.EOF after the last one. any other error means
+// nothing after it can be read
+type records interface {
+	next() (record, error)
+}
+
+func open(format string, body io.Reader, maxRecordBytes int) (records, error) {
+	switch format {
+	case types.ImportNDJSON:
+		lines := bufio.NewScanner(body)
+		limit := max(maxRecordBytes, 1024)
+		lines.Buffer(make([]byte, 0, min(4096, limit)), limit) // a larger buffer would raise the limit
+		return &ndjsonRecords{lines: lines}, nil
+	case types.ImportCSV:
+		return openCSV(body)
+	default:
+		return nil, fmt.Errorf("unknown import format %q", format)
+	}
+}
+
+type ndjsonRecords struct {
+	lines *bufio.Scanner
+	line  int
+}
+
+func (n *ndjsonRecords) next() (record, error) {
+	for n.lines.Scan() {
+		n.line++
+		raw := bytes.TrimSpace(n.lines.Bytes())
+		if len(raw) == 0 {
+			continue // blank lines (a trailing newline) are not records
+		}
+		return record{Line: n.line, Raw: raw}, nil
+	}
+	if err := n.lines.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return record{}, fmt.Errorf("line %d is longer than imports.max_record_bytes", n.line+1)
+		}
+		return record{}, err
+	}
+	return record{}, io.EOF
+}
+
+// csvRecords turns every row into a json object with the header as keys, so a row is checked exactly like a json
+// record. cells that are whole numbers become numbers, empty cells are left out (a missing required field)
+type csvRecords struct {
+	r      *csv.Reader
+	header []string
+}
+
+// openCSV reads the header, a column the students do not have fails the whole upload and not every row of it
+func openCSV(body io.Reader) (*csvRecords, error) {
+	r := csv.NewReader(body)
+	r.TrimLeadingSpace = true
+	r.ReuseRecord = true
+	header, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("the csv has no header row")
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]any, len(header))
+	c := &csvRecords{r: r, header: make([]string, len(header))}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))) // excel starts the file with a bom
+		if _, twice := columns[name]; twice {
+			return nil, fmt.Errorf("column %q is in the header twice", name)
+		}
+		columns[name] = nil
+		c.header[i] = name
+	}
+	raw, _ := json.Marshal(columns)
+	var unknown *request.UnknownFieldError
+	if err := request.Unmarshal(raw, &dto.CreateStudentRequest{}); errors.As(err, &unknown) {
+		return nil, fmt.Errorf("column %q: %w", unknown.Field, unknown)
+	}
+	return c, nil
+}
+
+func (c *csvRecords) next() (record, error) {
+	row, err := c.r.Read()
+	if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+		return record{}, err
+	}
+	line, _ := c.r.FieldPos(0) // a row has at least one field, an empty line is no row
+	if err != nil {
+		return record{Line: line, Err: fmt.Sprintf("has %d cells, the header has %d", len(row), len(c.header))}, nil
+	}
+	object := make(map[string]any, len(row))
+	for i, cell := range row {
+		cell = strings.TrimSpace(cell)
+		switch {
+		case cell == "":
+		case isInt(cell):
+			object[c.header[i]] = json.Number(cell)
+		default:
+			object[c.header[i]] = cell
+		}
+	}
+	raw, err := json.Marshal(object)
+	if err != nil {
+		return record{}, err
+	}
+	return record{Line: line, Raw: raw}, nil
+}
+
+func isInt(s string) bool {
+	_, err := strconv.ParseInt(s, 10, 64)
+	return err == nil
+}
+
+// Count is how many records the upload has, it reads body to the end. an upload that can not be read to the end fails
+// here and not half way through the job
+func Count(format string, body io.Reader, maxRecordBytes int) (int, error) {
+	records, err := open(format, body, maxRecordBytes)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for {
+		_, err := records.next()
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		n++
+	}
+}
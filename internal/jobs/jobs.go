@@ -0,0 +1,502 @@
+// Package jobs runs background work outside of requests. every job is a row in storage first, so a restart or a crash
+// loses none of them -> a pool of workers picks up the due ones, runs each with a timeout and retries the failures
+// with backoff until the attempts of their kind are used up. a job that failed for good is kept as a dead job, which
+// an admin can requeue once the cause is fixed
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/anomaly"
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+// Handler runs one attempt of a job, job.Attempts already counts it. an error means try again later, unless it is
+// Permanent or the attempts are used up. ctx ends at the timeout of the kind, or when shutdown ran out of time. a
+// handler that takes long looks at Stopping(ctx) between its steps
+type Handler func(ctx context.Context, job types.Job) error
+
+// ErrStopping is returned by a handler that saw Stopping and saved how far it got, the job goes back to the queue
+// without using up an attempt and goes on after the restart
+var ErrStopping = errors.New("stopped for shutdown, goes on after the restart")
+
+type stoppingKey struct{}
+
+// Stopping is closed when shutdown is about to run out of time (config.Jobs.Checkpoint before its deadline), nil
+// (never closed) for a ctx that is not a job's. until then a job keeps working, it may finish in the drain time
+func Stopping(ctx context.Context) <-chan struct{} {
+	stopping, _ := ctx.Value(stoppingKey{}).(chan struct{})
+	return stopping
+}
+
+// Kind is how the jobs of one kind run. config.Jobs.Retry can change the retry fields of it
+type Kind struct {
+	Handler     Handler
+	MaxAttempts int           // the job failed for good after this many, <= 0 is 1
+	BaseDelay   time.Duration // retry delay after the first failure, doubling up to MaxDelay
+	MaxDelay    time.Duration // below BaseDelay is BaseDelay
+	Jitter      float64       // part of the retry delay taken off at random, 0 is config.Jobs.Jitter
+	Timeout     time.Duration // of one attempt, 0 is config.Jobs.Timeout
+	// Revive sets what a dead job of the kind works on (a delivery, an email) back to pending before Requeue runs
+	// the job again, a handler skips rows that are failed already. nil when there is nothing to set back
+	Revive func(ctx context.Context, payload string) error
+}
+
+// permanent is an error that no retry can fix
+type permanent struct{ err error }
+
+func (p permanent) Error() string { return p.err.Error() }
+func (p permanent) Unwrap() error { return p.err }
+
+// Permanent marks err as not worth retrying, the job fails for good right away
+func Permanent(err error) error {
+	return permanent{err: err}
+}
+
+// Backoff is the delay before the next attempt after attempts failures -> base, doubling up to max
+func Backoff(base, max time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	return min(delay, max)
+}
+
+// Jittered takes a random part of up to fraction off delay, so it never grows past the MaxDelay it came from
+func Jittered(delay time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 {
+		return delay
+	}
+	return delay - time.Duration(rand.Float64()*min(fraction, 1)*float64(delay))
+}
+
+// Queue runs the jobs of the registered kinds on cfg.Workers workers
+type Queue struct {
+	store storage.JobStore
+	cfg   config.Jobs
+	clock clock.Clock
+	kinds map[string]Kind
+	dead  *anomaly.Recorder // every job that failed for good shows on the anomalies page, nil in tests
+	names []string          // of kinds, what ClaimJobs looks for
+	wake  chan struct{}
+
+	// jobs run on base instead of the ctx of Run, so shutdown can let them finish after Run stopped picking up new ones
+	base   context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	busy     int            // workers running a job
+	draining bool           // set by Stop, no new job starts after it
+	running  sync.WaitGroup // one per running job
+	stopped  chan struct{}  // closed by Stop, Run returns
+	stopping chan struct{}  // closed near the deadline of Stop, what Stopping returns
+	checkpt  sync.Once      // closes stopping
+}
+
+func New(store storage.JobStore, cfg config.Jobs, clk clock.Clock, rec *anomaly.Recorder) *Queue {
+	// zero values come from configs built in code (tests), they get the same defaults as the yaml
+	if cfg.Workers <= 0 {
+		cfg.Workers = 4
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = time.Minute
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = 5 * time.Minute
+	}
+	if cfg.Checkpoint <= 0 {
+		cfg.Checkpoint = time.Second
+	}
+	base, cancel := context.WithCancel(context.Background())
+	return &Queue{
+		store:    store,
+		cfg:      cfg,
+		clock:    clk,
+		kinds:    map[string]Kind{},
+		dead:     rec,
+		wake:     make(chan struct{}, 1),
+		base:     base,
+		cancel:   cancel,
+		stopped:  make(chan struct{}),
+		stopping: make(chan struct{}),
+	}
+}
+
+// Register says how jobs of kind run, every kind is registered before Run starts
+func (q *Queue) Register(kind string, k Kind) {
+	if _, ok := q.kinds[kind]; ok {
+		panic("jobs: kind " + kind + " registered twice")
+	}
+	if k.Jitter == 0 {
+		k.Jitter = q.cfg.Jitter
+	}
+	if retry, ok := q.cfg.Retry[kind]; ok {
+		if retry.MaxAttempts > 0 {
+			k.MaxAttempts = retry.MaxAttempts
+		}
+		if retry.BaseDelay > 0 {
+			k.BaseDelay = retry.BaseDelay
+		}
+		if retry.MaxDelay > 0 {
+			k.MaxDelay = retry.MaxDelay
+		}
+		if retry.Jitter != nil {
+			k.Jitter = *retry.Jitter
+		}
+	}
+	if k.MaxAttempts <= 0 {
+		k.MaxAttempts = 1
+	}
+	if k.Timeout <= 0 {
+		k.Timeout = q.cfg.Timeout
+	}
+	if k.Timeout >= q.cfg.Lease {
+		// the lease would run out under a running attempt and another worker would run the job a second time
+		panic(fmt.Sprintf("jobs: kind %s times out after %s, not under the %s lease", kind, k.Timeout, q.cfg.Lease))
+	}
+	k.MaxDelay = max(k.MaxDelay, k.BaseDelay)
+	q.kinds[kind] = k
+	q.names = append(q.names, kind)
+}
+
+// Kind is how jobs of kind run, with what config.Jobs.Retry changed. handlers that keep a status of their own look at
+// its MaxAttempts to know the last attempt
+func (q *Queue) Kind(kind string) Kind {
+	return q.kinds[kind]
+}
+
+// Validate checks config.Jobs once every kind is registered -> a jitter that is not 0 to 1 is an error, a retry
+// policy for a kind nobody registered (its feature is off, or a typo) is only logged
+func (q *Queue) Validate() error {
+	if q.cfg.Jitter < 0 || q.cfg.Jitter > 1 {
+		return fmt.Errorf("jobs.jitter %v: want 0 to 1", q.cfg.Jitter)
+	}
+	for _, kind := range slices.Sorted(maps.Keys(q.cfg.Retry)) {
+		if jitter := q.cfg.Retry[kind].Jitter; jitter != nil && (*jitter < 0 || *jitter > 1) {
+			return fmt.Errorf("jobs.retry.%s.jitter %v: want 0 to 1", kind, *jitter)
+		}
+		if _, ok := q.kinds[kind]; !ok {
+			slog.Warn("jobs.retry has a kind that no job runs as", slog.String("kind", kind), slog.Any("kinds", q.names))
+		}
+	}
+	return nil
+}
+
+// Enqueue stores a job of kind to run as soon as a worker is free
+func (q *Queue) Enqueue(ctx context.Context, kind, payload string) (int64, error) {
+	if _, ok := q.kinds[kind]; !ok {
+		return 0, fmt.Errorf("jobs: unknown kind %q", kind)
+	}
+	now := q.clock.Now()
+	id, err := q.store.EnqueueJob(ctx, types.Job{Kind: kind, Payload: payload, RunAt: now, CreatedAt: now})
+	if err != nil {
+		return 0, err
+	}
+	q.Wake()
+	return id, nil
+}
+
+// Requeue runs dead job id again as a new job with all the attempts of its kind, and returns it with the new job id.
+// the Revive of the kind runs first. a dead job that was requeued before is a storage.ErrConflict
+func (q *Queue) Requeue(ctx context.Context, id int64) (types.DeadJob, error) {
+	dead, err := q.store.DeadJobById(ctx, id)
+	if err != nil {
+		return types.DeadJob{}, err
+	}
+	if dead.RequeuedAt != nil {
+		return types.DeadJob{}, fmt.Errorf("dead job %d was requeued as job %d: %w", id, dead.RequeuedJobId, storage.ErrConflict)
+	}
+	kind, ok := q.kinds[dead.Kind]
+	if !ok {
+		return types.DeadJob{}, fmt.Errorf("no job runs as kind %q here: %w", dead.Kind, storage.ErrConflict)
+	}
+	if kind.Revive != nil {
+		if err := kind.Revive(ctx, dead.Payload); err != nil {
+			return types.DeadJob{}, err
+		}
+	}
+	dead, err = q.store.RequeueDeadJob(ctx, id, q.clock.Now())
+	if err != nil {
+		return types.DeadJob{}, err
+	}
+	slog.InfoContext(ctx, "dead job requeued", slog.Int64("dead_job", id), slog.String("kind", dead.Kind), slog.Int64("job", dead.RequeuedJobId))
+	q.Wake()
+	return dead, nil
+}
+
+// Wake makes Run look for due jobs now instead of at the next poll, for jobs a store queued on its own
+func (q *Queue) Wake() {
+	select {
+	case q.wake <- struct{}{}:
+	default: // already woken
+	}
+}
+
+// Run starts due jobs until ctx is cancelled, the jobs that are running then are waited for by Drain
+func (q *Queue) Run(ctx context.Context) {
+	ticker := time.NewTicker(q.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		q.startDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.stopped:
+			return
+		case <-ticker.C:
+		case <-q.wake:
+		}
+	}
+}
+
+// startDue claims as many due jobs as there are free workers
+func (q *Queue) startDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		q.mu.Lock()
+		free := q.cfg.Workers - q.busy
+		if q.draining {
+			free = 0
+		}
+		q.mu.Unlock()
+		if free <= 0 {
+			return // a worker that finishes wakes Run up again
+		}
+
+		now := q.clock.Now()
+		claimed, err := q.store.ClaimJobs(ctx, q.names, now, now.Add(q.cfg.Lease), free)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.ErrorContext(ctx, "claim jobs failed", slog.String("error", err.Error()))
+			}
+			return
+		}
+		for _, job := range claimed {
+			q.start(job)
+		}
+		if len(claimed) < free {
+			return
+		}
+	}
+}
+
+func (q *Queue) start(job types.Job) {
+	q.mu.Lock()
+	if q.draining { // Stop was called since the claim, the job goes back for after the restart
+		q.mu.Unlock()
+		lease := *job.LockedUntil
+		job.Status = types.JobPending
+		job.LockedUntil = nil
+		q.save(job, lease)
+		return
+	}
+	q.busy++
+	q.running.Add(1)
+	q.mu.Unlock()
+	go q.run(job)
+}
+
+// run does one attempt of job and stores what comes next -> done, retry later or failed for good
+func (q *Queue) run(job types.Job) {
+	defer func() {
+		q.mu.Lock()
+		q.busy--
+		q.mu.Unlock()
+		q.running.Done()
+		q.Wake()
+	}()
+
+	kind := q.kinds[job.Kind]
+	ctx, cancel := context.WithTimeout(context.WithValue(q.base, stoppingKey{}, q.stopping), kind.Timeout)
+	job.Attempts++
+	err := call(ctx, kind.Handler, job)
+	cancel()
+
+	lease := *job.LockedUntil
+	job.LockedUntil = nil
+	if err != nil && (q.base.Err() != nil || errors.Is(err, ErrStopping)) {
+		// shutdown ran out of time and cut the job off, or the handler stopped at a checkpoint for it. the attempt
+		// does not count and the job runs again right after the restart
+		job.Attempts--
+		job.Status = types.JobPending
+		if errors.Is(err, ErrStopping) {
+			slog.Info("job stopped at a checkpoint for shutdown", slog.Int64("job", job.Id), slog.String("kind", job.Kind))
+		} else {
+			slog.Warn("job cut off by shutdown", slog.Int64("job", job.Id), slog.String("kind", job.Kind), slog.String("error", err.Error()))
+		}
+		q.save(job, lease)
+		return
+	}
+
+	now := q.clock.Now()
+	switch {
+	case err == nil:
+		job.Status = types.JobDone
+		job.LastError = ""
+		job.FinishedAt = &now
+	case errors.As(err, new(permanent)) || job.Attempts >= kind.MaxAttempts:
+		job.Status = types.JobFailed
+		job.LastError = err.Error()
+		job.FinishedAt = &now
+		slog.Warn("job failed for good", slog.Int64("job", job.Id), slog.String("kind", job.Kind),
+			slog.Int("attempts", job.Attempts), slog.String("error", err.Error()))
+		q.fail(job, lease)
+		return
+	default:
+		job.Status = types.JobPending
+		job.LastError = err.Error()
+		job.RunAt = now.Add(Jittered(Backoff(kind.BaseDelay, kind.MaxDelay, job.Attempts), kind.Jitter))
+	}
+	q.save(job, lease)
+}
+
+// call runs the handler, a panic fails the attempt instead of the whole server
+func call(ctx context.Context, h Handler, job types.Job) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("job panicked: %v", v)
+		}
+	}()
+	return h(ctx, job)
+}
+
+// save runs after the job ctx may be gone, it gets its own short one. lease is the lock the job was claimed with, once
+// it ran out and another worker claimed the job that worker's result is the one kept
+func (q *Queue) save(job types.Job, lease time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := q.store.UpdateJob(ctx, job, lease)
+	switch {
+	case errors.Is(err, storage.ErrLeaseLost):
+		slog.Warn("job lost its lease, another worker has it", slog.Int64("job", job.Id), slog.String("kind", job.Kind))
+	case err != nil:
+		slog.Error("save job failed", slog.Int64("job", job.Id), slog.String("kind", job.Kind), slog.String("error", err.Error()))
+	}
+}
+
+// fail saves the job as failed and dead, like save
+func (q *Queue) fail(job types.Job, lease time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := q.store.FailJob(ctx, job, lease)
+	if errors.Is(err, storage.ErrLeaseLost) {
+		slog.Warn("job lost its lease, another worker has it", slog.Int64("job", job.Id), slog.String("kind", job.Kind))
+		return
+	}
+	if err != nil {
+		slog.Error("save dead job failed", slog.Int64("job", job.Id), slog.String("kind", job.Kind), slog.String("error", err.Error()))
+	}
+	if q.dead != nil {
+		q.dead.Record(anomaly.DeadLetter, "job failed for good", map[string]string{
+			"job":   strconv.FormatInt(job.Id, 10),
+			"kind":  job.Kind,
+			"error": job.LastError,
+		})
+	}
+}
+
+// Stop is the start of shutdown -> Run stops and no new job starts, the running ones go on until config.Jobs.Checkpoint
+// before the deadline of ctx, then Stopping is closed so they save their progress. it returns how many are running
+func (q *Queue) Stop(ctx context.Context) int {
+	q.mu.Lock()
+	if !q.draining {
+		q.draining = true
+		close(q.stopped)
+		slog.Info("job queue stopped taking jobs", slog.Int("running", q.busy))
+	}
+	running := q.busy
+	q.mu.Unlock()
+
+	deadline, ok := ctx.Deadline()
+	switch {
+	case ctx.Err() != nil || (ok && time.Until(deadline) <= q.cfg.Checkpoint):
+		q.checkpoint() // no time to go on, before Stop returns
+	default:
+		go q.checkpointBefore(ctx, deadline, ok)
+	}
+	return running
+}
+
+// checkpointBefore closes Stopping a checkpoint ahead of the deadline of ctx, or once ctx is over when it has none
+func (q *Queue) checkpointBefore(ctx context.Context, deadline time.Time, hasDeadline bool) {
+	var at <-chan time.Time
+	if hasDeadline {
+		timer := time.NewTimer(time.Until(deadline) - q.cfg.Checkpoint)
+		defer timer.Stop()
+		at = timer.C
+	}
+	select {
+	case <-at:
+	case <-ctx.Done():
+	case <-q.stopping:
+		return
+	}
+	q.checkpoint()
+}
+
+// checkpoint closes Stopping, also once nothing runs anymore so no checkpointBefore is left waiting
+func (q *Queue) checkpoint() {
+	q.checkpt.Do(func() {
+		if running := q.Running(); running > 0 {
+			slog.Info("telling the running jobs to save their progress", slog.Int("running", running))
+		}
+		close(q.stopping)
+	})
+}
+
+// Running is how many jobs run right now
+func (q *Queue) Running() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.busy
+}
+
+// Drain calls Stop and waits for the running jobs, it has the signature of a shutdown hook. when ctx ends first they
+// are cancelled and go back to the queue, one that ignores its ctx is given up on a second later
+func (q *Queue) Drain(ctx context.Context) error {
+	if running := q.Stop(ctx); running > 0 {
+		slog.Info("waiting for running jobs", slog.Int("running", running))
+	}
+	started := time.Now()
+
+	done := make(chan struct{})
+	go func() {
+		q.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		q.checkpoint()
+		slog.Info("job queue drained", slog.Duration("took", time.Since(started)))
+		return nil
+	case <-ctx.Done():
+		select {
+		case <-done: // both were ready, nothing is running
+			return nil
+		default:
+		}
+		slog.Warn("drain timeout reached, cancelling the running jobs", slog.Int("running", q.Running()))
+		q.checkpoint()
+		q.cancel()
+		// a moment for the cancelled jobs to put themselves back before the storage is closed
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+		}
+		return fmt.Errorf("jobs were still running: %w", ctx.Err())
+	}
+}
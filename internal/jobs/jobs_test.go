@@ -0,0 +1,571 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/anomaly"
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/jobs"
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/storage/sqlite"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+func newStore(t *testing.T) *sqlite.Sqlite {
+	t.Helper()
+	store, err := sqlite.New(&config.Config{Storage_path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("sqlite: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// start runs the queue until the test ends
+func start(t *testing.T, queue *jobs.Queue) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { queue.Run(ctx); close(done) }()
+	t.Cleanup(func() { cancel(); <-done; queue.Drain(context.Background()) })
+}
+
+func jobState(t *testing.T, store *sqlite.Sqlite, id int64) (status string, attempts int) {
+	t.Helper()
+	if err := store.Db.QueryRow("SELECT status, attempts FROM jobs WHERE id = ?", id).Scan(&status, &attempts); err != nil {
+		t.Fatalf("read job: %v", err)
+	}
+	return status, attempts
+}
+
+// waitFinished moves the fake clock past every backoff until the job is done or failed,
+// never while the job runs, its lock would run out under it
+func waitFinished(t *testing.T, store *sqlite.Sqlite, clk *clock.Fake, id int64) (string, int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		status, attempts := jobState(t, store, id)
+		if status == types.JobDone || status == types.JobFailed {
+			return status, attempts
+		}
+		if status == types.JobPending {
+			clk.Advance(time.Minute)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("job did not finish")
+	return "", 0
+}
+
+func TestQueue(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name         string
+		handler      func(calls int32) func(ctx context.Context) error // calls counts this attempt too
+		wantStatus   string
+		wantAttempts int
+	}
+
+	tests := []testCase{
+		{
+			name:         "first_try",
+			handler:      func(int32) func(context.Context) error { return func(context.Context) error { return nil } },
+			wantStatus:   types.JobDone,
+			wantAttempts: 1,
+		},
+		{
+			name: "retried",
+			handler: func(calls int32) func(context.Context) error {
+				return func(context.Context) error {
+					if calls <= 2 {
+						return errors.New("receiver is down")
+					}
+					return nil
+				}
+			},
+			wantStatus:   types.JobDone,
+			wantAttempts: 3,
+		},
+		{
+			name: "gives_up",
+			handler: func(int32) func(context.Context) error {
+				return func(context.Context) error { return errors.New("receiver is down") }
+			},
+			wantStatus:   types.JobFailed,
+			wantAttempts: 3,
+		},
+		{
+			name: "permanent_error_is_not_retried",
+			handler: func(int32) func(context.Context) error {
+				return func(context.Context) error { return jobs.Permanent(errors.New("bad payload")) }
+			},
+			wantStatus:   types.JobFailed,
+			wantAttempts: 1,
+		},
+		{
+			name: "panic_is_a_failed_attempt",
+			handler: func(calls int32) func(context.Context) error {
+				return func(context.Context) error {
+					if calls == 1 {
+						panic("boom")
+					}
+					return nil
+				}
+			},
+			wantStatus:   types.JobDone,
+			wantAttempts: 2,
+		},
+		{
+			name: "timeout_is_a_failed_attempt",
+			handler: func(calls int32) func(context.Context) error {
+				return func(ctx context.Context) error {
+					if calls == 1 {
+						<-ctx.Done()
+						return ctx.Err()
+					}
+					return nil
+				}
+			},
+			wantStatus:   types.JobDone,
+			wantAttempts: 2,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := newStore(t)
+			clk := clock.NewFake(time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC))
+			queue := jobs.New(store, config.Jobs{PollInterval: 5 * time.Millisecond}, clk, nil)
+			var calls atomic.Int32
+			queue.Register("test", jobs.Kind{
+				Handler: func(ctx context.Context, job types.Job) error {
+					n := calls.Add(1)
+					if job.Attempts != int(n) || job.Payload != "payload" {
+						t.Errorf("attempt %d got job %+v", n, job)
+					}
+					return tc.handler(n)(ctx)
+				},
+				MaxAttempts: 3,
+				BaseDelay:   time.Minute,
+				Timeout:     20 * time.Millisecond,
+			})
+			start(t, queue)
+
+			id, err := queue.Enqueue(context.Background(), "test", "payload")
+			if err != nil {
+				t.Fatalf("enqueue: %v", err)
+			}
+			status, attempts := waitFinished(t, store, clk, id)
+			if status != tc.wantStatus || attempts != tc.wantAttempts {
+				t.Fatalf("job = %s after %d attempts, want %s after %d", status, attempts, tc.wantStatus, tc.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestQueueUnknownKind(t *testing.T) {
+	t.Parallel()
+
+	queue := jobs.New(newStore(t), config.Jobs{}, clock.System{}, nil)
+	if _, err := queue.Enqueue(context.Background(), "nobody-runs-this", ""); err == nil {
+		t.Fatal("want an error for a kind without handler")
+	}
+}
+
+// a job whose worker died (the lock ran out while it was running) is picked up again
+func TestQueueTakesOverExpiredLock(t *testing.T) {
+	t.Parallel()
+
+	store := newStore(t)
+	clk := clock.NewFake(time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC))
+	id, err := store.EnqueueJob(context.Background(), types.Job{Kind: "test", RunAt: clk.Now(), CreatedAt: clk.Now()})
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	// claimed by a worker that never finishes it
+	if claimed, err := store.ClaimJobs(context.Background(), []string{"test"}, clk.Now(), clk.Now().Add(5*time.Minute), 10); err != nil || len(claimed) != 1 {
+		t.Fatalf("claim: %v %v", claimed, err)
+	}
+
+	queue := jobs.New(store, config.Jobs{PollInterval: 5 * time.Millisecond}, clk, nil)
+	queue.Register("test", jobs.Kind{Handler: func(context.Context, types.Job) error { return nil }})
+	start(t, queue)
+
+	time.Sleep(20 * time.Millisecond)
+	if status, _ := jobState(t, store, id); status != types.JobRunning {
+		t.Fatalf("job taken over while its lock holds, status %s", status)
+	}
+	clk.Advance(5 * time.Minute)
+	if status, _ := waitFinished(t, store, clk, id); status != types.JobDone {
+		t.Fatalf("job = %s after the lock ran out, want done", status)
+	}
+}
+
+// a worker whose lock ran out and was claimed again saves nothing, the job stays with the worker that claimed it last
+func TestQueueLostLease(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name string
+		err  error // of the attempt that lost its lease
+	}
+
+	tests := []testCase{
+		{name: "done", err: nil},
+		{name: "failed_for_good", err: jobs.Permanent(errors.New("bad payload"))},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := newStore(t)
+			clk := clock.NewFake(time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC))
+			queue := jobs.New(store, config.Jobs{PollInterval: 5 * time.Millisecond}, clk, nil)
+			started, release := make(chan struct{}), make(chan struct{})
+			queue.Register("test", jobs.Kind{Handler: func(context.Context, types.Job) error {
+				close(started)
+				<-release
+				return tc.err
+			}})
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() { queue.Run(ctx); close(done) }()
+
+			id, err := queue.Enqueue(context.Background(), "test", "")
+			if err != nil {
+				t.Fatalf("enqueue: %v", err)
+			}
+			<-started
+			// what a claim by another worker does once the lock ran out
+			takenOver := clk.Now().Add(10 * time.Minute)
+			if _, err := store.Db.Exec("UPDATE jobs SET locked_until = ? WHERE id = ?", takenOver.UTC(), id); err != nil {
+				t.Fatalf("take over: %v", err)
+			}
+			close(release)
+			cancel()
+			<-done
+			if err := queue.Drain(context.Background()); err != nil {
+				t.Fatalf("drain: %v", err)
+			}
+
+			if status, attempts := jobState(t, store, id); status != types.JobRunning || attempts != 0 {
+				t.Fatalf("job = %s after %d attempts, want still running for the other worker", status, attempts)
+			}
+			if dead, err := store.ListDeadJobs(context.Background(), "", 10); err != nil || len(dead) != 0 {
+				t.Fatalf("dead jobs = %v %v, want none", dead, err)
+			}
+			// the worker holding the lease still saves
+			if err := store.UpdateJob(context.Background(), types.Job{Id: id, Status: types.JobDone, RunAt: clk.Now()}, takenOver); err != nil {
+				t.Fatalf("save with the current lease: %v", err)
+			}
+			err = store.UpdateJob(context.Background(), types.Job{Id: id, Status: types.JobPending, RunAt: clk.Now()}, takenOver)
+			if !errors.Is(err, storage.ErrLeaseLost) {
+				t.Fatalf("save after the job was done: want ErrLeaseLost, got %v", err)
+			}
+		})
+	}
+}
+
+func TestQueueDrain(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name         string
+		drainFor     time.Duration
+		wantErr      bool
+		wantStatus   string // of the job that was running
+		wantAttempts int
+	}
+
+	tests := []testCase{
+		{name: "waits_for_the_running_job", drainFor: 5 * time.Second, wantStatus: types.JobDone, wantAttempts: 1},
+		{name: "out_of_time_puts_it_back", drainFor: 20 * time.Millisecond, wantErr: true, wantStatus: types.JobPending, wantAttempts: 0},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := newStore(t)
+			clk := clock.NewFake(time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC))
+			queue := jobs.New(store, config.Jobs{PollInterval: 5 * time.Millisecond}, clk, nil)
+			started := make(chan struct{})
+			queue.Register("test", jobs.Kind{
+				Handler: func(ctx context.Context, job types.Job) error {
+					close(started)
+					select {
+					case <-time.After(200 * time.Millisecond):
+						return nil
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				},
+				Timeout: time.Minute,
+			})
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() { queue.Run(ctx); close(done) }()
+
+			id, err := queue.Enqueue(context.Background(), "test", "")
+			if err != nil {
+				t.Fatalf("enqueue: %v", err)
+			}
+			<-started
+			cancel() // shutdown begins -> no new jobs, the running one is drained
+			<-done
+
+			drainCtx, stop := context.WithTimeout(context.Background(), tc.drainFor)
+			defer stop()
+			if err := queue.Drain(drainCtx); (err != nil) != tc.wantErr {
+				t.Fatalf("drain error = %v, want error %v", err, tc.wantErr)
+			}
+			if status, attempts := jobState(t, store, id); status != tc.wantStatus || attempts != tc.wantAttempts {
+				t.Fatalf("job = %s after %d attempts, want %s after %d", status, attempts, tc.wantStatus, tc.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	t.Parallel()
+
+	off := 0.0
+	queue := jobs.New(newStore(t), config.Jobs{Jitter: 0.2, Retry: map[string]config.Retry{
+		"tuned": {MaxAttempts: 10, MaxDelay: time.Hour, Jitter: &off},
+	}}, clock.System{}, nil)
+	queue.Register("tuned", jobs.Kind{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Minute})
+	queue.Register("plain", jobs.Kind{MaxAttempts: 3, BaseDelay: time.Second})
+
+	want := map[string]jobs.Kind{
+		"tuned": {MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: time.Hour, Jitter: 0, Timeout: time.Minute},
+		"plain": {MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: time.Second, Jitter: 0.2, Timeout: time.Minute},
+	}
+	for name, w := range want {
+		got := queue.Kind(name)
+		got.Handler = nil
+		if got.MaxAttempts != w.MaxAttempts || got.BaseDelay != w.BaseDelay || got.MaxDelay != w.MaxDelay ||
+			got.Jitter != w.Jitter || got.Timeout != w.Timeout {
+			t.Errorf("kind %s = %+v, want %+v", name, got, w)
+		}
+	}
+	if err := queue.Validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+
+	tooMuch := 1.5
+	queue = jobs.New(newStore(t), config.Jobs{Retry: map[string]config.Retry{"tuned": {Jitter: &tooMuch}}}, clock.System{}, nil)
+	if err := queue.Validate(); err == nil {
+		t.Fatal("want an error for a jitter over 1")
+	}
+}
+
+// an attempt has to end before its lease does, or another worker runs the job a second time
+func TestRegisterTimeoutOverLease(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name      string
+		timeout   time.Duration
+		wantPanic bool
+	}
+
+	tests := []testCase{
+		{name: "under", timeout: 4 * time.Minute},
+		{name: "default", timeout: 0},
+		{name: "equal", timeout: 5 * time.Minute, wantPanic: true},
+		{name: "over", timeout: 10 * time.Minute, wantPanic: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			queue := jobs.New(newStore(t), config.Jobs{Lease: 5 * time.Minute}, clock.System{}, nil)
+			defer func() {
+				if r := recover(); (r != nil) != tc.wantPanic {
+					t.Fatalf("want a panic: %v, got %v", tc.wantPanic, r)
+				}
+			}()
+			queue.Register("test", jobs.Kind{Timeout: tc.timeout})
+		})
+	}
+}
+
+func TestJittered(t *testing.T) {
+	t.Parallel()
+
+	if got := jobs.Jittered(time.Minute, 0); got != time.Minute {
+		t.Fatalf("no jitter: got %v, want a minute", got)
+	}
+	for range 100 {
+		if got := jobs.Jittered(time.Minute, 0.25); got > time.Minute || got < 45*time.Second {
+			t.Fatalf("jittered minute = %v, want 45s to 1m", got)
+		}
+	}
+}
+
+// a job that failed for good is kept as a dead job, requeueing it sets its row back first and runs it again once
+func TestDeadJobRequeue(t *testing.T) {
+	t.Parallel()
+
+	store := newStore(t)
+	clk := clock.NewFake(time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC))
+	anomalies := anomaly.NewRecorder(10, clk)
+	queue := jobs.New(store, config.Jobs{PollInterval: 5 * time.Millisecond}, clk, anomalies)
+	var fixed atomic.Bool
+	var revived atomic.Value
+	queue.Register("test", jobs.Kind{
+		Handler: func(context.Context, types.Job) error {
+			if !fixed.Load() {
+				return errors.New("receiver is down")
+			}
+			return nil
+		},
+		MaxAttempts: 2,
+		BaseDelay:   time.Minute,
+		Revive: func(_ context.Context, payload string) error {
+			revived.Store(payload)
+			return nil
+		},
+	})
+	start(t, queue)
+
+	id, err := queue.Enqueue(context.Background(), "test", "payload")
+	if err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if status, _ := waitFinished(t, store, clk, id); status != types.JobFailed {
+		t.Fatalf("job = %s, want failed", status)
+	}
+	dead, err := store.ListDeadJobs(context.Background(), "test", 10)
+	if err != nil || len(dead) != 1 || dead[0].JobId != id || dead[0].Attempts != 2 || dead[0].LastError != "receiver is down" {
+		t.Fatalf("dead jobs = %+v %v, want the failed job after 2 attempts", dead, err)
+	}
+	if other, _ := store.ListDeadJobs(context.Background(), "other", 10); len(other) != 0 {
+		t.Fatalf("dead jobs of another kind = %+v, want none", other)
+	}
+	recorded := anomalies.Snapshot()
+	if len(recorded) != 1 || recorded[0].Kind != anomaly.DeadLetter || recorded[0].Recent[0].Attrs["job"] != strconv.FormatInt(id, 10) ||
+		recorded[0].Recent[0].Attrs["kind"] != "test" || recorded[0].Recent[0].Attrs["error"] != "receiver is down" {
+		t.Fatalf("anomalies = %+v, want the dead job", recorded)
+	}
+
+	fixed.Store(true)
+	requeued, err := queue.Requeue(context.Background(), dead[0].Id)
+	if err != nil || requeued.RequeuedJobId == 0 || requeued.RequeuedAt == nil {
+		t.Fatalf("requeue = %+v %v, want the new job", requeued, err)
+	}
+	if revived.Load() != "payload" {
+		t.Fatalf("revive got %v, want the payload", revived.Load())
+	}
+	if status, attempts := waitFinished(t, store, clk, requeued.RequeuedJobId); status != types.JobDone || attempts != 1 {
+		t.Fatalf("requeued job = %s after %d attempts, want done after 1", status, attempts)
+	}
+
+	if _, err := queue.Requeue(context.Background(), dead[0].Id); !errors.Is(err, storage.ErrConflict) {
+		t.Fatalf("second requeue = %v, want ErrConflict", err)
+	}
+	if _, err := queue.Requeue(context.Background(), 999); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("requeue of an unknown dead job = %v, want ErrNotFound", err)
+	}
+}
+
+// Stop starts nothing new, a running job goes on until the checkpoint before the deadline and either finishes by
+// then or saves its step and goes back to the queue
+func TestQueueStopCheckpoint(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name           string
+		steps          int32         // the handler is done after this many, 0 for never
+		drain          time.Duration // deadline of the shutdown
+		wantStatus     string
+		wantCheckpoint bool
+	}
+
+	tests := []testCase{
+		{name: "finishes_before_the_checkpoint", steps: 20, drain: time.Minute, wantStatus: types.JobDone},
+		{name: "saves_its_step_near_the_deadline", drain: 300 * time.Millisecond, wantStatus: types.JobPending, wantCheckpoint: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := newStore(t)
+			clk := clock.NewFake(time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC))
+			queue := jobs.New(store, config.Jobs{PollInterval: 5 * time.Millisecond, Checkpoint: 100 * time.Millisecond}, clk, nil)
+			started := make(chan struct{}, 1)
+			var checkpoint atomic.Int32
+			queue.Register("test", jobs.Kind{
+				Handler: func(ctx context.Context, job types.Job) error {
+					started <- struct{}{}
+					for step := int32(1); tc.steps == 0 || step <= tc.steps; step++ {
+						select {
+						case <-jobs.Stopping(ctx):
+							checkpoint.Store(step)
+							return jobs.ErrStopping
+						case <-ctx.Done():
+							return ctx.Err()
+						case <-time.After(time.Millisecond):
+						}
+					}
+					return nil
+				},
+				MaxAttempts: 1,
+				Timeout:     time.Minute,
+			})
+			start(t, queue)
+
+			id, err := queue.Enqueue(context.Background(), "test", "")
+			if err != nil {
+				t.Fatalf("enqueue: %v", err)
+			}
+			<-started
+			ctx, cancel := context.WithTimeout(context.Background(), tc.drain)
+			defer cancel()
+			stopped := time.Now()
+			if running := queue.Stop(ctx); running != 1 {
+				t.Fatalf("Stop = %d running, want 1", running)
+			}
+			later, err := queue.Enqueue(context.Background(), "test", "")
+			if err != nil {
+				t.Fatalf("enqueue after stop: %v", err)
+			}
+			if err := queue.Drain(ctx); err != nil {
+				t.Fatalf("drain: %v", err)
+			}
+
+			wantAttempts := 1
+			if tc.wantCheckpoint {
+				wantAttempts = 0
+				// the job had the drain time up to the checkpoint to go on
+				if took := time.Since(stopped); took < tc.drain-150*time.Millisecond {
+					t.Fatalf("job stopped %v after Stop, want it to work until the checkpoint", took)
+				}
+			}
+			if status, attempts := jobState(t, store, id); status != tc.wantStatus || attempts != wantAttempts || (checkpoint.Load() != 0) != tc.wantCheckpoint {
+				t.Fatalf("job = %s after %d attempts at checkpoint step %d, want %s after %d", status, attempts, checkpoint.Load(), tc.wantStatus, wantAttempts)
+			}
+			if status, attempts := jobState(t, store, later); status != types.JobPending || attempts != 0 {
+				t.Fatalf("job queued after Stop = %s after %d attempts, want it left for the restart", status, attempts)
+			}
+		})
+	}
+}
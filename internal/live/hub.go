@@ -0,0 +1,202 @@
+// Package live fans domain events out to long lived client connections (websocket, server-sent events)
+package live
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/events"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+var (
+	ErrTooManySubscribers = errors.New("too many live connections")
+	ErrClosed             = errors.New("server is shutting down")
+)
+
+// Message is an event with the id clients resume from -> "<hub start>-<sequence>",
+// the start part makes ids from before a restart unknown instead of pointing at the wrong events
+type Message struct {
+	ID    string
+	Event events.Event
+}
+
+// Hub gets every event from the bus and hands the public ones to all subscribers.
+// the last cfg.History messages are kept so a client that reconnects gets what it missed
+type Hub struct {
+	max     int // 0 means no limit
+	buffer  int
+	history int
+	epoch   string
+
+	mu     sync.Mutex
+	seq    uint64
+	recent []Message // oldest first, at most history long
+	subs   map[*Subscriber]struct{}
+	closed bool
+}
+
+func NewHub(bus *events.Bus, cfg config.Live) *Hub {
+	h := &Hub{
+		max:     cfg.MaxConnections,
+		buffer:  cfg.SendBuffer,
+		history: cfg.History,
+		epoch:   strconv.FormatInt(time.Now().UnixMilli(), 36),
+		subs:    map[*Subscriber]struct{}{},
+	}
+	if h.buffer <= 0 {
+		h.buffer = 64
+	}
+	if h.history <= 0 {
+		h.history = 1000
+	}
+	bus.Subscribe(h.publish)
+	return h
+}
+
+// Subscriber is one connection. Events is never closed, Done is closed when the hub shuts down
+// or the subscriber fell behind, after that the connection should end
+type Subscriber struct {
+	events chan Message
+	done   chan struct{}
+	once   sync.Once
+	reason error
+	gap    bool
+}
+
+func (s *Subscriber) Events() <-chan Message { return s.events }
+func (s *Subscriber) Done() <-chan struct{}  { return s.done }
+
+// Gap is true when Resume could not find the last id, events were missed and the client should reload
+func (s *Subscriber) Gap() bool { return s.gap }
+
+// Err says why Done was closed
+func (s *Subscriber) Err() error {
+	<-s.done
+	return s.reason
+}
+
+func (s *Subscriber) stop(reason error) {
+	s.once.Do(func() {
+		s.reason = reason
+		close(s.done)
+	})
+}
+
+var errSlow = errors.New("client too slow, events were dropped")
+
+// Subscribe adds a connection that only gets new events, call Unsubscribe when it ends
+func (h *Hub) Subscribe() (*Subscriber, error) {
+	return h.Resume("")
+}
+
+// Resume is Subscribe for a client that reconnects, it first gets every kept message after lastID.
+// an empty lastID is a fresh start, an unknown one sets Gap
+func (h *Hub) Resume(lastID string) (*Subscriber, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, ErrClosed
+	}
+	if h.max > 0 && len(h.subs) >= h.max {
+		return nil, ErrTooManySubscribers
+	}
+
+	var backlog []Message
+	gap := false
+	if lastID != "" {
+		backlog, gap = h.since(lastID)
+	}
+	s := &Subscriber{events: make(chan Message, h.buffer+len(backlog)), done: make(chan struct{}), gap: gap}
+	for _, m := range backlog {
+		s.events <- m
+	}
+	h.subs[s] = struct{}{}
+	return s, nil
+}
+
+// since returns the kept messages after lastID, gap is true when lastID is not ours or already too old
+func (h *Hub) since(lastID string) (backlog []Message, gap bool) {
+	epoch, seqText, _ := strings.Cut(lastID, "-")
+	seq, err := strconv.ParseUint(seqText, 10, 64)
+	if err != nil || epoch != h.epoch || seq > h.seq {
+		return nil, true
+	}
+	if seq == h.seq {
+		return nil, false
+	}
+	oldest := h.seq - uint64(len(h.recent)) + 1
+	if seq+1 < oldest { // what came right after lastID is already gone
+		return nil, true
+	}
+	return append([]Message(nil), h.recent[seq+1-oldest:]...), false
+}
+
+func (h *Hub) Unsubscribe(s *Subscriber) {
+	h.mu.Lock()
+	delete(h.subs, s)
+	h.mu.Unlock()
+	s.stop(nil)
+}
+
+// Len is the number of open connections
+func (h *Hub) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+// Close ends every connection and refuses new ones, http.Server does not track hijacked or streaming
+// connections during Shutdown so the app calls this when shutdown starts
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for s := range h.subs {
+		s.stop(ErrClosed)
+	}
+}
+
+// publish runs in the publisher goroutine, it never blocks -> a full buffer drops the subscriber, not the event for everyone
+func (h *Hub) publish(ctx context.Context, e events.Event) {
+	if !events.Public[e.EventType()] {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seq++
+	m := Message{ID: h.epoch + "-" + strconv.FormatUint(h.seq, 10), Event: e}
+	h.recent = append(h.recent, m)
+	if len(h.recent) > h.history {
+		h.recent = h.recent[len(h.recent)-h.history:]
+	}
+	for s := range h.subs {
+		select {
+		case s.events <- m:
+		default:
+			s.stop(errSlow)
+		}
+	}
+}
+
+// For returns the event as p may see it -> the student in it goes through auth.RedactStudent, the same rule the
+// http responses follow
+func For(p *auth.Principal, e events.Event) events.Event {
+	switch e := e.(type) {
+	case events.StudentCreated:
+		s := auth.RedactStudent(p, types.Student{Id: e.StudentId, Name: e.Name, Email: e.Email, Age: e.Age})
+		e.Name, e.Email, e.Age = s.Name, s.Email, s.Age
+		return e
+	case events.StudentUpdated:
+		s := auth.RedactStudent(p, types.Student{Id: e.StudentId, Name: e.Name, Email: e.Email, Age: e.Age})
+		e.Name, e.Email, e.Age = s.Name, s.Email, s.Age
+		return e
+	}
+	return e
+}
@@ -0,0 +1,142 @@
+package live_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/events"
+	"github.com/manishtomar-cpi/go-server/internal/live"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+func TestHub(t *testing.T) {
+	t.Parallel()
+
+	bus := events.NewBus()
+	hub := live.NewHub(bus, config.Live{MaxConnections: 2, SendBuffer: 1})
+	created, _ := events.NewStudentCreated(types.Student{Id: 1, Name: "Asha", Email: "asha@example.com", Age: 21}, time.Now())
+
+	slow, err := hub.Subscribe()
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	fast, _ := hub.Subscribe()
+	if _, err := hub.Subscribe(); !errors.Is(err, live.ErrTooManySubscribers) {
+		t.Fatalf("third subscriber: want ErrTooManySubscribers, got %v", err)
+	}
+
+	bus.Publish(context.Background(), events.AccountLocked{Scope: "account", Key: "asha"}) // internal, never forwarded
+	bus.Publish(context.Background(), created)
+	<-fast.Events()
+	bus.Publish(context.Background(), created) // slow still has the first one in its buffer of 1
+
+	select {
+	case <-slow.Done():
+	default:
+		t.Fatal("a subscriber with a full buffer should be dropped")
+	}
+	select {
+	case m := <-fast.Events():
+		if m.Event.EventType() != events.StudentCreatedType {
+			t.Fatalf("want student.created, got %s", m.Event.EventType())
+		}
+	default:
+		t.Fatal("fast subscriber should get the second event")
+	}
+
+	hub.Unsubscribe(slow)
+	hub.Close()
+	if err := fast.Err(); !errors.Is(err, live.ErrClosed) {
+		t.Fatalf("after Close: want ErrClosed, got %v", err)
+	}
+	if _, err := hub.Subscribe(); !errors.Is(err, live.ErrClosed) {
+		t.Fatalf("subscribe after Close: want ErrClosed, got %v", err)
+	}
+}
+
+func TestHubResume(t *testing.T) {
+	t.Parallel()
+
+	bus := events.NewBus()
+	hub := live.NewHub(bus, config.Live{History: 2})
+	first, _ := hub.Subscribe()
+	for id := int64(1); id <= 3; id++ {
+		deleted, _ := events.NewStudentDeleted(id, time.Now())
+		bus.Publish(context.Background(), deleted)
+	}
+	var ids []string
+	for range 3 {
+		ids = append(ids, (<-first.Events()).ID)
+	}
+
+	tests := []struct {
+		name    string
+		lastID  string
+		want    []string
+		wantGap bool
+	}{
+		{name: "fresh start", lastID: "", want: nil},
+		{name: "missed the last one", lastID: ids[1], want: ids[2:]},
+		{name: "up to date", lastID: ids[2], want: nil},
+		{name: "kept history starts after it", lastID: ids[0], want: ids[1:]},
+		{name: "older than the history", lastID: "0", wantGap: true},
+		{name: "from before a restart", lastID: "abc-2", wantGap: true},
+		{name: "garbage", lastID: "not an id", wantGap: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			sub, err := hub.Resume(tc.lastID)
+			if err != nil {
+				t.Fatalf("resume: %v", err)
+			}
+			defer hub.Unsubscribe(sub)
+			if sub.Gap() != tc.wantGap {
+				t.Fatalf("gap = %v, want %v", sub.Gap(), tc.wantGap)
+			}
+			var got []string
+			for len(sub.Events()) > 0 {
+				got = append(got, (<-sub.Events()).ID)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Fatalf("replayed %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFor(t *testing.T) {
+	t.Parallel()
+
+	created, _ := events.NewStudentCreated(types.Student{Id: 1, Name: "Asha", Email: "asha@example.com", Age: 21}, time.Now())
+
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		want      string
+	}{
+		{name: "teacher sees pii", principal: &auth.Principal{Roles: []string{auth.RoleTeacher}}, want: "asha@example.com"},
+		{name: "own record", principal: &auth.Principal{Roles: []string{auth.RoleStudent}, StudentID: 1}, want: "asha@example.com"},
+		{name: "other student", principal: &auth.Principal{Roles: []string{auth.RoleStudent}, StudentID: 2}, want: "a***@example.com"},
+		{name: "read scope only", principal: &auth.Principal{Scopes: []string{string(auth.ReadStudents)}}, want: "a***@example.com"},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got := live.For(tc.principal, created).(events.StudentCreated)
+			if got.Email != tc.want {
+				t.Errorf("email = %q, want %q", got.Email, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+)
+
+type requestIDKey struct{}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the id of the request this context belongs to, empty outside of a request
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+type clientIPKey struct{}
+
+// WithClientIP stores the real client address, after trusted proxies were taken into account
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// ClientIP returns the client address set by the RealIP middleware, empty outside of a request
+func ClientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
+
+// ContextHandler wraps another slog handler and adds request_id and client_ip to every record logged with a request context,
+// so slog.InfoContext(r.Context(), ...) lines can be matched to the X-Request-ID a client reports
+type ContextHandler struct {
+	slog.Handler
+}
+
+func NewContextHandler(next slog.Handler) *ContextHandler {
+	return &ContextHandler{Handler: next}
+}
+
+func (h *ContextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if ip := ClientIP(ctx); ip != "" {
+		record.AddAttrs(slog.String("client_ip", ip))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithGroup(name)}
+}
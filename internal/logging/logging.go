@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/redact"
+)
+
+// New builds the one logger the server uses -> json or text from config, the configured level,
+// and service/version on every line so logs of different deployments can be told apart.
+// level is set to the configured one, changing it later changes the level of the running logger.
+// set it with slog.SetDefault once at startup
+func New(w io.Writer, level *slog.LevelVar, cfg config.Logging, service, version string) *slog.Logger {
+	level.Set(ParseLevel(cfg.Level))
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact.LogAttr} // no raw emails or secrets in any log line
+
+	var handler slog.Handler
+	if strings.EqualFold(cfg.Format, "text") {
+		handler = slog.NewTextHandler(w, opts)
+	} else {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+	// a real handler is wrapped, wrapping slog.Default().Handler() would deadlock once SetDefault points the log package back at it
+	return slog.New(NewContextHandler(handler)).With(
+		slog.String("service", service),
+		slog.String("version", version),
+	)
+}
+
+// ParseLevel reads debug/info/warn/error, unknown levels fall back to info instead of failing startup over a typo
+func ParseLevel(level string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+// ToggleDebug switches between debug and the normal level, for a quick look at debug logs without a restart
+func ToggleDebug(level *slog.LevelVar, normal slog.Level) slog.Level {
+	next := slog.LevelDebug
+	if level.Level() == slog.LevelDebug {
+		next = normal
+	}
+	level.Set(next)
+	return next
+}
+
+type loggerKey struct{}
+
+// WithLogger stores the request-scoped logger, the Logger middleware does this for every request
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger of this request, or the default one outside of a request.
+// log with the ...Context methods so request_id and client_ip are added too
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,69 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/logging"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger := logging.New(&buf, new(slog.LevelVar), config.Logging{Format: "json", Level: "warn"}, "go-server", "1.2.3")
+
+	ctx := logging.WithRequestID(context.Background(), "req-1")
+	logger.InfoContext(ctx, "dropped, below the level")
+	logger.WarnContext(ctx, "kept")
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("want exactly one json line, got %q: %v", buf.String(), err)
+	}
+	for key, want := range map[string]string{"msg": "kept", "service": "go-server", "version": "1.2.3", "request_id": "req-1"} {
+		if line[key] != want {
+			t.Fatalf("want %s=%q, got %v", key, want, line[key])
+		}
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger := logging.New(&buf, new(slog.LevelVar), config.Logging{Format: "text"}, "go-server", "dev")
+	ctx := logging.WithLogger(context.Background(), logger)
+
+	if logging.FromContext(ctx) != logger {
+		t.Fatalf("want the stored logger back")
+	}
+	if logging.FromContext(context.Background()) == nil {
+		t.Fatalf("want the default logger outside of a request")
+	}
+}
+
+func TestLevelChangesAtRuntime(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	level := new(slog.LevelVar)
+	logger := logging.New(&buf, level, config.Logging{Level: "info"}, "go-server", "dev")
+
+	logger.Debug("hidden")
+	if got := logging.ToggleDebug(level, slog.LevelInfo); got != slog.LevelDebug {
+		t.Fatalf("want debug after the first toggle, got %v", got)
+	}
+	logger.Debug("shown")
+	if got := logging.ToggleDebug(level, slog.LevelInfo); got != slog.LevelInfo {
+		t.Fatalf("want info after the second toggle, got %v", got)
+	}
+
+	if bytes.Contains(buf.Bytes(), []byte("hidden")) || !bytes.Contains(buf.Bytes(), []byte("shown")) {
+		t.Fatalf("level change was not picked up: %q", buf.String())
+	}
+}
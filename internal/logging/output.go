@@ -0,0 +1,32 @@
+package logging
+
+import (
+	"io"
+
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Output is where the logs go -> stderr, the rotated log file, or both.
+// close the returned closer at the very end of main, after the last log line
+func Output(stderr io.Writer, cfg config.LogFile) (io.Writer, io.Closer) {
+	if cfg.Path == "" {
+		return stderr, noClose{}
+	}
+	file := &lumberjack.Logger{
+		Filename:   cfg.Path,
+		MaxSize:    cfg.MaxSizeMB,
+		MaxAge:     cfg.MaxAgeDays,
+		MaxBackups: cfg.MaxBackups,
+		Compress:   cfg.Compress,
+		LocalTime:  true, // rotated file names in the time zone people read the logs in
+	}
+	if cfg.Only {
+		return file, file
+	}
+	return io.MultiWriter(stderr, file), file
+}
+
+type noClose struct{}
+
+func (noClose) Close() error { return nil } // stderr is never closed by us
@@ -0,0 +1,58 @@
+package logging_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/logging"
+)
+
+func TestOutput(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name       string
+		file       bool
+		only       bool
+		wantStderr bool
+	}
+
+	tests := []testCase{
+		{name: "stderr_only", wantStderr: true},
+		{name: "stderr_and_file", file: true, wantStderr: true},
+		{name: "file_only", file: true, only: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var stderr bytes.Buffer
+			cfg := config.LogFile{MaxSizeMB: 1, Only: tc.only}
+			if tc.file {
+				cfg.Path = filepath.Join(t.TempDir(), "server.log")
+			}
+
+			out, closer := logging.Output(&stderr, cfg)
+			io.WriteString(out, "line\n")
+			if err := closer.Close(); err != nil {
+				t.Fatalf("close: %v", err)
+			}
+
+			if got := stderr.Len() > 0; got != tc.wantStderr {
+				t.Fatalf("want stderr written %v, got %v", tc.wantStderr, got)
+			}
+			if tc.file {
+				data, err := os.ReadFile(cfg.Path)
+				if err != nil || string(data) != "line\n" {
+					t.Fatalf("want the line in the log file, got %q (%v)", data, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package messaging_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/errcode"
+	"github.com/manishtomar-cpi/go-server/internal/messaging"
+	"github.com/manishtomar-cpi/go-server/internal/storage/sqlite"
+)
+
+func TestResponder(t *testing.T) {
+	t.Parallel()
+
+	store, err := sqlite.New(&config.Config{Storage_path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("sqlite: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	for _, name := range []string{"Asha", "Ravi", "Mira"} {
+		if _, err := store.CreateStudent(context.Background(), name, name+"@example.com", 21, time.Now()); err != nil {
+			t.Fatalf("create student: %v", err)
+		}
+	}
+	responder := messaging.NewResponder(store, time.Second)
+
+	type testCase struct {
+		name      string
+		request   string
+		body      string
+		wantCode  errcode.Code
+		wantCount int    // students in the answer of a list
+		wantName  string // of the student in the answer of a get
+	}
+
+	tests := []testCase{
+		{name: "get", request: "students.get", body: `{"id":2}`, wantName: "Ravi"},
+		{name: "get_unknown", request: "students.get", body: `{"id":99}`, wantCode: errcode.StudentNotFound},
+		{name: "get_bad_id", request: "students.get", body: `{"id":0}`, wantCode: errcode.ValidationFailed},
+		{name: "get_not_json", request: "students.get", body: `id=2`, wantCode: errcode.InvalidRequest},
+		{name: "list_empty_body", request: "students.list", wantCount: 3},
+		{name: "list_page", request: "students.list", body: `{"limit":2,"offset":2}`, wantCount: 1},
+		{name: "list_bad_limit", request: "students.list", body: `{"limit":501}`, wantCode: errcode.ValidationFailed},
+		{name: "unknown_request", request: "students.delete", body: `{"id":1}`, wantCode: errcode.NotFound},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			reply := responder.Handle(context.Background(), tc.request, []byte(tc.body))
+			// what goes over the wire
+			body, _ := json.Marshal(reply)
+			var got struct {
+				Data  json.RawMessage `json:"data"`
+				Error *struct {
+					Code errcode.Code `json:"code"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(body, &got); err != nil {
+				t.Fatalf("reply %s: %v", body, err)
+			}
+			if tc.wantCode != "" {
+				if got.Error == nil || got.Error.Code != tc.wantCode {
+					t.Fatalf("reply %s, want error %s", body, tc.wantCode)
+				}
+				return
+			}
+			if got.Error != nil {
+				t.Fatalf("reply %s, want data", body)
+			}
+			if tc.wantName != "" {
+				var student struct {
+					Name string `json:"name"`
+				}
+				json.Unmarshal(got.Data, &student)
+				if student.Name != tc.wantName {
+					t.Fatalf("reply %s, want student %s", body, tc.wantName)
+				}
+				return
+			}
+			var students []json.RawMessage
+			json.Unmarshal(got.Data, &students)
+			if len(students) != tc.wantCount {
+				t.Fatalf("reply %s, want %d students", body, tc.wantCount)
+			}
+		})
+	}
+}
+
+// a server that is down at start does not keep the app from starting, readiness says it is missing
+func TestConnectWhileServerIsDown(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	nc, err := messaging.Connect(config.NATS{URL: "nats://" + addr, Name: "test", ReconnectWait: 10 * time.Millisecond, MaxReconnects: -1})
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if err := messaging.Check(nc)(context.Background()); err == nil {
+		t.Fatal("check passed without a server")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := messaging.Close(nc)(ctx); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if !nc.IsClosed() {
+		t.Fatal("connection still open after close")
+	}
+}
@@ -0,0 +1,102 @@
+// Package messaging connects the server to message brokers. for now that is nats -> one connection that reconnects on
+// its own, the outbox publishes the change events on it and Responder answers requests of internal services
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/nats-io/nats.go"
+)
+
+// Connect opens the connection of cfg. a server that is down at start is retried in the background like a dropped
+// connection, so the server still starts and /readyz says what is missing
+func Connect(cfg config.NATS) (*nats.Conn, error) {
+	opts := []nats.Option{
+		nats.Name(cfg.Name),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(cfg.MaxReconnects),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				slog.Warn("nats disconnected, reconnecting", slog.String("error", err.Error()))
+			}
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			slog.Info("nats reconnected", slog.String("server", nc.ConnectedUrlRedacted()))
+		}),
+		nats.ClosedHandler(func(nc *nats.Conn) {
+			if err := nc.LastError(); err != nil {
+				slog.Error("nats connection closed", slog.String("error", err.Error()))
+			}
+		}),
+		nats.ErrorHandler(func(_ *nats.Conn, sub *nats.Subscription, err error) {
+			subject := ""
+			if sub != nil {
+				subject = sub.Subject
+			}
+			slog.Error("nats error", slog.String("subject", subject), slog.String("error", err.Error()))
+		}),
+	}
+	if cfg.ConnectTimeout > 0 {
+		opts = append(opts, nats.Timeout(cfg.ConnectTimeout))
+	}
+	if cfg.ReconnectWait > 0 {
+		opts = append(opts, nats.ReconnectWait(cfg.ReconnectWait))
+	}
+	if cfg.ReconnectBuffer > 0 {
+		opts = append(opts, nats.ReconnectBufSize(cfg.ReconnectBuffer))
+	}
+	if cfg.CredsFile != "" {
+		opts = append(opts, nats.UserCredentials(cfg.CredsFile))
+	}
+	if cfg.Token != "" {
+		opts = append(opts, nats.Token(cfg.Token))
+	}
+	nc, err := nats.Connect(cfg.URL, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("messaging.nats: %w", err)
+	}
+	return nc, nil
+}
+
+// Check is the readiness check of nc, it fails while nc is not connected
+func Check(nc *nats.Conn) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if status := nc.Status(); status != nats.CONNECTED {
+			return fmt.Errorf("nats is %s", status)
+		}
+		return nil
+	}
+}
+
+// Close drains nc -> no new requests come in, the ones being answered finish and what was published is flushed.
+// it has the signature of a shutdown hook
+func Close(nc *nats.Conn) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		err := nc.Drain()
+		switch {
+		case errors.Is(err, nats.ErrConnectionReconnecting):
+			// Drain closed it, what is still buffered can not reach the server anyway
+			slog.Warn("nats was not connected at shutdown, buffered messages are lost")
+			return nil
+		case err != nil && !errors.Is(err, nats.ErrConnectionClosed):
+			nc.Close()
+			return err
+		}
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for !nc.IsClosed() {
+			select {
+			case <-ctx.Done():
+				nc.Close()
+				return fmt.Errorf("nats drain: %w", ctx.Err())
+			case <-ticker.C:
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,139 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/errcode"
+	"github.com/manishtomar-cpi/go-server/internal/http/dto"
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/nats-io/nats.go"
+)
+
+// Reply is the body of every answer, Data on success and Error otherwise
+type Reply struct {
+	Data  any         `json:"data,omitempty"`
+	Error *ReplyError `json:"error,omitempty"`
+}
+
+// ReplyError has the same codes as the error bodies of the http api
+type ReplyError struct {
+	Code    errcode.Code `json:"code"`
+	Message string       `json:"message"`
+}
+
+// GetStudentRequest is the body of <prefix>.students.get
+type GetStudentRequest struct {
+	Id int64 `json:"id"`
+}
+
+// ListStudentsRequest is the body of <prefix>.students.list, limit 0 means 50 like the http api
+type ListStudentsRequest struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// Responder answers the read requests of internal services. there is no principal on a nats message, whoever may
+// publish to the subjects (nats permissions) may read every student
+type Responder struct {
+	students storage.Storage
+	timeout  time.Duration
+	handlers map[string]func(ctx context.Context, data []byte) Reply // by the part of the subject after the prefix
+}
+
+func NewResponder(students storage.Storage, timeout time.Duration) *Responder {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	r := &Responder{students: students, timeout: timeout}
+	r.handlers = map[string]func(ctx context.Context, data []byte) Reply{
+		"students.get":  r.getStudent,
+		"students.list": r.listStudents,
+	}
+	return r
+}
+
+// Serve subscribes every handler to <prefix>.<name> in queue group queue, Close of the connection ends it
+func (r *Responder) Serve(nc *nats.Conn, prefix, queue string) error {
+	for name := range r.handlers {
+		_, err := nc.QueueSubscribe(prefix+"."+name, queue, func(msg *nats.Msg) {
+			if msg.Reply == "" {
+				return // a publish instead of a request, nobody waits for an answer
+			}
+			body, _ := json.Marshal(r.Handle(context.Background(), name, msg.Data))
+			if err := msg.Respond(body); err != nil {
+				slog.Warn("nats reply failed", slog.String("subject", msg.Subject), slog.String("error", err.Error()))
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	slog.Info("answering nats requests", slog.String("prefix", prefix), slog.String("queue_group", queue))
+	return nil
+}
+
+// Handle answers the request data sent to the subject <prefix>.<name>
+func (r *Responder) Handle(ctx context.Context, name string, data []byte) Reply {
+	h, ok := r.handlers[name]
+	if !ok {
+		return failed(errcode.NotFound, "unknown request "+name)
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	return h(ctx, data)
+}
+
+func (r *Responder) getStudent(ctx context.Context, data []byte) Reply {
+	var req GetStudentRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return failed(errcode.InvalidRequest, "request body is not valid json")
+	}
+	if req.Id <= 0 {
+		return failed(errcode.ValidationFailed, "id must be a positive number")
+	}
+	student, err := r.students.GetStudentById(ctx, req.Id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return failed(errcode.StudentNotFound, "student not found")
+	}
+	if err != nil {
+		return internal(ctx, "get student failed", err)
+	}
+	return Reply{Data: dto.NewStudent(student)}
+}
+
+func (r *Responder) listStudents(ctx context.Context, data []byte) Reply {
+	req := ListStudentsRequest{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &req); err != nil {
+			return failed(errcode.InvalidRequest, "request body is not valid json")
+		}
+	}
+	if req.Limit == 0 {
+		req.Limit = 50
+	}
+	if req.Limit < 1 || req.Limit > 500 {
+		return failed(errcode.ValidationFailed, "limit must be between 1 and 500")
+	}
+	if req.Offset < 0 {
+		return failed(errcode.ValidationFailed, "offset must be a non negative number")
+	}
+	students, err := r.students.ListStudents(ctx, storage.StudentQuery{Limit: req.Limit, Offset: req.Offset})
+	if err != nil {
+		return internal(ctx, "list students failed", err)
+	}
+	return Reply{Data: dto.NewStudents(students)}
+}
+
+func failed(code errcode.Code, message string) Reply {
+	return Reply{Error: &ReplyError{Code: code, Message: message}}
+}
+
+// internal logs err and answers without it, storage errors can carry details the caller should not see
+func internal(ctx context.Context, msg string, err error) Reply {
+	slog.ErrorContext(ctx, msg, slog.String("error", err.Error()))
+	return failed(errcode.Internal, "internal error")
+}
@@ -0,0 +1,32 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Auth counts logins, a jump in failures or lockouts is the sign of a brute force or credential stuffing run
+type Auth struct {
+	logins   *prometheus.CounterVec
+	lockouts *prometheus.CounterVec
+}
+
+func NewAuth(reg prometheus.Registerer) *Auth {
+	m := &Auth{
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "auth_login_attempts_total",
+			Help: "Password logins, by result (success, failure, throttled).",
+		}, []string{"result"}),
+		lockouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "auth_lockouts_total",
+			Help: "Temporary lockouts after repeated login failures, by scope (account, ip).",
+		}, []string{"scope"}),
+	}
+	reg.MustRegister(m.logins, m.lockouts)
+	return m
+}
+
+func (m *Auth) Login(result string) {
+	m.logins.WithLabelValues(result).Inc()
+}
+
+func (m *Auth) Lockout(scope string) {
+	m.lockouts.WithLabelValues(scope).Inc()
+}
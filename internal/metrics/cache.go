@@ -0,0 +1,37 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Cache counts the reads of the in-process student cache, hits / (hits + misses) is the share the db never saw.
+// evictions going up with a low hit rate means the cache is too small for the students being read
+type Cache struct {
+	requests  *prometheus.CounterVec
+	evictions prometheus.Counter
+}
+
+func NewCache(reg prometheus.Registerer) *Cache {
+	m := &Cache{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "student_cache_requests_total",
+			Help: "Student reads by id through the in-process cache, by result (hit, miss).",
+		}, []string{"result"}),
+		evictions: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "student_cache_evictions_total",
+			Help: "Students dropped from the in-process cache to make room for another one.",
+		}),
+	}
+	reg.MustRegister(m.requests, m.evictions)
+	return m
+}
+
+func (m *Cache) Hit() {
+	m.requests.WithLabelValues("hit").Inc()
+}
+
+func (m *Cache) Miss() {
+	m.requests.WithLabelValues("miss").Inc()
+}
+
+func (m *Cache) Evict() {
+	m.evictions.Inc()
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HTTP holds the RED metrics -> Rate (requests), Errors (5xx), Duration (latency histogram)
+type HTTP struct {
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+var httpLabels = []string{"route", "method", "status_class"}
+
+func NewHTTP(reg prometheus.Registerer) *HTTP {
+	m := &HTTP{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests served, by route pattern, method and status class.",
+		}, httpLabels),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_request_errors_total",
+			Help: "HTTP requests that ended with a 5xx status.",
+		}, httpLabels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Time to serve HTTP requests.",
+			Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		}, httpLabels),
+	}
+	reg.MustRegister(m.requests, m.errors, m.duration)
+	return m
+}
+
+// Observe records one finished request. route must be the pattern ("GET /api/students/{id}"), never the raw path,
+// otherwise every id becomes its own time series
+func (m *HTTP) Observe(route string, method string, status int, took time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+	class := strconv.Itoa(status/100) + "xx"
+	m.requests.WithLabelValues(route, method, class).Inc()
+	if status >= 500 {
+		m.errors.WithLabelValues(route, method, class).Inc()
+	}
+	m.duration.WithLabelValues(route, method, class).Observe(took.Seconds())
+}
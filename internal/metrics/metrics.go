@@ -0,0 +1,19 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// NewRegistry makes the registry every metric of the app is registered in.
+// its own registry instead of the global default one, so tests can create as many apps as they want
+func NewRegistry() *prometheus.Registry {
+	return prometheus.NewRegistry()
+}
+
+// Handler serves the registry in prometheus text format, mounted on the admin listener at /metrics
+func Handler(reg *prometheus.Registry) http.Handler {
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{Registry: reg})
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Outbox has how far the relay is behind, alert on the lag -> a sink that is down makes it grow while nothing is lost
+type Outbox struct {
+	pending   prometheus.Gauge
+	lag       prometheus.Gauge
+	published prometheus.Counter
+	failures  prometheus.Counter
+}
+
+func NewOutbox(reg prometheus.Registerer) *Outbox {
+	m := &Outbox{
+		pending: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "outbox_pending_events",
+			Help: "Events in the outbox that were not published to the sink yet.",
+		}),
+		lag: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "outbox_lag_seconds",
+			Help: "Age of the oldest event that was not published yet, 0 when the outbox is empty.",
+		}),
+		published: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "outbox_published_events_total",
+			Help: "Events published to the sink, a retried batch counts again.",
+		}),
+		failures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "outbox_publish_failures_total",
+			Help: "Batches the sink did not take.",
+		}),
+	}
+	reg.MustRegister(m.pending, m.lag, m.published, m.failures)
+	return m
+}
+
+// Backlog sets what is still pending, lag is the age of the oldest pending event
+func (m *Outbox) Backlog(pending int64, lag time.Duration) {
+	m.pending.Set(float64(pending))
+	m.lag.Set(lag.Seconds())
+}
+
+func (m *Outbox) Published(n int) {
+	m.published.Add(float64(n))
+}
+
+func (m *Outbox) Failed() {
+	m.failures.Inc()
+}
@@ -0,0 +1,18 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// RegisterRuntime adds the go runtime and process metrics, read fresh on every scrape ->
+// go_goroutines, go_memstats_heap_* / go_memory_classes_*, go_gc_pauses_seconds (histogram, use histogram_quantile
+// for the percentiles), go_sched_latencies_seconds, process_open_fds and process_max_fds for descriptor leaks
+func RegisterRuntime(reg prometheus.Registerer) {
+	reg.MustRegister(
+		collectors.NewGoCollector(collectors.WithGoCollectorRuntimeMetrics(
+			collectors.MetricsGC, collectors.MetricsMemory, collectors.MetricsScheduler,
+		)),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
@@ -0,0 +1,35 @@
+package metrics_test
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/metrics"
+)
+
+func TestRegisterRuntime(t *testing.T) {
+	t.Parallel()
+
+	reg := metrics.NewRegistry()
+	metrics.RegisterRuntime(reg)
+	runtime.GC() // so there is at least one gc pause to report
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	got := map[string]bool{}
+	for _, f := range families {
+		got[f.GetName()] = true
+	}
+
+	want := []string{"go_goroutines", "go_memstats_heap_alloc_bytes", "go_gc_pauses_seconds"}
+	if runtime.GOOS == "linux" { // the process collector only reads /proc
+		want = append(want, "process_open_fds", "process_max_fds")
+	}
+	for _, name := range want {
+		if !got[name] {
+			t.Errorf("metric %s missing", name)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Scheduler has the runs of the periodic tasks. alert on last success getting old rather than on single failures,
+// a cleanup that fails once is simply done on its next turn
+type Scheduler struct {
+	runs        *prometheus.CounterVec
+	duration    *prometheus.HistogramVec
+	lastSuccess *prometheus.GaugeVec
+}
+
+func NewScheduler(reg prometheus.Registerer) *Scheduler {
+	m := &Scheduler{
+		runs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "scheduled_task_runs_total",
+			Help: "Turns of the scheduled tasks, by task and result (ok, error, skipped when the run before was still going).",
+		}, []string{"task", "result"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "scheduled_task_duration_seconds",
+			Help:    "How long the runs of the scheduled tasks took.",
+			Buckets: []float64{.01, .1, 1, 10, 60, 300, 1800},
+		}, []string{"task"}),
+		lastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "scheduled_task_last_success_timestamp_seconds",
+			Help: "Unix time the scheduled task last finished without an error.",
+		}, []string{"task"}),
+	}
+	reg.MustRegister(m.runs, m.duration, m.lastSuccess)
+	return m
+}
+
+// Finished records one run, err is what the task returned
+func (m *Scheduler) Finished(task string, took time.Duration, err error) {
+	m.duration.WithLabelValues(task).Observe(took.Seconds())
+	if err != nil {
+		m.runs.WithLabelValues(task, "error").Inc()
+		return
+	}
+	m.runs.WithLabelValues(task, "ok").Inc()
+	m.lastSuccess.WithLabelValues(task).SetToCurrentTime()
+}
+
+func (m *Scheduler) Skipped(task string) {
+	m.runs.WithLabelValues(task, "skipped").Inc()
+}
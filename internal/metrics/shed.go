@@ -0,0 +1,32 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Shedding shows what the adaptive shedder is doing -> the level it is at and the requests it turned away
+type Shedding struct {
+	level *prometheus.GaugeVec
+	shed  *prometheus.CounterVec
+}
+
+func NewShedding(reg prometheus.Registerer) *Shedding {
+	m := &Shedding{
+		level: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "load_shed_ratio",
+			Help: "Share of the requests of each priority the shedder rejects right now, 0 when the server keeps up.",
+		}, []string{"priority"}),
+		shed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "load_shed_requests_total",
+			Help: "Requests rejected with 503 by the adaptive shedder, by priority.",
+		}, []string{"priority"}),
+	}
+	reg.MustRegister(m.level, m.shed)
+	return m
+}
+
+func (m *Shedding) Ratio(priority string, ratio float64) {
+	m.level.WithLabelValues(priority).Set(ratio)
+}
+
+func (m *Shedding) Shed(priority string) {
+	m.shed.WithLabelValues(priority).Inc()
+}
@@ -0,0 +1,186 @@
+// Package notify emails students about what happened to them -> a welcome when they are created, a confirmation when
+// they are enrolled in a course. emails are rendered and written to the outbox in storage first and sent as background
+// jobs, so a restart or a mail server that is down for a while loses none, and the outbox shows how each one went
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/events"
+	"github.com/manishtomar-cpi/go-server/internal/jobs"
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+// Message is one rendered email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender hands a message to the mail server, SMTP in production
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Notifier writes an email to the outbox for every event that asks for one, the job queue sends them
+type Notifier struct {
+	store    storage.EmailStore
+	students storage.Storage
+	queue    *jobs.Queue
+	sender   Sender
+	clock    clock.Clock
+	cfg      config.Email
+}
+
+func NewNotifier(store storage.EmailStore, students storage.Storage, bus *events.Bus, queue *jobs.Queue, sender Sender, cfg config.Email, clk clock.Clock) *Notifier {
+	// zero values come from configs built in code (tests), they get the same defaults as the yaml
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = 30 * time.Second
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = time.Hour
+	}
+	n := &Notifier{
+		store:    store,
+		students: students,
+		queue:    queue,
+		sender:   sender,
+		clock:    clk,
+		cfg:      cfg,
+	}
+	queue.Register(types.EmailJob, jobs.Kind{
+		Handler:     n.send,
+		MaxAttempts: cfg.MaxAttempts,
+		BaseDelay:   cfg.BaseDelay,
+		MaxDelay:    cfg.MaxDelay,
+		Timeout:     cfg.Timeout,
+		Revive:      n.revive,
+	})
+	bus.Subscribe(n.enqueue)
+	return n
+}
+
+// enqueue runs in the publisher goroutine, it renders the email, writes it with its job and wakes the queue up
+func (n *Notifier) enqueue(ctx context.Context, e events.Event) {
+	// the request that published the event may be cancelled right after, the email must still be written
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	var (
+		template string
+		to       string
+		data     any
+	)
+	switch e := e.(type) {
+	case events.StudentCreated:
+		template, to = WelcomeTemplate, e.Email
+		data = struct {
+			Name      string
+			StudentId int64
+		}{e.Name, e.StudentId}
+	case events.EnrollmentAdded:
+		student, err := n.students.GetStudentById(ctx, e.StudentId)
+		if err != nil {
+			slog.ErrorContext(ctx, "load student for enrollment email failed", slog.Int64("student", e.StudentId), slog.String("error", err.Error()))
+			return
+		}
+		template, to = EnrollmentTemplate, student.Email
+		data = struct {
+			Name     string
+			CourseId int64
+			At       time.Time
+		}{student.Name, e.CourseId, e.OccurredAt}
+	default:
+		return
+	}
+
+	subject, body, err := Render(template, data)
+	if err != nil {
+		slog.ErrorContext(ctx, "render email failed", slog.String("template", template), slog.String("error", err.Error()))
+		return
+	}
+	_, err = n.store.EnqueueEmail(ctx, types.Email{Template: template, To: to, Subject: subject, Body: body, CreatedAt: n.clock.Now()})
+	if err != nil {
+		slog.ErrorContext(ctx, "queue email failed", slog.String("template", template), slog.String("error", err.Error()))
+		return
+	}
+	n.queue.Wake()
+}
+
+// send is the job of one email, it sends it and keeps the outbox row up to date for the admin endpoint
+func (n *Notifier) send(ctx context.Context, job types.Job) error {
+	id, err := strconv.ParseInt(job.Payload, 10, 64)
+	if err != nil {
+		return jobs.Permanent(fmt.Errorf("email id %q: %w", job.Payload, err))
+	}
+	email, err := n.store.EmailById(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return jobs.Permanent(err)
+	}
+	if err != nil {
+		return err
+	}
+	if email.Status != types.EmailPending { // sent before a crash that kept the job from being marked done
+		return nil
+	}
+
+	email.Attempts = job.Attempts
+	err = n.sender.Send(ctx, Message{To: email.To, Subject: email.Subject, Body: email.Body})
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return ctx.Err() // shutting down, the attempt does not count and the row stays as it was
+	}
+	switch {
+	case err == nil:
+		now := n.clock.Now()
+		email.Status = types.EmailSent
+		email.LastError = ""
+		email.SentAt = &now
+	case Permanent(err) || email.Attempts >= n.queue.Kind(job.Kind).MaxAttempts:
+		email.Status = types.EmailFailed
+		email.LastError = err.Error()
+		slog.WarnContext(ctx, "email failed for good", slog.Int64("email", email.Id), slog.String("template", email.Template),
+			slog.Int("attempts", email.Attempts), slog.String("error", err.Error()))
+	default:
+		email.LastError = err.Error()
+	}
+	n.save(ctx, email)
+	if Permanent(err) {
+		return jobs.Permanent(err)
+	}
+	return err
+}
+
+// revive sets a failed email back to pending, for a dead job that is requeued
+func (n *Notifier) revive(ctx context.Context, payload string) error {
+	id, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		return fmt.Errorf("email id %q: %w", payload, err)
+	}
+	email, err := n.store.EmailById(ctx, id)
+	if err != nil || email.Status != types.EmailFailed {
+		return err
+	}
+	email.Status = types.EmailPending
+	return n.store.UpdateEmail(ctx, email)
+}
+
+// save gets its own ctx, the one of the attempt may have timed out while sending
+func (n *Notifier) save(ctx context.Context, email types.Email) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := n.store.UpdateEmail(ctx, email); err != nil {
+		slog.ErrorContext(ctx, "save email failed", slog.Int64("email", email.Id), slog.String("error", err.Error()))
+	}
+}
@@ -0,0 +1,191 @@
+package notify_test
+
+import (
+	"context"
+	"errors"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/events"
+	"github.com/manishtomar-cpi/go-server/internal/jobs"
+	"github.com/manishtomar-cpi/go-server/internal/notify"
+	"github.com/manishtomar-cpi/go-server/internal/storage/sqlite"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+// fakeSender fails the first len(errs) sends with them and records the messages that went out
+type fakeSender struct {
+	mu   sync.Mutex
+	errs []error
+	sent []notify.Message
+}
+
+func (f *fakeSender) Send(ctx context.Context, msg notify.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return err
+	}
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+func (f *fakeSender) messages() []notify.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]notify.Message(nil), f.sent...)
+}
+
+// waitEmail moves the fake clock past every backoff until the only email is sent or failed
+func waitEmail(t *testing.T, store *sqlite.Sqlite, clk *clock.Fake) types.Email {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		emails, err := store.ListEmails(context.Background(), "", 10)
+		if err != nil {
+			t.Fatalf("list emails: %v", err)
+		}
+		if len(emails) == 1 && emails[0].Status != types.EmailPending {
+			return emails[0]
+		}
+		clk.Advance(time.Minute)
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("email was not sent")
+	return types.Email{}
+}
+
+func TestNotifier(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name         string
+		errs         []error // of the first sends
+		wantStatus   string
+		wantAttempts int
+		wantError    string
+	}
+
+	tests := []testCase{
+		{name: "sent", wantStatus: types.EmailSent, wantAttempts: 1},
+		{name: "retried", errs: []error{errors.New("connection refused")}, wantStatus: types.EmailSent, wantAttempts: 2},
+		{
+			name:         "gives_up",
+			errs:         []error{errors.New("timeout"), errors.New("timeout"), errors.New("timeout")},
+			wantStatus:   types.EmailFailed,
+			wantAttempts: 3,
+			wantError:    "timeout",
+		},
+		{
+			name:         "rejected_mailbox_is_not_retried",
+			errs:         []error{&textproto.Error{Code: 550, Msg: "no such user"}},
+			wantStatus:   types.EmailFailed,
+			wantAttempts: 1,
+			wantError:    "no such user",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store, err := sqlite.New(&config.Config{Storage_path: filepath.Join(t.TempDir(), "test.db")})
+			if err != nil {
+				t.Fatalf("sqlite: %v", err)
+			}
+			t.Cleanup(func() { store.Close() })
+			clk := clock.NewFake(time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC))
+			bus := events.NewBus()
+			queue := jobs.New(store, config.Jobs{PollInterval: 5 * time.Millisecond}, clk, nil)
+			sender := &fakeSender{errs: tc.errs}
+			notify.NewNotifier(store, store, bus, queue, sender, config.Email{MaxAttempts: 3, BaseDelay: time.Minute}, clk)
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() { queue.Run(ctx); close(done) }()
+			t.Cleanup(func() { cancel(); <-done; queue.Drain(context.Background()) })
+
+			id, err := store.CreateStudent(context.Background(), "Asha", "asha@example.com", 21, clk.Now())
+			if err != nil {
+				t.Fatalf("create student: %v", err)
+			}
+			created, _ := events.NewStudentCreated(types.Student{Id: id, Name: "Asha", Email: "asha@example.com", Age: 21}, clk.Now())
+			bus.Publish(context.Background(), created)
+
+			email := waitEmail(t, store, clk)
+			if email.Status != tc.wantStatus || email.Attempts != tc.wantAttempts || !strings.Contains(email.LastError, tc.wantError) {
+				t.Fatalf("email = %s after %d attempts (%q), want %s after %d (%q)",
+					email.Status, email.Attempts, email.LastError, tc.wantStatus, tc.wantAttempts, tc.wantError)
+			}
+			if email.Template != notify.WelcomeTemplate || email.To != "asha@example.com" || email.Subject != "Welcome, Asha" {
+				t.Fatalf("outbox row = %+v", email)
+			}
+			if sent := sender.messages(); tc.wantStatus == types.EmailSent && (len(sent) != 1 || sent[0].Body != email.Body) {
+				t.Fatalf("sent %+v, want the rendered email once", sent)
+			}
+		})
+	}
+}
+
+func TestNotifierEnrollment(t *testing.T) {
+	t.Parallel()
+
+	store, err := sqlite.New(&config.Config{Storage_path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("sqlite: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	clk := clock.NewFake(time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC))
+	bus := events.NewBus()
+	queue := jobs.New(store, config.Jobs{}, clk, nil)
+	notify.NewNotifier(store, store, bus, queue, &fakeSender{}, config.Email{}, clk)
+
+	id, err := store.CreateStudent(context.Background(), "Asha", "asha@example.com", 21, clk.Now())
+	if err != nil {
+		t.Fatalf("create student: %v", err)
+	}
+	enrolled, _ := events.NewEnrollmentAdded(id, 42, clk.Now())
+	bus.Publish(context.Background(), enrolled)
+	// an unknown student gets no email, and does not fail the publisher
+	unknown, _ := events.NewEnrollmentAdded(id+1, 42, clk.Now())
+	bus.Publish(context.Background(), unknown)
+
+	emails, err := store.ListEmails(context.Background(), types.EmailPending, 10)
+	if err != nil {
+		t.Fatalf("list emails: %v", err)
+	}
+	if len(emails) != 1 {
+		t.Fatalf("want one queued email, got %+v", emails)
+	}
+	e := emails[0]
+	if e.Template != notify.EnrollmentTemplate || e.To != "asha@example.com" || e.Subject != "You are enrolled in course 42" ||
+		!strings.Contains(e.Body, "Hi Asha") || !strings.Contains(e.Body, "1 March 2025") {
+		t.Fatalf("queued email = %+v", e)
+	}
+}
+
+func TestRender(t *testing.T) {
+	t.Parallel()
+
+	subject, _, err := notify.Render(notify.WelcomeTemplate, struct {
+		Name      string
+		StudentId int64
+	}{"Asha\r\nBcc: someone@example.com", 1})
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if strings.ContainsAny(subject, "\r\n") {
+		t.Fatalf("subject %q has a line break", subject)
+	}
+	if _, _, err := notify.Render("nope", nil); err == nil {
+		t.Fatal("want an error for an unknown template")
+	}
+}
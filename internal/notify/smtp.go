@@ -0,0 +1,141 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+)
+
+// SMTP sends every message over a new connection, notifications are few and a pool would only hold idle connections
+type SMTP struct {
+	cfg   config.Email
+	from  *mail.Address
+	clock clock.Clock
+}
+
+func NewSMTP(cfg config.Email, clk clock.Clock) (*SMTP, error) {
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("email.from %q: %w", cfg.From, err)
+	}
+	switch cfg.TLS {
+	case "":
+		cfg.TLS = "starttls"
+	case "starttls", "tls", "none":
+	default:
+		return nil, fmt.Errorf("email.tls %q: want starttls, tls or none", cfg.TLS)
+	}
+	if cfg.Port <= 0 {
+		cfg.Port = 587
+	}
+	return &SMTP{cfg: cfg, from: from, clock: clk}, nil
+}
+
+// Send delivers msg to the server. an answer of 5xx is a *textproto.Error with that Code, see Permanent
+func (s *SMTP) Send(ctx context.Context, msg Message) (err error) {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("recipient %q: %w", msg.To, err)
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port)))
+	if err != nil {
+		return err
+	}
+	// net/smtp knows no ctx, a deadline in the past makes the blocked read or write return
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+	defer func() {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("smtp: %w", ctx.Err())
+		}
+	}()
+
+	if s.cfg.TLS == "tls" {
+		conn = tls.Client(conn, &tls.Config{ServerName: s.cfg.Host})
+	}
+	c, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if s.cfg.TLS == "starttls" {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("smtp server does not offer STARTTLS, set email.tls to none to send without it")
+		}
+		if err := c.StartTLS(&tls.Config{ServerName: s.cfg.Host}); err != nil {
+			return err
+		}
+	}
+	if s.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.from.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to.Address); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(s.compose(to, msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// compose is the message with its headers, the body as quoted-printable utf-8
+func (s *SMTP) compose(to *mail.Address, msg Message) []byte {
+	var buf bytes.Buffer
+	header := func(name, value string) { buf.WriteString(name + ": " + value + "\r\n") }
+	header("From", s.from.String())
+	header("To", to.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", s.clock.Now().Format(time.RFC1123Z))
+	header("Message-ID", s.messageId())
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=utf-8")
+	header("Content-Transfer-Encoding", "quoted-printable")
+	buf.WriteString("\r\n")
+	qp := quotedprintable.NewWriter(&buf)
+	qp.Write([]byte(msg.Body))
+	qp.Close()
+	return buf.Bytes()
+}
+
+func (s *SMTP) messageId() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	domain := s.from.Address[strings.LastIndex(s.from.Address, "@")+1:]
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">"
+}
+
+// Permanent says err is an answer of the server that no retry changes, like an unknown mailbox (5xx)
+func Permanent(err error) bool {
+	var reply *textproto.Error
+	return errors.As(err, &reply) && reply.Code >= 500
+}
@@ -0,0 +1,139 @@
+package notify_test
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/notify"
+)
+
+// fakeSMTP answers one session on a random port, rcptCode is its answer to RCPT. what came after DATA ends up on data
+func fakeSMTP(t *testing.T, rcptCode int) (host string, port int, data <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	out := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		c := textproto.NewConn(conn)
+		c.PrintfLine("220 fake ready")
+		for {
+			line, err := c.ReadLine()
+			if err != nil {
+				return
+			}
+			switch verb, _, _ := strings.Cut(line, " "); strings.ToUpper(verb) {
+			case "EHLO":
+				c.PrintfLine("250 fake")
+			case "MAIL", "RSET", "NOOP":
+				c.PrintfLine("250 ok")
+			case "RCPT":
+				c.PrintfLine("%d rcpt", rcptCode)
+			case "DATA":
+				c.PrintfLine("354 go on")
+				body, _ := io.ReadAll(c.DotReader())
+				out <- string(body)
+				c.PrintfLine("250 queued")
+			case "QUIT":
+				c.PrintfLine("221 bye")
+				return
+			default:
+				c.PrintfLine("502 not here")
+			}
+		}
+	}()
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, out
+}
+
+func TestSMTP(t *testing.T) {
+	t.Parallel()
+
+	host, port, data := fakeSMTP(t, 250)
+	sender, err := notify.NewSMTP(config.Email{Host: host, Port: port, TLS: "none", From: "School <no-reply@example.com>"},
+		clock.NewFake(time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatalf("NewSMTP: %v", err)
+	}
+	err = sender.Send(context.Background(), notify.Message{To: "asha@example.com", Subject: "Welcome, Åsha", Body: "Hi Åsha,\n\nsee you.\n.\n"})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(<-data))
+	if err != nil {
+		t.Fatalf("parse message: %v", err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if subject != "Welcome, Åsha" || msg.Header.Get("From") != `"School" <no-reply@example.com>` ||
+		msg.Header.Get("To") != "<asha@example.com>" || msg.Header.Get("Date") != "Sat, 01 Mar 2025 10:00:00 +0000" ||
+		!strings.HasSuffix(msg.Header.Get("Message-ID"), "@example.com>") {
+		t.Fatalf("headers = %v", msg.Header)
+	}
+	body, _ := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if got := strings.ReplaceAll(string(body), "\r\n", "\n"); got != "Hi Åsha,\n\nsee you.\n.\n" {
+		t.Fatalf("body = %q", got)
+	}
+}
+
+func TestSMTPRejected(t *testing.T) {
+	t.Parallel()
+
+	host, port, _ := fakeSMTP(t, 550)
+	sender, err := notify.NewSMTP(config.Email{Host: host, Port: port, TLS: "none", From: "no-reply@example.com"}, clock.System{})
+	if err != nil {
+		t.Fatalf("NewSMTP: %v", err)
+	}
+	err = sender.Send(context.Background(), notify.Message{To: "nobody@example.com", Subject: "hi", Body: "hi"})
+	if err == nil || !notify.Permanent(err) {
+		t.Fatalf("send to a rejected mailbox = %v, want a permanent error", err)
+	}
+}
+
+func TestSMTPNeedsStartTLS(t *testing.T) {
+	t.Parallel()
+
+	host, port, _ := fakeSMTP(t, 250)
+	sender, err := notify.NewSMTP(config.Email{Host: host, Port: port, From: "no-reply@example.com"}, clock.System{})
+	if err != nil {
+		t.Fatalf("NewSMTP: %v", err)
+	}
+	// the fake offers no STARTTLS, nothing is sent in the clear
+	err = sender.Send(context.Background(), notify.Message{To: "asha@example.com", Subject: "hi", Body: "hi"})
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") || notify.Permanent(err) {
+		t.Fatalf("send without STARTTLS = %v, want a STARTTLS error", err)
+	}
+}
+
+func TestNewSMTPConfig(t *testing.T) {
+	t.Parallel()
+
+	for _, cfg := range []config.Email{
+		{Host: "smtp.example.com", From: "not an address"},
+		{Host: "smtp.example.com", From: "no-reply@example.com", TLS: "ssl"},
+	} {
+		if _, err := notify.NewSMTP(cfg, clock.System{}); err == nil {
+			t.Errorf("NewSMTP(%+v): want an error", cfg)
+		}
+	}
+	if _, err := notify.NewSMTP(config.Email{Host: "smtp.example.com", From: "no-reply@example.com"}, clock.System{}); err != nil {
+		t.Fatalf("NewSMTP: %v", err)
+	}
+}
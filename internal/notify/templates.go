@@ -0,0 +1,46 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// every template defines a "subject" and a "body"
+//
+//go:embed templates/*.tmpl
+var templateFiles embed.FS
+
+// template names, also what the outbox shows as template
+const (
+	WelcomeTemplate    = "welcome"
+	EnrollmentTemplate = "enrollment"
+)
+
+var templates = func() map[string]*template.Template {
+	out := map[string]*template.Template{}
+	for _, name := range []string{WelcomeTemplate, EnrollmentTemplate} {
+		out[name] = template.Must(template.ParseFS(templateFiles, "templates/"+name+".tmpl"))
+	}
+	return out
+}()
+
+// Render fills the template name with data, the subject is one line
+func Render(name string, data any) (subject, body string, err error) {
+	t, ok := templates[name]
+	if !ok {
+		return "", "", fmt.Errorf("unknown email template %q", name)
+	}
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, "subject", data); err != nil {
+		return "", "", err
+	}
+	subject = strings.Join(strings.Fields(buf.String()), " ") // a name with a line break in it must not end the subject
+	buf.Reset()
+	if err := t.ExecuteTemplate(&buf, "body", data); err != nil {
+		return "", "", err
+	}
+	return subject, buf.String(), nil
+}
@@ -0,0 +1,38 @@
+package observability
+
+import (
+	"context"
+	"errors"
+
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// Setup installs the W3C traceparent propagator and, when an OTLP endpoint is configured, the trace and metric exporters.
+// without an endpoint spans and instruments are no-ops but incoming trace ids still flow through.
+// the returned func flushes and stops both exporters, call it on shutdown
+func Setup(ctx context.Context, cfg config.Observability) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if cfg.OTLPEndpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	res := resource.NewSchemaless(attribute.String("service.name", cfg.ServiceName))
+
+	shutdownTracing, err := setupTracing(ctx, cfg, res)
+	if err != nil {
+		return nil, err
+	}
+	shutdownMetrics, err := setupMetrics(ctx, cfg, res)
+	if err != nil {
+		return nil, errors.Join(err, shutdownTracing(ctx))
+	}
+
+	return func(ctx context.Context) error {
+		return errors.Join(shutdownTracing(ctx), shutdownMetrics(ctx))
+	}, nil
+}
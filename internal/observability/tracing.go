@@ -0,0 +1,31 @@
+package observability
+
+import (
+	"context"
+
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func setupTracing(ctx context.Context, cfg config.Observability, res *resource.Resource) (func(context.Context) error, error) {
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.OTLPEndpoint), otlptracehttp.WithHeaders(cfg.Headers)}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter), // spans are sent in batches in the background, not one request per span
+		sdktrace.WithResource(res),
+		// a caller that already decided to sample (or not) wins, so one trace is never cut in half between services
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
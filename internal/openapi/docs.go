@@ -0,0 +1,32 @@
+package openapi
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// swagger ui comes from a cdn, shipping its few megabytes of js in the binary is not worth it for a docs page
+var docsPage = template.Must(template.New("docs").Parse(`<!doctype html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>{{.Title}}</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: {{.SpecURL}}, dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`))
+
+// Docs serves swagger ui for the document at specURL
+func Docs(title, specURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		docsPage.Execute(w, map[string]string{"Title": title, "SpecURL": specURL})
+	}
+}
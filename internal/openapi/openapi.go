@@ -0,0 +1,147 @@
+// Package openapi builds the OpenAPI 3 document of the api from registered operations.
+// request and response bodies are given as go values, their schemas come from the struct fields and json tags,
+// so a renamed field shows up in the spec without anyone editing it
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Operation is one route -> Method and Path like the router pattern ("GET", "/api/students/{id}")
+type Operation struct {
+	Method  string
+	Path    string
+	Summary string
+	Tag     string
+	Auth    bool    // needs a bearer token or an api key
+	Query   []Param // path params are found in Path, only query params go here
+	Body    any     // zero value of the request body type, nil when there is none
+	// Responses maps status codes to the zero value of the body type, nil for an empty body.
+	// error statuses can use response.Response{}
+	Responses map[int]any
+}
+
+// Param is a query parameter
+type Param struct {
+	Name        string
+	Type        string // string, integer, boolean
+	Description string
+}
+
+// Spec collects the operations and the schemas they use
+type Spec struct {
+	title   string
+	version string
+	ops     []Operation
+	schemas *schemas
+}
+
+func New(title, version string) *Spec {
+	return &Spec{title: title, version: version, schemas: newSchemas()}
+}
+
+// Add registers an operation, call it next to the route registration
+func (s *Spec) Add(op Operation) {
+	s.ops = append(s.ops, op)
+}
+
+// Routes lists "METHOD /path" of every operation, for comparing the spec with the router
+func (s *Spec) Routes() []string {
+	routes := make([]string, 0, len(s.ops))
+	for _, op := range s.ops {
+		routes = append(routes, op.Method+" "+op.Path)
+	}
+	return routes
+}
+
+var pathParam = regexp.MustCompile(`\{([^}.]+)(\.\.\.)?\}`)
+
+// Document is the OpenAPI 3 document as a json-ready value
+func (s *Spec) Document() map[string]any {
+	paths := map[string]map[string]any{}
+	for _, op := range s.ops {
+		path := pathParam.ReplaceAllString(op.Path, "{$1}") // {rest...} is a go pattern, openapi only knows {rest}
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path][strings.ToLower(op.Method)] = s.operation(op)
+	}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info":    map[string]any{"title": s.title, "version": s.version},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": s.schemas.defs,
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				"apiKey": map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+	}
+}
+
+func (s *Spec) operation(op Operation) map[string]any {
+	out := map[string]any{"summary": op.Summary}
+	if op.Tag != "" {
+		out["tags"] = []string{op.Tag}
+	}
+	if op.Auth {
+		out["security"] = []map[string][]string{{"bearer": {}}, {"apiKey": {}}}
+	}
+
+	var params []map[string]any
+	for _, m := range pathParam.FindAllStringSubmatch(op.Path, -1) {
+		typ := "string"
+		if m[1] == "id" || strings.HasSuffix(m[1], "Id") {
+			typ = "integer"
+		}
+		params = append(params, map[string]any{"name": m[1], "in": "path", "required": true, "schema": map[string]any{"type": typ}})
+	}
+	for _, q := range op.Query {
+		params = append(params, map[string]any{"name": q.Name, "in": "query", "description": q.Description, "schema": map[string]any{"type": q.Type}})
+	}
+	if params != nil {
+		out["parameters"] = params
+	}
+
+	if op.Body != nil {
+		out["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{"application/json": map[string]any{"schema": s.schemas.of(op.Body)}},
+		}
+	}
+
+	responses := map[string]any{}
+	codes := make([]int, 0, len(op.Responses))
+	for code := range op.Responses {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	for _, code := range codes {
+		r := map[string]any{"description": http.StatusText(code)}
+		if body := op.Responses[code]; body != nil {
+			r["content"] = map[string]any{"application/json": map[string]any{"schema": s.schemas.of(body)}}
+		}
+		responses[strconv.Itoa(code)] = r
+	}
+	out["responses"] = responses
+	return out
+}
+
+// Handler serves the document as json, built once
+func (s *Spec) Handler() http.HandlerFunc {
+	doc, err := json.Marshal(s.Document())
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			http.Error(w, "openapi document could not be built", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(doc)
+	}
+}
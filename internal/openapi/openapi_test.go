@@ -0,0 +1,69 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/openapi"
+)
+
+type pet struct {
+	Id    int64    `json:"id"`
+	Name  string   `json:"name" validate:"required"`
+	Tags  []string `json:"tags,omitempty"`
+	Owner *pet     `json:"owner,omitempty"`
+	Note  string   `json:"-"`
+}
+
+func TestDocument(t *testing.T) {
+	t.Parallel()
+
+	spec := openapi.New("test", "1.0.0")
+	spec.Add(openapi.Operation{Method: "PUT", Path: "/pets/{id}", Auth: true, Body: pet{},
+		Responses: map[int]any{200: pet{}, 404: nil}})
+	spec.Add(openapi.Operation{Method: "GET", Path: "/files/{name}/{rest...}"})
+
+	raw, err := json.Marshal(spec.Document())
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	at := func(path ...string) any {
+		var v any = doc
+		for _, p := range path {
+			m, ok := v.(map[string]any)
+			if !ok {
+				return nil
+			}
+			v = m[p]
+		}
+		return v
+	}
+
+	tests := []struct {
+		name string
+		path []string
+		want any
+	}{
+		{name: "body is a reference", path: []string{"paths", "/pets/{id}", "put", "requestBody", "content", "application/json", "schema", "$ref"}, want: "#/components/schemas/openapi_test.pet"},
+		{name: "required from validate tag", path: []string{"components", "schemas", "openapi_test.pet", "required"}, want: []any{"name"}},
+		{name: "json dash is skipped", path: []string{"components", "schemas", "openapi_test.pet", "properties", "Note"}, want: nil},
+		{name: "self reference", path: []string{"components", "schemas", "openapi_test.pet", "properties", "owner", "$ref"}, want: "#/components/schemas/openapi_test.pet"},
+		{name: "empty response has no content", path: []string{"paths", "/pets/{id}", "put", "responses", "404", "content"}, want: nil},
+		{name: "wildcard path", path: []string{"paths", "/files/{name}/{rest}", "get", "summary"}, want: ""},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := at(tc.path...); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("%v = %#v, want %#v", tc.path, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package openapi
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// schemas turns go types into json schemas, named structs go to components/schemas once and are referenced after that
+type schemas struct {
+	defs map[string]any
+}
+
+func newSchemas() *schemas {
+	return &schemas{defs: map[string]any{}}
+}
+
+func (s *schemas) of(v any) map[string]any {
+	return s.schema(reflect.TypeOf(v))
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (s *schemas) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t.ConvertibleTo(timeType): // time.Time and types over it, like a Time with its own text format
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.String:
+		return map[string]any{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return map[string]any{"type": "number"}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return map[string]any{"type": "array", "items": s.schema(t.Elem())}
+	case t.Kind() == reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.schema(t.Elem())}
+	case t.Kind() == reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		name := schemaName(t)
+		if _, done := s.defs[name]; !done {
+			s.defs[name] = map[string]any{} // placeholder, so a type that contains itself does not loop forever
+			s.defs[name] = s.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]any{} // interface{} and friends, anything goes
+}
+
+func (s *schemas) object(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	var required []string
+	s.fields(t, props, &required)
+	obj := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		obj["required"] = required
+	}
+	return obj
+}
+
+func (s *schemas) fields(t reflect.Type, props map[string]any, required *[]string) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct { // embedded fields are flattened like encoding/json does
+			s.fields(f.Type, props, required)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = s.schema(f.Type)
+		if strings.Contains(f.Tag.Get("validate"), "required") && !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// schemaName is "types.Student" -> "Student", types from other packages keep the package -> "auth.TokenResponse"
+func schemaName(t reflect.Type) string {
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	if pkg == "types" || pkg == "" {
+		return t.Name()
+	}
+	return pkg + "." + t.Name()
+}
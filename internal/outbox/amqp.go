@@ -0,0 +1,159 @@
+package outbox
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// AMQPSink publishes every event to one rabbitmq exchange with publisher confirms on. a batch is published once the
+// broker confirmed every message of it, a nack or a lost connection fails the batch and the relay sends it again. the
+// next batch after a lost connection dials a new one, the relay backoff paces that. an event no queue is bound for is
+// confirmed and dropped by the broker, an alternate exchange on the broker side keeps those
+type AMQPSink struct {
+	url      string
+	exchange string
+	cfg      config.OutboxAMQP
+	dial     amqp.Config
+
+	mu      sync.Mutex // one batch at a time, the channel sequences the confirms
+	conn    *amqp.Connection
+	channel *amqp.Channel
+}
+
+func NewAMQPSink(cfg config.Outbox) (*AMQPSink, error) {
+	uri, err := amqp.ParseURI(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("outbox.url: want an amqp(s) url, %w", err)
+	}
+	switch cfg.AMQP.ExchangeType {
+	case "":
+		cfg.AMQP.ExchangeType = "topic"
+	case "direct", "fanout", "topic", "headers":
+	default:
+		return nil, fmt.Errorf("outbox.amqp.exchange_type %q: want direct, fanout, topic or headers", cfg.AMQP.ExchangeType)
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	properties := amqp.NewConnectionProperties()
+	properties.SetClientConnectionName("go-server-outbox")
+	dial := amqp.Config{Dial: amqp.DefaultDial(timeout), Properties: properties, Locale: "en_US"}
+	if cfg.AMQP.Username != "" {
+		dial.SASL = []amqp.Authentication{&amqp.PlainAuth{Username: cfg.AMQP.Username, Password: cfg.AMQP.Password}}
+	}
+	if uri.Scheme == "amqps" {
+		dial.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12, ServerName: uri.Host}
+		if cfg.AMQP.CAFile != "" {
+			roots, err := rootCAs(cfg.AMQP.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("outbox.amqp.ca_file: %w", err)
+			}
+			dial.TLSClientConfig.RootCAs = roots
+		}
+	}
+	return &AMQPSink{url: cfg.URL, exchange: cfg.Topic, cfg: cfg.AMQP, dial: dial}, nil
+}
+
+func (s *AMQPSink) Publish(ctx context.Context, events []types.OutboxEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	channel, err := s.open()
+	if err != nil {
+		return err
+	}
+	confirms := make([]*amqp.DeferredConfirmation, 0, len(events))
+	for _, e := range events {
+		body, err := json.Marshal(NewMessage(e))
+		if err != nil {
+			return err
+		}
+		confirm, err := channel.PublishWithDeferredConfirmWithContext(ctx, s.exchange, s.routingKey(e.EventType), false, false, amqp.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent, // survives a broker restart once it sits in a durable queue
+			MessageId:    strconv.FormatInt(e.Id, 10),
+			Type:         e.EventType,
+			Timestamp:    e.CreatedAt.UTC(),
+			AppId:        "go-server",
+			Body:         body,
+		})
+		if err != nil {
+			s.close()
+			return err
+		}
+		confirms = append(confirms, confirm)
+	}
+	// a closed channel confirms what is still open as nacks, so this never waits longer than ctx
+	for i, confirm := range confirms {
+		acked, err := confirm.WaitContext(ctx)
+		if err != nil {
+			s.close()
+			return err
+		}
+		if !acked {
+			return fmt.Errorf("broker nacked event %d", events[i].Id)
+		}
+	}
+	return nil
+}
+
+// routingKey is the event type unless RoutingKeys has another key for it
+func (s *AMQPSink) routingKey(eventType string) string {
+	if key, ok := s.cfg.RoutingKeys[eventType]; ok {
+		return key
+	}
+	return eventType
+}
+
+// open returns the channel, dialing the broker again when the last connection was lost. the caller holds mu
+func (s *AMQPSink) open() (*amqp.Channel, error) {
+	if s.channel != nil && !s.channel.IsClosed() {
+		return s.channel, nil
+	}
+	if s.conn != nil {
+		slog.Warn("outbox amqp connection lost, dialing again")
+		s.close()
+	}
+	conn, err := amqp.DialConfig(s.url, s.dial)
+	if err != nil {
+		return nil, err
+	}
+	channel, err := conn.Channel()
+	if err == nil {
+		err = channel.Confirm(false)
+	}
+	if err == nil && s.cfg.Declare {
+		err = channel.ExchangeDeclare(s.exchange, s.cfg.ExchangeType, true, false, false, false, nil)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	s.conn, s.channel = conn, channel
+	return channel, nil
+}
+
+// close drops the connection, the caller holds mu
+func (s *AMQPSink) close() {
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.conn, s.channel = nil, nil
+}
+
+func (s *AMQPSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.close()
+	return nil
+}
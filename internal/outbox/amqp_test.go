@@ -0,0 +1,77 @@
+package outbox_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/outbox"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+func TestNewAMQPSink(t *testing.T) {
+	t.Parallel()
+
+	notPEM := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(notPEM, []byte("not a certificate"), 0o600)
+
+	type testCase struct {
+		name    string
+		url     string
+		amqp    config.OutboxAMQP
+		wantErr bool
+	}
+
+	tests := []testCase{
+		{name: "defaults", url: "amqp://rabbit:5672/"},
+		{name: "tls_with_credentials", url: "amqps://rabbit/events", amqp: config.OutboxAMQP{ExchangeType: "fanout", Username: "u", Password: "p"}},
+		{name: "routing_keys", url: "amqp://rabbit", amqp: config.OutboxAMQP{RoutingKeys: map[string]string{"student.created": "students.new"}}},
+		{name: "not_amqp", url: "http://rabbit:5672", wantErr: true},
+		{name: "unknown_exchange_type", url: "amqp://rabbit", amqp: config.OutboxAMQP{ExchangeType: "x-delayed"}, wantErr: true},
+		{name: "ca_file_without_certificate", url: "amqps://rabbit", amqp: config.OutboxAMQP{CAFile: notPEM}, wantErr: true},
+		{name: "ca_file_ignored_without_tls", url: "amqp://rabbit", amqp: config.OutboxAMQP{CAFile: notPEM}},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			sink, err := outbox.NewAMQPSink(config.Outbox{URL: tc.url, Topic: "events", AMQP: tc.amqp})
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tc.wantErr)
+			}
+			if sink != nil {
+				sink.Close()
+			}
+		})
+	}
+}
+
+// a broker that is down fails the batch, so it stays in the outbox, and every batch dials again
+func TestAMQPSinkBrokerDown(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	sink, err := outbox.NewAMQPSink(config.Outbox{URL: "amqp://" + addr, Topic: "events", Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("sink: %v", err)
+	}
+	defer sink.Close()
+	batch := []types.OutboxEvent{{Id: 1, EventType: "student.created", Payload: `{}`, CreatedAt: time.Now()}}
+	for attempt := 1; attempt <= 2; attempt++ {
+		if err := sink.Publish(context.Background(), batch); err == nil {
+			t.Fatalf("attempt %d: published without a broker", attempt)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package outbox
+
+import (
+	_ "embed"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+// AvroSchema is the schema of the avro the kafka sink writes, register it in the schema registry to get the schema_id
+//
+//go:embed student_event.avsc
+var AvroSchema string
+
+// ErrNoAvro is returned for events that are not about a student, AvroSchema has no place for them
+var ErrNoAvro = errors.New("event has no avro encoding")
+
+// EncodeAvro is e in the avro binary encoding of AvroSchema. the schema is fixed, so the encoding is written by hand
+// instead of pulling in a generic avro library. schemaId > 0 adds the confluent framing -> a 0 byte and the big
+// endian id in front
+func EncodeAvro(e types.OutboxEvent, schemaId int) ([]byte, error) {
+	var student struct {
+		StudentId int64   `json:"student_id"`
+		Name      *string `json:"name"`
+		Email     *string `json:"email"`
+		Age       *int    `json:"age"`
+	}
+	if err := json.Unmarshal([]byte(e.Payload), &student); err != nil {
+		return nil, fmt.Errorf("event %d: %w", e.Id, err)
+	}
+	if student.StudentId <= 0 {
+		return nil, fmt.Errorf("event %d %s: %w", e.Id, e.EventType, ErrNoAvro)
+	}
+
+	buf := make([]byte, 0, 64+len(e.Payload))
+	if schemaId > 0 {
+		buf = append(buf, 0)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(schemaId))
+	}
+	buf = binary.AppendVarint(buf, e.Id) // avro longs are zigzag varints, the same as encoding/binary's
+	buf = appendAvroString(buf, e.EventType)
+	buf = binary.AppendVarint(buf, e.CreatedAt.UnixMilli())
+	buf = binary.AppendVarint(buf, student.StudentId)
+	// a union is the index of the branch and then the value, null is branch 0 and has no value
+	for _, s := range []*string{student.Name, student.Email} {
+		if s == nil {
+			buf = binary.AppendVarint(buf, 0)
+			continue
+		}
+		buf = binary.AppendVarint(buf, 1)
+		buf = appendAvroString(buf, *s)
+	}
+	if student.Age == nil {
+		buf = binary.AppendVarint(buf, 0)
+	} else {
+		buf = binary.AppendVarint(buf, 1)
+		buf = binary.AppendVarint(buf, int64(*student.Age))
+	}
+	return buf, nil
+}
+
+// appendAvroString is the length as a long and then the utf-8 bytes
+func appendAvroString(buf []byte, s string) []byte {
+	buf = binary.AppendVarint(buf, int64(len(s)))
+	return append(buf, s...)
+}
@@ -0,0 +1,63 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+// HTTPSink posts every batch as a json array of Message, any 2xx means the receiver has all of them
+type HTTPSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func NewHTTPSink(cfg config.Outbox) (*HTTPSink, error) {
+	if u, err := url.Parse(cfg.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("outbox.url %q: want an http(s) url", cfg.URL)
+	}
+	return &HTTPSink{url: cfg.URL, headers: cfg.Headers, client: &http.Client{}}, nil
+}
+
+func (s *HTTPSink) Publish(ctx context.Context, events []types.OutboxEvent) error {
+	messages := make([]Message, len(events))
+	for i, e := range events {
+		messages[i] = NewMessage(e)
+	}
+	body, err := json.Marshal(messages)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-server-outbox")
+	for name, value := range s.headers {
+		req.Header.Set(name, value)
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10)) // drain a little so the connection can be reused
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("sink answered %d", res.StatusCode)
+	}
+	return nil
+}
+
+func (s *HTTPSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
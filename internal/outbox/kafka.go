@@ -0,0 +1,168 @@
+package outbox
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// KafkaSink writes every event to one topic. the key is the student the event is about, so the events of one student
+// land on one partition and are read in the order they happened
+type KafkaSink struct {
+	writer   *kafka.Writer
+	encoding string
+	schemaId int
+}
+
+func NewKafkaSink(cfg config.Outbox) (*KafkaSink, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, errors.New("outbox.brokers is empty")
+	}
+	switch cfg.Kafka.Encoding {
+	case "":
+		cfg.Kafka.Encoding = "json"
+	case "json", "avro":
+	default:
+		return nil, fmt.Errorf("outbox.kafka.encoding %q: want json or avro", cfg.Kafka.Encoding)
+	}
+	transport, err := kafkaTransport(cfg.Kafka)
+	if err != nil {
+		return nil, err
+	}
+	return &KafkaSink{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(cfg.Brokers...),
+			Topic:        cfg.Topic,
+			Transport:    transport,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll, // published means on every in-sync replica
+			MaxAttempts:  1,                // the relay retries the batch
+			BatchSize:    max(cfg.BatchSize, 1),
+		},
+		encoding: cfg.Kafka.Encoding,
+		schemaId: cfg.Kafka.SchemaID,
+	}, nil
+}
+
+// kafkaTransport has the tls and sasl of cfg, sasl without tls sends the password in the clear and is up to the operator
+func kafkaTransport(cfg config.OutboxKafka) (*kafka.Transport, error) {
+	transport := &kafka.Transport{}
+	if cfg.TLS {
+		transport.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+		if cfg.CAFile != "" {
+			roots, err := rootCAs(cfg.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("outbox.kafka.ca_file: %w", err)
+			}
+			transport.TLS.RootCAs = roots
+		}
+	}
+	var (
+		mechanism sasl.Mechanism
+		err       error
+	)
+	switch cfg.SASL {
+	case "":
+		return transport, nil
+	case "plain":
+		mechanism = plain.Mechanism{Username: cfg.Username, Password: cfg.Password}
+	case "scram-sha-256":
+		mechanism, err = scram.Mechanism(scram.SHA256, cfg.Username, cfg.Password)
+	case "scram-sha-512":
+		mechanism, err = scram.Mechanism(scram.SHA512, cfg.Username, cfg.Password)
+	default:
+		return nil, fmt.Errorf("outbox.kafka.sasl %q: want plain, scram-sha-256 or scram-sha-512", cfg.SASL)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("outbox.kafka.sasl: %w", err)
+	}
+	transport.SASL = mechanism
+	return transport, nil
+}
+
+// rootCAs are the system roots plus the certificates in file
+func rootCAs(file string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%s: no certificate in it", file)
+	}
+	return roots, nil
+}
+
+func (s *KafkaSink) Publish(ctx context.Context, events []types.OutboxEvent) error {
+	messages, err := s.messages(events)
+	if err != nil || len(messages) == 0 {
+		return err
+	}
+	return s.writer.WriteMessages(ctx, messages...)
+}
+
+// messages are events encoded for the topic. avro is for student changes, other events are left out of it instead of
+// holding up the outbox behind them
+func (s *KafkaSink) messages(events []types.OutboxEvent) ([]kafka.Message, error) {
+	messages := make([]kafka.Message, 0, len(events))
+	for _, e := range events {
+		var (
+			value       []byte
+			contentType string
+			err         error
+		)
+		if s.encoding == "avro" {
+			value, err = EncodeAvro(e, s.schemaId)
+			contentType = "avro/binary"
+			if errors.Is(err, ErrNoAvro) {
+				continue
+			}
+		} else {
+			value, err = json.Marshal(NewMessage(e))
+			contentType = "application/json"
+		}
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, kafka.Message{
+			Key:   partitionKey(e),
+			Value: value,
+			Headers: []kafka.Header{
+				{Key: "event-id", Value: []byte(strconv.FormatInt(e.Id, 10))},
+				{Key: "event-type", Value: []byte(e.EventType)},
+				{Key: "content-type", Value: []byte(contentType)},
+			},
+		})
+	}
+	return messages, nil
+}
+
+func (s *KafkaSink) Close() error {
+	return s.writer.Close()
+}
+
+// partitionKey is the student_id of the event, the event type for events about no student
+func partitionKey(e types.OutboxEvent) []byte {
+	var about struct {
+		StudentId int64 `json:"student_id"`
+	}
+	if json.Unmarshal([]byte(e.Payload), &about) == nil && about.StudentId > 0 {
+		return []byte(strconv.FormatInt(about.StudentId, 10))
+	}
+	return []byte(e.EventType)
+}
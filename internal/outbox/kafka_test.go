@@ -0,0 +1,132 @@
+package outbox_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/outbox"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+func TestEncodeAvro(t *testing.T) {
+	t.Parallel()
+
+	at := time.UnixMilli(1).UTC()
+	str := func(s string) []byte { return append([]byte{byte(len(s) * 2)}, s...) } // short strings only, one byte length
+
+	type testCase struct {
+		name     string
+		event    types.OutboxEvent
+		schemaId int
+		want     []byte
+		wantErr  error
+	}
+
+	tests := []testCase{
+		{
+			name:  "deleted_has_nulls",
+			event: types.OutboxEvent{Id: 1, EventType: "student.deleted", Payload: `{"student_id":3}`, CreatedAt: at},
+			want:  bytes.Join([][]byte{{0x02}, str("student.deleted"), {0x02, 0x06, 0x00, 0x00, 0x00}}, nil),
+		},
+		{
+			name:  "created",
+			event: types.OutboxEvent{Id: 2, EventType: "student.created", Payload: `{"student_id":3,"name":"Asha","email":"a@b.c","age":21}`, CreatedAt: at},
+			want: bytes.Join([][]byte{{0x04}, str("student.created"), {0x02, 0x06},
+				{0x02}, str("Asha"), {0x02}, str("a@b.c"), {0x02, 42}}, nil),
+		},
+		{
+			name:     "confluent_framing",
+			event:    types.OutboxEvent{Id: 1, EventType: "student.deleted", Payload: `{"student_id":3}`, CreatedAt: at},
+			schemaId: 258,
+			want:     bytes.Join([][]byte{{0x00, 0x00, 0x00, 0x01, 0x02}, {0x02}, str("student.deleted"), {0x02, 0x06, 0x00, 0x00, 0x00}}, nil),
+		},
+		{
+			name:    "not_about_a_student",
+			event:   types.OutboxEvent{Id: 1, EventType: "course.created", Payload: `{"course_id":3}`, CreatedAt: at},
+			wantErr: outbox.ErrNoAvro,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := outbox.EncodeAvro(tc.event, tc.schemaId)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tc.wantErr)
+			}
+			if !bytes.Equal(got, tc.want) {
+				t.Fatalf("encoded\n%x, want\n%x", got, tc.want)
+			}
+		})
+	}
+}
+
+// EncodeAvro writes the fields in the order of the schema, a reordered schema would make consumers read garbage
+func TestAvroSchema(t *testing.T) {
+	t.Parallel()
+
+	var schema struct {
+		Fields []struct {
+			Name string `json:"name"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal([]byte(outbox.AvroSchema), &schema); err != nil {
+		t.Fatalf("schema is not json: %v", err)
+	}
+	want := []string{"id", "type", "created_at", "student_id", "name", "email", "age"}
+	if len(schema.Fields) != len(want) {
+		t.Fatalf("schema has %d fields, want %v", len(schema.Fields), want)
+	}
+	for i, f := range schema.Fields {
+		if f.Name != want[i] {
+			t.Fatalf("field %d is %q, want %q", i, f.Name, want[i])
+		}
+	}
+}
+
+func TestNewKafkaSink(t *testing.T) {
+	t.Parallel()
+
+	notPEM := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(notPEM, []byte("not a certificate"), 0o600)
+	brokers := []string{"127.0.0.1:9092"}
+
+	type testCase struct {
+		name    string
+		kafka   config.OutboxKafka
+		wantErr bool
+	}
+
+	tests := []testCase{
+		{name: "defaults", kafka: config.OutboxKafka{}},
+		{name: "avro_over_tls_with_scram", kafka: config.OutboxKafka{Encoding: "avro", SchemaID: 1, TLS: true, SASL: "scram-sha-512", Username: "u", Password: "p"}},
+		{name: "plain", kafka: config.OutboxKafka{SASL: "plain", Username: "u", Password: "p"}},
+		{name: "unknown_encoding", kafka: config.OutboxKafka{Encoding: "protobuf"}, wantErr: true},
+		{name: "unknown_sasl", kafka: config.OutboxKafka{SASL: "gssapi"}, wantErr: true},
+		{name: "missing_ca_file", kafka: config.OutboxKafka{TLS: true, CAFile: filepath.Join(t.TempDir(), "nope.pem")}, wantErr: true},
+		{name: "ca_file_without_certificate", kafka: config.OutboxKafka{TLS: true, CAFile: notPEM}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			sink, err := outbox.NewKafkaSink(config.Outbox{Brokers: brokers, Topic: "events", Kafka: tc.kafka})
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tc.wantErr)
+			}
+			if sink != nil {
+				sink.Close()
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/manishtomar-cpi/go-server/internal/types"
+	"github.com/nats-io/nats.go"
+)
+
+// NATSSink publishes every event to <topic>.<event type>. a JetStream stream on those subjects de-duplicates a batch
+// that is published twice, the event id goes out as Nats-Msg-Id
+type NATSSink struct {
+	conn  *nats.Conn
+	topic string
+}
+
+// NewNATSSink publishes on conn, the connection of messaging.nats that also reconnects it
+func NewNATSSink(conn *nats.Conn, topic string) *NATSSink {
+	return &NATSSink{conn: conn, topic: topic}
+}
+
+func (s *NATSSink) Publish(ctx context.Context, events []types.OutboxEvent) error {
+	for _, e := range events {
+		data, err := json.Marshal(NewMessage(e))
+		if err != nil {
+			return err
+		}
+		msg := nats.NewMsg(s.topic + "." + e.EventType)
+		msg.Header.Set(nats.MsgIdHdr, strconv.FormatInt(e.Id, 10))
+		msg.Data = data
+		if err := s.conn.PublishMsg(msg); err != nil {
+			return err
+		}
+	}
+	// published only counts once the server has the messages, a flush round trip proves that. while reconnecting it
+	// fails and the batch stays in the outbox, instead of sitting in the reconnect buffer
+	return s.conn.FlushWithContext(ctx)
+}
+
+// Close leaves the connection open, messaging owns it
+func (s *NATSSink) Close() error {
+	return nil
+}
@@ -0,0 +1,229 @@
+// Package outbox publishes the public domain events to an external sink (an http endpoint, kafka, nats or rabbitmq).
+// the store writes every event to the outbox table in the transaction of the student change it is about, so a change
+// that committed always has its event and one that rolled back never does. a relay publishes the pending ones in order
+// and marks them delivered after the sink took them -> a sink that is down or a restart delays events but loses none.
+// a crash between publishing and marking publishes a batch twice, consumers de-duplicate on the event id
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/events"
+	"github.com/manishtomar-cpi/go-server/internal/jobs"
+	"github.com/manishtomar-cpi/go-server/internal/metrics"
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+	"github.com/nats-io/nats.go"
+)
+
+// Sink takes a batch of events in order, an error means none of them counts as published
+type Sink interface {
+	Publish(ctx context.Context, events []types.OutboxEvent) error
+	Close() error
+}
+
+// Message is how every sink sends one event, Data is the event itself
+type Message struct {
+	Id        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+func NewMessage(e types.OutboxEvent) Message {
+	return Message{Id: e.Id, Type: e.EventType, CreatedAt: e.CreatedAt.UTC(), Data: json.RawMessage(e.Payload)}
+}
+
+// New makes the sink cfg.Sink names, nc is the connection of messaging.nats and nil when it is off
+func New(cfg config.Outbox, nc *nats.Conn) (Sink, error) {
+	switch cfg.Sink {
+	case "http":
+		return NewHTTPSink(cfg)
+	case "kafka":
+		return NewKafkaSink(cfg)
+	case "nats":
+		if nc == nil {
+			return nil, errors.New("outbox.sink nats needs messaging.nats.url")
+		}
+		return NewNATSSink(nc, cfg.Topic), nil
+	case "amqp":
+		return NewAMQPSink(cfg)
+	default:
+		return nil, fmt.Errorf("outbox.sink %q: want http, kafka, nats or amqp", cfg.Sink)
+	}
+}
+
+// Relay publishes the public events of the outbox to the sink
+type Relay struct {
+	store   storage.OutboxStore
+	sink    Sink
+	cfg     config.Outbox
+	clock   clock.Clock
+	metrics *metrics.Outbox
+	woken   chan struct{}
+
+	running atomic.Bool   // set by Run
+	done    chan struct{} // closed when Run returned
+}
+
+func NewRelay(store storage.OutboxStore, sink Sink, bus *events.Bus, cfg config.Outbox, clk clock.Clock, m *metrics.Outbox) *Relay {
+	// zero values come from configs built in code (tests), they get the same defaults as the yaml
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = time.Second
+	}
+	cfg.MaxDelay = max(cfg.MaxDelay, cfg.BaseDelay)
+	r := &Relay{
+		store:   store,
+		sink:    sink,
+		cfg:     cfg,
+		clock:   clk,
+		metrics: m,
+		woken:   make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	bus.Subscribe(r.wake)
+	return r
+}
+
+// wake is a bus subscriber, the row of a public event is already committed when it is published so the relay can go
+// and send it instead of waiting for the next poll
+func (r *Relay) wake(ctx context.Context, e events.Event) {
+	if !events.Public[e.EventType()] {
+		return
+	}
+	select {
+	case r.woken <- struct{}{}:
+	default: // already woken
+	}
+}
+
+// Run publishes pending events until ctx is cancelled. a batch the sink refused is tried again after a backoff, the
+// events behind it wait so the sink gets them in order
+func (r *Relay) Run(ctx context.Context) {
+	r.running.Store(true)
+	defer close(r.done)
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+	failures := 0
+	for ctx.Err() == nil {
+		n, err := r.publish(ctx)
+		r.updateBacklog(ctx)
+		wait := time.Duration(0)
+		switch {
+		case err != nil:
+			failures++
+			wait = jobs.Backoff(r.cfg.BaseDelay, r.cfg.MaxDelay, failures)
+		case n == r.cfg.BatchSize:
+			failures = 0
+			continue // there may be more right behind it
+		default:
+			failures = 0
+		}
+		if wait > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(wait):
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		case <-r.woken:
+		}
+	}
+}
+
+// publish sends the next batch, n is how many events went out
+func (r *Relay) publish(ctx context.Context) (n int, err error) {
+	pending, err := r.store.PendingOutbox(ctx, r.cfg.BatchSize)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.ErrorContext(ctx, "read outbox failed", slog.String("error", err.Error()))
+		}
+		return 0, err
+	}
+	if len(pending) == 0 {
+		return 0, nil
+	}
+	ids := make([]int64, len(pending))
+	for i, e := range pending {
+		ids[i] = e.Id
+	}
+
+	publishCtx, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+	err = r.sink.Publish(publishCtx, pending)
+	cancel()
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, ctx.Err() // shutting down, the batch stays pending and goes out after the restart
+		}
+		r.metrics.Failed()
+		slog.WarnContext(ctx, "publish outbox events failed", slog.Int64("first", ids[0]), slog.Int("events", len(ids)),
+			slog.String("error", err.Error()))
+		if err := r.store.MarkOutboxFailed(ctx, ids, err.Error()); err != nil {
+			slog.ErrorContext(ctx, "save outbox failure failed", slog.String("error", err.Error()))
+		}
+		return 0, err
+	}
+	r.metrics.Published(len(pending))
+
+	// the sink has them, marking must happen even when shutdown started meanwhile or they are sent again
+	markCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := r.store.MarkOutboxDelivered(markCtx, ids, r.clock.Now()); err != nil {
+		slog.ErrorContext(ctx, "mark outbox events delivered failed, they will be published again", slog.String("error", err.Error()))
+		return 0, err
+	}
+	return len(pending), nil
+}
+
+func (r *Relay) updateBacklog(ctx context.Context) {
+	if ctx.Err() != nil {
+		return
+	}
+	pending, oldest, err := r.store.OutboxBacklog(ctx)
+	if err != nil {
+		return
+	}
+	lag := time.Duration(0)
+	if pending > 0 {
+		lag = max(r.clock.Now().Sub(oldest), 0)
+	}
+	r.metrics.Backlog(pending, lag)
+}
+
+// Wait blocks until Run returned after its ctx was cancelled, a shutdown hook so the db is not closed under a batch
+func (r *Relay) Wait(ctx context.Context) error {
+	if !r.running.Load() {
+		return nil
+	}
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		select {
+		case <-r.done: // both were ready
+			return nil
+		default:
+		}
+		return fmt.Errorf("outbox relay still running: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,305 @@
+package outbox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/events"
+	"github.com/manishtomar-cpi/go-server/internal/metrics"
+	"github.com/manishtomar-cpi/go-server/internal/outbox"
+	"github.com/manishtomar-cpi/go-server/internal/storage/sqlite"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fakeSink refuses the first failures batches and records the event ids of the batches it took
+type fakeSink struct {
+	mu       sync.Mutex
+	failures int
+	batches  [][]int64
+}
+
+func (f *fakeSink) Publish(ctx context.Context, events []types.OutboxEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("broker is down")
+	}
+	ids := make([]int64, len(events))
+	for i, e := range events {
+		ids[i] = e.Id
+	}
+	f.batches = append(f.batches, ids)
+	return nil
+}
+
+func (f *fakeSink) Close() error { return nil }
+
+func (f *fakeSink) published() (ids []int64, batches int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, batch := range f.batches {
+		ids = append(ids, batch...)
+	}
+	return ids, len(f.batches)
+}
+
+// newStore is a fresh database with the outbox on, as with outbox.sink set
+func newStore(t *testing.T) *sqlite.Sqlite {
+	t.Helper()
+	store, err := sqlite.New(&config.Config{Storage_path: filepath.Join(t.TempDir(), "test.db"), Outbox: config.Outbox{Sink: "http"}})
+	if err != nil {
+		t.Fatalf("sqlite: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// the outbox row is part of the student write, it is there exactly when the write committed
+func TestOutboxInWriteTransaction(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := newStore(t)
+	at := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	id, err := store.CreateStudent(ctx, "Asha", "asha@example.com", 20, at)
+	if err != nil {
+		t.Fatalf("create student: %v", err)
+	}
+	if _, err := store.CreateStudent(ctx, "Asha again", "ASHA@example.com", 21, at); err == nil {
+		t.Fatal("want the taken email refused")
+	}
+	if _, err := store.CreateStudents(ctx, []types.Student{
+		{Name: "Ravi", Email: "ravi@example.com", Age: 22, UpdatedAt: at},
+		{Name: "Mei", Email: "mei@example.com", Age: 23, UpdatedAt: at},
+	}); err != nil {
+		t.Fatalf("create students: %v", err)
+	}
+	if err := store.UpdateStudent(ctx, types.Student{Id: id, Name: "Asha K", Email: "asha@example.com", Age: 20, UpdatedAt: at}); err != nil {
+		t.Fatalf("update student: %v", err)
+	}
+	if err := store.UpdateStudent(ctx, types.Student{Id: 999, Name: "Nobody", Email: "nobody@example.com", UpdatedAt: at}); err == nil {
+		t.Fatal("want the missing student not updated")
+	}
+	if err := store.DeleteStudent(ctx, id, at); err != nil {
+		t.Fatalf("delete student: %v", err)
+	}
+
+	pending, err := store.PendingOutbox(ctx, 10)
+	if err != nil {
+		t.Fatalf("pending: %v", err)
+	}
+	var got []string
+	for _, e := range pending {
+		got = append(got, e.EventType)
+	}
+	want := []string{events.StudentCreatedType, events.StudentCreatedType, events.StudentCreatedType, events.StudentUpdatedType, events.StudentDeletedType}
+	if !slices.Equal(got, want) {
+		t.Fatalf("outbox has %v, want %v", got, want)
+	}
+	// the payload is the envelope the bus would have carried
+	e, err := events.Unmarshal([]byte(pending[3].Payload))
+	if updated, ok := e.(events.StudentUpdated); err != nil || !ok || updated.StudentId != id || updated.Name != "Asha K" {
+		t.Fatalf("updated event: %+v, %v", e, err)
+	}
+
+	// without a sink nothing would ever publish or purge them, so nothing is written
+	off, err := sqlite.New(&config.Config{Storage_path: filepath.Join(t.TempDir(), "off.db")})
+	if err != nil {
+		t.Fatalf("sqlite: %v", err)
+	}
+	t.Cleanup(func() { off.Close() })
+	if _, err := off.CreateStudent(ctx, "Asha", "asha@example.com", 20, at); err != nil {
+		t.Fatalf("create student: %v", err)
+	}
+	if pending, _ := off.PendingOutbox(ctx, 10); len(pending) != 0 {
+		t.Fatalf("outbox without a sink has %d events", len(pending))
+	}
+}
+
+func TestRelay(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name         string
+		failures     int
+		batchSize    int
+		wantBatches  int
+		wantAttempts int // on the outbox rows, failed publishes
+	}
+
+	tests := []testCase{
+		{name: "one_batch", batchSize: 10, wantBatches: 1},
+		{name: "small_batches", batchSize: 2, wantBatches: 3},
+		{name: "sink_down_for_a_while", failures: 2, batchSize: 10, wantBatches: 1, wantAttempts: 2},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := newStore(t)
+			clk := clock.NewFake(time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC))
+			bus := events.NewBus()
+			sink := &fakeSink{failures: tc.failures}
+			relay := outbox.NewRelay(store, sink, bus, config.Outbox{BatchSize: tc.batchSize, PollInterval: time.Hour, BaseDelay: time.Millisecond},
+				clk, metrics.NewOutbox(prometheus.NewRegistry()))
+
+			// written before the relay runs, it has to catch up in order
+			for i := 1; i <= 5; i++ {
+				if _, err := store.CreateStudent(context.Background(), "Asha", fmt.Sprintf("asha%d@example.com", i), 20, clk.Now()); err != nil {
+					t.Fatalf("create student: %v", err)
+				}
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			go relay.Run(ctx)
+			t.Cleanup(func() {
+				cancel()
+				relay.Wait(context.Background())
+			})
+
+			deadline := time.Now().Add(5 * time.Second)
+			for {
+				pending, _ := store.PendingOutbox(context.Background(), 10)
+				if len(pending) == 0 {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("events still pending: %+v", pending)
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+			ids, batches := sink.published()
+			if len(ids) != 5 || batches != tc.wantBatches {
+				t.Fatalf("published %v in %d batches, want 5 events in %d", ids, batches, tc.wantBatches)
+			}
+			for i, id := range ids {
+				if id != int64(i+1) {
+					t.Fatalf("published out of order: %v", ids)
+				}
+			}
+			var attempts int
+			store.Db.QueryRow("SELECT attempts FROM outbox WHERE id = 1").Scan(&attempts)
+			if attempts != tc.wantAttempts {
+				t.Fatalf("first event has %d failed attempts, want %d", attempts, tc.wantAttempts)
+			}
+		})
+	}
+}
+
+// an event published while the relay waits goes out without waiting for the next poll
+func TestRelayWakesUp(t *testing.T) {
+	t.Parallel()
+
+	store := newStore(t)
+	bus := events.NewBus()
+	sink := &fakeSink{}
+	relay := outbox.NewRelay(store, sink, bus, config.Outbox{PollInterval: time.Hour}, clock.System{}, metrics.NewOutbox(prometheus.NewRegistry()))
+	ctx, cancel := context.WithCancel(context.Background())
+	go relay.Run(ctx)
+	t.Cleanup(func() {
+		cancel()
+		relay.Wait(context.Background())
+	})
+
+	time.Sleep(20 * time.Millisecond) // the relay found nothing and waits for the poll an hour away
+	id, err := store.CreateStudent(context.Background(), "Asha", "asha@example.com", 20, time.Now())
+	if err != nil {
+		t.Fatalf("create student: %v", err)
+	}
+	// what the handler publishes after the write, it only wakes the relay up
+	e, _ := events.NewStudentCreated(types.Student{Id: id, Name: "Asha", Email: "asha@example.com", Age: 20}, time.Now())
+	bus.Publish(context.Background(), e)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if ids, _ := sink.published(); len(ids) == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("event was not published")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name    string
+		status  int
+		wantErr bool
+	}
+
+	tests := []testCase{
+		{name: "accepted", status: http.StatusAccepted},
+		{name: "refused", status: http.StatusServiceUnavailable, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got []outbox.Message
+			var auth string
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				auth = r.Header.Get("Authorization")
+				body, _ := io.ReadAll(r.Body)
+				json.Unmarshal(body, &got)
+				w.WriteHeader(tc.status)
+			}))
+			t.Cleanup(receiver.Close)
+
+			sink, err := outbox.New(config.Outbox{Sink: "http", URL: receiver.URL, Headers: map[string]string{"Authorization": "Bearer t"}}, nil)
+			if err != nil {
+				t.Fatalf("new sink: %v", err)
+			}
+			t.Cleanup(func() { sink.Close() })
+			at := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+			err = sink.Publish(context.Background(), []types.OutboxEvent{
+				{Id: 1, EventType: events.StudentDeletedType, Payload: `{"student_id":3}`, CreatedAt: at},
+				{Id: 2, EventType: events.StudentDeletedType, Payload: `{"student_id":4}`, CreatedAt: at},
+			})
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("publish error = %v, want error %v", err, tc.wantErr)
+			}
+			if len(got) != 2 || got[0].Id != 1 || got[1].Type != events.StudentDeletedType || string(got[1].Data) != `{"student_id":4}` ||
+				!got[0].CreatedAt.Equal(at) || auth != "Bearer t" {
+				t.Fatalf("receiver got %+v with authorization %q", got, auth)
+			}
+		})
+	}
+}
+
+func TestNewSink(t *testing.T) {
+	t.Parallel()
+
+	for _, cfg := range []config.Outbox{
+		{Sink: "sqs"},
+		{Sink: "http", URL: "not a url"},
+		{Sink: "kafka"}, // no brokers
+		{Sink: "nats"},  // messaging.nats is off
+		{Sink: "amqp", URL: "http://rabbit"},
+	} {
+		if _, err := outbox.New(cfg, nil); err == nil {
+			t.Errorf("outbox.New(%+v): want an error", cfg)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Store decides if one more request for key is allowed right now (token bucket).
+// when not allowed, retryAfter says how long until the next token is there
+type Store interface {
+	Allow(ctx context.Context, key string, now time.Time) (allowed bool, retryAfter time.Duration, err error)
+}
+
+// Limits of one bucket -> Rate tokens come back per second, a bucket holds at most Burst tokens
+type Limits struct {
+	Rate  float64
+	Burst int
+}
+
+// retryAfter is how long until the bucket has one full token again
+func (l Limits) retryAfter(tokens float64) time.Duration {
+	missing := 1 - tokens
+	return time.Duration(math.Ceil(missing / l.Rate * float64(time.Second)))
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// MemoryStore keeps the buckets in this process, fine for a single instance.
+// with several instances behind a load balancer every instance has its own buckets, use RedisStore there
+type MemoryStore struct {
+	limits  Limits
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+// buckets are only cleaned up once there are this many, so small deployments never pay for it
+const sweepAt = 10000
+
+func NewMemoryStore(limits Limits) *MemoryStore {
+	return &MemoryStore{limits: limits, buckets: map[string]*bucket{}}
+}
+
+func (m *MemoryStore) Allow(ctx context.Context, key string, now time.Time) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, ok := m.buckets[key]
+	if !ok {
+		if len(m.buckets) >= sweepAt {
+			m.sweep(now)
+		}
+		b = &bucket{tokens: float64(m.limits.Burst), last: now}
+		m.buckets[key] = b
+	}
+
+	// refill for the time since the last request, never above burst
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(m.limits.Burst), b.tokens+elapsed*m.limits.Rate)
+		b.last = now
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+	return false, m.limits.retryAfter(b.tokens), nil
+}
+
+// sweep drops buckets that would be full again by now, they carry no state worth keeping
+func (m *MemoryStore) sweep(now time.Time) {
+	full := time.Duration(float64(m.limits.Burst) / m.limits.Rate * float64(time.Second))
+	for key, b := range m.buckets {
+		if now.Sub(b.last) >= full {
+			delete(m.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/ratelimit"
+)
+
+func TestMemoryStoreTokenBucket(t *testing.T) {
+	t.Parallel()
+
+	store := ratelimit.NewMemoryStore(ratelimit.Limits{Rate: 2, Burst: 3}) // 2 per second, 3 at once
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// burst is allowed straight away
+	for i := 0; i < 3; i++ {
+		if ok, _, _ := store.Allow(ctx, "1.2.3.4", now); !ok {
+			t.Fatalf("request %d of the burst was rejected", i+1)
+		}
+	}
+
+	ok, retryAfter, err := store.Allow(ctx, "1.2.3.4", now)
+	if err != nil || ok {
+		t.Fatalf("4th request should be rejected, got ok=%v err=%v", ok, err)
+	}
+	if retryAfter != 500*time.Millisecond {
+		t.Fatalf("want retry after 500ms (one token at 2/s), got %v", retryAfter)
+	}
+
+	// other clients have their own bucket
+	if ok, _, _ := store.Allow(ctx, "5.6.7.8", now); !ok {
+		t.Fatal("other client should not be limited")
+	}
+
+	// half a second later one token is back
+	if ok, _, _ := store.Allow(ctx, "1.2.3.4", now.Add(500*time.Millisecond)); !ok {
+		t.Fatal("token should have refilled after 500ms")
+	}
+}
@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// same token bucket as MemoryStore, but done inside redis with a lua script so it is atomic
+// and shared by every instance of the server
+var tokenBucket = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1]) or burst
+local ts = tonumber(data[2]) or now
+if now > ts then
+  tokens = math.min(burst, tokens + (now - ts) / 1000 * rate)
+  ts = now
+end
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', ts)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+type RedisStore struct {
+	client *redis.Client
+	limits Limits
+	prefix string
+}
+
+func NewRedisStore(client *redis.Client, limits Limits) *RedisStore {
+	return &RedisStore{client: client, limits: limits, prefix: "ratelimit:"}
+}
+
+func (s *RedisStore) Allow(ctx context.Context, key string, now time.Time) (bool, time.Duration, error) {
+	res, err := tokenBucket.Run(ctx, s.client, []string{s.prefix + key},
+		s.limits.Rate, s.limits.Burst, now.UnixMilli()).Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	allowed, _ := res[0].(int64)
+	if allowed == 1 {
+		return true, 0, nil
+	}
+	var tokens float64
+	if str, ok := res[1].(string); ok {
+		tokens, _ = strconv.ParseFloat(str, 64)
+	}
+	return false, s.limits.retryAfter(tokens), nil
+}
@@ -0,0 +1,51 @@
+// Package redact masks personal data -> in api responses for callers that may not see it, and in every log line
+package redact
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+// Email keeps the first letter and the domain -> "a***@example.com", enough to tell two addresses apart in support
+func Email(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || local == "" {
+		return "***"
+	}
+	return local[:1] + "***@" + domain
+}
+
+// Text masks every email address inside free text, like error messages or urls
+func Text(s string) string {
+	if !strings.Contains(s, "@") { // cheap check first, almost no log line has one
+		return s
+	}
+	return emailPattern.ReplaceAllStringFunc(s, Email)
+}
+
+// Student returns the student with personal fields masked. add new personal fields (phone, address...) here too
+func Student(s types.Student) types.Student {
+	s.Email = Email(s.Email)
+	return s
+}
+
+// keys whose values never belong in a log, whatever they look like
+var secretKeys = map[string]bool{"password": true, "token": true, "access_token": true, "refresh_token": true, "authorization": true, "api_key": true}
+
+// LogAttr is a slog ReplaceAttr func -> secret keys are dropped to "[redacted]", emails in any string value are masked
+func LogAttr(groups []string, a slog.Attr) slog.Attr {
+	if secretKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, "[redacted]")
+	}
+	if a.Value.Kind() == slog.KindString {
+		if masked := Text(a.Value.String()); masked != a.Value.String() {
+			return slog.String(a.Key, masked)
+		}
+	}
+	return a
+}
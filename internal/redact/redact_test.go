@@ -0,0 +1,56 @@
+package redact_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/redact"
+)
+
+func TestText(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name string
+		in   string
+		want string
+	}
+
+	tests := []testCase{
+		{name: "no_email", in: "student 5 not found", want: "student 5 not found"},
+		{name: "email_in_message", in: "duplicate asha@example.com", want: "duplicate a***@example.com"},
+		{name: "email_in_query", in: "/api/students?email=ravi.k@uni.edu&x=1", want: "/api/students?email=r***@uni.edu&x=1"},
+		{name: "at_without_email", in: "meet @ noon", want: "meet @ noon"},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := redact.Text(tc.in); got != tc.want {
+				t.Fatalf("want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLogAttr(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{ReplaceAttr: redact.LogAttr}))
+	logger.Info("created asha@example.com", slog.String("password", "hunter2"), slog.String("error", "email ravi@example.com taken"))
+
+	out := buf.String()
+	for _, leaked := range []string{"asha@example.com", "ravi@example.com", "hunter2"} {
+		if strings.Contains(out, leaked) {
+			t.Fatalf("log line leaks %q: %s", leaked, out)
+		}
+	}
+	if !strings.Contains(out, "a***@example.com") || !strings.Contains(out, `"password":"[redacted]"`) {
+		t.Fatalf("want masked values in log line, got %s", out)
+	}
+}
@@ -0,0 +1,24 @@
+package rpc
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/manishtomar-cpi/go-server/internal/rpc/studentpb"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Gateway is the rest/json version of the StudentService, routes come from the google.api.http options in student.proto.
+// it calls students in process (no grpc listener needed), so it runs behind the http middlewares and the principal
+// they put in the request context is the one the service checks
+func Gateway(ctx context.Context, students studentpb.StudentServiceServer) (http.Handler, error) {
+	mux := runtime.NewServeMux(runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+		MarshalOptions:   protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}, // same field names as the proto, zeros included
+		UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+	}))
+	if err := studentpb.RegisterStudentServiceHandlerServer(ctx, mux, students); err != nil {
+		return nil, err
+	}
+	return mux, nil
+}
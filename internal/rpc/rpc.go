@@ -0,0 +1,86 @@
+// Package rpc serves the student api over grpc for internal services, next to the http api and on the same storage
+package rpc
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// NewServer builds the grpc server with recover and authentication on every call, services are registered by the caller
+func NewServer(cfg config.GRPCServer, authn auth.Authenticator) (*grpc.Server, error) {
+	opts := []grpc.ServerOption{grpc.ChainUnaryInterceptor(recoverer, authenticate(authn))}
+	if cfg.TLS.Enabled() {
+		creds, err := credentials.NewServerTLSFromFile(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	return grpc.NewServer(opts...), nil
+}
+
+// recoverer turns a panic into codes.Internal, like middleware.Recover does for http
+func recoverer(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			logging.FromContext(ctx).ErrorContext(ctx, "grpc handler panicked", slog.String("method", info.FullMethod),
+				slog.Any("panic", p), slog.String("stack", string(debug.Stack())))
+			err = status.Error(codes.Internal, "internal error")
+		}
+	}()
+	return handler(ctx, req)
+}
+
+// authenticate runs the same authenticators as the http api. grpc metadata is turned into http headers,
+// so "authorization: Bearer <jwt>" and "x-api-key: <key>" work the way they do over http
+func authenticate(authn auth.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		r, err := http.NewRequestWithContext(ctx, http.MethodPost, info.FullMethod, nil)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "internal error")
+		}
+		for key, values := range md {
+			for _, v := range values {
+				r.Header.Add(key, v)
+			}
+		}
+
+		p, err := authn.Authenticate(r)
+		switch {
+		case errors.Is(err, auth.ErrNoCredentials): // anonymous, the method decides if that is fine
+			return handler(ctx, req)
+		case err != nil:
+			return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+		}
+		return handler(auth.WithPrincipal(ctx, p), req)
+	}
+}
+
+// authorize is the grpc version of middleware.Require -> anonymous gets Unauthenticated, not allowed gets PermissionDenied
+func authorize(ctx context.Context, allowed func(p *auth.Principal) bool) error {
+	p, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "authentication required")
+	}
+	if !allowed(p) {
+		return status.Error(codes.PermissionDenied, "permission denied")
+	}
+	return nil
+}
+
+func require(ctx context.Context, perm auth.Permission) error {
+	return authorize(ctx, func(p *auth.Principal) bool { return p.Can(perm) })
+}
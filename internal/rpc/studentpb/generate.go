@@ -0,0 +1,12 @@
+// Package studentpb is the generated code of student.proto (grpc server, rest/json gateway and the openapi document),
+// regenerate it with go generate after editing the proto
+package studentpb
+
+import _ "embed"
+
+//go:generate buf generate
+
+// OpenAPI is the swagger 2.0 document of the gateway routes, feed it to an sdk generator for a client
+//
+//go:embed student.swagger.json
+var OpenAPI []byte
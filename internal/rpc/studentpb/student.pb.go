@@ -0,0 +1,547 @@
+// StudentService is the grpc version of the /api/v1/students routes, for internal services that prefer grpc.
+// same storage, same auth (bearer token or x-api-key in the metadata) and same permissions as the http api.
+// the google.api.http options are the rest/json gateway of the same service under /api/rpc/v1, generated from this file too
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: student.proto
+
+package studentpb
+
+import (
+	_ "google.golang.org/genproto/googleapis/api/annotations"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Student struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Email         string                 `protobuf:"bytes,3,opt,name=email,proto3" json:"email,omitempty"`
+	Age           int32                  `protobuf:"varint,4,opt,name=age,proto3" json:"age,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Student) Reset() {
+	*x = Student{}
+	mi := &file_student_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Student) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Student) ProtoMessage() {}
+
+func (x *Student) ProtoReflect() protoreflect.Message {
+	mi := &file_student_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Student.ProtoReflect.Descriptor instead.
+func (*Student) Descriptor() ([]byte, []int) {
+	return file_student_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Student) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Student) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Student) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+func (x *Student) GetAge() int32 {
+	if x != nil {
+		return x.Age
+	}
+	return 0
+}
+
+type CreateStudentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Email         string                 `protobuf:"bytes,2,opt,name=email,proto3" json:"email,omitempty"`
+	Age           int32                  `protobuf:"varint,3,opt,name=age,proto3" json:"age,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateStudentRequest) Reset() {
+	*x = CreateStudentRequest{}
+	mi := &file_student_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateStudentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateStudentRequest) ProtoMessage() {}
+
+func (x *CreateStudentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_student_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateStudentRequest.ProtoReflect.Descriptor instead.
+func (*CreateStudentRequest) Descriptor() ([]byte, []int) {
+	return file_student_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CreateStudentRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CreateStudentRequest) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+func (x *CreateStudentRequest) GetAge() int32 {
+	if x != nil {
+		return x.Age
+	}
+	return 0
+}
+
+type GetStudentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStudentRequest) Reset() {
+	*x = GetStudentRequest{}
+	mi := &file_student_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStudentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStudentRequest) ProtoMessage() {}
+
+func (x *GetStudentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_student_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStudentRequest.ProtoReflect.Descriptor instead.
+func (*GetStudentRequest) Descriptor() ([]byte, []int) {
+	return file_student_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetStudentRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type ListStudentsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Limit         int32                  `protobuf:"varint,1,opt,name=limit,proto3" json:"limit,omitempty"` // 1 to 500, 0 means 50
+	Offset        int32                  `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListStudentsRequest) Reset() {
+	*x = ListStudentsRequest{}
+	mi := &file_student_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListStudentsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListStudentsRequest) ProtoMessage() {}
+
+func (x *ListStudentsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_student_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListStudentsRequest.ProtoReflect.Descriptor instead.
+func (*ListStudentsRequest) Descriptor() ([]byte, []int) {
+	return file_student_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ListStudentsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *ListStudentsRequest) GetOffset() int32 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+type ListStudentsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Students      []*Student             `protobuf:"bytes,1,rep,name=students,proto3" json:"students,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListStudentsResponse) Reset() {
+	*x = ListStudentsResponse{}
+	mi := &file_student_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListStudentsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListStudentsResponse) ProtoMessage() {}
+
+func (x *ListStudentsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_student_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListStudentsResponse.ProtoReflect.Descriptor instead.
+func (*ListStudentsResponse) Descriptor() ([]byte, []int) {
+	return file_student_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListStudentsResponse) GetStudents() []*Student {
+	if x != nil {
+		return x.Students
+	}
+	return nil
+}
+
+type UpdateStudentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Email         string                 `protobuf:"bytes,3,opt,name=email,proto3" json:"email,omitempty"`
+	Age           int32                  `protobuf:"varint,4,opt,name=age,proto3" json:"age,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateStudentRequest) Reset() {
+	*x = UpdateStudentRequest{}
+	mi := &file_student_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateStudentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateStudentRequest) ProtoMessage() {}
+
+func (x *UpdateStudentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_student_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateStudentRequest.ProtoReflect.Descriptor instead.
+func (*UpdateStudentRequest) Descriptor() ([]byte, []int) {
+	return file_student_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *UpdateStudentRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *UpdateStudentRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *UpdateStudentRequest) GetEmail() string {
+	if x != nil {
+		return x.Email
+	}
+	return ""
+}
+
+func (x *UpdateStudentRequest) GetAge() int32 {
+	if x != nil {
+		return x.Age
+	}
+	return 0
+}
+
+type DeleteStudentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteStudentRequest) Reset() {
+	*x = DeleteStudentRequest{}
+	mi := &file_student_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteStudentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteStudentRequest) ProtoMessage() {}
+
+func (x *DeleteStudentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_student_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteStudentRequest.ProtoReflect.Descriptor instead.
+func (*DeleteStudentRequest) Descriptor() ([]byte, []int) {
+	return file_student_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *DeleteStudentRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type DeleteStudentResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteStudentResponse) Reset() {
+	*x = DeleteStudentResponse{}
+	mi := &file_student_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteStudentResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteStudentResponse) ProtoMessage() {}
+
+func (x *DeleteStudentResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_student_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteStudentResponse.ProtoReflect.Descriptor instead.
+func (*DeleteStudentResponse) Descriptor() ([]byte, []int) {
+	return file_student_proto_rawDescGZIP(), []int{7}
+}
+
+var File_student_proto protoreflect.FileDescriptor
+
+const file_student_proto_rawDesc = "" +
+	"\n" +
+	"\rstudent.proto\x12\n" +
+	"student.v1\x1a\x1cgoogle/api/annotations.proto\"U\n" +
+	"\aStudent\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x14\n" +
+	"\x05email\x18\x03 \x01(\tR\x05email\x12\x10\n" +
+	"\x03age\x18\x04 \x01(\x05R\x03age\"R\n" +
+	"\x14CreateStudentRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05email\x18\x02 \x01(\tR\x05email\x12\x10\n" +
+	"\x03age\x18\x03 \x01(\x05R\x03age\"#\n" +
+	"\x11GetStudentRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\"C\n" +
+	"\x13ListStudentsRequest\x12\x14\n" +
+	"\x05limit\x18\x01 \x01(\x05R\x05limit\x12\x16\n" +
+	"\x06offset\x18\x02 \x01(\x05R\x06offset\"G\n" +
+	"\x14ListStudentsResponse\x12/\n" +
+	"\bstudents\x18\x01 \x03(\v2\x13.student.v1.StudentR\bstudents\"b\n" +
+	"\x14UpdateStudentRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x14\n" +
+	"\x05email\x18\x03 \x01(\tR\x05email\x12\x10\n" +
+	"\x03age\x18\x04 \x01(\x05R\x03age\"&\n" +
+	"\x14DeleteStudentRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\"\x17\n" +
+	"\x15DeleteStudentResponse2\xb6\x04\n" +
+	"\x0eStudentService\x12g\n" +
+	"\rCreateStudent\x12 .student.v1.CreateStudentRequest\x1a\x13.student.v1.Student\"\x1f\x82\xd3\xe4\x93\x02\x19:\x01*\"\x14/api/rpc/v1/students\x12c\n" +
+	"\n" +
+	"GetStudent\x12\x1d.student.v1.GetStudentRequest\x1a\x13.student.v1.Student\"!\x82\xd3\xe4\x93\x02\x1b\x12\x19/api/rpc/v1/students/{id}\x12o\n" +
+	"\fListStudents\x12\x1f.student.v1.ListStudentsRequest\x1a .student.v1.ListStudentsResponse\"\x1c\x82\xd3\xe4\x93\x02\x16\x12\x14/api/rpc/v1/students\x12l\n" +
+	"\rUpdateStudent\x12 .student.v1.UpdateStudentRequest\x1a\x13.student.v1.Student\"$\x82\xd3\xe4\x93\x02\x1e:\x01*\x1a\x19/api/rpc/v1/students/{id}\x12w\n" +
+	"\rDeleteStudent\x12 .student.v1.DeleteStudentRequest\x1a!.student.v1.DeleteStudentResponse\"!\x82\xd3\xe4\x93\x02\x1b*\x19/api/rpc/v1/students/{id}B=Z;github.com/manishtomar-cpi/go-server/internal/rpc/studentpbb\x06proto3"
+
+var (
+	file_student_proto_rawDescOnce sync.Once
+	file_student_proto_rawDescData []byte
+)
+
+func file_student_proto_rawDescGZIP() []byte {
+	file_student_proto_rawDescOnce.Do(func() {
+		file_student_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_student_proto_rawDesc), len(file_student_proto_rawDesc)))
+	})
+	return file_student_proto_rawDescData
+}
+
+var file_student_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_student_proto_goTypes = []any{
+	(*Student)(nil),               // 0: student.v1.Student
+	(*CreateStudentRequest)(nil),  // 1: student.v1.CreateStudentRequest
+	(*GetStudentRequest)(nil),     // 2: student.v1.GetStudentRequest
+	(*ListStudentsRequest)(nil),   // 3: student.v1.ListStudentsRequest
+	(*ListStudentsResponse)(nil),  // 4: student.v1.ListStudentsResponse
+	(*UpdateStudentRequest)(nil),  // 5: student.v1.UpdateStudentRequest
+	(*DeleteStudentRequest)(nil),  // 6: student.v1.DeleteStudentRequest
+	(*DeleteStudentResponse)(nil), // 7: student.v1.DeleteStudentResponse
+}
+var file_student_proto_depIdxs = []int32{
+	0, // 0: student.v1.ListStudentsResponse.students:type_name -> student.v1.Student
+	1, // 1: student.v1.StudentService.CreateStudent:input_type -> student.v1.CreateStudentRequest
+	2, // 2: student.v1.StudentService.GetStudent:input_type -> student.v1.GetStudentRequest
+	3, // 3: student.v1.StudentService.ListStudents:input_type -> student.v1.ListStudentsRequest
+	5, // 4: student.v1.StudentService.UpdateStudent:input_type -> student.v1.UpdateStudentRequest
+	6, // 5: student.v1.StudentService.DeleteStudent:input_type -> student.v1.DeleteStudentRequest
+	0, // 6: student.v1.StudentService.CreateStudent:output_type -> student.v1.Student
+	0, // 7: student.v1.StudentService.GetStudent:output_type -> student.v1.Student
+	4, // 8: student.v1.StudentService.ListStudents:output_type -> student.v1.ListStudentsResponse
+	0, // 9: student.v1.StudentService.UpdateStudent:output_type -> student.v1.Student
+	7, // 10: student.v1.StudentService.DeleteStudent:output_type -> student.v1.DeleteStudentResponse
+	6, // [6:11] is the sub-list for method output_type
+	1, // [1:6] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_student_proto_init() }
+func file_student_proto_init() {
+	if File_student_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_student_proto_rawDesc), len(file_student_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_student_proto_goTypes,
+		DependencyIndexes: file_student_proto_depIdxs,
+		MessageInfos:      file_student_proto_msgTypes,
+	}.Build()
+	File_student_proto = out.File
+	file_student_proto_goTypes = nil
+	file_student_proto_depIdxs = nil
+}
@@ -0,0 +1,165 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/events"
+	"github.com/manishtomar-cpi/go-server/internal/http/dto"
+	"github.com/manishtomar-cpi/go-server/internal/logging"
+	"github.com/manishtomar-cpi/go-server/internal/rpc/studentpb"
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+	"github.com/manishtomar-cpi/go-server/internal/validation"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Students is the StudentService, it checks the same permissions as the /api/v1/students routes
+type Students struct {
+	studentpb.UnimplementedStudentServiceServer
+
+	store storage.Storage
+	bus   *events.Bus
+	clock clock.Clock
+}
+
+func NewStudents(store storage.Storage, bus *events.Bus, clk clock.Clock) *Students {
+	return &Students{store: store, bus: bus, clock: clk}
+}
+
+func (s *Students) CreateStudent(ctx context.Context, req *studentpb.CreateStudentRequest) (*studentpb.Student, error) {
+	if err := require(ctx, auth.WriteStudents); err != nil {
+		return nil, err
+	}
+	body := dto.CreateStudentRequest{Name: req.GetName(), Email: req.GetEmail(), Age: int(req.GetAge())}
+	if err := validate(body); err != nil {
+		return nil, err
+	}
+	student := body.Student()
+	student.UpdatedAt = s.clock.Now()
+	id, err := s.store.CreateStudent(ctx, student.Name, student.Email, student.Age, student.UpdatedAt)
+	if err != nil {
+		return nil, storageError(ctx, "create student failed", err)
+	}
+	student.Id = id
+	if event, err := events.NewStudentCreated(student, student.UpdatedAt); err == nil {
+		s.bus.Publish(ctx, event)
+	}
+	return toProto(student), nil
+}
+
+func (s *Students) GetStudent(ctx context.Context, req *studentpb.GetStudentRequest) (*studentpb.Student, error) {
+	err := authorize(ctx, func(p *auth.Principal) bool {
+		return p.Can(auth.ReadStudents) || (p.StudentID != 0 && p.StudentID == req.GetId() && p.Can(auth.ReadOwnStudent))
+	})
+	if err != nil {
+		return nil, err
+	}
+	student, err := s.store.GetStudentById(ctx, req.GetId())
+	if err != nil {
+		return nil, storageError(ctx, "get student failed", err)
+	}
+	return toProto(shape(ctx, student)), nil
+}
+
+// ListStudents returns one page, limit 0 means 50 like the http api
+func (s *Students) ListStudents(ctx context.Context, req *studentpb.ListStudentsRequest) (*studentpb.ListStudentsResponse, error) {
+	if err := require(ctx, auth.ReadStudents); err != nil {
+		return nil, err
+	}
+	limit := int(req.GetLimit())
+	if limit == 0 {
+		limit = 50
+	}
+	if limit < 1 || limit > 500 {
+		return nil, status.Error(codes.InvalidArgument, "limit must be between 1 and 500")
+	}
+	if req.GetOffset() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "offset must be a non negative number")
+	}
+
+	students, err := s.store.ListStudents(ctx, storage.StudentQuery{Limit: limit, Offset: int(req.GetOffset())})
+	if err != nil {
+		return nil, internal(ctx, "list students failed", err)
+	}
+	res := &studentpb.ListStudentsResponse{Students: make([]*studentpb.Student, 0, len(students))}
+	for _, student := range students {
+		res.Students = append(res.Students, toProto(shape(ctx, student)))
+	}
+	return res, nil
+}
+
+func (s *Students) UpdateStudent(ctx context.Context, req *studentpb.UpdateStudentRequest) (*studentpb.Student, error) {
+	if err := require(ctx, auth.WriteStudents); err != nil {
+		return nil, err
+	}
+	body := dto.CreateStudentRequest{Name: req.GetName(), Email: req.GetEmail(), Age: int(req.GetAge())}
+	if err := validate(body); err != nil {
+		return nil, err
+	}
+	student := body.Student()
+	student.Id = req.GetId()
+	student.UpdatedAt = s.clock.Now()
+	if err := s.store.UpdateStudent(ctx, student); err != nil {
+		return nil, storageError(ctx, "update student failed", err)
+	}
+	if event, err := events.NewStudentUpdated(student, student.UpdatedAt); err == nil {
+		s.bus.Publish(ctx, event)
+	}
+	return toProto(student), nil
+}
+
+func (s *Students) DeleteStudent(ctx context.Context, req *studentpb.DeleteStudentRequest) (*studentpb.DeleteStudentResponse, error) {
+	if err := require(ctx, auth.DeleteStudents); err != nil {
+		return nil, err
+	}
+	now := s.clock.Now()
+	if err := s.store.DeleteStudent(ctx, req.GetId(), now); err != nil {
+		return nil, storageError(ctx, "delete student failed", err)
+	}
+	if event, err := events.NewStudentDeleted(req.GetId(), now); err == nil {
+		s.bus.Publish(ctx, event)
+	}
+	return &studentpb.DeleteStudentResponse{}, nil
+}
+
+// validate uses the struct tags of the http request body, so grpc and http accept the same students
+func validate(body dto.CreateStudentRequest) error {
+	if err := validation.Struct(body); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return nil
+}
+
+// storageError is the grpc side of the http storeerr mapping, the storage errors keep their text and everything else is internal
+func storageError(ctx context.Context, msg string, err error) error {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, storage.ErrDuplicate):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, storage.ErrConflict):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return internal(ctx, msg, err)
+}
+
+// internal logs the real error and hides it from the caller
+func internal(ctx context.Context, msg string, err error) error {
+	logging.FromContext(ctx).ErrorContext(ctx, msg, slog.String("error", err.Error()))
+	return status.Error(codes.Internal, "internal error")
+}
+
+// shape is student as the caller may see it, same as the http handlers
+func shape(ctx context.Context, student types.Student) types.Student {
+	p, _ := auth.PrincipalFrom(ctx)
+	return auth.RedactStudent(p, student)
+}
+
+func toProto(student types.Student) *studentpb.Student {
+	return &studentpb.Student{Id: student.Id, Name: student.Name, Email: student.Email, Age: int32(student.Age)}
+}
@@ -0,0 +1,190 @@
+// Package scheduler runs periodic tasks (cleanups and the like) on cron schedules. a task never runs twice at the same
+// time -> when it is due while the last run is still going, that turn is skipped and counted
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/metrics"
+	"github.com/robfig/cron/v3"
+)
+
+// Task is one periodic piece of work. Schedule is a standard 5 field cron line ("30 3 * * *") or a descriptor like
+// "@daily" or "@every 1h", in the time zone of the server. Timeout <= 0 lets a run take as long as it needs
+type Task struct {
+	Name     string
+	Schedule string
+	Timeout  time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Status is what the admin endpoint shows of one task
+type Status struct {
+	Name         string     `json:"name"`
+	Schedule     string     `json:"schedule"`
+	Running      bool       `json:"running"`
+	NextRun      time.Time  `json:"next_run"`
+	LastStart    *time.Time `json:"last_start,omitempty"`
+	LastDuration string     `json:"last_duration,omitempty"`
+	LastError    string     `json:"last_error,omitempty"` // of the last run, empty when it succeeded
+	Runs         int        `json:"runs"`
+	Failures     int        `json:"failures"`
+	Skipped      int        `json:"skipped"` // turns missed because the run before was still going
+}
+
+type task struct {
+	Task
+	schedule cron.Schedule
+	status   Status // guarded by Scheduler.mu
+}
+
+// Scheduler starts every task when its schedule says so
+type Scheduler struct {
+	clock   clock.Clock
+	metrics *metrics.Scheduler
+	tick    time.Duration
+
+	mu      sync.Mutex
+	tasks   []*task
+	stopped bool           // set by Wait, nothing starts after it
+	running sync.WaitGroup // one per run in progress
+}
+
+// New checks the schedule every tick, a minute is what cron lines can express, a second also catches "@every 10s"
+func New(clk clock.Clock, m *metrics.Scheduler) *Scheduler {
+	return &Scheduler{clock: clk, metrics: m, tick: time.Second}
+}
+
+// Add registers a task, its first run is the next time its schedule matches
+func (s *Scheduler) Add(t Task) error {
+	schedule, err := cron.ParseStandard(t.Schedule)
+	if err != nil {
+		return fmt.Errorf("task %s: schedule %q: %w", t.Name, t.Schedule, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks = append(s.tasks, &task{
+		Task:     t,
+		schedule: schedule,
+		status:   Status{Name: t.Name, Schedule: t.Schedule, NextRun: schedule.Next(s.clock.Now())},
+	})
+	return nil
+}
+
+// Run starts due tasks until ctx is cancelled, the runs in progress see ctx cancelled too
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.RunDue(ctx)
+		}
+	}
+}
+
+// RunDue starts every task whose next run has come, Run calls it every tick
+func (s *Scheduler) RunDue(ctx context.Context) {
+	now := s.clock.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return
+	}
+	for _, t := range s.tasks {
+		if now.Before(t.status.NextRun) {
+			continue
+		}
+		// the next run counts from now, a server that was stopped for a day runs a daily task once and not for every missed day
+		t.status.NextRun = t.schedule.Next(now)
+		if t.status.Running {
+			t.status.Skipped++
+			s.metrics.Skipped(t.Name)
+			slog.Warn("scheduled task still running, skipped this turn", slog.String("task", t.Name))
+			continue
+		}
+		t.status.Running = true
+		start := now
+		t.status.LastStart = &start
+		s.running.Add(1)
+		go s.run(ctx, t, start)
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, t *task, start time.Time) {
+	defer s.running.Done()
+	if t.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.Timeout)
+		defer cancel()
+	}
+	err := call(ctx, t.Run)
+	took := s.clock.Now().Sub(start)
+	s.metrics.Finished(t.Name, took, err)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t.status.Running = false
+	t.status.Runs++
+	t.status.LastDuration = took.String()
+	t.status.LastError = ""
+	if err != nil {
+		t.status.Failures++
+		t.status.LastError = err.Error()
+		slog.Error("scheduled task failed", slog.String("task", t.Name), slog.Duration("took", took), slog.String("error", err.Error()))
+		return
+	}
+	slog.Info("scheduled task done", slog.String("task", t.Name), slog.Duration("took", took))
+}
+
+// call runs fn, a panic fails the run instead of the whole server
+func call(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("task panicked: %v", v)
+		}
+	}()
+	return fn(ctx)
+}
+
+// Statuses of every task, in the order they were added
+func (s *Scheduler) Statuses() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Status, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		out = append(out, t.status)
+	}
+	return out
+}
+
+// Wait blocks until the runs in progress returned or ctx ends, a shutdown hook so the db is not closed under a task
+func (s *Scheduler) Wait(ctx context.Context) error {
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		select {
+		case <-done: // both were ready, a late hook with nothing running is not an error
+			return nil
+		default:
+		}
+		return fmt.Errorf("scheduled tasks still running: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,213 @@
+package scheduler_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/metrics"
+	"github.com/manishtomar-cpi/go-server/internal/scheduler"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func newScheduler(t *testing.T, clk clock.Clock) *scheduler.Scheduler {
+	t.Helper()
+	s := scheduler.New(clk, metrics.NewScheduler(prometheus.NewRegistry()))
+	t.Cleanup(func() { s.Wait(context.Background()) })
+	return s
+}
+
+// waitIdle waits until no task of s is running anymore
+func waitIdle(t *testing.T, s *scheduler.Scheduler) []scheduler.Status {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		statuses := s.Statuses()
+		running := false
+		for _, st := range statuses {
+			running = running || st.Running
+		}
+		if !running {
+			return statuses
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("tasks did not finish")
+	return nil
+}
+
+func TestScheduler(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name         string
+		run          func(ctx context.Context) error
+		wantRuns     int
+		wantFailures int
+		wantError    string // part of the last error
+	}
+
+	tests := []testCase{
+		{name: "ok", run: func(context.Context) error { return nil }, wantRuns: 2},
+		{
+			name:         "error",
+			run:          func(context.Context) error { return errors.New("db is locked") },
+			wantRuns:     2,
+			wantFailures: 2,
+			wantError:    "db is locked",
+		},
+		{
+			name:         "panic",
+			run:          func(context.Context) error { panic("boom") },
+			wantRuns:     2,
+			wantFailures: 2,
+			wantError:    "panicked: boom",
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			clk := clock.NewFake(time.Date(2025, 3, 1, 10, 0, 30, 0, time.UTC))
+			s := newScheduler(t, clk)
+			if err := s.Add(scheduler.Task{Name: "task", Schedule: "@hourly", Run: tc.run}); err != nil {
+				t.Fatalf("add: %v", err)
+			}
+			if next := s.Statuses()[0].NextRun; !next.Equal(time.Date(2025, 3, 1, 11, 0, 0, 0, time.UTC)) {
+				t.Fatalf("next run = %v, want 11:00", next)
+			}
+
+			s.RunDue(context.Background()) // not due yet
+			if st := waitIdle(t, s)[0]; st.Runs != 0 || st.LastStart != nil {
+				t.Fatalf("ran before it was due: %+v", st)
+			}
+
+			// a server that was stopped for hours runs the task once, not for every missed turn
+			clk.Advance(3 * time.Hour)
+			s.RunDue(context.Background())
+			waitIdle(t, s)
+			s.RunDue(context.Background())
+			waitIdle(t, s)
+			clk.Advance(time.Hour)
+			s.RunDue(context.Background())
+
+			st := waitIdle(t, s)[0]
+			if st.Runs != tc.wantRuns || st.Failures != tc.wantFailures {
+				t.Fatalf("runs %d failures %d, want %d and %d", st.Runs, st.Failures, tc.wantRuns, tc.wantFailures)
+			}
+			if !strings.Contains(st.LastError, tc.wantError) || (tc.wantError == "") != (st.LastError == "") {
+				t.Fatalf("last error = %q, want %q", st.LastError, tc.wantError)
+			}
+			if !st.NextRun.Equal(time.Date(2025, 3, 1, 15, 0, 0, 0, time.UTC)) {
+				t.Fatalf("next run = %v, want 15:00", st.NextRun)
+			}
+		})
+	}
+}
+
+// a turn that comes while the run before is still going is skipped
+func TestSchedulerSkipsOverlap(t *testing.T) {
+	t.Parallel()
+
+	clk := clock.NewFake(time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC))
+	s := newScheduler(t, clk)
+	release := make(chan struct{})
+	started := make(chan struct{}, 10)
+	err := s.Add(scheduler.Task{Name: "slow", Schedule: "@every 1m", Run: func(context.Context) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}})
+	if err != nil {
+		t.Fatalf("add: %v", err)
+	}
+
+	clk.Advance(time.Minute)
+	s.RunDue(context.Background())
+	<-started
+	clk.Advance(time.Minute)
+	s.RunDue(context.Background())
+	if st := s.Statuses()[0]; !st.Running || st.Skipped != 1 {
+		t.Fatalf("status while running = %+v, want running with one skipped", st)
+	}
+	close(release)
+
+	st := waitIdle(t, s)[0]
+	if st.Runs != 1 || st.Skipped != 1 || len(started) != 0 {
+		t.Fatalf("status = %+v, want one run and one skipped", st)
+	}
+}
+
+func TestSchedulerTimeout(t *testing.T) {
+	t.Parallel()
+
+	clk := clock.NewFake(time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC))
+	s := newScheduler(t, clk)
+	err := s.Add(scheduler.Task{Name: "stuck", Schedule: "* * * * *", Timeout: 10 * time.Millisecond, Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	if err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	clk.Advance(time.Minute)
+	s.RunDue(context.Background())
+	if st := waitIdle(t, s)[0]; st.Failures != 1 || !strings.Contains(st.LastError, "deadline") {
+		t.Fatalf("status = %+v, want a failed run that hit its timeout", st)
+	}
+}
+
+func TestSchedulerBadSchedule(t *testing.T) {
+	t.Parallel()
+
+	s := newScheduler(t, clock.System{})
+	for _, schedule := range []string{"", "every day", "61 * * * *", "@weekdays"} {
+		if err := s.Add(scheduler.Task{Name: "bad", Schedule: schedule, Run: func(context.Context) error { return nil }}); err == nil {
+			t.Errorf("schedule %q: want an error", schedule)
+		}
+	}
+	if n := len(s.Statuses()); n != 0 {
+		t.Fatalf("%d tasks added with a bad schedule", n)
+	}
+}
+
+// nothing starts once Wait was called, it waits for the run in progress
+func TestSchedulerWait(t *testing.T) {
+	t.Parallel()
+
+	clk := clock.NewFake(time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC))
+	s := newScheduler(t, clk)
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{}, 10)
+	err := s.Add(scheduler.Task{Name: "task", Schedule: "@every 1m", Run: func(ctx context.Context) error {
+		started <- struct{}{}
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	if err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	clk.Advance(time.Minute)
+	s.RunDue(ctx)
+	<-started
+
+	waitCtx, stop := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer stop()
+	if err := s.Wait(waitCtx); err == nil {
+		t.Fatal("wait returned while the task was running")
+	}
+	cancel() // what Run's ctx does at shutdown
+	if err := s.Wait(context.Background()); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	clk.Advance(time.Minute)
+	s.RunDue(context.Background())
+	if st := s.Statuses()[0]; st.Runs != 1 || st.Running || len(started) != 0 {
+		t.Fatalf("status = %+v, want nothing started after Wait", st)
+	}
+}
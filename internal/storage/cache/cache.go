@@ -0,0 +1,128 @@
+// Package cache keeps students in memory in front of a storage.Storage, for a single instance that does not want to
+// run redis. only reads by id are cached, lists and exports always go to the db
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/metrics"
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+// Students is an LRU of the students read by id, at most size of them for at most ttl each. UpdateStudent and
+// DeleteStudent drop the student, everything else goes straight to the wrapped storage.
+// a write done by another instance is not seen here until the ttl passes
+type Students struct {
+	storage.Storage
+	size    int
+	ttl     time.Duration
+	clock   clock.Clock
+	metrics *metrics.Cache
+
+	mu      sync.Mutex
+	order   *list.List              // of *entry, the front is the most recently read
+	entries map[int64]*list.Element // by student id
+	writes  uint64                  // counts the writes, a read that started before one does not keep what it read
+}
+
+type entry struct {
+	student types.Student
+	expires time.Time
+}
+
+func New(next storage.Storage, cfg config.StudentCache, clk clock.Clock, m *metrics.Cache) *Students {
+	return &Students{
+		Storage: next,
+		size:    max(cfg.Size, 1),
+		ttl:     cfg.TTL,
+		clock:   clk,
+		metrics: m,
+		order:   list.New(),
+		entries: map[int64]*list.Element{},
+	}
+}
+
+func (c *Students) GetStudentById(ctx context.Context, id int64) (types.Student, error) {
+	now := c.clock.Now()
+	c.mu.Lock()
+	if el, ok := c.entries[id]; ok {
+		e := el.Value.(*entry)
+		if now.Before(e.expires) {
+			c.order.MoveToFront(el)
+			c.mu.Unlock()
+			c.metrics.Hit()
+			return e.student, nil
+		}
+		c.remove(el)
+	}
+	writes := c.writes
+	c.mu.Unlock()
+	c.metrics.Miss()
+
+	student, err := c.Storage.GetStudentById(ctx, id)
+	if err != nil {
+		return student, err // missing students are not cached, the id can still be created
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// a write that happened while we read may have come after our row, keeping it would serve the old student for a whole ttl
+	if writes == c.writes {
+		c.add(student, now.Add(c.ttl))
+	}
+	return student, nil
+}
+
+func (c *Students) UpdateStudent(ctx context.Context, student types.Student) error {
+	err := c.Storage.UpdateStudent(ctx, student)
+	c.forget(student.Id) // on errors too, the row may have changed anyway (a timeout after the commit)
+	return err
+}
+
+func (c *Students) DeleteStudent(ctx context.Context, id int64, at time.Time) error {
+	err := c.Storage.DeleteStudent(ctx, id, at)
+	c.forget(id)
+	return err
+}
+
+// Len is how many students are cached right now, expired ones included until they are read or evicted
+func (c *Students) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *Students) forget(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writes++
+	if el, ok := c.entries[id]; ok {
+		c.remove(el)
+	}
+}
+
+// add puts the student at the front and evicts the least recently read one when over size, mu must be held
+func (c *Students) add(student types.Student, expires time.Time) {
+	if el, ok := c.entries[student.Id]; ok {
+		el.Value = &entry{student: student, expires: expires}
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[student.Id] = c.order.PushFront(&entry{student: student, expires: expires})
+	if c.order.Len() > c.size {
+		c.remove(c.order.Back())
+		c.metrics.Evict()
+	}
+}
+
+// remove drops el, mu must be held
+func (c *Students) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*entry).student.Id)
+}
@@ -0,0 +1,202 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/metrics"
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/storage/cache"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// db is the storage behind the cache, it counts how often a student was read from it
+type db struct {
+	storage.Storage // nil, the cache only calls the methods below
+	students        map[int64]types.Student
+	reads           int
+}
+
+func (d *db) GetStudentById(ctx context.Context, id int64) (types.Student, error) {
+	d.reads++
+	s, ok := d.students[id]
+	if !ok {
+		return types.Student{}, &storage.NotFoundError{Entity: "student"}
+	}
+	return s, nil
+}
+
+func (d *db) UpdateStudent(ctx context.Context, student types.Student) error {
+	d.students[student.Id] = student
+	return nil
+}
+
+func (d *db) DeleteStudent(ctx context.Context, id int64, at time.Time) error {
+	delete(d.students, id)
+	return nil
+}
+
+func newCache(t *testing.T, size int) (*cache.Students, *db, *clock.Fake, *prometheus.Registry) {
+	t.Helper()
+	d := &db{students: map[int64]types.Student{
+		1: {Id: 1, Name: "Asha", Email: "asha@example.com", Age: 21},
+		2: {Id: 2, Name: "Ravi", Email: "ravi@example.com", Age: 22},
+		3: {Id: 3, Name: "Mia", Email: "mia@example.com", Age: 23},
+	}}
+	clk := clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	reg := prometheus.NewRegistry()
+	c := cache.New(d, config.StudentCache{Size: size, TTL: time.Minute}, clk, metrics.NewCache(reg))
+	return c, d, clk, reg
+}
+
+func get(t *testing.T, c *cache.Students, id int64) types.Student {
+	t.Helper()
+	s, err := c.GetStudentById(context.Background(), id)
+	if err != nil {
+		t.Fatalf("get %d: %v", id, err)
+	}
+	return s
+}
+
+// counter reads a counter of the registry, for student_cache_requests_total the label is the result
+func counter(t *testing.T, reg *prometheus.Registry, name, result string) float64 {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			if len(m.GetLabel()) == 0 || m.GetLabel()[0].GetValue() == result {
+				return m.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
+
+func TestStudentsHitAndMiss(t *testing.T) {
+	t.Parallel()
+
+	c, d, _, reg := newCache(t, 10)
+	get(t, c, 1)
+	if s := get(t, c, 1); s.Name != "Asha" {
+		t.Fatalf("want Asha from the cache, got %+v", s)
+	}
+	if d.reads != 1 {
+		t.Fatalf("want 1 db read, got %d", d.reads)
+	}
+	if hits, misses := counter(t, reg, "student_cache_requests_total", "hit"), counter(t, reg, "student_cache_requests_total", "miss"); hits != 1 || misses != 1 {
+		t.Fatalf("want 1 hit and 1 miss, got %v and %v", hits, misses)
+	}
+
+	// missing students are not cached, the id may be created next
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetStudentById(context.Background(), 9); !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("want ErrNotFound, got %v", err)
+		}
+	}
+	if d.reads != 3 {
+		t.Fatalf("want both reads of a missing student to reach the db, got %d reads", d.reads)
+	}
+}
+
+func TestStudentsTTL(t *testing.T) {
+	t.Parallel()
+
+	c, d, clk, _ := newCache(t, 10)
+	get(t, c, 1)
+	clk.Advance(59 * time.Second)
+	get(t, c, 1)
+	if d.reads != 1 {
+		t.Fatalf("want the student cached before the ttl, got %d db reads", d.reads)
+	}
+	clk.Advance(time.Second)
+	get(t, c, 1)
+	if d.reads != 2 {
+		t.Fatalf("want the student read again once the ttl passed, got %d db reads", d.reads)
+	}
+}
+
+func TestStudentsInvalidation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		write func(c *cache.Students) error
+		check func(t *testing.T, c *cache.Students)
+	}{
+		{
+			name: "update",
+			write: func(c *cache.Students) error {
+				return c.UpdateStudent(context.Background(), types.Student{Id: 1, Name: "Asha Rao", Email: "asha@example.com", Age: 21})
+			},
+			check: func(t *testing.T, c *cache.Students) {
+				if s := get(t, c, 1); s.Name != "Asha Rao" {
+					t.Fatalf("want the updated student, got %+v", s)
+				}
+			},
+		},
+		{
+			name:  "delete",
+			write: func(c *cache.Students) error { return c.DeleteStudent(context.Background(), 1, time.Now()) },
+			check: func(t *testing.T, c *cache.Students) {
+				if _, err := c.GetStudentById(context.Background(), 1); !errors.Is(err, storage.ErrNotFound) {
+					t.Fatalf("want ErrNotFound after the delete, got %v", err)
+				}
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c, _, _, _ := newCache(t, 10)
+			get(t, c, 1)
+			if err := tc.write(c); err != nil {
+				t.Fatal(err)
+			}
+			if c.Len() != 0 {
+				t.Fatalf("want the student dropped, %d still cached", c.Len())
+			}
+			tc.check(t, c)
+		})
+	}
+}
+
+func TestStudentsEvictsLeastRecentlyRead(t *testing.T) {
+	t.Parallel()
+
+	c, d, _, reg := newCache(t, 2)
+	get(t, c, 1)
+	get(t, c, 2)
+	get(t, c, 1) // 2 is now the least recently read
+	get(t, c, 3)
+	if c.Len() != 2 {
+		t.Fatalf("want 2 cached, got %d", c.Len())
+	}
+	if got := counter(t, reg, "student_cache_evictions_total", ""); got != 1 {
+		t.Fatalf("want 1 eviction, got %v", got)
+	}
+
+	reads := d.reads
+	get(t, c, 1)
+	get(t, c, 3)
+	if d.reads != reads {
+		t.Fatal("want 1 and 3 still cached")
+	}
+	get(t, c, 2)
+	if d.reads != reads+1 {
+		t.Fatal("want 2 evicted and read from the db again")
+	}
+}
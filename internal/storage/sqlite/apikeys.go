@@ -0,0 +1,126 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+const createAPIKeysTable = `CREATE TABLE IF NOT EXISTS api_keys(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	prefix TEXT NOT NULL,
+	hash TEXT NOT NULL UNIQUE,
+	scopes TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	last_used_at TIMESTAMP,
+	revoked_at TIMESTAMP
+)`
+
+const (
+	insertAPIKeyQuery = "INSERT INTO api_keys (name, prefix, hash, scopes, created_at) VALUES(?,?,?,?,?)"
+	listAPIKeysQuery  = "SELECT id, name, prefix, hash, scopes, created_at, last_used_at, revoked_at FROM api_keys ORDER BY id"
+	apiKeyByHashQuery = "SELECT id, name, prefix, hash, scopes, created_at, last_used_at, revoked_at FROM api_keys WHERE hash = ?"
+	revokeAPIKeyQuery = "UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL"
+	touchAPIKeyQuery  = "UPDATE api_keys SET last_used_at = ? WHERE id = ?"
+)
+
+func (s *Sqlite) CreateAPIKey(ctx context.Context, key types.APIKey) (id int64, err error) {
+	ctx, span := startSpan(ctx, "CreateAPIKey", insertAPIKeyQuery)
+	defer func() { endSpan(span, err) }()
+
+	res, err := s.Db.ExecContext(ctx, insertAPIKeyQuery, key.Name, key.Prefix, key.Hash, strings.Join(key.Scopes, ","), key.CreatedAt.UTC())
+	if err != nil {
+		return 0, writeError(err, "api key")
+	}
+	return res.LastInsertId()
+}
+
+func (s *Sqlite) ListAPIKeys(ctx context.Context) (keys []types.APIKey, err error) {
+	ctx, span := startSpan(ctx, "ListAPIKeys", listAPIKeysQuery)
+	defer func() { endSpan(span, err) }()
+
+	rows, err := s.Db.QueryContext(ctx, listAPIKeysQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys = []types.APIKey{} // empty json array instead of null when there are no keys
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *Sqlite) APIKeyByHash(ctx context.Context, hash string) (key types.APIKey, err error) {
+	ctx, span := startSpan(ctx, "APIKeyByHash", apiKeyByHashQuery)
+	defer func() { endSpan(span, err) }()
+
+	key, err = scanAPIKey(s.Db.QueryRowContext(ctx, apiKeyByHashQuery, hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return types.APIKey{}, &storage.NotFoundError{Entity: "api key"}
+	}
+	return key, err
+}
+
+func (s *Sqlite) RevokeAPIKey(ctx context.Context, id int64, at time.Time) (err error) {
+	ctx, span := startSpan(ctx, "RevokeAPIKey", revokeAPIKeyQuery)
+	defer func() { endSpan(span, err) }()
+
+	res, err := s.Db.ExecContext(ctx, revokeAPIKeyQuery, at.UTC(), id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return &storage.NotFoundError{Entity: "active api key", Key: fmt.Sprintf("id %d", id)}
+	}
+	return nil
+}
+
+func (s *Sqlite) TouchAPIKey(ctx context.Context, id int64, at time.Time) (err error) {
+	ctx, span := startSpan(ctx, "TouchAPIKey", touchAPIKeyQuery)
+	defer func() { endSpan(span, err) }()
+
+	_, err = s.Db.ExecContext(ctx, touchAPIKeyQuery, at.UTC(), id)
+	return err
+}
+
+// scanner is what *sql.Row and *sql.Rows have in common
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAPIKey(row scanner) (types.APIKey, error) {
+	var key types.APIKey
+	var scopes string
+	var lastUsed, revoked sql.NullTime
+	if err := row.Scan(&key.Id, &key.Name, &key.Prefix, &key.Hash, &scopes, &key.CreatedAt, &lastUsed, &revoked); err != nil {
+		return types.APIKey{}, err
+	}
+	key.Scopes = []string{}
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	if lastUsed.Valid {
+		key.LastUsedAt = &lastUsed.Time
+	}
+	if revoked.Valid {
+		key.RevokedAt = &revoked.Time
+	}
+	return key, nil
+}
@@ -0,0 +1,119 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+const createEmailsTable = `CREATE TABLE IF NOT EXISTS emails(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	template TEXT NOT NULL,
+	recipient TEXT NOT NULL,
+	subject TEXT NOT NULL,
+	body TEXT NOT NULL,
+	status TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL,
+	sent_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS emails_status ON emails(status, id)`
+
+const emailColumns = "id, template, recipient, subject, body, status, attempts, last_error, created_at, sent_at"
+
+const (
+	insertEmailQuery = "INSERT INTO emails (template, recipient, subject, body, status, created_at) VALUES(?,?,?,?,'pending',?)"
+	getEmailQuery    = "SELECT " + emailColumns + " FROM emails WHERE id = ?"
+	updateEmailQuery = "UPDATE emails SET status = ?, attempts = ?, last_error = ?, sent_at = ? WHERE id = ?"
+	listEmailsQuery  = "SELECT " + emailColumns + " FROM emails WHERE ? = '' OR status = ? ORDER BY id DESC LIMIT ?"
+	// pending ones still have a job that sends them
+	purgeEmailsQuery = "DELETE FROM emails WHERE status <> 'pending' AND created_at < ?"
+)
+
+func (s *Sqlite) EnqueueEmail(ctx context.Context, email types.Email) (id int64, err error) {
+	ctx, span := startSpan(ctx, "EnqueueEmail", insertEmailQuery)
+	defer func() { endSpan(span, err) }()
+
+	tx, err := s.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback() // no-op after Commit
+	res, err := tx.ExecContext(ctx, insertEmailQuery, email.Template, email.To, email.Subject, email.Body, email.CreatedAt.UTC())
+	if err != nil {
+		return 0, err
+	}
+	if id, err = res.LastInsertId(); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, insertJobQuery, types.EmailJob, strconv.FormatInt(id, 10), email.CreatedAt.UTC(), email.CreatedAt.UTC()); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+func (s *Sqlite) EmailById(ctx context.Context, id int64) (email types.Email, err error) {
+	ctx, span := startSpan(ctx, "EmailById", getEmailQuery)
+	defer func() { endSpan(span, err) }()
+
+	emails, err := s.queryEmails(ctx, getEmailQuery, id)
+	if err != nil {
+		return types.Email{}, err
+	}
+	if len(emails) == 0 {
+		return types.Email{}, &storage.NotFoundError{Entity: "email", Key: fmt.Sprintf("id %d", id)}
+	}
+	return emails[0], nil
+}
+
+func (s *Sqlite) UpdateEmail(ctx context.Context, email types.Email) (err error) {
+	ctx, span := startSpan(ctx, "UpdateEmail", updateEmailQuery)
+	defer func() { endSpan(span, err) }()
+
+	_, err = s.Db.ExecContext(ctx, updateEmailQuery, email.Status, email.Attempts, email.LastError, nullTime(email.SentAt), email.Id)
+	return err
+}
+
+func (s *Sqlite) ListEmails(ctx context.Context, status string, limit int) (emails []types.Email, err error) {
+	ctx, span := startSpan(ctx, "ListEmails", listEmailsQuery)
+	defer func() { endSpan(span, err) }()
+
+	return s.queryEmails(ctx, listEmailsQuery, status, status, limit)
+}
+
+// PurgeEmails deletes the sent and failed emails created before before
+func (s *Sqlite) PurgeEmails(ctx context.Context, before time.Time) (n int64, err error) {
+	ctx, span := startSpan(ctx, "PurgeEmails", purgeEmailsQuery)
+	defer func() { endSpan(span, err) }()
+
+	return s.purge(ctx, purgeEmailsQuery, before)
+}
+
+func (s *Sqlite) queryEmails(ctx context.Context, query string, args ...any) ([]types.Email, error) {
+	rows, err := s.Db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	emails := []types.Email{}
+	for rows.Next() {
+		var e types.Email
+		var sent sql.NullTime
+		if err := rows.Scan(&e.Id, &e.Template, &e.To, &e.Subject, &e.Body, &e.Status, &e.Attempts, &e.LastError,
+			&e.CreatedAt, &sent); err != nil {
+			return nil, err
+		}
+		if sent.Valid {
+			e.SentAt = &sent.Time
+		}
+		emails = append(emails, e)
+	}
+	return emails, rows.Err()
+}
@@ -0,0 +1,33 @@
+package sqlite
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/mattn/go-sqlite3"
+)
+
+// writeError turns constraint failures of a write into the storage errors, anything else is returned as it is.
+// entity names the row for the message, like "student"
+func writeError(err error, entity string) error {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.Code != sqlite3.ErrConstraint {
+		return err
+	}
+	if sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
+		return &storage.DuplicateError{Entity: entity, Field: uniqueColumn(sqliteErr)}
+	}
+	return fmt.Errorf("%s: %w", entity, storage.ErrConflict)
+}
+
+// uniqueColumn reads the column out of "UNIQUE constraint failed: users.username", empty when there is more than one
+func uniqueColumn(err sqlite3.Error) string {
+	_, columns, ok := strings.Cut(err.Error(), "constraint failed: ")
+	if !ok || strings.Contains(columns, ",") {
+		return ""
+	}
+	_, column, _ := strings.Cut(columns, ".")
+	return column
+}
@@ -0,0 +1,251 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+// upload is the path of the file the job reads, the report of the records that failed is in import_errors
+const createImportsTable = `CREATE TABLE IF NOT EXISTS imports(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	job_id INTEGER NOT NULL,
+	format TEXT NOT NULL,
+	created_by TEXT NOT NULL DEFAULT '',
+	upload TEXT NOT NULL,
+	status TEXT NOT NULL,
+	total INTEGER NOT NULL,
+	processed INTEGER NOT NULL DEFAULT 0,
+	created INTEGER NOT NULL DEFAULT 0,
+	failed INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL,
+	started_at TIMESTAMP,
+	finished_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS imports_job ON imports(job_id)`
+
+// one row per record that failed, a batch only adds its own rows and the report is read in line order
+const createImportErrorsTable = `CREATE TABLE IF NOT EXISTS import_errors(
+	import_id INTEGER NOT NULL,
+	line INTEGER NOT NULL,
+	json TEXT NOT NULL,
+	PRIMARY KEY (import_id, line)
+) WITHOUT ROWID`
+
+const importColumns = "id, job_id, format, created_by, status, total, processed, created, failed, last_error, created_at, started_at, finished_at"
+
+// the report is read this many lines per query, a slow download never keeps a read open on the db
+const importErrorsPage = 1000
+
+const (
+	insertImportQuery      = "INSERT INTO imports (job_id, format, created_by, upload, status, total, created_at) VALUES(0,?,?,?,'pending',?,?)"
+	setImportJobQuery      = "UPDATE imports SET job_id = ? WHERE id = ?"
+	getImportQuery         = "SELECT " + importColumns + ", upload FROM imports WHERE id = ?"
+	importByJobQuery       = "SELECT " + importColumns + ", '' FROM imports WHERE job_id = ?"
+	importErrorsQuery      = "SELECT line, json FROM import_errors WHERE import_id = ? AND line > ? ORDER BY line LIMIT ?"
+	insertImportErrorQuery = "INSERT INTO import_errors (import_id, line, json) VALUES(?,?,?)"
+	importProgressQuery    = "UPDATE imports SET status = ?, processed = ?, created = ?, failed = ?, started_at = ? WHERE id = ?"
+	importStatusQuery      = "UPDATE imports SET status = ?, last_error = ?, finished_at = ?, upload = CASE WHEN ? THEN '' ELSE upload END WHERE id = ?"
+	// unfinished ones still have a job that works on them
+	purgeImportErrorsQuery = "DELETE FROM import_errors WHERE import_id IN (SELECT id FROM imports WHERE finished_at IS NOT NULL AND created_at < ?)"
+	purgeImportsQuery      = "DELETE FROM imports WHERE finished_at IS NOT NULL AND created_at < ? RETURNING upload"
+)
+
+func (s *Sqlite) CreateImport(ctx context.Context, imp types.Import) (_ types.Import, err error) {
+	ctx, span := startSpan(ctx, "CreateImport", insertImportQuery)
+	defer func() { endSpan(span, err) }()
+
+	tx, err := s.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return types.Import{}, err
+	}
+	defer tx.Rollback() // no-op after Commit
+	res, err := tx.ExecContext(ctx, insertImportQuery, imp.Format, imp.CreatedBy, imp.Upload, imp.Total, imp.CreatedAt.UTC())
+	if err != nil {
+		return types.Import{}, err
+	}
+	if imp.Id, err = res.LastInsertId(); err != nil {
+		return types.Import{}, err
+	}
+	res, err = tx.ExecContext(ctx, insertJobQuery, types.ImportJob, strconv.FormatInt(imp.Id, 10), imp.CreatedAt.UTC(), imp.CreatedAt.UTC())
+	if err != nil {
+		return types.Import{}, err
+	}
+	if imp.JobId, err = res.LastInsertId(); err != nil {
+		return types.Import{}, err
+	}
+	if _, err := tx.ExecContext(ctx, setImportJobQuery, imp.JobId, imp.Id); err != nil {
+		return types.Import{}, err
+	}
+	imp.Status = types.JobPending
+	return imp, tx.Commit()
+}
+
+func (s *Sqlite) ImportById(ctx context.Context, id int64) (imp types.Import, err error) {
+	ctx, span := startSpan(ctx, "ImportById", getImportQuery)
+	defer func() { endSpan(span, err) }()
+
+	return s.queryImport(ctx, getImportQuery, fmt.Sprintf("id %d", id), id)
+}
+
+func (s *Sqlite) ImportByJob(ctx context.Context, jobId int64) (imp types.Import, err error) {
+	ctx, span := startSpan(ctx, "ImportByJob", importByJobQuery)
+	defer func() { endSpan(span, err) }()
+
+	return s.queryImport(ctx, importByJobQuery, fmt.Sprintf("job %d", jobId), jobId)
+}
+
+// ImportErrors hands the error report of an import to fn a line at a time. it is read a page at a time after the
+// last line handed out, so lines that later batches add while it runs still come in order
+func (s *Sqlite) ImportErrors(ctx context.Context, importId int64, fn func(line []byte) error) (err error) {
+	ctx, span := startSpan(ctx, "ImportErrors", importErrorsQuery)
+	defer func() { endSpan(span, err) }()
+
+	type failure struct {
+		line int
+		json []byte
+	}
+	page := make([]failure, 0, importErrorsPage)
+	for after := 0; ; {
+		rows, err := s.Db.QueryContext(ctx, importErrorsQuery, importId, after, importErrorsPage)
+		if err != nil {
+			return err
+		}
+		page = page[:0]
+		for rows.Next() {
+			var f failure
+			if err := rows.Scan(&f.line, &f.json); err != nil {
+				rows.Close()
+				return err
+			}
+			page = append(page, f)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		// the page is read to the end first, fn may be slow (a download) and must not hold the query open
+		for _, f := range page {
+			if err := fn(append(f.json, '\n')); err != nil {
+				return err
+			}
+			after = f.line
+		}
+		if len(page) < importErrorsPage {
+			return nil
+		}
+	}
+}
+
+func (s *Sqlite) ImportBatch(ctx context.Context, progress types.Import, students []types.Student, failures []types.ImportFailure) (ids []int64, err error) {
+	ctx, span := startSpan(ctx, "ImportBatch", importProgressQuery)
+	defer func() { endSpan(span, err) }()
+
+	save := func(tx *sql.Tx) error {
+		if len(students) > 0 {
+			if ids, err = s.insertStudents(ctx, tx, students); err != nil {
+				return err
+			}
+		}
+		for _, f := range failures {
+			if _, err := tx.ExecContext(ctx, insertImportErrorQuery, progress.Id, f.Line, f.Report); err != nil {
+				return err
+			}
+		}
+		_, err := tx.ExecContext(ctx, importProgressQuery, progress.Status, progress.Processed, progress.Created, progress.Failed,
+			nullTime(progress.StartedAt), progress.Id)
+		return err
+	}
+	if len(students) > 0 {
+		err = s.writeStudents(ctx, newest(students), save)
+	} else {
+		// nothing was stored, the lists keep their Last-Modified
+		err = s.inTx(ctx, save)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (s *Sqlite) UpdateImportStatus(ctx context.Context, imp types.Import) (err error) {
+	ctx, span := startSpan(ctx, "UpdateImportStatus", importStatusQuery)
+	defer func() { endSpan(span, err) }()
+
+	_, err = s.Db.ExecContext(ctx, importStatusQuery, imp.Status, imp.LastError, nullTime(imp.FinishedAt), imp.Status == types.JobDone, imp.Id)
+	return err
+}
+
+// PurgeImports deletes the finished imports created before before with their error reports, uploads are the files
+// the failed ones still had
+func (s *Sqlite) PurgeImports(ctx context.Context, before time.Time) (n int64, uploads []string, err error) {
+	ctx, span := startSpan(ctx, "PurgeImports", purgeImportsQuery)
+	defer func() { endSpan(span, err) }()
+
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, purgeImportErrorsQuery, before.UTC()); err != nil {
+			return err
+		}
+		rows, err := tx.QueryContext(ctx, purgeImportsQuery, before.UTC())
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var upload string
+			if err := rows.Scan(&upload); err != nil {
+				return err
+			}
+			n++
+			if upload != "" {
+				uploads = append(uploads, upload)
+			}
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return n, uploads, nil
+}
+
+func (s *Sqlite) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // no-op after Commit
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Sqlite) queryImport(ctx context.Context, query, key string, args ...any) (types.Import, error) {
+	var (
+		imp               types.Import
+		started, finished sql.NullTime
+	)
+	err := s.Db.QueryRowContext(ctx, query, args...).Scan(&imp.Id, &imp.JobId, &imp.Format, &imp.CreatedBy, &imp.Status, &imp.Total,
+		&imp.Processed, &imp.Created, &imp.Failed, &imp.LastError, &imp.CreatedAt, &started, &finished, &imp.Upload)
+	if errors.Is(err, sql.ErrNoRows) {
+		return types.Import{}, &storage.NotFoundError{Entity: "import", Key: key}
+	}
+	if err != nil {
+		return types.Import{}, err
+	}
+	if started.Valid {
+		imp.StartedAt = &started.Time
+	}
+	if finished.Valid {
+		imp.FinishedAt = &finished.Time
+	}
+	return imp, nil
+}
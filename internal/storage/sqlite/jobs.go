@@ -0,0 +1,318 @@
+package sqlite
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+const createJobsTable = `CREATE TABLE IF NOT EXISTS jobs(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	kind TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	status TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	run_at TIMESTAMP NOT NULL,
+	locked_until TIMESTAMP,
+	created_at TIMESTAMP NOT NULL,
+	finished_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS jobs_due ON jobs(status, run_at)`
+
+// deliveries that were pending before the jobs table existed were sent by a poller that is gone, each gets the job
+// that sends it now. runs once, when New creates the table
+const queuePendingDeliveries = `INSERT INTO jobs (kind, payload, status, attempts, run_at, created_at)
+	SELECT ?, CAST(id AS TEXT), 'pending', attempts, COALESCE(next_attempt_at, created_at), created_at
+	FROM webhook_deliveries WHERE status = 'pending' ORDER BY id`
+
+// dead_jobs keeps the jobs that failed for good after the jobs themselves were purged, until they are requeued
+const createDeadJobsTable = `CREATE TABLE IF NOT EXISTS dead_jobs(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	job_id INTEGER NOT NULL UNIQUE,
+	kind TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	attempts INTEGER NOT NULL,
+	last_error TEXT NOT NULL,
+	failed_at TIMESTAMP NOT NULL,
+	requeued_at TIMESTAMP,
+	requeued_job_id INTEGER
+);
+CREATE INDEX IF NOT EXISTS dead_jobs_kind ON dead_jobs(kind, id)`
+
+// the jobs that failed before dead_jobs existed are dead letters too. runs once, when New creates the table
+const buryFailedJobs = `INSERT INTO dead_jobs (job_id, kind, payload, attempts, last_error, failed_at)
+	SELECT id, kind, payload, attempts, last_error, COALESCE(finished_at, created_at) FROM jobs WHERE status = 'failed' ORDER BY id`
+
+const jobColumns = "id, kind, payload, status, attempts, last_error, run_at, locked_until, created_at, finished_at"
+
+const (
+	insertJobQuery = "INSERT INTO jobs (kind, payload, status, run_at, created_at) VALUES(?,?,'pending',?,?)"
+	// one statement, so two workers (or two instances on one file) never claim the same job
+	claimJobsQuery = `UPDATE jobs SET status = 'running', locked_until = ? WHERE id IN (
+		SELECT id FROM jobs WHERE kind IN (%s) AND ((status = 'pending' AND run_at <= ?) OR (status = 'running' AND locked_until <= ?))
+		ORDER BY run_at, id LIMIT ?) RETURNING ` + jobColumns
+	// only the worker holding the lease it was claimed with writes, one that ran out and was claimed again changes nothing
+	updateJobQuery = "UPDATE jobs SET status = ?, attempts = ?, last_error = ?, run_at = ?, locked_until = ?, finished_at = ? WHERE id = ? AND locked_until = ?"
+	purgeJobsQuery = "DELETE FROM jobs WHERE status IN ('done', 'failed') AND finished_at < ?"
+)
+
+const deadJobColumns = "id, job_id, kind, payload, attempts, last_error, failed_at, requeued_at, requeued_job_id"
+
+const (
+	insertDeadJobQuery = "INSERT INTO dead_jobs (job_id, kind, payload, attempts, last_error, failed_at) VALUES(?,?,?,?,?,?) ON CONFLICT(job_id) DO NOTHING"
+	listDeadJobsQuery  = "SELECT " + deadJobColumns + " FROM dead_jobs WHERE ? = '' OR kind = ? ORDER BY id DESC LIMIT ?"
+	getDeadJobQuery    = "SELECT " + deadJobColumns + " FROM dead_jobs WHERE id = ?"
+	// only the first requeue wins, a second one finds requeued_at set and gets no row. it writes first, a transaction
+	// that reads first fails with "database is locked" when another one wrote in between
+	requeueDeadJobQuery = "UPDATE dead_jobs SET requeued_at = ? WHERE id = ? AND requeued_at IS NULL RETURNING " + deadJobColumns
+	setRequeuedJobQuery = "UPDATE dead_jobs SET requeued_job_id = ? WHERE id = ?"
+	// the ones never requeued go too, nobody looked at them for that long
+	purgeDeadJobsQuery = "DELETE FROM dead_jobs WHERE failed_at < ?"
+)
+
+// createJobs makes the jobs table, and queues the deliveries of an older file the first time. then the same for the
+// dead_jobs table and the jobs that failed before it
+func createJobs(db *sql.DB) error {
+	if err := createFilled(db, "jobs", createJobsTable, queuePendingDeliveries, types.DeliveryJob); err != nil {
+		return err
+	}
+	return createFilled(db, "dead_jobs", createDeadJobsTable, buryFailedJobs)
+}
+
+// createFilled makes table with create and fills it with fill, in one transaction. nothing happens when table exists
+func createFilled(db *sql.DB, table, create, fill string, args ...any) error {
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = ?)", table).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // no-op after Commit
+	if _, err := tx.Exec(create); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(fill, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Sqlite) EnqueueJob(ctx context.Context, job types.Job) (id int64, err error) {
+	ctx, span := startSpan(ctx, "EnqueueJob", insertJobQuery)
+	defer func() { endSpan(span, err) }()
+
+	res, err := s.Db.ExecContext(ctx, insertJobQuery, job.Kind, job.Payload, job.RunAt.UTC(), job.CreatedAt.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (s *Sqlite) ClaimJobs(ctx context.Context, kinds []string, now, lockedUntil time.Time, limit int) (jobs []types.Job, err error) {
+	if len(kinds) == 0 || limit <= 0 {
+		return []types.Job{}, nil
+	}
+	query := fmt.Sprintf(claimJobsQuery, strings.TrimSuffix(strings.Repeat("?,", len(kinds)), ","))
+	ctx, span := startSpan(ctx, "ClaimJobs", query)
+	defer func() { endSpan(span, err) }()
+
+	args := []any{lockedUntil.UTC()}
+	for _, kind := range kinds {
+		args = append(args, kind)
+	}
+	args = append(args, now.UTC(), now.UTC(), limit)
+	rows, err := s.Db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs = []types.Job{}
+	for rows.Next() {
+		var job types.Job
+		var locked, finished sql.NullTime
+		if err := rows.Scan(&job.Id, &job.Kind, &job.Payload, &job.Status, &job.Attempts, &job.LastError, &job.RunAt,
+			&locked, &job.CreatedAt, &finished); err != nil {
+			return nil, err
+		}
+		if locked.Valid {
+			job.LockedUntil = &locked.Time
+		}
+		if finished.Valid {
+			job.FinishedAt = &finished.Time
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// RETURNING gives the rows in no particular order
+	slices.SortFunc(jobs, func(a, b types.Job) int {
+		if c := a.RunAt.Compare(b.RunAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Id, b.Id)
+	})
+	return jobs, nil
+}
+
+func (s *Sqlite) UpdateJob(ctx context.Context, job types.Job, lease time.Time) (err error) {
+	ctx, span := startSpan(ctx, "UpdateJob", updateJobQuery)
+	defer func() { endSpan(span, err) }()
+
+	return leaseHeld(s.Db.ExecContext(ctx, updateJobQuery, job.Status, job.Attempts, job.LastError, job.RunAt.UTC(),
+		nullTime(job.LockedUntil), nullTime(job.FinishedAt), job.Id, lease.UTC()))
+}
+
+func (s *Sqlite) FailJob(ctx context.Context, job types.Job, lease time.Time) (err error) {
+	ctx, span := startSpan(ctx, "FailJob", insertDeadJobQuery)
+	defer func() { endSpan(span, err) }()
+
+	failedAt := job.RunAt
+	if job.FinishedAt != nil {
+		failedAt = *job.FinishedAt
+	}
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if err := leaseHeld(tx.ExecContext(ctx, updateJobQuery, job.Status, job.Attempts, job.LastError, job.RunAt.UTC(),
+			nullTime(job.LockedUntil), nullTime(job.FinishedAt), job.Id, lease.UTC())); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, insertDeadJobQuery, job.Id, job.Kind, job.Payload, job.Attempts, job.LastError, failedAt.UTC())
+		return err
+	})
+}
+
+// leaseHeld is the result of the fenced updateJobQuery -> no row changed is a lease that was lost
+func leaseHeld(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return storage.ErrLeaseLost
+	}
+	return nil
+}
+
+func (s *Sqlite) ListDeadJobs(ctx context.Context, kind string, limit int) (dead []types.DeadJob, err error) {
+	ctx, span := startSpan(ctx, "ListDeadJobs", listDeadJobsQuery)
+	defer func() { endSpan(span, err) }()
+
+	rows, err := s.Db.QueryContext(ctx, listDeadJobsQuery, kind, kind, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dead = []types.DeadJob{}
+	for rows.Next() {
+		job, err := scanDeadJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		dead = append(dead, job)
+	}
+	return dead, rows.Err()
+}
+
+func (s *Sqlite) DeadJobById(ctx context.Context, id int64) (job types.DeadJob, err error) {
+	ctx, span := startSpan(ctx, "DeadJobById", getDeadJobQuery)
+	defer func() { endSpan(span, err) }()
+
+	job, err = scanDeadJob(s.Db.QueryRowContext(ctx, getDeadJobQuery, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return types.DeadJob{}, &storage.NotFoundError{Entity: "dead job", Key: fmt.Sprintf("id %d", id)}
+	}
+	return job, err
+}
+
+func (s *Sqlite) RequeueDeadJob(ctx context.Context, id int64, now time.Time) (job types.DeadJob, err error) {
+	ctx, span := startSpan(ctx, "RequeueDeadJob", requeueDeadJobQuery)
+	defer func() { endSpan(span, err) }()
+
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		job, err = scanDeadJob(tx.QueryRowContext(ctx, requeueDeadJobQuery, now.UTC(), id))
+		if err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, insertJobQuery, job.Kind, job.Payload, now.UTC(), now.UTC())
+		if err != nil {
+			return err
+		}
+		if job.RequeuedJobId, err = res.LastInsertId(); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, setRequeuedJobQuery, job.RequeuedJobId, id)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// not there, or requeued before
+		if _, err := s.DeadJobById(ctx, id); err != nil {
+			return types.DeadJob{}, err
+		}
+		return types.DeadJob{}, fmt.Errorf("dead job %d was requeued before: %w", id, storage.ErrConflict)
+	}
+	if err != nil {
+		return types.DeadJob{}, err
+	}
+	return job, nil
+}
+
+// PurgeDeadJobs deletes the dead jobs that failed before before, requeued or not
+func (s *Sqlite) PurgeDeadJobs(ctx context.Context, before time.Time) (n int64, err error) {
+	ctx, span := startSpan(ctx, "PurgeDeadJobs", purgeDeadJobsQuery)
+	defer func() { endSpan(span, err) }()
+
+	return s.purge(ctx, purgeDeadJobsQuery, before)
+}
+
+func scanDeadJob(row scanner) (types.DeadJob, error) {
+	var (
+		job      types.DeadJob
+		requeued sql.NullTime
+		newJob   sql.NullInt64
+	)
+	if err := row.Scan(&job.Id, &job.JobId, &job.Kind, &job.Payload, &job.Attempts, &job.LastError, &job.FailedAt,
+		&requeued, &newJob); err != nil {
+		return types.DeadJob{}, err
+	}
+	if requeued.Valid {
+		job.RequeuedAt = &requeued.Time
+	}
+	job.RequeuedJobId = newJob.Int64
+	return job, nil
+}
+
+// PurgeJobs deletes the done and failed jobs that finished before before, pending and running ones are kept
+func (s *Sqlite) PurgeJobs(ctx context.Context, before time.Time) (n int64, err error) {
+	ctx, span := startSpan(ctx, "PurgeJobs", purgeJobsQuery)
+	defer func() { endSpan(span, err) }()
+
+	return s.purge(ctx, purgeJobsQuery, before)
+}
+
+// purge runs a DELETE with before as its only argument and says how many rows went
+func (s *Sqlite) purge(ctx context.Context, query string, before time.Time) (int64, error) {
+	res, err := s.Db.ExecContext(ctx, query, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
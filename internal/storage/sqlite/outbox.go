@@ -0,0 +1,140 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/events"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+const createOutboxTable = `CREATE TABLE IF NOT EXISTS outbox(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	event_type TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	delivered_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS outbox_pending ON outbox(delivered_at, id)`
+
+const (
+	appendOutboxQuery  = "INSERT INTO outbox (event_type, payload, created_at) VALUES(?,?,?)"
+	pendingOutboxQuery = "SELECT id, event_type, payload, created_at, attempts, last_error, delivered_at FROM outbox WHERE delivered_at IS NULL ORDER BY id LIMIT ?"
+	// ids go in as a json array, so one statement marks any number of them
+	deliveredOutboxQuery = "UPDATE outbox SET delivered_at = ?, last_error = '' WHERE id IN (SELECT value FROM json_each(?))"
+	failedOutboxQuery    = "UPDATE outbox SET attempts = attempts + 1, last_error = ? WHERE id IN (SELECT value FROM json_each(?))"
+	// the oldest is the first pending row, MIN() would lose the column type and come back as text
+	outboxBacklogQuery = `SELECT COUNT(*), (SELECT created_at FROM outbox WHERE delivered_at IS NULL ORDER BY id LIMIT 1)
+		FROM outbox WHERE delivered_at IS NULL`
+	purgeOutboxQuery = "DELETE FROM outbox WHERE delivered_at < ?"
+)
+
+// appendOutbox adds events to the outbox in tx, so they are committed or rolled back with the write they are about.
+// without a sink nothing is kept, no relay would ever publish or purge the rows
+func (s *Sqlite) appendOutbox(ctx context.Context, tx *sql.Tx, at time.Time, evs ...events.Event) error {
+	if !s.outbox {
+		return nil
+	}
+	for _, e := range evs {
+		if e == nil {
+			continue
+		}
+		body, err := events.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if _, err := s.txExec(ctx, tx, appendOutboxQuery, e.EventType(), string(body), at.UTC()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// studentCreated is the student.created event of a student that was just inserted, nil for one the event would
+// refuse (no email), the handlers publish nothing for those either
+func studentCreated(student types.Student, at time.Time) events.Event {
+	event, err := events.NewStudentCreated(student, at)
+	if err != nil {
+		return nil
+	}
+	return event
+}
+
+func (s *Sqlite) PendingOutbox(ctx context.Context, limit int) (events []types.OutboxEvent, err error) {
+	ctx, span := startSpan(ctx, "PendingOutbox", pendingOutboxQuery)
+	defer func() { endSpan(span, err) }()
+
+	rows, err := s.Db.QueryContext(ctx, pendingOutboxQuery, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events = []types.OutboxEvent{}
+	for rows.Next() {
+		var e types.OutboxEvent
+		var delivered sql.NullTime
+		if err := rows.Scan(&e.Id, &e.EventType, &e.Payload, &e.CreatedAt, &e.Attempts, &e.LastError, &delivered); err != nil {
+			return nil, err
+		}
+		if delivered.Valid {
+			e.DeliveredAt = &delivered.Time
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func (s *Sqlite) MarkOutboxDelivered(ctx context.Context, ids []int64, at time.Time) (err error) {
+	ctx, span := startSpan(ctx, "MarkOutboxDelivered", deliveredOutboxQuery)
+	defer func() { endSpan(span, err) }()
+
+	_, err = s.Db.ExecContext(ctx, deliveredOutboxQuery, at.UTC(), idsJSON(ids))
+	return err
+}
+
+func (s *Sqlite) MarkOutboxFailed(ctx context.Context, ids []int64, lastError string) (err error) {
+	ctx, span := startSpan(ctx, "MarkOutboxFailed", failedOutboxQuery)
+	defer func() { endSpan(span, err) }()
+
+	_, err = s.Db.ExecContext(ctx, failedOutboxQuery, lastError, idsJSON(ids))
+	return err
+}
+
+func (s *Sqlite) OutboxBacklog(ctx context.Context) (pending int64, oldest time.Time, err error) {
+	ctx, span := startSpan(ctx, "OutboxBacklog", outboxBacklogQuery)
+	defer func() { endSpan(span, err) }()
+
+	var first sql.NullTime
+	if err := s.Db.QueryRowContext(ctx, outboxBacklogQuery).Scan(&pending, &first); err != nil {
+		return 0, time.Time{}, err
+	}
+	return pending, first.Time, nil
+}
+
+// PurgeOutbox deletes the events that were delivered before before
+func (s *Sqlite) PurgeOutbox(ctx context.Context, before time.Time) (n int64, err error) {
+	ctx, span := startSpan(ctx, "PurgeOutbox", purgeOutboxQuery)
+	defer func() { endSpan(span, err) }()
+
+	return s.purge(ctx, purgeOutboxQuery, before)
+}
+
+// idsJSON is ids as a json array for json_each
+func idsJSON(ids []int64) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, id := range ids {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatInt(id, 10))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
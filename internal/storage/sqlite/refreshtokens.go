@@ -0,0 +1,103 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+const createRefreshTokensTable = `CREATE TABLE IF NOT EXISTS refresh_tokens(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	hash TEXT NOT NULL UNIQUE,
+	family TEXT NOT NULL,
+	subject TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	roles TEXT NOT NULL,
+	scopes TEXT NOT NULL DEFAULT '',
+	student_id INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP,
+	revoked_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS refresh_tokens_family ON refresh_tokens(family)`
+
+const (
+	insertRefreshTokenQuery = "INSERT INTO refresh_tokens (hash, family, subject, kind, roles, scopes, student_id, created_at, expires_at) VALUES(?,?,?,?,?,?,?,?,?)"
+	refreshTokenByHashQuery = "SELECT id, hash, family, subject, kind, roles, scopes, student_id, created_at, expires_at, used_at, revoked_at FROM refresh_tokens WHERE hash = ?"
+	useRefreshTokenQuery    = "UPDATE refresh_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL"
+	revokeFamilyQuery       = "UPDATE refresh_tokens SET revoked_at = ? WHERE family = ? AND revoked_at IS NULL"
+	purgeRefreshTokensQuery = "DELETE FROM refresh_tokens WHERE expires_at < ?"
+)
+
+func (s *Sqlite) CreateRefreshToken(ctx context.Context, token types.RefreshToken) (err error) {
+	ctx, span := startSpan(ctx, "CreateRefreshToken", insertRefreshTokenQuery)
+	defer func() { endSpan(span, err) }()
+
+	_, err = s.Db.ExecContext(ctx, insertRefreshTokenQuery, token.Hash, token.Family, token.Subject, token.Kind,
+		strings.Join(token.Roles, ","), strings.Join(token.Scopes, ","), token.StudentID, token.CreatedAt.UTC(), token.ExpiresAt.UTC())
+	return writeError(err, "refresh token")
+}
+
+func (s *Sqlite) RefreshTokenByHash(ctx context.Context, hash string) (token types.RefreshToken, err error) {
+	ctx, span := startSpan(ctx, "RefreshTokenByHash", refreshTokenByHashQuery)
+	defer func() { endSpan(span, err) }()
+
+	var roles, scopes string
+	var used, revoked sql.NullTime
+	err = s.Db.QueryRowContext(ctx, refreshTokenByHashQuery, hash).Scan(&token.Id, &token.Hash, &token.Family, &token.Subject,
+		&token.Kind, &roles, &scopes, &token.StudentID, &token.CreatedAt, &token.ExpiresAt, &used, &revoked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return types.RefreshToken{}, &storage.NotFoundError{Entity: "refresh token"}
+	}
+	if err != nil {
+		return types.RefreshToken{}, err
+	}
+	if roles != "" {
+		token.Roles = strings.Split(roles, ",")
+	}
+	if scopes != "" {
+		token.Scopes = strings.Split(scopes, ",")
+	}
+	if used.Valid {
+		token.UsedAt = &used.Time
+	}
+	if revoked.Valid {
+		token.RevokedAt = &revoked.Time
+	}
+	return token, nil
+}
+
+func (s *Sqlite) MarkRefreshTokenUsed(ctx context.Context, id int64, at time.Time) (won bool, err error) {
+	ctx, span := startSpan(ctx, "MarkRefreshTokenUsed", useRefreshTokenQuery)
+	defer func() { endSpan(span, err) }()
+
+	res, err := s.Db.ExecContext(ctx, useRefreshTokenQuery, at.UTC(), id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (s *Sqlite) RevokeRefreshFamily(ctx context.Context, family string, at time.Time) (err error) {
+	ctx, span := startSpan(ctx, "RevokeRefreshFamily", revokeFamilyQuery)
+	defer func() { endSpan(span, err) }()
+
+	_, err = s.Db.ExecContext(ctx, revokeFamilyQuery, at.UTC(), family)
+	return err
+}
+
+// PurgeRefreshTokens deletes the tokens that expired before before. reuse detection of a family needs its used tokens
+// only until they expire, an expired token is refused anyway
+func (s *Sqlite) PurgeRefreshTokens(ctx context.Context, before time.Time) (n int64, err error) {
+	ctx, span := startSpan(ctx, "PurgeRefreshTokens", purgeRefreshTokensQuery)
+	defer func() { endSpan(span, err) }()
+
+	return s.purge(ctx, purgeRefreshTokensQuery, before)
+}
@@ -1,14 +1,26 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
 
 	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/events"
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/types"
 	_ "github.com/mattn/go-sqlite3" // _ because we are using this behind the seen
 )
 
 type Sqlite struct {
-	Db *sql.DB
+	Db          *sql.DB
+	insertBatch int                  // students per INSERT of CreateStudents
+	stmts       map[string]*sql.Stmt // the student queries by their text, prepared once in New
+	outbox      bool                 // an outbox sink is configured, student writes add their public events to it
 }
 
 func New(cfg *config.Config) (*Sqlite, error) {
@@ -20,32 +32,514 @@ func New(cfg *config.Config) (*Sqlite, error) {
 	       id INTEGER PRIMARY KEY AUTOINCREMENT,
 		   name TEXT,
 		   age INTEGER,
-		   email TEXT
+		   email TEXT,
+		   updated_at TIMESTAMP
 	   )`)
 
 	if err != nil {
 		return nil, err
 	}
+	// files from before updated_at was kept get the column, their students have it NULL and are sent without a
+	// Last-Modified until they change
+	if err := addColumn(db, "students", "updated_at", "TIMESTAMP"); err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(createStudentsEmailIndex); err != nil {
+		return nil, fmt.Errorf("students: unique email index, remove the students that share an email first: %w", err)
+	}
+	for _, table := range []string{createStudentsChangedTable, createAPIKeysTable, createUsersTable, createRefreshTokensTable, createWebhooksTable, createWebhookDeliveriesTable, createEmailsTable, createOutboxTable, createImportsTable, createImportErrorsTable} {
+		if _, err := db.Exec(table); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := createJobs(db); err != nil {
+		return nil, err
+	}
+
+	s := &Sqlite{
+		Db:          db,
+		insertBatch: min(max(cfg.SQLite.InsertBatch, 1), maxInsertBatch),
+		outbox:      cfg.Outbox.Sink != "",
+	}
+	if s.stmts, err = prepare(db, append(studentQueries, insertStudentsQuery(s.insertBatch))); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// addColumn adds a column to a table made by an older version, ALTER TABLE has no IF NOT EXISTS
+func addColumn(db *sql.DB, table, column, decl string) error {
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	_, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, decl))
+	return err
+}
+
+// studentQueries run on every request, New prepares them once instead of sqlite parsing them again each time
+var studentQueries = []string{insertStudentQuery, getStudentQuery, listStudentsQuery, updateStudentQuery, deleteStudentQuery,
+	exportStudentsQuery, emailTakenQuery, touchStudentsQuery, studentsChangedQuery, appendOutboxQuery}
+
+func prepare(db *sql.DB, queries []string) (map[string]*sql.Stmt, error) {
+	stmts := make(map[string]*sql.Stmt, len(queries))
+	for _, q := range queries {
+		stmt, err := db.Prepare(q)
+		if err != nil {
+			for _, prepared := range stmts {
+				prepared.Close()
+			}
+			return nil, fmt.Errorf("prepare %q: %w", q, err)
+		}
+		stmts[q] = stmt
+	}
+	return stmts, nil
+}
+
+// exec, query and queryRow run q on the statement New prepared for it, or straight on the db for a query that has
+// none (the filtered lists) or a Sqlite that was not made by New
+func (s *Sqlite) exec(ctx context.Context, q string, args ...any) (sql.Result, error) {
+	if stmt := s.stmts[q]; stmt != nil {
+		return stmt.ExecContext(ctx, args...)
+	}
+	return s.Db.ExecContext(ctx, q, args...)
+}
+
+func (s *Sqlite) query(ctx context.Context, q string, args ...any) (*sql.Rows, error) {
+	if stmt := s.stmts[q]; stmt != nil {
+		return stmt.QueryContext(ctx, args...)
+	}
+	return s.Db.QueryContext(ctx, q, args...)
+}
+
+func (s *Sqlite) queryRow(ctx context.Context, q string, args ...any) *sql.Row {
+	if stmt := s.stmts[q]; stmt != nil {
+		return stmt.QueryRowContext(ctx, args...)
+	}
+	return s.Db.QueryRowContext(ctx, q, args...)
+}
+
+// txExec is exec inside tx
+func (s *Sqlite) txExec(ctx context.Context, tx *sql.Tx, q string, args ...any) (sql.Result, error) {
+	if stmt := s.stmts[q]; stmt != nil {
+		return tx.StmtContext(ctx, stmt).ExecContext(ctx, args...)
+	}
+	return tx.ExecContext(ctx, q, args...)
+}
+
+// writeStudents runs fn in a transaction that also moves students_changed to at, so a list never keeps its
+// Last-Modified after a write that committed
+func (s *Sqlite) writeStudents(ctx context.Context, at time.Time, fn func(tx *sql.Tx) error) error {
+	tx, err := s.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // no-op after Commit
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if _, err := s.txExec(ctx, tx, touchStudentsQuery, at.UTC()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// maxInsertBatch is as many students as fit the 32766 parameters sqlite takes for one statement, 4 per student
+const maxInsertBatch = 32766 / 4
+
+// one student per email, without looking at case. the create and update of a taken email fail with a DuplicateError
+const createStudentsEmailIndex = "CREATE UNIQUE INDEX IF NOT EXISTS students_email ON students(email COLLATE NOCASE)"
+
+// students_changed has a single row, when any student was last created, changed or deleted. it is the Last-Modified
+// of the lists, a deleted student leaves no updated_at behind to take the newest of
+const createStudentsChangedTable = `CREATE TABLE IF NOT EXISTS students_changed(
+	id INTEGER PRIMARY KEY CHECK (id = 1),
+	at TIMESTAMP NOT NULL
+)`
+
+// all queries in one place, New prepares each of them once at startup
+const (
+	studentColumns       = "id, name, email, age, updated_at" // what scanStudent reads
+	insertStudentQuery   = "INSERT INTO students (name,email,age,updated_at) VALUES(?,?,?,?)"
+	getStudentQuery      = "SELECT " + studentColumns + " FROM students WHERE id = ?"
+	listStudentsQuery    = "SELECT " + studentColumns + " FROM students ORDER BY id LIMIT ? OFFSET ?"
+	updateStudentQuery   = "UPDATE students SET name = ?, email = ?, age = ?, updated_at = ? WHERE id = ?"
+	deleteStudentQuery   = "DELETE FROM students WHERE id = ?"
+	exportStudentsQuery  = "SELECT " + studentColumns + " FROM students WHERE id > ? ORDER BY id"
+	emailTakenQuery      = "SELECT EXISTS(SELECT 1 FROM students WHERE email = ? COLLATE NOCASE)"
+	touchStudentsQuery   = "INSERT INTO students_changed (id, at) VALUES (1, ?) ON CONFLICT(id) DO UPDATE SET at = max(at, excluded.at)"
+	studentsChangedQuery = "SELECT at FROM students_changed WHERE id = 1"
+)
+
+// scanStudent reads the studentColumns of one row
+func scanStudent(row interface{ Scan(dest ...any) error }) (types.Student, error) {
+	var (
+		student types.Student
+		updated sql.NullTime // NULL for students from before it was kept
+	)
+	err := row.Scan(&student.Id, &student.Name, &student.Email, &student.Age, &updated)
+	student.UpdatedAt = updated.Time
+	return student, err
+}
+
+func (s *Sqlite) CreateStudent(ctx context.Context, name string, email string, age int, at time.Time) (id int64, err error) {
+	ctx, span := startSpan(ctx, "CreateStudent", insertStudentQuery)
+	defer func() { endSpan(span, err) }()
+
+	err = s.writeStudents(ctx, at, func(tx *sql.Tx) error {
+		res, err := s.txExec(ctx, tx, insertStudentQuery, name, email, age, at.UTC()) // inserting the data, on the statement prepared in New
+		if err != nil {
+			return writeError(err, "student")
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return err
+		}
+		return s.appendOutbox(ctx, tx, at, studentCreated(types.Student{Id: id, Name: name, Email: email, Age: age}, at))
+	})
+	return id, err
+}
+
+func (s *Sqlite) CreateStudents(ctx context.Context, students []types.Student) (ids []int64, err error) {
+	batch := s.insertBatch
+	if batch <= 0 {
+		batch = 500
+	}
+	ctx, span := startSpan(ctx, "CreateStudents", insertStudentsQuery(min(batch, len(students))))
+	defer func() { endSpan(span, err) }()
+
+	// one transaction for the whole batch, sqlite syncs to disk once instead of once per row
+	err = s.writeStudents(ctx, newest(students), func(tx *sql.Tx) error {
+		ids, err = s.insertStudents(ctx, tx, students)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// newest is the latest UpdatedAt of students, where students_changed moves to. they usually all have the same time
+func newest(students []types.Student) time.Time {
+	var at time.Time
+	for _, student := range students {
+		if student.UpdatedAt.After(at) {
+			at = student.UpdatedAt
+		}
+	}
+	return at
+}
+
+// insertStudents adds students in tx, the new ids come back in the same order
+func (s *Sqlite) insertStudents(ctx context.Context, tx *sql.Tx, students []types.Student) ([]int64, error) {
+	batch := s.insertBatch
+	if batch <= 0 {
+		batch = 500
+	}
+	// one INSERT with many VALUES per batch students, parsing and running a statement per row was most of the
+	// time of an import. the full size statement is the one New prepared, a shorter last batch gets its own
+	var full *sql.Stmt
+	ids := make([]int64, 0, len(students))
+	args := make([]any, 0, 4*min(batch, len(students)))
+	for start := 0; start < len(students); start += batch {
+		rows := students[start:min(start+batch, len(students))]
+		args = args[:0]
+		for _, student := range rows {
+			args = append(args, student.Name, student.Email, student.Age, student.UpdatedAt.UTC())
+		}
+		var (
+			res sql.Result
+			err error
+		)
+		if len(rows) == batch {
+			if full == nil {
+				if prepared := s.stmts[insertStudentsQuery(batch)]; prepared != nil {
+					full = tx.StmtContext(ctx, prepared)
+				} else if full, err = tx.PrepareContext(ctx, insertStudentsQuery(batch)); err != nil {
+					return nil, err
+				}
+				defer full.Close()
+			}
+			res, err = full.ExecContext(ctx, args...)
+		} else {
+			res, err = tx.ExecContext(ctx, insertStudentsQuery(len(rows)), args...)
+		}
+		if err != nil {
+			return nil, writeError(err, "student")
+		}
+		last, err := res.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		// the rows of one statement get ids one after the other (AUTOINCREMENT, and the transaction keeps other
+		// writers out), the last one is what LastInsertId says
+		for i := range rows {
+			ids = append(ids, last-int64(len(rows)-1-i))
+		}
+	}
+	for i, student := range students {
+		student.Id = ids[i]
+		if err := s.appendOutbox(ctx, tx, student.UpdatedAt, studentCreated(student, student.UpdatedAt)); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
 
-	return &Sqlite{
-		Db: db,
-	}, nil
+// insertStudentsQuery is insertStudentQuery with n rows of VALUES
+func insertStudentsQuery(n int) string {
+	if n <= 1 {
+		return insertStudentQuery
+	}
+	return insertStudentQuery + strings.Repeat(",(?,?,?,?)", n-1)
+}
+
+func (s *Sqlite) GetStudentById(ctx context.Context, id int64) (student types.Student, err error) {
+	ctx, span := startSpan(ctx, "GetStudentById", getStudentQuery)
+	defer func() { endSpan(span, err) }()
+
+	student, err = scanStudent(s.queryRow(ctx, getStudentQuery, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return types.Student{}, &storage.NotFoundError{Entity: "student", Key: fmt.Sprintf("id %d", id)}
+	}
+	if err != nil {
+		return types.Student{}, err
+	}
+	return student, nil
 }
 
-func (s *Sqlite) CreateStudent(name string, email string, age int) (int64, error) {
-	stmt, err := s.Db.Prepare("INSERT INTO students (name,email,age) VALUES(?,?,?)") //preparing the data
+func (s *Sqlite) ListStudents(ctx context.Context, q storage.StudentQuery) (students []types.Student, err error) {
+	query, args, err := listStudents(q)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	defer stmt.Close()
-	res, err := stmt.Exec(name, email, age) // inserting the data
+	ctx, span := startSpan(ctx, "ListStudents", query)
+	defer func() { endSpan(span, err) }()
+
+	rows, err := s.query(ctx, query, args...)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	defer rows.Close()
+
+	students = []types.Student{} // not nil, so an empty page is [] in json and not null
+	for rows.Next() {
+		student, err := scanStudent(rows)
+		if err != nil {
+			return nil, err
+		}
+		students = append(students, student)
+	}
+	return students, rows.Err()
+}
+
+// sortColumns are the ORDER BY of storage.StudentSorts, names sort without looking at case like the emails are compared
+var sortColumns = map[string]string{"id": "id", "name": "name COLLATE NOCASE", "age": "age"}
+
+// listStudents builds the query of one page, without filters and sorted by id it is listStudentsQuery (the one New
+// prepares)
+func listStudents(q storage.StudentQuery) (string, []any, error) {
+	var (
+		where []string
+		args  []any
+	)
+	if q.Name != "" {
+		where = append(where, "name LIKE ? ESCAPE '\\'")
+		args = append(args, "%"+likeEscaper.Replace(q.Name)+"%")
 	}
-	id, dbErr := res.LastInsertId()
+	if q.MinAge > 0 {
+		where = append(where, "age >= ?")
+		args = append(args, q.MinAge)
+	}
+	if q.MaxAge > 0 {
+		where = append(where, "age <= ?")
+		args = append(args, q.MaxAge)
+	}
+	sort, desc := strings.CutPrefix(q.Sort, "-")
+	if sort == "" {
+		sort = "id"
+	}
+	column, ok := sortColumns[sort]
+	if !ok {
+		return "", nil, fmt.Errorf("sqlite: can not sort students by %q", q.Sort)
+	}
+	if len(where) == 0 && column == "id" && !desc {
+		return listStudentsQuery, []any{q.Limit, q.Offset}, nil
+	}
+
+	var b strings.Builder
+	b.WriteString("SELECT " + studentColumns + " FROM students")
+	if len(where) > 0 {
+		b.WriteString(" WHERE " + strings.Join(where, " AND "))
+	}
+	order := "ASC"
+	if desc {
+		order = "DESC"
+	}
+	b.WriteString(" ORDER BY " + column + " " + order)
+	if column != "id" {
+		b.WriteString(", id " + order)
+	}
+	b.WriteString(" LIMIT ? OFFSET ?")
+	return b.String(), append(args, q.Limit, q.Offset), nil
+}
+
+// likeEscaper keeps % and _ of a name filter literal, LIKE would take them as wildcards
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (s *Sqlite) UpdateStudent(ctx context.Context, student types.Student) (err error) {
+	ctx, span := startSpan(ctx, "UpdateStudent", updateStudentQuery)
+	defer func() { endSpan(span, err) }()
+
+	return s.writeStudents(ctx, student.UpdatedAt, func(tx *sql.Tx) error {
+		res, err := s.txExec(ctx, tx, updateStudentQuery, student.Name, student.Email, student.Age, student.UpdatedAt.UTC(), student.Id)
+		if err != nil {
+			return writeError(err, "student")
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return &storage.NotFoundError{Entity: "student", Key: fmt.Sprintf("id %d", student.Id)}
+		}
+		event, err := events.NewStudentUpdated(student, student.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		return s.appendOutbox(ctx, tx, student.UpdatedAt, event)
+	})
+}
+
+func (s *Sqlite) DeleteStudent(ctx context.Context, id int64, at time.Time) (err error) {
+	ctx, span := startSpan(ctx, "DeleteStudent", deleteStudentQuery)
+	defer func() { endSpan(span, err) }()
+
+	return s.writeStudents(ctx, at, func(tx *sql.Tx) error {
+		res, err := s.txExec(ctx, tx, deleteStudentQuery, id)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return &storage.NotFoundError{Entity: "student", Key: fmt.Sprintf("id %d", id)}
+		}
+		event, err := events.NewStudentDeleted(id, at)
+		if err != nil {
+			return err
+		}
+		return s.appendOutbox(ctx, tx, at, event)
+	})
+}
+
+func (s *Sqlite) StudentsChangedAt(ctx context.Context) (at time.Time, err error) {
+	ctx, span := startSpan(ctx, "StudentsChangedAt", studentsChangedQuery)
+	defer func() { endSpan(span, err) }()
+
+	err = s.queryRow(ctx, studentsChangedQuery).Scan(&at)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil // no write since the table was made
+	}
+	return at, err
+}
+
+func (s *Sqlite) StudentEmailTaken(ctx context.Context, email string) (taken bool, err error) {
+	ctx, span := startSpan(ctx, "StudentEmailTaken", emailTakenQuery)
+	defer func() { endSpan(span, err) }()
+
+	err = s.queryRow(ctx, emailTakenQuery, email).Scan(&taken)
+	return taken, err
+}
+
+func (s *Sqlite) ExportStudents(ctx context.Context, afterId int64, fn func(student types.Student) error) (err error) {
+	ctx, span := startSpan(ctx, "ExportStudents", exportStudentsQuery)
+	defer func() { endSpan(span, err) }()
+
+	// QueryContext so the query is cancelled when the export time budget runs out
+	rows, err := s.query(ctx, exportStudentsQuery, afterId)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		student, err := scanStudent(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(student); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// warmTables are the other tables a request or a worker reads soon after startup, Warm reads one row of each
+var warmTables = []string{"users", "api_keys", "refresh_tokens", "jobs", "imports", "webhooks", "webhook_deliveries", "emails", "outbox"}
+
+// Warm runs the prepared reads of the requests once and reads one row of every main table, so the first request
+// does not pay for loading the schema and the first pages of the file. the arguments match at most one row
+func (s *Sqlite) Warm(ctx context.Context) error {
+	reads := []struct {
+		query string
+		args  []any
+	}{
+		{listStudentsQuery, []any{1, 0}},
+		{getStudentQuery, []any{1}},
+		{exportStudentsQuery, []any{math.MaxInt64}},
+		{emailTakenQuery, []any{""}},
+		{studentsChangedQuery, nil},
+	}
+	for _, read := range reads {
+		if err := drain(s.query(ctx, read.query, read.args...)); err != nil {
+			return fmt.Errorf("warm %q: %w", read.query, err)
+		}
+	}
+	for _, table := range warmTables {
+		if err := drain(s.Db.QueryContext(ctx, "SELECT * FROM "+table+" LIMIT 1")); err != nil {
+			return fmt.Errorf("warm %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// drain reads rows to the end and closes them, for reads that are only run for their side effects
+func drain(rows *sql.Rows, err error) error {
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	return rows.Err()
+}
+
+// Close releases the prepared statements and the db handle, called from the shutdown hooks
+func (s *Sqlite) Close() error {
+	for _, stmt := range s.stmts {
+		stmt.Close()
+	}
+	return s.Db.Close()
+}
+
+// Ping checks the database file can still be used
+func (s *Sqlite) Ping(ctx context.Context) error {
+	return s.Db.PingContext(ctx)
+}
 
-	if dbErr != nil {
-		return 0, dbErr
+// SchemaReady checks the tables New creates are really there
+func (s *Sqlite) SchemaReady(ctx context.Context) error {
+	var name string
+	err := s.Db.QueryRowContext(ctx, "SELECT name FROM sqlite_master WHERE type = 'table' AND name = 'students'").Scan(&name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("students table is missing")
 	}
-	return id, nil
+	return err
 }
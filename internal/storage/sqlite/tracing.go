@@ -0,0 +1,34 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/manishtomar-cpi/go-server/internal/storage/sqlite")
+
+// startSpan opens a client span for one db call, it nests under the request span that is in ctx
+func startSpan(ctx context.Context, operation string, query string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "sqlite."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "sqlite"),
+			attribute.String("db.operation", operation),
+			attribute.String("db.statement", query),
+		))
+}
+
+// endSpan marks the span failed for real errors, not found and a taken unique value are normal answers and not errors of the db
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, storage.ErrNotFound) && !errors.Is(err, storage.ErrDuplicate) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,65 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+// usernames are unique without looking at case, "Asha" can not register next to "asha"
+const createUsersTable = `CREATE TABLE IF NOT EXISTS users(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	username TEXT NOT NULL UNIQUE COLLATE NOCASE,
+	password_hash TEXT NOT NULL,
+	roles TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL
+)`
+
+const (
+	insertUserQuery     = "INSERT INTO users (username, password_hash, roles, created_at) VALUES(?,?,?,?)"
+	userByUsernameQuery = "SELECT id, username, password_hash, roles, created_at FROM users WHERE username = ?"
+	updatePasswordQuery = "UPDATE users SET password_hash = ? WHERE id = ?"
+)
+
+func (s *Sqlite) CreateUser(ctx context.Context, user types.User) (id int64, err error) {
+	ctx, span := startSpan(ctx, "CreateUser", insertUserQuery)
+	defer func() { endSpan(span, err) }()
+
+	res, err := s.Db.ExecContext(ctx, insertUserQuery, user.Username, user.PasswordHash, strings.Join(user.Roles, ","), user.CreatedAt.UTC())
+	if err != nil {
+		return 0, writeError(err, "user")
+	}
+	return res.LastInsertId()
+}
+
+func (s *Sqlite) UserByUsername(ctx context.Context, username string) (user types.User, err error) {
+	ctx, span := startSpan(ctx, "UserByUsername", userByUsernameQuery)
+	defer func() { endSpan(span, err) }()
+
+	var roles string
+	err = s.Db.QueryRowContext(ctx, userByUsernameQuery, username).Scan(&user.Id, &user.Username, &user.PasswordHash, &roles, &user.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return types.User{}, &storage.NotFoundError{Entity: "user", Key: fmt.Sprintf("username %q", username)}
+	}
+	if err != nil {
+		return types.User{}, err
+	}
+	user.Roles = []string{}
+	if roles != "" {
+		user.Roles = strings.Split(roles, ",")
+	}
+	return user, nil
+}
+
+func (s *Sqlite) UpdatePasswordHash(ctx context.Context, id int64, hash string) (err error) {
+	ctx, span := startSpan(ctx, "UpdatePasswordHash", updatePasswordQuery)
+	defer func() { endSpan(span, err) }()
+
+	_, err = s.Db.ExecContext(ctx, updatePasswordQuery, hash, id)
+	return err
+}
@@ -0,0 +1,217 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+const createWebhooksTable = `CREATE TABLE IF NOT EXISTS webhooks(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	events TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	deleted_at TIMESTAMP
+)`
+
+const createWebhookDeliveriesTable = `CREATE TABLE IF NOT EXISTS webhook_deliveries(
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	webhook_id INTEGER NOT NULL REFERENCES webhooks(id),
+	event_type TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	status TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_status INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL,
+	next_attempt_at TIMESTAMP,
+	delivered_at TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at)`
+
+const deliveryColumns = "id, webhook_id, event_type, payload, status, attempts, last_status, last_error, created_at, next_attempt_at, delivered_at"
+
+const (
+	insertWebhookQuery = "INSERT INTO webhooks (url, secret, events, created_at) VALUES(?,?,?,?)"
+	listWebhooksQuery  = "SELECT id, url, secret, events, created_at, deleted_at FROM webhooks WHERE deleted_at IS NULL ORDER BY id"
+	deleteWebhookQuery = "UPDATE webhooks SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL"
+	// events are stored comma joined, the commas around both sides make "student.created" not match "student.created.v2"
+	enqueueDeliveriesQuery = `INSERT INTO webhook_deliveries (webhook_id, event_type, payload, status, created_at, next_attempt_at)
+		SELECT id, ?, ?, 'pending', ?, ? FROM webhooks WHERE deleted_at IS NULL AND ',' || events || ',' LIKE '%,' || ? || ',%'
+		RETURNING id`
+	getDeliveryQuery    = "SELECT " + deliveryColumns + " FROM webhook_deliveries WHERE id = ?"
+	updateDeliveryQuery = "UPDATE webhook_deliveries SET status = ?, attempts = ?, last_status = ?, last_error = ?, next_attempt_at = ?, delivered_at = ? WHERE id = ?"
+	listDeliveriesQuery = "SELECT " + deliveryColumns + " FROM webhook_deliveries WHERE webhook_id = ? ORDER BY id DESC LIMIT ?"
+	// pending ones still have a job that sends them
+	purgeDeliveriesQuery = "DELETE FROM webhook_deliveries WHERE status <> 'pending' AND created_at < ?"
+)
+
+func (s *Sqlite) CreateWebhook(ctx context.Context, hook types.Webhook) (id int64, err error) {
+	ctx, span := startSpan(ctx, "CreateWebhook", insertWebhookQuery)
+	defer func() { endSpan(span, err) }()
+
+	res, err := s.Db.ExecContext(ctx, insertWebhookQuery, hook.URL, hook.Secret, strings.Join(hook.Events, ","), hook.CreatedAt.UTC())
+	if err != nil {
+		return 0, writeError(err, "webhook")
+	}
+	return res.LastInsertId()
+}
+
+func (s *Sqlite) ListWebhooks(ctx context.Context) (hooks []types.Webhook, err error) {
+	ctx, span := startSpan(ctx, "ListWebhooks", listWebhooksQuery)
+	defer func() { endSpan(span, err) }()
+
+	rows, err := s.Db.QueryContext(ctx, listWebhooksQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks = []types.Webhook{}
+	for rows.Next() {
+		var hook types.Webhook
+		var events string
+		var deleted sql.NullTime
+		if err := rows.Scan(&hook.Id, &hook.URL, &hook.Secret, &events, &hook.CreatedAt, &deleted); err != nil {
+			return nil, err
+		}
+		hook.Events = strings.Split(events, ",")
+		if deleted.Valid {
+			hook.DeletedAt = &deleted.Time
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, rows.Err()
+}
+
+func (s *Sqlite) DeleteWebhook(ctx context.Context, id int64, at time.Time) (err error) {
+	ctx, span := startSpan(ctx, "DeleteWebhook", deleteWebhookQuery)
+	defer func() { endSpan(span, err) }()
+
+	res, err := s.Db.ExecContext(ctx, deleteWebhookQuery, at.UTC(), id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return &storage.NotFoundError{Entity: "active webhook", Key: fmt.Sprintf("id %d", id)}
+	}
+	return nil
+}
+
+func (s *Sqlite) EnqueueDeliveries(ctx context.Context, eventType, payload string, at time.Time) (err error) {
+	ctx, span := startSpan(ctx, "EnqueueDeliveries", enqueueDeliveriesQuery)
+	defer func() { endSpan(span, err) }()
+
+	tx, err := s.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // no-op after Commit
+	rows, err := tx.QueryContext(ctx, enqueueDeliveriesQuery, eventType, payload, at.UTC(), at.UTC(), eventType)
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	// every new delivery gets the job that sends it, in the same transaction
+	for _, id := range ids {
+		if _, err := tx.ExecContext(ctx, insertJobQuery, types.DeliveryJob, strconv.FormatInt(id, 10), at.UTC(), at.UTC()); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *Sqlite) DeliveryById(ctx context.Context, id int64) (delivery types.WebhookDelivery, err error) {
+	ctx, span := startSpan(ctx, "DeliveryById", getDeliveryQuery)
+	defer func() { endSpan(span, err) }()
+
+	deliveries, err := s.queryDeliveries(ctx, getDeliveryQuery, id)
+	if err != nil {
+		return types.WebhookDelivery{}, err
+	}
+	if len(deliveries) == 0 {
+		return types.WebhookDelivery{}, &storage.NotFoundError{Entity: "webhook delivery", Key: fmt.Sprintf("id %d", id)}
+	}
+	return deliveries[0], nil
+}
+
+func (s *Sqlite) UpdateDelivery(ctx context.Context, d types.WebhookDelivery) (err error) {
+	ctx, span := startSpan(ctx, "UpdateDelivery", updateDeliveryQuery)
+	defer func() { endSpan(span, err) }()
+
+	_, err = s.Db.ExecContext(ctx, updateDeliveryQuery, d.Status, d.Attempts, d.LastStatus, d.LastError,
+		nullTime(d.NextAttemptAt), nullTime(d.DeliveredAt), d.Id)
+	return err
+}
+
+func (s *Sqlite) ListDeliveries(ctx context.Context, webhookId int64, limit int) (deliveries []types.WebhookDelivery, err error) {
+	ctx, span := startSpan(ctx, "ListDeliveries", listDeliveriesQuery)
+	defer func() { endSpan(span, err) }()
+
+	return s.queryDeliveries(ctx, listDeliveriesQuery, webhookId, limit)
+}
+
+// PurgeDeliveries deletes the delivered and failed deliveries created before before
+func (s *Sqlite) PurgeDeliveries(ctx context.Context, before time.Time) (n int64, err error) {
+	ctx, span := startSpan(ctx, "PurgeDeliveries", purgeDeliveriesQuery)
+	defer func() { endSpan(span, err) }()
+
+	return s.purge(ctx, purgeDeliveriesQuery, before)
+}
+
+func (s *Sqlite) queryDeliveries(ctx context.Context, query string, args ...any) ([]types.WebhookDelivery, error) {
+	rows, err := s.Db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []types.WebhookDelivery{}
+	for rows.Next() {
+		var d types.WebhookDelivery
+		var next, delivered sql.NullTime
+		if err := rows.Scan(&d.Id, &d.WebhookId, &d.EventType, &d.Payload, &d.Status, &d.Attempts, &d.LastStatus, &d.LastError,
+			&d.CreatedAt, &next, &delivered); err != nil {
+			return nil, err
+		}
+		if next.Valid {
+			d.NextAttemptAt = &next.Time
+		}
+		if delivered.Valid {
+			d.DeliveredAt = &delivered.Time
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// nullTime stores a nil time as NULL
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
@@ -1,5 +1,190 @@
 package storage
 
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+// ErrNotFound is returned by every backend when the asked row does not exist, so handlers can answer 404
+var ErrNotFound = errors.New("not found")
+
+// ErrDuplicate is returned when a unique value (a username, an email) is already taken, handlers answer 409
+var ErrDuplicate = errors.New("already exists")
+
+// ErrConflict is returned when a write breaks any other rule of the stored data, like pointing at a row that is gone. handlers answer 409
+var ErrConflict = errors.New("conflicts with stored data")
+
+// ErrLeaseLost is returned when a worker saves a job whose lock ran out and was claimed again, the other worker has it now
+var ErrLeaseLost = errors.New("job lease lost")
+
+// NotFoundError says what was looked up, errors.Is(err, ErrNotFound) holds for it.
+// backends return it instead of a bare ErrNotFound so the handlers can tell a missing student from a missing webhook
+type NotFoundError struct {
+	Entity string // student, user, webhook...
+	Key    string // how it was looked up, like "id 5", empty when the key is secret (a token hash)
+}
+
+func (e *NotFoundError) Error() string {
+	if e.Key == "" {
+		return e.Entity + ": " + ErrNotFound.Error()
+	}
+	return e.Entity + " with " + e.Key + ": " + ErrNotFound.Error()
+}
+
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// DuplicateError says which unique value is taken, errors.Is(err, ErrDuplicate) holds for it.
+// the value itself is left out, it is often personal data and ends up in logs
+type DuplicateError struct {
+	Entity string
+	Field  string // the unique column, empty when the backend can not tell
+}
+
+func (e *DuplicateError) Error() string {
+	if e.Field == "" {
+		return e.Entity + ": " + ErrDuplicate.Error()
+	}
+	return e.Entity + " with this " + e.Field + ": " + ErrDuplicate.Error()
+}
+
+func (e *DuplicateError) Is(target error) bool {
+	return target == ErrDuplicate
+}
+
+// StudentQuery is one page of ListStudents. Sort is one of StudentSorts, empty sorts by id. Name keeps the students
+// whose name contains it without looking at case, MinAge and MaxAge are left out when 0
+type StudentQuery struct {
+	Limit  int
+	Offset int
+	Sort   string
+	Name   string
+	MinAge int
+	MaxAge int
+}
+
+// StudentSorts are the orders ListStudents knows, a leading "-" is descending. ties are broken by id
+var StudentSorts = []string{"id", "-id", "name", "-name", "age", "-age"}
+
 type Storage interface {
-	CreateStudent(name string, email string, age int) (int64, error) // will return new added id and error also
+	// will return new added id and error also, at is kept as its UpdatedAt
+	CreateStudent(ctx context.Context, name string, email string, age int, at time.Time) (int64, error)
+	// CreateStudents adds all students or none of them, the new ids come back in the same order
+	CreateStudents(ctx context.Context, students []types.Student) ([]int64, error)
+	GetStudentById(ctx context.Context, id int64) (types.Student, error)
+	ListStudents(ctx context.Context, q StudentQuery) ([]types.Student, error)
+	UpdateStudent(ctx context.Context, student types.Student) error  // ErrNotFound when no student has student.Id
+	DeleteStudent(ctx context.Context, id int64, at time.Time) error // ErrNotFound when no student has this id
+	// StudentsChangedAt is the time of the last create, update or delete of any student, zero when there was none.
+	// the lists are sent with it as Last-Modified
+	StudentsChangedAt(ctx context.Context) (time.Time, error)
+	// emails are unique without looking at case, creating or updating a student to a taken one is a DuplicateError on "email"
+	StudentEmailTaken(ctx context.Context, email string) (bool, error)
+	// streams students with id > afterId in id order, one row at a time, so big exports never sit in memory. if fn returns an error iteration stops and that error is returned
+	ExportStudents(ctx context.Context, afterId int64, fn func(student types.Student) error) error
+}
+
+// APIKeyStore keeps the api keys, looked up by the sha256 of the key on every request that sends one
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, key types.APIKey) (int64, error)
+	ListAPIKeys(ctx context.Context) ([]types.APIKey, error)
+	APIKeyByHash(ctx context.Context, hash string) (types.APIKey, error) // ErrNotFound for unknown keys
+	RevokeAPIKey(ctx context.Context, id int64, at time.Time) error      // ErrNotFound when no active key has this id
+	TouchAPIKey(ctx context.Context, id int64, at time.Time) error       // sets last_used_at
+}
+
+// UserStore keeps the registered accounts
+type UserStore interface {
+	CreateUser(ctx context.Context, user types.User) (int64, error)          // ErrDuplicate when the username is taken
+	UserByUsername(ctx context.Context, username string) (types.User, error) // ErrNotFound for unknown users
+	UpdatePasswordHash(ctx context.Context, id int64, hash string) error
+}
+
+// RefreshTokenStore keeps the hashes of issued refresh tokens
+type RefreshTokenStore interface {
+	CreateRefreshToken(ctx context.Context, token types.RefreshToken) error
+	RefreshTokenByHash(ctx context.Context, hash string) (types.RefreshToken, error) // ErrNotFound for unknown tokens
+	// MarkRefreshTokenUsed sets used_at if it is not set yet, false means someone else used the token first
+	MarkRefreshTokenUsed(ctx context.Context, id int64, at time.Time) (bool, error)
+	RevokeRefreshFamily(ctx context.Context, family string, at time.Time) error
+}
+
+// WebhookStore keeps webhooks and the queue of their deliveries, the queue survives restarts
+type WebhookStore interface {
+	CreateWebhook(ctx context.Context, hook types.Webhook) (int64, error)
+	ListWebhooks(ctx context.Context) ([]types.Webhook, error)       // deleted ones are left out
+	DeleteWebhook(ctx context.Context, id int64, at time.Time) error // ErrNotFound when no active webhook has this id
+	// EnqueueDeliveries adds a pending delivery for every active webhook that wants eventType, and in the same
+	// transaction a types.DeliveryJob job for each of them -> no delivery is ever left without the job that sends it
+	EnqueueDeliveries(ctx context.Context, eventType, payload string, at time.Time) error
+	DeliveryById(ctx context.Context, id int64) (types.WebhookDelivery, error) // ErrNotFound for unknown ids
+	UpdateDelivery(ctx context.Context, delivery types.WebhookDelivery) error
+	ListDeliveries(ctx context.Context, webhookId int64, limit int) ([]types.WebhookDelivery, error)
+}
+
+// EmailStore is the outbox of notification emails
+type EmailStore interface {
+	// EnqueueEmail adds a pending email and in the same transaction the types.EmailJob job that sends it
+	EnqueueEmail(ctx context.Context, email types.Email) (int64, error)
+	EmailById(ctx context.Context, id int64) (types.Email, error) // ErrNotFound for unknown ids
+	UpdateEmail(ctx context.Context, email types.Email) error
+	ListEmails(ctx context.Context, status string, limit int) ([]types.Email, error) // newest first, empty status is all of them
+}
+
+// OutboxStore keeps the public events until the relay published them, oldest first. the student writes add them in
+// their own transaction
+type OutboxStore interface {
+	PendingOutbox(ctx context.Context, limit int) ([]types.OutboxEvent, error) // not delivered yet, in id order
+	MarkOutboxDelivered(ctx context.Context, ids []int64, at time.Time) error
+	// MarkOutboxFailed counts a failed publish on the events of ids, they stay pending
+	MarkOutboxFailed(ctx context.Context, ids []int64, lastError string) error
+	// OutboxBacklog is how many events are pending and when the oldest of them was written, zero when there is none
+	OutboxBacklog(ctx context.Context) (pending int64, oldest time.Time, err error)
+}
+
+// ImportStore keeps the imports the import job works through, their uploads are files and only the paths are here
+type ImportStore interface {
+	// CreateImport adds a pending import and in the same transaction the types.ImportJob job that runs it, the result
+	// has the ids of both
+	CreateImport(ctx context.Context, imp types.Import) (types.Import, error)
+	ImportById(ctx context.Context, id int64) (types.Import, error)     // ErrNotFound for unknown ids
+	ImportByJob(ctx context.Context, jobId int64) (types.Import, error) // ErrNotFound when no import has this job, without its upload
+	// ImportErrors hands the error report of an import to fn one json line at a time, in upload order
+	ImportErrors(ctx context.Context, importId int64, fn func(line []byte) error) error
+	// ImportBatch adds students and saves the progress of the import in one transaction -> a retried job never stores
+	// a record twice. progress has the new Status, Processed, Created, Failed and StartedAt, failures are added to
+	// its report. the new ids come back in the order of students
+	ImportBatch(ctx context.Context, progress types.Import, students []types.Student, failures []types.ImportFailure) ([]int64, error)
+	// UpdateImportStatus saves Status, LastError and FinishedAt, the upload path is dropped once the import is done. a
+	// failed one keeps it, its job may be requeued
+	UpdateImportStatus(ctx context.Context, imp types.Import) error
+	// PurgeImports deletes the finished imports created before before with their error reports, uploads are the
+	// files of those that still had one (failed imports)
+	PurgeImports(ctx context.Context, before time.Time) (n int64, uploads []string, err error)
+}
+
+// JobStore is the queue of background jobs, the rows survive restarts
+type JobStore interface {
+	EnqueueJob(ctx context.Context, job types.Job) (int64, error)
+	// ClaimJobs marks up to limit due jobs of kinds as running until lockedUntil and returns them, the earliest first.
+	// pending jobs whose run_at passed are due, and running ones whose lock ran out (their worker died)
+	ClaimJobs(ctx context.Context, kinds []string, now, lockedUntil time.Time, limit int) ([]types.Job, error)
+	// UpdateJob saves job for the worker that claimed it until lease, ErrLeaseLost when the job is locked until anything else
+	UpdateJob(ctx context.Context, job types.Job, lease time.Time) error
+	// FailJob saves job as failed for good and keeps a DeadJob of it, in one transaction and fenced like UpdateJob
+	FailJob(ctx context.Context, job types.Job, lease time.Time) error
+	ListDeadJobs(ctx context.Context, kind string, limit int) ([]types.DeadJob, error) // newest first, empty kind is all of them
+	DeadJobById(ctx context.Context, id int64) (types.DeadJob, error)
+	// RequeueDeadJob enqueues a new job with the kind and payload of dead job id and marks the dead job requeued, in
+	// one transaction. a dead job that was requeued before is an ErrConflict
+	RequeueDeadJob(ctx context.Context, id int64, now time.Time) (types.DeadJob, error)
+}
+
+// Warmer is implemented by backends that can do their slow first-time work (open connections, prepare statements) before traffic comes
+type Warmer interface {
+	Warm(ctx context.Context) error
 }
@@ -1,7 +1,188 @@
 package types
 
+import "time"
+
+// Student is a student as the server keeps it. what clients send is checked in the request types of internal/http/dto
 type Student struct {
-	Name  string `json:"name" validate:"required"`
-	Email string `json:"email" validate:"required,email"`
-	Age   int    `json:"age" validate:"required,gte=1,lte=100"`
+	Id    int64  `json:"id" xml:"id"`
+	Name  string `json:"name" xml:"name"`
+	Email string `json:"email" xml:"email"`
+	Age   int    `json:"age" xml:"age"`
+	// UpdatedAt is when it was created or last changed, zero for students from before it was kept. it goes out as
+	// the Last-Modified header and not in bodies
+	UpdatedAt time.Time `json:"-" xml:"-"`
+}
+
+// APIKey is a key for server-to-server calls. only the sha256 of the key is stored, the key itself is shown once on creation
+type APIKey struct {
+	Id         int64      `json:"id"`
+	Name       string     `json:"name" validate:"required,max=100"`
+	Prefix     string     `json:"prefix"` // first characters of the key, so people can tell their keys apart
+	Hash       string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// User is an account that registered through /api/v1/auth/register
+type User struct {
+	Id           int64     `json:"id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"-"` // bcrypt
+	Roles        []string  `json:"roles"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// RefreshToken is one link of a rotation chain. all tokens rotated from the same login share a Family,
+// so when a used token comes back (stolen and replayed) the whole chain can be revoked at once
+type RefreshToken struct {
+	Id        int64
+	Hash      string // sha256 of the token
+	Family    string
+	Subject   string
+	Kind      string
+	Roles     []string
+	Scopes    []string // set when the login asked for a narrower token, kept on every rotation
+	StudentID int64
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
+
+// Webhook is an outside url that gets the student events it asked for, signed with Secret
+type Webhook struct {
+	Id        int64      `json:"id"`
+	URL       string     `json:"url"`
+	Secret    string     `json:"-"` // hmac key, shown once on creation
+	Events    []string   `json:"events"`
+	CreatedAt time.Time  `json:"created_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// WebhookDelivery is one event for one webhook, with where its retries stand
+type WebhookDelivery struct {
+	Id            int64      `json:"id"`
+	WebhookId     int64      `json:"webhook_id"`
+	EventType     string     `json:"event_type"`
+	Payload       string     `json:"payload"`
+	Status        string     `json:"status"` // pending, delivered or failed
+	Attempts      int        `json:"attempts"`
+	LastStatus    int        `json:"last_status,omitempty"` // http status of the last attempt, 0 when it never got an answer
+	LastError     string     `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"` // nil once delivered or failed
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// DeliveryJob is the kind of the background job that sends one delivery, its payload is the delivery id
+const DeliveryJob = "webhook.deliver"
+
+// Email is one message of the outbox, rendered when it was queued so the log shows what was really sent
+type Email struct {
+	Id        int64      `json:"id"`
+	Template  string     `json:"template"` // welcome, enrollment...
+	To        string     `json:"to"`
+	Subject   string     `json:"subject"`
+	Body      string     `json:"-"`
+	Status    string     `json:"status"` // pending, sent or failed
+	Attempts  int        `json:"attempts"`
+	LastError string     `json:"last_error,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	SentAt    *time.Time `json:"sent_at,omitempty"`
 }
+
+const (
+	EmailPending = "pending"
+	EmailSent    = "sent"
+	EmailFailed  = "failed"
+)
+
+// EmailJob is the kind of the background job that sends one email, its payload is the email id
+const EmailJob = "email.send"
+
+// OutboxEvent is a public domain event waiting in the outbox for the relay to publish it to the sink
+type OutboxEvent struct {
+	Id          int64      `json:"id"` // grows with every event, consumers de-duplicate on it
+	EventType   string     `json:"event_type"`
+	Payload     string     `json:"payload"` // the event as events.Marshal wrote it
+	CreatedAt   time.Time  `json:"created_at"`
+	Attempts    int        `json:"attempts"`
+	LastError   string     `json:"last_error,omitempty"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+}
+
+// Job is one piece of background work, the handler registered for Kind runs it with Payload
+type Job struct {
+	Id          int64      `json:"id"`
+	Kind        string     `json:"kind"`
+	Payload     string     `json:"payload"`
+	Status      string     `json:"status"` // pending, running, done or failed
+	Attempts    int        `json:"attempts"`
+	LastError   string     `json:"last_error,omitempty"`
+	RunAt       time.Time  `json:"run_at"`                 // not before this, moved on by every retry
+	LockedUntil *time.Time `json:"locked_until,omitempty"` // while running, after it another worker may take the job over
+	CreatedAt   time.Time  `json:"created_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+const (
+	JobPending = "pending"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// DeadJob is a copy of a job that failed for good, kept until it is requeued or purged. the failed job itself may be
+// purged before it
+type DeadJob struct {
+	Id            int64      `json:"id"`
+	JobId         int64      `json:"job_id"`
+	Kind          string     `json:"kind"`
+	Payload       string     `json:"payload"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error"`
+	FailedAt      time.Time  `json:"failed_at"`
+	RequeuedAt    *time.Time `json:"requeued_at,omitempty"`
+	RequeuedJobId int64      `json:"requeued_job_id,omitempty"` // the job that runs it again
+}
+
+// Import is an upload of students that a background job stores, clients follow it by the id of its job. Status is the
+// same as the job's (pending, running, done or failed) but stays after done jobs are purged
+type Import struct {
+	Id         int64      `json:"id"`
+	JobId      int64      `json:"job_id"`
+	Format     string     `json:"format"` // csv or ndjson
+	CreatedBy  string     `json:"-"`      // kind:subject of the principal that uploaded it, only it and admins see the import
+	Upload     string     `json:"-"`      // path of the file with the upload, emptied once the import is done
+	Status     string     `json:"status"`
+	Total      int        `json:"total"`     // records in the upload, counted when it was accepted
+	Processed  int        `json:"processed"` // records read so far, a retried job goes on after them
+	Created    int        `json:"created"`
+	Failed     int        `json:"failed"`
+	LastError  string     `json:"last_error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// ImportFailure is one line of the error report of an import, Report is the json of the record that failed
+type ImportFailure struct {
+	Line   int
+	Report string
+}
+
+// ImportJob is the kind of the background job that runs one import, its payload is the import id
+const ImportJob = "student.import"
+
+const (
+	ImportCSV    = "csv"
+	ImportNDJSON = "ndjson"
+)
@@ -0,0 +1,28 @@
+// Package bufpool hands out byte buffers that are reused between requests. answers are built in a buffer before they
+// go out (to hash them, to wait for enough bytes to compress, to fail cleanly halfway), a new buffer per request grew
+// from zero every time and left it all to the garbage collector
+package bufpool
+
+import (
+	"bytes"
+	"sync"
+)
+
+// MaxSize is the biggest buffer Put keeps, the one huge export or page would otherwise pin its memory in the pool
+const MaxSize = 64 << 10
+
+var pool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// Get returns an empty buffer, give it back with Put once nothing refers to its bytes anymore
+func Get() *bytes.Buffer {
+	return pool.Get().(*bytes.Buffer)
+}
+
+// Put resets b and keeps it for the next Get, nil is ignored
+func Put(b *bytes.Buffer) {
+	if b == nil || b.Cap() > MaxSize {
+		return
+	}
+	b.Reset()
+	pool.Put(b)
+}
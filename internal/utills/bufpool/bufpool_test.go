@@ -0,0 +1,46 @@
+package bufpool_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/utills/bufpool"
+)
+
+func TestGetIsEmpty(t *testing.T) {
+	t.Parallel()
+
+	b := bufpool.Get()
+	b.WriteString("left over")
+	bufpool.Put(b)
+	bufpool.Put(nil) // ignored
+
+	for i := 0; i < 10; i++ {
+		if got := bufpool.Get(); got.Len() != 0 {
+			t.Fatalf("want an empty buffer, got %q", got)
+		}
+	}
+}
+
+func TestPutDropsBigBuffers(t *testing.T) {
+	t.Parallel()
+
+	big := bytes.NewBufferString(strings.Repeat("x", bufpool.MaxSize+1))
+	bufpool.Put(big)
+	if big.Len() == 0 {
+		t.Fatal("a buffer over MaxSize should not be reset and kept")
+	}
+}
+
+func BenchmarkGetPut(b *testing.B) {
+	chunk := []byte(strings.Repeat("x", 4096))
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			buf := bufpool.Get()
+			buf.Write(chunk)
+			bufpool.Put(buf)
+		}
+	})
+}
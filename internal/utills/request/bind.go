@@ -0,0 +1,129 @@
+package request
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/manishtomar-cpi/go-server/internal/errcode"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+	"github.com/manishtomar-cpi/go-server/internal/validation"
+)
+
+// DefaultMaxBody is how big a body Bind reads unless SetMaxBody says otherwise
+const DefaultMaxBody = 1 << 20
+
+var maxBody atomic.Int64
+
+func init() {
+	maxBody.Store(DefaultMaxBody)
+}
+
+// SetMaxBody sets how many bytes of a body Bind reads before it gives up with 413, 0 or less keeps DefaultMaxBody
+func SetMaxBody(n int64) {
+	if n <= 0 {
+		n = DefaultMaxBody
+	}
+	maxBody.Store(n)
+}
+
+// ErrBodyTooLarge is the error of a body bigger than the limit of SetMaxBody
+var ErrBodyTooLarge = errors.New("request body too large")
+
+// BindError is why Bind failed, with the answer for it
+type BindError struct {
+	Status   int
+	Response response.Response
+	Err      error
+}
+
+func (e *BindError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// Write answers the request with the error
+func (e *BindError) Write(w http.ResponseWriter) {
+	response.WriteJson(w, e.Status, e.Response)
+}
+
+// Bind reads the body of r into a T in the media type it came in (see Decode), at most SetMaxBody bytes of it, and
+// checks it against the validate tags of T. that is the whole start of a handler ->
+//
+//	req, bindErr := request.Bind[dto.CreateStudentRequest](r)
+//	if bindErr != nil {
+//		bindErr.Write(w)
+//		return
+//	}
+//
+// only structs are validated, a T that is a slice is left to the handler so one bad item does not fail the others
+func Bind[T any](r *http.Request) (T, *BindError) {
+	var v T
+	r.Body = http.MaxBytesReader(nil, r.Body, maxBody.Load())
+	if err := Decode(r, &v); err != nil {
+		return v, bindError(err)
+	}
+	return v, check(v)
+}
+
+// BindJSON is Bind for the endpoints that only take json, whatever the Content-Type says
+func BindJSON[T any](r *http.Request) (T, *BindError) {
+	var v T
+	r.Body = http.MaxBytesReader(nil, r.Body, maxBody.Load())
+	if err := DecodeJSON(r, &v); err != nil {
+		return v, bindError(err)
+	}
+	return v, check(v)
+}
+
+func check(v any) *BindError {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	if err := validation.Struct(v); err != nil {
+		return bindError(err)
+	}
+	return nil
+}
+
+// bindError picks the answer for an error of Decode, Query or the validator -> 413 for a body over the limit, 415 for
+// a content type nothing can read, 400 naming the field for an unknown one, a bad query param or a failed validation
+// and 400 for an empty or broken body
+func bindError(err error) *BindError {
+	var (
+		tooLarge *http.MaxBytesError
+		unknown  *UnknownFieldError
+		bad      *QueryError
+		invalid  validator.ValidationErrors
+	)
+	switch {
+	case errors.As(err, &tooLarge):
+		return coded(errcode.PayloadTooLarge, ErrBodyTooLarge)
+	case errors.Is(err, ErrUnsupportedMediaType):
+		return coded(errcode.UnsupportedMediaType, err)
+	case errors.Is(err, io.EOF):
+		return coded(errcode.InvalidRequest, ErrEmptyBody)
+	case errors.As(err, &unknown):
+		return &BindError{Status: http.StatusBadRequest, Response: unknown.Response(), Err: err}
+	case errors.As(err, &bad):
+		return &BindError{Status: http.StatusBadRequest, Response: bad.Response(), Err: err}
+	case errors.As(err, &invalid):
+		return &BindError{Status: http.StatusBadRequest, Response: response.ValidationError(invalid), Err: err}
+	default:
+		return coded(errcode.InvalidRequest, err)
+	}
+}
+
+func coded(code errcode.Code, err error) *BindError {
+	return &BindError{Status: code.Status(), Response: response.CodedError(code, err), Err: err}
+}
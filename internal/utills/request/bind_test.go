@@ -0,0 +1,92 @@
+package request_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/errcode"
+	"github.com/manishtomar-cpi/go-server/internal/utills/request"
+)
+
+type createBody struct {
+	Name string `json:"name" validate:"required"`
+	Age  int    `json:"age" validate:"gte=1,lte=100"`
+}
+
+func TestBind(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int // 0 when Bind should work
+		wantCode    errcode.Code
+	}
+
+	tests := []testCase{
+		{name: "ok", body: `{"name":"Asha","age":21}`},
+		{name: "empty", body: ``, wantStatus: http.StatusBadRequest, wantCode: errcode.InvalidRequest},
+		{name: "broken", body: `{"name":`, wantStatus: http.StatusBadRequest, wantCode: errcode.InvalidRequest},
+		{name: "unknown_field", body: `{"name":"Asha","age":21,"id":7}`, wantStatus: http.StatusBadRequest, wantCode: errcode.UnknownField},
+		{name: "invalid", body: `{"name":"Asha","age":120}`, wantStatus: http.StatusBadRequest, wantCode: errcode.ValidationFailed},
+		{name: "unsupported", contentType: "text/plain", body: `Asha`, wantStatus: http.StatusUnsupportedMediaType, wantCode: errcode.UnsupportedMediaType},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/students", strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				r.Header.Set("Content-Type", tc.contentType)
+			}
+			got, bindErr := request.Bind[createBody](r)
+			if tc.wantStatus == 0 {
+				if bindErr != nil || got != (createBody{Name: "Asha", Age: 21}) {
+					t.Fatalf("want the body, got %+v %v", got, bindErr)
+				}
+				return
+			}
+			if bindErr == nil || bindErr.Status != tc.wantStatus || bindErr.Response.Code != tc.wantCode {
+				t.Fatalf("want %d %s, got %+v", tc.wantStatus, tc.wantCode, bindErr)
+			}
+		})
+	}
+}
+
+func TestBindSliceIsNotValidated(t *testing.T) {
+	t.Parallel()
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/students/bulk", strings.NewReader(`[{"name":"","age":0}]`))
+	got, bindErr := request.Bind[[]createBody](r)
+	if bindErr != nil || len(got) != 1 {
+		t.Fatalf("want the items left to the handler, got %+v %v", got, bindErr)
+	}
+}
+
+// not parallel, the limit is global
+func TestBindMaxBody(t *testing.T) {
+	request.SetMaxBody(16)
+	t.Cleanup(func() { request.SetMaxBody(0) })
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/students", strings.NewReader(`{"name":"Asha Bhosle","age":21}`))
+	_, bindErr := request.Bind[createBody](r)
+	if bindErr == nil || bindErr.Status != http.StatusRequestEntityTooLarge || bindErr.Response.Code != errcode.PayloadTooLarge {
+		t.Fatalf("want 413 PAYLOAD_TOO_LARGE, got %+v", bindErr)
+	}
+}
+
+func BenchmarkBind(b *testing.B) {
+	body := `{"name":"Asha","age":21}`
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/students", strings.NewReader(body))
+		if _, bindErr := request.Bind[createBody](r); bindErr != nil {
+			b.Fatal(bindErr)
+		}
+	}
+}
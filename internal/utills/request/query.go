@@ -0,0 +1,107 @@
+package request
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/manishtomar-cpi/go-server/internal/errcode"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+	"github.com/manishtomar-cpi/go-server/internal/validation"
+)
+
+// QueryError is a query param that does not parse into its field -> ?limit=ten
+type QueryError struct {
+	Param string
+	Value string
+	Rule  string // the validator rule it broke, "numeric" or "boolean", so the message is translated like one
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("%s must be a %s value, got %q", e.Param, e.Rule, e.Value)
+}
+
+// Response is the 400 for the param, listed like a failed validation
+func (e *QueryError) Response() response.Response {
+	resp := response.CodedError(errcode.InvalidRequest, e)
+	resp.Fields = []response.FieldError{{Field: e.Param, Rule: e.Rule, Message: e.Error()}}
+	return resp
+}
+
+// Query fills the fields of v (a pointer to a struct) tagged `query:"name"` from the query string of r. a param that
+// is not sent gets the `default:"..."` of its field, then v goes through the shared validator like a body ->
+//
+//	type ListQuery struct {
+//		Limit int    `query:"limit" default:"50" validate:"gte=1,lte=500"`
+//		Sort  string `query:"sort" default:"id" validate:"oneof=id -id"`
+//	}
+//
+// strings, numbers, bools and comma separated []string are supported. the error is a *QueryError or
+// validator.ValidationErrors, WriteError answers both with a 400 naming the param
+func Query(r *http.Request, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("request.Query: want a pointer to a struct, got %T", v)
+	}
+	values := r.URL.Query()
+	rv = rv.Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		name := f.Tag.Get("query")
+		if name == "" || !f.IsExported() {
+			continue
+		}
+		raw, ok := values.Get(name), values.Has(name)
+		if !ok || raw == "" {
+			if raw, ok = f.Tag.Lookup("default"); !ok {
+				continue
+			}
+		}
+		if err := setQuery(rv.Field(i), name, raw); err != nil {
+			return err
+		}
+	}
+	return validation.Struct(v)
+}
+
+func setQuery(field reflect.Value, name, raw string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, field.Type().Bits())
+		if err != nil {
+			return &QueryError{Param: name, Value: raw, Rule: "numeric"}
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, field.Type().Bits())
+		if err != nil {
+			return &QueryError{Param: name, Value: raw, Rule: "numeric"}
+		}
+		field.SetUint(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return &QueryError{Param: name, Value: raw, Rule: "boolean"}
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("request.Query: %s: only []string slices are supported", name)
+		}
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("request.Query: %s: %s fields are not supported", name, field.Kind())
+	}
+	return nil
+}
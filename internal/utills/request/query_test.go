@@ -0,0 +1,83 @@
+package request_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/manishtomar-cpi/go-server/internal/utills/request"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+type listQuery struct {
+	Limit  int      `query:"limit" default:"50" validate:"gte=1,lte=500"`
+	Offset int      `query:"offset" validate:"gte=0"`
+	Sort   string   `query:"sort" default:"id" validate:"oneof=id -id name"`
+	Tags   []string `query:"tags"`
+	Active bool     `query:"active"`
+}
+
+func TestQuery(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name      string
+		query     string
+		want      listQuery
+		wantParam string // the param the 400 names
+		wantRule  string
+	}
+
+	tests := []testCase{
+		{name: "defaults", want: listQuery{Limit: 50, Sort: "id"}},
+		{name: "empty_is_default", query: "limit=", want: listQuery{Limit: 50, Sort: "id"}},
+		{name: "all_set", query: "limit=10&offset=20&sort=-id&tags=a,+b,,c&active=true",
+			want: listQuery{Limit: 10, Offset: 20, Sort: "-id", Tags: []string{"a", "b", "c"}, Active: true}},
+		{name: "not_a_number", query: "limit=ten", wantParam: "limit", wantRule: "numeric"},
+		{name: "not_a_bool", query: "active=yes", wantParam: "active", wantRule: "boolean"},
+		{name: "limit_too_big", query: "limit=501", wantParam: "limit", wantRule: "lte"},
+		{name: "negative_offset", query: "offset=-1", wantParam: "offset", wantRule: "gte"},
+		{name: "unknown_sort", query: "sort=email", wantParam: "sort", wantRule: "oneof"},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/students?"+tc.query, nil)
+			var got listQuery
+			err := request.Query(r, &got)
+			if tc.wantParam == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !reflect.DeepEqual(got, tc.want) {
+					t.Fatalf("want %+v, got %+v", tc.want, got)
+				}
+				return
+			}
+
+			var bad *request.QueryError
+			var invalid validator.ValidationErrors
+			if !errors.As(err, &bad) && !errors.As(err, &invalid) {
+				t.Fatalf("want a query or validation error, got %v", err)
+			}
+			rr := httptest.NewRecorder()
+			rr.Header().Set(response.ProblemHeader, "/api/v1/students") // what the problem details middleware sets
+			request.WriteError(rr, err)
+			var problem response.Problem
+			if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if rr.Code != http.StatusBadRequest || len(problem.Errors) != 1 ||
+				problem.Errors[0].Field != tc.wantParam || problem.Errors[0].Rule != tc.wantRule {
+				t.Fatalf("want 400 naming %s (%s), got %d %s", tc.wantParam, tc.wantRule, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+// Package request reads request bodies in the media type the client sent them in,
+// the decoding side of the content negotiation in the response package
+package request
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sync"
+
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+// Decoder reads one request body into v
+type Decoder interface {
+	Decode(r io.Reader, v any) error
+}
+
+type DecoderFunc func(r io.Reader, v any) error
+
+func (f DecoderFunc) Decode(r io.Reader, v any) error {
+	return f(r, v)
+}
+
+// ErrUnsupportedMediaType is returned by Decode for a Content-Type nothing is registered for, handlers answer it with 415
+var ErrUnsupportedMediaType = errors.New("unsupported content type")
+
+var decoders = struct {
+	mu sync.RWMutex
+	m  map[string]Decoder
+}{m: map[string]Decoder{}}
+
+func init() {
+	Register(response.JSON, DecoderFunc(decodeJSON))
+	Register(response.MsgPack, DecoderFunc(decodeMsgPack))
+}
+
+// Register adds the decoder for a media type, or replaces the one already there
+func Register(mediaType string, dec Decoder) {
+	decoders.mu.Lock()
+	defer decoders.mu.Unlock()
+	decoders.m[mediaType] = dec
+}
+
+// Decode reads the body of r into v with the decoder of its Content-Type, a request without one is read as json.
+// an empty body is io.EOF for every media type, so handlers can tell it from a broken one
+func Decode(r *http.Request, v any) error {
+	mediaType := response.JSON
+	if header := r.Header.Get("Content-Type"); header != "" {
+		parsed, _, err := mime.ParseMediaType(header)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrUnsupportedMediaType, header)
+		}
+		mediaType = parsed
+	}
+
+	decoders.mu.RLock()
+	dec, ok := decoders.m[mediaType]
+	decoders.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnsupportedMediaType, mediaType)
+	}
+	return dec.Decode(r.Body, v)
+}
@@ -0,0 +1,136 @@
+package request_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/types"
+	"github.com/manishtomar-cpi/go-server/internal/utills/request"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestDecode(t *testing.T) {
+	t.Parallel()
+
+	// a msgpack client only knows the json names of the fields
+	packed, err := msgpack.Marshal(map[string]any{"name": "Asha", "email": "asha@example.com", "age": 21})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	want := types.Student{Name: "Asha", Email: "asha@example.com", Age: 21}
+
+	type testCase struct {
+		name        string
+		contentType string
+		body        []byte
+		want        types.Student
+		wantErr     error
+	}
+
+	tests := []testCase{
+		{name: "json", contentType: "application/json; charset=utf-8", body: []byte(`{"name":"Asha","email":"asha@example.com","age":21}`), want: want},
+		{name: "no_content_type_is_json", body: []byte(`{"name":"Asha","email":"asha@example.com","age":21}`), want: want},
+		{name: "msgpack", contentType: "application/msgpack", body: packed, want: want},
+		{name: "empty_msgpack_is_eof", contentType: "application/msgpack", wantErr: io.EOF},
+		{name: "empty_json_is_eof", contentType: "application/json", wantErr: io.EOF},
+		{name: "unknown_type", contentType: "text/plain", body: []byte("Asha"), wantErr: request.ErrUnsupportedMediaType},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/students", bytes.NewReader(tc.body))
+			if tc.contentType != "" {
+				r.Header.Set("Content-Type", tc.contentType)
+			}
+			var got types.Student
+			err := request.Decode(r, &got)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("want error %v, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("want %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDecodeUnknownField(t *testing.T) {
+	t.Parallel()
+
+	packed, err := msgpack.Marshal(map[string]any{"name": "Asha", "emial": "asha@example.com"})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	type testCase struct {
+		name        string
+		contentType string
+		body        []byte
+	}
+
+	tests := []testCase{
+		{name: "json", contentType: "application/json", body: []byte(`{"name":"Asha","emial":"asha@example.com"}`)},
+		{name: "msgpack", contentType: "application/msgpack", body: packed},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/students", bytes.NewReader(tc.body))
+			r.Header.Set("Content-Type", tc.contentType)
+			var got types.Student
+			err := request.Decode(r, &got)
+			var unknown *request.UnknownFieldError
+			if !errors.As(err, &unknown) || unknown.Field != "emial" {
+				t.Fatalf("want unknown field emial, got %v", err)
+			}
+
+			rr := httptest.NewRecorder()
+			request.WriteError(rr, err)
+			if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), `"code":"UNKNOWN_FIELD"`) {
+				t.Fatalf("want 400 UNKNOWN_FIELD, got %d %s", rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	t.Parallel()
+
+	var got types.Student
+	if err := request.Unmarshal([]byte(`{"name":"Asha"} {"name":"Ravi"}`), &got); err == nil {
+		t.Fatal("want an error for data after the value")
+	}
+	var unknown *request.UnknownFieldError
+	if err := request.Unmarshal([]byte(`{"name":"Asha","nmae":"Ravi"}`), &got); !errors.As(err, &unknown) || unknown.Field != "nmae" {
+		t.Fatalf("want unknown field nmae, got %v", err)
+	}
+}
+
+// not parallel, lenient is global
+func TestLenient(t *testing.T) {
+	request.SetLenient(true)
+	t.Cleanup(func() { request.SetLenient(false) })
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/students", strings.NewReader(`{"name":"Asha","emial":"asha@example.com"}`))
+	var got types.Student
+	if err := request.Decode(r, &got); err != nil || got.Name != "Asha" {
+		t.Fatalf("want the extra field dropped, got %+v %v", got, err)
+	}
+}
@@ -0,0 +1,106 @@
+package request
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/manishtomar-cpi/go-server/internal/errcode"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// lenient turns the unknown field check off, see SetLenient
+var lenient atomic.Bool
+
+// SetLenient lets bodies carry fields the target type does not have, they are dropped like encoding/json does by default.
+// off unless the decoding config turns it on -> a typo like "emial" is a 400 and not a student without an email
+func SetLenient(on bool) {
+	lenient.Store(on)
+}
+
+// ErrEmptyBody is what WriteError says for a body with nothing in it
+var ErrEmptyBody = errors.New("empty body")
+
+// UnknownFieldError is a body with a field the target type does not have
+type UnknownFieldError struct {
+	Field string
+}
+
+func (e *UnknownFieldError) Error() string {
+	return fmt.Sprintf("unknown field %q", e.Field)
+}
+
+// Response is the 400 for the field, with the field in the list of invalid ones like a failed validation
+func (e *UnknownFieldError) Response() response.Response {
+	resp := response.CodedError(errcode.UnknownField, e)
+	resp.Fields = []response.FieldError{{Field: e.Field, Rule: "unknown", Message: e.Error()}}
+	return resp
+}
+
+func decodeJSON(r io.Reader, v any) error {
+	dec := json.NewDecoder(r)
+	if !lenient.Load() {
+		dec.DisallowUnknownFields()
+	}
+	return unknownField(dec.Decode(v), "json: unknown field ")
+}
+
+func decodeMsgPack(r io.Reader, v any) error {
+	dec := msgpack.NewDecoder(r)
+	dec.SetCustomStructTag("json") // same field names as the json body, no msgpack tags needed on the types
+	dec.DisallowUnknownFields(!lenient.Load())
+	return unknownField(dec.Decode(v), "msgpack: unknown field ")
+}
+
+// unknownField turns the unknown field error of a decoder into an UnknownFieldError, the decoders only have it as text
+func unknownField(err error, prefix string) error {
+	if err == nil {
+		return nil
+	}
+	if quoted, ok := strings.CutPrefix(err.Error(), prefix); ok {
+		if field, uerr := strconv.Unquote(quoted); uerr == nil {
+			return &UnknownFieldError{Field: field}
+		}
+	}
+	return err
+}
+
+// DecodeJSON reads the body of r as json whatever its Content-Type says, for the endpoints that only take json
+func DecodeJSON(r *http.Request, v any) error {
+	return decodeJSON(r.Body, v)
+}
+
+// Unmarshal is json.Unmarshal with the unknown field check of Decode, for json that does not come as a whole body
+// (one line of an ndjson upload)
+func Unmarshal(data []byte, v any) error {
+	if !json.Valid(data) { // the decoder would stop after the first value, this way anything after it is an error too
+		return json.Unmarshal(data, v)
+	}
+	return decodeJSON(bytes.NewReader(data), v)
+}
+
+// WriteError answers a failed Decode or Query, with the same answers as a failed Bind
+func WriteError(w http.ResponseWriter, err error) {
+	bindError(err).Write(w)
+}
+
+// WriteShapeError is WriteError for small fixed bodies -> a broken or empty one (or err nil, when the caller found the
+// body incomplete) is answered with shape, what the body has to look like
+func WriteShapeError(w http.ResponseWriter, err error, shape string) {
+	var (
+		unknown  *UnknownFieldError
+		tooLarge *http.MaxBytesError
+	)
+	if errors.As(err, &unknown) || errors.As(err, &tooLarge) || errors.Is(err, ErrUnsupportedMediaType) {
+		WriteError(w, err)
+		return
+	}
+	response.WriteError(w, errcode.InvalidRequest, errors.New(shape))
+}
@@ -0,0 +1,43 @@
+package response
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+
+	"github.com/manishtomar-cpi/go-server/internal/utills/bufpool"
+)
+
+// encoder is a buffer with a json encoder writing into it. bodies are encoded into the buffer before the status goes
+// out, both are reused between requests so a request neither grows a new buffer nor sets up a new encoder. the
+// encoder is tied to its buffer, so it has its own pool and does not take buffers from bufpool
+type encoder struct {
+	buf  bytes.Buffer
+	json *json.Encoder
+}
+
+var encoderPool = sync.Pool{New: func() any {
+	e := new(encoder)
+	e.json = json.NewEncoder(&e.buf)
+	return e
+}}
+
+// encode writes v and a newline like json.Encoder does, with the generated marshaler of v when the build has them
+func (e *encoder) encode(v any) error {
+	if ok, err := encodeGenerated(&e.buf, v); ok {
+		return err
+	}
+	return e.json.Encode(v)
+}
+
+func getEncoder() *encoder {
+	return encoderPool.Get().(*encoder)
+}
+
+func putEncoder(e *encoder) {
+	if e.buf.Cap() > bufpool.MaxSize {
+		return
+	}
+	e.buf.Reset()
+	encoderPool.Put(e)
+}
@@ -0,0 +1,78 @@
+package response
+
+import (
+	"net/http"
+
+	"github.com/manishtomar-cpi/go-server/internal/errcode"
+)
+
+// BulkItem is the outcome of one item of a bulk request, the items come back in the order they were sent
+type BulkItem struct {
+	Index  int          `json:"index"`
+	Status int          `json:"status"` // what the item would have gotten on its own request -> 201, 400, 409...
+	ID     int64        `json:"id,omitempty"`
+	Code   errcode.Code `json:"code,omitempty"`
+	Error  string       `json:"error,omitempty"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// BulkSummary counts the items of a bulk answer, it goes in meta.bulk
+type BulkSummary struct {
+	Total     int `json:"total"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+}
+
+// Bulk collects the outcome of every item of a bulk create/update/delete, WriteBulk sends it.
+// every bulk endpoint answers through it, so they all look and behave the same
+type Bulk struct {
+	items []BulkItem
+	errs  []Response // same index as items, Status is empty for the items that worked
+}
+
+// OK records an item that worked, id is the resource it created or changed (0 to leave it out)
+func (b *Bulk) OK(index, status int, id int64) {
+	b.items = append(b.items, BulkItem{Index: index, Status: status, ID: id})
+	b.errs = append(b.errs, Response{})
+}
+
+// Fail records an item that did not work, resp is the error body the item would have gotten on its own
+// (GeneralError, CodedError, ValidationError) and status its status, the status of the code wins when resp has one
+func (b *Bulk) Fail(index, status int, resp Response) {
+	if resp.Code != "" {
+		status = resp.Code.Status()
+	}
+	resp.Status = StatusError
+	b.items = append(b.items, BulkItem{Index: index, Status: status})
+	b.errs = append(b.errs, resp)
+}
+
+// WriteBulk answers 200 when every item worked and 207 Multi-Status when one or more failed, the client has to look
+// at the items then. item errors are coded and translated like any other error body
+func WriteBulk(w http.ResponseWriter, r *http.Request, b *Bulk) error {
+	lang := w.Header().Get(LanguageHeader)
+	items := make([]BulkItem, len(b.items))
+	summary := BulkSummary{Total: len(b.items)}
+	for i, item := range b.items {
+		resp := b.errs[i]
+		if resp.Status != StatusError {
+			summary.Succeeded++
+			items[i] = item
+			continue
+		}
+		summary.Failed++
+		if resp.Code == "" {
+			resp.Code = errcode.ForStatus(item.Status)
+		}
+		if lang != "" {
+			resp = localize(resp, lang)
+		}
+		item.Code, item.Error, item.Fields = resp.Code, resp.Error, resp.Fields
+		items[i] = item
+	}
+	status := http.StatusOK
+	if summary.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	return Write(w, r, status, Envelope{Data: items, Meta: Meta{Bulk: &summary}})
+}
@@ -0,0 +1,90 @@
+package response_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/errcode"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+func TestWriteBulk(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name       string
+		fill       func(b *response.Bulk)
+		wantStatus int
+		wantItems  []response.BulkItem
+		wantBulk   response.BulkSummary
+	}
+
+	tests := []testCase{
+		{
+			name: "all_worked",
+			fill: func(b *response.Bulk) {
+				b.OK(0, http.StatusCreated, 7)
+				b.OK(1, http.StatusCreated, 8)
+			},
+			wantStatus: http.StatusOK,
+			wantItems: []response.BulkItem{
+				{Index: 0, Status: http.StatusCreated, ID: 7},
+				{Index: 1, Status: http.StatusCreated, ID: 8},
+			},
+			wantBulk: response.BulkSummary{Total: 2, Succeeded: 2},
+		},
+		{
+			name: "some_failed",
+			fill: func(b *response.Bulk) {
+				b.OK(0, http.StatusNoContent, 0)
+				b.Fail(1, http.StatusInternalServerError, response.CodedError(errcode.StudentNotFound, errors.New("student with id 9: not found")))
+				b.Fail(2, http.StatusBadRequest, response.GeneralError(errors.New("bad student")))
+			},
+			wantStatus: http.StatusMultiStatus,
+			wantItems: []response.BulkItem{
+				{Index: 0, Status: http.StatusNoContent},
+				{Index: 1, Status: http.StatusNotFound, Code: errcode.StudentNotFound, Error: "student with id 9: not found"},
+				{Index: 2, Status: http.StatusBadRequest, Code: errcode.InvalidRequest, Error: "bad student"},
+			},
+			wantBulk: response.BulkSummary{Total: 3, Succeeded: 1, Failed: 2},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var b response.Bulk
+			tc.fill(&b)
+			rr := httptest.NewRecorder()
+			if err := response.WriteBulk(rr, httptest.NewRequest(http.MethodPost, "/api/v1/students/bulk", nil), &b); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("status: want %d, got %d", tc.wantStatus, rr.Code)
+			}
+			var got struct {
+				Data []response.BulkItem `json:"data"`
+				Meta response.Meta       `json:"meta"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if len(got.Data) != len(tc.wantItems) {
+				t.Fatalf("want %d items, got %s", len(tc.wantItems), rr.Body.String())
+			}
+			for i, want := range tc.wantItems {
+				if g := got.Data[i]; g.Index != want.Index || g.Status != want.Status || g.ID != want.ID || g.Code != want.Code || g.Error != want.Error {
+					t.Fatalf("item %d: want %+v, got %+v", i, want, g)
+				}
+			}
+			if got.Meta.Bulk == nil || *got.Meta.Bulk != tc.wantBulk {
+				t.Fatalf("summary: want %+v, got %+v", tc.wantBulk, got.Meta.Bulk)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package response
+
+import (
+	"net/http"
+	"time"
+)
+
+// NotModified sends modified as Last-Modified and answers 304 when the If-Modified-Since of the client is not older,
+// true means the answer is written and the handler is done. a zero modified (nothing known) sends neither.
+// a client that also sends If-None-Match is left to the ETag of the Cache middleware, RFC 9110 has it win. http dates
+// have whole seconds, a change in the same second as the copy of the client is only seen by the ETag
+func NotModified(w http.ResponseWriter, r *http.Request, modified time.Time) bool {
+	if modified.IsZero() {
+		return false
+	}
+	modified = modified.UTC().Truncate(time.Second) // http dates have whole seconds
+	w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+	if (r.Method != http.MethodGet && r.Method != http.MethodHead) || r.Header.Get("If-None-Match") != "" {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || modified.After(since) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
@@ -0,0 +1,69 @@
+package response_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+func TestNotModified(t *testing.T) {
+	t.Parallel()
+
+	modified := time.Date(2025, 3, 1, 10, 0, 0, 500_000_000, time.UTC) // the fraction is dropped, http dates have none
+	lastModified := "Sat, 01 Mar 2025 10:00:00 GMT"
+
+	type testCase struct {
+		name             string
+		method           string
+		modified         time.Time
+		header           map[string]string
+		wantNotModified  bool
+		wantLastModified string
+	}
+
+	tests := []testCase{
+		{name: "no_condition", modified: modified, wantLastModified: lastModified},
+		{name: "same_time", modified: modified, header: map[string]string{"If-Modified-Since": lastModified},
+			wantNotModified: true, wantLastModified: lastModified},
+		{name: "client_newer", modified: modified, header: map[string]string{"If-Modified-Since": "Sat, 01 Mar 2025 11:00:00 GMT"},
+			wantNotModified: true, wantLastModified: lastModified},
+		{name: "changed_since", modified: modified, header: map[string]string{"If-Modified-Since": "Sat, 01 Mar 2025 09:59:59 GMT"},
+			wantLastModified: lastModified},
+		{name: "bad_date", modified: modified, header: map[string]string{"If-Modified-Since": "yesterday"},
+			wantLastModified: lastModified},
+		{name: "etag_wins", modified: modified, header: map[string]string{"If-Modified-Since": lastModified, "If-None-Match": `"abc"`},
+			wantLastModified: lastModified},
+		{name: "not_a_read", method: http.MethodPut, modified: modified, header: map[string]string{"If-Modified-Since": lastModified},
+			wantLastModified: lastModified},
+		{name: "unknown_time", header: map[string]string{"If-Modified-Since": lastModified}},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, "/students/1", nil)
+			for k, v := range tc.header {
+				req.Header.Set(k, v)
+			}
+			rr := httptest.NewRecorder()
+			if got := response.NotModified(rr, req, tc.modified); got != tc.wantNotModified {
+				t.Fatalf("want %v, got %v", tc.wantNotModified, got)
+			}
+			if tc.wantNotModified && rr.Code != http.StatusNotModified {
+				t.Fatalf("want 304, got %d", rr.Code)
+			}
+			if got := rr.Header().Get("Last-Modified"); got != tc.wantLastModified {
+				t.Fatalf("want Last-Modified %q, got %q", tc.wantLastModified, got)
+			}
+		})
+	}
+}
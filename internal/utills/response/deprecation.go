@@ -0,0 +1,51 @@
+package response
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DeprecationHeader = "Deprecation"
+	SunsetHeader      = "Sunset" // RFC 8594
+	WarningHeader     = "Warning"
+)
+
+// warnCode is the "miscellaneous persistent warning" of RFC 7234, the notice stays true until the client changes
+const warnCode = "299"
+
+// Deprecate marks the answer as coming from something that is going away -> "Deprecation: true", a Link to successor
+// when it is not empty and a Sunset header with the date it stops working when sunset is not zero
+func Deprecate(w http.ResponseWriter, successor string, sunset time.Time) {
+	h := w.Header()
+	h.Set(DeprecationHeader, "true")
+	if successor != "" {
+		h.Add("Link", "<"+successor+`>; rel="successor-version"`)
+	}
+	if !sunset.IsZero() {
+		h.Set(SunsetHeader, sunset.UTC().Format(http.TimeFormat))
+	}
+}
+
+// Warn adds a notice for the client, like a field that is going away -> `Warning: 299 - "..."`.
+// call it before the body is written, the json envelope repeats every warning in meta.warnings
+func Warn(w http.ResponseWriter, msg string) {
+	w.Header().Add(WarningHeader, warnCode+" - "+strconv.Quote(msg))
+}
+
+// warnings reads back the texts Warn put in the headers
+func warnings(h http.Header) []string {
+	var out []string
+	for _, v := range h.Values(WarningHeader) {
+		quoted, ok := strings.CutPrefix(v, warnCode+" - ")
+		if !ok {
+			continue
+		}
+		if msg, err := strconv.Unquote(quoted); err == nil {
+			out = append(out, msg)
+		}
+	}
+	return out
+}
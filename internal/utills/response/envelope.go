@@ -0,0 +1,73 @@
+package response
+
+//go:generate easyjson -build_tags easyjson -no_std_marshalers envelope.go response.go
+
+import (
+	"net/http"
+	"path"
+	"reflect"
+	"strconv"
+)
+
+// Envelope is the body of every successful json answer -> {"data": ..., "meta": {...}}.
+// data is the resource or the list, meta is about the answer itself, so clients always find the payload in the same place
+//
+//easyjson:json
+type Envelope struct {
+	Data any  `json:"data"`
+	Meta Meta `json:"meta"`
+}
+
+// Meta has no request id, unlike error bodies -> the body of a GET must be the same every time or the ETag never matches
+type Meta struct {
+	Page     *Page        `json:"page,omitempty"`     // only for lists
+	Bulk     *BulkSummary `json:"bulk,omitempty"`     // only for bulk requests
+	Warnings []string     `json:"warnings,omitempty"` // the Warning headers of the answer, filled in by Write
+}
+
+// Page says which part of a list the answer is
+type Page struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+	Count  int `json:"count"` // items in this page, less than limit on the last one
+}
+
+// OK answers 200 with data in the envelope
+func OK(w http.ResponseWriter, r *http.Request, data any) error {
+	return Write(w, r, http.StatusOK, Envelope{Data: data})
+}
+
+// OKPage answers 200 with one page of a list, items should be a slice
+func OKPage(w http.ResponseWriter, r *http.Request, items any, page Page) error {
+	if v := reflect.ValueOf(items); v.Kind() == reflect.Slice {
+		page.Count = v.Len()
+	}
+	return Write(w, r, http.StatusOK, Envelope{Data: items, Meta: Meta{Page: &page}})
+}
+
+// Created answers 201 with the new resource, location is its url (see Location) and goes in the Location header, left out when empty
+func Created(w http.ResponseWriter, r *http.Request, location string, data any) error {
+	if location != "" {
+		w.Header().Set("Location", location)
+	}
+	return Write(w, r, http.StatusCreated, Envelope{Data: data})
+}
+
+// Accepted answers 202 for work that goes on in the background, location is where to follow it and data how it stands now
+func Accepted(w http.ResponseWriter, r *http.Request, location string, data any) error {
+	if location != "" {
+		w.Header().Set("Location", location)
+	}
+	return Write(w, r, http.StatusAccepted, Envelope{Data: data})
+}
+
+// Location is the url of the resource a POST to r created -> POST /api/v1/students that made id 7 gives /api/v1/students/7
+func Location(r *http.Request, id int64) string {
+	return path.Join(r.URL.Path, strconv.FormatInt(id, 10))
+}
+
+// NoContent answers 204, for deletes and other changes with nothing to send back
+func NoContent(w http.ResponseWriter) {
+	w.Header().Del("Content-Type")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,326 @@
+//go:build easyjson
+// +build easyjson
+
+// Code generated by easyjson for marshaling/unmarshaling. DO NOT EDIT.
+
+package response
+
+import (
+	json "encoding/json"
+	easyjson "github.com/mailru/easyjson"
+	jlexer "github.com/mailru/easyjson/jlexer"
+	jwriter "github.com/mailru/easyjson/jwriter"
+)
+
+// suppress unused package warning
+var (
+	_ *json.RawMessage
+	_ *jlexer.Lexer
+	_ *jwriter.Writer
+	_ easyjson.Marshaler
+)
+
+func easyjson11af3d8cDecodeGithubComManishtomarCpiGoServerInternalUtillsResponse(in *jlexer.Lexer, out *Envelope) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		switch key {
+		case "data":
+			if m, ok := out.Data.(easyjson.Unmarshaler); ok {
+				m.UnmarshalEasyJSON(in)
+			} else if m, ok := out.Data.(json.Unmarshaler); ok {
+				_ = m.UnmarshalJSON(in.Raw())
+			} else {
+				out.Data = in.Interface()
+			}
+		case "meta":
+			easyjson11af3d8cDecodeGithubComManishtomarCpiGoServerInternalUtillsResponse1(in, &out.Meta)
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson11af3d8cEncodeGithubComManishtomarCpiGoServerInternalUtillsResponse(out *jwriter.Writer, in Envelope) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"data\":"
+		out.RawString(prefix[1:])
+		if m, ok := in.Data.(easyjson.Marshaler); ok {
+			m.MarshalEasyJSON(out)
+		} else if m, ok := in.Data.(json.Marshaler); ok {
+			out.Raw(m.MarshalJSON())
+		} else {
+			out.Raw(json.Marshal(in.Data))
+		}
+	}
+	{
+		const prefix string = ",\"meta\":"
+		out.RawString(prefix)
+		easyjson11af3d8cEncodeGithubComManishtomarCpiGoServerInternalUtillsResponse1(out, in.Meta)
+	}
+	out.RawByte('}')
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v Envelope) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson11af3d8cEncodeGithubComManishtomarCpiGoServerInternalUtillsResponse(w, v)
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *Envelope) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson11af3d8cDecodeGithubComManishtomarCpiGoServerInternalUtillsResponse(l, v)
+}
+func easyjson11af3d8cDecodeGithubComManishtomarCpiGoServerInternalUtillsResponse1(in *jlexer.Lexer, out *Meta) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		switch key {
+		case "page":
+			if in.IsNull() {
+				in.Skip()
+				out.Page = nil
+			} else {
+				if out.Page == nil {
+					out.Page = new(Page)
+				}
+				easyjson11af3d8cDecodeGithubComManishtomarCpiGoServerInternalUtillsResponse2(in, out.Page)
+			}
+		case "bulk":
+			if in.IsNull() {
+				in.Skip()
+				out.Bulk = nil
+			} else {
+				if out.Bulk == nil {
+					out.Bulk = new(BulkSummary)
+				}
+				easyjson11af3d8cDecodeGithubComManishtomarCpiGoServerInternalUtillsResponse3(in, out.Bulk)
+			}
+		case "warnings":
+			if in.IsNull() {
+				in.Skip()
+				out.Warnings = nil
+			} else {
+				in.Delim('[')
+				if out.Warnings == nil {
+					if !in.IsDelim(']') {
+						out.Warnings = make([]string, 0, 4)
+					} else {
+						out.Warnings = []string{}
+					}
+				} else {
+					out.Warnings = (out.Warnings)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v1 string
+					if in.IsNull() {
+						in.Skip()
+					} else {
+						v1 = string(in.String())
+					}
+					out.Warnings = append(out.Warnings, v1)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson11af3d8cEncodeGithubComManishtomarCpiGoServerInternalUtillsResponse1(out *jwriter.Writer, in Meta) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	if in.Page != nil {
+		const prefix string = ",\"page\":"
+		first = false
+		out.RawString(prefix[1:])
+		easyjson11af3d8cEncodeGithubComManishtomarCpiGoServerInternalUtillsResponse2(out, *in.Page)
+	}
+	if in.Bulk != nil {
+		const prefix string = ",\"bulk\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		easyjson11af3d8cEncodeGithubComManishtomarCpiGoServerInternalUtillsResponse3(out, *in.Bulk)
+	}
+	if len(in.Warnings) != 0 {
+		const prefix string = ",\"warnings\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		{
+			out.RawByte('[')
+			for v2, v3 := range in.Warnings {
+				if v2 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v3))
+			}
+			out.RawByte(']')
+		}
+	}
+	out.RawByte('}')
+}
+func easyjson11af3d8cDecodeGithubComManishtomarCpiGoServerInternalUtillsResponse3(in *jlexer.Lexer, out *BulkSummary) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		switch key {
+		case "total":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Total = int(in.Int())
+			}
+		case "succeeded":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Succeeded = int(in.Int())
+			}
+		case "failed":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Failed = int(in.Int())
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson11af3d8cEncodeGithubComManishtomarCpiGoServerInternalUtillsResponse3(out *jwriter.Writer, in BulkSummary) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"total\":"
+		out.RawString(prefix[1:])
+		out.Int(int(in.Total))
+	}
+	{
+		const prefix string = ",\"succeeded\":"
+		out.RawString(prefix)
+		out.Int(int(in.Succeeded))
+	}
+	{
+		const prefix string = ",\"failed\":"
+		out.RawString(prefix)
+		out.Int(int(in.Failed))
+	}
+	out.RawByte('}')
+}
+func easyjson11af3d8cDecodeGithubComManishtomarCpiGoServerInternalUtillsResponse2(in *jlexer.Lexer, out *Page) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		switch key {
+		case "limit":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Limit = int(in.Int())
+			}
+		case "offset":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Offset = int(in.Int())
+			}
+		case "count":
+			if in.IsNull() {
+				in.Skip()
+			} else {
+				out.Count = int(in.Int())
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson11af3d8cEncodeGithubComManishtomarCpiGoServerInternalUtillsResponse2(out *jwriter.Writer, in Page) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"limit\":"
+		out.RawString(prefix[1:])
+		out.Int(int(in.Limit))
+	}
+	{
+		const prefix string = ",\"offset\":"
+		out.RawString(prefix)
+		out.Int(int(in.Offset))
+	}
+	{
+		const prefix string = ",\"count\":"
+		out.RawString(prefix)
+		out.Int(int(in.Count))
+	}
+	out.RawByte('}')
+}
@@ -0,0 +1,152 @@
+package response_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/http/dto"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+func TestEnvelope(t *testing.T) {
+	t.Parallel()
+
+	students := []types.Student{{Id: 1, Name: "Asha", Email: "asha@example.com", Age: 21}}
+
+	type testCase struct {
+		name       string
+		accept     string
+		write      func(w http.ResponseWriter, r *http.Request) error
+		wantStatus int
+		assert     func(t *testing.T, rr *httptest.ResponseRecorder)
+	}
+
+	tests := []testCase{
+		{
+			name: "page_in_meta",
+			write: func(w http.ResponseWriter, r *http.Request) error {
+				return response.OKPage(w, r, students, response.Page{Limit: 50})
+			},
+			wantStatus: http.StatusOK,
+			assert: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				var got struct {
+					Data []types.Student `json:"data"`
+					Meta response.Meta   `json:"meta"`
+				}
+				if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				if len(got.Data) != 1 || got.Meta.Page == nil ||
+					got.Meta.Page.Limit != 50 || got.Meta.Page.Count != 1 {
+					t.Fatalf("unexpected body: %s", rr.Body.String())
+				}
+			},
+		},
+		{
+			name: "created_sets_location",
+			write: func(w http.ResponseWriter, r *http.Request) error {
+				return response.Created(w, r, "/api/v1/students/1", students[0])
+			},
+			wantStatus: http.StatusCreated,
+			assert: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				if got := rr.Header().Get("Location"); got != "/api/v1/students/1" {
+					t.Fatalf("location: got %q", got)
+				}
+				if !strings.HasPrefix(rr.Body.String(), `{"data":{"id":1`) {
+					t.Fatalf("want the student under data, got %s", rr.Body.String())
+				}
+			},
+		},
+		{
+			name: "warnings_in_headers_and_meta",
+			write: func(w http.ResponseWriter, r *http.Request) error {
+				response.Deprecate(w, "/api/v2/students", time.Date(2027, 6, 30, 0, 0, 0, 0, time.UTC))
+				response.Warn(w, `field "age" is going away`)
+				return response.OK(w, r, students[0])
+			},
+			wantStatus: http.StatusOK,
+			assert: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				h := rr.Header()
+				if h.Get("Deprecation") != "true" || h.Get("Sunset") != "Wed, 30 Jun 2027 00:00:00 GMT" ||
+					h.Get("Link") != `</api/v2/students>; rel="successor-version"` ||
+					h.Get("Warning") != `299 - "field \"age\" is going away"` {
+					t.Fatalf("unexpected headers: %v", h)
+				}
+				var got struct {
+					Meta response.Meta `json:"meta"`
+				}
+				if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				if len(got.Meta.Warnings) != 1 || got.Meta.Warnings[0] != `field "age" is going away` {
+					t.Fatalf("want the warning in meta, got %s", rr.Body.String())
+				}
+			},
+		},
+		{
+			name:   "csv_has_no_envelope",
+			accept: response.CSV,
+			write: func(w http.ResponseWriter, r *http.Request) error {
+				return response.OK(w, r, students)
+			},
+			wantStatus: http.StatusOK,
+			assert: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				if want := "id,name,email,age\n1,Asha,asha@example.com,21\n"; rr.Body.String() != want {
+					t.Fatalf("want %q, got %q", want, rr.Body.String())
+				}
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/students", nil)
+			if tc.accept != "" {
+				r.Header.Set("Accept", tc.accept)
+			}
+			rr := httptest.NewRecorder()
+			if err := tc.write(rr, r); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if rr.Code != tc.wantStatus {
+				t.Fatalf("status: want %d, got %d", tc.wantStatus, rr.Code)
+			}
+			tc.assert(t, rr)
+		})
+	}
+}
+
+func BenchmarkOK(b *testing.B) {
+	student := types.Student{Id: 1, Name: "Asha", Email: "asha@example.com", Age: 21}
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/students/1", nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := response.OK(httptest.NewRecorder(), r, student); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// go test -bench OKPage -tags easyjson compares the generated marshalers with encoding/json
+func BenchmarkOKPage(b *testing.B) {
+	students := make([]types.Student, 50)
+	for i := range students {
+		students[i] = types.Student{Id: int64(i + 1), Name: "Asha", Email: "asha@example.com", Age: 21}
+	}
+	page := dto.NewStudents(students)
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/students", nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := response.OKPage(httptest.NewRecorder(), r, page, response.Page{Limit: 50}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
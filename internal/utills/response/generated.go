@@ -0,0 +1,34 @@
+//go:build easyjson
+
+package response
+
+import (
+	"bytes"
+
+	"github.com/mailru/easyjson"
+	"github.com/mailru/easyjson/buffer"
+	"github.com/mailru/easyjson/jwriter"
+)
+
+// built with -tags easyjson the hot answers (a student, a list of them, the envelope and both error bodies) are
+// encoded by the marshalers easyjson generated into the *_easyjson.go files, without reflection. the output is the
+// same as encoding/json, generated_test.go checks that. run go generate ./... after changing one of those types
+
+// encodeGenerated writes v with its generated marshaler, false when v has none
+func encodeGenerated(buf *bytes.Buffer, v any) (bool, error) {
+	m, ok := v.(easyjson.Marshaler)
+	if !ok {
+		return false, nil
+	}
+	// the writer starts in the free space of buf, so a body that fits is written straight into it and BuildBytes
+	// hands back that same memory
+	w := jwriter.Writer{Buffer: buffer.Buffer{Buf: buf.AvailableBuffer()}}
+	m.MarshalEasyJSON(&w)
+	out, err := w.BuildBytes()
+	if err != nil {
+		return true, err
+	}
+	buf.Write(out)
+	buf.WriteByte('\n')
+	return true, nil
+}
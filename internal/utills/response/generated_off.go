@@ -0,0 +1,10 @@
+//go:build !easyjson
+
+package response
+
+import "bytes"
+
+// encodeGenerated is a no-op without -tags easyjson, everything goes through encoding/json (see generated.go)
+func encodeGenerated(buf *bytes.Buffer, v any) (bool, error) {
+	return false, nil
+}
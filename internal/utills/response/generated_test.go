@@ -0,0 +1,102 @@
+//go:build easyjson
+
+package response_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mailru/easyjson"
+	"github.com/manishtomar-cpi/go-server/internal/errcode"
+	"github.com/manishtomar-cpi/go-server/internal/http/dto"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+// the generated marshalers must write byte for byte what encoding/json writes, clients and ETags depend on it
+func TestGeneratedMatchesEncodingJSON(t *testing.T) {
+	t.Parallel()
+
+	student := dto.Student{ID: 7, Name: "Asha <script>&", Email: "asha@example.com", Age: 21}
+	weird := dto.Student{ID: -1, Name: "line\u2028sep \"quoted\" \\ \x01 ünï", Email: ""}
+
+	type testCase struct {
+		name string
+		v    easyjson.Marshaler
+	}
+
+	tests := []testCase{
+		{name: "student", v: student},
+		{name: "student_escaping", v: weird},
+		{name: "students", v: dto.Students{student, weird}},
+		{name: "students_empty", v: dto.NewStudents(nil)},
+		{name: "students_nil", v: dto.Students(nil)},
+		{name: "envelope_student", v: response.Envelope{Data: student}},
+		{name: "envelope_nil_data", v: response.Envelope{}},
+		{name: "envelope_page", v: response.Envelope{
+			Data: dto.NewStudents([]types.Student{{Id: 1, Name: "Asha"}}),
+			Meta: response.Meta{Page: &response.Page{Limit: 50, Offset: 100, Count: 1}, Warnings: []string{`299 - "deprecated"`}},
+		}},
+		{name: "envelope_bulk", v: response.Envelope{
+			Data: []types.Student{{Id: 1}},
+			Meta: response.Meta{Bulk: &response.BulkSummary{Total: 2, Succeeded: 1, Failed: 1}},
+		}},
+		{name: "envelope_plain_data", v: response.Envelope{Data: map[string]any{"b": 1, "a": []int{}}}},
+		{name: "error", v: response.Response{Status: response.StatusError, Error: "student 7 not found", Code: errcode.StudentNotFound}},
+		{name: "error_fields", v: response.Response{
+			Status: response.StatusError, Error: "invalid body", Code: errcode.ValidationFailed, RequestID: "req-1",
+			Fields: []response.FieldError{{Field: "email", Message: "must be an email <x>"}},
+		}},
+		{name: "problem", v: response.Problem{
+			Type: response.ProblemTypeBlank, Title: "Not Found", Status: http.StatusNotFound, Detail: "gone", Instance: "/api/v1/students/7",
+		}},
+		{name: "problem_fields", v: response.Problem{
+			Type: response.ProblemTypePrefix + "validation-failed", Title: "Bad Request", Status: http.StatusBadRequest,
+			Errors: []response.FieldError{{Field: "age", Message: "too small"}},
+		}},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			want, err := json.Marshal(tc.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := easyjson.Marshal(tc.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("generated\n%s\nencoding/json\n%s", got, want)
+			}
+
+			// and the writer really takes the generated path and keeps the newline json.Encoder adds
+			rr := httptest.NewRecorder()
+			if err := response.WriteJson(rr, http.StatusOK, tc.v); err != nil {
+				t.Fatal(err)
+			}
+			if body := rr.Body.String(); body != string(want)+"\n" {
+				t.Fatalf("body = %q, want %q", body, string(want)+"\n")
+			}
+		})
+	}
+}
+
+func TestGeneratedEncodeError(t *testing.T) {
+	t.Parallel()
+
+	rr := httptest.NewRecorder()
+	if err := response.WriteJson(rr, http.StatusOK, response.Envelope{Data: math.NaN()}); err == nil {
+		t.Fatal("want an error for NaN")
+	}
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", rr.Code)
+	}
+}
@@ -0,0 +1,40 @@
+package response
+
+import (
+	"strings"
+
+	"github.com/manishtomar-cpi/go-server/internal/i18n"
+	"github.com/manishtomar-cpi/go-server/internal/validation"
+)
+
+// LanguageHeader is set by the localize middleware to the language picked from Accept-Language,
+// WriteJson translates error messages into it. unlike the problem marker it is a real header and stays on the response
+const LanguageHeader = "Content-Language"
+
+// localize translates the messages of an error, the code stays as it is so clients can still branch on it
+func localize(resp Response, lang string) Response {
+	if len(resp.Fields) == 0 {
+		resp.Error = i18n.Message(lang, string(resp.Code), resp.Error)
+		return resp
+	}
+	fields := make([]FieldError, len(resp.Fields)) // the caller may reuse its slice
+	msgs := make([]string, len(resp.Fields))
+	for i, f := range resp.Fields {
+		if msg, ok := translate(resp, i, lang); ok {
+			f.Message = msg
+		} else if msg, ok := i18n.Rule(lang, f.Rule, f.Field); ok {
+			f.Message = msg
+		}
+		fields[i], msgs[i] = f, f.Message
+	}
+	resp.Fields, resp.Error = fields, strings.Join(msgs, ",")
+	return resp
+}
+
+// translate is the sentence of the validator for field i, it knows the parameter of the rule and the catalog does not
+func translate(resp Response, i int, lang string) (string, bool) {
+	if i >= len(resp.invalid) {
+		return "", false
+	}
+	return validation.Message(lang, resp.invalid[i])
+}
@@ -0,0 +1,283 @@
+package response
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/utills/bufpool"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	JSON    = "application/json"
+	XML     = "application/xml"
+	CSV     = "text/csv"
+	MsgPack = "application/msgpack" // binary, for mobile and iot clients that count every byte
+)
+
+// Encoder writes a response value in one media type
+type Encoder interface {
+	Encode(w io.Writer, v any) error
+}
+
+type EncoderFunc func(w io.Writer, v any) error
+
+func (f EncoderFunc) Encode(w io.Writer, v any) error {
+	return f(w, v)
+}
+
+// ErrUnsupported is returned by encoders that can not represent a value (a map as csv), Write falls back to json then
+var ErrUnsupported = errors.New("value can not be encoded in this media type")
+
+type registry struct {
+	mu       sync.RWMutex
+	encoders map[string]Encoder
+	order    []string // registration order, decides what "text/*" means
+}
+
+var encoders = &registry{encoders: map[string]Encoder{}}
+
+func init() {
+	Register(JSON, EncoderFunc(func(w io.Writer, v any) error { return json.NewEncoder(w).Encode(v) }))
+	Register(XML, EncoderFunc(encodeXML))
+	Register(CSV, EncoderFunc(encodeCSV))
+	Register(MsgPack, EncoderFunc(encodeMsgPack))
+}
+
+// Register adds the encoder for a media type, or replaces the one already there
+func Register(mediaType string, enc Encoder) {
+	encoders.mu.Lock()
+	defer encoders.mu.Unlock()
+	if _, ok := encoders.encoders[mediaType]; !ok {
+		encoders.order = append(encoders.order, mediaType)
+	}
+	encoders.encoders[mediaType] = enc
+}
+
+func lookup(mediaType string) (Encoder, bool) {
+	encoders.mu.RLock()
+	defer encoders.mu.RUnlock()
+	enc, ok := encoders.encoders[mediaType]
+	return enc, ok
+}
+
+// Negotiate picks the registered media type the Accept header of r likes best.
+// json when there is no Accept header or nothing in it is registered, clients never get a 406 from us
+func Negotiate(r *http.Request) string {
+	type choice struct {
+		mediaType string
+		q         float64
+	}
+	var choices []choice
+	for _, header := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(header, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+			q := 1.0
+			if v, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(v, 64); err != nil {
+					continue
+				}
+			}
+			if q > 0 {
+				choices = append(choices, choice{mediaType: mediaType, q: q})
+			}
+		}
+	}
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].q > choices[j].q })
+
+	encoders.mu.RLock()
+	defer encoders.mu.RUnlock()
+	for _, c := range choices {
+		switch {
+		case c.mediaType == "*/*":
+			return JSON
+		case strings.HasSuffix(c.mediaType, "/*"):
+			prefix := strings.TrimSuffix(c.mediaType, "*")
+			for _, mediaType := range encoders.order {
+				if strings.HasPrefix(mediaType, prefix) {
+					return mediaType
+				}
+			}
+		default:
+			if _, ok := encoders.encoders[c.mediaType]; ok {
+				return c.mediaType
+			}
+		}
+	}
+	return JSON
+}
+
+// Write is WriteJson for handlers that can answer in every registered media type, picked from the Accept header.
+// errors always go out as json (or problem+json), only the success body is negotiated
+func Write(w http.ResponseWriter, r *http.Request, status int, data any) error {
+	if resp, ok := data.(Response); ok && resp.Status == StatusError {
+		return WriteJson(w, status, data)
+	}
+	w.Header().Add("Vary", "Accept") // caches must not hand the csv to the next json client
+	if env, ok := data.(Envelope); ok {
+		env.Meta.Warnings = warnings(w.Header())
+		data = env
+	}
+
+	mediaType := Negotiate(r)
+	enc, ok := lookup(mediaType)
+	if mediaType == JSON || !ok {
+		return WriteJson(w, status, data)
+	}
+	body := data
+	if env, ok := data.(Envelope); ok && mediaType != MsgPack {
+		body = env.Data // xml and csv have no place for meta, they carry the bare data like before the envelope
+	}
+
+	e := getEncoder() // encoded up front, so a value the encoder can not handle still gets a proper json answer
+	defer putEncoder(e)
+	if err := enc.Encode(&e.buf, body); err != nil {
+		if errors.Is(err, ErrUnsupported) {
+			return WriteJson(w, status, data)
+		}
+		WriteJson(w, http.StatusInternalServerError, GeneralError(errors.New("could not encode response")))
+		return err
+	}
+	contentType := mediaType
+	if mediaType != MsgPack {
+		contentType += "; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	_, err := w.Write(e.buf.Bytes())
+	return err
+}
+
+// encodeMsgPack uses the json field names, so a msgpack client sees the same keys as a json one.
+// ints take the fewest bytes that hold the value, an id of 1 is one byte and not nine
+func encodeMsgPack(w io.Writer, v any) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	return enc.Encode(v)
+}
+
+// encodeXML wraps lists in <items>, xml needs a single root element
+func encodeXML(w io.Writer, v any) error {
+	buf := bufpool.Get() // nothing reaches w when encoding fails halfway
+	defer bufpool.Put(buf)
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(buf)
+	err := func() error {
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice {
+			return enc.Encode(v)
+		}
+		start := xml.StartElement{Name: xml.Name{Local: "items"}}
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		for i := range rv.Len() {
+			if err := enc.Encode(rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	}()
+	if err == nil {
+		err = enc.Flush()
+	}
+	var unsupported *xml.UnsupportedTypeError
+	if errors.As(err, &unsupported) {
+		return fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// encodeCSV writes a struct or a list of structs, one row each. columns are the json names of the fields,
+// so the csv and the json of an endpoint never disagree. nested values have no column and are not supported
+func encodeCSV(w io.Writer, v any) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	rows := []reflect.Value{rv}
+	elem := rv.Type()
+	if rv.Kind() == reflect.Slice {
+		rows = rows[:0]
+		for i := range rv.Len() {
+			rows = append(rows, reflect.Indirect(rv.Index(i)))
+		}
+		elem = rv.Type().Elem()
+	}
+	if elem.Kind() == reflect.Pointer {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return ErrUnsupported
+	}
+
+	var header []string
+	var fields []int
+	for i := range elem.NumField() {
+		f := elem.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		header = append(header, name)
+		fields = append(fields, i)
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := make([]string, len(fields))
+		for col, i := range fields {
+			cell, err := csvCell(row.Field(i))
+			if err != nil {
+				return err
+			}
+			record[col] = cell
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvCell(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.Format(time.RFC3339), nil
+	}
+	switch v.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return fmt.Sprint(v.Interface()), nil
+	}
+	return "", ErrUnsupported
+}
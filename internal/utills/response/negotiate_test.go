@@ -0,0 +1,119 @@
+package response_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/types"
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+func TestNegotiate(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name   string
+		accept string
+		want   string
+	}
+
+	tests := []testCase{
+		{name: "no_accept_is_json", want: response.JSON},
+		{name: "anything_is_json", accept: "*/*", want: response.JSON},
+		{name: "xml", accept: "application/xml", want: response.XML},
+		{name: "csv", accept: "text/csv", want: response.CSV},
+		{name: "msgpack", accept: "application/msgpack", want: response.MsgPack},
+		{name: "highest_q_wins", accept: "application/xml;q=0.5, text/csv;q=0.8", want: response.CSV},
+		{name: "wildcard_subtype", accept: "text/*", want: response.CSV},
+		{name: "refused_type_skipped", accept: "text/csv;q=0, application/xml", want: response.XML},
+		{name: "unknown_falls_back_to_json", accept: "image/png", want: response.JSON},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodGet, "/api/students", nil)
+			if tc.accept != "" {
+				r.Header.Set("Accept", tc.accept)
+			}
+			if got := response.Negotiate(r); got != tc.want {
+				t.Fatalf("want %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	students := []types.Student{{Id: 1, Name: "Asha", Email: "asha@example.com", Age: 21}, {Id: 2, Name: "Ravi, Jr", Email: "ravi@example.com", Age: 22}}
+
+	type testCase struct {
+		name            string
+		accept          string
+		data            any
+		wantContentType string
+		wantBody        string
+	}
+
+	tests := []testCase{
+		{name: "json", data: students, wantContentType: "application/json",
+			wantBody: `[{"id":1,"name":"Asha","email":"asha@example.com","age":21},{"id":2,"name":"Ravi, Jr","email":"ravi@example.com","age":22}]` + "\n"},
+		{name: "csv_list", accept: "text/csv", data: students, wantContentType: "text/csv; charset=utf-8",
+			wantBody: "id,name,email,age\n1,Asha,asha@example.com,21\n2,\"Ravi, Jr\",ravi@example.com,22\n"},
+		{name: "xml_single", accept: "application/xml", data: students[0], wantContentType: "application/xml; charset=utf-8",
+			wantBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<Student><id>1</id><name>Asha</name><email>asha@example.com</email><age>21</age></Student>`},
+		{name: "xml_list_has_root", accept: "application/xml", data: students[:1], wantContentType: "application/xml; charset=utf-8",
+			wantBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<items><Student><id>1</id><name>Asha</name><email>asha@example.com</email><age>21</age></Student></items>`},
+		{name: "msgpack_uses_json_names", accept: "application/msgpack", data: students[0], wantContentType: "application/msgpack",
+			wantBody: "\x84\xa2id\x01\xa4name\xa4Asha\xa5email\xb0asha@example.com\xa3age\x15"},
+		{name: "csv_of_map_falls_back_to_json", accept: "text/csv", data: map[string]int{"id": 1}, wantContentType: "application/json",
+			wantBody: `{"id":1}` + "\n"},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodGet, "/api/students", nil)
+			if tc.accept != "" {
+				r.Header.Set("Accept", tc.accept)
+			}
+			rr := httptest.NewRecorder()
+			if err := response.Write(rr, r, http.StatusOK, tc.data); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if ct := rr.Header().Get("Content-Type"); ct != tc.wantContentType {
+				t.Fatalf("content-type: want %q, got %q", tc.wantContentType, ct)
+			}
+			if !strings.Contains(rr.Header().Get("Vary"), "Accept") {
+				t.Fatalf("want Vary: Accept, got %q", rr.Header().Get("Vary"))
+			}
+			if rr.Body.String() != tc.wantBody {
+				t.Fatalf("body: want %q, got %q", tc.wantBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func BenchmarkWriteXML(b *testing.B) {
+	students := make([]types.Student, 50)
+	for i := range students {
+		students[i] = types.Student{Id: int64(i + 1), Name: "Asha", Email: "asha@example.com", Age: 21}
+	}
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/students", nil)
+	r.Header.Set("Accept", "application/xml")
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := response.Write(httptest.NewRecorder(), r, http.StatusOK, response.Envelope{Data: students}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -1,19 +1,68 @@
 package response
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/manishtomar-cpi/go-server/internal/errcode"
+	"github.com/manishtomar-cpi/go-server/internal/i18n"
+	"github.com/manishtomar-cpi/go-server/internal/validation"
 )
 
+// Response is the error body -> {"status": "Error", "error": "...", "code": "..."}
+//
+//easyjson:json
 type Response struct {
-	Status string
-	Error  string
+	Status    string       `json:"status"`
+	Error     string       `json:"error"`
+	Code      errcode.Code `json:"code,omitempty"`       // machine readable reason, clients branch on this and never on Error. WriteJson fills it from the status when empty
+	RequestID string       `json:"request_id,omitempty"` // filled in by WriteJson, support takes these straight to the logs and the trace
+	TraceID   string       `json:"trace_id,omitempty"`
+	Fields    []FieldError `json:"-"` // only sent in the problem+json body, the old body keeps the joined Error text
+
+	invalid validator.ValidationErrors // what Fields was made from, the messages are translated from it
+}
+
+// Problem is the RFC 7807 body sent instead of Response to clients that asked for application/problem+json
+//
+//easyjson:json
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      errcode.Code `json:"code,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	TraceID   string       `json:"trace_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"` // one entry per invalid field
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
 }
 
+const (
+	ProblemContentType = "application/problem+json"
+	ProblemTypeBlank   = "about:blank" // rfc 7807: nothing more to say than the status code
+	ProblemTypePrefix  = "/problems/"  // + slug of the code, like /problems/validation-failed
+)
+
+// ProblemHeader is set by the problem details middleware to the request path when the client gets problem+json errors.
+// it only carries that choice down to WriteJson (handlers never see the request there) and is removed before the response goes out
+const ProblemHeader = "X-Problem-Instance"
+
+// set on every response by the request id and tracing middlewares, error bodies repeat them
+const (
+	RequestIDHeader = "X-Request-ID"
+	TraceIDHeader   = "X-Trace-ID"
+)
+
 const (
 	StatusOk    = "OK"
 	StatusError = "Error"
@@ -21,10 +70,58 @@ const (
 
 // send response for requests in json
 func WriteJson(w http.ResponseWriter, status int, data any) error {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
+	if resp, ok := data.(Response); ok && resp.Status == StatusError {
+		if resp.RequestID == "" {
+			resp.RequestID = w.Header().Get(RequestIDHeader)
+		}
+		if resp.TraceID == "" {
+			resp.TraceID = w.Header().Get(TraceIDHeader)
+		}
+		if resp.Code == "" {
+			resp.Code = errcode.ForStatus(status)
+		}
+		if lang := w.Header().Get(LanguageHeader); lang != "" {
+			resp = localize(resp, lang)
+		}
+		if instance := w.Header().Get(ProblemHeader); instance != "" {
+			return writeProblem(w, status, resp, instance)
+		}
+		data = resp
+	}
+	return writeEncoded(w, status, JSON, data)
+}
+
+func writeProblem(w http.ResponseWriter, status int, resp Response, instance string) error {
+	problem := Problem{
+		Type:      ProblemTypeBlank,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    resp.Error,
+		Instance:  instance,
+		Code:      resp.Code,
+		RequestID: resp.RequestID,
+		TraceID:   resp.TraceID,
+		Errors:    resp.Fields,
+	}
+	if resp.Code != "" {
+		problem.Type = ProblemTypePrefix + resp.Code.Slug()
+	}
+	return writeEncoded(w, status, ProblemContentType, problem)
+}
 
-	return json.NewEncoder(w).Encode(data)
+// writeEncoded encodes v before anything is sent -> a value json can not encode (a channel, a NaN) turns into a clean 500
+// instead of a 200 with half a body. the error is returned so the caller still learns about it
+func writeEncoded(w http.ResponseWriter, status int, contentType string, v any) error {
+	e := getEncoder()
+	defer putEncoder(e)
+	if err := e.encode(v); err != nil {
+		WriteJson(w, http.StatusInternalServerError, GeneralError(errors.New("could not encode response")))
+		return err
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	_, err := w.Write(e.buf.Bytes())
+	return err
 }
 
 func GeneralError(err error) Response {
@@ -34,20 +131,36 @@ func GeneralError(err error) Response {
 	}
 }
 
-// for validation error
+// CodedError is GeneralError with a code more specific than the one of the status, like STUDENT_NOT_FOUND for a 404
+func CodedError(code errcode.Code, err error) Response {
+	resp := GeneralError(err)
+	resp.Code = code
+	return resp
+}
+
+// WriteError writes a coded error with the status that belongs to the code
+func WriteError(w http.ResponseWriter, code errcode.Code, err error) error {
+	return WriteJson(w, code.Status(), CodedError(code, err))
+}
+
+// ValidationError has one sentence per invalid field, like "age must be 100 or less". WriteJson says them again in
+// the language of the client
 func ValidationError(errs validator.ValidationErrors) Response {
-	var errMsgs []string
+	errMsgs := make([]string, 0, len(errs))
+	fields := make([]FieldError, 0, len(errs))
 	for _, err := range errs {
-		switch err.ActualTag() {
-		case "requried":
-			errMsgs = append(errMsgs, fmt.Sprintf("field %s is requried filed", err.Field()))
-		default:
-			errMsgs = append(errMsgs, fmt.Sprintf("field %s is invalid", err.Field()))
-
+		msg, ok := validation.Message(i18n.Default, err)
+		if !ok {
+			msg = fmt.Sprintf("field %s is invalid", err.Field())
 		}
+		errMsgs = append(errMsgs, msg)
+		fields = append(fields, FieldError{Field: err.Field(), Rule: err.ActualTag(), Message: msg})
 	}
 	return Response{
-		Status: StatusError,
-		Error:  strings.Join(errMsgs, ","),
+		Status:  StatusError,
+		Error:   strings.Join(errMsgs, ","),
+		Code:    errcode.ValidationFailed,
+		Fields:  fields,
+		invalid: errs,
 	}
 }
@@ -2,11 +2,14 @@ package response_test
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http/httptest"
-	"strings"
 	"testing"
 
+	"github.com/go-playground/validator/v10"
+	"github.com/manishtomar-cpi/go-server/internal/errcode"
 	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+	"github.com/manishtomar-cpi/go-server/internal/validation"
 )
 
 func TestWriteJson(t *testing.T) {
@@ -17,6 +20,7 @@ func TestWriteJson(t *testing.T) {
 		status          int
 		data            any
 		wantErr         bool
+		wantStatus      int // 0 means status
 		wantContentType string
 		assertBody      func(t *testing.T, body string)
 	}
@@ -34,8 +38,8 @@ func TestWriteJson(t *testing.T) {
 				if err := json.Unmarshal([]byte(body), &got); err != nil {
 					t.Fatalf("failed to decode body: %v", err)
 				}
-				if got["Status"] != response.StatusOk {
-					t.Fatalf("want Status=%q, got=%v", response.StatusOk, got["Status"])
+				if got["status"] != response.StatusOk {
+					t.Fatalf("want status=%q, got=%v", response.StatusOk, got["status"])
 				}
 			},
 		},
@@ -56,15 +60,20 @@ func TestWriteJson(t *testing.T) {
 			},
 		},
 		{
-			name:            "returns_error_when_json_encoding_fails",
-			status:          500,
+			name:            "encode_failure_sends_clean_500",
+			status:          200,
 			data:            make(chan int), // json cannot encode channels
 			wantErr:         true,
+			wantStatus:      500,
 			wantContentType: "application/json",
 			assertBody: func(t *testing.T, body string) {
-				// When encoding fails, encoder returns error and should not write a body
-				if strings.TrimSpace(body) != "" {
-					t.Fatalf("expected empty body on encode error, got: %q", body)
+				// nothing of the 200 went out, the body is one whole error
+				var got map[string]any
+				if err := json.Unmarshal([]byte(body), &got); err != nil {
+					t.Fatalf("want a single json error body, got %q: %v", body, err)
+				}
+				if got["error"] != "could not encode response" || got["code"] != string(errcode.Internal) {
+					t.Fatalf("unexpected body: %v", got)
 				}
 			},
 		},
@@ -87,8 +96,12 @@ func TestWriteJson(t *testing.T) {
 			}
 
 			// status code
-			if rr.Code != tc.status {
-				t.Fatalf("status mismatch: want %d, got %d", tc.status, rr.Code)
+			wantStatus := tc.status
+			if tc.wantStatus != 0 {
+				wantStatus = tc.wantStatus
+			}
+			if rr.Code != wantStatus {
+				t.Fatalf("status mismatch: want %d, got %d", wantStatus, rr.Code)
 			}
 
 			// content type
@@ -101,3 +114,127 @@ func TestWriteJson(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteJsonAddsCorrelationIDsToErrors(t *testing.T) {
+	t.Parallel()
+
+	rr := httptest.NewRecorder()
+	rr.Header().Set(response.RequestIDHeader, "req-1") // what the middlewares set before the handler runs
+	rr.Header().Set(response.TraceIDHeader, "4bf92f3577b34da6a3ce929d0e0e4736")
+
+	if err := response.WriteJson(rr, 500, response.GeneralError(errors.New("boom"))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	if got["request_id"] != "req-1" || got["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("want request and trace id in the body, got %v", got)
+	}
+	if got["code"] != string(errcode.Internal) { // no code given, the one of the status is used
+		t.Fatalf("want code %s, got %v", errcode.Internal, got["code"])
+	}
+}
+
+func TestWriteJsonProblem(t *testing.T) {
+	t.Parallel()
+
+	rr := httptest.NewRecorder()
+	rr.Header().Set(response.RequestIDHeader, "req-1")
+	rr.Header().Set(response.ProblemHeader, "/api/students") // what the problem details middleware sets
+
+	resp := response.Response{Status: response.StatusError, Error: "field Email is invalid", Code: errcode.ValidationFailed,
+		Fields: []response.FieldError{{Field: "Email", Rule: "email", Message: "field Email is invalid"}}}
+	if err := response.WriteJson(rr, 400, resp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if ct := rr.Header().Get("Content-Type"); ct != response.ProblemContentType {
+		t.Fatalf("content-type: want %q, got %q", response.ProblemContentType, ct)
+	}
+	var got response.Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	if got.Type != "/problems/validation-failed" || got.Title != "Bad Request" || got.Status != 400 || got.Detail != resp.Error ||
+		got.Instance != "/api/students" || got.RequestID != "req-1" || len(got.Errors) != 1 || got.Errors[0].Rule != "email" {
+		t.Fatalf("unexpected problem: %+v", got)
+	}
+}
+
+func TestWriteJsonLocalizes(t *testing.T) {
+	t.Parallel()
+
+	rr := httptest.NewRecorder()
+	rr.Header().Set(response.LanguageHeader, "es") // what the localize middleware sets
+
+	fields := []response.FieldError{{Field: "Name", Rule: "required", Message: "field Name is requried filed"}}
+	resp := response.Response{Status: response.StatusError, Error: fields[0].Message, Code: errcode.ValidationFailed, Fields: fields}
+	if err := response.WriteJson(rr, 400, resp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	if got["error"] != "el campo Name es obligatorio" || got["code"] != string(errcode.ValidationFailed) {
+		t.Fatalf("unexpected body: %v", got)
+	}
+	if fields[0].Message != "field Name is requried filed" {
+		t.Fatal("the fields of the caller were changed")
+	}
+}
+
+func TestValidationErrorSentences(t *testing.T) {
+	t.Parallel()
+
+	type student struct {
+		Name string `json:"name" validate:"required"`
+		Age  int    `json:"age" validate:"gte=1,lte=100"`
+	}
+	var errs validator.ValidationErrors
+	if !errors.As(validation.Struct(student{Age: 120}), &errs) {
+		t.Fatalf("want validation errors")
+	}
+
+	type testCase struct {
+		name       string
+		lang       string
+		wantFields []string
+	}
+
+	tests := []testCase{
+		{name: "english", lang: "en", wantFields: []string{"name is a required field", "age must be 100 or less"}},
+		{name: "spanish", lang: "es", wantFields: []string{"name es un campo requerido", "age debe ser 100 o menos"}},
+		{name: "hindi", lang: "hi", wantFields: []string{"name आवश्यक है", "age अधिकतम 100 हो सकता है"}},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rr := httptest.NewRecorder()
+			rr.Header().Set(response.LanguageHeader, tc.lang)
+			rr.Header().Set(response.ProblemHeader, "/api/v1/students")
+			if err := response.WriteJson(rr, 400, response.ValidationError(errs)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got response.Problem
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to decode body: %v", err)
+			}
+			if len(got.Errors) != len(tc.wantFields) {
+				t.Fatalf("want %d fields, got %+v", len(tc.wantFields), got.Errors)
+			}
+			for i, want := range tc.wantFields {
+				if got.Errors[i].Message != want {
+					t.Fatalf("field %d: want %q, got %q", i, want, got.Errors[i].Message)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package response
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// flushEvery is how many items go into the socket buffers before the stream pushes them to the client
+const flushEvery = 64
+
+// ArrayStream writes a list in the envelope one item at a time -> {"data":[...],"meta":{...}}, for lists too big to
+// hold in memory. the status is sent when the stream starts, so errors after that can only end the list early
+type ArrayStream struct {
+	w     http.ResponseWriter
+	rc    *http.ResponseController
+	count int
+	err   error // first write error, the client is gone and nothing is written after it
+}
+
+// StreamArray sends status and opens the list. the error is the one of the first write, the stream is useless then
+func StreamArray(w http.ResponseWriter, status int) (*ArrayStream, error) {
+	w.Header().Set("Content-Type", JSON)
+	w.WriteHeader(status)
+	s := &ArrayStream{w: w, rc: http.NewResponseController(w)}
+	s.write([]byte(`{"data":[`))
+	return s, s.err
+}
+
+// Item adds one value to the list. a value json can not encode is left out and its error returned, the list stays valid
+func (s *ArrayStream) Item(v any) error {
+	if s.err != nil {
+		return s.err
+	}
+	e := getEncoder()
+	defer putEncoder(e)
+	if s.count > 0 {
+		e.buf.WriteByte(',')
+	}
+	if err := e.encode(v); err != nil {
+		return err
+	}
+	s.write(bytes.TrimSuffix(e.buf.Bytes(), []byte("\n")))
+	if s.err != nil {
+		return s.err
+	}
+	s.count++
+	if s.count%flushEvery == 0 {
+		s.flush()
+	}
+	return nil
+}
+
+// Count is the number of items written so far
+func (s *ArrayStream) Count() int {
+	return s.count
+}
+
+// Close ends the list and writes meta, the count of meta.Page is filled in from the items written
+func (s *ArrayStream) Close(meta Meta) error {
+	if s.err != nil {
+		return s.err
+	}
+	if meta.Page != nil {
+		page := *meta.Page
+		page.Count = s.count
+		meta.Page = &page
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	s.write([]byte(`],"meta":`))
+	s.write(data)
+	s.write([]byte("}\n"))
+	s.flush()
+	return s.err
+}
+
+func (s *ArrayStream) write(b []byte) {
+	if s.err != nil {
+		return
+	}
+	_, s.err = s.w.Write(b)
+}
+
+func (s *ArrayStream) flush() {
+	if s.err != nil {
+		return
+	}
+	if err := s.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		s.err = err
+	}
+}
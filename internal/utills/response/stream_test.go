@@ -0,0 +1,120 @@
+package response_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/utills/response"
+)
+
+func TestArrayStream(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name      string
+		items     []any
+		meta      response.Meta
+		wantData  []any
+		wantCount int
+		wantErrs  int // items json can not encode
+	}
+
+	tests := []testCase{
+		{
+			name:     "empty_list_is_an_empty_array",
+			wantData: []any{},
+		},
+		{
+			name:      "items_in_order_with_page_count",
+			items:     []any{map[string]int{"id": 1}, map[string]int{"id": 2}},
+			meta:      response.Meta{Page: &response.Page{Limit: 10}},
+			wantData:  []any{map[string]any{"id": float64(1)}, map[string]any{"id": float64(2)}},
+			wantCount: 2,
+		},
+		{
+			name:      "bad_item_is_left_out",
+			items:     []any{1, make(chan int), 3},
+			meta:      response.Meta{Page: &response.Page{}},
+			wantData:  []any{float64(1), float64(3)},
+			wantCount: 2,
+			wantErrs:  1,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rr := httptest.NewRecorder()
+			s, err := response.StreamArray(rr, http.StatusOK)
+			if err != nil {
+				t.Fatalf("start: %v", err)
+			}
+			errs := 0
+			for _, item := range tc.items {
+				if err := s.Item(item); err != nil {
+					errs++
+				}
+			}
+			if err := s.Close(tc.meta); err != nil {
+				t.Fatalf("close: %v", err)
+			}
+			if errs != tc.wantErrs {
+				t.Fatalf("want %d item errors, got %d", tc.wantErrs, errs)
+			}
+
+			var got struct {
+				Data []any         `json:"data"`
+				Meta response.Meta `json:"meta"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatalf("want valid json, got %q: %v", rr.Body.String(), err)
+			}
+			if len(got.Data) != len(tc.wantData) {
+				t.Fatalf("want data %v, got %v", tc.wantData, got.Data)
+			}
+			for i := range tc.wantData {
+				if a, b := got.Data[i], tc.wantData[i]; !equalJSON(a, b) {
+					t.Fatalf("item %d: want %v, got %v", i, b, a)
+				}
+			}
+			if tc.meta.Page != nil && got.Meta.Page.Count != tc.wantCount {
+				t.Fatalf("want page count %d, got %d", tc.wantCount, got.Meta.Page.Count)
+			}
+		})
+	}
+}
+
+func TestArrayStreamStopsOnGoneClient(t *testing.T) {
+	t.Parallel()
+
+	w := &failingWriter{ResponseRecorder: httptest.NewRecorder()}
+	s, err := response.StreamArray(w, http.StatusOK)
+	if err == nil {
+		t.Fatalf("want the write error from the start")
+	}
+	if err := s.Item(1); err == nil {
+		t.Fatalf("want items refused after the client is gone")
+	}
+	if err := s.Close(response.Meta{}); err == nil {
+		t.Fatalf("want close to report the client is gone")
+	}
+}
+
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (f *failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func equalJSON(a, b any) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return string(x) == string(y)
+}
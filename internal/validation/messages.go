@@ -0,0 +1,90 @@
+package validation
+
+import (
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/hi"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	entranslations "github.com/go-playground/validator/v10/translations/en"
+	estranslations "github.com/go-playground/validator/v10/translations/es"
+)
+
+// validator ships sentences for every built-in rule in en and es. ours are below, {0} is the field and {1} the
+// parameter of the rule (the 100 of lte=100). hi has no built-in set, so the rules the api uses are written out here
+var sentences = map[string]map[string]string{
+	"en": {
+		"name":         "{0} may only have letters, spaces, dots, apostrophes and dashes",
+		"email_domain": "{0} must be an email of an allowed domain",
+		"phone":        "{0} must be a phone number like +919876543210",
+	},
+	"es": {
+		"name":         "{0} solo puede tener letras, espacios, puntos, apóstrofos y guiones",
+		"email_domain": "{0} debe ser un correo de un dominio permitido",
+		"phone":        "{0} debe ser un teléfono como +34612345678",
+	},
+	"hi": {
+		"required":     "{0} आवश्यक है",
+		"email":        "{0} एक मान्य ईमेल होना चाहिए",
+		"url":          "{0} एक मान्य url होना चाहिए",
+		"alphanum":     "{0} में केवल अक्षर और अंक हो सकते हैं",
+		"gte":          "{0} कम से कम {1} होना चाहिए",
+		"lte":          "{0} अधिकतम {1} हो सकता है",
+		"min":          "{0} कम से कम {1} होना चाहिए",
+		"max":          "{0} अधिकतम {1} हो सकता है",
+		"oneof":        "{0} इनमें से एक होना चाहिए: {1}",
+		"gtefield":     "{0} कम से कम {1} जितना होना चाहिए",
+		"name":         "{0} में केवल अक्षर, रिक्त स्थान, बिंदु, एपॉस्ट्रॉफ़ी और हाइफ़न हो सकते हैं",
+		"email_domain": "{0} अनुमत डोमेन का ईमेल होना चाहिए",
+		"phone":        "{0} +919876543210 जैसा फ़ोन नंबर होना चाहिए",
+	},
+}
+
+// translators makes the sentences of every language for v. a translator only takes a sentence once, so every
+// validator gets its own set
+func translators(v *validator.Validate) (*ut.UniversalTranslator, error) {
+	english := en.New()
+	uni := ut.New(english, english, es.New(), hi.New())
+
+	enTrans, _ := uni.GetTranslator("en")
+	if err := entranslations.RegisterDefaultTranslations(v, enTrans); err != nil {
+		return nil, err
+	}
+	esTrans, _ := uni.GetTranslator("es")
+	if err := estranslations.RegisterDefaultTranslations(v, esTrans); err != nil {
+		return nil, err
+	}
+	for lang, tags := range sentences {
+		trans, _ := uni.GetTranslator(lang)
+		for tag, sentence := range tags {
+			register := func(t ut.Translator) error { return t.Add(tag, sentence, true) }
+			if err := v.RegisterTranslation(tag, trans, register, translate); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return uni, nil
+}
+
+func translate(t ut.Translator, fe validator.FieldError) string {
+	msg, err := t.T(fe.Tag(), fe.Field(), fe.Param())
+	if err != nil {
+		return fe.Error()
+	}
+	return msg
+}
+
+// Message is the sentence for a failed field in lang, like "age must be 100 or less". false when lang has no
+// sentence for the rule, the caller falls back to the i18n catalog then
+func Message(lang string, fe validator.FieldError) (string, bool) {
+	uni := shared.Load().uni
+	trans, found := uni.GetTranslator(lang)
+	if !found {
+		return "", false
+	}
+	msg := fe.Translate(trans)
+	if msg == fe.Error() { // what validator gives back for a rule with no sentence
+		return "", false
+	}
+	return msg, true
+}
@@ -0,0 +1,200 @@
+// Package validation holds the one validator the whole server checks request bodies with. it is built once at startup
+// (validator caches what it learns about every struct, a new one per request starts from zero each time) and carries
+// our own rules next to the built-in ones -> "name", "email_domain" and "phone", tuned from the validation config,
+// and the struct rules of RegisterStruct for checks across fields.
+// errors name fields by their json name and every rule has a sentence in en, es and hi (see Message)
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+)
+
+const (
+	// DefaultNamePattern is letters of any script with spaces, dots, apostrophes and dashes between them -> "Mary-Jane O'Neil"
+	DefaultNamePattern = `^[\p{L}\p{M}][\p{L}\p{M} .'-]*$`
+	// DefaultPhonePattern is E.164 -> "+919876543210"
+	DefaultPhonePattern = `^\+[1-9][0-9]{6,14}$`
+)
+
+var (
+	shared atomic.Pointer[instance] // swapped as a whole, requests never see one that is half set up
+
+	mu      sync.Mutex
+	cfg     config.Validation
+	custom  = map[string]validator.Func{}
+	structs = map[reflect.Type]validator.StructLevelFunc{}
+)
+
+// instance is a validator with the sentences of its rules, they are registered on the validator and can not be shared
+type instance struct {
+	v   *validator.Validate
+	uni *ut.UniversalTranslator
+}
+
+func init() {
+	if err := rebuild(); err != nil {
+		panic(err)
+	}
+}
+
+// Configure sets the rules that come from config, called once at startup
+func Configure(c config.Validation) error {
+	mu.Lock()
+	defer mu.Unlock()
+	prev := cfg
+	cfg = c
+	if err := rebuild(); err != nil {
+		cfg = prev
+		return err
+	}
+	return nil
+}
+
+// Register adds a rule for the validate tags of every struct, or replaces one -> Register("isbn", fn) makes
+// `validate:"isbn"` work. meant for init and startup, not for the middle of serving
+func Register(tag string, fn validator.Func) error {
+	mu.Lock()
+	defer mu.Unlock()
+	prev, had := custom[tag]
+	custom[tag] = fn
+	if err := rebuild(); err != nil {
+		if had {
+			custom[tag] = prev
+		} else {
+			delete(custom, tag)
+		}
+		return err
+	}
+	return nil
+}
+
+// RegisterStruct adds a rule that looks at the whole of the given struct types, for checks across fields like "a
+// guardian_email is required under 18" -> fn reports every broken field with sl.ReportError(value, "guardian_email",
+// "GuardianEmail", "required_under", "18") and it comes back in validator.ValidationErrors like a tag would. the tag
+// names the sentence of the error, use a built-in one or give it one in sentences. meant for init and startup
+func RegisterStruct(fn validator.StructLevelFunc, types ...any) error {
+	mu.Lock()
+	defer mu.Unlock()
+	prev := make(map[reflect.Type]validator.StructLevelFunc, len(types))
+	for _, t := range types {
+		rt := reflect.TypeOf(t)
+		prev[rt] = structs[rt]
+		structs[rt] = fn
+	}
+	if err := rebuild(); err != nil {
+		for rt, fn := range prev {
+			if fn == nil {
+				delete(structs, rt)
+			} else {
+				structs[rt] = fn
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// Struct checks s against its validate tags, the error is validator.ValidationErrors when fields failed
+func Struct(s any) error {
+	return shared.Load().v.Struct(s)
+}
+
+// Var checks a single value against a tag, like Var(email, "required,email")
+func Var(field any, tag string) error {
+	return shared.Load().v.Var(field, tag)
+}
+
+// rebuild makes a new validator with cfg and custom, mu must be held
+func rebuild() error {
+	name, err := compile("name_pattern", cfg.NamePattern, DefaultNamePattern)
+	if err != nil {
+		return err
+	}
+	phone, err := compile("phone_pattern", cfg.PhonePattern, DefaultPhonePattern)
+	if err != nil {
+		return err
+	}
+
+	v := validator.New()
+	v.RegisterTagNameFunc(jsonName)
+	rules := map[string]validator.Func{
+		"name":         matches(name),
+		"phone":        matches(phone),
+		"email_domain": emailDomain(cfg.EmailDomains),
+	}
+	for tag, fn := range custom {
+		rules[tag] = fn
+	}
+	for tag, fn := range rules {
+		if err := v.RegisterValidation(tag, fn); err != nil {
+			return fmt.Errorf("validation: rule %q: %w", tag, err)
+		}
+	}
+	for rt, fn := range structs {
+		v.RegisterStructValidation(fn, reflect.Zero(rt).Interface())
+	}
+	uni, err := translators(v)
+	if err != nil {
+		return fmt.Errorf("validation: messages: %w", err)
+	}
+	shared.Store(&instance{v: v, uni: uni})
+	return nil
+}
+
+// jsonName makes errors name fields like clients know them -> "email" and not "Email", and "min_age" for the
+// query param of a struct filled by request.Query
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return f.Tag.Get("query")
+	}
+	return name
+}
+
+func compile(key, pattern, fallback string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		pattern = fallback
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("validation.%s: %w", key, err)
+	}
+	return re, nil
+}
+
+// matches passes strings the pattern matches, an empty string is left to required
+func matches(re *regexp.Regexp) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		s := fl.Field().String()
+		return s == "" || re.MatchString(s)
+	}
+}
+
+// emailDomain passes emails of one of the domains, every email when there are none. it only looks at the part
+// after the last @, the "email" rule checks the rest
+func emailDomain(domains []string) validator.Func {
+	allowed := make(map[string]bool, len(domains))
+	for _, d := range domains {
+		allowed[strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "@"))] = true
+	}
+	return func(fl validator.FieldLevel) bool {
+		if len(allowed) == 0 {
+			return true
+		}
+		email := fl.Field().String()
+		at := strings.LastIndex(email, "@")
+		return email == "" || (at >= 0 && allowed[strings.ToLower(email[at+1:])])
+	}
+}
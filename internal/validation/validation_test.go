@@ -0,0 +1,121 @@
+package validation_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/validation"
+)
+
+// the tests change the shared validator, so they run one after the other
+
+func TestDefaultRules(t *testing.T) {
+	type testCase struct {
+		name    string
+		value   string
+		tag     string
+		wantErr bool
+	}
+
+	tests := []testCase{
+		{name: "plain_name", value: "Asha", tag: "name"},
+		{name: "name_with_punctuation", value: "Mary-Jane O'Neil Jr.", tag: "name"},
+		{name: "devanagari_name", value: "आशा शर्मा", tag: "name"},
+		{name: "name_with_digits", value: "R2D2", tag: "name", wantErr: true},
+		{name: "name_with_markup", value: "<b>Asha</b>", tag: "name", wantErr: true},
+		{name: "empty_left_to_required", value: "", tag: "name"},
+		{name: "e164_phone", value: "+919876543210", tag: "phone"},
+		{name: "local_phone", value: "09876543210", tag: "phone", wantErr: true},
+		{name: "any_domain_without_config", value: "asha@example.com", tag: "email_domain"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validation.Var(tc.value, tc.tag)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("%q %s: want error %v, got %v", tc.value, tc.tag, tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	t.Cleanup(func() { validation.Configure(config.Validation{}) })
+
+	if err := validation.Configure(config.Validation{NamePattern: "("}); err == nil {
+		t.Fatalf("want an error for a broken pattern")
+	}
+	if err := validation.Configure(config.Validation{EmailDomains: []string{"School.edu"}}); err != nil {
+		t.Fatalf("configure: %v", err)
+	}
+	if err := validation.Var("asha@school.EDU", "email,email_domain"); err != nil {
+		t.Fatalf("allowed domain: %v", err)
+	}
+	err := validation.Struct(struct {
+		Email string `validate:"email_domain"`
+	}{Email: "asha@gmail.com"})
+	var fields validator.ValidationErrors
+	if !errors.As(err, &fields) || fields[0].Tag() != "email_domain" {
+		t.Fatalf("other domain: want an email_domain failure, got %v", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	if err := validation.Register("even", func(fl validator.FieldLevel) bool { return fl.Field().Int()%2 == 0 }); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := validation.Var(4, "even"); err != nil {
+		t.Fatalf("4: %v", err)
+	}
+	if err := validation.Var(3, "even"); err == nil {
+		t.Fatalf("3: want an error")
+	}
+}
+
+type enrollment struct {
+	Age           int    `json:"age"`
+	GuardianEmail string `json:"guardian_email" validate:"omitempty,email"`
+}
+
+func TestRegisterStruct(t *testing.T) {
+	err := validation.RegisterStruct(func(sl validator.StructLevel) {
+		e := sl.Current().Interface().(enrollment)
+		if e.Age < 18 && e.GuardianEmail == "" {
+			sl.ReportError(e.GuardianEmail, "guardian_email", "GuardianEmail", "required", "")
+		}
+	}, enrollment{})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	if err := validation.Struct(enrollment{Age: 21}); err != nil {
+		t.Fatalf("adult: %v", err)
+	}
+	if err := validation.Struct(enrollment{Age: 15, GuardianEmail: "mum@example.com"}); err != nil {
+		t.Fatalf("minor with guardian: %v", err)
+	}
+	err = validation.Struct(enrollment{Age: 15})
+	var fields validator.ValidationErrors
+	if !errors.As(err, &fields) || len(fields) != 1 || fields[0].Field() != "guardian_email" || fields[0].Tag() != "required" {
+		t.Fatalf("minor without guardian: want guardian_email required, got %v", err)
+	}
+	if msg, ok := validation.Message("en", fields[0]); !ok || msg != "guardian_email is a required field" {
+		t.Fatalf("want the sentence of the rule, got %q %v", msg, ok)
+	}
+}
+
+func BenchmarkStruct(b *testing.B) {
+	s := struct {
+		Name  string `json:"name" validate:"required,name"`
+		Email string `json:"email" validate:"required,email,email_domain"`
+		Age   int    `json:"age" validate:"required,gte=1,lte=100"`
+	}{Name: "Asha", Email: "asha@example.com", Age: 21}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := validation.Struct(s); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package warmup
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+)
+
+// Step is one piece of warm-up work, like priming prepared statements or loading reference data into a cache
+type Step struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Probe is a synthetic request sent to the in-process handler, so the first real request does not pay for
+// lazy init (validator caches, json reflection, db connections). use requests that do not change any data
+type Probe struct {
+	Method string
+	Path   string
+	Body   string
+	// Principal is who the probe is sent as, for routes that need one. it goes on the context, nil is anonymous
+	Principal *auth.Principal
+}
+
+// Run executes all steps and then all probes, stops on the first failure
+func Run(ctx context.Context, handler http.Handler, steps []Step, probes []Probe) error {
+	start := time.Now()
+	for _, step := range steps {
+		if err := step.Run(ctx); err != nil {
+			return fmt.Errorf("warm-up step %s: %w", step.Name, err)
+		}
+	}
+
+	for _, probe := range probes {
+		req, err := http.NewRequestWithContext(ctx, probe.Method, probe.Path, strings.NewReader(probe.Body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if probe.Principal != nil {
+			req = req.WithContext(auth.WithPrincipal(ctx, probe.Principal))
+		}
+		rec := &discardWriter{header: http.Header{}, status: http.StatusOK}
+		handler.ServeHTTP(rec, req) // no network, the handler is called directly
+		if rec.status >= 500 {
+			return fmt.Errorf("warm-up probe %s %s returned %d", probe.Method, probe.Path, rec.status)
+		}
+	}
+
+	slog.Info("warm-up done", slog.Int("steps", len(steps)), slog.Int("probes", len(probes)), slog.Duration("took", time.Since(start)))
+	return nil
+}
+
+// discardWriter is a ResponseWriter that only remembers the status code, the body is thrown away
+type discardWriter struct {
+	header http.Header
+	status int
+	wrote  bool
+}
+
+func (d *discardWriter) Header() http.Header {
+	return d.header
+}
+
+func (d *discardWriter) Write(b []byte) (int, error) {
+	d.wrote = true
+	return len(b), nil
+}
+
+func (d *discardWriter) WriteHeader(status int) {
+	if !d.wrote {
+		d.status = status
+		d.wrote = true
+	}
+}
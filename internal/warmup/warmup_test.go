@@ -0,0 +1,97 @@
+package warmup_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/manishtomar-cpi/go-server/internal/auth"
+	"github.com/manishtomar-cpi/go-server/internal/warmup"
+)
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	errCold := errors.New("database is not reachable")
+
+	type testCase struct {
+		name      string
+		stepErr   error // of the first step, the second one never fails
+		status    int   // the probed handler answers with it, 0 writes a body without a status
+		wantErr   string
+		wantSteps int // steps that ran
+		wantProbe bool
+	}
+
+	tests := []testCase{
+		{name: "all_ok", status: http.StatusOK, wantSteps: 2, wantProbe: true},
+		{name: "implicit_200", wantSteps: 2, wantProbe: true},
+		{name: "probe_4xx_is_fine", status: http.StatusBadRequest, wantSteps: 2, wantProbe: true},
+		{name: "probe_5xx_fails", status: http.StatusServiceUnavailable, wantErr: "warm-up probe POST /api/v1/auth/login returned 503", wantSteps: 2, wantProbe: true},
+		{name: "step_fails", stepErr: errCold, wantErr: "warm-up step storage: database is not reachable", wantSteps: 1},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var ran int
+			steps := []warmup.Step{
+				{Name: "storage", Run: func(context.Context) error { ran++; return tc.stepErr }},
+				{Name: "cache", Run: func(context.Context) error { ran++; return nil }},
+			}
+			var probed bool
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				probed = true
+				if r.Header.Get("Content-Type") != "application/json" {
+					t.Errorf("probe sent Content-Type %q, want application/json", r.Header.Get("Content-Type"))
+				}
+				if tc.status != 0 {
+					w.WriteHeader(tc.status)
+				}
+				w.Write([]byte(`{}`))
+			})
+			probes := []warmup.Probe{{Method: http.MethodPost, Path: "/api/v1/auth/login", Body: "{}"}}
+
+			err := warmup.Run(context.Background(), handler, steps, probes)
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Fatalf("Run: %v", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Fatalf("want error %q, got %v", tc.wantErr, err)
+			}
+			if tc.stepErr != nil && !errors.Is(err, tc.stepErr) {
+				t.Fatalf("want the step error wrapped, got %v", err)
+			}
+			if ran != tc.wantSteps || probed != tc.wantProbe {
+				t.Fatalf("ran %d steps and probed %v, want %d and %v", ran, probed, tc.wantSteps, tc.wantProbe)
+			}
+		})
+	}
+}
+
+// a probe with a principal reaches the handler as it, like a request Authenticate let through
+func TestRunProbePrincipal(t *testing.T) {
+	t.Parallel()
+
+	reader := &auth.Principal{Subject: "warm-up", Kind: "internal", Scopes: []string{string(auth.ReadStudents)}}
+	var got []*auth.Principal
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, _ := auth.PrincipalFrom(r.Context())
+		got = append(got, p)
+	})
+	probes := []warmup.Probe{
+		{Method: http.MethodPost, Path: "/api/v1/auth/login", Body: "{}"},
+		{Method: http.MethodGet, Path: "/api/v1/students?limit=1", Principal: reader},
+	}
+
+	if err := warmup.Run(context.Background(), handler, nil, probes); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(got) != 2 || got[0] != nil || got[1] != reader {
+		t.Fatalf("probes sent as %v, want anonymous and then %v", got, reader)
+	}
+}
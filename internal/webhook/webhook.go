@@ -0,0 +1,243 @@
+// Package webhook delivers public events to the urls admins registered, signed and retried with backoff.
+// deliveries are queued in storage first and sent as background jobs, so a restart or a receiver that is down for a
+// while loses nothing
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/anomaly"
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/events"
+	"github.com/manishtomar-cpi/go-server/internal/jobs"
+	"github.com/manishtomar-cpi/go-server/internal/storage"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+)
+
+// headers every delivery carries, receivers check the signature before trusting the body
+const (
+	SignatureHeader = "X-Webhook-Signature" // sha256=<hex hmac of "<timestamp>.<body>">
+	TimestampHeader = "X-Webhook-Timestamp" // unix seconds, lets receivers refuse old replays
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery" // same for every retry of one delivery, for de-duplicating
+)
+
+// Sign is the signature of body sent at timestamp, receivers compute the same with their copy of the secret
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewSecret makes a signing secret for a webhook that was registered without one
+func NewSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Dispatcher queues a delivery for every public event, the job queue sends them
+type Dispatcher struct {
+	store  storage.WebhookStore
+	queue  *jobs.Queue
+	clock  clock.Clock
+	cfg    config.Webhooks
+	client *http.Client
+	failed *anomaly.Recorder // deliveries that failed for good, nil in tests
+}
+
+func NewDispatcher(store storage.WebhookStore, bus *events.Bus, queue *jobs.Queue, cfg config.Webhooks, clk clock.Clock, rec *anomaly.Recorder) *Dispatcher {
+	// zero values come from configs built in code (tests), they get the same defaults as the yaml
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 8
+	}
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = 10 * time.Second
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = time.Hour
+	}
+	d := &Dispatcher{
+		store:  store,
+		queue:  queue,
+		clock:  clk,
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		failed: rec,
+	}
+	queue.Register(types.DeliveryJob, jobs.Kind{
+		Handler:     d.deliver,
+		MaxAttempts: cfg.MaxAttempts,
+		BaseDelay:   cfg.BaseDelay,
+		MaxDelay:    cfg.MaxDelay,
+		Timeout:     cfg.Timeout,
+		Revive:      d.revive,
+	})
+	bus.Subscribe(d.enqueue)
+	return d
+}
+
+// enqueue runs in the publisher goroutine, it only writes the rows (a delivery and its job per webhook) and wakes the queue up
+func (d *Dispatcher) enqueue(ctx context.Context, e events.Event) {
+	if !events.Public[e.EventType()] {
+		return
+	}
+	body, err := events.Marshal(e)
+	if err != nil {
+		return
+	}
+	// the request that published the event may be cancelled right after, the rows must still be written
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := d.store.EnqueueDeliveries(ctx, e.EventType(), string(body), d.clock.Now()); err != nil {
+		slog.ErrorContext(ctx, "queue webhook deliveries failed", slog.String("event", e.EventType()), slog.String("error", err.Error()))
+		return
+	}
+	d.queue.Wake()
+}
+
+// deliver is the job of one delivery, it sends it and keeps the delivery row up to date for the admin endpoints.
+// the queue decides when the next attempt runs, the row shows the same time
+func (d *Dispatcher) deliver(ctx context.Context, job types.Job) error {
+	id, err := strconv.ParseInt(job.Payload, 10, 64)
+	if err != nil {
+		return jobs.Permanent(fmt.Errorf("delivery id %q: %w", job.Payload, err))
+	}
+	delivery, err := d.store.DeliveryById(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		return jobs.Permanent(err)
+	}
+	if err != nil {
+		return err
+	}
+	if delivery.Status != types.DeliveryPending { // sent before a crash that kept the job from being marked done
+		return nil
+	}
+	hooks, err := d.store.ListWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+	hook, ok := findHook(hooks, delivery.WebhookId)
+	if !ok {
+		delivery.Status = types.DeliveryFailed
+		delivery.LastError = "webhook was deleted"
+		delivery.NextAttemptAt = nil
+		d.save(ctx, delivery)
+		return jobs.Permanent(errors.New(delivery.LastError))
+	}
+
+	// jobs.retry may have changed the policy of the kind, the queue has the one in use
+	kind := d.queue.Kind(job.Kind)
+	delivery.Attempts = job.Attempts
+	status, err := d.send(ctx, hook, delivery)
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return ctx.Err() // shutting down, the attempt does not count and the row stays as it was
+	}
+	now := d.clock.Now()
+	delivery.LastStatus = status
+	switch {
+	case err == nil:
+		delivery.Status = types.DeliveryDelivered
+		delivery.LastError = ""
+		delivery.NextAttemptAt = nil
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= kind.MaxAttempts:
+		delivery.Status = types.DeliveryFailed
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = nil
+		slog.WarnContext(ctx, "webhook delivery failed for good", slog.Int64("delivery", delivery.Id),
+			slog.Int64("webhook", hook.Id), slog.Int("attempts", delivery.Attempts), slog.String("error", err.Error()))
+		if d.failed != nil {
+			d.failed.Record(anomaly.WebhookFailure, "webhook delivery failed for good", map[string]string{
+				"delivery": strconv.FormatInt(delivery.Id, 10),
+				"webhook":  strconv.FormatInt(hook.Id, 10),
+				"url":      hook.URL,
+				"error":    err.Error(),
+			})
+		}
+	default:
+		next := now.Add(jobs.Backoff(kind.BaseDelay, kind.MaxDelay, delivery.Attempts)) // the latest it runs, jitter may be sooner
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = &next
+	}
+	d.save(ctx, delivery)
+	return err
+}
+
+// revive sets a failed delivery back to pending, for a dead job that is requeued
+func (d *Dispatcher) revive(ctx context.Context, payload string) error {
+	id, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		return fmt.Errorf("delivery id %q: %w", payload, err)
+	}
+	delivery, err := d.store.DeliveryById(ctx, id)
+	if err != nil || delivery.Status != types.DeliveryFailed {
+		return err
+	}
+	now := d.clock.Now()
+	delivery.Status = types.DeliveryPending
+	delivery.NextAttemptAt = &now
+	return d.store.UpdateDelivery(ctx, delivery)
+}
+
+func findHook(hooks []types.Webhook, id int64) (types.Webhook, bool) {
+	for _, hook := range hooks {
+		if hook.Id == id {
+			return hook, true
+		}
+	}
+	return types.Webhook{}, false
+}
+
+// save gets its own ctx, the one of the attempt may have timed out while sending
+func (d *Dispatcher) save(ctx context.Context, delivery types.WebhookDelivery) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := d.store.UpdateDelivery(ctx, delivery); err != nil {
+		slog.ErrorContext(ctx, "save webhook delivery failed", slog.Int64("delivery", delivery.Id), slog.String("error", err.Error()))
+	}
+}
+
+// send posts the payload, anything but a 2xx is a failure. status is 0 when there was no answer at all
+func (d *Dispatcher) send(ctx context.Context, hook types.Webhook, delivery types.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := d.clock.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-server-webhooks")
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, timestamp, body))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.Id, 10))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10)) // drain a little so the connection can be reused
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("receiver answered %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
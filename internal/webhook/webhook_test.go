@@ -0,0 +1,111 @@
+package webhook_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/manishtomar-cpi/go-server/internal/anomaly"
+	"github.com/manishtomar-cpi/go-server/internal/clock"
+	"github.com/manishtomar-cpi/go-server/internal/config"
+	"github.com/manishtomar-cpi/go-server/internal/events"
+	"github.com/manishtomar-cpi/go-server/internal/jobs"
+	"github.com/manishtomar-cpi/go-server/internal/storage/sqlite"
+	"github.com/manishtomar-cpi/go-server/internal/types"
+	"github.com/manishtomar-cpi/go-server/internal/webhook"
+)
+
+func TestDispatcher(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		failures     int32 // receiver answers 500 this many times before 200
+		wantStatus   string
+		wantAttempts int
+	}{
+		{name: "first try", failures: 0, wantStatus: types.DeliveryDelivered, wantAttempts: 1},
+		{name: "retried", failures: 2, wantStatus: types.DeliveryDelivered, wantAttempts: 3},
+		{name: "gives up", failures: 10, wantStatus: types.DeliveryFailed, wantAttempts: 3},
+	}
+
+	for _, tc := range tests {
+		tc := tc // capture range variable
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			const secret = "a-secret-of-some-length"
+			var calls, badSignatures atomic.Int32
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				ts, _ := strconv.ParseInt(r.Header.Get(webhook.TimestampHeader), 10, 64)
+				if r.Header.Get(webhook.SignatureHeader) != webhook.Sign(secret, ts, body) || r.Header.Get(webhook.EventHeader) != events.StudentCreatedType {
+					badSignatures.Add(1)
+				}
+				if calls.Add(1) <= tc.failures {
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+			}))
+			t.Cleanup(receiver.Close)
+
+			store, err := sqlite.New(&config.Config{Storage_path: filepath.Join(t.TempDir(), "test.db")})
+			if err != nil {
+				t.Fatalf("sqlite: %v", err)
+			}
+			t.Cleanup(func() { store.Close() })
+			clk := clock.NewFake(time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC))
+			hookId, _ := store.CreateWebhook(context.Background(), types.Webhook{URL: receiver.URL, Secret: secret, Events: []string{events.StudentCreatedType}, CreatedAt: clk.Now()})
+
+			bus := events.NewBus()
+			queue := jobs.New(store, config.Jobs{PollInterval: 5 * time.Millisecond}, clk, nil)
+			anomalies := anomaly.NewRecorder(10, clk)
+			webhook.NewDispatcher(store, bus, queue, config.Webhooks{MaxAttempts: 3, BaseDelay: time.Minute}, clk, anomalies)
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() { queue.Run(ctx); close(done) }()
+			t.Cleanup(func() { cancel(); <-done; queue.Drain(context.Background()) })
+
+			created, _ := events.NewStudentCreated(types.Student{Id: 1, Name: "Asha", Email: "asha@example.com", Age: 21}, clk.Now())
+			updated, _ := events.NewStudentUpdated(types.Student{Id: 1, Name: "Asha", Email: "asha@example.com", Age: 22}, clk.Now())
+			bus.Publish(context.Background(), created)
+			bus.Publish(context.Background(), updated) // the webhook did not ask for it
+
+			// each retry only becomes due once the fake clock passed its backoff
+			var delivery types.WebhookDelivery
+			deadline := time.Now().Add(5 * time.Second)
+			for time.Now().Before(deadline) {
+				deliveries, err := store.ListDeliveries(context.Background(), hookId, 10)
+				if err != nil {
+					t.Fatalf("list deliveries: %v", err)
+				}
+				if len(deliveries) != 1 {
+					t.Fatalf("want exactly one delivery, got %d", len(deliveries))
+				}
+				delivery = deliveries[0]
+				if delivery.Status != types.DeliveryPending {
+					break
+				}
+				clk.Advance(10 * time.Second)
+				time.Sleep(5 * time.Millisecond)
+			}
+
+			if delivery.Status != tc.wantStatus || delivery.Attempts != tc.wantAttempts {
+				t.Fatalf("delivery = %s after %d attempts, want %s after %d", delivery.Status, delivery.Attempts, tc.wantStatus, tc.wantAttempts)
+			}
+			recorded := anomalies.Snapshot()
+			failed := len(recorded) == 1 && recorded[0].Kind == anomaly.WebhookFailure && recorded[0].Recent[0].Attrs["url"] == receiver.URL
+			if wantFailed := tc.wantStatus == types.DeliveryFailed; failed != wantFailed || (!wantFailed && len(recorded) != 0) {
+				t.Fatalf("anomalies = %+v, want a webhook failure: %v", recorded, wantFailed)
+			}
+			if n := badSignatures.Load(); n != 0 {
+				t.Fatalf("%d requests had a wrong signature or event header", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+// k6 run loadtest/k6.js, against the server of loadtest/config.yaml unless BASE_URL says otherwise.
+// a mix like real use -> mostly reads, some creates. the thresholds fail the run when p95 or errors go up
+import http from 'k6/http';
+import { check } from 'k6';
+import exec from 'k6/execution';
+
+const base = __ENV.BASE_URL || 'http://localhost:8090';
+
+export const options = {
+  scenarios: {
+    mixed: { executor: 'constant-arrival-rate', rate: Number(__ENV.RATE || 200), timeUnit: '1s', duration: __ENV.DURATION || '30s', preAllocatedVUs: 50 },
+  },
+  thresholds: {
+    http_req_failed: ['rate<0.01'],
+    'http_req_duration{op:create}': ['p(95)<50'],
+    'http_req_duration{op:get}': ['p(95)<20'],
+    'http_req_duration{op:list}': ['p(95)<30'],
+  },
+};
+
+export function setup() {
+  const res = http.post(`${base}/api/v1/auth/login`, JSON.stringify({ username: __ENV.USERNAME || 'loadtest', password: __ENV.PASSWORD || 'loadtest' }),
+    { headers: { 'Content-Type': 'application/json' } });
+  check(res, { 'logged in': (r) => r.status === 200 });
+  const token = res.json('access_token');
+  const headers = { 'Content-Type': 'application/json', Authorization: `Bearer ${token}` };
+  const first = http.post(`${base}/api/v1/students`, JSON.stringify({ name: 'Seed', email: `seed-${Date.now()}@example.com`, age: 21 }), { headers });
+  return { headers, id: first.json('data.id') };
+}
+
+export default function (data) {
+  const n = exec.scenario.iterationInTest;
+  const roll = n % 10;
+  if (roll < 2) {
+    const body = JSON.stringify({ name: 'Asha', email: `asha-${exec.vu.idInTest}-${n}-${Date.now()}@example.com`, age: 21 });
+    check(http.post(`${base}/api/v1/students`, body, { headers: data.headers, tags: { op: 'create' } }), { created: (r) => r.status === 201 });
+  } else if (roll < 7) {
+    check(http.get(`${base}/api/v1/students/${data.id}`, { headers: data.headers, tags: { op: 'get' } }), { got: (r) => r.status === 200 });
+  } else {
+    check(http.get(`${base}/api/v1/students?limit=50`, { headers: data.headers, tags: { op: 'list' } }), { listed: (r) => r.status === 200 });
+  }
+}
@@ -0,0 +1,19 @@
+#!/usr/bin/env sh
+# reads at a fixed rate with vegeta -> RATE=500 DURATION=30s loadtest/vegeta.sh, the report goes to stdout and the raw
+# results to loadtest/results.bin for `vegeta plot` or to compare two runs
+set -eu
+
+BASE_URL=${BASE_URL:-http://localhost:8090}
+RATE=${RATE:-500}
+DURATION=${DURATION:-30s}
+dir=$(dirname "$0")
+
+token=$(curl -fsS -H 'Content-Type: application/json' -d '{"username":"'"${USERNAME:-loadtest}"'","password":"'"${PASSWORD:-loadtest}"'"}' \
+  "$BASE_URL/api/v1/auth/login" | sed -n 's/.*"access_token":"\([^"]*\)".*/\1/p')
+id=$(curl -fsS -H 'Content-Type: application/json' -H "Authorization: Bearer $token" \
+  -d '{"name":"Seed","email":"seed-'"$(date +%s)"'@example.com","age":21}' \
+  "$BASE_URL/api/v1/students" | sed -n 's/.*"id":\([0-9]*\).*/\1/p')
+
+printf 'GET %s/api/v1/students/%s\nAuthorization: Bearer %s\n\nGET %s/api/v1/students?limit=50\nAuthorization: Bearer %s\n' \
+  "$BASE_URL" "$id" "$token" "$BASE_URL" "$token" |
+  vegeta attack -rate="$RATE" -duration="$DURATION" | tee "$dir/results.bin" | vegeta report